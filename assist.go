@@ -0,0 +1,201 @@
+// assist.go drafts the fields of the task form with Claude. Given a title
+// and description, a short run without file editing tools suggests acceptance
+// criteria, a task type, a priority and an iteration estimate. The
+// suggestions are only returned; the user applies them in the form. The
+// one-shot Claude run is shared with the task breakdown (see split.go).
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// assistTimeout bounds the Claude run that drafts a task
+const assistTimeout = 2 * time.Minute
+
+// maxSuggestedIterations caps the iteration estimate, like the task form
+const maxSuggestedIterations = 100
+
+// askClaude sends prompt to Claude in dir as a single message, without its
+// file editing tools, and returns the final answer
+func (r *RalphRunner) askClaude(ctx context.Context, dir, prompt string, timeout time.Duration) (string, error) {
+	config, err := r.db.GetConfig()
+	if err != nil {
+		return "", err
+	}
+	cli := claudeCLI.Get(claudeCommand(config))
+	args, err := cli.Args(true, config.Claude)
+	if err != nil {
+		return "", err
+	}
+	input, err := cli.encodePrompt(prompt)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The only message is the prompt, Claude exits after answering it
+	cmd := exec.CommandContext(ctx, cli.Command, args...)
+	cmd.Dir = dir
+	runInOwnProcessGroup(cmd)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("Claude did not answer within %s", timeout)
+		}
+		return "", fmt.Errorf("Claude failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return lastRalphMessage(stdout.String()), nil
+}
+
+// extractJSONObject returns the outermost JSON object of a Claude answer,
+// which may be wrapped in prose or a code fence
+func extractJSONObject(text string) ([]byte, error) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("Claude's answer contains no JSON object")
+	}
+	return []byte(text[start : end+1]), nil
+}
+
+// BuildAssistPrompt generates the prompt that drafts a task
+func BuildAssistPrompt(req TaskAssistRequest, taskTypes []TaskType, defaultIterations int) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("# Draft: %s\n\n", req.Title))
+
+	if req.Description != "" {
+		sb.WriteString("## Description\n\n")
+		sb.WriteString(req.Description)
+		sb.WriteString("\n\n")
+	}
+
+	sb.WriteString("## Instructions\n\n")
+	sb.WriteString("This task is about to be created for an autonomous coding agent. Do NOT create, modify or delete any files. ")
+	sb.WriteString("Look at the codebase only as far as needed, then suggest:\n\n")
+	sb.WriteString("- `acceptance_criteria`: a Markdown list of concrete, verifiable criteria\n")
+	sb.WriteString("- `task_type`: one of ")
+	names := make([]string, 0, len(taskTypes))
+	for _, tt := range taskTypes {
+		names = append(names, fmt.Sprintf("%q", tt.Name))
+	}
+	sb.WriteString(strings.Join(names, ", "))
+	sb.WriteString("\n")
+	sb.WriteString("- `priority`: 1 (high), 2 (medium) or 3 (low)\n")
+	sb.WriteString(fmt.Sprintf("- `max_iterations`: how many iterations the agent likely needs; the default is %d\n", defaultIterations))
+	sb.WriteString("- `reason`: one or two sentences explaining the estimate\n\n")
+	sb.WriteString("Answer with a single JSON object with exactly these keys and nothing else.\n")
+
+	return sb.String()
+}
+
+// parseTaskSuggestion reads Claude's answer and checks the suggestions
+// against the task types and the limits of the task form
+func parseTaskSuggestion(text string, taskTypes []TaskType) (*TaskSuggestion, error) {
+	data, err := extractJSONObject(text)
+	if err != nil {
+		return nil, err
+	}
+	var answer struct {
+		AcceptanceCriteria interface{} `json:"acceptance_criteria"`
+		TaskType           string      `json:"task_type"`
+		Priority           int         `json:"priority"`
+		MaxIterations      int         `json:"max_iterations"`
+		Reason             string      `json:"reason"`
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return nil, fmt.Errorf("Claude's answer is not a valid suggestion: %v", err)
+	}
+
+	suggestion := &TaskSuggestion{Reason: strings.TrimSpace(answer.Reason)}
+	// Criteria may come as one Markdown string or as a list of items
+	switch criteria := answer.AcceptanceCriteria.(type) {
+	case string:
+		suggestion.AcceptanceCriteria = strings.TrimSpace(criteria)
+	case []interface{}:
+		var lines []string
+		for _, item := range criteria {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				lines = append(lines, "- "+strings.TrimPrefix(strings.TrimSpace(s), "- "))
+			}
+		}
+		suggestion.AcceptanceCriteria = strings.Join(lines, "\n")
+	}
+	for _, tt := range taskTypes {
+		if strings.EqualFold(tt.Name, strings.TrimSpace(answer.TaskType)) || tt.ID == answer.TaskType {
+			suggestion.TaskTypeID = tt.ID
+			suggestion.TaskTypeName = tt.Name
+			break
+		}
+	}
+	if answer.Priority >= 1 && answer.Priority <= 3 {
+		suggestion.Priority = answer.Priority
+	}
+	if answer.MaxIterations >= 1 {
+		suggestion.MaxIterations = min(answer.MaxIterations, maxSuggestedIterations)
+	}
+	return suggestion, nil
+}
+
+// HandleTaskAssist handles POST /api/tasks/assist
+// Drafts acceptance criteria, type, priority and an iteration estimate for a
+// task that is being created. Nothing is stored.
+func (h *Handler) HandleTaskAssist(w http.ResponseWriter, r *http.Request) {
+	var req TaskAssistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		h.writeError(w, http.StatusBadRequest, "Title is required")
+		return
+	}
+
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+	taskTypes, err := h.db.GetAllTaskTypes()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task types: "+err.Error())
+		return
+	}
+	// With a project, Claude can look at the code the task is about
+	dir := req.ProjectDir
+	if req.ProjectID != "" {
+		if project, _ := h.db.GetProject(req.ProjectID); project != nil {
+			dir = project.Path
+		}
+	}
+	if dir != "" && !fileExists(dir) {
+		dir = ""
+	}
+
+	answer, err := h.runner.askClaude(r.Context(), dir, BuildAssistPrompt(req, taskTypes, config.DefaultMaxIterations), assistTimeout)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to draft the task: "+err.Error())
+		return
+	}
+	suggestion, err := parseTaskSuggestion(answer, taskTypes)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to draft the task: "+err.Error())
+		return
+	}
+	logFrom(r.Context()).Info("Drafted task", "title", req.Title, "task_type", suggestion.TaskTypeName, "max_iterations", suggestion.MaxIterations)
+	h.writeJSON(w, http.StatusOK, suggestion)
+}
@@ -0,0 +1,86 @@
+// attachforward.go copies a task's attachments into its working tree before a
+// run, so the prompt can reference them by a path relative to Claude's working
+// directory instead of by the server's upload path, which does not exist
+// inside a container or a separate worktree. The copies live in
+// .forge/attachments/<task>/, are ignored by git and are removed when the run
+// ends. If a copy fails the prompt falls back to the upload path.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// forwardedAttachmentsDir is the folder in the working tree holding the copies
+const forwardedAttachmentsDir = ".forge/attachments"
+
+// promptPath is the path under which Claude finds the attachment
+func (a Attachment) promptPath() string {
+	if a.WorkPath != "" {
+		return a.WorkPath
+	}
+	return a.Path
+}
+
+// forwardAttachments copies the attachments into the task's working tree and
+// returns them with WorkPath set to the copies
+func (r *RalphRunner) forwardAttachments(task *Task, attachments []Attachment) []Attachment {
+	if len(attachments) == 0 || task.ProjectDir == "" {
+		return attachments
+	}
+	base := filepath.Join(task.ProjectDir, task.WorkDir, forwardedAttachmentsDir)
+	dir := filepath.Join(base, task.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		r.taskLog(task.ID).Warn("Failed to create attachment folder", "dir", dir, "err", err)
+		return attachments
+	}
+	// Ignores the folder itself too, so RALPH never commits the copies
+	if err := os.WriteFile(filepath.Join(base, ".gitignore"), []byte("*\n"), 0644); err != nil {
+		r.taskLog(task.ID).Warn("Failed to write attachment .gitignore", "err", err)
+		return attachments
+	}
+
+	forwarded := make([]Attachment, len(attachments))
+	used := map[string]bool{}
+	for i, att := range attachments {
+		forwarded[i] = att
+		name := forwardedFileName(att, used)
+		if err := copyFile(att.Path, filepath.Join(dir, name)); err != nil {
+			r.taskLog(task.ID).Warn("Failed to copy attachment into working tree", "attachment", att.Filename, "err", err)
+			continue
+		}
+		forwarded[i].WorkPath = filepath.ToSlash(filepath.Join(forwardedAttachmentsDir, task.ID, name))
+	}
+	return forwarded
+}
+
+// forwardedFileName returns a safe, unique file name for the copy of att
+func forwardedFileName(att Attachment, used map[string]bool) string {
+	name := filepath.Base(strings.ReplaceAll(att.Filename, "\\", "/"))
+	if name == "." || name == "/" || name == ".." || strings.HasPrefix(name, ".") {
+		name = att.ID + filepath.Ext(att.Path)
+	}
+	unique := name
+	for n := 2; used[unique]; n++ {
+		ext := filepath.Ext(name)
+		unique = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	used[unique] = true
+	return unique
+}
+
+// removeForwardedAttachments deletes the copies of a run; the .forge folder
+// goes as well once no other task uses it
+func removeForwardedAttachments(task *Task) {
+	if task == nil || task.ProjectDir == "" {
+		return
+	}
+	base := filepath.Join(task.ProjectDir, task.WorkDir, forwardedAttachmentsDir)
+	os.RemoveAll(filepath.Join(base, task.ID))
+	if entries, err := os.ReadDir(base); err == nil && len(entries) == 1 && entries[0].Name() == ".gitignore" {
+		os.RemoveAll(base)
+		os.Remove(filepath.Dir(base)) // Only succeeds if .forge is empty
+	}
+}
@@ -0,0 +1,297 @@
+// attachments.go decides which files may be attached to a task and turns
+// textual attachments into prompt context. The file type is sniffed from the
+// content rather than trusted from the upload, checked against the configured
+// allowlist (Config.AttachmentTypes) and the size limit of its kind.
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultAttachmentTypes is the allowlist used when Config.AttachmentTypes is empty
+const DefaultAttachmentTypes = "image/*, video/*, text/*, application/pdf, application/json"
+
+// Kinds of attachments; each kind has its own size limit
+const (
+	AttachmentKindImage    = "image"
+	AttachmentKindVideo    = "video"
+	AttachmentKindText     = "text"
+	AttachmentKindDocument = "document"
+)
+
+// attachmentSizeLimits are the maximum upload sizes per kind (MaxUploadSize is the overall cap)
+var attachmentSizeLimits = map[string]int64{
+	AttachmentKindImage:    20 * 1024 * 1024,
+	AttachmentKindVideo:    MaxUploadSize,
+	AttachmentKindText:     5 * 1024 * 1024,
+	AttachmentKindDocument: 20 * 1024 * 1024,
+}
+
+// binaryAttachmentTypes maps the supported binary MIME types to kind and file extension
+var binaryAttachmentTypes = map[string]struct{ kind, ext string }{
+	"image/png":       {AttachmentKindImage, ".png"},
+	"image/jpeg":      {AttachmentKindImage, ".jpg"},
+	"image/gif":       {AttachmentKindImage, ".gif"},
+	"image/webp":      {AttachmentKindImage, ".webp"},
+	"video/mp4":       {AttachmentKindVideo, ".mp4"},
+	"video/webm":      {AttachmentKindVideo, ".webm"},
+	"video/quicktime": {AttachmentKindVideo, ".mov"},
+	"application/pdf": {AttachmentKindDocument, ".pdf"},
+}
+
+// textAttachmentTypes maps file extensions of text files to their MIME type.
+// Text with any other extension is stored as text/plain.
+var textAttachmentTypes = map[string]string{
+	".csv":   "text/csv",
+	".tsv":   "text/tab-separated-values",
+	".md":    "text/markdown",
+	".diff":  "text/x-diff",
+	".patch": "text/x-diff",
+	".json":  "application/json",
+	".yaml":  "text/yaml",
+	".yml":   "text/yaml",
+}
+
+// Limits for inlining attachment text into the RALPH prompt
+const (
+	promptAttachmentLimit = 20 * 1024  // Per attachment
+	promptAttachmentTotal = 100 * 1024 // All attachments of a task
+)
+
+// attachmentKind returns the kind of a stored MIME type
+func attachmentKind(mimeType string) string {
+	if t, ok := binaryAttachmentTypes[mimeType]; ok {
+		return t.kind
+	}
+	if strings.HasPrefix(mimeType, "text/") || mimeType == "application/json" {
+		return AttachmentKindText
+	}
+	return ""
+}
+
+// sniffAttachment determines the MIME type of an upload from its first bytes and
+// its filename. Files whose content contradicts a known extension are rejected,
+// as is binary content other than the supported image, video and PDF formats.
+func sniffAttachment(head []byte, filename string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	mimeType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+
+	// QuickTime and some MP4 brands are not recognized by DetectContentType
+	if mimeType == "application/octet-stream" && len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		mimeType = "video/mp4"
+		if string(head[8:12]) == "qt  " {
+			mimeType = "video/quicktime"
+		}
+	}
+
+	// A known extension must name the same kind of file as the content
+	extKind := ""
+	for _, t := range binaryAttachmentTypes {
+		if t.ext == ext || (ext == ".jpeg" && t.ext == ".jpg") {
+			extKind = t.kind
+		}
+	}
+
+	if t, ok := binaryAttachmentTypes[mimeType]; ok {
+		if extKind != "" && extKind != t.kind {
+			return "", fmt.Errorf("file content (%s) does not match its extension %s", mimeType, ext)
+		}
+		return mimeType, nil
+	}
+	if strings.HasPrefix(mimeType, "text/") {
+		if extKind != "" {
+			return "", fmt.Errorf("file content (text) does not match its extension %s", ext)
+		}
+		if textType, ok := textAttachmentTypes[ext]; ok {
+			return textType, nil
+		}
+		return "text/plain", nil
+	}
+
+	return "", fmt.Errorf("unsupported file content (%s)", mimeType)
+}
+
+// trimIncompleteRune cuts a UTF-8 sequence that was split at the end of a truncated buffer
+func trimIncompleteRune(b []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// attachmentTypeAllowed reports whether mimeType matches one of the comma-separated patterns
+func attachmentTypeAllowed(patterns, mimeType string) bool {
+	if strings.TrimSpace(patterns) == "" {
+		patterns = DefaultAttachmentTypes
+	}
+	for _, p := range strings.Split(patterns, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch {
+		case p == "*" || p == "*/*" || p == mimeType:
+			return true
+		case strings.HasSuffix(p, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(p, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeAttachmentTypes validates and cleans up an allowlist from the settings
+func normalizeAttachmentTypes(patterns string) (string, error) {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, p := range strings.Split(patterns, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" || seen[p] {
+			continue
+		}
+		typ, sub, ok := strings.Cut(p, "/")
+		if p != "*" && (!ok || typ == "" || sub == "" || strings.ContainsAny(p, " ;") || (typ == "*" && sub != "*")) {
+			return "", fmt.Errorf("invalid attachment type %q (use e.g. image/* or application/pdf)", p)
+		}
+		seen[p] = true
+		cleaned = append(cleaned, p)
+	}
+	return strings.Join(cleaned, ", "), nil
+}
+
+// attachmentExtension returns the extension a file of mimeType is stored with.
+// Text is always stored as .txt so the uploads directory never serves markup.
+func attachmentExtension(mimeType string) string {
+	if t, ok := binaryAttachmentTypes[mimeType]; ok {
+		return t.ext
+	}
+	return ".txt"
+}
+
+// attachmentContentType returns the Content-Type an attachment is served with
+func attachmentContentType(mimeType string) string {
+	if attachmentKind(mimeType) == AttachmentKindText {
+		return "text/plain; charset=utf-8"
+	}
+	if _, ok := binaryAttachmentTypes[mimeType]; ok {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// uploadContentType returns the Content-Type for a file below the uploads directory by extension
+func uploadContentType(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	for mimeType, t := range binaryAttachmentTypes {
+		if t.ext == ext {
+			return mimeType
+		}
+	}
+	if ext == ".txt" {
+		return "text/plain; charset=utf-8"
+	}
+	// Uploads from before content sniffing kept the original extension (e.g. .jpeg)
+	if t := mime.TypeByExtension(ext); strings.HasPrefix(t, "image/") || strings.HasPrefix(t, "video/") {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// ============================================================================
+// Prompt Context
+// ============================================================================
+
+// attachmentText returns up to limit bytes of text from att; ok is false if the
+// attachment has no extractable text. PDFs are converted with pdftotext if installed.
+func attachmentText(att Attachment, limit int) (text string, truncated bool, ok bool) {
+	var data []byte
+	switch attachmentKind(att.MimeType) {
+	case AttachmentKindText:
+		f, err := os.Open(att.Path)
+		if err != nil {
+			return "", false, false
+		}
+		defer f.Close()
+		data, err = io.ReadAll(io.LimitReader(f, int64(limit)+1))
+		if err != nil {
+			return "", false, false
+		}
+
+	case AttachmentKindDocument:
+		pdftotext, err := exec.LookPath("pdftotext")
+		if err != nil {
+			return "", false, false
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		data, err = exec.CommandContext(ctx, pdftotext, "-layout", att.Path, "-").Output()
+		if err != nil {
+			return "", false, false
+		}
+
+	default:
+		return "", false, false
+	}
+
+	if len(data) > limit {
+		data, truncated = trimIncompleteRune(data[:limit]), true
+	}
+	return string(bytes.ToValidUTF8(data, []byte("�"))), truncated, true
+}
+
+// writeAttachmentContents appends the text of textual attachments to the prompt
+func writeAttachmentContents(sb *strings.Builder, attachments []Attachment) {
+	budget := promptAttachmentTotal
+	header := false
+	for _, att := range attachments {
+		if budget <= 0 {
+			break
+		}
+		text, truncated, ok := attachmentText(att, min(promptAttachmentLimit, budget))
+		if !ok || strings.TrimSpace(text) == "" {
+			continue
+		}
+		budget -= len(text)
+
+		if !header {
+			sb.WriteString("## Attachment Contents\n\n")
+			header = true
+		}
+		lang := strings.TrimPrefix(strings.ToLower(filepath.Ext(att.Filename)), ".")
+		if _, known := textAttachmentTypes["."+lang]; !known {
+			lang = "text"
+		}
+		fence := codeFence(text)
+		sb.WriteString(fmt.Sprintf("### %s\n\n%s%s\n%s\n%s\n", att.Filename, fence, lang, strings.TrimRight(text, "\n"), fence))
+		if truncated {
+			sb.WriteString(fmt.Sprintf("\n(Truncated - read %s for the full content.)\n", att.promptPath()))
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// codeFence returns a backtick fence longer than any backtick run in text
+func codeFence(text string) string {
+	longest, run := 0, 0
+	for _, c := range text {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
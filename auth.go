@@ -0,0 +1,478 @@
+// auth.go adds a login with GitHub OAuth. It is off unless
+// FORGE_GITHUB_CLIENT_ID is set; then the API, the WebSocket and the uploads
+// need a session, except the login itself, share links, the health check and
+// webhooks. Only the GitHub logins in FORGE_GITHUB_ALLOWED_USERS may sign in.
+// In the browser the web flow redirects to GitHub (which needs
+// FORGE_GITHUB_CLIENT_SECRET) and sets a session cookie; the CLI signs in with
+// the device flow ("forge login") and sends the session token as a bearer
+// token. Sessions are stored as the SHA-256 of their token and last
+// sessionTTL. The user's OAuth token is kept, encrypted like the tokens of the
+// config, and with Config.GithubUserTokens it replaces the shared PAT for the
+// pushes and pull requests the user starts; profile.go adds a token and git
+// identity of the user's own.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// sessionTTL is how long a session lasts after signing in
+const sessionTTL = 30 * 24 * time.Hour
+
+// Cookies of the web flow
+const (
+	sessionCookie    = "forge_session"
+	oauthStateCookie = "forge_oauth_state"
+)
+
+// githubOAuthScope lets FORGE read the profile and push and open pull
+// requests with the user's token
+const githubOAuthScope = "repo read:user"
+
+// deviceGrantType is the grant type of the OAuth device flow
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// publicPaths need no session; /api/auth/ includes the login itself
+var publicPaths = []string{"/api/auth/", "/api/share/", "/api/health", "/api/webhooks/"}
+
+// authSettings is the login configuration read from the environment
+type authSettings struct {
+	clientID     string          // FORGE_GITHUB_CLIENT_ID
+	clientSecret string          // FORGE_GITHUB_CLIENT_SECRET, needed for the web flow
+	allowedUsers map[string]bool // FORGE_GITHUB_ALLOWED_USERS, lower case
+	githubURL    string          // OAuth endpoints, github.com outside of tests
+}
+
+// authConfig is the process-wide login configuration
+var authConfig = authSettingsFromEnv()
+
+// authSettingsFromEnv reads the FORGE_GITHUB_CLIENT_* and FORGE_GITHUB_ALLOWED_USERS variables
+func authSettingsFromEnv() authSettings {
+	s := authSettings{
+		clientID:     os.Getenv("FORGE_GITHUB_CLIENT_ID"),
+		clientSecret: os.Getenv("FORGE_GITHUB_CLIENT_SECRET"),
+		allowedUsers: make(map[string]bool),
+		githubURL:    "https://github.com",
+	}
+	for _, login := range splitList(os.Getenv("FORGE_GITHUB_ALLOWED_USERS")) {
+		s.allowedUsers[strings.ToLower(login)] = true
+	}
+	return s
+}
+
+// enabled reports whether FORGE requires a login
+func (s authSettings) enabled() bool {
+	return s.clientID != ""
+}
+
+// validate rejects a login anyone with a GitHub account could pass
+func (s authSettings) validate() error {
+	if s.clientSecret != "" && s.clientID == "" {
+		return fmt.Errorf("FORGE_GITHUB_CLIENT_SECRET needs FORGE_GITHUB_CLIENT_ID")
+	}
+	if s.enabled() && len(s.allowedUsers) == 0 {
+		return fmt.Errorf("set FORGE_GITHUB_ALLOWED_USERS to the GitHub logins that may sign in")
+	}
+	return nil
+}
+
+// allows reports whether a GitHub login may sign in
+func (s authSettings) allows(login string) bool {
+	return s.allowedUsers[strings.ToLower(login)]
+}
+
+// authRequired reports whether a request to path needs a session
+func authRequired(path string) bool {
+	if path == "/ws" || strings.HasPrefix(path, "/uploads/") {
+		return true
+	}
+	if !strings.HasPrefix(path, "/api/") {
+		return false // The frontend itself, which shows the login
+	}
+	for _, public := range publicPaths {
+		if strings.HasPrefix(path, public) {
+			return false
+		}
+	}
+	return true
+}
+
+type userKey struct{}
+
+// withUser returns a copy of ctx carrying the signed-in user
+func withUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// userFrom returns the signed-in user of a request, nil without login
+func userFrom(ctx context.Context) *User {
+	user, _ := ctx.Value(userKey{}).(*User)
+	return user
+}
+
+// sessionToken returns the session token of a request: the bearer token of
+// the CLI or the cookie of the browser
+func sessionToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// hashSessionToken is what a session is stored under
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken returns n random bytes, base64url encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// userCredentialName names a token of a user for sealCredential: the OAuth
+// token (column github_token) is "user:<GitHub ID>", others add their column
+func userCredentialName(githubID int64, column string) string {
+	if column == "github_token" {
+		return fmt.Sprintf("user:%d", githubID)
+	}
+	return fmt.Sprintf("user:%d:%s", githubID, column)
+}
+
+// sealUserToken encrypts a token of a user like the tokens of the config
+func sealUserToken(githubID int64, column, token string) (string, error) {
+	return sealCredential(userCredentialName(githubID, column), token)
+}
+
+// openUserToken decrypts a stored token of a user; one that cannot be
+// decrypted counts as unset until the user signs in or sets it again
+func openUserToken(githubID int64, column, stored string) string {
+	token, err := openCredential(userCredentialName(githubID, column), stored)
+	if err != nil {
+		componentLog("auth").Warn("Cannot read the GitHub token of a user", "github_id", githubID, "column", column, "err", err)
+		return ""
+	}
+	return token
+}
+
+// userGithubToken returns the token of the signed-in user (see
+// User.githubToken), "" if there is none or no one is signed in
+func userGithubToken(r *http.Request, config *Config) string {
+	token, _ := userFrom(r.Context()).githubToken(config)
+	return token
+}
+
+// githubTokenFor returns the token GitHub API calls for a request use: the
+// user's (see userGithubToken), else the shared PAT
+func githubTokenFor(r *http.Request, config *Config) string {
+	if token := userGithubToken(r, config); token != "" {
+		return token
+	}
+	return config.GithubToken
+}
+
+// pushToken returns the token pushes for a request authenticate with, "" for
+// git's own credentials
+func (h *Handler) pushToken(r *http.Request) string {
+	config, err := h.db.GetConfig()
+	if err != nil {
+		return ""
+	}
+	return userGithubToken(r, config)
+}
+
+// authMiddleware attaches the user of a valid session to the request and
+// answers 401 to requests that need one without it
+func (h *Handler) authMiddleware(next http.Handler) http.Handler {
+	if !authConfig.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := sessionToken(r); token != "" {
+			user, err := h.db.GetSessionUser(hashSessionToken(token))
+			if err != nil {
+				h.writeError(w, http.StatusInternalServerError, "Failed to check session: "+err.Error())
+				return
+			}
+			if user != nil {
+				next.ServeHTTP(w, r.WithContext(withUser(r.Context(), user)))
+				return
+			}
+		}
+		if authRequired(r.URL.Path) {
+			h.writeError(w, http.StatusUnauthorized, "Login required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// oauthResponse is the answer of GitHub's token endpoint
+type oauthResponse struct {
+	AccessToken      string `json:"access_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	Interval         int    `json:"interval"`
+}
+
+// githubOAuthPost posts a form to an OAuth endpoint of GitHub and decodes the JSON answer
+func githubOAuthPost(path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, authConfig.githubURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach GitHub: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("GitHub answered %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// oauthError turns an error answer of GitHub into a Go error
+func oauthError(resp oauthResponse) error {
+	if resp.ErrorDescription != "" {
+		return errors.New(resp.ErrorDescription)
+	}
+	return errors.New(resp.Error)
+}
+
+// callbackURL is where GitHub sends the browser back to in the web flow
+func callbackURL(r *http.Request) string {
+	return requestScheme(r) + "://" + r.Host + "/api/auth/github/callback"
+}
+
+// signIn creates a session for the owner of a GitHub OAuth token, if they may
+// sign in, and sets its cookie
+func (h *Handler) signIn(w http.ResponseWriter, r *http.Request, accessToken string) (*LoginResult, error) {
+	ghUser, err := NewGitHubClient(accessToken).ValidateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the GitHub profile: %v", err)
+	}
+	if !authConfig.allows(ghUser.Login) {
+		logFrom(r.Context()).Warn("Rejected login", "component", "auth", "login", ghUser.Login)
+		return nil, fmt.Errorf("GitHub user %s may not sign in to FORGE", ghUser.Login)
+	}
+
+	user := &User{
+		GithubID:    int64(ghUser.ID),
+		Login:       ghUser.Login,
+		Name:        ghUser.Name,
+		AvatarURL:   ghUser.AvatarURL,
+		GithubToken: accessToken,
+	}
+	if err := h.db.UpsertUser(user); err != nil {
+		return nil, fmt.Errorf("failed to save user: %v", err)
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(sessionTTL)
+	if err := h.db.CreateSession(user.ID, hashSessionToken(token), expires); err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   requestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	logFrom(r.Context()).Info("User signed in", "component", "auth", "login", user.Login)
+	return &LoginResult{User: user, Token: token, ExpiresAt: expires}, nil
+}
+
+// HandleAuthStatus handles GET /api/auth/status
+// Returns whether a login is required and who is signed in.
+func (h *Handler) HandleAuthStatus(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, AuthStatus{
+		Enabled: authConfig.enabled(),
+		WebFlow: authConfig.enabled() && authConfig.clientSecret != "",
+		User:    userFrom(r.Context()),
+	})
+}
+
+// HandleGitHubLogin handles GET /api/auth/github/login
+// Starts the web flow by redirecting the browser to GitHub.
+func (h *Handler) HandleGitHubLogin(w http.ResponseWriter, r *http.Request) {
+	if !authConfig.enabled() || authConfig.clientSecret == "" {
+		h.writeError(w, http.StatusNotFound, "GitHub login in the browser is not configured")
+		return
+	}
+	state, err := randomToken(16)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to start login: "+err.Error())
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/api/auth/github/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   requestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	query := url.Values{
+		"client_id":    {authConfig.clientID},
+		"redirect_uri": {callbackURL(r)},
+		"scope":        {githubOAuthScope},
+		"state":        {state},
+	}
+	http.Redirect(w, r, authConfig.githubURL+"/login/oauth/authorize?"+query.Encode(), http.StatusFound)
+}
+
+// HandleGitHubCallback handles GET /api/auth/github/callback
+// Finishes the web flow and sends the browser back to the board, with
+// ?login_error= if the login failed.
+func (h *Handler) HandleGitHubCallback(w http.ResponseWriter, r *http.Request) {
+	fail := func(msg string) {
+		http.Redirect(w, r, "/?login_error="+url.QueryEscape(msg), http.StatusFound)
+	}
+	if !authConfig.enabled() || authConfig.clientSecret == "" {
+		fail("GitHub login in the browser is not configured")
+		return
+	}
+	if msg := r.URL.Query().Get("error_description"); msg != "" {
+		fail(msg)
+		return
+	}
+	cookie, err := r.Cookie(oauthStateCookie)
+	state := r.URL.Query().Get("state")
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		fail("The login expired, please try again")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/api/auth/github/", MaxAge: -1})
+
+	var resp oauthResponse
+	err = githubOAuthPost("/login/oauth/access_token", url.Values{
+		"client_id":     {authConfig.clientID},
+		"client_secret": {authConfig.clientSecret},
+		"code":          {r.URL.Query().Get("code")},
+		"redirect_uri":  {callbackURL(r)},
+	}, &resp)
+	if err == nil && resp.AccessToken == "" {
+		err = oauthError(resp)
+	}
+	if err != nil {
+		logFrom(r.Context()).Warn("GitHub login failed", "component", "auth", "err", err)
+		fail("GitHub login failed: " + err.Error())
+		return
+	}
+	if _, err := h.signIn(w, r, resp.AccessToken); err != nil {
+		fail(err.Error())
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// HandleGitHubDevice handles POST /api/auth/github/device
+// Starts the device flow: returns the code the user enters on GitHub.
+func (h *Handler) HandleGitHubDevice(w http.ResponseWriter, r *http.Request) {
+	if !authConfig.enabled() {
+		h.writeError(w, http.StatusNotFound, "GitHub login is not configured")
+		return
+	}
+	var login DeviceLogin
+	err := githubOAuthPost("/login/device/code", url.Values{
+		"client_id": {authConfig.clientID},
+		"scope":     {githubOAuthScope},
+	}, &login)
+	if err == nil && login.DeviceCode == "" {
+		err = errors.New("GitHub returned no device code, is the device flow enabled for the OAuth app?")
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadGateway, "Failed to start login: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, login)
+}
+
+// HandleGitHubDeviceToken handles POST /api/auth/github/device/token
+// Polls the device flow: 202 {"status": "pending"} until the user has entered
+// the code (with "slow_down" and a new interval if polled too often), then
+// the session as a LoginResult.
+func (h *Handler) HandleGitHubDeviceToken(w http.ResponseWriter, r *http.Request) {
+	if !authConfig.enabled() {
+		h.writeError(w, http.StatusNotFound, "GitHub login is not configured")
+		return
+	}
+	var req DeviceTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DeviceCode == "" {
+		h.writeError(w, http.StatusBadRequest, "device_code is required")
+		return
+	}
+
+	var resp oauthResponse
+	err := githubOAuthPost("/login/oauth/access_token", url.Values{
+		"client_id":   {authConfig.clientID},
+		"device_code": {req.DeviceCode},
+		"grant_type":  {deviceGrantType},
+	}, &resp)
+	if err != nil {
+		h.writeError(w, http.StatusBadGateway, "Failed to finish login: "+err.Error())
+		return
+	}
+	switch resp.Error {
+	case "":
+	case "authorization_pending":
+		h.writeJSON(w, http.StatusAccepted, map[string]string{"status": "pending"})
+		return
+	case "slow_down":
+		h.writeJSON(w, http.StatusAccepted, map[string]interface{}{"status": "slow_down", "interval": resp.Interval})
+		return
+	default:
+		h.writeError(w, http.StatusBadRequest, "GitHub login failed: "+oauthError(resp).Error())
+		return
+	}
+
+	result, err := h.signIn(w, r, resp.AccessToken)
+	if err != nil {
+		h.writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// HandleLogout handles POST /api/auth/logout
+func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if token := sessionToken(r); token != "" {
+		if err := h.db.DeleteSession(hashSessionToken(token)); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to end session: "+err.Error())
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "logged_out"})
+}
@@ -0,0 +1,93 @@
+// automerge.go merges the pull requests of tasks in branch-per-task projects
+// that have auto_merge set to a merge method (merge, squash or rebase). During
+// the pull request sync (see prsync.go) the open pull request of a task in
+// review is merged once GitHub reports it clean, meaning its checks passed and
+// the branch protection of its base is satisfied, and at least one reviewer
+// approved it while none still requests changes. FORGE then deletes the head
+// branch, records the merge commit on the task and moves the task to done.
+// Draft pull requests are left alone. Check and review events of the GitHub
+// webhook run the sync right away.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Merge methods of auto-merge
+const (
+	MergeMethodMerge  = "merge"
+	MergeMethodSquash = "squash"
+	MergeMethodRebase = "rebase"
+)
+
+// isValidMergeMethod reports whether m is a merge method GitHub knows
+func isValidMergeMethod(m string) bool {
+	return m == MergeMethodMerge || m == MergeMethodSquash || m == MergeMethodRebase
+}
+
+// readyMergeableStates are the mergeable states of a pull request whose
+// checks passed and whose branch protection is satisfied
+var readyMergeableStates = map[string]bool{"clean": true, "has_hooks": true}
+
+// reviewApproved reports whether the latest review of at least one reviewer
+// approves and no reviewer's latest review requests changes
+func reviewApproved(reviews []GitHubReview) bool {
+	// GitHub lists reviews oldest first; comments do not change a verdict
+	latest := make(map[string]string)
+	for _, review := range reviews {
+		switch review.State {
+		case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+			latest[review.User.Login] = review.State
+		}
+	}
+	approvals := 0
+	for _, state := range latest {
+		switch state {
+		case "CHANGES_REQUESTED":
+			return false
+		case "APPROVED":
+			approvals++
+		}
+	}
+	return approvals > 0
+}
+
+// autoMergePR merges the open pull request of a task in review if its project
+// has auto-merge on and the pull request is ready. Reports whether it merged.
+func (r *RalphRunner) autoMergePR(client *GitHubClient, repo string, project *Project, task *Task, pr *GitHubPullRequest) bool {
+	if project == nil || project.Workflow != WorkflowBranch || project.AutoMerge == "" || pr.Draft {
+		return false
+	}
+	// Checks pending or failed, required reviews missing, conflicts, or not computed yet
+	if !readyMergeableStates[pr.MergeableState] {
+		return false
+	}
+	reviews, err := client.ListPullRequestReviews(repo, pr.Number)
+	if err != nil {
+		componentLog("prsync").Warn("Failed to get reviews", "task_id", task.ID, "pr", pr.Number, "err", err)
+		return false
+	}
+	if !reviewApproved(reviews) {
+		return false
+	}
+
+	// Only the head that passed is merged, commits pushed since wait for their checks
+	sha, err := client.MergePullRequest(repo, pr.Number, project.AutoMerge, pr.Head.SHA)
+	if err != nil {
+		componentLog("prsync").Warn("Failed to merge pull request", "task_id", task.ID, "pr", pr.Number, "err", err)
+		return false
+	}
+	if err := r.db.UpdateTaskMergeCommit(task.ID, sha); err != nil {
+		componentLog("prsync").Error("Failed to save merge commit", "task_id", task.ID, "err", err)
+	}
+	// The repository may delete head branches on merge by itself
+	if err := client.DeleteBranch(repo, pr.Head.Ref); err != nil && !strings.Contains(err.Error(), "Reference does not exist") {
+		msg := fmt.Sprintf("[FORGE] Could not delete branch %s: %v\n", pr.Head.Ref, err)
+		r.db.AppendTaskLogs(task.ID, msg)
+		r.hub.BroadcastLog(task.ID, msg)
+	}
+
+	r.finishTaskFromPR(task, StatusDone, "", fmt.Sprintf("Pull request #%d was merged automatically (%s) as %s", pr.Number, project.AutoMerge, shortHash(sha)))
+	return true
+}
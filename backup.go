@@ -0,0 +1,516 @@
+// backup.go implements periodic SQLite snapshots and restore for FORGE.
+// Snapshots are written with VACUUM INTO, so they are consistent even while
+// the server keeps writing. The uploads directory can optionally be archived
+// alongside each snapshot.
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default backup configuration
+const (
+	defaultBackupDir       = "backups"
+	defaultBackupRetention = 7
+	defaultBackupInterval  = 24 * time.Hour
+)
+
+// errInvalidBackupName is returned for names that do not refer to a FORGE backup
+var errInvalidBackupName = errors.New("invalid backup name")
+
+// errBackupUnsupported is returned when the database backend cannot be snapshotted by FORGE
+var errBackupUnsupported = errors.New("backups are only supported for SQLite, use pg_dump for PostgreSQL")
+
+// backupTimeFormat is used in backup file names so they sort chronologically
+const backupTimeFormat = "20060102-150405"
+
+// BackupManager creates, prunes and restores database backups
+type BackupManager struct {
+	db                 Store
+	dir                string
+	retention          int
+	interval           time.Duration
+	includeAttachments bool
+	mu                 sync.Mutex // serializes backup and restore runs
+}
+
+// NewBackupManager creates a BackupManager configured from environment variables:
+//   - FORGE_BACKUP_DIR: target directory (default: backups)
+//   - FORGE_BACKUP_RETENTION: number of backups to keep (default: 7)
+//   - FORGE_BACKUP_INTERVAL: interval for automatic backups, e.g. 6h (default: 24h, 0 disables)
+//   - FORGE_BACKUP_ATTACHMENTS: true to include the uploads directory in automatic backups
+func NewBackupManager(db Store) *BackupManager {
+	b := &BackupManager{
+		db:        db,
+		dir:       defaultBackupDir,
+		retention: defaultBackupRetention,
+		interval:  defaultBackupInterval,
+	}
+
+	if dir := os.Getenv("FORGE_BACKUP_DIR"); dir != "" {
+		b.dir = dir
+	}
+	if v := os.Getenv("FORGE_BACKUP_RETENTION"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			b.retention = n
+		} else {
+			componentLog("backup").Warn("Ignoring invalid FORGE_BACKUP_RETENTION", "value", v)
+		}
+	}
+	if v := os.Getenv("FORGE_BACKUP_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			b.interval = d
+		} else {
+			componentLog("backup").Warn("Ignoring invalid FORGE_BACKUP_INTERVAL", "value", v)
+		}
+	}
+	b.includeAttachments = os.Getenv("FORGE_BACKUP_ATTACHMENTS") == "true"
+
+	return b
+}
+
+// Run creates a backup every interval until stop is closed.
+// Does nothing if automatic backups are disabled.
+func (b *BackupManager) Run(stop <-chan struct{}) {
+	if b.interval == 0 {
+		componentLog("backup").Info("Automatic backups disabled")
+		return
+	}
+	if b.db.Driver() != DriverSQLite {
+		componentLog("backup").Info("Automatic backups disabled", "reason", errBackupUnsupported)
+		return
+	}
+	componentLog("backup").Info("Automatic backups enabled", "interval", b.interval, "dir", b.dir, "keep", b.retention)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if info, err := b.Create(b.includeAttachments); err != nil {
+				componentLog("backup").Error("Automatic backup failed", "err", err)
+			} else {
+				componentLog("backup").Info("Backup created", "name", info.Name)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Create writes a new backup and prunes old ones beyond the retention limit
+func (b *BackupManager) Create(includeAttachments bool) (*BackupInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	info, err := b.create(includeAttachments)
+	if err != nil {
+		return nil, err
+	}
+	b.prune()
+	return info, nil
+}
+
+// create writes the database snapshot and optionally the uploads archive.
+// Callers must hold b.mu.
+func (b *BackupManager) create(includeAttachments bool) (*BackupInfo, error) {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	// VACUUM INTO refuses to overwrite, so pick a free name
+	base := "forge-" + time.Now().Format(backupTimeFormat)
+	name := base + ".db"
+	for i := 1; fileExists(filepath.Join(b.dir, name)); i++ {
+		name = fmt.Sprintf("%s-%d.db", base, i)
+	}
+	dbPath := filepath.Join(b.dir, name)
+
+	if err := b.db.BackupTo(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to back up database: %v", err)
+	}
+
+	if includeAttachments {
+		if err := zipDirectory(UploadsDir, attachmentsArchiveFor(dbPath)); err != nil {
+			os.Remove(dbPath)
+			os.Remove(attachmentsArchiveFor(dbPath))
+			return nil, fmt.Errorf("failed to back up attachments: %v", err)
+		}
+	}
+
+	return b.stat(name)
+}
+
+// List returns all backups, newest first
+func (b *BackupManager) List() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(b.dir)
+	if os.IsNotExist(err) {
+		return []BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []BackupInfo{}
+	for _, e := range entries {
+		if e.IsDir() || !isBackupName(e.Name()) {
+			continue
+		}
+		if info, err := b.stat(e.Name()); err == nil {
+			backups = append(backups, *info)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// Restore replaces the live database (and optionally the uploads directory)
+// with the named backup. A fresh backup of the current state is taken first.
+func (b *BackupManager) Restore(name string, restoreAttachments bool) (*BackupInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isBackupName(name) {
+		return nil, errInvalidBackupName
+	}
+	src := filepath.Join(b.dir, name)
+	if !fileExists(src) {
+		return nil, os.ErrNotExist
+	}
+	archive := attachmentsArchiveFor(src)
+	if restoreAttachments && !fileExists(archive) {
+		return nil, fmt.Errorf("backup %s has no attachments archive", name)
+	}
+
+	// Safety net: snapshot the current state before replacing it.
+	// Not pruned here so the backup being restored cannot be removed.
+	safety, err := b.create(restoreAttachments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pre-restore backup: %v", err)
+	}
+	componentLog("backup").Info("Pre-restore backup created", "name", safety.Name)
+
+	if err := b.db.RestoreFrom(src); err != nil {
+		return nil, err
+	}
+
+	if restoreAttachments {
+		if err := restoreDirectory(archive, UploadsDir); err != nil {
+			return nil, fmt.Errorf("database restored, but attachments failed: %v", err)
+		}
+	}
+
+	componentLog("backup").Info("Backup restored", "name", name)
+	return safety, nil
+}
+
+// prune deletes the oldest backups beyond the retention limit.
+// Callers must hold b.mu.
+func (b *BackupManager) prune() {
+	backups, err := b.List()
+	if err != nil {
+		componentLog("backup").Error("Failed to list backups for pruning", "err", err)
+		return
+	}
+	for i := b.retention; i < len(backups); i++ {
+		path := filepath.Join(b.dir, backups[i].Name)
+		if err := os.Remove(path); err != nil {
+			componentLog("backup").Error("Failed to remove backup", "name", backups[i].Name, "err", err)
+			continue
+		}
+		os.Remove(attachmentsArchiveFor(path))
+		componentLog("backup").Info("Backup pruned", "name", backups[i].Name)
+	}
+}
+
+// stat builds the BackupInfo for a backup file in the backup directory
+func (b *BackupManager) stat(name string) (*BackupInfo, error) {
+	path := filepath.Join(b.dir, name)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &BackupInfo{
+		Name:      name,
+		Size:      fi.Size(),
+		CreatedAt: fi.ModTime(),
+	}
+	if ai, err := os.Stat(attachmentsArchiveFor(path)); err == nil {
+		info.Attachments = filepath.Base(attachmentsArchiveFor(path))
+		info.AttachmentsSize = ai.Size()
+	}
+	return info, nil
+}
+
+// isBackupName reports whether name looks like a database backup created by FORGE.
+// Also guards against path traversal in user-supplied names.
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, "forge-") && strings.HasSuffix(name, ".db") &&
+		filepath.Base(name) == name
+}
+
+// attachmentsArchiveFor returns the uploads archive path belonging to a database backup
+func attachmentsArchiveFor(dbPath string) string {
+	return strings.TrimSuffix(dbPath, ".db") + "-uploads.zip"
+}
+
+// fileExists reports whether a file exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// copyFile copies src to dst, replacing dst if it exists
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// verifySQLiteBackup checks that path is an intact FORGE database before it is restored
+func verifySQLiteBackup(path string) error {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("backup is not a valid SQLite database: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup failed integrity check: %s", result)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('tasks', 'schema_version')").Scan(&count); err != nil || count != 2 {
+		return fmt.Errorf("backup is not a FORGE database")
+	}
+	return nil
+}
+
+// zipDirectory archives all files below dir into dest.
+// A missing dir produces an empty archive.
+func zipDirectory(dir string, dest string) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+
+	err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return nil
+			}
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return addFileToZip(zw, path, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// restoreDirectory replaces dir with the contents of the zip archive.
+// The archive is extracted next to dir first so a failed extraction leaves dir untouched.
+func restoreDirectory(archive string, dir string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	staging := dir + ".restore"
+	os.RemoveAll(staging)
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		// Reject entries that would escape the target directory
+		target := filepath.Join(staging, filepath.FromSlash(f.Name))
+		if !strings.HasPrefix(target, filepath.Clean(staging)+string(os.PathSeparator)) {
+			os.RemoveAll(staging)
+			return fmt.Errorf("invalid path in archive: %s", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := extractZipFile(f, target); err != nil {
+			os.RemoveAll(staging)
+			return err
+		}
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	return os.Rename(staging, dir)
+}
+
+// extractZipFile writes a single zip entry to target
+func extractZipFile(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// ============================================================================
+// Backup-Handler
+// ============================================================================
+
+// HandleAdminBackup handles POST /api/admin/backup
+// Body (optional): {"attachments": true} to include the uploads directory.
+func (h *Handler) HandleAdminBackup(w http.ResponseWriter, r *http.Request) {
+	var req BackupRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	// Archiving large upload directories can exceed the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(10 * time.Minute))
+
+	info, err := h.backups.Create(req.Attachments)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create backup: "+err.Error())
+		return
+	}
+
+	logFrom(r.Context()).Info("Manual backup created", "component", "backup", "name", info.Name)
+	h.writeJSON(w, http.StatusCreated, info)
+}
+
+// HandleAdminBackups handles GET /api/admin/backups
+func (h *Handler) HandleAdminBackups(w http.ResponseWriter, r *http.Request) {
+	backups, err := h.backups.List()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list backups: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, backups)
+}
+
+// HandleAdminRestore handles POST /api/admin/restore
+// Body: {"name": "forge-20240101-120000.db", "attachments": true}
+// Refused while Claude processes are running, since they write to the database.
+func (h *Handler) HandleAdminRestore(w http.ResponseWriter, r *http.Request) {
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Name == "" {
+		h.writeError(w, http.StatusBadRequest, "Backup name is required")
+		return
+	}
+
+	if n := h.runner.RunningCount(); n > 0 {
+		h.writeError(w, http.StatusConflict, fmt.Sprintf("Cannot restore while %d task(s) are running", n))
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(10 * time.Minute))
+
+	safety, err := h.backups.Restore(req.Name, req.Attachments)
+	if os.IsNotExist(err) {
+		h.writeError(w, http.StatusNotFound, "Backup not found")
+		return
+	}
+	if err == errInvalidBackupName {
+		h.writeError(w, http.StatusBadRequest, "Invalid backup name")
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to restore backup: "+err.Error())
+		return
+	}
+
+	// Restored tasks may still reference processes from the time of the backup
+	recoverTasks(h.db, h.runner)
+
+	// Let connected clients reload the restored board
+	if tasks, err := h.db.GetAllTasks(); err == nil {
+		for i := range tasks {
+			h.hub.BroadcastTaskUpdate(&tasks[i])
+		}
+	}
+	if projects, err := h.db.GetAllProjects(); err == nil {
+		for i := range projects {
+			h.hub.BroadcastProjectUpdate(&projects[i])
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"restored":           req.Name,
+		"attachments":        req.Attachments,
+		"pre_restore_backup": safety.Name,
+	})
+}
@@ -0,0 +1,345 @@
+// boards.go lets one FORGE instance hold several boards, e.g. one per team or
+// product. Every task and every project belongs to a board; a new task lands
+// on the board it is created on, else on its project's, and everything that
+// existed before boards is on the "default" board, which cannot be deleted.
+// The built-in columns are shared by all boards, custom columns belong to one
+// board (BoardColumn.BoardID), so a task can only move to another board from
+// a shared column. Status keys stay unique across boards, and WIP limits count
+// a column's tasks on all boards. Each board has its own queue: its projects
+// keep their lanes (see queue.go), Board.QueuePolicy and
+// Board.PriorityAgingHours decide which of its lanes goes next, and
+// Board.MaxConcurrentTasks caps how many of its tasks run at once, within
+// Config.MaxConcurrentTasks. Between boards the one waiting longest goes
+// first. Tasks are listed and created through /api/boards/{id}/tasks and move
+// to another board by updating their board_id.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// errDefaultBoard is returned when deleting the default board
+	errDefaultBoard = errors.New("the default board cannot be deleted")
+
+	// errBoardNotEmpty is returned when deleting a board that still has tasks or projects
+	errBoardNotEmpty = errors.New("board still has tasks or projects, move them first")
+)
+
+// onBoard reports whether the column is shown on a board; shared columns are on every board
+func (c *BoardColumn) onBoard(boardID string) bool {
+	return c.BoardID == "" || c.BoardID == boardID
+}
+
+// boardColumns returns the columns shown on a board, in board order
+func boardColumns(columns []BoardColumn, boardID string) []BoardColumn {
+	shown := []BoardColumn{}
+	for _, c := range columns {
+		if c.onBoard(boardID) {
+			shown = append(shown, c)
+		}
+	}
+	return shown
+}
+
+// runningByBoard counts the running tasks of every board
+func (r *RalphRunner) runningByBoard() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, proc := range r.processes {
+		counts[proc.boardID]++
+	}
+	return counts
+}
+
+// fullBoards returns the boards that already run as many tasks as they may
+func (r *RalphRunner) fullBoards() map[string]bool {
+	full := make(map[string]bool)
+	boards, err := r.db.GetBoards()
+	if err != nil {
+		componentLog("queue").Warn("Failed to read board limits", "err", err)
+		return full
+	}
+	running := r.runningByBoard()
+	for _, b := range boards {
+		if b.MaxConcurrentTasks > 0 && running[b.ID] >= b.MaxConcurrentTasks {
+			full[b.ID] = true
+		}
+	}
+	return full
+}
+
+// broadcastBoards sends the current board list to all clients
+func (h *Handler) broadcastBoards() {
+	boards, err := h.db.GetBoards()
+	if err == nil {
+		h.hub.BroadcastBoardsUpdate(boards)
+	}
+}
+
+// checkBoard answers 400 and returns false if no board has the ID
+func (h *Handler) checkBoard(w http.ResponseWriter, boardID string) bool {
+	board, err := h.db.GetBoard(boardID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get board: "+err.Error())
+		return false
+	}
+	if board == nil {
+		h.writeError(w, http.StatusBadRequest, "Unknown board: "+boardID)
+		return false
+	}
+	return true
+}
+
+// checkTaskBoard rejects an update that would leave the task in a custom
+// column of another board: moving it to another board while it is in such a
+// column, or moving it into another board's column. Unknown statuses are left
+// to the caller.
+func (h *Handler) checkTaskBoard(w http.ResponseWriter, task *Task, req UpdateTaskRequest) bool {
+	boardID := task.BoardID
+	if req.BoardID != nil && *req.BoardID != task.BoardID {
+		if !h.checkBoard(w, *req.BoardID) {
+			return false
+		}
+		boardID = *req.BoardID
+	}
+	status := task.Status
+	if req.Status != nil {
+		status = *req.Status
+	}
+
+	column, err := h.db.GetBoardColumn(status)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get column: "+err.Error())
+		return false
+	}
+	if column == nil || column.onBoard(boardID) {
+		return true
+	}
+	if status != task.Status {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("Column %q belongs to another board", column.Name))
+	} else {
+		h.writeError(w, http.StatusConflict, fmt.Sprintf("Task is in column %q of its board, move it to a shared column first", column.Name))
+	}
+	return false
+}
+
+// getBoardOr404 returns the board of the request path, or answers 404
+func (h *Handler) getBoardOr404(w http.ResponseWriter, r *http.Request) *Board {
+	board, err := h.db.GetBoard(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get board: "+err.Error())
+		return nil
+	}
+	if board == nil {
+		h.writeError(w, http.StatusNotFound, "Board not found")
+		return nil
+	}
+	return board
+}
+
+// HandleBoards handles GET/POST /api/boards
+func (h *Handler) HandleBoards(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		boards, err := h.db.GetBoards()
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get boards: "+err.Error())
+			return
+		}
+		if boards == nil {
+			boards = []Board{}
+		}
+		h.writeJSON(w, http.StatusOK, boards)
+
+	case http.MethodPost:
+		var req CreateBoardRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			h.writeError(w, http.StatusBadRequest, "Name is required")
+			return
+		}
+		if req.QueuePolicy == "" {
+			req.QueuePolicy = QueuePolicyFIFO
+		}
+		if !validQueuePolicy(req.QueuePolicy) {
+			h.writeError(w, http.StatusBadRequest, "Queue policy must be fifo, priority or round_robin")
+			return
+		}
+		if req.MaxConcurrentTasks < 0 {
+			h.writeError(w, http.StatusBadRequest, "Max concurrent tasks must not be negative")
+			return
+		}
+		if req.PriorityAgingHours < 0 {
+			h.writeError(w, http.StatusBadRequest, "priority_aging_hours must not be negative")
+			return
+		}
+
+		board, err := h.db.CreateBoard(req)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to create board: "+err.Error())
+			return
+		}
+
+		h.broadcastBoards()
+		h.writeJSON(w, http.StatusCreated, board)
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleBoard handles GET/PUT/DELETE /api/boards/{id}
+func (h *Handler) HandleBoard(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		if board := h.getBoardOr404(w, r); board != nil {
+			h.writeJSON(w, http.StatusOK, board)
+		}
+
+	case http.MethodPut:
+		var req UpdateBoardRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				h.writeError(w, http.StatusBadRequest, "Name must not be empty")
+				return
+			}
+			req.Name = &name
+		}
+		if req.QueuePolicy != nil && !validQueuePolicy(*req.QueuePolicy) {
+			h.writeError(w, http.StatusBadRequest, "Queue policy must be fifo, priority or round_robin")
+			return
+		}
+		if req.MaxConcurrentTasks != nil && *req.MaxConcurrentTasks < 0 {
+			h.writeError(w, http.StatusBadRequest, "Max concurrent tasks must not be negative")
+			return
+		}
+		if req.PriorityAgingHours != nil && *req.PriorityAgingHours < 0 {
+			h.writeError(w, http.StatusBadRequest, "priority_aging_hours must not be negative")
+			return
+		}
+
+		board, err := h.db.UpdateBoard(id, req)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to update board: "+err.Error())
+			return
+		}
+		if board == nil {
+			h.writeError(w, http.StatusNotFound, "Board not found")
+			return
+		}
+
+		// A raised limit can free a slot for the board's queue, policy and aging can change its order
+		if req.QueuePolicy != nil || req.MaxConcurrentTasks != nil || req.PriorityAgingHours != nil {
+			go h.runner.TryStartNextQueued(r.Context())
+		}
+		h.broadcastBoards()
+		h.writeJSON(w, http.StatusOK, board)
+
+	case http.MethodDelete:
+		err := h.db.DeleteBoard(id)
+		if err == sql.ErrNoRows {
+			h.writeError(w, http.StatusNotFound, "Board not found")
+			return
+		}
+		if errors.Is(err, errDefaultBoard) || errors.Is(err, errBoardNotEmpty) {
+			h.writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to delete board: "+err.Error())
+			return
+		}
+
+		h.broadcastBoards()
+		h.broadcastColumns()
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleBoardTasks handles GET/POST /api/boards/{id}/tasks
+// Lists the tasks of a board (with the filters of GET /api/tasks) or creates one on it.
+func (h *Handler) HandleBoardTasks(w http.ResponseWriter, r *http.Request) {
+	board := h.getBoardOr404(w, r)
+	if board == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getTasks(w, r, board.ID)
+	case http.MethodPost:
+		h.createTask(w, r, board.ID)
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleBoardProjects handles GET /api/boards/{id}/projects
+func (h *Handler) HandleBoardProjects(w http.ResponseWriter, r *http.Request) {
+	board := h.getBoardOr404(w, r)
+	if board == nil {
+		return
+	}
+	h.getProjectsOfBoard(w, board.ID)
+}
+
+// HandleBoardColumnsOfBoard handles GET/POST /api/boards/{id}/columns and PUT /api/boards/{id}/columns (reorder)
+// The shared columns are part of every board, so reordering them reorders them on all boards.
+func (h *Handler) HandleBoardColumnsOfBoard(w http.ResponseWriter, r *http.Request) {
+	board := h.getBoardOr404(w, r)
+	if board == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		columns, err := h.db.GetBoardColumns()
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get columns: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusOK, boardColumns(columns, board.ID))
+
+	case http.MethodPost:
+		var req CreateBoardColumnRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		req.BoardID = board.ID
+		h.createColumn(w, req)
+
+	case http.MethodPut:
+		var req ReorderBoardColumnsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		h.reorderColumns(w, req, board.ID)
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,298 @@
+// bootstrap.go creates new projects from scratch. POST /api/projects/bootstrap
+// runs the whole flow server-side: create the directory, git init, register the
+// project, let RALPH generate a starter structure from a template, commit it and
+// optionally create a GitHub repository and push. Every step is reported as a
+// bootstrap_progress WebSocket message.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// taskPollInterval is how often the status of a task FORGE waits for is checked
+const taskPollInterval = 2 * time.Second
+
+// Steps of a bootstrap, in order
+const (
+	BootstrapStepDirectory = "directory"
+	BootstrapStepGitInit   = "git_init"
+	BootstrapStepProject   = "project"
+	BootstrapStepTask      = "task"
+	BootstrapStepCommit    = "commit"
+	BootstrapStepGithub    = "github"
+	BootstrapStepPush      = "push"
+	BootstrapStepDone      = "done"
+)
+
+// bootstrapTemplates are the starter structures RALPH can generate
+var bootstrapTemplates = []BootstrapTemplate{
+	{
+		ID:   "go",
+		Name: "Go module",
+		Prompt: "Create a Go module with a main package (cmd/<name>/main.go), an internal package with one example function " +
+			"and a table-driven test for it, a Makefile with build/test/lint targets and a .gitignore for Go.",
+	},
+	{
+		ID:   "node",
+		Name: "Node.js (TypeScript)",
+		Prompt: "Create a Node.js project in TypeScript: package.json with build, test and lint scripts, tsconfig.json, " +
+			"src/index.ts with one example function, a test using the Node test runner and a .gitignore for Node.",
+	},
+	{
+		ID:   "python",
+		Name: "Python package",
+		Prompt: "Create a Python package with a pyproject.toml (setuptools, pytest as dev dependency), " +
+			"src/<name>/__init__.py with one example function, tests/test_example.py and a .gitignore for Python.",
+	},
+	{
+		ID:   "web",
+		Name: "Static website",
+		Prompt: "Create a static website without build step: index.html, css/style.css with a small responsive layout, " +
+			"js/main.js and a .gitignore.",
+	},
+	{
+		ID:     "empty",
+		Name:   "Minimal (README only)",
+		Prompt: "Create only a README.md and a .gitignore suitable for the project description.",
+	},
+}
+
+func findBootstrapTemplate(id string) *BootstrapTemplate {
+	for i := range bootstrapTemplates {
+		if bootstrapTemplates[i].ID == id {
+			return &bootstrapTemplates[i]
+		}
+	}
+	return nil
+}
+
+// HandleProjectBootstrap handles GET/POST /api/projects/bootstrap
+// GET lists the templates; POST starts a bootstrap and responds with 202 and its ID.
+func (h *Handler) HandleProjectBootstrap(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.writeJSON(w, http.StatusOK, bootstrapTemplates)
+	case http.MethodPost:
+		h.startBootstrap(w, r)
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (h *Handler) startBootstrap(w http.ResponseWriter, r *http.Request) {
+	var req BootstrapProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		h.writeError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	template := findBootstrapTemplate(req.Template)
+	if template == nil {
+		h.writeError(w, http.StatusBadRequest, "Unknown template: "+req.Template)
+		return
+	}
+
+	dest, config, ok := h.reserveProjectDir(w, req.Name)
+	if !ok {
+		return
+	}
+	if req.CreateGithubRepo && config.GithubToken == "" {
+		releaseProjectDir(dest)
+		h.writeError(w, http.StatusBadRequest, "GitHub token not configured")
+		return
+	}
+
+	progress := BootstrapProgress{BootstrapID: uuid.New().String(), Name: req.Name, Path: dest, Step: BootstrapStepDirectory}
+	started := progress // runBootstrap updates its own copy
+	go h.runBootstrap(req, *template, config, &progress)
+
+	h.writeJSON(w, http.StatusAccepted, started)
+}
+
+// runBootstrap executes all steps; on failure the steps done so far are kept
+// (an already registered project stays, so the user can continue manually)
+func (h *Handler) runBootstrap(req BootstrapProjectRequest, template BootstrapTemplate, config *Config, progress *BootstrapProgress) {
+	released := false
+	release := func() {
+		if !released {
+			releaseProjectDir(progress.Path)
+			released = true
+		}
+	}
+	defer release()
+
+	step := func(name, message string) {
+		progress.Step, progress.Message = name, message
+		h.hub.BroadcastBootstrapProgress(progress)
+	}
+	fail := func(err error) {
+		componentLog("bootstrap").Error("Bootstrap failed", "project", req.Name, "step", progress.Step, "err", err)
+		progress.Done = true
+		progress.Error = err.Error()
+		h.hub.BroadcastBootstrapProgress(progress)
+	}
+
+	step(BootstrapStepDirectory, "Creating "+progress.Path)
+	if err := os.MkdirAll(progress.Path, 0755); err != nil {
+		fail(fmt.Errorf("failed to create directory: %w", err))
+		return
+	}
+
+	step(BootstrapStepGitInit, "Initializing git repository")
+	if err := InitGitRepository(progress.Path); err != nil {
+		fail(err)
+		return
+	}
+
+	step(BootstrapStepProject, "Registering project")
+	project, err := h.db.CreateProject(CreateProjectRequest{
+		Name:        req.Name,
+		Path:        progress.Path,
+		Description: req.Description,
+	}, false)
+	if err != nil {
+		fail(fmt.Errorf("failed to create project: %w", err))
+		return
+	}
+	// From here on the project exists; the path no longer needs a reservation
+	release()
+	progress.Project = project
+	h.hub.BroadcastProjectUpdate(project)
+
+	task, err := h.createBootstrapTask(req, template, project, config)
+	if err != nil {
+		fail(fmt.Errorf("failed to create bootstrap task: %w", err))
+		return
+	}
+	progress.TaskID = task.ID
+	step(BootstrapStepTask, "RALPH is generating the starter structure")
+
+	if err := h.waitForTask(task.ID, "bootstrap"); err != nil {
+		fail(err)
+		return
+	}
+
+	step(BootstrapStepCommit, "Committing starter structure")
+	if hasChanges, _ := HasUncommittedChanges(progress.Path); hasChanges {
+		if _, err := CommitAllChanges(progress.Path, WithTaskTrailer(commitMessage(project, task, "Initial project structure"), task.ID)); err != nil {
+			fail(err)
+			return
+		}
+	}
+	if _, err := GetCurrentCommitHash(progress.Path); err != nil {
+		fail(fmt.Errorf("the bootstrap task did not create any files"))
+		return
+	}
+	gitStatus.Invalidate(progress.Path)
+	detectNewProjectStack(h.db, project)
+
+	if req.CreateGithubRepo {
+		step(BootstrapStepGithub, "Creating GitHub repository")
+		repo, err := githubEndpointsFor(config, nil).client(config.GithubToken).CreateRepository("", GitHubCreateRepoRequest{
+			Name:        req.Name,
+			Description: req.Description,
+			Private:     req.Private,
+		})
+		if err != nil {
+			fail(fmt.Errorf("failed to create GitHub repo: %w", err))
+			return
+		}
+		progress.RepoURL = repo.HTMLURL
+		if err := SetRemoteOrigin(progress.Path, repo.CloneURL); err != nil {
+			fail(err)
+			return
+		}
+
+		step(BootstrapStepPush, "Pushing to "+repo.HTMLURL)
+		if err := PushToRemote(progress.Path); err != nil {
+			fail(err)
+			return
+		}
+		gitStatus.Invalidate(progress.Path)
+	}
+
+	if updated, _ := h.db.GetProject(project.ID); updated != nil {
+		progress.Project = updated
+		h.hub.BroadcastProjectUpdate(updated)
+	}
+	componentLog("bootstrap").Info("Project created", "project", req.Name, "path", progress.Path)
+	progress.Done = true
+	step(BootstrapStepDone, "Project ready")
+}
+
+// createBootstrapTask creates the RALPH task for the template and puts it in the queue
+func (h *Handler) createBootstrapTask(req BootstrapProjectRequest, template BootstrapTemplate, project *Project, config *Config) (*Task, error) {
+	var desc strings.Builder
+	desc.WriteString(fmt.Sprintf("Set up the new, empty project **%s** (template: %s).\n\n", req.Name, template.Name))
+	if req.Description != "" {
+		desc.WriteString("Project description: " + req.Description + "\n\n")
+	}
+	desc.WriteString(strings.ReplaceAll(template.Prompt, "<name>", req.Name) + "\n\n")
+	desc.WriteString("Also write a README.md that explains what the project is and how to build, run and test it.\n")
+	if req.Instructions != "" {
+		desc.WriteString("\nAdditional instructions:\n" + req.Instructions + "\n")
+	}
+
+	if err := h.checkWIPLimit(StatusQueued); err != nil {
+		return nil, err
+	}
+
+	task, err := h.db.CreateTask(CreateTaskRequest{
+		Title:              "Bootstrap " + req.Name,
+		Description:        desc.String(),
+		AcceptanceCriteria: "- The starter structure exists and builds\n- Tests (if any) pass\n- README.md describes the project",
+		ProjectID:          project.ID,
+		ProjectDir:         project.Path,
+	}, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.db.AddToQueue(task.ID); err != nil {
+		return nil, err
+	}
+
+	if queued, _ := h.db.GetTask(task.ID); queued != nil {
+		task = queued
+	}
+	h.hub.BroadcastTaskUpdate(task)
+	go h.runner.TryStartNextQueued(context.Background())
+	return task, nil
+}
+
+// waitForTask blocks until RALPH finished a task FORGE created for itself
+// (review or done); kind names the task in errors
+func (h *Handler) waitForTask(taskID, kind string) error {
+	ticker := time.NewTicker(taskPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		task, err := h.db.GetTask(taskID)
+		if err != nil {
+			return err
+		}
+		if task == nil {
+			return fmt.Errorf("the %s task was deleted", kind)
+		}
+		switch task.Status {
+		case StatusReview, StatusDone:
+			return nil
+		case StatusBlocked:
+			return fmt.Errorf("the %s task is blocked: %s", kind, task.Error)
+		case StatusBacklog:
+			return fmt.Errorf("the %s task was moved back to the backlog", kind)
+		}
+	}
+	return nil
+}
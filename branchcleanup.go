@@ -0,0 +1,224 @@
+// branchcleanup.go cleans up the branches a project no longer needs, mostly
+// the working/* branches tasks of the branch-per-task workflow leave behind.
+// POST /api/projects/{id}/branches/cleanup lists the candidates: local and
+// remote-tracking branches fully merged into the default branch and, with
+// older_than_days, branches without a commit for that long. The default and
+// the current branch, protected branches and the working branches of tasks
+// that are not finished are never candidates. With delete the request removes
+// the chosen candidates: local ones with git branch -D, remote ones by
+// deleting them on the remote.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Reasons a branch is a cleanup candidate
+const (
+	StaleReasonMerged = "merged"
+	StaleReasonOld    = "old"
+)
+
+// staleBranches returns the branches of a project that can be cleaned up
+func (h *Handler) staleBranches(project *Project, olderThanDays int) ([]StaleBranch, error) {
+	defaultBranch := GetDefaultBranch(project.Path)
+	branches, err := gitReads.BranchDetails(project.Path, defaultBranch)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := h.db.GetBranchRules(project.ID)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := h.db.GetTasksByProject(project.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Working branches of finished tasks may go, the others are in use
+	taskBranches := make(map[string]string)
+	inUse := make(map[string]bool)
+	for _, task := range tasks {
+		if task.WorkingBranch == "" {
+			continue
+		}
+		if h.columnRole(task.Status) == ColumnRoleTerminal {
+			taskBranches[task.WorkingBranch] = task.ID
+		} else {
+			inUse[task.WorkingBranch] = true
+		}
+	}
+
+	var cutoff time.Time
+	if olderThanDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -olderThanDays)
+	}
+	current := ""
+	for _, branch := range branches {
+		if branch.IsCurrent {
+			current = branch.Name
+		}
+	}
+
+	candidates := []StaleBranch{}
+	for _, branch := range branches {
+		// origin/feature is the remote side of the local feature
+		name := branch.Name
+		if branch.IsRemote {
+			if _, short, ok := strings.Cut(name, "/"); ok {
+				name = short
+			}
+		}
+		if name == defaultBranch || name == current || inUse[name] || IsBranchProtected(name, rules) {
+			continue
+		}
+
+		stale := StaleBranch{BranchInfo: branch, TaskID: taskBranches[name]}
+		if branch.Merged {
+			stale.Reasons = append(stale.Reasons, StaleReasonMerged)
+		}
+		if !cutoff.IsZero() && branch.LastCommitAt != nil && branch.LastCommitAt.Before(cutoff) {
+			stale.Reasons = append(stale.Reasons, StaleReasonOld)
+		}
+		if len(stale.Reasons) > 0 {
+			candidates = append(candidates, stale)
+		}
+	}
+	return candidates, nil
+}
+
+// deleteStaleBranch deletes a local branch, or a remote-tracking branch on
+// its remote, authenticated with a GitHub token for the GitHub instance at
+// webURL ("" = git's own credentials)
+func deleteStaleBranch(path string, branch BranchInfo, token, webURL string) error {
+	if !branch.IsRemote {
+		// Branches kept for their age are not merged, which -d would refuse
+		cmd := exec.Command("git", "branch", "-D", branch.Name)
+		cmd.Dir = path
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git branch -D failed: %v, output: %s", err, string(output))
+		}
+		return nil
+	}
+
+	remote, name, ok := strings.Cut(branch.Name, "/")
+	if !ok {
+		return fmt.Errorf("not a remote branch: %s", branch.Name)
+	}
+	cmd := exec.Command("git", "push", remote, "--delete", name)
+	cmd.Dir = path
+	if token != "" {
+		cmd.Env = append(os.Environ(), githubAuthEnv(token, webURL)...)
+	}
+	defer withDeployKey(cmd)()
+	output, err := cmd.CombinedOutput()
+	if err != nil && strings.Contains(string(output), "remote ref does not exist") {
+		// Deleted on the remote already, only the tracking ref was left
+		prune := exec.Command("git", "branch", "-d", "-r", branch.Name)
+		prune.Dir = path
+		if pruneOutput, pruneErr := prune.CombinedOutput(); pruneErr != nil {
+			return fmt.Errorf("git branch -d -r failed: %v, output: %s", pruneErr, string(pruneOutput))
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("git push --delete failed: %v, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// HandleBranchCleanup handles POST /api/projects/{id}/branches/cleanup
+// Lists the branches that can be cleaned up; with delete it deletes the listed ones first.
+func (h *Handler) HandleBranchCleanup(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if !IsGitRepository(project.Path) {
+		h.writeError(w, http.StatusBadRequest, "Project is not a git repository")
+		return
+	}
+
+	var req BranchCleanupRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+	}
+	if req.OlderThanDays < 0 {
+		h.writeError(w, http.StatusBadRequest, "older_than_days must not be negative")
+		return
+	}
+
+	resp := BranchCleanupResponse{Deleted: []string{}}
+	if len(req.Delete) > 0 {
+		unlock, err := lockRepoWithin(r.Context(), project.Path, "branch cleanup")
+		if err != nil {
+			h.writeLockError(w, err)
+			return
+		}
+		candidates, err := h.staleBranches(project, req.OlderThanDays)
+		if err != nil {
+			unlock()
+			h.writeError(w, http.StatusInternalServerError, "Failed to list branches: "+err.Error())
+			return
+		}
+		byName := make(map[string]BranchInfo, len(candidates))
+		for _, c := range candidates {
+			byName[c.Name] = c.BranchInfo
+		}
+
+		config, err := h.db.GetConfig()
+		if err != nil {
+			unlock()
+			h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+			return
+		}
+		token := userGithubToken(r, config)
+		webURL := githubEndpointsFor(config, project).WebURL
+		for _, name := range req.Delete {
+			branch, ok := byName[name]
+			if !ok {
+				resp.addFailure(name, "not a cleanup candidate")
+				continue
+			}
+			if err := deleteStaleBranch(project.Path, branch, token, webURL); err != nil {
+				resp.addFailure(name, err.Error())
+				continue
+			}
+			resp.Deleted = append(resp.Deleted, name)
+		}
+		unlock()
+		gitStatus.Invalidate(project.Path)
+		if len(resp.Deleted) > 0 {
+			logFrom(r.Context()).Info("Deleted stale branches", "project_id", project.ID, "branches", resp.Deleted)
+		}
+	}
+
+	resp.Candidates, err = h.staleBranches(project, req.OlderThanDays)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list branches: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// addFailure records a branch that could not be deleted
+func (resp *BranchCleanupResponse) addFailure(name, reason string) {
+	if resp.Failed == nil {
+		resp.Failed = make(map[string]string)
+	}
+	resp.Failed[name] = reason
+}
@@ -0,0 +1,271 @@
+// budget.go caps what Claude may cost. A budget sets a daily and a monthly
+// limit in USD, for all projects together (Config.Budget) and for every
+// project (Project.Budget); 0 means no limit. The spend of a period is the
+// cost of the runs that finished in the current local day or month, so runs
+// still going when a budget is reached can overrun it. Once a budget is used
+// up, the dispatcher starts no queued task of its scope, all of them for the
+// global budget, until the period ends; tasks started by hand still run. A
+// budget reaching budgetWarnPercent and one used up are announced once per
+// period as a budget_alert message. GET /api/stats/budgets returns the state
+// of every budget.
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// budgetWarnPercent is the share of a budget at which users are warned
+const budgetWarnPercent = 80
+
+// Budget states of BudgetStatus
+const (
+	BudgetOK       = "ok"
+	BudgetWarning  = "warning"
+	BudgetExceeded = "exceeded"
+)
+
+// CostBudget limits the cost of Claude runs, stored as a JSON object
+type CostBudget struct {
+	DailyUSD   float64 `json:"daily_usd,omitempty"`   // 0 = no daily limit
+	MonthlyUSD float64 `json:"monthly_usd,omitempty"` // 0 = no monthly limit
+}
+
+// Value stores the budget as JSON, no limits as an empty string
+func (b CostBudget) Value() (driver.Value, error) {
+	if b.DailyUSD == 0 && b.MonthlyUSD == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads a budget stored by Value
+func (b *CostBudget) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into CostBudget", src)
+	}
+	*b = CostBudget{}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, b)
+}
+
+// validate rejects negative limits
+func (b CostBudget) validate() error {
+	if b.DailyUSD < 0 || b.MonthlyUSD < 0 {
+		return fmt.Errorf("budget limits must not be negative")
+	}
+	return nil
+}
+
+// budgetPeriod is a day or month a budget applies to
+type budgetPeriod struct {
+	name       string
+	start, end time.Time
+}
+
+// budgetPeriods returns the current day and month
+func budgetPeriods(now time.Time) (day, month budgetPeriod) {
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return budgetPeriod{"daily", dayStart, dayStart.AddDate(0, 0, 1)},
+		budgetPeriod{"monthly", monthStart, monthStart.AddDate(0, 1, 0)}
+}
+
+// budgetStatus returns the state of a budget with limit after spending spent
+func budgetStatus(period budgetPeriod, limit, spent float64) BudgetStatus {
+	status := BudgetStatus{
+		Period:   period.name,
+		LimitUSD: limit,
+		SpentUSD: spent,
+		Percent:  spent / limit * 100,
+		State:    BudgetOK,
+		ResetsAt: period.end,
+	}
+	switch {
+	case spent >= limit:
+		status.State = BudgetExceeded
+	case status.Percent >= budgetWarnPercent:
+		status.State = BudgetWarning
+	}
+	return status
+}
+
+// computeBudgets returns the state of every configured budget, global ones
+// first; daily and monthly are the costs of the current day and month by project
+func computeBudgets(config *Config, projects []Project, daily, monthly map[string]float64, now time.Time) []BudgetStatus {
+	day, month := budgetPeriods(now)
+	sum := func(costs map[string]float64) float64 {
+		total := 0.0
+		for _, cost := range costs {
+			total += cost
+		}
+		return total
+	}
+
+	statuses := []BudgetStatus{}
+	add := func(budget CostBudget, project *Project, dailySpent, monthlySpent float64) {
+		for _, b := range []struct {
+			period budgetPeriod
+			limit  float64
+			spent  float64
+		}{{day, budget.DailyUSD, dailySpent}, {month, budget.MonthlyUSD, monthlySpent}} {
+			if b.limit <= 0 {
+				continue
+			}
+			status := budgetStatus(b.period, b.limit, b.spent)
+			status.Scope = "global"
+			if project != nil {
+				status.Scope = "project"
+				status.ProjectID = project.ID
+				status.ProjectName = project.Name
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	add(config.Budget, nil, sum(daily), sum(monthly))
+	for i := range projects {
+		add(projects[i].Budget, &projects[i], daily[projects[i].ID], monthly[projects[i].ID])
+	}
+	return statuses
+}
+
+// budgetStatuses returns the state of every configured budget
+func (r *RalphRunner) budgetStatuses(now time.Time) ([]BudgetStatus, error) {
+	config, err := r.db.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	projects, err := r.db.GetAllProjects()
+	if err != nil {
+		return nil, err
+	}
+	day, month := budgetPeriods(now)
+	daily, err := r.db.GetRunCosts(day.start)
+	if err != nil {
+		return nil, err
+	}
+	monthly, err := r.db.GetRunCosts(month.start)
+	if err != nil {
+		return nil, err
+	}
+	return computeBudgets(config, projects, daily, monthly, now), nil
+}
+
+// exhaustedBudgets are the scopes whose budget is used up, with the time their
+// tasks may start again; if both a daily and a monthly budget are used up,
+// the monthly one
+type exhaustedBudgets struct {
+	global   time.Time
+	projects map[string]time.Time
+}
+
+// blocks reports whether a used-up budget keeps a task of projectID from starting
+func (e exhaustedBudgets) blocks(projectID string) bool {
+	_, held := e.projects[projectID]
+	return !e.global.IsZero() || held
+}
+
+// exhaustedBudgets returns the scopes whose budget is used up, and makes the
+// dispatcher look at the queue again when the first of them resets
+func (r *RalphRunner) exhaustedBudgets(now time.Time) exhaustedBudgets {
+	exhausted := exhaustedBudgets{projects: make(map[string]time.Time)}
+	statuses, err := r.budgetStatuses(now)
+	if err != nil {
+		componentLog("queue").Warn("Failed to check cost budgets", "err", err)
+		return exhausted
+	}
+	var firstReset time.Time
+	for _, status := range statuses {
+		if status.State != BudgetExceeded {
+			continue
+		}
+		if status.Scope == "global" {
+			exhausted.global = laterTime(exhausted.global, status.ResetsAt)
+		} else {
+			exhausted.projects[status.ProjectID] = laterTime(exhausted.projects[status.ProjectID], status.ResetsAt)
+		}
+		if firstReset.IsZero() || status.ResetsAt.Before(firstReset) {
+			firstReset = status.ResetsAt
+		}
+	}
+	if !firstReset.IsZero() {
+		r.wakeQueueAt(firstReset)
+	}
+	return exhausted
+}
+
+// budgetName names a budget for log and alert messages
+func budgetName(status BudgetStatus) string {
+	if status.Scope == "global" {
+		return "The " + status.Period + " budget"
+	}
+	return fmt.Sprintf("The %s budget of %s", status.Period, status.ProjectName)
+}
+
+// checkBudgets announces budgets that reached budgetWarnPercent or were used
+// up by the run that just finished, each state once per period
+func (r *RalphRunner) checkBudgets() {
+	statuses, err := r.budgetStatuses(time.Now())
+	if err != nil {
+		componentLog("queue").Warn("Failed to check cost budgets", "err", err)
+		return
+	}
+	for i := range statuses {
+		status := &statuses[i]
+		if status.State == BudgetOK {
+			continue
+		}
+		key := fmt.Sprintf("%s|%s|%s|%s", status.Scope, status.ProjectID, status.Period, status.ResetsAt.Format(time.RFC3339))
+		r.mu.Lock()
+		if r.budgetAlerts == nil {
+			r.budgetAlerts = make(map[string]string)
+		}
+		announced := r.budgetAlerts[key] == status.State ||
+			(r.budgetAlerts[key] == BudgetExceeded && status.State == BudgetWarning)
+		r.budgetAlerts[key] = status.State
+		r.mu.Unlock()
+		if announced {
+			continue
+		}
+
+		var msg string
+		if status.State == BudgetExceeded {
+			msg = fmt.Sprintf("%s is used up ($%.2f of $%.2f), no queued tasks start until %s",
+				budgetName(*status), status.SpentUSD, status.LimitUSD, status.ResetsAt.Format("2006-01-02 15:04"))
+		} else {
+			msg = fmt.Sprintf("%s is %.0f%% used ($%.2f of $%.2f)",
+				budgetName(*status), status.Percent, status.SpentUSD, status.LimitUSD)
+		}
+		componentLog("queue").Warn("Cost budget alert", "scope", status.Scope, "project_id", status.ProjectID,
+			"period", status.Period, "state", status.State, "spent_usd", status.SpentUSD, "limit_usd", status.LimitUSD)
+		r.hub.BroadcastBudgetAlert(status, msg)
+	}
+}
+
+// HandleBudgets handles GET /api/stats/budgets
+// Returns the state of every configured cost budget in the current period.
+func (h *Handler) HandleBudgets(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.runner.budgetStatuses(time.Now())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get budgets: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, statuses)
+}
@@ -0,0 +1,286 @@
+// checkpoints.go snapshots a task's working tree at the end of every
+// iteration, so a task can be rolled back to any iteration instead of only to
+// the tag from before it started. A checkpoint is a commit of the whole
+// working tree, uncommitted and untracked files included, built in a
+// temporary index: the branch, index and files RALPH works on stay untouched.
+// Checkpoints live under refs/forge/checkpoints/<task id>/<n>, out of sight of
+// branches and tags, and are removed when the task is rolled back to its start.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checkpointRefPrefix is the namespace of checkpoint refs
+const checkpointRefPrefix = "refs/forge/checkpoints/"
+
+// checkpointSubject is the subject of checkpoint commits, parsed back when listing
+const checkpointSubject = "FORGE checkpoint: iteration %d"
+
+// checkpointRef returns the ref of a task's checkpoint
+func checkpointRef(taskID string, id int) string {
+	return checkpointRefPrefix + taskID + "/" + strconv.Itoa(id)
+}
+
+// runCheckpointGit runs git in path with additional environment variables
+// and returns its trimmed output
+func runCheckpointGit(path string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// CreateCheckpoint commits the working tree of path as checkpoint id of a task
+// and returns the commit hash. The commit's parent is the current HEAD.
+func CreateCheckpoint(path string, taskID string, id int, iteration int) (string, error) {
+	dir, err := os.MkdirTemp("", "forge-checkpoint-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(dir, "index")}
+
+	if _, err := runCheckpointGit(path, env, "read-tree", "HEAD"); err != nil {
+		return "", err
+	}
+	if _, err := runCheckpointGit(path, env, "add", "-A"); err != nil {
+		return "", err
+	}
+	tree, err := runCheckpointGit(path, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	commit, err := runCheckpointGit(path, nil, "commit-tree", tree, "-p", "HEAD", "-m", fmt.Sprintf(checkpointSubject, iteration))
+	if err != nil {
+		return "", err
+	}
+	if _, err := runCheckpointGit(path, nil, "update-ref", checkpointRef(taskID, id), commit); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// ListCheckpoints returns the checkpoints of a task, oldest first
+func ListCheckpoints(path string, taskID string) ([]Checkpoint, error) {
+	prefix := checkpointRefPrefix + taskID + "/"
+	out, err := runCheckpointGit(path, nil, "for-each-ref",
+		"--format=%(refname)%09%(objectname)%09%(parent)%09%(committerdate:iso-strict)%09%(contents:subject)", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints := []Checkpoint{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 5)
+		if len(fields) < 5 {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(fields[0], prefix))
+		if err != nil {
+			continue
+		}
+		cp := Checkpoint{ID: id, Commit: fields[1], Base: fields[2]}
+		cp.CreatedAt, _ = time.Parse(time.RFC3339, fields[3])
+		fmt.Sscanf(fields[4], checkpointSubject, &cp.Iteration)
+		checkpoints = append(checkpoints, cp)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].ID < checkpoints[j].ID })
+	return checkpoints, nil
+}
+
+// RestoreCheckpoint resets the branch to the commit the checkpoint was taken
+// on and the working tree to the checkpoint's files. Changes made since then,
+// including new untracked files, are discarded; changes the checkpoint holds
+// are left uncommitted, as they were. A subdirectory project shares the branch
+// with other projects, so only its files are restored, as uncommitted changes.
+func RestoreCheckpoint(path string, cp Checkpoint) error {
+	steps := [][]string{
+		{"reset", "--hard", cp.Base},
+		{"clean", "-fd"},
+		{"restore", "--source", cp.Commit, "--worktree", "--", "."},
+	}
+	if isRepoSubdir(path) {
+		steps = [][]string{
+			{"restore", "--staged", "--", "."},
+			{"clean", "-fd", "--", "."},
+			{"restore", "--source", cp.Commit, "--worktree", "--", "."},
+		}
+	}
+	for _, args := range steps {
+		if _, err := runCheckpointGit(path, nil, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCheckpoints removes all checkpoints of a task
+func DeleteCheckpoints(path string, taskID string) error {
+	checkpoints, err := ListCheckpoints(path, taskID)
+	if err != nil {
+		return err
+	}
+	for _, cp := range checkpoints {
+		if _, err := runCheckpointGit(path, nil, "update-ref", "-d", checkpointRef(taskID, cp.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveCheckpoint snapshots the working tree of a running task that finished
+// an iteration
+func (r *RalphRunner) saveCheckpoint(taskID string, iteration int) {
+	r.mu.RLock()
+	proc, exists := r.processes[taskID]
+	r.mu.RUnlock()
+	if !exists || proc.dir == "" || !IsGitRepository(proc.dir) {
+		return
+	}
+
+	checkpoints, err := ListCheckpoints(proc.dir, taskID)
+	if err != nil {
+		proc.log.Warn("Failed to list checkpoints", "err", err)
+		return
+	}
+	id := 1
+	if len(checkpoints) > 0 {
+		id = checkpoints[len(checkpoints)-1].ID + 1
+	}
+
+	unlock := lockRepo(proc.dir, "checkpoint")
+	commit, err := CreateCheckpoint(proc.dir, taskID, id, iteration)
+	unlock()
+	if err != nil {
+		proc.log.Warn("Failed to create checkpoint", "iteration", iteration, "err", err)
+		return
+	}
+	proc.log.Info("Saved checkpoint", "checkpoint", id, "iteration", iteration, "commit", commit[:min(7, len(commit))])
+	r.hub.BroadcastLog(taskID, fmt.Sprintf("[FORGE] Checkpoint %d saved after iteration %d\n", id, iteration))
+}
+
+// checkpointTask loads the task of a checkpoint request and its git
+// repository, writing the error response if either is missing
+func (h *Handler) checkpointTask(w http.ResponseWriter, r *http.Request) (*Task, string, bool) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return nil, "", false
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return nil, "", false
+	}
+
+	projectDir := task.ProjectDir
+	if projectDir == "" && task.ProjectID != "" {
+		if project, _ := h.db.GetProject(task.ProjectID); project != nil {
+			projectDir = project.Path
+		}
+	}
+	if projectDir == "" {
+		h.writeError(w, http.StatusBadRequest, "Task has no project directory")
+		return nil, "", false
+	}
+	if !IsGitRepository(projectDir) {
+		h.writeError(w, http.StatusBadRequest, "Project is not a git repository")
+		return nil, "", false
+	}
+	return task, projectDir, true
+}
+
+// HandleTaskCheckpoints handles GET /api/tasks/{id}/checkpoints
+func (h *Handler) HandleTaskCheckpoints(w http.ResponseWriter, r *http.Request) {
+	task, projectDir, ok := h.checkpointTask(w, r)
+	if !ok {
+		return
+	}
+	checkpoints, err := ListCheckpoints(projectDir, task.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list checkpoints: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, checkpoints)
+}
+
+// HandleTaskRestoreCheckpoint handles POST /api/tasks/{id}/restore-checkpoint
+// The task keeps its status; its iteration is set back to the checkpoint's.
+func (h *Handler) HandleTaskRestoreCheckpoint(w http.ResponseWriter, r *http.Request) {
+	task, projectDir, ok := h.checkpointTask(w, r)
+	if !ok {
+		return
+	}
+
+	// Like a rollback, only while RALPH is not working on the files
+	if task.Status != StatusReview && task.Status != StatusBlocked {
+		h.writeError(w, http.StatusBadRequest, "Task must be in review or blocked status")
+		return
+	}
+	if h.runner.IsRunning(task.ID) {
+		h.writeError(w, http.StatusConflict, "Task is running")
+		return
+	}
+
+	var req RestoreCheckpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+
+	checkpoints, err := ListCheckpoints(projectDir, task.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list checkpoints: "+err.Error())
+		return
+	}
+	var checkpoint *Checkpoint
+	for i := range checkpoints {
+		if checkpoints[i].ID == req.Checkpoint {
+			checkpoint = &checkpoints[i]
+		}
+	}
+	if checkpoint == nil {
+		h.writeError(w, http.StatusNotFound, "Checkpoint not found")
+		return
+	}
+
+	unlock, err := lockRepoWithin(r.Context(), projectDir, "checkpoint restore")
+	if err != nil {
+		h.writeLockError(w, err)
+		return
+	}
+	defer unlock()
+	defer gitStatus.Invalidate(projectDir)
+	if err := RestoreCheckpoint(projectDir, *checkpoint); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Restore failed: "+err.Error())
+		return
+	}
+	logFrom(r.Context()).Info("Restored checkpoint", "component", "runner", "task_id", task.ID, "checkpoint", checkpoint.ID, "iteration", checkpoint.Iteration)
+
+	h.db.UpdateTaskIteration(task.ID, checkpoint.Iteration)
+	h.db.AppendTaskLogs(task.ID, fmt.Sprintf("\n[FORGE] Restored checkpoint %d (after iteration %d)\n", checkpoint.ID, checkpoint.Iteration))
+	if updatedTask, _ := h.db.GetTask(task.ID); updatedTask != nil {
+		h.hub.BroadcastTaskUpdate(updatedTask)
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("Restored checkpoint %d (after iteration %d)", checkpoint.ID, checkpoint.Iteration),
+	})
+}
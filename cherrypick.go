@@ -0,0 +1,273 @@
+// cherrypick.go applies the commits of a task onto another branch, e.g. to
+// backport a fix to a release branch. The commits are the ones indexed for
+// the task (see taskcommits.go), or the range since its rollback tag. They are
+// cherry-picked with -x, so each new commit names its original; changes the
+// branch already has are skipped. Uncommitted changes are stashed meanwhile
+// and the original branch is checked out again afterwards. If a commit does
+// not apply, the cherry-pick is aborted and a resolution task redoes it on
+// the target branch (see conflicts.go).
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// HandleTaskCherryPick handles POST /api/tasks/{id}/cherry-pick
+func (h *Handler) HandleTaskCherryPick(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	var req CherryPickRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	req.TargetBranch = strings.TrimSpace(req.TargetBranch)
+	if req.TargetBranch == "" {
+		h.writeError(w, http.StatusBadRequest, "Target branch is required")
+		return
+	}
+
+	var project *Project
+	if task.ProjectID != "" {
+		project, _ = h.db.GetProject(task.ProjectID)
+	}
+	projectDir := task.ProjectDir
+	if projectDir == "" && project != nil {
+		projectDir = project.Path
+	}
+	if projectDir == "" || !IsGitRepository(projectDir) {
+		h.writeError(w, http.StatusBadRequest, "Task has no git project")
+		return
+	}
+	if !localOrRemoteBranchExists(projectDir, req.TargetBranch) {
+		h.writeError(w, http.StatusBadRequest, "Unknown branch: "+req.TargetBranch)
+		return
+	}
+	if op := gitOperation(gitRoot(projectDir)); op != "" {
+		h.writeError(w, http.StatusConflict, "A "+op+" is in progress, finish or abort it first")
+		return
+	}
+
+	if project != nil {
+		if err := commitIndex.IndexProject(h.db, project); err != nil {
+			componentLog("commitindex").Warn("Failed to index project", "path", project.Path, "err", err)
+		}
+	}
+	hashes, err := h.taskCommitsToPick(task, projectDir, req.TargetBranch)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to find the task's commits: "+err.Error())
+		return
+	}
+	if len(hashes) == 0 {
+		h.writeError(w, http.StatusBadRequest, "The task has no commits that "+req.TargetBranch+" lacks")
+		return
+	}
+
+	result, files, err := h.cherryPickOnto(task, projectDir, req, hashes, requestAuthor(r), h.pushToken(r))
+	if err != nil {
+		h.writeError(w, pushErrorStatus(err), "Failed to cherry-pick: "+err.Error())
+		return
+	}
+	if len(files) > 0 {
+		resolution, err := h.runner.openCherryPickConflict(task, req.TargetBranch, hashes, files)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to queue the conflict resolution: "+err.Error())
+			return
+		}
+		go h.runner.TryStartNextQueued(r.Context())
+		h.writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":           "The commits conflict with " + req.TargetBranch + "; a task to cherry-pick them was queued",
+			"resolution_task": resolution,
+		})
+		return
+	}
+
+	msg := fmt.Sprintf("\n[FORGE] Cherry-picked %d commit(s) onto %s\n", len(result.Commits), req.TargetBranch)
+	h.db.AppendTaskLogs(task.ID, msg)
+	h.hub.BroadcastLog(task.ID, msg)
+	componentLog("git").Info("Cherry-picked task", "task_id", task.ID, "branch", req.TargetBranch, "commits", len(result.Commits))
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// taskCommitsToPick returns the task's commits whose changes branch does
+// not have, oldest first. Merge commits and earlier cherry-picks are left out.
+func (h *Handler) taskCommitsToPick(task *Task, path, branch string) ([]string, error) {
+	commits, err := h.db.GetTaskCommits(task.ID)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	for _, commit := range commits {
+		if commit.ProjectID == "" || commit.ProjectID == task.ProjectID {
+			args = append(args, commit.Hash)
+		}
+	}
+	if len(args) > 0 {
+		args = append([]string{"--no-walk=sorted"}, args...)
+	} else if task.RollbackTag != "" {
+		end := task.CommitHash
+		if end == "" {
+			end = "HEAD"
+		}
+		args = []string{task.RollbackTag + ".." + end}
+	} else {
+		return nil, nil
+	}
+	// Earlier cherry-picks keep the task trailer, only the originals count
+	args = append([]string{"rev-list", "--no-merges", "--reverse", "--invert-grep", "--fixed-strings", "--grep", "cherry picked from commit "}, args...)
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git rev-list failed: %v", err)
+	}
+	var hashes []string
+	for _, hash := range strings.Fields(string(output)) {
+		if !branchHasChange(path, branch, hash) {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+// branchHasChange reports whether branch contains hash or an equivalent
+// change, e.g. from an earlier cherry-pick
+func branchHasChange(path, branch, hash string) bool {
+	cmd := exec.Command("git", "cherry", branch, hash, hash+"^")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		// A root commit has no parent to compare from
+		return false
+	}
+	return !strings.HasPrefix(strings.TrimSpace(string(output)), "+")
+}
+
+// cherryPickOnto cherry-picks hashes onto the target branch, committed by
+// committer, and pushes it with token if asked. If a commit does not apply,
+// the cherry-pick is aborted and the conflicting files are returned instead.
+func (h *Handler) cherryPickOnto(task *Task, path string, req CherryPickRequest, hashes []string, committer gitAuthor, token string) (*CherryPickResponse, []ConflictFile, error) {
+	defer lockRepo(path, "cherry-pick")()
+	defer gitStatus.Invalidate(path)
+
+	original, _ := GetCurrentBranch(path)
+	stash, err := StashChanges(path, "FORGE: before cherry-pick onto "+req.TargetBranch)
+	stashed := err == nil
+	if err != nil && !errors.Is(err, errNothingToStash) {
+		return nil, nil, err
+	}
+	defer func() {
+		if original != "" && original != req.TargetBranch {
+			if err := CheckoutBranch(path, original); err != nil {
+				componentLog("git").Warn("Failed to switch back after cherry-pick", "branch", original, "err", err)
+			}
+		}
+		if stashed {
+			if err := popStashCommit(path, stash); err != nil {
+				componentLog("git").Warn("Failed to restore stashed changes, they are kept in the stash", "err", err)
+			}
+		}
+	}()
+
+	if err := CheckoutBranch(path, req.TargetBranch); err != nil {
+		return nil, nil, err
+	}
+	before, err := GetCurrentCommitHash(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pick := exec.Command("git", append(append(committer.configArgs(), "cherry-pick", "-x"), hashes...)...)
+	pick.Dir = path
+	if output, err := runSigned(pick); err != nil {
+		files, _ := GetConflictFiles(path)
+		abort := exec.Command("git", "cherry-pick", "--abort")
+		abort.Dir = path
+		abort.Run()
+		if len(files) > 0 {
+			return nil, files, nil
+		}
+		return nil, nil, gitFailed("cherry-pick", err, output)
+	}
+
+	result := &CherryPickResponse{Branch: req.TargetBranch, Commits: []string{}}
+	list := exec.Command("git", "rev-list", "--reverse", before+"..HEAD")
+	list.Dir = path
+	if output, err := list.Output(); err == nil {
+		result.Commits = strings.Fields(string(output))
+	}
+	if req.Push {
+		if err := pushUnlessProtectedAs(h.db, task.ProjectID, path, token); err != nil {
+			return nil, nil, err
+		}
+		result.Pushed = true
+	}
+	return result, nil, nil
+}
+
+// openCherryPickConflict queues a resolution task that cherry-picks hashes
+// onto branch by hand. The task itself is not blocked.
+func (r *RalphRunner) openCherryPickConflict(task *Task, branch string, hashes []string, conflict []ConflictFile) (*Task, error) {
+	files := make([]string, 0, len(conflict))
+	for _, file := range conflict {
+		files = append(files, file.Path)
+	}
+
+	var desc strings.Builder
+	fmt.Fprintf(&desc, "Cherry-pick the commits of the task %q onto the branch `%s`. They don't apply cleanly; conflicting files:\n\n", task.Title, branch)
+	for _, file := range files {
+		fmt.Fprintf(&desc, "- %s\n", file)
+	}
+	fmt.Fprintf(&desc, "\nRun `git cherry-pick -x %s`, resolve each conflict so the change of the commit is kept and fits the branch, ", strings.Join(hashes, " "))
+	desc.WriteString("then continue with `git cherry-pick --continue` until all commits are applied. Do not push and do not switch branches.")
+
+	resolution, err := r.queueResolution(task, &TaskConflict{
+		Stage:          ConflictStageCherryPick,
+		Branch:         branch,
+		UpstreamCommit: hashes[len(hashes)-1],
+		Files:          files,
+	}, CreateTaskRequest{
+		Title:              fmt.Sprintf("Cherry-pick onto %s: %s", branch, task.Title),
+		Description:        desc.String(),
+		AcceptanceCriteria: fmt.Sprintf("- All %d commit(s) are applied on %s\n- No cherry-pick is in progress and no conflict markers are left", len(hashes), branch),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("\n[FORGE] Cherry-pick onto %s conflicts in: %s\n[FORGE] Queued %q to do it\n", branch, strings.Join(files, ", "), resolution.Title)
+	r.db.AppendTaskLogs(task.ID, msg)
+	r.hub.BroadcastLog(task.ID, msg)
+	componentLog("conflicts").Info("Cherry-pick conflict, queued resolution task", "task_id", task.ID, "branch", branch, "resolution_task_id", resolution.ID)
+	if queued, _ := r.db.GetTask(resolution.ID); queued != nil {
+		r.hub.BroadcastTaskUpdate(queued)
+		resolution = queued
+	}
+	return resolution, nil
+}
+
+// localOrRemoteBranchExists reports whether branch exists locally or on origin
+func localOrRemoteBranchExists(path, branch string) bool {
+	for _, ref := range []string{"refs/heads/" + branch, "refs/remotes/origin/" + branch} {
+		cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", ref)
+		cmd.Dir = path
+		if cmd.Run() == nil {
+			return true
+		}
+	}
+	return false
+}
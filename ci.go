@@ -0,0 +1,349 @@
+// ci.go shows the GitHub checks of a task's commit on the task. Every
+// FORGE_CI_SYNC_INTERVAL the runner looks up the check runs of the commits of
+// recently finished tasks whose CI has not finished yet; a GitHub webhook for
+// check_run and check_suite events (secret in FORGE_GITHUB_WEBHOOK_SECRET)
+// triggers the same lookup right away. With FORGE_CI_AUTO_FIX=true a task in
+// review whose checks fail is queued again with the failing jobs and the end
+// of their logs as continue message.
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CI status of a task's commit
+const (
+	CIStatusPending = "pending"
+	CIStatusSuccess = "success"
+	CIStatusFailure = "failure"
+)
+
+// defaultCISyncInterval is how often the checks of tasks are looked up
+const defaultCISyncInterval = time.Minute
+
+// ciWatchWindow is how long after its last update a task's checks are looked up
+const ciWatchWindow = 24 * time.Hour
+
+// ciLogTailLines is how many lines from the end of a failed job's log go into the continue message
+const ciLogTailLines = 60
+
+// ciLogTailLimit caps the log tail of each job in bytes
+const ciLogTailLimit = 6000
+
+// maxWebhookSize is the largest payload GitHub sends
+const maxWebhookSize = 25 << 20
+
+// ciAutoFixLimit is how often a task is continued automatically because of failed checks
+const ciAutoFixLimit = 3
+
+// ciFailedConclusions are the conclusions of check runs that fail the CI
+var ciFailedConclusions = map[string]bool{
+	"failure": true, "timed_out": true, "cancelled": true, "action_required": true, "startup_failure": true,
+}
+
+// actionsTimestamp matches the timestamp GitHub Actions puts before every log line
+var actionsTimestamp = regexp.MustCompile(`(?m)^\d{4}-\d\d-\d\dT[\d:.]+Z `)
+
+// ciSyncIntervalFromEnv reads FORGE_CI_SYNC_INTERVAL (e.g. 30s; 0 disables the polling)
+func ciSyncIntervalFromEnv() time.Duration {
+	v := os.Getenv("FORGE_CI_SYNC_INTERVAL")
+	if v == "" {
+		return defaultCISyncInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("ci").Warn("Ignoring invalid FORGE_CI_SYNC_INTERVAL", "value", v)
+		return defaultCISyncInterval
+	}
+	return d
+}
+
+// RunCISync looks up the checks of tasks every interval until stop is closed
+func (r *RalphRunner) RunCISync(interval time.Duration, stop <-chan struct{}) {
+	if interval == 0 {
+		componentLog("ci").Info("CI status polling disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.SyncTaskCI(watchingCI)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// watchingCI reports whether the checks of a task are still looked up: it
+// has a commit, finished recently and its checks have not finished
+func watchingCI(task *Task) bool {
+	if task.Status != StatusReview && task.Status != StatusDone {
+		return false
+	}
+	if task.CIStatus != "" && task.CIStatus != CIStatusPending {
+		return false
+	}
+	return time.Since(task.UpdatedAt) < ciWatchWindow
+}
+
+// SyncTaskCI updates the CI status of the tasks with a commit that match
+func (r *RalphRunner) SyncTaskCI(match func(*Task) bool) {
+	config, err := r.db.GetConfig()
+	if err != nil || config.GithubToken == "" {
+		return
+	}
+	tasks, err := r.db.GetAllTasks()
+	if err != nil {
+		componentLog("ci").Error("Failed to list tasks", "err", err)
+		return
+	}
+
+	// Project ID -> owner/repo on its GitHub instance, repo "" if not on GitHub
+	type projectRepo struct {
+		endpoints GitHubEndpoints
+		repo      string
+	}
+	repos := make(map[string]projectRepo)
+	for i := range tasks {
+		task := &tasks[i]
+		if task.CommitHash == "" || task.ProjectID == "" || !match(task) {
+			continue
+		}
+
+		pr, ok := repos[task.ProjectID]
+		if !ok {
+			if project, _ := r.db.GetProject(task.ProjectID); project != nil {
+				pr.endpoints = githubEndpointsFor(config, project)
+				if remoteURL, err := GetRemoteURL(project.Path); err == nil {
+					pr.repo, _ = pr.endpoints.parseRepo(remoteURL)
+				}
+			}
+			repos[task.ProjectID] = pr
+		}
+		if pr.repo != "" {
+			r.syncTaskCI(pr.endpoints, config.GithubToken, pr.repo, task)
+		}
+	}
+}
+
+// syncTaskCI looks up the checks of one task's commit and stores their status
+func (r *RalphRunner) syncTaskCI(endpoints GitHubEndpoints, token, repo string, task *Task) {
+	client := endpoints.client(token)
+	runs, err := client.ListCheckRuns(repo, task.CommitHash)
+	if err != nil {
+		componentLog("ci").Warn("Failed to get check runs", "task_id", task.ID, "commit", task.CommitHash, "err", err)
+		return
+	}
+	status, failed := ciStatusOf(runs)
+	if status == "" {
+		return // No checks (yet), e.g. the commit was not pushed
+	}
+
+	url := fmt.Sprintf("%s/commit/%s/checks", endpoints.repoURL(repo), task.CommitHash)
+	if len(failed) > 0 {
+		url = failed[0].HTMLURL
+	}
+	if status == task.CIStatus && url == task.CIURL {
+		return
+	}
+	if err := r.db.UpdateTaskCI(task.ID, status, url); err != nil {
+		componentLog("ci").Error("Failed to save CI status", "task_id", task.ID, "err", err)
+		return
+	}
+
+	if status != CIStatusPending {
+		msg := "[FORGE] CI checks passed for " + shortHash(task.CommitHash) + "\n"
+		if status == CIStatusFailure {
+			names := make([]string, len(failed))
+			for i, run := range failed {
+				names[i] = run.Name
+			}
+			msg = "[FORGE] CI checks failed for " + shortHash(task.CommitHash) + ": " + strings.Join(names, ", ") + "\n"
+		}
+		r.db.AppendTaskLogs(task.ID, msg)
+		r.hub.BroadcastLog(task.ID, msg)
+		componentLog("ci").Info("CI finished", "task_id", task.ID, "commit", task.CommitHash, "status", status)
+	}
+	if updated, _ := r.db.GetTask(task.ID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+
+	if status == CIStatusFailure {
+		r.autoFixCI(client, repo, task, failed)
+	}
+}
+
+// ciStatusOf sums up check runs: pending while one runs, failure if one
+// failed, success otherwise; "" without check runs. Returns the failed runs.
+func ciStatusOf(runs []GitHubCheckRun) (string, []GitHubCheckRun) {
+	if len(runs) == 0 {
+		return "", nil
+	}
+	var failed []GitHubCheckRun
+	pending := false
+	for _, run := range runs {
+		if run.Status != "completed" {
+			pending = true
+		} else if ciFailedConclusions[run.Conclusion] {
+			failed = append(failed, run)
+		}
+	}
+	switch {
+	case pending:
+		return CIStatusPending, nil
+	case len(failed) > 0:
+		return CIStatusFailure, failed
+	default:
+		return CIStatusSuccess, nil
+	}
+}
+
+// autoFixCI queues a task in review whose checks failed with the failing
+// jobs as continue message, if FORGE_CI_AUTO_FIX is set
+func (r *RalphRunner) autoFixCI(client *GitHubClient, repo string, task *Task, failed []GitHubCheckRun) {
+	if os.Getenv("FORGE_CI_AUTO_FIX") != "true" || task.Status != StatusReview {
+		return
+	}
+	if task.CIFixAttempts >= ciAutoFixLimit {
+		msg := fmt.Sprintf("[FORGE] CI still fails after %d automatic attempts, not continuing again\n", task.CIFixAttempts)
+		r.db.AppendTaskLogs(task.ID, msg)
+		r.hub.BroadcastLog(task.ID, msg)
+		return
+	}
+
+	if err := r.db.AddToQueueWithMessage(task.ID, ciFixMessage(client, repo, task, failed)); err != nil {
+		componentLog("ci").Warn("Failed to queue task to fix CI", "task_id", task.ID, "err", err)
+		return
+	}
+	r.db.IncrementTaskCIFixAttempts(task.ID)
+	componentLog("ci").Info("Queued task to fix CI", "task_id", task.ID, "attempt", task.CIFixAttempts+1)
+	if updated, _ := r.db.GetTask(task.ID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+	go r.TryStartNextQueued(context.Background())
+}
+
+// ciFixMessage is the continue message for a task whose checks failed: the
+// failed checks with the end of their logs, where GitHub Actions has them
+func ciFixMessage(client *GitHubClient, repo string, task *Task, failed []GitHubCheckRun) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The CI checks of your commit %s failed. Find and fix the cause, then make sure the checks would pass.\n\n", shortHash(task.CommitHash))
+	for _, run := range failed {
+		fmt.Fprintf(&sb, "## %s (%s)\n\n%s\n\n", run.Name, strings.ReplaceAll(run.Conclusion, "_", " "), run.HTMLURL)
+		if run.App.Slug != "github-actions" {
+			continue
+		}
+		log, err := client.GetJobLog(repo, run.ID)
+		if err != nil {
+			componentLog("ci").Warn("Failed to get job log", "task_id", task.ID, "job", run.Name, "err", err)
+			continue
+		}
+		fmt.Fprintf(&sb, "End of the log:\n\n```\n%s\n```\n\n", logTail(log))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// logTail returns the last lines of a job log without the Actions timestamps
+func logTail(log string) string {
+	lines := strings.Split(strings.TrimRight(actionsTimestamp.ReplaceAllString(log, ""), "\n"), "\n")
+	if len(lines) > ciLogTailLines {
+		lines = lines[len(lines)-ciLogTailLines:]
+	}
+	return truncateText(strings.Join(lines, "\n"), ciLogTailLimit)
+}
+
+// shortHash abbreviates a commit hash for messages
+func shortHash(hash string) string {
+	return hash[:min(7, len(hash))]
+}
+
+// HandleGitHubWebhook handles POST /api/webhooks/github
+// check_run and check_suite events update the CI status of the tasks with
+// the event's commit right away and auto-merge pull requests whose checks
+// passed (see automerge.go), review events the review comments of tasks
+// (see reviews.go). The payload must be signed with FORGE_GITHUB_WEBHOOK_SECRET.
+func (h *Handler) HandleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	secret := os.Getenv("FORGE_GITHUB_WEBHOOK_SECRET")
+	if secret == "" {
+		h.writeError(w, http.StatusNotFound, "GitHub webhook is not configured, set FORGE_GITHUB_WEBHOOK_SECRET")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Failed to read body: "+err.Error())
+		return
+	}
+	if !validWebhookSignature(secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		h.writeError(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	var payload struct {
+		CheckRun *struct {
+			HeadSHA string `json:"head_sha"`
+		} `json:"check_run"`
+		CheckSuite *struct {
+			HeadSHA string `json:"head_sha"`
+		} `json:"check_suite"`
+	}
+	event := r.Header.Get("X-GitHub-Event")
+	if reviewWebhookEvents[event] {
+		h.handleReviewWebhook(w, body)
+		return
+	}
+	if event != "check_run" && event != "check_suite" {
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+		return
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	var sha string
+	if payload.CheckRun != nil {
+		sha = payload.CheckRun.HeadSHA
+	} else if payload.CheckSuite != nil {
+		sha = payload.CheckSuite.HeadSHA
+	}
+	if sha == "" {
+		h.writeError(w, http.StatusBadRequest, "Event has no head_sha")
+		return
+	}
+
+	go func() {
+		h.runner.SyncTaskCI(func(task *Task) bool { return task.CommitHash == sha })
+		// Pull requests waiting for their checks to auto-merge (see automerge.go)
+		h.runner.SyncTaskPRs()
+	}()
+	h.writeJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+}
+
+// validWebhookSignature checks the X-Hub-Signature-256 header of a webhook
+func validWebhookSignature(secret string, body []byte, signature string) bool {
+	hexSum, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	sum, err := hex.DecodeString(hexSum)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sum, mac.Sum(nil))
+}
@@ -0,0 +1,252 @@
+// claudecli.go detects what the installed Claude CLI supports. Versions
+// differ in their flags: older ones lack stream-json input, --resume or
+// --disallowedTools. The configured command is probed with --version and
+// --help at startup and whenever the command changes in the config; the
+// runner builds its arguments from the detected capabilities, so a missing
+// optional flag degrades a feature (feedback then restarts the task) and a
+// missing required one fails the run with a clear message instead of a
+// cryptic Claude error. GET /api/doctor shows what was detected.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// claudeHelpTimeout limits how long claude --help may take
+const claudeHelpTimeout = 10 * time.Second
+
+// claudeCLI is the process-wide cache of detected Claude CLI capabilities
+var claudeCLI = &claudeProber{entries: make(map[string]*ClaudeCapabilities)}
+
+// claudeProber probes Claude commands and caches the results per command
+type claudeProber struct {
+	mu      sync.Mutex
+	entries map[string]*ClaudeCapabilities
+}
+
+// claudeCommand returns the configured Claude command
+func claudeCommand(config *Config) string {
+	if config.ClaudeCommand == "" {
+		return "claude"
+	}
+	return config.ClaudeCommand
+}
+
+// Get returns the capabilities of command, probing it on first use. A failed
+// probe is repeated, the command may have been installed since.
+func (p *claudeProber) Get(command string) *ClaudeCapabilities {
+	p.mu.Lock()
+	caps, ok := p.entries[command]
+	p.mu.Unlock()
+	if ok && caps.Error == "" {
+		return caps
+	}
+	return p.Refresh(command)
+}
+
+// Refresh probes command again and caches the result
+func (p *claudeProber) Refresh(command string) *ClaudeCapabilities {
+	caps := probeClaude(command)
+	p.mu.Lock()
+	p.entries[command] = caps
+	p.mu.Unlock()
+
+	logger := componentLog("claude")
+	switch {
+	case caps.Error != "":
+		logger.Warn("Claude CLI not usable", "command", command, "err", caps.Error)
+	case !caps.Detected:
+		logger.Info("Claude CLI help not recognized, assuming all flags are supported", "command", command, "version", caps.Version)
+	default:
+		logger.Info("Detected Claude CLI", "command", command, "version", caps.Version, "missing", strings.Join(caps.Missing(), ","))
+	}
+	return caps
+}
+
+// probeClaude runs command with --version and --help
+func probeClaude(command string) *ClaudeCapabilities {
+	caps := &ClaudeCapabilities{Command: command, DetectedAt: time.Now()}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		caps.Error = fmt.Sprintf("Claude CLI %q not found", command)
+		return caps
+	}
+	caps.Path = path
+
+	version, err := commandVersion(path)
+	if err != nil {
+		caps.Error = fmt.Sprintf("%s --version failed: %v", path, err)
+		return caps
+	}
+	caps.Version = version
+
+	ctx, cancel := context.WithTimeout(context.Background(), claudeHelpTimeout)
+	defer cancel()
+	help, _ := exec.CommandContext(ctx, path, "--help").CombinedOutput()
+	caps.applyHelp(string(help))
+	return caps
+}
+
+// applyHelp sets the capabilities from the --help output. Output that does
+// not look like Claude's help (e.g. from a wrapper script) is not trusted:
+// every flag is then assumed to be supported, as before the detection.
+func (c *ClaudeCapabilities) applyHelp(help string) {
+	if !strings.Contains(help, "--print") {
+		c.Detected = false
+		c.StreamJSONInput, c.StreamJSONOutput, c.Verbose = true, true, true
+		c.SkipPermissions, c.DisallowedTools, c.Resume = true, true, true
+		c.AllowedTools, c.PermissionMode, c.AppendSystemPrompt, c.MCPConfig = true, true, true, true
+		return
+	}
+
+	c.Detected = true
+	input, hasInput := helpOption(help, "--input-format")
+	output, hasOutput := helpOption(help, "--output-format")
+	c.StreamJSONInput = hasInput && strings.Contains(input, "stream-json")
+	c.StreamJSONOutput = hasOutput && strings.Contains(output, "stream-json")
+	_, c.Verbose = helpOption(help, "--verbose")
+	_, c.SkipPermissions = helpOption(help, "--dangerously-skip-permissions")
+	_, c.DisallowedTools = helpOption(help, "--disallowedTools")
+	_, c.Resume = helpOption(help, "--resume")
+	_, c.AllowedTools = helpOption(help, "--allowedTools")
+	_, c.PermissionMode = helpOption(help, "--permission-mode")
+	_, c.AppendSystemPrompt = helpOption(help, "--append-system-prompt")
+	_, c.MCPConfig = helpOption(help, "--mcp-config")
+}
+
+// helpOption finds flag in the option list of a --help output and returns
+// its description, including continuation lines
+func helpOption(help, flag string) (string, bool) {
+	lines := strings.Split(help, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		// The flags of an option come before its description, e.g. "-r, --resume [id]  Resume ..."
+		names, _, _ := strings.Cut(trimmed, "  ")
+		found := false
+		for _, name := range strings.FieldsFunc(names, func(r rune) bool { return r == ',' || r == ' ' }) {
+			if name == flag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		desc := trimmed
+		for _, next := range lines[i+1:] {
+			next = strings.TrimSpace(next)
+			if next == "" || strings.HasPrefix(next, "-") {
+				break
+			}
+			desc += " " + next
+		}
+		return desc, true
+	}
+	return "", false
+}
+
+// Missing lists the flags FORGE uses that the CLI lacks
+func (c *ClaudeCapabilities) Missing() []string {
+	var missing []string
+	for _, flag := range []struct {
+		name      string
+		supported bool
+	}{
+		{"--output-format stream-json", c.StreamJSONOutput},
+		{"--dangerously-skip-permissions", c.SkipPermissions},
+		{"--input-format stream-json", c.StreamJSONInput},
+		{"--verbose", c.Verbose},
+		{"--disallowedTools", c.DisallowedTools},
+		{"--resume", c.Resume},
+	} {
+		if !flag.supported {
+			missing = append(missing, flag.name)
+		}
+	}
+	return missing
+}
+
+// Args returns the arguments of a headless run with settings applied (see
+// claudesettings.go). Plan mode also disables the file editing tools. Without
+// stream-json input the prompt is sent as plain text and the session takes no
+// further messages.
+func (c *ClaudeCapabilities) Args(planning bool, settings ClaudeSettings) ([]string, error) {
+	if c.Error != "" {
+		return nil, errors.New(c.Error)
+	}
+	unsupported := func(what string) error {
+		return fmt.Errorf("Claude CLI %s does not support %s; update Claude Code (npm install -g @anthropic-ai/claude-code)", c.Version, what)
+	}
+	if !c.StreamJSONOutput {
+		return nil, unsupported("--output-format stream-json")
+	}
+	bypass := settings.PermissionMode == "" || settings.PermissionMode == PermissionModeBypass
+	if bypass && !c.SkipPermissions {
+		return nil, unsupported("--dangerously-skip-permissions")
+	}
+	if !bypass && !c.PermissionMode {
+		return nil, unsupported("--permission-mode, which the Claude settings use")
+	}
+	if planning && !c.DisallowedTools {
+		return nil, unsupported("--disallowedTools, which plan mode needs")
+	}
+	if len(settings.DisallowedTools) > 0 && !c.DisallowedTools {
+		return nil, unsupported("--disallowedTools, which the Claude settings use")
+	}
+	if len(settings.AllowedTools) > 0 && !c.AllowedTools {
+		return nil, unsupported("--allowedTools, which the Claude settings use")
+	}
+	if settings.AppendSystemPrompt != "" && !c.AppendSystemPrompt {
+		return nil, unsupported("--append-system-prompt, which the Claude settings use")
+	}
+	extraArgs, err := splitCLIArgs(settings.ExtraArgs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid additional Claude arguments: %w", err)
+	}
+
+	args := []string{"-p"}
+	if c.StreamJSONInput {
+		args = append(args, "--input-format", "stream-json")
+	}
+	args = append(args, "--output-format", "stream-json")
+	if c.Verbose { // Newer versions only stream JSON with --verbose
+		args = append(args, "--verbose")
+	}
+	if bypass {
+		args = append(args, "--dangerously-skip-permissions") // Autonomous file operations
+	} else {
+		args = append(args, "--permission-mode", settings.PermissionMode)
+	}
+	if len(settings.AllowedTools) > 0 {
+		args = append(args, "--allowedTools", strings.Join(settings.AllowedTools, ","))
+	}
+	disallowed := settings.DisallowedTools
+	if planning {
+		disallowed = mergeTools(planDisallowedTools, disallowed)
+	}
+	if len(disallowed) > 0 {
+		args = append(args, "--disallowedTools", strings.Join(disallowed, ","))
+	}
+	if settings.AppendSystemPrompt != "" {
+		args = append(args, "--append-system-prompt", settings.AppendSystemPrompt)
+	}
+	return append(args, extraArgs...), nil
+}
+
+// encodePrompt returns the first message of a session in the input format of the CLI
+func (c *ClaudeCapabilities) encodePrompt(text string) ([]byte, error) {
+	if !c.StreamJSONInput {
+		return []byte(text), nil
+	}
+	return encodeUserMessage(text)
+}
@@ -0,0 +1,225 @@
+// claudesettings.go lets advanced users tune how RALPH invokes Claude: tools
+// to allow or forbid, the permission mode, additional CLI arguments and text
+// appended to the system prompt. The settings exist globally (Settings) and
+// per project; a project adds its tools and arguments to the global ones and
+// overrides the permission mode. Flags FORGE itself depends on, such as the
+// output format, cannot be overridden through the additional arguments.
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Permission modes of the Claude CLI a run may use. Plan mode is not offered,
+// FORGE has its own (see plan.go).
+const (
+	PermissionModeBypass = "bypassPermissions" // No permission checks (default)
+	PermissionModeAccept = "acceptEdits"       // File edits allowed, other tools only if allowed
+	PermissionModeAsk    = "default"           // Only allowed tools; in a headless run everything else is denied
+)
+
+// reservedClaudeFlags are set by FORGE or by a dedicated setting and may not
+// appear in the additional arguments
+var reservedClaudeFlags = []string{
+	"-p", "--print", "--input-format", "--output-format", "-r", "--resume", "-c", "--continue",
+	"--dangerously-skip-permissions", "--permission-mode", "--allowedTools", "--allowed-tools",
+	"--disallowedTools", "--disallowed-tools", "--append-system-prompt",
+}
+
+// ClaudeSettings configures the Claude invocation, stored as a JSON object
+type ClaudeSettings struct {
+	AllowedTools       []string `json:"allowed_tools,omitempty"`        // e.g. "Bash(git:*)", "Edit"
+	DisallowedTools    []string `json:"disallowed_tools,omitempty"`     // Forbidden in every run
+	PermissionMode     string   `json:"permission_mode,omitempty"`      // "" = bypassPermissions
+	ExtraArgs          string   `json:"extra_args,omitempty"`           // Additional CLI arguments, shell-quoted
+	AppendSystemPrompt string   `json:"append_system_prompt,omitempty"` // Appended to Claude's system prompt
+}
+
+// IsZero reports whether nothing is configured
+func (s ClaudeSettings) IsZero() bool {
+	return len(s.AllowedTools) == 0 && len(s.DisallowedTools) == 0 && s.PermissionMode == "" &&
+		s.ExtraArgs == "" && s.AppendSystemPrompt == ""
+}
+
+// Value stores the settings as JSON, no settings as an empty string
+func (s ClaudeSettings) Value() (driver.Value, error) {
+	if s.IsZero() {
+		return "", nil
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads settings stored by Value
+func (s *ClaudeSettings) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into ClaudeSettings", src)
+	}
+	*s = ClaudeSettings{}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, s)
+}
+
+// clean trims the settings and rejects values the runner could not use
+func (s *ClaudeSettings) clean() error {
+	var err error
+	if s.AllowedTools, err = cleanToolList(s.AllowedTools); err != nil {
+		return err
+	}
+	if s.DisallowedTools, err = cleanToolList(s.DisallowedTools); err != nil {
+		return err
+	}
+
+	s.PermissionMode = strings.TrimSpace(s.PermissionMode)
+	switch s.PermissionMode {
+	case "", PermissionModeBypass, PermissionModeAccept, PermissionModeAsk:
+	default:
+		return fmt.Errorf("invalid permission mode %q (use %s, %s or %s)", s.PermissionMode, PermissionModeBypass, PermissionModeAccept, PermissionModeAsk)
+	}
+
+	s.ExtraArgs = strings.TrimSpace(s.ExtraArgs)
+	args, err := splitCLIArgs(s.ExtraArgs)
+	if err != nil {
+		return fmt.Errorf("invalid additional arguments: %w", err)
+	}
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		if slices.Contains(reservedClaudeFlags, name) {
+			return fmt.Errorf("%s is set by FORGE and cannot be an additional argument", name)
+		}
+	}
+
+	s.AppendSystemPrompt = strings.TrimSpace(s.AppendSystemPrompt)
+	return nil
+}
+
+// cleanToolList trims tool names and drops empty and repeated ones
+func cleanToolList(tools []string) ([]string, error) {
+	var cleaned []string
+	for _, tool := range tools {
+		tool = strings.TrimSpace(tool)
+		if tool == "" || slices.Contains(cleaned, tool) {
+			continue
+		}
+		if strings.Contains(tool, ",") {
+			return nil, fmt.Errorf("invalid tool %q: list one tool per entry", tool)
+		}
+		cleaned = append(cleaned, tool)
+	}
+	return cleaned, nil
+}
+
+// mergeClaudeSettings applies the settings of a project over the global ones:
+// tools and arguments add up, the permission mode of the project wins
+func mergeClaudeSettings(global, project ClaudeSettings) ClaudeSettings {
+	merged := ClaudeSettings{
+		AllowedTools:    mergeTools(global.AllowedTools, project.AllowedTools),
+		DisallowedTools: mergeTools(global.DisallowedTools, project.DisallowedTools),
+		PermissionMode:  global.PermissionMode,
+		ExtraArgs:       strings.TrimSpace(global.ExtraArgs + " " + project.ExtraArgs),
+	}
+	if project.PermissionMode != "" {
+		merged.PermissionMode = project.PermissionMode
+	}
+	var prompts []string
+	for _, prompt := range []string{global.AppendSystemPrompt, project.AppendSystemPrompt} {
+		if prompt != "" {
+			prompts = append(prompts, prompt)
+		}
+	}
+	merged.AppendSystemPrompt = strings.Join(prompts, "\n\n")
+	return merged
+}
+
+// mergeTools returns the tools of both lists, each once
+func mergeTools(lists ...[]string) []string {
+	var merged []string
+	for _, list := range lists {
+		for _, tool := range list {
+			if !slices.Contains(merged, tool) {
+				merged = append(merged, tool)
+			}
+		}
+	}
+	return merged
+}
+
+// splitCLIArgs splits a command line like a POSIX shell does, with single and
+// double quotes and backslash escapes, but without expansions
+func splitCLIArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// claudeSettings returns the settings of a run of task: the global ones with
+// those of the task's project applied
+func (r *RalphRunner) claudeSettings(task *Task, config *Config) ClaudeSettings {
+	settings := config.Claude
+	if task.ProjectID != "" {
+		if project, err := r.db.GetProject(task.ProjectID); err == nil && project != nil {
+			settings = mergeClaudeSettings(settings, project.Claude)
+		}
+	}
+	return settings
+}
@@ -0,0 +1,646 @@
+// cli.go implements the terminal client of FORGE ("forge task ...").
+// The client talks to a running FORGE server over the HTTP API and the
+// WebSocket, so it can be used from scripts and other machines.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultServerURL is used when neither -server nor FORGE_URL is set
+const defaultServerURL = "http://localhost:3333"
+
+// taskCommandUsage describes the "forge task" subcommands
+const taskCommandUsage = `Usage: forge task <command> [flags]
+
+Commands:
+  create   Create a task (description from -description or stdin)
+  list     List tasks on the board
+  show     Show a single task
+  watch    Stream the logs of a task
+  enqueue  Start a task, or queue it if another task is running
+  stop     Stop the Claude process of a task
+  deploy   Commit and push the changes of a task
+
+Task IDs can be abbreviated to any unique prefix.
+Run "forge task <command> -h" for the flags of a command.`
+
+// apiClient is a minimal client for the FORGE HTTP API
+type apiClient struct {
+	baseURL string
+	token   string // Session token from "forge login" (FORGE_TOKEN), sent as bearer token
+	http    *http.Client
+}
+
+// newAPIClient creates a client for the given server URL
+func newAPIClient(server string) *apiClient {
+	return &apiClient{
+		baseURL: strings.TrimRight(server, "/"),
+		token:   os.Getenv("FORGE_TOKEN"),
+		http:    &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// authHeader returns the headers that authenticate the client, nil without a token
+func (c *apiClient) authHeader() http.Header {
+	if c.token == "" {
+		return nil
+	}
+	return http.Header{"Authorization": {"Bearer " + c.token}}
+}
+
+// do sends a JSON request and decodes the JSON response into out (if not nil).
+// API errors ({"error": "..."}) are returned as Go errors.
+func (c *apiClient) do(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach FORGE at %s: %v", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			if resp.StatusCode == http.StatusUnauthorized {
+				return fmt.Errorf("%s (run \"forge login\" and set FORGE_TOKEN)", apiErr.Error)
+			}
+			return fmt.Errorf("%s", apiErr.Error)
+		}
+		return fmt.Errorf("request failed: %s", resp.Status)
+	}
+
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// resolveTaskID expands a unique ID prefix to the full task ID
+func (c *apiClient) resolveTaskID(prefix string) (string, error) {
+	var tasks []Task
+	if err := c.do(http.MethodGet, "/api/tasks", nil, &tasks); err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, t := range tasks {
+		if t.ID == prefix {
+			return t.ID, nil
+		}
+		if strings.HasPrefix(t.ID, prefix) {
+			matches = append(matches, t.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no task matches %q", prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("task ID %q is ambiguous (%d matches)", prefix, len(matches))
+	}
+}
+
+// newTaskFlags creates the flag set of a task subcommand with the shared -server flag
+func newTaskFlags(name string, args string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("task "+name, flag.ContinueOnError)
+	server := os.Getenv("FORGE_URL")
+	if server == "" {
+		server = defaultServerURL
+	}
+	serverFlag := fs.String("server", server, "FORGE server URL (env: FORGE_URL)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: forge task %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs, serverFlag
+}
+
+// runTaskCommand implements "forge task <command>". Returns the process exit code.
+func runTaskCommand(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprintln(os.Stderr, taskCommandUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "create":
+		err = taskCreateCommand(args[1:])
+	case "list", "ls":
+		err = taskListCommand(args[1:])
+	case "show":
+		err = taskShowCommand(args[1:])
+	case "watch", "logs":
+		err = taskWatchCommand(args[1:])
+	case "enqueue", "start":
+		err = taskEnqueueCommand(args[1:])
+	case "stop":
+		err = taskStopCommand(args[1:])
+	case "deploy":
+		err = taskDeployCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s\n", args[0], taskCommandUsage)
+		return 2
+	}
+
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// taskCreateCommand implements "forge task create"
+func taskCreateCommand(args []string) error {
+	fs, server := newTaskFlags("create", "[title]")
+	title := fs.String("title", "", "task title (or first positional argument)")
+	description := fs.String("description", "", `task description, "-" reads stdin (default: stdin if piped)`)
+	criteria := fs.String("criteria", "", "acceptance criteria")
+	priority := fs.Int("priority", 0, "priority 1-3 (default from config)")
+	project := fs.String("project", "", "project ID")
+	taskType := fs.String("type", "", "task type ID, e.g. type-bug")
+	branch := fs.String("branch", "", "target branch")
+	workDir := fs.String("workdir", "", "subdirectory of the project RALPH runs in")
+	env := TaskEnv{}
+	fs.Var(&env, "env", "environment variable NAME=value for RALPH (repeatable)")
+	enqueue := fs.Bool("enqueue", false, "start or queue the task right away")
+	force := fs.Bool("force", false, "create the task even if similar tasks exist")
+	asJSON := fs.Bool("json", false, "print the created task as JSON")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *title == "" {
+		*title = strings.Join(rest, " ")
+	}
+	if *title == "" {
+		fs.Usage()
+		return fmt.Errorf("a title is required")
+	}
+
+	// Read the description from stdin when asked to, or when something is piped in
+	if *description == "-" || (*description == "" && stdinIsPiped()) {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read description from stdin: %v", err)
+		}
+		*description = strings.TrimSpace(string(data))
+	}
+
+	c := newAPIClient(*server)
+	var task Task
+	err = c.do(http.MethodPost, "/api/tasks", CreateTaskRequest{
+		Title:              *title,
+		Description:        *description,
+		AcceptanceCriteria: *criteria,
+		Priority:           *priority,
+		ProjectID:          *project,
+		TaskTypeID:         *taskType,
+		TargetBranch:       *branch,
+		Env:                env,
+		WorkDir:            *workDir,
+		Force:              *force,
+	}, &task)
+	if err != nil {
+		return err
+	}
+
+	if *enqueue {
+		if err := enqueueTask(c, &task); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		return printJSON(task)
+	}
+	fmt.Printf("Created task %s: %s\n", shortID(task.ID), task.Title)
+	if *enqueue {
+		printEnqueueResult(&task)
+	}
+	return nil
+}
+
+// taskListCommand implements "forge task list"
+func taskListCommand(args []string) error {
+	fs, server := newTaskFlags("list", "")
+	status := fs.String("status", "", "only tasks with this status (comma-separated)")
+	project := fs.String("project", "", "only tasks of this project ID")
+	asJSON := fs.Bool("json", false, "print tasks as JSON")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	c := newAPIClient(*server)
+	var tasks []Task
+	if err := c.do(http.MethodGet, "/api/tasks", nil, &tasks); err != nil {
+		return err
+	}
+
+	statuses := map[string]bool{}
+	for _, s := range strings.Split(*status, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			statuses[s] = true
+		}
+	}
+
+	filtered := []Task{}
+	for _, t := range tasks {
+		if len(statuses) > 0 && !statuses[string(t.Status)] {
+			continue
+		}
+		if *project != "" && t.ProjectID != *project {
+			continue
+		}
+		filtered = append(filtered, t)
+	}
+
+	if *asJSON {
+		return printJSON(filtered)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tPRIO\tITER\tTITLE")
+	for _, t := range filtered {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d/%d\t%s\n",
+			shortID(t.ID), t.Status, t.Priority, t.CurrentIteration, t.MaxIterations, t.Title)
+	}
+	return tw.Flush()
+}
+
+// taskShowCommand implements "forge task show"
+func taskShowCommand(args []string) error {
+	fs, server := newTaskFlags("show", "<task-id>")
+	asJSON := fs.Bool("json", false, "print the task as JSON")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	c, id, err := clientAndTaskID(fs, rest, *server)
+	if err != nil {
+		return err
+	}
+
+	var task Task
+	if err := c.do(http.MethodGet, "/api/tasks/"+id, nil, &task); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(task)
+	}
+
+	fmt.Printf("%s  %s\n", task.ID, task.Title)
+	fmt.Printf("Status:    %s (iteration %d/%d)\n", task.Status, task.CurrentIteration, task.MaxIterations)
+	fmt.Printf("Priority:  %d\n", task.Priority)
+	if task.WorkingBranch != "" {
+		fmt.Printf("Branch:    %s\n", task.WorkingBranch)
+	}
+	if task.Error != "" {
+		fmt.Printf("Error:     %s\n", task.Error)
+	}
+	if task.Description != "" {
+		fmt.Printf("\n%s\n", task.Description)
+	}
+	if task.AcceptanceCriteria != "" {
+		fmt.Printf("\nAcceptance criteria:\n%s\n", task.AcceptanceCriteria)
+	}
+	return nil
+}
+
+// taskWatchCommand implements "forge task watch": prints the existing logs and
+// then streams new log lines over the WebSocket until the task stops running.
+func taskWatchCommand(args []string) error {
+	fs, server := newTaskFlags("watch", "<task-id>")
+	noHistory := fs.Bool("no-history", false, "only print new log lines")
+	follow := fs.Bool("follow", false, "keep watching after the task stops running")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	c, id, err := clientAndTaskID(fs, rest, *server)
+	if err != nil {
+		return err
+	}
+
+	var task Task
+	if err := c.do(http.MethodGet, "/api/tasks/"+id, nil, &task); err != nil {
+		return err
+	}
+	if !*noHistory && task.Logs != "" {
+		fmt.Print(task.Logs)
+		if !strings.HasSuffix(task.Logs, "\n") {
+			fmt.Println()
+		}
+	}
+	if !*follow && !isActiveStatus(task.Status) {
+		fmt.Fprintf(os.Stderr, "Task is %s, not running (use -follow to wait)\n", task.Status)
+		return nil
+	}
+
+	wsURL, err := websocketURL(c.baseURL)
+	if err != nil {
+		return err
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, c.authHeader())
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", wsURL, err)
+	}
+	defer conn.Close()
+
+	// Only receive messages of this task
+	if err := conn.WriteJSON(WSClientMessage{Action: "subscribe", TaskIDs: []string{id}}); err != nil {
+		return fmt.Errorf("failed to subscribe: %v", err)
+	}
+
+	for {
+		var msg WSMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return fmt.Errorf("connection closed: %v", err)
+		}
+		if msg.TaskID != id {
+			continue
+		}
+
+		switch msg.Type {
+		case "log":
+			fmt.Print(msg.Message)
+			if !strings.HasSuffix(msg.Message, "\n") {
+				fmt.Println()
+			}
+		case "status":
+			fmt.Fprintf(os.Stderr, "[forge] status %s (iteration %d)\n", msg.Status, msg.Iteration)
+			if !*follow && !isActiveStatus(msg.Status) {
+				return nil
+			}
+		case "task_updated":
+			if msg.Task != nil && !*follow && !isActiveStatus(msg.Task.Status) {
+				fmt.Fprintf(os.Stderr, "[forge] task is now %s\n", msg.Task.Status)
+				return nil
+			}
+		}
+	}
+}
+
+// taskEnqueueCommand implements "forge task enqueue"
+func taskEnqueueCommand(args []string) error {
+	fs, server := newTaskFlags("enqueue", "<task-id>")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	c, id, err := clientAndTaskID(fs, rest, *server)
+	if err != nil {
+		return err
+	}
+	task := Task{ID: id}
+	if err := enqueueTask(c, &task); err != nil {
+		return err
+	}
+	printEnqueueResult(&task)
+	return nil
+}
+
+// enqueueTask moves a task to progress. The server queues it instead if another task is running.
+func enqueueTask(c *apiClient, task *Task) error {
+	status := StatusProgress
+	return c.do(http.MethodPut, "/api/tasks/"+task.ID, UpdateTaskRequest{Status: &status}, task)
+}
+
+// printEnqueueResult reports whether an enqueued task was started or queued
+func printEnqueueResult(task *Task) {
+	if task.Status == StatusQueued {
+		fmt.Printf("Task %s queued at position %d\n", shortID(task.ID), task.QueuePosition)
+	} else {
+		fmt.Printf("Task %s started\n", shortID(task.ID))
+	}
+}
+
+// taskStopCommand implements "forge task stop"
+func taskStopCommand(args []string) error {
+	fs, server := newTaskFlags("stop", "<task-id>")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	c, id, err := clientAndTaskID(fs, rest, *server)
+	if err != nil {
+		return err
+	}
+	if err := c.do(http.MethodPost, "/api/tasks/"+id+"/stop", nil, nil); err != nil {
+		return err
+	}
+	fmt.Printf("Task %s stopped\n", shortID(id))
+	return nil
+}
+
+// taskDeployCommand implements "forge task deploy"
+func taskDeployCommand(args []string) error {
+	fs, server := newTaskFlags("deploy", "<task-id>")
+	message := fs.String("m", "", "commit message (default: \"Deploy task: <title>\")")
+	env := fs.String("env", "", "environment to deploy to (default: the project's own deploy commands)")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	c, id, err := clientAndTaskID(fs, rest, *server)
+	if err != nil {
+		return err
+	}
+
+	var resp DeploymentResponse
+	if err := c.do(http.MethodPost, "/api/tasks/"+id+"/deploy", DeploymentRequest{CommitMessage: *message, Environment: *env}, &resp); err != nil {
+		return err
+	}
+	if resp.CommitHash != "" {
+		fmt.Printf("Committed %s and pushed to %s\n", resp.CommitHash, resp.PushURL)
+	} else {
+		fmt.Printf("Pushed to %s\n", resp.PushURL)
+	}
+	if resp.Deployment != nil && resp.Deployment.Status == DeploymentRunning {
+		fmt.Printf("Deployment %s started, the task is done once its deploy commands succeed\n", resp.Deployment.ID)
+	}
+	return nil
+}
+
+// String and Set make TaskEnv a repeatable NAME=value flag
+func (e *TaskEnv) String() string {
+	return strings.Join(e.names(), ",")
+}
+
+func (e *TaskEnv) Set(v string) error {
+	name, value, ok := strings.Cut(v, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected NAME=value")
+	}
+	(*e)[name] = value
+	return nil
+}
+
+// runLoginCommand implements "forge login": signs in to a server that requires
+// a GitHub login with the device flow and prints the session token. Returns
+// the process exit code.
+func runLoginCommand(args []string) int {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	server := os.Getenv("FORGE_URL")
+	if server == "" {
+		server = defaultServerURL
+	}
+	serverFlag := fs.String("server", server, "FORGE server URL (env: FORGE_URL)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: forge login [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if err := deviceLogin(newAPIClient(*serverFlag)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// deviceLogin runs the device flow: the user enters a code on GitHub while
+// the server is polled until the login is done
+func deviceLogin(c *apiClient) error {
+	var login DeviceLogin
+	if err := c.do(http.MethodPost, "/api/auth/github/device", nil, &login); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Open %s and enter the code %s\n", login.VerificationURI, login.UserCode)
+
+	interval := time.Duration(max(login.Interval, 1)) * time.Second
+	deadline := time.Now().Add(time.Duration(login.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		var result struct {
+			LoginResult
+			Status   string `json:"status"`
+			Interval int    `json:"interval"`
+		}
+		if err := c.do(http.MethodPost, "/api/auth/github/device/token", DeviceTokenRequest{DeviceCode: login.DeviceCode}, &result); err != nil {
+			return err
+		}
+		switch {
+		case result.Status == "slow_down":
+			interval = time.Duration(max(result.Interval, login.Interval+5)) * time.Second
+		case result.Token != "":
+			fmt.Fprintf(os.Stderr, "Signed in as %s until %s. Use the session with:\n\n", result.User.Login, result.ExpiresAt.Format("2006-01-02"))
+			fmt.Printf("export FORGE_TOKEN=%s\n", result.Token)
+			return nil
+		}
+	}
+	return fmt.Errorf("the code expired, run forge login again")
+}
+
+// parseFlags parses flags that may appear before or after positional arguments
+// and returns the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// clientAndTaskID creates the API client and resolves the task ID argument
+func clientAndTaskID(fs *flag.FlagSet, args []string, server string) (*apiClient, string, error) {
+	if len(args) != 1 {
+		fs.Usage()
+		return nil, "", fmt.Errorf("exactly one task ID is required")
+	}
+	c := newAPIClient(server)
+	id, err := c.resolveTaskID(args[0])
+	return c, id, err
+}
+
+// websocketURL derives the WebSocket endpoint from the server URL
+func websocketURL(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/ws"
+	return u.String(), nil
+}
+
+// isActiveStatus reports whether a task is waiting for or being processed by Claude
+func isActiveStatus(status TaskStatus) bool {
+	return status == StatusProgress || status == StatusQueued
+}
+
+// shortID abbreviates a task ID for terminal output
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// stdinIsPiped reports whether stdin is a pipe or file rather than a terminal
+func stdinIsPiped() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice == 0
+}
+
+// printJSON writes v as indented JSON to stdout
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,280 @@
+// clone.go adds projects straight from a repository URL. The repository is
+// cloned into the projects base directory in the background; progress is
+// streamed as clone_progress WebSocket messages and the project is registered
+// once the clone succeeded. For GitHub HTTPS URLs the stored token is passed
+// to git as an auth header, so it never ends up in the remote URL or .git/config.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// cloneTimeout aborts clones that hang, e.g. on an unreachable host
+const cloneTimeout = 30 * time.Minute
+
+// cloneProgressInterval throttles progress messages within a phase
+const cloneProgressInterval = 250 * time.Millisecond
+
+// cloneProgressRe matches git's progress lines, e.g. "Receiving objects:  45% (450/1000)"
+var cloneProgressRe = regexp.MustCompile(`^(?:remote: )?([A-Za-z ]+):\s+(\d{1,3})%`)
+
+// projectDirNameRe restricts names of directories created in the projects base directory
+var projectDirNameRe = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+var (
+	projectDirsMu sync.Mutex
+	projectDirs   = make(map[string]bool) // Destinations of running clones and bootstraps
+)
+
+// HandleProjectClone handles POST /api/projects/clone
+// Responds with 202 and the clone ID; the result arrives via WebSocket.
+func (h *Handler) HandleProjectClone(w http.ResponseWriter, r *http.Request) {
+	var req CloneProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	req.Branch = strings.TrimSpace(req.Branch)
+	req.Name = strings.TrimSpace(req.Name)
+
+	if err := validateCloneURL(req.URL); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.HasPrefix(req.Branch, "-") {
+		h.writeError(w, http.StatusBadRequest, "Invalid branch name")
+		return
+	}
+	if req.Name == "" {
+		req.Name = repoNameFromURL(req.URL)
+	}
+
+	dest, config, ok := h.reserveProjectDir(w, req.Name)
+	if !ok {
+		return
+	}
+
+	progress := CloneProgress{CloneID: uuid.New().String(), URL: req.URL, Path: dest}
+	started := progress // runClone updates its own copy
+	go h.runClone(req, config, &progress)
+
+	h.writeJSON(w, http.StatusAccepted, started)
+}
+
+// reserveProjectDir checks that a new project named name can be created in the
+// projects base directory and reserves the path until releaseProjectDir.
+// On failure the error response is already written.
+func (h *Handler) reserveProjectDir(w http.ResponseWriter, name string) (string, *Config, bool) {
+	if !projectDirNameRe.MatchString(name) || name == "." || name == ".." {
+		h.writeError(w, http.StatusBadRequest, "Invalid project name (letters, digits, '.', '_' and '-' only)")
+		return "", nil, false
+	}
+
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return "", nil, false
+	}
+	if config.ProjectsBaseDir == "" {
+		h.writeError(w, http.StatusBadRequest, "Projects base directory not configured (Settings)")
+		return "", nil, false
+	}
+	dest, ok := h.allowedPath(w, filepath.Join(config.ProjectsBaseDir, name))
+	if !ok {
+		return "", nil, false
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		h.writeError(w, http.StatusConflict, "Directory already exists: "+dest)
+		return "", nil, false
+	}
+	if existing, _ := h.db.GetProjectByPath(dest); existing != nil {
+		h.writeError(w, http.StatusConflict, "Project already exists for this path")
+		return "", nil, false
+	}
+
+	projectDirsMu.Lock()
+	defer projectDirsMu.Unlock()
+	if projectDirs[dest] {
+		h.writeError(w, http.StatusConflict, "A project is already being created in "+dest)
+		return "", nil, false
+	}
+	projectDirs[dest] = true
+	return dest, config, true
+}
+
+func releaseProjectDir(dest string) {
+	projectDirsMu.Lock()
+	delete(projectDirs, dest)
+	projectDirsMu.Unlock()
+}
+
+// runClone clones the repository, streams its progress and registers the project
+func (h *Handler) runClone(req CloneProjectRequest, config *Config, progress *CloneProgress) {
+	defer releaseProjectDir(progress.Path)
+
+	fail := func(err error) {
+		componentLog("clone").Error("Clone failed", "url", req.URL, "err", err)
+		progress.Done = true
+		progress.Error = err.Error()
+		h.hub.BroadcastCloneProgress(progress)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(progress.Path), 0755); err != nil {
+		fail(fmt.Errorf("failed to create projects directory: %w", err))
+		return
+	}
+
+	if err := h.cloneRepository(req, config.GithubToken, githubEndpointsFor(config, nil).WebURL, progress); err != nil {
+		os.RemoveAll(progress.Path) // Remove a partial checkout
+		fail(err)
+		return
+	}
+
+	project, err := h.db.CreateProject(CreateProjectRequest{
+		Name:        req.Name,
+		Path:        progress.Path,
+		Description: req.Description,
+	}, false)
+	if err != nil {
+		fail(fmt.Errorf("cloned, but failed to create project: %w", err))
+		return
+	}
+	detectNewProjectStack(h.db, project)
+	componentLog("clone").Info("Repository cloned", "url", req.URL, "path", progress.Path)
+
+	h.hub.BroadcastProjectUpdate(project)
+	progress.Phase, progress.Percent, progress.Done, progress.Project = "Done", 100, true, project
+	h.hub.BroadcastCloneProgress(progress)
+}
+
+// cloneRepository runs git clone and broadcasts each change of phase or percentage.
+// The token is only sent to the GitHub instance at webURL.
+func (h *Handler) cloneRepository(req CloneProjectRequest, token, webURL string, progress *CloneProgress) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cloneTimeout)
+	defer cancel()
+
+	args := []string{"clone", "--progress"}
+	if req.Branch != "" {
+		args = append(args, "--branch", req.Branch)
+	}
+	args = append(args, "--", req.URL, progress.Path)
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if token != "" && strings.HasPrefix(req.URL, webURL+"/") {
+		cmd.Env = append(cmd.Env, githubAuthEnv(token, webURL)...)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	progress.Phase = "Cloning"
+	h.hub.BroadcastCloneProgress(progress)
+
+	// git rewrites progress lines with \r; keep the last lines for error messages
+	var lastLines []string
+	var lastBroadcast time.Time
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(scanCloneOutput)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if m := cloneProgressRe.FindStringSubmatch(line); m != nil {
+			// At most a few updates per second, but every new phase and its completion
+			percent, _ := strconv.Atoi(m[2])
+			changed := m[1] != progress.Phase || (percent != progress.Percent && (percent == 100 || time.Since(lastBroadcast) >= cloneProgressInterval))
+			progress.Phase, progress.Percent = m[1], percent
+			if changed {
+				h.hub.BroadcastCloneProgress(progress)
+				lastBroadcast = time.Now()
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "Cloning into") {
+			continue
+		}
+		lastLines = append(lastLines, line)
+		if len(lastLines) > 5 {
+			lastLines = lastLines[1:]
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("git clone timed out after %s", cloneTimeout)
+		}
+		if len(lastLines) > 0 {
+			return fmt.Errorf("git clone failed: %s", strings.Join(lastLines, "\n"))
+		}
+		return fmt.Errorf("git clone failed: %w", err)
+	}
+	return nil
+}
+
+// scanCloneOutput splits git's stderr at \n and \r
+func scanCloneOutput(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// validateCloneURL allows remote https, ssh and scp-like URLs only. Local paths,
+// file:// and git's ext:: transport could read or execute arbitrary things.
+func validateCloneURL(rawURL string) error {
+	if rawURL == "" {
+		return fmt.Errorf("URL is required")
+	}
+	if strings.HasPrefix(rawURL, "-") || strings.ContainsAny(rawURL, " \t\n") {
+		return fmt.Errorf("invalid repository URL")
+	}
+	lower := strings.ToLower(rawURL)
+	for _, prefix := range []string{"https://", "ssh://"} {
+		if strings.HasPrefix(lower, prefix) && len(rawURL) > len(prefix) {
+			return nil
+		}
+	}
+	// scp-like syntax: user@host:owner/repo
+	if at := strings.Index(rawURL, "@"); at > 0 {
+		if colon := strings.Index(rawURL, ":"); colon > at+1 && !strings.Contains(rawURL[:colon], "/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported repository URL (use https://, ssh:// or git@host:owner/repo)")
+}
+
+// repoNameFromURL returns the last path segment of a repository URL without .git
+func repoNameFromURL(rawURL string) string {
+	name := strings.TrimRight(rawURL, "/")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, ".git")
+}
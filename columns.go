@@ -0,0 +1,297 @@
+// columns.go implements configurable board columns.
+// Every task status is a column in board_columns. The six built-in statuses are
+// system columns shared by all boards; custom columns belong to one board (see
+// boards.go) and map onto the runner through their role.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	// errSystemColumn is returned when deleting a system column or changing its role
+	errSystemColumn = errors.New("system columns cannot be deleted and their role is fixed")
+
+	// errColumnExists is returned when creating a column whose status is taken
+	errColumnExists = errors.New("a column with this status already exists")
+
+	// errColumnNotEmpty is returned when deleting a column that still has tasks
+	errColumnNotEmpty = errors.New("column still contains tasks, move them first")
+)
+
+// columnStatusPattern restricts status keys to values that are safe in URLs and CSS selectors
+var columnStatusPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// validColumnRole reports whether role is one of the ColumnRole constants
+func validColumnRole(role string) bool {
+	switch role {
+	case ColumnRoleNone, ColumnRoleQueue, ColumnRoleProgress, ColumnRoleTerminal:
+		return true
+	}
+	return false
+}
+
+// columnRole returns the role of the column for status ("" for unknown statuses)
+func (h *Handler) columnRole(status TaskStatus) string {
+	return statusRole(h.db, status)
+}
+
+// statusRole returns the role of the column for status, for code without a
+// Handler ("" for unknown statuses)
+func statusRole(db Store, status TaskStatus) string {
+	column, err := db.GetBoardColumn(status)
+	if err != nil || column == nil {
+		return ColumnRoleNone
+	}
+	return column.Role
+}
+
+// wipLimitError is returned when moving a task would exceed a column's WIP limit
+type wipLimitError struct {
+	column *BoardColumn
+	count  int
+}
+
+func (e *wipLimitError) Error() string {
+	return fmt.Sprintf("WIP limit reached: column %q already holds %d of %d tasks",
+		e.column.Name, e.count, e.column.WIPLimit)
+}
+
+// checkWIPLimit returns a *wipLimitError if one more task in status would exceed the column's WIP limit
+func (h *Handler) checkWIPLimit(status TaskStatus) error {
+	column, err := h.db.GetBoardColumn(status)
+	if err != nil || column == nil || column.WIPLimit <= 0 {
+		return err
+	}
+	count, err := h.db.CountTasksByStatus(status)
+	if err != nil {
+		return err
+	}
+	if count >= column.WIPLimit {
+		return &wipLimitError{column: column, count: count}
+	}
+	return nil
+}
+
+// writeWIPError answers a failed checkWIPLimit: 409 for a reached limit, 500 otherwise
+func (h *Handler) writeWIPError(w http.ResponseWriter, err error) {
+	var wipErr *wipLimitError
+	if errors.As(err, &wipErr) {
+		h.writeError(w, http.StatusConflict, wipErr.Error())
+		return
+	}
+	h.writeError(w, http.StatusInternalServerError, "Failed to check WIP limit: "+err.Error())
+}
+
+// broadcastColumns sends the current column list to all clients
+func (h *Handler) broadcastColumns() {
+	columns, err := h.db.GetBoardColumns()
+	if err == nil {
+		h.hub.BroadcastColumnsUpdate(columns)
+	}
+}
+
+// HandleBoardColumns handles GET/POST /api/columns and PUT /api/columns (reorder)
+func (h *Handler) HandleBoardColumns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		columns, err := h.db.GetBoardColumns()
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get columns: "+err.Error())
+			return
+		}
+		if columns == nil {
+			columns = []BoardColumn{}
+		}
+		h.writeJSON(w, http.StatusOK, columns)
+
+	case http.MethodPost:
+		var req CreateBoardColumnRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		h.createColumn(w, req)
+
+	case http.MethodPut:
+		var req ReorderBoardColumnsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		h.reorderColumns(w, req, "")
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// createColumn validates and creates a custom column on req.BoardID (default: the default board)
+func (h *Handler) createColumn(w http.ResponseWriter, req CreateBoardColumnRequest) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		h.writeError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if !columnStatusPattern.MatchString(string(req.Status)) {
+		h.writeError(w, http.StatusBadRequest, "Status must start with a letter and contain only a-z, 0-9, - and _ (max. 32)")
+		return
+	}
+	if !validColumnRole(req.Role) {
+		h.writeError(w, http.StatusBadRequest, "Role must be queue, progress, terminal or empty")
+		return
+	}
+	if req.WIPLimit < 0 {
+		h.writeError(w, http.StatusBadRequest, "WIP limit must not be negative")
+		return
+	}
+	if req.SLAHours < 0 {
+		h.writeError(w, http.StatusBadRequest, "SLA hours must not be negative")
+		return
+	}
+	if req.Color == "" {
+		req.Color = "#808080" // Default gray
+	}
+	if req.BoardID != "" && !h.checkBoard(w, req.BoardID) {
+		return
+	}
+
+	column, err := h.db.CreateBoardColumn(req)
+	if errors.Is(err, errColumnExists) {
+		h.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create column: "+err.Error())
+		return
+	}
+
+	h.broadcastColumns()
+	h.writeJSON(w, http.StatusCreated, column)
+}
+
+// reorderColumns sets the order of the columns of a board ("" = all columns)
+func (h *Handler) reorderColumns(w http.ResponseWriter, req ReorderBoardColumnsRequest, boardID string) {
+	// The new order has to name every column exactly once
+	columns, err := h.db.GetBoardColumns()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get columns: "+err.Error())
+		return
+	}
+	if boardID != "" {
+		columns = boardColumns(columns, boardID)
+	}
+	known := make(map[TaskStatus]bool, len(columns))
+	for _, c := range columns {
+		known[c.Status] = true
+	}
+	if len(req.Statuses) != len(columns) {
+		h.writeError(w, http.StatusBadRequest, "Order must contain every column exactly once")
+		return
+	}
+	for _, status := range req.Statuses {
+		if !known[status] {
+			h.writeError(w, http.StatusBadRequest, "Order must contain every column exactly once")
+			return
+		}
+		delete(known, status)
+	}
+
+	if err := h.db.ReorderBoardColumns(req.Statuses); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to reorder columns: "+err.Error())
+		return
+	}
+
+	columns, _ = h.db.GetBoardColumns()
+	h.hub.BroadcastColumnsUpdate(columns)
+	if boardID != "" {
+		columns = boardColumns(columns, boardID)
+	}
+	h.writeJSON(w, http.StatusOK, columns)
+}
+
+// HandleBoardColumn handles GET/PUT/DELETE /api/columns/{status}
+func (h *Handler) HandleBoardColumn(w http.ResponseWriter, r *http.Request) {
+	status := TaskStatus(r.PathValue("status"))
+
+	switch r.Method {
+	case http.MethodGet:
+		column, err := h.db.GetBoardColumn(status)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get column: "+err.Error())
+			return
+		}
+		if column == nil {
+			h.writeError(w, http.StatusNotFound, "Column not found")
+			return
+		}
+		h.writeJSON(w, http.StatusOK, column)
+
+	case http.MethodPut:
+		var req UpdateBoardColumnRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+			h.writeError(w, http.StatusBadRequest, "Name must not be empty")
+			return
+		}
+		if req.Role != nil && !validColumnRole(*req.Role) {
+			h.writeError(w, http.StatusBadRequest, "Role must be queue, progress, terminal or empty")
+			return
+		}
+		if req.WIPLimit != nil && *req.WIPLimit < 0 {
+			h.writeError(w, http.StatusBadRequest, "WIP limit must not be negative")
+			return
+		}
+		if req.SLAHours != nil && *req.SLAHours < 0 {
+			h.writeError(w, http.StatusBadRequest, "SLA hours must not be negative")
+			return
+		}
+
+		column, err := h.db.UpdateBoardColumn(status, req)
+		if errors.Is(err, errSystemColumn) {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to update column: "+err.Error())
+			return
+		}
+		if column == nil {
+			h.writeError(w, http.StatusNotFound, "Column not found")
+			return
+		}
+
+		h.broadcastColumns()
+		h.writeJSON(w, http.StatusOK, column)
+
+	case http.MethodDelete:
+		err := h.db.DeleteBoardColumn(status)
+		if err == sql.ErrNoRows {
+			h.writeError(w, http.StatusNotFound, "Column not found")
+			return
+		}
+		if errors.Is(err, errSystemColumn) || errors.Is(err, errColumnNotEmpty) {
+			h.writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to delete column: "+err.Error())
+			return
+		}
+
+		h.broadcastColumns()
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
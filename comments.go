@@ -0,0 +1,215 @@
+// comments.go implements the discussion thread on tasks.
+// A comment can be handed to RALPH: a running task receives it as feedback,
+// a task in review or blocked is queued with it as continue message.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// errCommentNotSendable is returned when a comment is sent to RALPH for a task that cannot continue
+var errCommentNotSendable = errors.New("task must be running, in review or blocked to send a comment to RALPH")
+
+// sendCommentToRalph hands a comment to RALPH as continuation message
+func (h *Handler) sendCommentToRalph(ctx context.Context, task *Task, body string) error {
+	if h.columnRole(task.Status) == ColumnRoleProgress {
+		config, err := h.db.GetConfig()
+		if err != nil {
+			return err
+		}
+		return h.runner.Continue(ctx, task, config, body)
+	}
+
+	if task.Status != StatusReview && task.Status != StatusBlocked {
+		return errCommentNotSendable
+	}
+	if err := h.checkWIPLimit(StatusQueued); err != nil {
+		return err
+	}
+	if err := h.db.AddToQueueWithMessage(task.ID, body); err != nil {
+		return err
+	}
+
+	if updated, err := h.db.GetTask(task.ID); err == nil && updated != nil {
+		h.hub.BroadcastTaskUpdate(updated)
+	}
+	go h.runner.TryStartNextQueued(ctx)
+	return nil
+}
+
+// writeSendError answers a failed sendCommentToRalph
+func (h *Handler) writeSendError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errCommentNotSendable) {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.writeWIPError(w, err)
+}
+
+// HandleTaskComments handles GET/POST /api/tasks/{id}/comments
+func (h *Handler) HandleTaskComments(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+	task, err := h.db.GetTask(taskID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		comments, err := h.db.GetComments(taskID)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get comments: "+err.Error())
+			return
+		}
+		if comments == nil {
+			comments = []TaskComment{}
+		}
+		h.writeJSON(w, http.StatusOK, comments)
+
+	case http.MethodPost:
+		var req CreateCommentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		req.Body = strings.TrimSpace(req.Body)
+		if req.Body == "" {
+			h.writeError(w, http.StatusBadRequest, "Body is required")
+			return
+		}
+
+		// Send first, so a rejected send doesn't leave a comment behind
+		if req.SendToRalph {
+			if err := h.sendCommentToRalph(r.Context(), task, req.Body); err != nil {
+				h.writeSendError(w, err)
+				return
+			}
+		}
+
+		req.Author = strings.TrimSpace(req.Author)
+		comment, err := h.db.CreateComment(taskID, req)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to create comment: "+err.Error())
+			return
+		}
+		if req.SendToRalph {
+			if sent, err := h.db.MarkCommentSent(comment.ID); err == nil && sent != nil {
+				comment = sent
+			}
+		}
+
+		h.hub.BroadcastComment("comment_created", comment)
+		h.writeJSON(w, http.StatusCreated, comment)
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleTaskComment handles GET/PUT/DELETE /api/tasks/{id}/comments/{commentId}
+// and POST /api/tasks/{id}/comments/{commentId}/send
+func (h *Handler) HandleTaskComment(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+	commentID, action := r.PathValue("commentId"), r.PathValue("action")
+
+	// Verify comment exists and belongs to the task
+	comment, err := h.db.GetComment(commentID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get comment: "+err.Error())
+		return
+	}
+	if comment == nil || comment.TaskID != taskID {
+		h.writeError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	if action == "send" {
+		h.sendComment(w, r, comment)
+		return
+	}
+	if action != "" {
+		h.writeError(w, http.StatusNotFound, "Unknown comment action")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.writeJSON(w, http.StatusOK, comment)
+
+	case http.MethodPut:
+		var req UpdateCommentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if req.Body != nil {
+			body := strings.TrimSpace(*req.Body)
+			if body == "" {
+				h.writeError(w, http.StatusBadRequest, "Body must not be empty")
+				return
+			}
+			req.Body = &body
+		}
+
+		updated, err := h.db.UpdateComment(commentID, req)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to update comment: "+err.Error())
+			return
+		}
+		if updated == nil {
+			h.writeError(w, http.StatusNotFound, "Comment not found")
+			return
+		}
+
+		h.hub.BroadcastComment("comment_updated", updated)
+		h.writeJSON(w, http.StatusOK, updated)
+
+	case http.MethodDelete:
+		if err := h.db.DeleteComment(commentID); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to delete comment: "+err.Error())
+			return
+		}
+
+		h.hub.BroadcastComment("comment_deleted", comment)
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// sendComment hands an existing comment to RALPH (POST .../comments/{commentId}/send)
+func (h *Handler) sendComment(w http.ResponseWriter, r *http.Request, comment *TaskComment) {
+	task, err := h.db.GetTask(comment.TaskID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	if err := h.sendCommentToRalph(r.Context(), task, comment.Body); err != nil {
+		h.writeSendError(w, err)
+		return
+	}
+
+	sent, err := h.db.MarkCommentSent(comment.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to update comment: "+err.Error())
+		return
+	}
+
+	h.hub.BroadcastComment("comment_updated", sent)
+	h.writeJSON(w, http.StatusOK, sent)
+}
@@ -0,0 +1,138 @@
+// commitmsg.go formats the commit messages FORGE writes itself: leftovers of
+// a task before its pull request, deploys, WIP commits of interrupted tasks,
+// pushes and new branches from the board, bootstrap, releases, version bumps
+// and deploy rollbacks. A project's commit_template replaces the plain message with
+// variables ({summary} is what FORGE would have written, {title}, {id} and
+// {type} describe the task, {project} names the project). With
+// conventional_commits the subject line gets a Conventional Commits prefix:
+// the type comes from the task type (Bug is fix, Feature is feat, ...) and
+// the scope from the task's work directory. Commits without a task are
+// chores, and subjects that already have a prefix are left alone. RALPH is
+// asked to format its own commits the same way.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// commitTemplateVarPattern matches a variable of a commit template, e.g. {title}
+var commitTemplateVarPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// conventionalSubjectPattern matches a subject that already has a Conventional Commits prefix
+var conventionalSubjectPattern = regexp.MustCompile(`^[a-z]+(\([^)]*\))?!?: `)
+
+// commitTemplateVars are the variables a commit template may use
+var commitTemplateVars = map[string]bool{
+	"summary": true, "title": true, "id": true, "type": true, "project": true,
+}
+
+// conventionalTypes maps task type names to Conventional Commits types
+var conventionalTypes = map[string]string{
+	"feature": "feat", "feat": "feat", "enhancement": "feat",
+	"bug": "fix", "bugfix": "fix", "fix": "fix", "hotfix": "fix",
+	"refactor": "refactor", "refactoring": "refactor",
+	"test": "test", "tests": "test",
+	"docs": "docs", "documentation": "docs",
+	"performance": "perf", "perf": "perf",
+	"chore": "chore", "maintenance": "chore",
+	"style": "style", "build": "build", "ci": "ci",
+}
+
+// validateCommitTemplate rejects templates with unknown variables or no text
+func validateCommitTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	if strings.TrimSpace(commitTemplateVarPattern.ReplaceAllString(template, "x")) == "" {
+		return fmt.Errorf("commit template is empty")
+	}
+	for _, m := range commitTemplateVarPattern.FindAllStringSubmatch(template, -1) {
+		if !commitTemplateVars[m[1]] {
+			return fmt.Errorf("unknown variable {%s} in commit template (use {summary}, {title}, {id}, {type} or {project})", m[1])
+		}
+	}
+	return nil
+}
+
+// conventionalType returns the Conventional Commits type of a task's commits:
+// from its task type, feat for other tasks and chore without a task
+func conventionalType(task *Task) string {
+	if task == nil {
+		return "chore"
+	}
+	if task.TaskType != nil {
+		if t, ok := conventionalTypes[strings.ToLower(strings.TrimSpace(task.TaskType.Name))]; ok {
+			return t
+		}
+	}
+	return "feat"
+}
+
+// commitMessage formats a commit message FORGE writes in a project, for a
+// task or none. summary is the message without a template. Returns summary
+// for projects without a commit style.
+func commitMessage(project *Project, task *Task, summary string) string {
+	if project == nil || (project.CommitTemplate == "" && !project.ConventionalCommits) {
+		return summary
+	}
+
+	message := summary
+	if project.CommitTemplate != "" {
+		vars := map[string]string{"summary": summary, "title": summary, "project": project.Name}
+		if task != nil {
+			vars["title"] = task.Title
+			vars["id"] = task.ID
+			if task.TaskType != nil {
+				vars["type"] = task.TaskType.Name
+			}
+		}
+		message = commitTemplateVarPattern.ReplaceAllStringFunc(project.CommitTemplate, func(v string) string {
+			return vars[v[1:len(v)-1]]
+		})
+		message = strings.TrimSpace(message)
+		if message == "" {
+			message = summary
+		}
+	}
+
+	if project.ConventionalCommits {
+		subject, body, _ := strings.Cut(message, "\n")
+		if !conventionalSubjectPattern.MatchString(subject) {
+			prefix := conventionalType(task)
+			if task != nil && task.WorkDir != "" {
+				if scope := filepath.Base(filepath.Clean(task.WorkDir)); scope != "." && scope != string(filepath.Separator) {
+					prefix += "(" + scope + ")"
+				}
+			}
+			subject = prefix + ": " + subject
+		}
+		message = subject
+		if body != "" {
+			message += "\n" + body
+		}
+	}
+	return message
+}
+
+// commitStyleExample returns the subject line RALPH's commits for a task
+// should look like, "" if the project has no commit style
+func commitStyleExample(project *Project, task *Task) string {
+	if project.CommitTemplate == "" && !project.ConventionalCommits {
+		return ""
+	}
+	subject, _, _ := strings.Cut(commitMessage(project, task, "<summary of the change>"), "\n")
+	return subject
+}
+
+// taskCommitMessage formats a commit message for a task, with its project
+// looked up, and appends the Forge-Task trailer
+func taskCommitMessage(db Store, task *Task, summary string) string {
+	var project *Project
+	if task.ProjectID != "" {
+		project, _ = db.GetProject(task.ProjectID)
+	}
+	return WithTaskTrailer(commitMessage(project, task, summary), task.ID)
+}
@@ -0,0 +1,223 @@
+// conflicts.go turns merge conflicts with the remote into tracked work. When
+// the branch of a task cannot take the upstream changes, either while the
+// task starts or when its deploy push is rejected, FORGE records the
+// conflicting files, blocks the task and queues a resolution task that merges
+// the upstream on the same branch. Once the resolution task succeeds and the
+// upstream commit is part of the branch, the conflict is resolved and the
+// original task resumes: a task that was starting goes back into the queue, a
+// task that was being deployed goes back to review to be deployed again.
+// Cherry-picks of a task onto another branch (see cherrypick.go) use the
+// same pipeline without blocking the task.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// isResolutionTask reports whether the task resolves an open conflict. It
+// starts on the conflicting branch as it is and merges the upstream itself.
+func (r *RalphRunner) isResolutionTask(taskID string) bool {
+	conflict, _ := r.db.GetOpenConflictByResolutionTask(taskID)
+	return conflict != nil
+}
+
+// openConflict blocks task on conflict and queues a task to resolve it. The
+// caller holds the repository lock, the queue is started afterwards.
+func (r *RalphRunner) openConflict(task *Task, conflict *MergeConflict, stage string) error {
+	logger := componentLog("conflicts").With("task_id", task.ID, "stage", stage)
+
+	files := make([]string, 0, len(conflict.Files))
+	for _, file := range conflict.Files {
+		files = append(files, file.Path)
+	}
+
+	resolution, err := r.queueResolution(task, &TaskConflict{
+		Stage:          stage,
+		Branch:         conflict.WorkingBranch,
+		Upstream:       conflict.TargetBranch,
+		UpstreamCommit: conflict.UpstreamCommit,
+		Files:          files,
+	}, CreateTaskRequest{
+		Title:              "Resolve merge conflict: " + task.Title,
+		Description:        conflictPrompt(task, conflict, files),
+		AcceptanceCriteria: fmt.Sprintf("- %s is merged into %s\n- No conflict markers are left\n- The merge is committed", conflict.TargetBranch, conflict.WorkingBranch),
+	})
+	if err != nil {
+		return err
+	}
+
+	r.db.RemoveFromQueue(task.ID)
+	r.db.UpdateTaskStatus(task.ID, StatusBlocked)
+	r.db.UpdateTaskError(task.ID, fmt.Sprintf("Merge conflict with %s in %s; waiting for task %q", conflict.TargetBranch, strings.Join(files, ", "), resolution.Title))
+	msg := fmt.Sprintf("\n[FORGE] %s conflicts with %s in: %s\n[FORGE] Queued %q to resolve it, this task resumes afterwards\n",
+		conflict.WorkingBranch, conflict.TargetBranch, strings.Join(files, ", "), resolution.Title)
+	r.db.AppendTaskLogs(task.ID, msg)
+	r.hub.BroadcastLog(task.ID, msg)
+	logger.Info("Merge conflict, queued resolution task", "resolution_task_id", resolution.ID, "files", len(files))
+
+	conflict.TaskID = task.ID
+	conflict.ResolutionTaskID = resolution.ID
+	r.hub.BroadcastMergeConflict(conflict)
+	for _, id := range []string{task.ID, resolution.ID} {
+		if updated, _ := r.db.GetTask(id); updated != nil {
+			r.hub.BroadcastTaskUpdate(updated)
+		}
+	}
+	return nil
+}
+
+// queueResolution creates the resolution task described by req for the
+// conflict record of task, records the conflict and queues the task. The
+// resolution task works on the conflict's branch in task's project.
+func (r *RalphRunner) queueResolution(task *Task, record *TaskConflict, req CreateTaskRequest) (*Task, error) {
+	config, err := r.db.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	req.Priority = 1
+	req.ProjectID = task.ProjectID
+	req.ProjectDir = task.ProjectDir
+	req.TargetBranch = record.Branch
+	req.BoardID = task.BoardID
+	resolution, err := r.db.CreateTask(req, config)
+	if err != nil {
+		return nil, err
+	}
+
+	record.TaskID = task.ID
+	record.ResolutionTaskID = resolution.ID
+	if err := r.db.CreateTaskConflict(record); err != nil {
+		return nil, err
+	}
+	if err := r.db.AddToQueue(resolution.ID); err != nil {
+		return nil, err
+	}
+	return resolution, nil
+}
+
+// conflictPrompt is the description of a resolution task
+func conflictPrompt(task *Task, conflict *MergeConflict, files []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The branch `%s` cannot take the latest changes from `%s`: merging them conflicts. ", conflict.WorkingBranch, conflict.TargetBranch)
+	fmt.Fprintf(&sb, "The task %q is blocked until this is resolved.\n\n", task.Title)
+	sb.WriteString("Conflicting files:\n\n")
+	for _, file := range files {
+		fmt.Fprintf(&sb, "- %s\n", file)
+	}
+	fmt.Fprintf(&sb, "\nRun `git merge %s`, resolve the conflicts so that the changes of both sides are kept, ", conflict.TargetBranch)
+	sb.WriteString("make sure the project still builds and commit the merge. Do not push and do not switch branches.")
+	return sb.String()
+}
+
+// checkConflictResolved resumes the task blocked by the conflict that
+// resolutionTaskID resolves, once the upstream commit is merged. Reports
+// whether a conflict was resolved.
+func (r *RalphRunner) checkConflictResolved(resolutionTaskID string) bool {
+	conflict, err := r.db.GetOpenConflictByResolutionTask(resolutionTaskID)
+	if err != nil || conflict == nil {
+		return false
+	}
+	resolution, _ := r.db.GetTask(resolutionTaskID)
+	if resolution == nil {
+		return false
+	}
+	projectDir := resolution.ProjectDir
+	if projectDir == "" && resolution.ProjectID != "" {
+		if project, _ := r.db.GetProject(resolution.ProjectID); project != nil {
+			projectDir = project.Path
+		}
+	}
+	if projectDir == "" || !conflictResolvedIn(projectDir, conflict) {
+		msg := fmt.Sprintf("\n[FORGE] %s is not merged yet, the blocked task stays blocked\n", conflict.Upstream)
+		if conflict.Stage == ConflictStageCherryPick {
+			msg = fmt.Sprintf("\n[FORGE] The cherry-pick onto %s is not finished yet\n", conflict.Branch)
+		}
+		r.db.AppendTaskLogs(resolutionTaskID, msg)
+		r.hub.BroadcastLog(resolutionTaskID, msg)
+		return false
+	}
+
+	if err := r.db.ResolveTaskConflict(conflict.ID); err != nil {
+		componentLog("conflicts").Error("Failed to resolve conflict", "conflict_id", conflict.ID, "err", err)
+		return false
+	}
+	r.db.UpdateTaskStatus(resolutionTaskID, StatusDone)
+	if updated, _ := r.db.GetTask(resolutionTaskID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+
+	task, _ := r.db.GetTask(conflict.TaskID)
+	if task != nil && conflict.Stage == ConflictStageCherryPick {
+		// A cherry-pick never blocked the task
+		msg := fmt.Sprintf("\n[FORGE] Cherry-picked onto %s by %q\n", conflict.Branch, resolution.Title)
+		r.db.AppendTaskLogs(task.ID, msg)
+		r.hub.BroadcastLog(task.ID, msg)
+		return true
+	}
+	if task == nil || task.Status != StatusBlocked {
+		return true
+	}
+	r.db.UpdateTaskError(task.ID, "")
+	var msg string
+	if conflict.Stage == ConflictStagePush {
+		r.db.UpdateTaskStatus(task.ID, StatusReview)
+		msg = fmt.Sprintf("\n[FORGE] Merge conflict resolved by %q, deploy the task again to push\n", resolution.Title)
+	} else {
+		r.db.AddToQueue(task.ID)
+		msg = fmt.Sprintf("\n[FORGE] Merge conflict resolved by %q, task queued again\n", resolution.Title)
+	}
+	r.db.AppendTaskLogs(task.ID, msg)
+	r.hub.BroadcastLog(task.ID, msg)
+	componentLog("conflicts").Info("Merge conflict resolved", "task_id", task.ID, "resolution_task_id", resolutionTaskID, "stage", conflict.Stage)
+	if updated, _ := r.db.GetTask(task.ID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+	return true
+}
+
+// conflictResolvedIn reports whether no merge, rebase or cherry-pick is left
+// half done and HEAD has the conflict's upstream commit: merged for merge
+// conflicts, cherry-picked with -x for cherry-picks
+func conflictResolvedIn(path string, conflict *TaskConflict) bool {
+	if files, err := GetConflictFiles(path); err != nil || len(files) > 0 {
+		return false
+	}
+	if gitOperation(gitRoot(path)) != "" {
+		return false
+	}
+	if conflict.UpstreamCommit == "" {
+		return true
+	}
+	if conflict.Stage == ConflictStageCherryPick {
+		cmd := exec.Command("git", "log", "-1", "--format=%H", "--fixed-strings", "--grep", "cherry picked from commit "+conflict.UpstreamCommit, "HEAD")
+		cmd.Dir = path
+		output, err := cmd.Output()
+		return err == nil && strings.TrimSpace(string(output)) != ""
+	}
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", conflict.UpstreamCommit, "HEAD")
+	cmd.Dir = path
+	return cmd.Run() == nil
+}
+
+// HandleTaskConflicts handles GET /api/tasks/{id}/conflicts
+// Lists the conflicts the task was blocked by or resolves, newest first.
+func (h *Handler) HandleTaskConflicts(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	conflicts, err := h.db.GetTaskConflicts(task.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get conflicts: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, conflicts)
+}
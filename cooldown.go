@@ -0,0 +1,125 @@
+// cooldown.go keeps the queue from running into Claude's rate limits over and
+// over. A rate or usage limit holds up every task, not just the one that hit
+// it, so when a run fails with one (see failures.go) the queue cools down: no
+// queued task starts until the cooldown ends, and the task is queued again to
+// continue then, without using up its retries. The cooldown lasts until the
+// limit resets if the CLI says when, else minRateLimitBackoff, doubled for
+// every further rate limit in a row up to maxRateLimitWait; a run that gets
+// through ends the streak. Running tasks that hit the limit meanwhile wait
+// for the same cooldown. It is stored with the queue state, so it survives a
+// restart, announced as a queue_cooldown message, and the dispatcher resumes
+// by itself when it ends. Resuming the queue ends it early.
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// minRateLimitBackoff is the shortest cooldown after a rate limit
+const minRateLimitBackoff = 5 * time.Minute
+
+// maxRateLimitWait caps the cooldown, also when the limit resets later
+const maxRateLimitWait = 6 * time.Hour
+
+// cooldownNote tells the user why a start was turned into a queue entry
+const cooldownNote = "Claude is rate limited, the task will start when the queue's cooldown ends"
+
+// cooldownDuration returns how long the queue cools down after a rate limit,
+// the streak-th in a row (0-based)
+func cooldownDuration(failure runFailure, streak int, now time.Time) time.Duration {
+	if reset, ok := rateLimitReset(failure.Reason); ok {
+		return min(max(reset.Sub(now), minRateLimitBackoff), maxRateLimitWait)
+	}
+	delay := minRateLimitBackoff
+	for i := 0; i < streak && delay < maxRateLimitWait; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRateLimitWait)
+}
+
+// queueState returns the queue state with the number of queued and running tasks
+func (r *RalphRunner) queueState() (*QueueState, error) {
+	state, err := r.db.GetQueueState()
+	if err != nil {
+		return nil, err
+	}
+	queued, err := r.db.GetQueuedTasks()
+	if err != nil {
+		return nil, err
+	}
+	state.Queued = len(queued)
+	state.Running = r.RunningCount()
+	return state, nil
+}
+
+// startCooldown cools the queue down after a rate-limited run and returns when
+// the cooldown ends. A cooldown that is already running is kept.
+func (r *RalphRunner) startCooldown(failure runFailure) (time.Time, error) {
+	state, err := r.db.GetQueueState()
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now()
+	if state.CooldownUntil != nil && state.CooldownUntil.After(now) {
+		return *state.CooldownUntil, nil
+	}
+
+	until := now.Add(cooldownDuration(failure, state.CooldownLevel, now))
+	if _, err := r.db.SetQueueCooldown(&until, state.CooldownLevel+1, failure.Reason); err != nil {
+		return time.Time{}, err
+	}
+	componentLog("queue").Warn("Claude is rate limited, queue cools down",
+		"until", until, "streak", state.CooldownLevel+1, "reason", failure.Reason)
+	if state, err := r.queueState(); err == nil {
+		r.hub.BroadcastQueueCooldown(state,
+			fmt.Sprintf("Claude is rate limited, the queue resumes at %s", until.Format("15:04")))
+	}
+	r.wakeQueueAt(until)
+	return until, nil
+}
+
+// coolingDown reports whether the queue cools down after a rate limit. A
+// cooldown that has ended is cleared and announced.
+func (r *RalphRunner) coolingDown() bool {
+	state, err := r.db.GetQueueState()
+	if err != nil {
+		componentLog("queue").Warn("Failed to read queue state", "err", err)
+		return false
+	}
+	if state.CooldownUntil == nil {
+		return false
+	}
+	if state.CooldownUntil.After(time.Now()) {
+		// Also after a restart, when no wake-up is pending yet
+		r.wakeQueueAt(*state.CooldownUntil)
+		return true
+	}
+
+	// The streak goes on until a run gets through
+	if _, err := r.db.SetQueueCooldown(nil, state.CooldownLevel, ""); err != nil {
+		componentLog("queue").Warn("Failed to end cooldown", "err", err)
+		return false
+	}
+	componentLog("queue").Info("Cooldown ended, resuming the queue")
+	if state, err := r.queueState(); err == nil {
+		r.hub.BroadcastQueueState(state)
+	}
+	return false
+}
+
+// endRateLimitStreak resets the doubling of the cooldown after a run that
+// got through to Claude
+func (r *RalphRunner) endRateLimitStreak(run *TaskRun) {
+	switch {
+	case run.FailureClass == FailureRateLimit, run.Outcome == RunStopped, run.Outcome == RunInterrupted:
+		return
+	}
+	state, err := r.db.GetQueueState()
+	if err != nil || state.CooldownLevel == 0 {
+		return
+	}
+	if _, err := r.db.SetQueueCooldown(state.CooldownUntil, 0, state.CooldownReason); err != nil {
+		componentLog("queue").Warn("Failed to reset the rate limit streak", "err", err)
+	}
+}
@@ -0,0 +1,174 @@
+// credentials.go protects the tokens in the config (GitHub, Jira, Linear).
+// With FORGE_SECRETS_KEY set they are stored encrypted with the same key as
+// project secrets; the API never returns them, only whether one is set.
+// PUT /api/config/credentials/{name} rotates a token, DELETE clears it.
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// credentialPrefix marks an encrypted credential. Values without it are
+// plaintext, stored before a key was configured.
+const credentialPrefix = "enc:v1:"
+
+// credentialField is one token of the config
+type credentialField struct {
+	name  string // Column name, also the authenticated data of the ciphertext
+	value *string
+	set   *bool
+}
+
+// credentialFields lists the tokens of c
+func (c *Config) credentialFields() []credentialField {
+	return []credentialField{
+		{"github_token", &c.GithubToken, &c.GithubTokenSet},
+		{"jira_token", &c.JiraToken, &c.JiraTokenSet},
+		{"linear_token", &c.LinearToken, &c.LinearTokenSet},
+	}
+}
+
+// credentialNames maps the names used in /api/config/credentials/{name} to columns
+var credentialNames = map[string]string{
+	"github": "github_token",
+	"jira":   "jira_token",
+	"linear": "linear_token",
+}
+
+// sealCredential encrypts a token for storage. Without a key the token is
+// stored as is.
+func sealCredential(name, value string) (string, error) {
+	if value == "" || secretsBox == nil {
+		return value, nil
+	}
+	sealed, err := secretsBox.seal("config:"+name, value)
+	if err != nil {
+		return "", err
+	}
+	return credentialPrefix + sealed, nil
+}
+
+// openCredential decrypts a stored token
+func openCredential(name, stored string) (string, error) {
+	if !strings.HasPrefix(stored, credentialPrefix) {
+		return stored, nil
+	}
+	return secretsBox.open("config:"+name, strings.TrimPrefix(stored, credentialPrefix))
+}
+
+// openCredentials decrypts the tokens of a config read from the database and
+// sets the *Set flags. A token that cannot be decrypted counts as unset, so a
+// lost key only means entering the tokens again.
+func (c *Config) openCredentials() {
+	for _, f := range c.credentialFields() {
+		value, err := openCredential(f.name, *f.value)
+		if err != nil {
+			componentLog("credentials").Warn("Cannot read credential", "name", f.name, "err", err)
+			value = ""
+		}
+		*f.value = value
+		*f.set = value != ""
+	}
+}
+
+// sealCredentials encrypts the tokens set in req into c, which holds the
+// stored (possibly encrypted) values
+func (c *Config) sealCredentials(req UpdateConfigRequest) error {
+	updates := map[string]*string{
+		"github_token": req.GithubToken,
+		"jira_token":   req.JiraToken,
+		"linear_token": req.LinearToken,
+	}
+	for _, f := range c.credentialFields() {
+		if v := updates[f.name]; v != nil {
+			sealed, err := sealCredential(f.name, *v)
+			if err != nil {
+				return err
+			}
+			*f.value = sealed
+		}
+	}
+	return nil
+}
+
+// encryptStoredCredentials re-saves the tokens at startup, which encrypts
+// those stored in plaintext before FORGE_SECRETS_KEY was set
+func encryptStoredCredentials(db Store) {
+	config, err := db.GetConfig()
+	if err != nil {
+		componentLog("credentials").Error("Failed to read config", "err", err)
+		return
+	}
+
+	var req UpdateConfigRequest
+	updates := map[string]**string{
+		"github_token": &req.GithubToken,
+		"jira_token":   &req.JiraToken,
+		"linear_token": &req.LinearToken,
+	}
+	for _, f := range config.credentialFields() {
+		if *f.set {
+			value := *f.value
+			*updates[f.name] = &value
+		}
+	}
+	if req.GithubToken == nil && req.JiraToken == nil && req.LinearToken == nil {
+		return
+	}
+
+	if secretsBox == nil {
+		componentLog("credentials").Warn("FORGE_SECRETS_KEY is not set, tokens are stored unencrypted")
+		return
+	}
+	if _, err := db.UpdateConfig(req); err != nil {
+		componentLog("credentials").Error("Failed to encrypt tokens", "err", err)
+	}
+}
+
+// HandleConfigCredential handles PUT/DELETE /api/config/credentials/{name}
+// name is github, jira or linear. Returns the config, which never contains tokens.
+func (h *Handler) HandleConfigCredential(w http.ResponseWriter, r *http.Request) {
+	column, ok := credentialNames[r.PathValue("name")]
+	if !ok {
+		h.writeError(w, http.StatusNotFound, "Unknown credential (use github, jira or linear)")
+		return
+	}
+
+	var value string
+	switch r.Method {
+	case http.MethodPut:
+		var req CredentialRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		value = strings.TrimSpace(req.Value)
+		if value == "" {
+			h.writeError(w, http.StatusBadRequest, "Value is required (use DELETE to clear)")
+			return
+		}
+	case http.MethodDelete:
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req UpdateConfigRequest
+	switch column {
+	case "github_token":
+		req.GithubToken = &value
+	case "jira_token":
+		req.JiraToken = &value
+	case "linear_token":
+		req.LinearToken = &value
+	}
+
+	config, err := h.db.UpdateConfig(req)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to update config: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, config)
+}
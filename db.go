@@ -4,8 +4,13 @@
 package main
 
 import (
+	"crypto/rand"
 	"database/sql"
-	"log"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,20 +21,23 @@ import (
 // Database kapselt die SQL-Datenbankverbindung mit einem Mutex für Thread-Sicherheit.
 // Lesende Operationen verwenden RLock, schreibende Operationen Lock.
 type Database struct {
-	db *sql.DB
-	mu sync.RWMutex
+	db     *sqlConn
+	mu     sync.RWMutex
+	driver string // DriverSQLite oder DriverPostgres
+	path   string // SQLite-Dateipfad, wird für Backup/Restore benötigt
 }
 
 // NewDatabase erstellt eine neue Datenbankverbindung und initialisiert das Schema.
 // Verwendet WAL-Modus (Write-Ahead-Logging) für bessere Performance bei gleichzeitigen Zugriffen.
 // Der Busy-Timeout von 5 Sekunden verhindert "database is locked" Fehler.
-func NewDatabase(path string) (*Database, error) {
-	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+// Mit migrate=false bleiben ausstehende Migrationen unangewendet (für "forge migrate").
+func NewDatabase(path string, migrate bool) (*Database, error) {
+	db, err := openSQLite(path)
 	if err != nil {
 		return nil, err
 	}
 
-	database := &Database{db: db}
+	database := &Database{db: &sqlConn{DB: db, dialect: DriverSQLite}, driver: DriverSQLite, path: path}
 
 	// Schema initialisieren (erstellt Tabellen falls nicht vorhanden)
 	if err := database.initSchema(); err != nil {
@@ -38,22 +46,61 @@ func NewDatabase(path string) (*Database, error) {
 	}
 
 	// Migrationen ausführen (fügt neue Spalten/Tabellen hinzu)
-	if err := database.runMigrations(); err != nil {
-		db.Close()
-		return nil, err
+	if migrate {
+		if err := database.runMigrations(); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 
 	return database, nil
 }
 
+// openSQLite öffnet eine SQLite-Verbindung mit den Standard-Optionen von FORGE.
+func openSQLite(path string) (*sql.DB, error) {
+	return sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+}
+
 // Close schließt die Datenbankverbindung.
 func (d *Database) Close() error {
 	return d.db.Close()
 }
 
+// Driver gibt den verwendeten Datenbank-Treiber zurück (DriverSQLite oder DriverPostgres).
+func (d *Database) Driver() string {
+	return d.driver
+}
+
+// Path gibt den SQLite-Dateipfad zurück (leer bei PostgreSQL).
+func (d *Database) Path() string {
+	return d.path
+}
+
+// CheckWritable prüft, ob die Datenbank Schreibzugriffe annimmt.
+// Die Schreibsperre wird geholt und die Transaktion wieder verworfen.
+func (d *Database) CheckWritable() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE config SET id = id WHERE id = 1")
+	return err
+}
+
 // initSchema erstellt die initialen Datenbanktabellen.
 // Wird beim Start ausgeführt - existierende Tabellen werden nicht überschrieben.
 func (d *Database) initSchema() error {
+	// PostgreSQL startet direkt mit dem vollständigen Schema (siehe postgres.go)
+	if d.driver == DriverPostgres {
+		_, err := d.db.Exec(postgresSchema)
+		return err
+	}
+
 	schema := `
 	-- Versionstabelle für Schema-Migrationen
 	CREATE TABLE IF NOT EXISTS schema_version (
@@ -94,301 +141,22 @@ func (d *Database) initSchema() error {
 	return err
 }
 
-// runMigrations führt alle ausstehenden Datenbank-Migrationen aus.
+// runMigrations führt alle ausstehenden Datenbank-Migrationen aus (siehe migrations.go).
 // Jede Migration hat eine Versionsnummer - nur höhere Versionen werden ausgeführt.
 func (d *Database) runMigrations() error {
-	// Aktuelle Schema-Version ermitteln
-	var version int
-	err := d.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
-	if err != nil {
-		return err
-	}
-
-	log.Printf("Current schema version: %d", version)
-
-	// ========== Migration 1: Projekte, Task-Typen und Branch-Schutz ==========
-	if version < 1 {
-		log.Println("Running migration 1: Adding projects, task types, and branch protection")
-		migration1 := `
-		-- Projekte: Code-Repositories/Projekte
-		CREATE TABLE IF NOT EXISTS projects (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			path TEXT NOT NULL UNIQUE,
-			description TEXT DEFAULT '',
-			is_auto_detected INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);
-
-		-- Task-Typen: Kategorien für Tasks (Feature, Bug, etc.)
-		CREATE TABLE IF NOT EXISTS task_types (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL UNIQUE,
-			color TEXT NOT NULL,
-			is_system INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);
-
-		-- Branch-Schutzregeln: Definiert geschützte Branches pro Projekt
-		CREATE TABLE IF NOT EXISTS branch_protection_rules (
-			id TEXT PRIMARY KEY,
-			project_id TEXT NOT NULL,
-			branch_pattern TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
-			UNIQUE(project_id, branch_pattern)
-		);
-
-		-- Standard Task-Typen einfügen
-		INSERT OR IGNORE INTO task_types (id, name, color, is_system, created_at) VALUES
-			('type-feature', 'Feature', '#3fb950', 1, CURRENT_TIMESTAMP),
-			('type-bug', 'Bug', '#f85149', 1, CURRENT_TIMESTAMP),
-			('type-refactor', 'Refactor', '#d29922', 1, CURRENT_TIMESTAMP),
-			('type-test', 'Test', '#58a6ff', 1, CURRENT_TIMESTAMP);
-
-		INSERT INTO schema_version (version) VALUES (1);
-		`
-		if _, err := d.db.Exec(migration1); err != nil {
-			return err
-		}
-		log.Println("Migration 1 completed")
-	}
-
-	// ========== Migration 2: Neue Spalten für Tasks und Config ==========
-	if version < 2 {
-		log.Println("Running migration 2: Adding new columns to tasks and config")
-
-		migration2 := `
-		INSERT INTO schema_version (version) VALUES (2);
-		`
-
-		// Spalten einzeln hinzufügen (ignoriert Fehler wenn Spalte bereits existiert)
-		columns := []struct {
-			table  string
-			column string
-			def    string
-		}{
-			{"tasks", "project_id", "TEXT DEFAULT ''"},       // Verknüpfung zu Projekt
-			{"tasks", "task_type_id", "TEXT DEFAULT ''"},     // Verknüpfung zu Task-Typ
-			{"tasks", "working_branch", "TEXT DEFAULT ''"},   // Aktueller Git-Branch
-			{"config", "projects_base_dir", "TEXT DEFAULT ''"}, // Scan-Basis-Verzeichnis
-		}
-
-		for _, col := range columns {
-			query := "ALTER TABLE " + col.table + " ADD COLUMN " + col.column + " " + col.def
-			if _, err := d.db.Exec(query); err != nil {
-				// Fehler ignorieren wenn Spalte bereits existiert
-				log.Printf("Note: Column %s.%s may already exist: %v", col.table, col.column, err)
-			}
-		}
-
-		if _, err := d.db.Exec(migration2); err != nil {
-			return err
-		}
-		log.Println("Migration 2 completed")
-	}
-
-	// ========== Migration 3: GitHub-Token ==========
-	if version < 3 {
-		log.Println("Running migration 3: Adding github_token to config")
-		_, err := d.db.Exec("ALTER TABLE config ADD COLUMN github_token TEXT DEFAULT ''")
-		if err != nil {
-			log.Printf("Note: Column github_token may already exist: %v", err)
-		}
-		_, err = d.db.Exec("INSERT INTO schema_version (version) VALUES (3)")
-		if err != nil {
-			return err
-		}
-		log.Println("Migration 3 completed")
-	}
-
-	// ========== Migration 4: Erweiterte Einstellungen ==========
-	if version < 4 {
-		log.Println("Running migration 4: Adding new settings fields to config")
-
-		newColumns := []struct {
-			name string
-			def  string
-		}{
-			{"auto_commit", "INTEGER DEFAULT 0"},       // Auto-Commit bei Task-Abschluss
-			{"auto_push", "INTEGER DEFAULT 0"},         // Auto-Push nach Commit
-			{"default_branch", "TEXT DEFAULT 'main'"},  // Standard-Branch
-			{"default_priority", "INTEGER DEFAULT 2"},  // Standard-Priorität
-			{"auto_archive_days", "INTEGER DEFAULT 0"}, // Auto-Archivierung (0 = deaktiviert)
-		}
-
-		for _, col := range newColumns {
-			query := "ALTER TABLE config ADD COLUMN " + col.name + " " + col.def
-			if _, err := d.db.Exec(query); err != nil {
-				log.Printf("Note: Column config.%s may already exist: %v", col.name, err)
-			}
-		}
-
-		_, err := d.db.Exec("INSERT INTO schema_version (version) VALUES (4)")
-		if err != nil {
-			return err
-		}
-		log.Println("Migration 4 completed")
-	}
-
-	// ========== Migration 5: Conflict PR tracking for tasks ==========
-	if version < 5 {
-		log.Println("Running migration 5: Adding conflict PR fields to tasks")
-
-		newColumns := []struct {
-			name string
-			def  string
-		}{
-			{"conflict_pr_url", "TEXT DEFAULT ''"},    // GitHub PR URL for conflict resolution
-			{"conflict_pr_number", "INTEGER DEFAULT 0"}, // GitHub PR number
-		}
-
-		for _, col := range newColumns {
-			query := "ALTER TABLE tasks ADD COLUMN " + col.name + " " + col.def
-			if _, err := d.db.Exec(query); err != nil {
-				log.Printf("Note: Column tasks.%s may already exist: %v", col.name, err)
-			}
-		}
-
-		_, err := d.db.Exec("INSERT INTO schema_version (version) VALUES (5)")
-		if err != nil {
-			return err
-		}
-		log.Println("Migration 5 completed")
-	}
-
-	// ========== Migration 6: Attachments table for task screenshots/videos ==========
-	if version < 6 {
-		log.Println("Running migration 6: Creating attachments table")
-		migration6 := `
-		CREATE TABLE IF NOT EXISTS attachments (
-			id TEXT PRIMARY KEY,
-			task_id TEXT NOT NULL,
-			filename TEXT NOT NULL,
-			mime_type TEXT NOT NULL,
-			size INTEGER NOT NULL,
-			path TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_attachments_task_id ON attachments(task_id);
-
-		INSERT INTO schema_version (version) VALUES (6);
-		`
-		if _, err := d.db.Exec(migration6); err != nil {
-			return err
-		}
-		log.Println("Migration 6 completed")
-	}
-
-	// ========== Migration 7: Queue and Process tracking fields ==========
-	if version < 7 {
-		log.Println("Running migration 7: Adding queue and process tracking fields to tasks")
-
-		newColumns := []struct {
-			name string
-			def  string
-		}{
-			{"queue_position", "INTEGER DEFAULT 0"},    // Position in queue (0 = not queued)
-			{"process_pid", "INTEGER DEFAULT 0"},       // PID of running Claude process
-			{"process_status", "TEXT DEFAULT 'idle'"},  // idle, running, finished, error
-			{"started_at", "DATETIME"},                 // When RALPH started
-			{"finished_at", "DATETIME"},                // When RALPH finished
-		}
-
-		for _, col := range newColumns {
-			query := "ALTER TABLE tasks ADD COLUMN " + col.name + " " + col.def
-			if _, err := d.db.Exec(query); err != nil {
-				log.Printf("Note: Column tasks.%s may already exist: %v", col.name, err)
-			}
-		}
-
-		// Create index for efficient queue queries
-		_, err := d.db.Exec(`CREATE INDEX IF NOT EXISTS idx_tasks_queue ON tasks(status, queue_position) WHERE status = 'queued'`)
-		if err != nil {
-			log.Printf("Note: Index idx_tasks_queue may already exist: %v", err)
-		}
-
-		_, err = d.db.Exec("INSERT INTO schema_version (version) VALUES (7)")
-		if err != nil {
-			return err
-		}
-		log.Println("Migration 7 completed")
-	}
-
-	// ========== Migration 8: Trunk-Based Development ==========
-	if version < 8 {
-		log.Println("Running migration 8: Trunk-based development fields")
-
-		// Project: Persistenter Working Branch
-		_, err := d.db.Exec("ALTER TABLE projects ADD COLUMN working_branch TEXT DEFAULT ''")
-		if err != nil {
-			log.Printf("Note: Column projects.working_branch may already exist: %v", err)
-		}
-
-		// Config: Push-Strategie
-		_, err = d.db.Exec("ALTER TABLE config ADD COLUMN push_strategy TEXT DEFAULT 'manual'")
-		if err != nil {
-			log.Printf("Note: Column config.push_strategy may already exist: %v", err)
-		}
-
-		// Task: Rollback-Felder
-		taskColumns := []struct {
-			name string
-			def  string
-		}{
-			{"rollback_tag", "TEXT DEFAULT ''"},
-			{"commit_hash", "TEXT DEFAULT ''"},
-		}
-
-		for _, col := range taskColumns {
-			query := "ALTER TABLE tasks ADD COLUMN " + col.name + " " + col.def
-			if _, err := d.db.Exec(query); err != nil {
-				log.Printf("Note: Column tasks.%s may already exist: %v", col.name, err)
-			}
-		}
-
-		_, err = d.db.Exec("INSERT INTO schema_version (version) VALUES (8)")
-		if err != nil {
-			return err
-		}
-		log.Println("Migration 8 completed")
+	version, err := d.schemaVersion()
+	if err != nil {
+		return err
 	}
+	slog.Info("Current schema version", "version", version)
 
-	// ========== Migration 9: Continue Message for Queue ==========
-	if version < 9 {
-		log.Println("Running migration 9: Adding continue_message field to tasks")
-
-		_, err := d.db.Exec("ALTER TABLE tasks ADD COLUMN continue_message TEXT DEFAULT ''")
-		if err != nil {
-			log.Printf("Note: Column tasks.continue_message may already exist: %v", err)
-		}
-
-		_, err = d.db.Exec("INSERT INTO schema_version (version) VALUES (9)")
-		if err != nil {
-			return err
-		}
-		log.Println("Migration 9 completed")
+	applied, err := d.migrateUp(0, false)
+	if err != nil {
+		return err
 	}
-
-	// ========== Migration 10: Target Branch for Tasks ==========
-	if version < 10 {
-		log.Println("Running migration 10: Adding target_branch field to tasks")
-
-		_, err := d.db.Exec("ALTER TABLE tasks ADD COLUMN target_branch TEXT DEFAULT ''")
-		if err != nil {
-			log.Printf("Note: Column tasks.target_branch may already exist: %v", err)
-		}
-
-		_, err = d.db.Exec("INSERT INTO schema_version (version) VALUES (10)")
-		if err != nil {
-			return err
-		}
-		log.Println("Migration 10 completed")
+	if len(applied) > 0 {
+		slog.Info("Applied migrations", "count", len(applied), "version", applied[len(applied)-1].Version)
 	}
-
 	return nil
 }
 
@@ -409,10 +177,19 @@ func (d *Database) GetAllTasks() ([]Task, error) {
 		       COALESCE(t.project_id, ''), COALESCE(t.task_type_id, ''), COALESCE(t.working_branch, ''),
 		       COALESCE(t.target_branch, ''),
 		       COALESCE(t.conflict_pr_url, ''), COALESCE(t.conflict_pr_number, 0),
+		       COALESCE(t.pr_url, ''), COALESCE(t.pr_number, 0), COALESCE(t.merge_commit, ''),
+		       COALESCE(t.issue_url, ''), COALESCE(t.issue_number, 0),
+		       COALESCE(t.jira_key, ''), COALESCE(t.jira_synced_status, ''),
+		       COALESCE(t.linear_id, ''), COALESCE(t.linear_identifier, ''), COALESCE(t.linear_url, ''),
+		       COALESCE(t.env, ''), COALESCE(t.work_dir, ''),
 		       COALESCE(t.queue_position, 0), COALESCE(t.process_pid, 0), COALESCE(t.process_status, 'idle'),
-		       t.started_at, t.finished_at,
+		       t.started_at, t.finished_at, t.queued_at, t.retry_at,
 		       COALESCE(t.rollback_tag, ''), COALESCE(t.commit_hash, ''),
+		       COALESCE(t.ci_status, ''), COALESCE(t.ci_url, ''), COALESCE(t.ci_fix_attempts, 0),
+		       COALESCE(t.review_comments, 0), t.review_addressed_at,
 		       COALESCE(t.continue_message, ''),
+		       COALESCE(t.plan, ''), COALESCE(t.plan_status, ''), COALESCE(t.parent_id, ''),
+		       COALESCE(t.triage, ''), COALESCE(t.error_class, ''), COALESCE(t.board_id, 'default'),
 		       tt.id, tt.name, tt.color, tt.is_system
 		FROM tasks t
 		LEFT JOIN task_types tt ON t.task_type_id = tt.id
@@ -428,18 +205,27 @@ func (d *Database) GetAllTasks() ([]Task, error) {
 		var t Task
 		var ttID, ttName, ttColor sql.NullString
 		var ttIsSystem sql.NullBool
-		var startedAt, finishedAt sql.NullTime
+		var startedAt, finishedAt, queuedAt, retryAt, reviewAddressedAt sql.NullTime
 		err := rows.Scan(
 			&t.ID, &t.Title, &t.Description, &t.AcceptanceCriteria,
 			&t.Status, &t.Priority, &t.CurrentIteration, &t.MaxIterations,
-			&t.Logs, &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
+			(*storedLogs)(&t.Logs), &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
 			&t.ProjectID, &t.TaskTypeID, &t.WorkingBranch,
 			&t.TargetBranch,
 			&t.ConflictPRURL, &t.ConflictPRNumber,
+			&t.PRURL, &t.PRNumber, &t.MergeCommit,
+			&t.IssueURL, &t.IssueNumber,
+			&t.JiraKey, &t.JiraSyncedStatus,
+			&t.LinearID, &t.LinearIdentifier, &t.LinearURL,
+			&t.Env, &t.WorkDir,
 			&t.QueuePosition, &t.ProcessPID, &t.ProcessStatus,
-			&startedAt, &finishedAt,
+			&startedAt, &finishedAt, &queuedAt, &retryAt,
 			&t.RollbackTag, &t.CommitHash,
+			&t.CIStatus, &t.CIURL, &t.CIFixAttempts,
+			&t.ReviewComments, &reviewAddressedAt,
 			&t.ContinueMessage,
+			&t.Plan, &t.PlanStatus, &t.ParentID,
+			triageColumn{&t.Triage}, &t.ErrorClass, &t.BoardID,
 			&ttID, &ttName, &ttColor, &ttIsSystem,
 		)
 		if err != nil {
@@ -451,6 +237,15 @@ func (d *Database) GetAllTasks() ([]Task, error) {
 		if finishedAt.Valid {
 			t.FinishedAt = &finishedAt.Time
 		}
+		if queuedAt.Valid && t.QueuePosition > 0 {
+			t.QueuedAt = &queuedAt.Time
+		}
+		if retryAt.Valid {
+			t.RetryAt = &retryAt.Time
+		}
+		if reviewAddressedAt.Valid {
+			t.ReviewAddressedAt = &reviewAddressedAt.Time
+		}
 		// Task-Typ hinzufügen falls vorhanden
 		if ttID.Valid && ttID.String != "" {
 			t.TaskType = &TaskType{
@@ -462,8 +257,17 @@ func (d *Database) GetAllTasks() ([]Task, error) {
 		}
 		tasks = append(tasks, t)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return tasks, rows.Err()
+	if err := d.loadTaskLabels(tasks); err != nil {
+		return nil, err
+	}
+	if err := d.loadTaskDependencies(tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
 }
 
 // GetTask gibt einen einzelnen Task anhand seiner ID zurück.
@@ -475,7 +279,7 @@ func (d *Database) GetTask(id string) (*Task, error) {
 	var t Task
 	var ttID, ttName, ttColor sql.NullString
 	var ttIsSystem sql.NullBool
-	var startedAt, finishedAt sql.NullTime
+	var startedAt, finishedAt, queuedAt, retryAt, reviewAddressedAt sql.NullTime
 	err := d.db.QueryRow(`
 		SELECT t.id, t.title, t.description, t.acceptance_criteria, t.status, t.priority,
 		       t.current_iteration, t.max_iterations, t.logs, t.error, t.project_dir,
@@ -483,10 +287,19 @@ func (d *Database) GetTask(id string) (*Task, error) {
 		       COALESCE(t.project_id, ''), COALESCE(t.task_type_id, ''), COALESCE(t.working_branch, ''),
 		       COALESCE(t.target_branch, ''),
 		       COALESCE(t.conflict_pr_url, ''), COALESCE(t.conflict_pr_number, 0),
+		       COALESCE(t.pr_url, ''), COALESCE(t.pr_number, 0), COALESCE(t.merge_commit, ''),
+		       COALESCE(t.issue_url, ''), COALESCE(t.issue_number, 0),
+		       COALESCE(t.jira_key, ''), COALESCE(t.jira_synced_status, ''),
+		       COALESCE(t.linear_id, ''), COALESCE(t.linear_identifier, ''), COALESCE(t.linear_url, ''),
+		       COALESCE(t.env, ''), COALESCE(t.work_dir, ''),
 		       COALESCE(t.queue_position, 0), COALESCE(t.process_pid, 0), COALESCE(t.process_status, 'idle'),
-		       t.started_at, t.finished_at,
+		       t.started_at, t.finished_at, t.queued_at, t.retry_at,
 		       COALESCE(t.rollback_tag, ''), COALESCE(t.commit_hash, ''),
+		       COALESCE(t.ci_status, ''), COALESCE(t.ci_url, ''), COALESCE(t.ci_fix_attempts, 0),
+		       COALESCE(t.review_comments, 0), t.review_addressed_at,
 		       COALESCE(t.continue_message, ''),
+		       COALESCE(t.plan, ''), COALESCE(t.plan_status, ''), COALESCE(t.parent_id, ''),
+		       COALESCE(t.triage, ''), COALESCE(t.error_class, ''), COALESCE(t.board_id, 'default'),
 		       tt.id, tt.name, tt.color, tt.is_system
 		FROM tasks t
 		LEFT JOIN task_types tt ON t.task_type_id = tt.id
@@ -494,14 +307,23 @@ func (d *Database) GetTask(id string) (*Task, error) {
 	`, id).Scan(
 		&t.ID, &t.Title, &t.Description, &t.AcceptanceCriteria,
 		&t.Status, &t.Priority, &t.CurrentIteration, &t.MaxIterations,
-		&t.Logs, &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
+		(*storedLogs)(&t.Logs), &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
 		&t.ProjectID, &t.TaskTypeID, &t.WorkingBranch,
 		&t.TargetBranch,
 		&t.ConflictPRURL, &t.ConflictPRNumber,
+		&t.PRURL, &t.PRNumber, &t.MergeCommit,
+		&t.IssueURL, &t.IssueNumber,
+		&t.JiraKey, &t.JiraSyncedStatus,
+		&t.LinearID, &t.LinearIdentifier, &t.LinearURL,
+		&t.Env, &t.WorkDir,
 		&t.QueuePosition, &t.ProcessPID, &t.ProcessStatus,
-		&startedAt, &finishedAt,
+		&startedAt, &finishedAt, &queuedAt, &retryAt,
 		&t.RollbackTag, &t.CommitHash,
+		&t.CIStatus, &t.CIURL, &t.CIFixAttempts,
+		&t.ReviewComments, &reviewAddressedAt,
 		&t.ContinueMessage,
+		&t.Plan, &t.PlanStatus, &t.ParentID,
+		triageColumn{&t.Triage}, &t.ErrorClass, &t.BoardID,
 		&ttID, &ttName, &ttColor, &ttIsSystem,
 	)
 	if err == sql.ErrNoRows {
@@ -516,6 +338,15 @@ func (d *Database) GetTask(id string) (*Task, error) {
 	if finishedAt.Valid {
 		t.FinishedAt = &finishedAt.Time
 	}
+	if queuedAt.Valid && t.QueuePosition > 0 {
+		t.QueuedAt = &queuedAt.Time
+	}
+	if retryAt.Valid {
+		t.RetryAt = &retryAt.Time
+	}
+	if reviewAddressedAt.Valid {
+		t.ReviewAddressedAt = &reviewAddressedAt.Time
+	}
 	if ttID.Valid && ttID.String != "" {
 		t.TaskType = &TaskType{
 			ID:       ttID.String,
@@ -524,7 +355,15 @@ func (d *Database) GetTask(id string) (*Task, error) {
 			IsSystem: ttIsSystem.Bool,
 		}
 	}
-	return &t, nil
+
+	tasks := []Task{t}
+	if err := d.loadTaskLabels(tasks); err != nil {
+		return nil, err
+	}
+	if err := d.loadTaskDependencies(tasks); err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
 }
 
 // GetTasksByProject gibt alle Tasks für ein bestimmtes Projekt zurück.
@@ -539,10 +378,19 @@ func (d *Database) GetTasksByProject(projectID string) ([]Task, error) {
 		       COALESCE(t.project_id, ''), COALESCE(t.task_type_id, ''), COALESCE(t.working_branch, ''),
 		       COALESCE(t.target_branch, ''),
 		       COALESCE(t.conflict_pr_url, ''), COALESCE(t.conflict_pr_number, 0),
+		       COALESCE(t.pr_url, ''), COALESCE(t.pr_number, 0), COALESCE(t.merge_commit, ''),
+		       COALESCE(t.issue_url, ''), COALESCE(t.issue_number, 0),
+		       COALESCE(t.jira_key, ''), COALESCE(t.jira_synced_status, ''),
+		       COALESCE(t.linear_id, ''), COALESCE(t.linear_identifier, ''), COALESCE(t.linear_url, ''),
+		       COALESCE(t.env, ''), COALESCE(t.work_dir, ''),
 		       COALESCE(t.queue_position, 0), COALESCE(t.process_pid, 0), COALESCE(t.process_status, 'idle'),
-		       t.started_at, t.finished_at,
+		       t.started_at, t.finished_at, t.queued_at, t.retry_at,
 		       COALESCE(t.rollback_tag, ''), COALESCE(t.commit_hash, ''),
+		       COALESCE(t.ci_status, ''), COALESCE(t.ci_url, ''), COALESCE(t.ci_fix_attempts, 0),
+		       COALESCE(t.review_comments, 0), t.review_addressed_at,
 		       COALESCE(t.continue_message, ''),
+		       COALESCE(t.plan, ''), COALESCE(t.plan_status, ''), COALESCE(t.parent_id, ''),
+		       COALESCE(t.triage, ''), COALESCE(t.error_class, ''), COALESCE(t.board_id, 'default'),
 		       tt.id, tt.name, tt.color, tt.is_system
 		FROM tasks t
 		LEFT JOIN task_types tt ON t.task_type_id = tt.id
@@ -559,18 +407,27 @@ func (d *Database) GetTasksByProject(projectID string) ([]Task, error) {
 		var t Task
 		var ttID, ttName, ttColor sql.NullString
 		var ttIsSystem sql.NullBool
-		var startedAt, finishedAt sql.NullTime
+		var startedAt, finishedAt, queuedAt, retryAt, reviewAddressedAt sql.NullTime
 		err := rows.Scan(
 			&t.ID, &t.Title, &t.Description, &t.AcceptanceCriteria,
 			&t.Status, &t.Priority, &t.CurrentIteration, &t.MaxIterations,
-			&t.Logs, &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
+			(*storedLogs)(&t.Logs), &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
 			&t.ProjectID, &t.TaskTypeID, &t.WorkingBranch,
 			&t.TargetBranch,
 			&t.ConflictPRURL, &t.ConflictPRNumber,
+			&t.PRURL, &t.PRNumber, &t.MergeCommit,
+			&t.IssueURL, &t.IssueNumber,
+			&t.JiraKey, &t.JiraSyncedStatus,
+			&t.LinearID, &t.LinearIdentifier, &t.LinearURL,
+			&t.Env, &t.WorkDir,
 			&t.QueuePosition, &t.ProcessPID, &t.ProcessStatus,
-			&startedAt, &finishedAt,
+			&startedAt, &finishedAt, &queuedAt, &retryAt,
 			&t.RollbackTag, &t.CommitHash,
+			&t.CIStatus, &t.CIURL, &t.CIFixAttempts,
+			&t.ReviewComments, &reviewAddressedAt,
 			&t.ContinueMessage,
+			&t.Plan, &t.PlanStatus, &t.ParentID,
+			triageColumn{&t.Triage}, &t.ErrorClass, &t.BoardID,
 			&ttID, &ttName, &ttColor, &ttIsSystem,
 		)
 		if err != nil {
@@ -582,6 +439,15 @@ func (d *Database) GetTasksByProject(projectID string) ([]Task, error) {
 		if finishedAt.Valid {
 			t.FinishedAt = &finishedAt.Time
 		}
+		if queuedAt.Valid && t.QueuePosition > 0 {
+			t.QueuedAt = &queuedAt.Time
+		}
+		if retryAt.Valid {
+			t.RetryAt = &retryAt.Time
+		}
+		if reviewAddressedAt.Valid {
+			t.ReviewAddressedAt = &reviewAddressedAt.Time
+		}
 		if ttID.Valid && ttID.String != "" {
 			t.TaskType = &TaskType{
 				ID:       ttID.String,
@@ -592,8 +458,17 @@ func (d *Database) GetTasksByProject(projectID string) ([]Task, error) {
 		}
 		tasks = append(tasks, t)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return tasks, rows.Err()
+	if err := d.loadTaskLabels(tasks); err != nil {
+		return nil, err
+	}
+	if err := d.loadTaskDependencies(tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
 }
 
 // CreateTask erstellt einen neuen Task.
@@ -615,6 +490,9 @@ func (d *Database) CreateTask(req CreateTaskRequest, config *Config) (*Task, err
 		ProjectID:          req.ProjectID,
 		TaskTypeID:         req.TaskTypeID,
 		TargetBranch:       req.TargetBranch,
+		Env:                req.Env,
+		WorkDir:            req.WorkDir,
+		BoardID:            req.BoardID,
 		CreatedAt:          time.Now(),
 		UpdatedAt:          time.Now(),
 	}
@@ -629,23 +507,49 @@ func (d *Database) CreateTask(req CreateTaskRequest, config *Config) (*Task, err
 	if task.ProjectDir == "" {
 		task.ProjectDir = config.DefaultProjectDir
 	}
+	// Ohne Board landet der Task auf dem Board seines Projekts
+	if task.BoardID == "" && task.ProjectID != "" {
+		err := d.db.QueryRow(`SELECT COALESCE(board_id, '') FROM projects WHERE id = ?`, task.ProjectID).Scan(&task.BoardID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	if task.BoardID == "" {
+		task.BoardID = DefaultBoardID
+	}
+	if err := d.checkLabelIDs(req.LabelIDs); err != nil {
+		return nil, err
+	}
 
 	_, err := d.db.Exec(`
 		INSERT INTO tasks (id, title, description, acceptance_criteria, status,
 		                   priority, current_iteration, max_iterations, logs,
 		                   error, project_dir, project_id, task_type_id, working_branch,
-		                   target_branch, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		                   target_branch, env, work_dir, board_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		task.ID, task.Title, task.Description, task.AcceptanceCriteria,
 		task.Status, task.Priority, task.CurrentIteration, task.MaxIterations,
 		task.Logs, task.Error, task.ProjectDir, task.ProjectID, task.TaskTypeID,
-		task.WorkingBranch, task.TargetBranch, task.CreatedAt, task.UpdatedAt,
+		task.WorkingBranch, task.TargetBranch, task.Env, task.WorkDir, task.BoardID, task.CreatedAt, task.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
+	if err := recordTaskStatus(d.db, task.ID, task.Status, task.CreatedAt); err != nil {
+		return nil, err
+	}
 
+	if len(req.LabelIDs) > 0 {
+		if err := d.setTaskLabels(task.ID, req.LabelIDs); err != nil {
+			return nil, err
+		}
+		tasks := []Task{*task}
+		if err := d.loadTaskLabels(tasks); err != nil {
+			return nil, err
+		}
+		return &tasks[0], nil
+	}
 	return task, nil
 }
 
@@ -663,15 +567,25 @@ func (d *Database) UpdateTask(id string, req UpdateTaskRequest) (*Task, error) {
 		       created_at, updated_at,
 		       COALESCE(project_id, ''), COALESCE(task_type_id, ''), COALESCE(working_branch, ''),
 		       COALESCE(target_branch, ''),
-		       COALESCE(conflict_pr_url, ''), COALESCE(conflict_pr_number, 0)
+		       COALESCE(conflict_pr_url, ''), COALESCE(conflict_pr_number, 0),
+		       COALESCE(pr_url, ''), COALESCE(pr_number, 0),
+		       COALESCE(issue_url, ''), COALESCE(issue_number, 0),
+		       COALESCE(jira_key, ''), COALESCE(jira_synced_status, ''),
+		       COALESCE(linear_id, ''), COALESCE(linear_identifier, ''), COALESCE(linear_url, ''),
+		       COALESCE(env, ''), COALESCE(work_dir, ''), COALESCE(board_id, 'default')
 		FROM tasks WHERE id = ?
 	`, id).Scan(
 		&t.ID, &t.Title, &t.Description, &t.AcceptanceCriteria,
 		&t.Status, &t.Priority, &t.CurrentIteration, &t.MaxIterations,
-		&t.Logs, &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
+		(*storedLogs)(&t.Logs), &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
 		&t.ProjectID, &t.TaskTypeID, &t.WorkingBranch,
 		&t.TargetBranch,
 		&t.ConflictPRURL, &t.ConflictPRNumber,
+		&t.PRURL, &t.PRNumber,
+		&t.IssueURL, &t.IssueNumber,
+		&t.JiraKey, &t.JiraSyncedStatus,
+		&t.LinearID, &t.LinearIdentifier, &t.LinearURL,
+		&t.Env, &t.WorkDir, &t.BoardID,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -714,24 +628,61 @@ func (d *Database) UpdateTask(id string, req UpdateTaskRequest) (*Task, error) {
 	if req.TargetBranch != nil {
 		t.TargetBranch = *req.TargetBranch
 	}
+	if req.Env != nil {
+		t.Env = *req.Env
+	}
+	if req.WorkDir != nil {
+		t.WorkDir = *req.WorkDir
+	}
+	if req.BoardID != nil {
+		t.BoardID = *req.BoardID
+	}
+	if req.LabelIDs != nil {
+		if err := d.checkLabelIDs(*req.LabelIDs); err != nil {
+			return nil, err
+		}
+	}
 	t.UpdatedAt = time.Now()
 
 	_, err = d.db.Exec(`
 		UPDATE tasks SET
 			title = ?, description = ?, acceptance_criteria = ?, status = ?,
 			priority = ?, max_iterations = ?, project_dir = ?,
-			project_id = ?, task_type_id = ?, working_branch = ?, target_branch = ?, updated_at = ?
+			project_id = ?, task_type_id = ?, working_branch = ?, target_branch = ?,
+			env = ?, work_dir = ?, board_id = ?, updated_at = ?
 		WHERE id = ?
 	`,
 		t.Title, t.Description, t.AcceptanceCriteria, t.Status,
 		t.Priority, t.MaxIterations, t.ProjectDir,
-		t.ProjectID, t.TaskTypeID, t.WorkingBranch, t.TargetBranch, t.UpdatedAt, t.ID,
+		t.ProjectID, t.TaskTypeID, t.WorkingBranch, t.TargetBranch,
+		t.Env, t.WorkDir, t.BoardID, t.UpdatedAt, t.ID,
 	)
 	if err != nil {
 		return nil, err
 	}
+	if req.Status != nil {
+		if err := recordTaskStatus(d.db, t.ID, t.Status, t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		// Ein manuell verschobener Task wartet auf keinen erneuten Versuch mehr
+		if _, err := d.db.Exec(`UPDATE tasks SET retry_at = NULL WHERE id = ?`, t.ID); err != nil {
+			return nil, err
+		}
+	}
 
-	return &t, nil
+	if req.LabelIDs != nil {
+		if err := d.setTaskLabels(t.ID, *req.LabelIDs); err != nil {
+			return nil, err
+		}
+	}
+	tasks := []Task{t}
+	if err := d.loadTaskLabels(tasks); err != nil {
+		return nil, err
+	}
+	if err := d.loadTaskDependencies(tasks); err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
 }
 
 // UpdateTaskStatus aktualisiert nur den Status eines Tasks.
@@ -739,10 +690,13 @@ func (d *Database) UpdateTaskStatus(id string, status TaskStatus) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	_, err := d.db.Exec(`
+	now := time.Now()
+	if _, err := d.db.Exec(`
 		UPDATE tasks SET status = ?, updated_at = ? WHERE id = ?
-	`, status, time.Now(), id)
-	return err
+	`, status, now, id); err != nil {
+		return err
+	}
+	return recordTaskStatus(d.db, id, status, now)
 }
 
 // UpdateTaskIteration aktualisiert die aktuelle Iteration eines Tasks.
@@ -768,16 +722,39 @@ func (d *Database) UpdateTaskWorkingBranch(id string, branch string) error {
 }
 
 // UpdateTaskError aktualisiert die Fehlermeldung eines Tasks.
+// Die Triage gehört zum alten Fehler und wird verworfen.
 func (d *Database) UpdateTaskError(id string, errorMsg string) error {
+	return d.UpdateTaskFailure(id, errorMsg, "")
+}
+
+// UpdateTaskFailure aktualisiert Fehlermeldung und Fehlerklasse eines Tasks
+// nach einem gescheiterten Lauf ("" = kein klassifizierter Fehler).
+func (d *Database) UpdateTaskFailure(id string, errorMsg string, class string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	_, err := d.db.Exec(`
-		UPDATE tasks SET error = ?, updated_at = ? WHERE id = ?
-	`, errorMsg, time.Now(), id)
+		UPDATE tasks SET error = ?, error_class = ?, triage = '', updated_at = ? WHERE id = ?
+	`, errorMsg, class, time.Now(), id)
 	return err
 }
 
+// UpdateTaskTriage speichert die Triage eines blockierten Tasks, aber nur,
+// solange er noch mit demselben Fehler blockiert ist.
+func (d *Database) UpdateTaskTriage(id string, triage *TaskTriage) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	res, err := d.db.Exec(`
+		UPDATE tasks SET triage = ?, updated_at = ? WHERE id = ? AND status = ? AND error = ?
+	`, *triage, time.Now(), id, StatusBlocked, triage.Error)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
 // UpdateTaskConflictPR updates the conflict PR info for a task.
 func (d *Database) UpdateTaskConflictPR(id string, prURL string, prNumber int) error {
 	d.mu.Lock()
@@ -789,326 +766,329 @@ func (d *Database) UpdateTaskConflictPR(id string, prURL string, prNumber int) e
 	return err
 }
 
-// AppendTaskLogs fügt Text an die Task-Logs an.
-// Verwendet SQL-String-Konkatenation für Effizienz.
-func (d *Database) AppendTaskLogs(id string, logs string) error {
+// UpdateTaskIssue verknüpft einen Task mit dem GitHub-Issue, aus dem er importiert wurde.
+func (d *Database) UpdateTaskIssue(id string, issueURL string, issueNumber int) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	_, err := d.db.Exec(`
-		UPDATE tasks SET logs = logs || ?, updated_at = ? WHERE id = ?
-	`, logs, time.Now(), id)
+		UPDATE tasks SET issue_url = ?, issue_number = ?, updated_at = ? WHERE id = ?
+	`, issueURL, issueNumber, time.Now(), id)
 	return err
 }
 
-// ResetTaskForProgress setzt einen Task für einen neuen RALPH-Lauf zurück.
-// Löscht Logs, Fehler, Iteration und Working-Branch.
-func (d *Database) ResetTaskForProgress(id string) error {
+// UpdateTaskJira speichert das Jira-Issue eines Tasks und den zuletzt an Jira übertragenen Status.
+func (d *Database) UpdateTaskJira(id string, jiraKey string, syncedStatus TaskStatus) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	_, err := d.db.Exec(`
-		UPDATE tasks SET
-			current_iteration = 0,
-			logs = '',
-			error = '',
-			working_branch = '',
-			updated_at = ?
-		WHERE id = ?
-	`, time.Now(), id)
+		UPDATE tasks SET jira_key = ?, jira_synced_status = ? WHERE id = ?
+	`, jiraKey, syncedStatus, id)
 	return err
 }
 
-// DeleteTask löscht einen Task anhand seiner ID.
-func (d *Database) DeleteTask(id string) error {
+// UpdateTaskLinear verknüpft einen Task mit dem Linear-Issue, aus dem er importiert wurde.
+func (d *Database) UpdateTaskLinear(id string, linearID, identifier, url string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	_, err := d.db.Exec(`DELETE FROM tasks WHERE id = ?`, id)
+	_, err := d.db.Exec(`
+		UPDATE tasks SET linear_id = ?, linear_identifier = ?, linear_url = ?, updated_at = ? WHERE id = ?
+	`, linearID, identifier, url, time.Now(), id)
 	return err
 }
 
-// MarkRunningTasksAsBlocked markiert alle laufenden Tasks als blockiert.
-// Wird bei Server-Neustart aufgerufen, da die RALPH-Prozesse nicht mehr existieren.
-func (d *Database) MarkRunningTasksAsBlocked(reason string) error {
+// UpdateTaskPR speichert den PR des Task-Branches (Branch-per-Task-Workflow).
+func (d *Database) UpdateTaskPR(id string, prURL string, prNumber int) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	_, err := d.db.Exec(`
-		UPDATE tasks SET
-			status = ?,
-			error = ?,
-			updated_at = ?
-		WHERE status = ?
-	`, StatusBlocked, reason, time.Now(), StatusProgress)
+		UPDATE tasks SET pr_url = ?, pr_number = ?, updated_at = ? WHERE id = ?
+	`, prURL, prNumber, time.Now(), id)
 	return err
 }
 
-// ============================================================================
-// Queue and Process Tracking Operations
-// ============================================================================
+// AppendTaskLogs fügt Text an die Task-Logs an.
+// Verwendet SQL-String-Konkatenation für Effizienz.
+// Komprimierte Logs (siehe logretention.go) werden dafür wieder entpackt.
+func (d *Database) AppendTaskLogs(id string, logs string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-// GetQueuedTasks returns all queued tasks ordered by position.
-func (d *Database) GetQueuedTasks() ([]Task, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+	// Der laufende Lauf behält seine Logs, auch wenn die des Tasks neu beginnen
+	if _, err := d.db.Exec(`
+		UPDATE task_runs SET logs = logs || ? WHERE task_id = ? AND finished_at IS NULL
+	`, logs, id); err != nil {
+		return err
+	}
 
-	rows, err := d.db.Query(`
-		SELECT id, title, description, acceptance_criteria, status, priority,
-		       current_iteration, max_iterations, logs, error, project_dir,
-		       created_at, updated_at,
-		       COALESCE(project_id, ''), COALESCE(task_type_id, ''), COALESCE(working_branch, ''),
-		       COALESCE(target_branch, ''),
-		       COALESCE(conflict_pr_url, ''), COALESCE(conflict_pr_number, 0),
-		       COALESCE(queue_position, 0), COALESCE(process_pid, 0), COALESCE(process_status, 'idle'),
-		       started_at, finished_at,
-		       COALESCE(continue_message, '')
-		FROM tasks
-		WHERE status = 'queued' AND queue_position > 0
-		ORDER BY queue_position ASC
-	`)
+	res, err := d.db.Exec(`
+		UPDATE tasks SET logs = logs || ?, updated_at = ? WHERE id = ? AND logs NOT LIKE ?
+	`, logs, time.Now(), id, compressedLogsPrefix+"%")
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
 	}
-	defer rows.Close()
 
-	var tasks []Task
-	for rows.Next() {
-		var t Task
-		var startedAt, finishedAt sql.NullTime
-		err := rows.Scan(
-			&t.ID, &t.Title, &t.Description, &t.AcceptanceCriteria,
-			&t.Status, &t.Priority, &t.CurrentIteration, &t.MaxIterations,
-			&t.Logs, &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
-			&t.ProjectID, &t.TaskTypeID, &t.WorkingBranch,
-			&t.TargetBranch,
-			&t.ConflictPRURL, &t.ConflictPRNumber,
-			&t.QueuePosition, &t.ProcessPID, &t.ProcessStatus,
-			&startedAt, &finishedAt,
-			&t.ContinueMessage,
-		)
-		if err != nil {
-			return nil, err
-		}
-		if startedAt.Valid {
-			t.StartedAt = &startedAt.Time
-		}
-		if finishedAt.Valid {
-			t.FinishedAt = &finishedAt.Time
-		}
-		tasks = append(tasks, t)
+	var stored string
+	err = d.db.QueryRow(`SELECT logs FROM tasks WHERE id = ?`, id).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return nil
 	}
-
-	return tasks, rows.Err()
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`
+		UPDATE tasks SET logs = ?, updated_at = ? WHERE id = ?
+	`, expandLogs(stored)+logs, time.Now(), id)
+	return err
 }
 
-// GetNextQueuedTask returns the task at position 1 in the queue.
-func (d *Database) GetNextQueuedTask() (*Task, error) {
+// GetTaskLogs liefert die (entpackten) Logs eines Tasks.
+func (d *Database) GetTaskLogs(id string) (string, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	var t Task
-	var startedAt, finishedAt sql.NullTime
-	err := d.db.QueryRow(`
-		SELECT id, title, description, acceptance_criteria, status, priority,
-		       current_iteration, max_iterations, logs, error, project_dir,
-		       created_at, updated_at,
-		       COALESCE(project_id, ''), COALESCE(task_type_id, ''), COALESCE(working_branch, ''),
-		       COALESCE(target_branch, ''),
-		       COALESCE(conflict_pr_url, ''), COALESCE(conflict_pr_number, 0),
-		       COALESCE(queue_position, 0), COALESCE(process_pid, 0), COALESCE(process_status, 'idle'),
-		       started_at, finished_at,
-		       COALESCE(continue_message, '')
-		FROM tasks
-		WHERE status = 'queued' AND queue_position > 0
-		ORDER BY queue_position ASC
-		LIMIT 1
-	`).Scan(
-		&t.ID, &t.Title, &t.Description, &t.AcceptanceCriteria,
-		&t.Status, &t.Priority, &t.CurrentIteration, &t.MaxIterations,
-		&t.Logs, &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
-		&t.ProjectID, &t.TaskTypeID, &t.WorkingBranch,
-		&t.TargetBranch,
-		&t.ConflictPRURL, &t.ConflictPRNumber,
-		&t.QueuePosition, &t.ProcessPID, &t.ProcessStatus,
-		&startedAt, &finishedAt,
-		&t.ContinueMessage,
-	)
+	var logs storedLogs
+	err := d.db.QueryRow(`SELECT logs FROM tasks WHERE id = ?`, id).Scan(&logs)
 	if err == sql.ErrNoRows {
-		return nil, nil
+		return "", nil
 	}
-	if err != nil {
+	return string(logs), err
+}
+
+// taskLogSizeQuery liefert Größe, Format und Alter der Logs; bereits gelöschte Logs fehlen
+const taskLogSizeQuery = `
+	SELECT id, status, LENGTH(logs), CASE WHEN logs LIKE ? THEN 1 ELSE 0 END, finished_at, updated_at
+	FROM tasks WHERE logs <> '' AND logs NOT LIKE ?`
+
+// scanTaskLogSize liest eine Zeile von taskLogSizeQuery
+func scanTaskLogSize(row interface{ Scan(...interface{}) error }) (*TaskLogSize, error) {
+	var s TaskLogSize
+	var compressed int
+	var finishedAt sql.NullTime
+	if err := row.Scan(&s.TaskID, &s.Status, &s.Size, &compressed, &finishedAt, &s.LastActivity); err != nil {
 		return nil, err
 	}
-	if startedAt.Valid {
-		t.StartedAt = &startedAt.Time
-	}
+	s.Compressed = compressed == 1
 	if finishedAt.Valid {
-		t.FinishedAt = &finishedAt.Time
+		s.LastActivity = finishedAt.Time
 	}
-	return &t, nil
+	return &s, nil
 }
 
-// HasTaskInProgress checks if any task is currently in progress.
-func (d *Database) HasTaskInProgress() (bool, error) {
+// GetTaskLogSize liefert Größe und Alter der Logs eines Tasks (nil = keine Logs).
+func (d *Database) GetTaskLogSize(id string) (*TaskLogSize, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	var count int
-	err := d.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE status = 'progress'`).Scan(&count)
-	if err != nil {
-		return false, err
+	size, err := scanTaskLogSize(d.db.QueryRow(taskLogSizeQuery+` AND id = ?`,
+		compressedLogsPrefix+"%", purgedLogsPrefix+"%", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	return count > 0, nil
+	return size, err
 }
 
-// GetMaxQueuePosition returns the current maximum queue position.
-func (d *Database) GetMaxQueuePosition() (int, error) {
+// GetTaskLogSizes liefert Größe und Alter der Logs aller Tasks mit Logs.
+func (d *Database) GetTaskLogSizes() ([]TaskLogSize, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	var maxPos sql.NullInt64
-	err := d.db.QueryRow(`SELECT MAX(queue_position) FROM tasks WHERE status = 'queued'`).Scan(&maxPos)
+	rows, err := d.db.Query(taskLogSizeQuery, compressedLogsPrefix+"%", purgedLogsPrefix+"%")
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	if !maxPos.Valid {
-		return 0, nil
+	defer rows.Close()
+
+	var sizes []TaskLogSize
+	for rows.Next() {
+		size, err := scanTaskLogSize(rows)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, *size)
 	}
-	return int(maxPos.Int64), nil
+	return sizes, rows.Err()
 }
 
-// AddToQueue adds a task to the queue with the next position.
-func (d *Database) AddToQueue(taskID string) error {
+// ReplaceTaskLogs ersetzt die gespeicherten Logs, sofern sie noch oldSize lang sind,
+// ohne updated_at zu ändern. false = der Task hat inzwischen neue Logs.
+func (d *Database) ReplaceTaskLogs(id string, oldSize int64, logs string) (bool, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Get the next position
-	var maxPos sql.NullInt64
-	err := d.db.QueryRow(`SELECT MAX(queue_position) FROM tasks WHERE status = 'queued'`).Scan(&maxPos)
+	res, err := d.db.Exec(`
+		UPDATE tasks SET logs = ? WHERE id = ? AND LENGTH(logs) = ?
+	`, logs, id, oldSize)
 	if err != nil {
-		return err
-	}
-	nextPos := 1
-	if maxPos.Valid {
-		nextPos = int(maxPos.Int64) + 1
+		return false, err
 	}
-
-	_, err = d.db.Exec(`
-		UPDATE tasks SET queue_position = ?, status = 'queued', updated_at = ? WHERE id = ?
-	`, nextPos, time.Now(), taskID)
-	return err
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
-// AddToQueueWithMessage adds a task to the queue with a continue message.
-func (d *Database) AddToQueueWithMessage(taskID string, message string) error {
+// ResetTaskForProgress setzt einen Task für einen neuen RALPH-Lauf zurück.
+// Löscht Logs, Fehler, Triage, Iteration und Working-Branch.
+func (d *Database) ResetTaskForProgress(id string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Get the next position
-	var maxPos sql.NullInt64
-	err := d.db.QueryRow(`SELECT MAX(queue_position) FROM tasks WHERE status = 'queued'`).Scan(&maxPos)
-	if err != nil {
-		return err
-	}
-	nextPos := 1
-	if maxPos.Valid {
-		nextPos = int(maxPos.Int64) + 1
-	}
-
-	_, err = d.db.Exec(`
-		UPDATE tasks SET queue_position = ?, status = 'queued', continue_message = ?, error = '', updated_at = ? WHERE id = ?
-	`, nextPos, message, time.Now(), taskID)
+	_, err := d.db.Exec(`
+		UPDATE tasks SET
+			current_iteration = 0,
+			logs = '',
+			error = '',
+			error_class = '',
+			triage = '',
+			working_branch = '',
+			updated_at = ?
+		WHERE id = ?
+	`, time.Now(), id)
 	return err
 }
 
-// ClearContinueMessage clears the continue message for a task.
-func (d *Database) ClearContinueMessage(taskID string) error {
+// DeleteTask löscht einen Task anhand seiner ID.
+func (d *Database) DeleteTask(id string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	_, err := d.db.Exec(`UPDATE tasks SET continue_message = '', updated_at = ? WHERE id = ?`, time.Now(), taskID)
+	if _, err := d.db.Exec(`DELETE FROM task_labels WHERE task_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := d.db.Exec(`DELETE FROM task_comments WHERE task_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := d.db.Exec(`DELETE FROM task_commits WHERE task_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := d.db.Exec(`DELETE FROM task_conflicts WHERE task_id = ? OR resolution_task_id = ?`, id, id); err != nil {
+		return err
+	}
+	if _, err := d.db.Exec(`DELETE FROM task_dependencies WHERE task_id = ? OR depends_on_id = ?`, id, id); err != nil {
+		return err
+	}
+	if _, err := d.db.Exec(`DELETE FROM task_events WHERE task_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := d.db.Exec(`DELETE FROM task_runs WHERE task_id = ?`, id); err != nil {
+		return err
+	}
+	// Teil-Tasks eines gelöschten Epics bleiben als eigenständige Tasks erhalten
+	if _, err := d.db.Exec(`UPDATE tasks SET parent_id = '' WHERE parent_id = ?`, id); err != nil {
+		return err
+	}
+	_, err := d.db.Exec(`DELETE FROM tasks WHERE id = ?`, id)
 	return err
 }
 
-// RemoveFromQueue removes a task from the queue and reorders remaining tasks.
-func (d *Database) RemoveFromQueue(taskID string) error {
+// MarkRunningTasksAsBlocked markiert alle laufenden Tasks als blockiert.
+// Wird bei Server-Neustart aufgerufen, da die RALPH-Prozesse nicht mehr existieren.
+func (d *Database) MarkRunningTasksAsBlocked(reason string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Get current position
-	var currentPos int
-	err := d.db.QueryRow(`SELECT COALESCE(queue_position, 0) FROM tasks WHERE id = ?`, taskID).Scan(&currentPos)
+	rows, err := d.db.Query(`SELECT id FROM tasks WHERE status = ?`, StatusProgress)
 	if err != nil {
 		return err
 	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
 
-	// Clear the task's queue position
-	_, err = d.db.Exec(`UPDATE tasks SET queue_position = 0, updated_at = ? WHERE id = ?`, time.Now(), taskID)
-	if err != nil {
+	now := time.Now()
+	if _, err := d.db.Exec(`
+		UPDATE tasks SET
+			status = ?,
+			error = ?,
+			error_class = '',
+			updated_at = ?
+		WHERE status = ?
+	`, StatusBlocked, reason, now, StatusProgress); err != nil {
 		return err
 	}
-
-	// Reorder remaining tasks if this task had a position
-	if currentPos > 0 {
-		_, err = d.db.Exec(`
-			UPDATE tasks SET queue_position = queue_position - 1, updated_at = ?
-			WHERE status = 'queued' AND queue_position > ?
-		`, time.Now(), currentPos)
+	for _, id := range ids {
+		if err := recordTaskStatus(d.db, id, StatusBlocked, now); err != nil {
+			return err
+		}
 	}
-	return err
+	return nil
 }
 
-// UpdateTaskProcessInfo updates the PID and process status of a task.
-func (d *Database) UpdateTaskProcessInfo(id string, pid int, status string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	_, err := d.db.Exec(`
-		UPDATE tasks SET process_pid = ?, process_status = ?, updated_at = ? WHERE id = ?
-	`, pid, status, time.Now(), id)
-	return err
-}
+// ============================================================================
+// Queue and Process Tracking Operations
+// ============================================================================
 
-// UpdateTaskStartedAt sets the started_at timestamp for a task.
-func (d *Database) UpdateTaskStartedAt(id string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// GetQueuedTasks returns all queued tasks ordered by position. Every project
+// has its own positions; of equal positions the task queued first comes first.
+func (d *Database) GetQueuedTasks() ([]Task, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	_, err := d.db.Exec(`
-		UPDATE tasks SET started_at = ?, updated_at = ? WHERE id = ?
-	`, time.Now(), time.Now(), id)
-	return err
+	return d.queryQueuedTasks(`ORDER BY queue_position ASC, queued_at ASC`)
 }
 
-// UpdateTaskFinishedAt sets the finished_at timestamp for a task.
-func (d *Database) UpdateTaskFinishedAt(id string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// GetDispatchQueue returns the queued tasks in the order the dispatcher
+// considers them: the tasks of boards with the priority policy by effective
+// priority (see effectivePriority in queue.go), raised by the priority aging of
+// their board for the time waited until now, then like GetQueuedTasks.
+func (d *Database) GetDispatchQueue(now time.Time) ([]Task, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	_, err := d.db.Exec(`
-		UPDATE tasks SET finished_at = ?, updated_at = ? WHERE id = ?
-	`, time.Now(), time.Now(), id)
-	return err
+	return d.queryQueuedTasks(`ORDER BY CASE WHEN queue_board.policy = 'priority' THEN `+d.effectivePrioritySQL()+` ELSE 0 END ASC,
+		queue_position ASC, queued_at ASC`, now)
 }
 
-// GetTasksWithRunningProcess returns tasks that have a non-zero PID.
-func (d *Database) GetTasksWithRunningProcess() ([]Task, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+// effectivePrioritySQL is the effective priority of a queued task in SQL, for
+// queryQueuedTasks. Its only parameter is the time waited until.
+func (d *Database) effectivePrioritySQL() string {
+	if d.driver == DriverPostgres {
+		return `CASE WHEN queue_board.aging_hours > 0 AND tasks.queued_at IS NOT NULL
+			THEN GREATEST(tasks.priority - FLOOR(EXTRACT(EPOCH FROM (CAST(? AS TIMESTAMPTZ) - tasks.queued_at)) / 3600 / queue_board.aging_hours), 1)
+			ELSE tasks.priority END`
+	}
+	return `CASE WHEN queue_board.aging_hours > 0 AND tasks.queued_at IS NOT NULL
+		THEN MAX(tasks.priority - CAST((julianday(?) - julianday(tasks.queued_at)) * 24 / queue_board.aging_hours AS INTEGER), 1)
+		ELSE tasks.priority END`
+}
 
+// queryQueuedTasks returns the queued tasks in the given order. The order may
+// use the queue policy and priority aging of the task's board as
+// queue_board.policy and queue_board.aging_hours.
+func (d *Database) queryQueuedTasks(order string, args ...interface{}) ([]Task, error) {
 	rows, err := d.db.Query(`
 		SELECT id, title, description, acceptance_criteria, status, priority,
 		       current_iteration, max_iterations, logs, error, project_dir,
 		       created_at, updated_at,
 		       COALESCE(project_id, ''), COALESCE(task_type_id, ''), COALESCE(working_branch, ''),
+		       COALESCE(target_branch, ''),
 		       COALESCE(conflict_pr_url, ''), COALESCE(conflict_pr_number, 0),
+		       COALESCE(pr_url, ''), COALESCE(pr_number, 0),
+		       COALESCE(issue_url, ''), COALESCE(issue_number, 0),
+		       COALESCE(jira_key, ''), COALESCE(jira_synced_status, ''),
+		       COALESCE(linear_id, ''), COALESCE(linear_identifier, ''), COALESCE(linear_url, ''),
+		       COALESCE(env, ''), COALESCE(work_dir, ''),
 		       COALESCE(queue_position, 0), COALESCE(process_pid, 0), COALESCE(process_status, 'idle'),
-		       started_at, finished_at,
-		       COALESCE(continue_message, '')
+		       started_at, finished_at, queued_at, retry_at,
+		       COALESCE(continue_message, ''), COALESCE(board_id, 'default')
 		FROM tasks
-		WHERE process_pid > 0
-	`)
+		LEFT JOIN (SELECT id AS queue_board_id, COALESCE(queue_policy, 'fifo') AS policy,
+		                  COALESCE(priority_aging_hours, 0) AS aging_hours FROM boards) queue_board
+		       ON queue_board.queue_board_id = COALESCE(tasks.board_id, 'default')
+		WHERE `+queueStatusFilter+` AND queue_position > 0
+		`+order, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -1117,16 +1097,22 @@ func (d *Database) GetTasksWithRunningProcess() ([]Task, error) {
 	var tasks []Task
 	for rows.Next() {
 		var t Task
-		var startedAt, finishedAt sql.NullTime
+		var startedAt, finishedAt, queuedAt, retryAt sql.NullTime
 		err := rows.Scan(
 			&t.ID, &t.Title, &t.Description, &t.AcceptanceCriteria,
 			&t.Status, &t.Priority, &t.CurrentIteration, &t.MaxIterations,
-			&t.Logs, &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
+			(*storedLogs)(&t.Logs), &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
 			&t.ProjectID, &t.TaskTypeID, &t.WorkingBranch,
+			&t.TargetBranch,
 			&t.ConflictPRURL, &t.ConflictPRNumber,
+			&t.PRURL, &t.PRNumber,
+			&t.IssueURL, &t.IssueNumber,
+			&t.JiraKey, &t.JiraSyncedStatus,
+			&t.LinearID, &t.LinearIdentifier, &t.LinearURL,
+			&t.Env, &t.WorkDir,
 			&t.QueuePosition, &t.ProcessPID, &t.ProcessStatus,
-			&startedAt, &finishedAt,
-			&t.ContinueMessage,
+			&startedAt, &finishedAt, &queuedAt, &retryAt,
+			&t.ContinueMessage, &t.BoardID,
 		)
 		if err != nil {
 			return nil, err
@@ -1137,689 +1123,3866 @@ func (d *Database) GetTasksWithRunningProcess() ([]Task, error) {
 		if finishedAt.Valid {
 			t.FinishedAt = &finishedAt.Time
 		}
+		if queuedAt.Valid && t.QueuePosition > 0 {
+			t.QueuedAt = &queuedAt.Time
+		}
+		if retryAt.Valid {
+			t.RetryAt = &retryAt.Time
+		}
 		tasks = append(tasks, t)
 	}
 
 	return tasks, rows.Err()
 }
 
-// ============================================================================
-// Projekt CRUD-Operationen
-// ============================================================================
-
-// GetAllProjects gibt alle Projekte zurück, sortiert nach Name.
-// Ergänzt Git-Informationen (Branch, IsGitRepo) zur Laufzeit.
-func (d *Database) GetAllProjects() ([]Project, error) {
+// GetRunCosts returns the cost of the runs finished since then, by project
+// ("" for tasks without a project)
+func (d *Database) GetRunCosts(since time.Time) (map[string]float64, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
 	rows, err := d.db.Query(`
-		SELECT p.id, p.name, p.path, p.description, p.is_auto_detected, p.created_at, p.updated_at,
-		       COALESCE(p.working_branch, ''),
-		       (SELECT COUNT(*) FROM tasks WHERE project_id = p.id) as task_count
-		FROM projects p
-		ORDER BY p.name ASC
-	`)
+		SELECT COALESCE(t.project_id, ''), COALESCE(SUM(r.cost_usd), 0)
+		FROM task_runs r JOIN tasks t ON t.id = r.task_id
+		WHERE r.finished_at IS NOT NULL AND r.finished_at >= ?
+		GROUP BY COALESCE(t.project_id, '')
+	`, since)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var projects []Project
+	costs := make(map[string]float64)
 	for rows.Next() {
-		var p Project
-		err := rows.Scan(
-			&p.ID, &p.Name, &p.Path, &p.Description, &p.IsAutoDetected,
-			&p.CreatedAt, &p.UpdatedAt, &p.WorkingBranch, &p.TaskCount,
-		)
-		if err != nil {
+		var projectID string
+		var cost float64
+		if err := rows.Scan(&projectID, &cost); err != nil {
 			return nil, err
 		}
-		// Git-Informationen zur Laufzeit ermitteln
-		p.IsGitRepo = IsGitRepository(p.Path)
-		if p.IsGitRepo {
-			if branch, err := GetCurrentBranch(p.Path); err == nil {
-				p.CurrentBranch = branch
-			}
-		}
-		projects = append(projects, p)
+		costs[projectID] = cost
 	}
-
-	return projects, rows.Err()
+	return costs, rows.Err()
 }
 
-// GetProject gibt ein einzelnes Projekt anhand seiner ID zurück.
-func (d *Database) GetProject(id string) (*Project, error) {
+// recentRunLimit caps how many finished runs GetRunDurations reads
+const recentRunLimit = 500
+
+// GetRunDurations returns the durations of the most recently finished runs;
+// stopped and interrupted runs say nothing about how long a run takes
+func (d *Database) GetRunDurations() ([]RunDuration, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	var p Project
-	err := d.db.QueryRow(`
-		SELECT p.id, p.name, p.path, p.description, p.is_auto_detected, p.created_at, p.updated_at,
-		       COALESCE(p.working_branch, ''),
-		       (SELECT COUNT(*) FROM tasks WHERE project_id = p.id) as task_count
-		FROM projects p
-		WHERE p.id = ?
-	`, id).Scan(
-		&p.ID, &p.Name, &p.Path, &p.Description, &p.IsAutoDetected,
-		&p.CreatedAt, &p.UpdatedAt, &p.WorkingBranch, &p.TaskCount,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+	rows, err := d.db.Query(`
+		SELECT COALESCE(t.project_id, ''), COALESCE(t.task_type_id, ''), r.started_at, r.finished_at
+		FROM task_runs r JOIN tasks t ON t.id = r.task_id
+		WHERE r.finished_at IS NOT NULL AND COALESCE(r.outcome, '') NOT IN (?, ?)
+		ORDER BY r.finished_at DESC
+		LIMIT ?
+	`, RunStopped, RunInterrupted, recentRunLimit)
 	if err != nil {
 		return nil, err
 	}
-	// Git-Informationen zur Laufzeit ermitteln
-	p.IsGitRepo = IsGitRepository(p.Path)
-	if p.IsGitRepo {
-		if branch, err := GetCurrentBranch(p.Path); err == nil {
-			p.CurrentBranch = branch
+	defer rows.Close()
+
+	var runs []RunDuration
+	for rows.Next() {
+		var run RunDuration
+		var startedAt, finishedAt time.Time
+		if err := rows.Scan(&run.ProjectID, &run.TaskTypeID, &startedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+		if run.Duration = finishedAt.Sub(startedAt); run.Duration > 0 {
+			runs = append(runs, run)
 		}
 	}
-	return &p, nil
+	return runs, rows.Err()
 }
 
-// GetProjectByPath gibt ein Projekt anhand seines Pfads zurück.
-// Wird verwendet um Duplikate beim Projekt-Scan zu vermeiden.
-func (d *Database) GetProjectByPath(path string) (*Project, error) {
+// GetNextQueuedTask returns the task queued first among those at position 1 of their queue lane.
+func (d *Database) GetNextQueuedTask() (*Task, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	var p Project
+	var t Task
+	var startedAt, finishedAt, queuedAt, retryAt sql.NullTime
 	err := d.db.QueryRow(`
-		SELECT id, name, path, description, is_auto_detected, created_at, updated_at
-		FROM projects WHERE path = ?
-	`, path).Scan(
-		&p.ID, &p.Name, &p.Path, &p.Description, &p.IsAutoDetected,
-		&p.CreatedAt, &p.UpdatedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
+		SELECT id, title, description, acceptance_criteria, status, priority,
+		       current_iteration, max_iterations, logs, error, project_dir,
+		       created_at, updated_at,
+		       COALESCE(project_id, ''), COALESCE(task_type_id, ''), COALESCE(working_branch, ''),
+		       COALESCE(target_branch, ''),
+		       COALESCE(conflict_pr_url, ''), COALESCE(conflict_pr_number, 0),
+		       COALESCE(pr_url, ''), COALESCE(pr_number, 0),
+		       COALESCE(issue_url, ''), COALESCE(issue_number, 0),
+		       COALESCE(jira_key, ''), COALESCE(jira_synced_status, ''),
+		       COALESCE(linear_id, ''), COALESCE(linear_identifier, ''), COALESCE(linear_url, ''),
+		       COALESCE(env, ''), COALESCE(work_dir, ''),
+		       COALESCE(queue_position, 0), COALESCE(process_pid, 0), COALESCE(process_status, 'idle'),
+		       started_at, finished_at, queued_at, retry_at,
+		       COALESCE(continue_message, ''), COALESCE(board_id, 'default')
+		FROM tasks
+		WHERE ` + queueStatusFilter + ` AND queue_position > 0
+		ORDER BY queue_position ASC, queued_at ASC
+		LIMIT 1
+	`).Scan(
+		&t.ID, &t.Title, &t.Description, &t.AcceptanceCriteria,
+		&t.Status, &t.Priority, &t.CurrentIteration, &t.MaxIterations,
+		(*storedLogs)(&t.Logs), &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
+		&t.ProjectID, &t.TaskTypeID, &t.WorkingBranch,
+		&t.TargetBranch,
+		&t.ConflictPRURL, &t.ConflictPRNumber,
+		&t.PRURL, &t.PRNumber,
+		&t.IssueURL, &t.IssueNumber,
+		&t.JiraKey, &t.JiraSyncedStatus,
+		&t.LinearID, &t.LinearIdentifier, &t.LinearURL,
+		&t.Env, &t.WorkDir,
+		&t.QueuePosition, &t.ProcessPID, &t.ProcessStatus,
+		&startedAt, &finishedAt, &queuedAt, &retryAt,
+		&t.ContinueMessage, &t.BoardID,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &p, nil
+	if startedAt.Valid {
+		t.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		t.FinishedAt = &finishedAt.Time
+	}
+	if queuedAt.Valid && t.QueuePosition > 0 {
+		t.QueuedAt = &queuedAt.Time
+	}
+	if retryAt.Valid {
+		t.RetryAt = &retryAt.Time
+	}
+	return &t, nil
 }
 
-// CreateProject erstellt ein neues Projekt.
-// isAutoDetected gibt an, ob das Projekt durch Scan gefunden wurde.
-func (d *Database) CreateProject(req CreateProjectRequest, isAutoDetected bool) (*Project, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// GetMaxQueuePosition returns the current maximum queue position.
+func (d *Database) GetMaxQueuePosition() (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	project := &Project{
-		ID:             uuid.New().String(),
-		Name:           req.Name,
-		Path:           req.Path,
-		Description:    req.Description,
-		IsAutoDetected: isAutoDetected,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+	var maxPos sql.NullInt64
+	err := d.db.QueryRow(`SELECT MAX(queue_position) FROM tasks WHERE ` + queueStatusFilter).Scan(&maxPos)
+	if err != nil {
+		return 0, err
+	}
+	if !maxPos.Valid {
+		return 0, nil
 	}
+	return int(maxPos.Int64), nil
+}
 
-	_, err := d.db.Exec(`
-		INSERT INTO projects (id, name, path, description, is_auto_detected, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`,
-		project.ID, project.Name, project.Path, project.Description,
-		project.IsAutoDetected, project.CreatedAt, project.UpdatedAt,
-	)
+// queueLaneFilter restricts a query to the queue lane of the task given as
+// argument: every project has its own queue, tasks without a project share one.
+const queueLaneFilter = "COALESCE(project_id, '') = (SELECT COALESCE(project_id, '') FROM tasks WHERE id = ?)"
+
+// nextQueuePosition returns the position after the last task in the queue
+// lane of taskID. The caller holds d.mu.
+func (d *Database) nextQueuePosition(taskID string) (int, error) {
+	var maxPos sql.NullInt64
+	err := d.db.QueryRow(`SELECT MAX(queue_position) FROM tasks WHERE `+queueStatusFilter+` AND `+queueLaneFilter, taskID).Scan(&maxPos)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-
-	// Git-Informationen zur Laufzeit ermitteln
-	project.IsGitRepo = IsGitRepository(project.Path)
-	if project.IsGitRepo {
-		if branch, err := GetCurrentBranch(project.Path); err == nil {
-			project.CurrentBranch = branch
-		}
+	if !maxPos.Valid {
+		return 1, nil
 	}
+	return int(maxPos.Int64) + 1, nil
+}
 
-	return project, nil
+// AddToQueue adds a task to the queue with the next position.
+func (d *Database) AddToQueue(taskID string) error {
+	return d.AddToQueueWithStatus(taskID, StatusQueued)
 }
 
-// UpdateProject aktualisiert ein bestehendes Projekt.
-func (d *Database) UpdateProject(id string, req UpdateProjectRequest) (*Project, error) {
+// AddToQueueWithStatus adds a task to the end of its project's queue lane and
+// moves it into the given queue column. All queue columns share one queue order.
+func (d *Database) AddToQueueWithStatus(taskID string, status TaskStatus) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	var p Project
-	err := d.db.QueryRow(`
-		SELECT id, name, path, description, is_auto_detected, created_at, updated_at
-		FROM projects WHERE id = ?
-	`, id).Scan(
-		&p.ID, &p.Name, &p.Path, &p.Description, &p.IsAutoDetected,
-		&p.CreatedAt, &p.UpdatedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+	nextPos, err := d.nextQueuePosition(taskID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// Updates anwenden
-	if req.Name != nil {
-		p.Name = *req.Name
+	now := time.Now()
+	if _, err := d.db.Exec(`
+		UPDATE tasks SET queue_position = ?, queued_at = ?, retry_at = NULL, status = ?, updated_at = ? WHERE id = ?
+	`, nextPos, now, status, now, taskID); err != nil {
+		return err
 	}
-	if req.Description != nil {
-		p.Description = *req.Description
+	return recordTaskStatus(d.db, taskID, status, now)
+}
+
+// AddToQueueWithMessage adds a task to the queue with a continue message.
+func (d *Database) AddToQueueWithMessage(taskID string, message string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	nextPos, err := d.nextQueuePosition(taskID)
+	if err != nil {
+		return err
 	}
-	p.UpdatedAt = time.Now()
 
-	_, err = d.db.Exec(`
-		UPDATE projects SET name = ?, description = ?, updated_at = ? WHERE id = ?
-	`, p.Name, p.Description, p.UpdatedAt, p.ID)
+	now := time.Now()
+	if _, err := d.db.Exec(`
+		UPDATE tasks SET queue_position = ?, queued_at = ?, retry_at = NULL, status = 'queued', continue_message = ?, error = '', error_class = '', triage = '', updated_at = ? WHERE id = ?
+	`, nextPos, now, message, now, taskID); err != nil {
+		return err
+	}
+	return recordTaskStatus(d.db, taskID, StatusQueued, now)
+}
+
+// QueueTaskRetry stellt einen Task nach einem vorübergehenden Fehler mit einer
+// Fortsetzungs-Nachricht erneut in die Queue; er startet frühestens um retryAt.
+func (d *Database) QueueTaskRetry(taskID string, message string, retryAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	nextPos, err := d.nextQueuePosition(taskID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &p, nil
+	now := time.Now()
+	if _, err := d.db.Exec(`
+		UPDATE tasks SET queue_position = ?, queued_at = ?, retry_at = ?, status = 'queued', continue_message = ?, error = '', error_class = '', triage = '', updated_at = ? WHERE id = ?
+	`, nextPos, now, retryAt, message, now, taskID); err != nil {
+		return err
+	}
+	return recordTaskStatus(d.db, taskID, StatusQueued, now)
 }
 
-// DeleteProject löscht ein Projekt und entfernt die Verknüpfung von allen Tasks.
-func (d *Database) DeleteProject(id string) error {
+// ClearTaskRetry markiert den erneuten Versuch eines Tasks als gestartet.
+func (d *Database) ClearTaskRetry(taskID string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Zuerst project_id von Tasks entfernen
-	_, err := d.db.Exec(`UPDATE tasks SET project_id = '' WHERE project_id = ?`, id)
+	_, err := d.db.Exec(`UPDATE tasks SET retry_at = NULL, updated_at = ? WHERE id = ?`, time.Now(), taskID)
+	return err
+}
+
+// ClearContinueMessage clears the continue message for a task.
+func (d *Database) ClearContinueMessage(taskID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`UPDATE tasks SET continue_message = '', updated_at = ? WHERE id = ?`, time.Now(), taskID)
+	return err
+}
+
+// RemoveFromQueue removes a task from the queue and reorders the remaining
+// tasks of its queue lane.
+func (d *Database) RemoveFromQueue(taskID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Get current position
+	var currentPos int
+	err := d.db.QueryRow(`SELECT COALESCE(queue_position, 0) FROM tasks WHERE id = ?`, taskID).Scan(&currentPos)
 	if err != nil {
 		return err
 	}
 
-	// Dann Projekt löschen (Branch-Regeln werden durch CASCADE gelöscht)
-	_, err = d.db.Exec(`DELETE FROM projects WHERE id = ?`, id)
+	// Clear the task's queue position
+	_, err = d.db.Exec(`UPDATE tasks SET queue_position = 0, updated_at = ? WHERE id = ?`, time.Now(), taskID)
+	if err != nil {
+		return err
+	}
+
+	// Reorder remaining tasks if this task had a position
+	if currentPos > 0 {
+		_, err = d.db.Exec(`
+			UPDATE tasks SET queue_position = queue_position - 1, updated_at = ?
+			WHERE `+queueStatusFilter+` AND queue_position > ? AND `+queueLaneFilter,
+			time.Now(), currentPos, taskID)
+	}
 	return err
 }
 
-// ============================================================================
-// Task-Typ CRUD-Operationen
-// ============================================================================
+// MoveInQueue moves a queued task to position (1-based, clamped to the length
+// of its lane) and renumbers the lane in one transaction. Returns the new
+// order of the lane.
+func (d *Database) MoveInQueue(taskID string, position int) ([]QueueEntry, error) {
+	return d.reorderQueue(taskID, func(ids []string) ([]string, error) {
+		idx := -1
+		for i, id := range ids {
+			if id == taskID {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return nil, errTaskNotQueued
+		}
 
-// GetAllTaskTypes gibt alle Task-Typen zurück.
-// System-Typen werden zuerst angezeigt, dann benutzerdefinierte nach Name.
-func (d *Database) GetAllTaskTypes() ([]TaskType, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+		ids = append(ids[:idx], ids[idx+1:]...)
+		position = max(1, min(position, len(ids)+1))
+		ids = append(ids[:position-1], append([]string{taskID}, ids[position-1:]...)...)
+		return ids, nil
+	})
+}
 
-	rows, err := d.db.Query(`
-		SELECT id, name, color, is_system, created_at
-		FROM task_types
-		ORDER BY is_system DESC, name ASC
-	`)
+// ReorderQueue puts the given queued tasks at the front of their lane in the
+// given order. They must all belong to the same project. Queued tasks that are
+// not listed keep their relative order behind them.
+func (d *Database) ReorderQueue(taskIDs []string) ([]QueueEntry, error) {
+	return d.reorderQueue(taskIDs[0], func(ids []string) ([]string, error) {
+		queued := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			queued[id] = true
+		}
+
+		listed := make(map[string]bool, len(taskIDs))
+		order := make([]string, 0, len(ids))
+		for _, id := range taskIDs {
+			if !queued[id] || listed[id] {
+				return nil, errTaskNotQueued
+			}
+			listed[id] = true
+			order = append(order, id)
+		}
+		for _, id := range ids {
+			if !listed[id] {
+				order = append(order, id)
+			}
+		}
+		return order, nil
+	})
+}
+
+// reorderQueue loads the order of the queue lane of laneTaskID, lets reorder
+// rearrange it and writes positions 1..n back, all inside one transaction.
+func (d *Database) reorderQueue(laneTaskID string, reorder func(ids []string) ([]string, error)) ([]QueueEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var types []TaskType
+	rows, err := tx.Query(`
+		SELECT id FROM tasks
+		WHERE `+queueStatusFilter+` AND queue_position > 0 AND `+queueLaneFilter+`
+		ORDER BY queue_position ASC, queued_at ASC
+	`, laneTaskID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
 	for rows.Next() {
-		var t TaskType
-		err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.IsSystem, &t.CreatedAt)
-		if err != nil {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
 			return nil, err
 		}
-		types = append(types, t)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return types, rows.Err()
+	ids, err = reorder(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := make([]QueueEntry, len(ids))
+	for i, id := range ids {
+		if _, err := tx.Exec(`UPDATE tasks SET queue_position = ?, updated_at = ? WHERE id = ?`, i+1, now, id); err != nil {
+			return nil, err
+		}
+		entries[i] = QueueEntry{TaskID: id, Position: i + 1}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return entries, nil
 }
 
-// GetTaskType gibt einen einzelnen Task-Typ anhand seiner ID zurück.
-func (d *Database) GetTaskType(id string) (*TaskType, error) {
+// GetQueueState reads whether the queue is paused. Queued and Running are left
+// to the caller.
+func (d *Database) GetQueueState() (*QueueState, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
+	return d.queueState()
+}
 
-	var t TaskType
-	err := d.db.QueryRow(`
-		SELECT id, name, color, is_system, created_at
-		FROM task_types WHERE id = ?
-	`, id).Scan(&t.ID, &t.Name, &t.Color, &t.IsSystem, &t.CreatedAt)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+// SetQueuePaused pauses or resumes the queue. Pausing a paused queue only
+// updates the reason.
+func (d *Database) SetQueuePaused(paused bool, reason string) (*QueueState, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, err := d.queueState()
 	if err != nil {
 		return nil, err
 	}
-	return &t, nil
+	var pausedAt interface{}
+	switch {
+	case !paused:
+		reason = ""
+	case state.Paused && state.PausedAt != nil:
+		pausedAt = *state.PausedAt
+	default:
+		pausedAt = time.Now()
+	}
+	if _, err := d.db.Exec(`UPDATE config SET queue_paused = ?, queue_pause_reason = ?, queue_paused_at = ? WHERE id = 1`,
+		paused, reason, pausedAt); err != nil {
+		return nil, err
+	}
+	return d.queueState()
 }
 
-// CreateTaskType erstellt einen neuen benutzerdefinierten Task-Typ.
-func (d *Database) CreateTaskType(req CreateTaskTypeRequest) (*TaskType, error) {
+// SetQueueCooldown stores the cooldown of the queue after a rate limit; nil
+// until ends it. level counts the rate limits in a row.
+func (d *Database) SetQueueCooldown(until *time.Time, level int, reason string) (*QueueState, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	taskType := &TaskType{
-		ID:        uuid.New().String(),
-		Name:      req.Name,
-		Color:     req.Color,
-		IsSystem:  false, // Benutzerdefinierte Typen sind nie System-Typen
-		CreatedAt: time.Now(),
+	if until == nil {
+		reason = ""
 	}
-
-	_, err := d.db.Exec(`
-		INSERT INTO task_types (id, name, color, is_system, created_at)
-		VALUES (?, ?, ?, ?, ?)
-	`, taskType.ID, taskType.Name, taskType.Color, taskType.IsSystem, taskType.CreatedAt)
-	if err != nil {
+	if _, err := d.db.Exec(`UPDATE config SET queue_cooldown_until = ?, queue_cooldown_reason = ?, queue_cooldown_level = ? WHERE id = 1`,
+		until, reason, level); err != nil {
 		return nil, err
 	}
-
-	return taskType, nil
+	return d.queueState()
 }
 
-// UpdateTaskType aktualisiert einen bestehenden Task-Typ.
-func (d *Database) UpdateTaskType(id string, req UpdateTaskTypeRequest) (*TaskType, error) {
+// GetShareKey returns the key share links are signed with, created on first use
+func (d *Database) GetShareKey() ([]byte, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	var t TaskType
-	err := d.db.QueryRow(`
-		SELECT id, name, color, is_system, created_at
-		FROM task_types WHERE id = ?
-	`, id).Scan(&t.ID, &t.Name, &t.Color, &t.IsSystem, &t.CreatedAt)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
+	var stored sql.NullString
+	if err := d.db.QueryRow(`SELECT share_key FROM config WHERE id = 1`).Scan(&stored); err != nil {
 		return nil, err
 	}
+	if stored.String != "" {
+		return base64.StdEncoding.DecodeString(stored.String)
+	}
 
-	// Updates anwenden
-	if req.Name != nil {
-		t.Name = *req.Name
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
 	}
-	if req.Color != nil {
-		t.Color = *req.Color
+	if _, err := d.db.Exec(`UPDATE config SET share_key = ? WHERE id = 1`, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, err
 	}
+	return key, nil
+}
 
-	_, err = d.db.Exec(`
-		UPDATE task_types SET name = ?, color = ? WHERE id = ?
-	`, t.Name, t.Color, t.ID)
+// queueState reads the pause and cooldown columns of the config; the caller holds d.mu
+func (d *Database) queueState() (*QueueState, error) {
+	var paused sql.NullBool
+	var reason, cooldownReason sql.NullString
+	var pausedAt, cooldownUntil sql.NullTime
+	var cooldownLevel sql.NullInt64
+	err := d.db.QueryRow(`SELECT queue_paused, queue_pause_reason, queue_paused_at,
+		queue_cooldown_until, queue_cooldown_reason, queue_cooldown_level FROM config WHERE id = 1`).
+		Scan(&paused, &reason, &pausedAt, &cooldownUntil, &cooldownReason, &cooldownLevel)
 	if err != nil {
 		return nil, err
 	}
-
-	return &t, nil
+	state := &QueueState{Paused: paused.Bool, Reason: reason.String, CooldownLevel: int(cooldownLevel.Int64)}
+	if paused.Bool && pausedAt.Valid {
+		state.PausedAt = &pausedAt.Time
+	}
+	if cooldownUntil.Valid {
+		state.CooldownUntil = &cooldownUntil.Time
+		state.CooldownReason = cooldownReason.String
+	}
+	return state, nil
 }
 
-// DeleteTaskType löscht einen benutzerdefinierten Task-Typ.
-// System-Typen können nicht gelöscht werden.
-func (d *Database) DeleteTaskType(id string) error {
-	d.mu.Lock()
+// UpdateTaskProcessInfo updates the PID and process status of a task.
+func (d *Database) UpdateTaskProcessInfo(id string, pid int, status string) error {
+	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Prüfen ob es ein System-Typ ist
-	var isSystem bool
-	err := d.db.QueryRow(`SELECT is_system FROM task_types WHERE id = ?`, id).Scan(&isSystem)
-	if err != nil {
-		return err
-	}
-	if isSystem {
-		return sql.ErrNoRows // System-Typen können nicht gelöscht werden
-	}
+	_, err := d.db.Exec(`
+		UPDATE tasks SET process_pid = ?, process_status = ?, updated_at = ? WHERE id = ?
+	`, pid, status, time.Now(), id)
+	return err
+}
 
-	// task_type_id von Tasks entfernen die diesen Typ verwenden
-	_, err = d.db.Exec(`UPDATE tasks SET task_type_id = '' WHERE task_type_id = ?`, id)
-	if err != nil {
-		return err
-	}
+// UpdateTaskStartedAt sets the started_at timestamp for a task.
+func (d *Database) UpdateTaskStartedAt(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	_, err = d.db.Exec(`DELETE FROM task_types WHERE id = ? AND is_system = 0`, id)
+	_, err := d.db.Exec(`
+		UPDATE tasks SET started_at = ?, updated_at = ? WHERE id = ?
+	`, time.Now(), time.Now(), id)
 	return err
 }
 
-// ============================================================================
-// Branch-Schutzregel CRUD-Operationen
-// ============================================================================
+// UpdateTaskFinishedAt sets the finished_at timestamp for a task.
+func (d *Database) UpdateTaskFinishedAt(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-// GetBranchRules gibt alle Branch-Schutzregeln für ein Projekt zurück.
-func (d *Database) GetBranchRules(projectID string) ([]BranchProtectionRule, error) {
+	_, err := d.db.Exec(`
+		UPDATE tasks SET finished_at = ?, updated_at = ? WHERE id = ?
+	`, time.Now(), time.Now(), id)
+	return err
+}
+
+// GetTasksWithRunningProcess returns tasks that have a non-zero PID.
+func (d *Database) GetTasksWithRunningProcess() ([]Task, error) {
+	return d.getTasksByProcess("process_pid > 0")
+}
+
+// GetResumableTasks gibt Tasks zurück, die beim Shutdown angehalten wurden
+// und fortgesetzt werden können (siehe drain.go).
+func (d *Database) GetResumableTasks() ([]Task, error) {
+	return d.getTasksByProcess("process_status = ?", ProcessStatusResumable)
+}
+
+// getTasksByProcess gibt Tasks zurück, deren Prozess-Felder where erfüllen.
+func (d *Database) getTasksByProcess(where string, args ...interface{}) ([]Task, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
 	rows, err := d.db.Query(`
-		SELECT id, project_id, branch_pattern, created_at
-		FROM branch_protection_rules
-		WHERE project_id = ?
-		ORDER BY branch_pattern ASC
-	`, projectID)
+		SELECT id, title, description, acceptance_criteria, status, priority,
+		       current_iteration, max_iterations, logs, error, project_dir,
+		       created_at, updated_at,
+		       COALESCE(project_id, ''), COALESCE(task_type_id, ''), COALESCE(working_branch, ''),
+		       COALESCE(conflict_pr_url, ''), COALESCE(conflict_pr_number, 0),
+		       COALESCE(pr_url, ''), COALESCE(pr_number, 0),
+		       COALESCE(issue_url, ''), COALESCE(issue_number, 0),
+		       COALESCE(jira_key, ''), COALESCE(jira_synced_status, ''),
+		       COALESCE(linear_id, ''), COALESCE(linear_identifier, ''), COALESCE(linear_url, ''),
+		       COALESCE(env, ''), COALESCE(work_dir, ''),
+		       COALESCE(queue_position, 0), COALESCE(process_pid, 0), COALESCE(process_status, 'idle'),
+		       started_at, finished_at, queued_at,
+		       COALESCE(continue_message, '')
+		FROM tasks
+		WHERE `+where, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var rules []BranchProtectionRule
+	var tasks []Task
 	for rows.Next() {
-		var r BranchProtectionRule
-		err := rows.Scan(&r.ID, &r.ProjectID, &r.BranchPattern, &r.CreatedAt)
+		var t Task
+		var startedAt, finishedAt, queuedAt sql.NullTime
+		err := rows.Scan(
+			&t.ID, &t.Title, &t.Description, &t.AcceptanceCriteria,
+			&t.Status, &t.Priority, &t.CurrentIteration, &t.MaxIterations,
+			(*storedLogs)(&t.Logs), &t.Error, &t.ProjectDir, &t.CreatedAt, &t.UpdatedAt,
+			&t.ProjectID, &t.TaskTypeID, &t.WorkingBranch,
+			&t.ConflictPRURL, &t.ConflictPRNumber,
+			&t.PRURL, &t.PRNumber,
+			&t.IssueURL, &t.IssueNumber,
+			&t.JiraKey, &t.JiraSyncedStatus,
+			&t.LinearID, &t.LinearIdentifier, &t.LinearURL,
+			&t.Env, &t.WorkDir,
+			&t.QueuePosition, &t.ProcessPID, &t.ProcessStatus,
+			&startedAt, &finishedAt, &queuedAt,
+			&t.ContinueMessage,
+		)
 		if err != nil {
 			return nil, err
 		}
-		rules = append(rules, r)
+		if startedAt.Valid {
+			t.StartedAt = &startedAt.Time
+		}
+		if finishedAt.Valid {
+			t.FinishedAt = &finishedAt.Time
+		}
+		if queuedAt.Valid && t.QueuePosition > 0 {
+			t.QueuedAt = &queuedAt.Time
+		}
+		tasks = append(tasks, t)
 	}
 
-	return rules, rows.Err()
+	return tasks, rows.Err()
 }
 
-// CreateBranchRule erstellt eine neue Branch-Schutzregel.
-func (d *Database) CreateBranchRule(projectID string, pattern string) (*BranchProtectionRule, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// ============================================================================
+// Projekt CRUD-Operationen
+// ============================================================================
 
-	rule := &BranchProtectionRule{
-		ID:            uuid.New().String(),
-		ProjectID:     projectID,
-		BranchPattern: pattern,
-		CreatedAt:     time.Now(),
-	}
+// GetAllProjects gibt alle Projekte zurück, sortiert nach Name.
+// Ergänzt Git-Informationen (Branch, IsGitRepo) zur Laufzeit.
+func (d *Database) GetAllProjects() ([]Project, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	_, err := d.db.Exec(`
-		INSERT INTO branch_protection_rules (id, project_id, branch_pattern, created_at)
-		VALUES (?, ?, ?, ?)
-	`, rule.ID, rule.ProjectID, rule.BranchPattern, rule.CreatedAt)
+	rows, err := d.db.Query(`
+		SELECT p.id, p.name, p.path, p.description, p.is_auto_detected, p.created_at, p.updated_at,
+		       COALESCE(p.working_branch, ''), COALESCE(p.workflow, 'trunk'), COALESCE(p.issue_sync, 0), COALESCE(p.jira_project_key, ''), COALESCE(p.push_hook, 0),
+		       COALESCE(p.deploy_command, ''), COALESCE(p.deploy_timeout, 0), COALESCE(p.claude_settings, ''), COALESCE(p.screenshot_url, ''), COALESCE(p.screenshot_command, ''),
+		       COALESCE(p.budget, ''), COALESCE(p.board_id, 'default'), COALESCE(p.github, ''), COALESCE(p.auto_merge, ''),
+		       COALESCE(p.commit_template, ''), COALESCE(p.conventional_commits, 0), COALESCE(p.sign_commits, 0), p.missing_since,
+		       COALESCE(p.stack, ''), COALESCE(p.test_command, ''), COALESCE(p.build_command, ''),
+		       (SELECT COUNT(*) FROM tasks WHERE project_id = p.id) as task_count
+		FROM projects p
+		ORDER BY p.name ASC
+	`)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return rule, nil
-}
-
-// DeleteBranchRule löscht eine Branch-Schutzregel anhand ihrer ID.
-func (d *Database) DeleteBranchRule(id string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	var projects []Project
+	for rows.Next() {
+		var p Project
+		var missingSince sql.NullTime
+		err := rows.Scan(
+			&p.ID, &p.Name, &p.Path, &p.Description, &p.IsAutoDetected,
+			&p.CreatedAt, &p.UpdatedAt, &p.WorkingBranch, &p.Workflow, &p.IssueSync, &p.JiraProjectKey, &p.PushHook,
+			&p.DeployCommand, &p.DeployTimeout, &p.Claude, &p.ScreenshotURL, &p.ScreenshotCommand, &p.Budget, &p.BoardID, &p.GitHub, &p.AutoMerge, &p.CommitTemplate, &p.ConventionalCommits, &p.SignCommits, &missingSince, &p.Stack, &p.TestCommand, &p.BuildCommand, &p.TaskCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if missingSince.Valid {
+			p.MissingSince = &missingSince.Time
+		}
+		// Git-Informationen aus dem Cache (siehe gitstatus.go)
+		p.applyGitStatus()
+		projects = append(projects, p)
+	}
 
-	_, err := d.db.Exec(`DELETE FROM branch_protection_rules WHERE id = ?`, id)
-	return err
+	return projects, rows.Err()
 }
 
-// ============================================================================
-// Konfigurations-Operationen
-// ============================================================================
-
-// GetConfig gibt die globale Konfiguration zurück.
-// Es existiert nur ein Datensatz mit id=1.
-func (d *Database) GetConfig() (*Config, error) {
+// GetProject gibt ein einzelnes Projekt anhand seiner ID zurück.
+func (d *Database) GetProject(id string) (*Project, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	var c Config
-	// Nullable Felder für optionale Spalten
-	var projectsBaseDir, githubToken, defaultBranch, pushStrategy sql.NullString
-	var autoCommit, autoPush sql.NullBool
-	var defaultPriority, autoArchiveDays sql.NullInt64
-
+	var p Project
+	var missingSince sql.NullTime
 	err := d.db.QueryRow(`
-		SELECT id, default_project_dir, default_max_iterations, claude_command,
-		       COALESCE(projects_base_dir, ''), COALESCE(github_token, ''),
-		       COALESCE(auto_commit, 0), COALESCE(auto_push, 0),
-		       COALESCE(default_branch, 'main'), COALESCE(default_priority, 2),
-		       COALESCE(auto_archive_days, 0), COALESCE(push_strategy, 'manual')
-		FROM config WHERE id = 1
-	`).Scan(&c.ID, &c.DefaultProjectDir, &c.DefaultMaxIterations, &c.ClaudeCommand,
-		&projectsBaseDir, &githubToken, &autoCommit, &autoPush,
-		&defaultBranch, &defaultPriority, &autoArchiveDays, &pushStrategy)
+		SELECT p.id, p.name, p.path, p.description, p.is_auto_detected, p.created_at, p.updated_at,
+		       COALESCE(p.working_branch, ''), COALESCE(p.workflow, 'trunk'), COALESCE(p.issue_sync, 0), COALESCE(p.jira_project_key, ''), COALESCE(p.push_hook, 0),
+		       COALESCE(p.deploy_command, ''), COALESCE(p.deploy_timeout, 0), COALESCE(p.claude_settings, ''), COALESCE(p.screenshot_url, ''), COALESCE(p.screenshot_command, ''),
+		       COALESCE(p.budget, ''), COALESCE(p.board_id, 'default'), COALESCE(p.github, ''), COALESCE(p.auto_merge, ''),
+		       COALESCE(p.commit_template, ''), COALESCE(p.conventional_commits, 0), COALESCE(p.sign_commits, 0), p.missing_since,
+		       COALESCE(p.stack, ''), COALESCE(p.test_command, ''), COALESCE(p.build_command, ''),
+		       (SELECT COUNT(*) FROM tasks WHERE project_id = p.id) as task_count
+		FROM projects p
+		WHERE p.id = ?
+	`, id).Scan(
+		&p.ID, &p.Name, &p.Path, &p.Description, &p.IsAutoDetected,
+		&p.CreatedAt, &p.UpdatedAt, &p.WorkingBranch, &p.Workflow, &p.IssueSync, &p.JiraProjectKey, &p.PushHook,
+		&p.DeployCommand, &p.DeployTimeout, &p.Claude, &p.ScreenshotURL, &p.ScreenshotCommand, &p.Budget, &p.BoardID, &p.GitHub, &p.AutoMerge, &p.CommitTemplate, &p.ConventionalCommits, &p.SignCommits, &missingSince, &p.Stack, &p.TestCommand, &p.BuildCommand, &p.TaskCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-
-	// Nullable Werte übertragen
-	if projectsBaseDir.Valid {
-		c.ProjectsBaseDir = projectsBaseDir.String
+	if missingSince.Valid {
+		p.MissingSince = &missingSince.Time
 	}
-	if githubToken.Valid {
-		c.GithubToken = githubToken.String
+	// Git-Informationen aus dem Cache (siehe gitstatus.go)
+	p.applyGitStatus()
+	return &p, nil
+}
+
+// GetProjectByPath gibt ein Projekt anhand seines Pfads zurück.
+// Wird verwendet um Duplikate beim Projekt-Scan zu vermeiden.
+func (d *Database) GetProjectByPath(path string) (*Project, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var p Project
+	var missingSince sql.NullTime
+	err := d.db.QueryRow(`
+		SELECT id, name, path, description, is_auto_detected, created_at, updated_at,
+		       COALESCE(workflow, 'trunk'), COALESCE(issue_sync, 0),
+		       COALESCE(jira_project_key, ''), COALESCE(push_hook, 0),
+		       COALESCE(deploy_command, ''), COALESCE(deploy_timeout, 0), COALESCE(claude_settings, ''),
+		       COALESCE(screenshot_url, ''), COALESCE(screenshot_command, ''), COALESCE(budget, ''), COALESCE(board_id, 'default'),
+		       COALESCE(github, ''), COALESCE(auto_merge, ''), COALESCE(commit_template, ''), COALESCE(conventional_commits, 0),
+		       COALESCE(sign_commits, 0), missing_since, COALESCE(stack, ''), COALESCE(test_command, ''), COALESCE(build_command, '')
+		FROM projects WHERE path = ?
+	`, path).Scan(
+		&p.ID, &p.Name, &p.Path, &p.Description, &p.IsAutoDetected,
+		&p.CreatedAt, &p.UpdatedAt, &p.Workflow, &p.IssueSync, &p.JiraProjectKey, &p.PushHook,
+		&p.DeployCommand, &p.DeployTimeout, &p.Claude, &p.ScreenshotURL, &p.ScreenshotCommand, &p.Budget, &p.BoardID, &p.GitHub, &p.AutoMerge, &p.CommitTemplate, &p.ConventionalCommits, &p.SignCommits, &missingSince,
+		&p.Stack, &p.TestCommand, &p.BuildCommand,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	if autoCommit.Valid {
-		c.AutoCommit = autoCommit.Bool
+	if err != nil {
+		return nil, err
 	}
-	if autoPush.Valid {
-		c.AutoPush = autoPush.Bool
+	if missingSince.Valid {
+		p.MissingSince = &missingSince.Time
 	}
-	if defaultBranch.Valid {
-		c.DefaultBranch = defaultBranch.String
+	return &p, nil
+}
+
+// CreateProject erstellt ein neues Projekt.
+// isAutoDetected gibt an, ob das Projekt durch Scan gefunden wurde.
+func (d *Database) CreateProject(req CreateProjectRequest, isAutoDetected bool) (*Project, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	project := &Project{
+		ID:                uuid.New().String(),
+		Name:              req.Name,
+		Path:              req.Path,
+		Description:       req.Description,
+		IsAutoDetected:    isAutoDetected,
+		Workflow:          req.Workflow,
+		IssueSync:         req.IssueSync,
+		JiraProjectKey:    req.JiraProjectKey,
+		PushHook:          req.PushHook,
+		DeployCommand:     req.DeployCommand,
+		DeployTimeout:     req.DeployTimeout,
+		Claude:            req.Claude,
+		ScreenshotURL:     req.ScreenshotURL,
+		ScreenshotCommand: req.ScreenshotCommand,
+		Budget:            req.Budget,
+		BoardID:           req.BoardID,
+		GitHub:            req.GitHub,
+		AutoMerge:           req.AutoMerge,
+		CommitTemplate:      req.CommitTemplate,
+		ConventionalCommits: req.ConventionalCommits,
+		SignCommits:         req.SignCommits,
+		TestCommand:         req.TestCommand,
+		BuildCommand:        req.BuildCommand,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
 	}
-	if defaultPriority.Valid {
-		c.DefaultPriority = int(defaultPriority.Int64)
+	if project.Workflow == "" {
+		project.Workflow = WorkflowTrunk
 	}
-	if autoArchiveDays.Valid {
-		c.AutoArchiveDays = int(autoArchiveDays.Int64)
+	if project.BoardID == "" {
+		project.BoardID = DefaultBoardID
 	}
-	if pushStrategy.Valid {
-		c.PushStrategy = pushStrategy.String
+
+	_, err := d.db.Exec(`
+		INSERT INTO projects (id, name, path, description, is_auto_detected, workflow, issue_sync, jira_project_key, push_hook,
+		                      deploy_command, deploy_timeout, claude_settings, screenshot_url, screenshot_command, budget, board_id, github, auto_merge,
+		                      commit_template, conventional_commits, sign_commits, test_command, build_command, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		project.ID, project.Name, project.Path, project.Description,
+		project.IsAutoDetected, project.Workflow, project.IssueSync, project.JiraProjectKey, project.PushHook,
+		project.DeployCommand, project.DeployTimeout, project.Claude, project.ScreenshotURL, project.ScreenshotCommand, project.Budget, project.BoardID, project.GitHub, project.AutoMerge,
+		project.CommitTemplate, project.ConventionalCommits, project.SignCommits, project.TestCommand, project.BuildCommand,
+		project.CreatedAt, project.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
 	}
-	return &c, nil
+
+	// Git-Informationen aus dem Cache (siehe gitstatus.go)
+	project.applyGitStatus()
+
+	return project, nil
 }
 
-// UpdateConfig aktualisiert die globale Konfiguration.
-// Nur nicht-nil Felder werden aktualisiert.
-func (d *Database) UpdateConfig(req UpdateConfigRequest) (*Config, error) {
+// UpdateProject aktualisiert ein bestehendes Projekt.
+func (d *Database) UpdateProject(id string, req UpdateProjectRequest) (*Project, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Aktuelle Config laden
-	var c Config
-	var projectsBaseDir, githubToken, defaultBranch, pushStrategy sql.NullString
-	var autoCommit, autoPush sql.NullBool
-	var defaultPriority, autoArchiveDays sql.NullInt64
-
+	var p Project
+	var missingSince sql.NullTime
 	err := d.db.QueryRow(`
-		SELECT id, default_project_dir, default_max_iterations, claude_command,
-		       COALESCE(projects_base_dir, ''), COALESCE(github_token, ''),
-		       COALESCE(auto_commit, 0), COALESCE(auto_push, 0),
-		       COALESCE(default_branch, 'main'), COALESCE(default_priority, 2),
-		       COALESCE(auto_archive_days, 0), COALESCE(push_strategy, 'manual')
-		FROM config WHERE id = 1
-	`).Scan(&c.ID, &c.DefaultProjectDir, &c.DefaultMaxIterations, &c.ClaudeCommand,
-		&projectsBaseDir, &githubToken, &autoCommit, &autoPush,
-		&defaultBranch, &defaultPriority, &autoArchiveDays, &pushStrategy)
+		SELECT id, name, path, description, is_auto_detected, created_at, updated_at,
+		       COALESCE(working_branch, ''), COALESCE(workflow, 'trunk'), COALESCE(issue_sync, 0),
+		       COALESCE(jira_project_key, ''), COALESCE(push_hook, 0),
+		       COALESCE(deploy_command, ''), COALESCE(deploy_timeout, 0), COALESCE(claude_settings, ''),
+		       COALESCE(screenshot_url, ''), COALESCE(screenshot_command, ''), COALESCE(budget, ''), COALESCE(board_id, 'default'),
+		       COALESCE(github, ''), COALESCE(auto_merge, ''), COALESCE(commit_template, ''), COALESCE(conventional_commits, 0),
+		       COALESCE(sign_commits, 0), missing_since, COALESCE(stack, ''), COALESCE(test_command, ''), COALESCE(build_command, '')
+		FROM projects WHERE id = ?
+	`, id).Scan(
+		&p.ID, &p.Name, &p.Path, &p.Description, &p.IsAutoDetected,
+		&p.CreatedAt, &p.UpdatedAt, &p.WorkingBranch, &p.Workflow, &p.IssueSync, &p.JiraProjectKey, &p.PushHook,
+		&p.DeployCommand, &p.DeployTimeout, &p.Claude, &p.ScreenshotURL, &p.ScreenshotCommand, &p.Budget, &p.BoardID, &p.GitHub, &p.AutoMerge, &p.CommitTemplate, &p.ConventionalCommits, &p.SignCommits, &missingSince,
+		&p.Stack, &p.TestCommand, &p.BuildCommand,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	if missingSince.Valid {
+		p.MissingSince = &missingSince.Time
+	}
 
-	// Nullable Werte übertragen
-	if projectsBaseDir.Valid {
-		c.ProjectsBaseDir = projectsBaseDir.String
+	// Updates anwenden
+	if req.Name != nil {
+		p.Name = *req.Name
 	}
-	if githubToken.Valid {
-		c.GithubToken = githubToken.String
+	if req.Description != nil {
+		p.Description = *req.Description
 	}
-	if autoCommit.Valid {
-		c.AutoCommit = autoCommit.Bool
+	if req.Workflow != nil {
+		p.Workflow = *req.Workflow
 	}
-	if autoPush.Valid {
-		c.AutoPush = autoPush.Bool
+	if req.IssueSync != nil {
+		p.IssueSync = *req.IssueSync
 	}
-	if defaultBranch.Valid {
-		c.DefaultBranch = defaultBranch.String
+	if req.JiraProjectKey != nil {
+		p.JiraProjectKey = *req.JiraProjectKey
 	}
-	if defaultPriority.Valid {
-		c.DefaultPriority = int(defaultPriority.Int64)
+	if req.PushHook != nil {
+		p.PushHook = *req.PushHook
 	}
-	if autoArchiveDays.Valid {
-		c.AutoArchiveDays = int(autoArchiveDays.Int64)
+	if req.DeployCommand != nil {
+		p.DeployCommand = *req.DeployCommand
 	}
-	if pushStrategy.Valid {
-		c.PushStrategy = pushStrategy.String
+	if req.DeployTimeout != nil {
+		p.DeployTimeout = *req.DeployTimeout
 	}
-
-	// Updates anwenden
-	if req.DefaultProjectDir != nil {
-		c.DefaultProjectDir = *req.DefaultProjectDir
+	if req.Claude != nil {
+		p.Claude = *req.Claude
 	}
-	if req.DefaultMaxIterations != nil {
-		c.DefaultMaxIterations = *req.DefaultMaxIterations
+	if req.ScreenshotURL != nil {
+		p.ScreenshotURL = *req.ScreenshotURL
 	}
-	if req.ClaudeCommand != nil {
-		c.ClaudeCommand = *req.ClaudeCommand
+	if req.ScreenshotCommand != nil {
+		p.ScreenshotCommand = *req.ScreenshotCommand
 	}
-	if req.ProjectsBaseDir != nil {
-		c.ProjectsBaseDir = *req.ProjectsBaseDir
+	if req.Budget != nil {
+		p.Budget = *req.Budget
 	}
-	if req.GithubToken != nil {
-		c.GithubToken = *req.GithubToken
+	if req.BoardID != nil {
+		p.BoardID = *req.BoardID
 	}
-	if req.AutoCommit != nil {
-		c.AutoCommit = *req.AutoCommit
+	if req.GitHub != nil {
+		p.GitHub = *req.GitHub
 	}
-	if req.AutoPush != nil {
-		c.AutoPush = *req.AutoPush
+	if req.AutoMerge != nil {
+		p.AutoMerge = *req.AutoMerge
 	}
-	if req.DefaultBranch != nil {
-		c.DefaultBranch = *req.DefaultBranch
+	if req.CommitTemplate != nil {
+		p.CommitTemplate = *req.CommitTemplate
 	}
-	if req.DefaultPriority != nil {
-		c.DefaultPriority = *req.DefaultPriority
+	if req.ConventionalCommits != nil {
+		p.ConventionalCommits = *req.ConventionalCommits
 	}
-	if req.AutoArchiveDays != nil {
-		c.AutoArchiveDays = *req.AutoArchiveDays
+	if req.SignCommits != nil {
+		p.SignCommits = *req.SignCommits
+	}
+	if req.TestCommand != nil {
+		p.TestCommand = *req.TestCommand
 	}
+	if req.BuildCommand != nil {
+		p.BuildCommand = *req.BuildCommand
+	}
+	p.UpdatedAt = time.Now()
 
 	_, err = d.db.Exec(`
-		UPDATE config SET
-			default_project_dir = ?,
-			default_max_iterations = ?,
-			claude_command = ?,
-			projects_base_dir = ?,
-			github_token = ?,
-			auto_commit = ?,
-			auto_push = ?,
-			default_branch = ?,
-			default_priority = ?,
-			auto_archive_days = ?,
-			push_strategy = ?
-		WHERE id = 1
-	`, c.DefaultProjectDir, c.DefaultMaxIterations, c.ClaudeCommand, c.ProjectsBaseDir, c.GithubToken,
-		c.AutoCommit, c.AutoPush, c.DefaultBranch, c.DefaultPriority, c.AutoArchiveDays, c.PushStrategy)
+		UPDATE projects SET name = ?, description = ?, workflow = ?, issue_sync = ?, jira_project_key = ?, push_hook = ?,
+		                    deploy_command = ?, deploy_timeout = ?, claude_settings = ?, screenshot_url = ?, screenshot_command = ?,
+		                    budget = ?, board_id = ?, github = ?, auto_merge = ?, commit_template = ?, conventional_commits = ?,
+		                    sign_commits = ?, test_command = ?, build_command = ?, updated_at = ? WHERE id = ?
+	`, p.Name, p.Description, p.Workflow, p.IssueSync, p.JiraProjectKey, p.PushHook, p.DeployCommand, p.DeployTimeout, p.Claude,
+		p.ScreenshotURL, p.ScreenshotCommand, p.Budget, p.BoardID, p.GitHub, p.AutoMerge, p.CommitTemplate, p.ConventionalCommits, p.SignCommits,
+		p.TestCommand, p.BuildCommand, p.UpdatedAt, p.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &c, nil
+	return &p, nil
+}
+
+// DeleteProject löscht ein Projekt und entfernt die Verknüpfung von allen Tasks.
+func (d *Database) DeleteProject(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Zuerst project_id von Tasks entfernen
+	_, err := d.db.Exec(`UPDATE tasks SET project_id = '' WHERE project_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	// Secrets explizit löschen, damit keine verschlüsselten Werte zurückbleiben
+	_, err = d.db.Exec(`DELETE FROM project_secrets WHERE project_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(`DELETE FROM releases WHERE project_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(`DELETE FROM deployments WHERE project_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(`DELETE FROM project_environments WHERE project_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(`DELETE FROM path_protection_rules WHERE project_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(`DELETE FROM project_mcp_servers WHERE project_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(`DELETE FROM deploy_keys WHERE project_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	// Dann Projekt löschen (Branch-Regeln werden durch CASCADE gelöscht)
+	_, err = d.db.Exec(`DELETE FROM projects WHERE id = ?`, id)
+	return err
 }
 
 // ============================================================================
-// Attachment CRUD-Operationen
+// Task-Typ CRUD-Operationen
 // ============================================================================
 
-// GetAttachmentsByTask gibt alle Attachments für einen Task zurück.
-func (d *Database) GetAttachmentsByTask(taskID string) ([]Attachment, error) {
+// GetAllTaskTypes gibt alle Task-Typen zurück.
+// System-Typen werden zuerst angezeigt, dann benutzerdefinierte nach Name.
+func (d *Database) GetAllTaskTypes() ([]TaskType, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
 	rows, err := d.db.Query(`
-		SELECT id, task_id, filename, mime_type, size, path, created_at
-		FROM attachments
-		WHERE task_id = ?
-		ORDER BY created_at ASC
-	`, taskID)
+		SELECT id, name, color, is_system, created_at
+		FROM task_types
+		ORDER BY is_system DESC, name ASC
+	`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var attachments []Attachment
+	var types []TaskType
 	for rows.Next() {
-		var a Attachment
-		err := rows.Scan(&a.ID, &a.TaskID, &a.Filename, &a.MimeType, &a.Size, &a.Path, &a.CreatedAt)
+		var t TaskType
+		err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.IsSystem, &t.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
-		attachments = append(attachments, a)
+		types = append(types, t)
 	}
 
-	return attachments, rows.Err()
+	return types, rows.Err()
 }
 
-// GetAttachment gibt ein einzelnes Attachment anhand seiner ID zurück.
-func (d *Database) GetAttachment(id string) (*Attachment, error) {
+// GetTaskType gibt einen einzelnen Task-Typ anhand seiner ID zurück.
+func (d *Database) GetTaskType(id string) (*TaskType, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	var a Attachment
+	var t TaskType
 	err := d.db.QueryRow(`
-		SELECT id, task_id, filename, mime_type, size, path, created_at
-		FROM attachments WHERE id = ?
-	`, id).Scan(&a.ID, &a.TaskID, &a.Filename, &a.MimeType, &a.Size, &a.Path, &a.CreatedAt)
+		SELECT id, name, color, is_system, created_at
+		FROM task_types WHERE id = ?
+	`, id).Scan(&t.ID, &t.Name, &t.Color, &t.IsSystem, &t.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &a, nil
+	return &t, nil
 }
 
-// CreateAttachment erstellt einen neuen Attachment-Datensatz.
-func (d *Database) CreateAttachment(attachment *Attachment) error {
+// CreateTaskType erstellt einen neuen benutzerdefinierten Task-Typ.
+func (d *Database) CreateTaskType(req CreateTaskTypeRequest) (*TaskType, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	taskType := &TaskType{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Color:     req.Color,
+		IsSystem:  false, // Benutzerdefinierte Typen sind nie System-Typen
+		CreatedAt: time.Now(),
+	}
+
 	_, err := d.db.Exec(`
-		INSERT INTO attachments (id, task_id, filename, mime_type, size, path, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, attachment.ID, attachment.TaskID, attachment.Filename, attachment.MimeType,
-		attachment.Size, attachment.Path, attachment.CreatedAt)
-	return err
+		INSERT INTO task_types (id, name, color, is_system, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, taskType.ID, taskType.Name, taskType.Color, taskType.IsSystem, taskType.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return taskType, nil
 }
 
-// DeleteAttachment löscht ein Attachment anhand seiner ID.
-func (d *Database) DeleteAttachment(id string) error {
+// UpdateTaskType aktualisiert einen bestehenden Task-Typ.
+func (d *Database) UpdateTaskType(id string, req UpdateTaskTypeRequest) (*TaskType, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	_, err := d.db.Exec(`DELETE FROM attachments WHERE id = ?`, id)
-	return err
+	var t TaskType
+	err := d.db.QueryRow(`
+		SELECT id, name, color, is_system, created_at
+		FROM task_types WHERE id = ?
+	`, id).Scan(&t.ID, &t.Name, &t.Color, &t.IsSystem, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Updates anwenden
+	if req.Name != nil {
+		t.Name = *req.Name
+	}
+	if req.Color != nil {
+		t.Color = *req.Color
+	}
+
+	_, err = d.db.Exec(`
+		UPDATE task_types SET name = ?, color = ? WHERE id = ?
+	`, t.Name, t.Color, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
 }
 
-// DeleteAttachmentsByTask löscht alle Attachments für einen Task.
-func (d *Database) DeleteAttachmentsByTask(taskID string) error {
+// DeleteTaskType löscht einen benutzerdefinierten Task-Typ.
+// System-Typen können nicht gelöscht werden.
+func (d *Database) DeleteTaskType(id string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	_, err := d.db.Exec(`DELETE FROM attachments WHERE task_id = ?`, taskID)
+	// Prüfen ob es ein System-Typ ist
+	var isSystem bool
+	err := d.db.QueryRow(`SELECT is_system FROM task_types WHERE id = ?`, id).Scan(&isSystem)
+	if err != nil {
+		return err
+	}
+	if isSystem {
+		return sql.ErrNoRows // System-Typen können nicht gelöscht werden
+	}
+
+	// task_type_id von Tasks entfernen die diesen Typ verwenden
+	_, err = d.db.Exec(`UPDATE tasks SET task_type_id = '' WHERE task_type_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(`DELETE FROM task_types WHERE id = ? AND is_system = 0`, id)
 	return err
 }
 
 // ============================================================================
-// Trunk-Based Development Operations
+// Label CRUD-Operationen
 // ============================================================================
 
-// UpdateProjectWorkingBranch aktualisiert den persistenten Working-Branch eines Projekts.
-func (d *Database) UpdateProjectWorkingBranch(id string, branch string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// GetAllLabels gibt alle Labels sortiert nach Name zurück.
+func (d *Database) GetAllLabels() ([]Label, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	_, err := d.db.Exec(`
-		UPDATE projects SET working_branch = ?, updated_at = ? WHERE id = ?
-	`, branch, time.Now(), id)
-	return err
+	rows, err := d.db.Query(`
+		SELECT id, name, color, created_at
+		FROM labels
+		ORDER BY name ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []Label
+	for rows.Next() {
+		var l Label
+		if err := rows.Scan(&l.ID, &l.Name, &l.Color, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+
+	return labels, rows.Err()
 }
 
-// UpdateTaskRollbackTag aktualisiert den Rollback-Tag eines Tasks.
-func (d *Database) UpdateTaskRollbackTag(id string, tag string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// GetLabel gibt ein einzelnes Label anhand seiner ID zurück.
+func (d *Database) GetLabel(id string) (*Label, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	_, err := d.db.Exec(`
-		UPDATE tasks SET rollback_tag = ?, updated_at = ? WHERE id = ?
-	`, tag, time.Now(), id)
-	return err
+	return d.getLabel(id)
 }
 
-// UpdateTaskCommitHash aktualisiert den Commit-Hash eines Tasks.
-func (d *Database) UpdateTaskCommitHash(id string, hash string) error {
+// getLabel liest ein Label ohne Locking (Aufrufer hält d.mu)
+func (d *Database) getLabel(id string) (*Label, error) {
+	var l Label
+	err := d.db.QueryRow(`
+		SELECT id, name, color, created_at
+		FROM labels WHERE id = ?
+	`, id).Scan(&l.ID, &l.Name, &l.Color, &l.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// labelNameTaken prüft, ob ein anderes Label bereits diesen Namen trägt (ohne Groß-/Kleinschreibung)
+func (d *Database) labelNameTaken(name, exceptID string) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM labels WHERE LOWER(name) = LOWER(?) AND id != ?`,
+		name, exceptID).Scan(&count)
+	return count > 0, err
+}
+
+// CreateLabel erstellt ein neues Label.
+func (d *Database) CreateLabel(req CreateLabelRequest) (*Label, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	taken, err := d.labelNameTaken(req.Name, "")
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, errLabelExists
+	}
+
+	label := &Label{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Color:     req.Color,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = d.db.Exec(`
+		INSERT INTO labels (id, name, color, created_at)
+		VALUES (?, ?, ?, ?)
+	`, label.ID, label.Name, label.Color, label.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return label, nil
+}
+
+// UpdateLabel aktualisiert Name und/oder Farbe eines Labels.
+func (d *Database) UpdateLabel(id string, req UpdateLabelRequest) (*Label, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	l, err := d.getLabel(id)
+	if err != nil || l == nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		taken, err := d.labelNameTaken(*req.Name, id)
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, errLabelExists
+		}
+		l.Name = *req.Name
+	}
+	if req.Color != nil {
+		l.Color = *req.Color
+	}
+
+	_, err = d.db.Exec(`UPDATE labels SET name = ?, color = ? WHERE id = ?`, l.Name, l.Color, l.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// DeleteLabel löscht ein Label und entfernt es von allen Tasks.
+// Gibt sql.ErrNoRows zurück, wenn das Label nicht existiert.
+func (d *Database) DeleteLabel(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM task_labels WHERE label_id = ?`, id); err != nil {
+		return err
+	}
+	res, err := tx.Exec(`DELETE FROM labels WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// checkLabelIDs prüft, ob alle Label-IDs existieren (Aufrufer hält d.mu)
+func (d *Database) checkLabelIDs(ids []string) error {
+	for _, id := range ids {
+		var count int
+		if err := d.db.QueryRow(`SELECT COUNT(*) FROM labels WHERE id = ?`, id).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %s", errLabelNotFound, id)
+		}
+	}
+	return nil
+}
+
+// setTaskLabels ersetzt die Labels eines Tasks (Aufrufer hält d.mu)
+func (d *Database) setTaskLabels(taskID string, labelIDs []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM task_labels WHERE task_id = ?`, taskID); err != nil {
+		return err
+	}
+	for _, labelID := range labelIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO task_labels (task_id, label_id) VALUES (?, ?)`,
+			taskID, labelID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// loadTaskLabels hängt die Labels an die Tasks an (Aufrufer hält d.mu).
+// Für einen einzelnen Task wird nur dessen Zuordnung gelesen.
+func (d *Database) loadTaskLabels(tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT tl.task_id, l.id, l.name, l.color, l.created_at
+		FROM task_labels tl
+		JOIN labels l ON l.id = tl.label_id`
+	var args []interface{}
+	if len(tasks) == 1 {
+		query += ` WHERE tl.task_id = ?`
+		args = append(args, tasks[0].ID)
+	}
+	query += ` ORDER BY l.name ASC`
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	index := make(map[string]int, len(tasks))
+	for i := range tasks {
+		index[tasks[i].ID] = i
+	}
+	for rows.Next() {
+		var taskID string
+		var l Label
+		if err := rows.Scan(&taskID, &l.ID, &l.Name, &l.Color, &l.CreatedAt); err != nil {
+			return err
+		}
+		if i, ok := index[taskID]; ok {
+			tasks[i].Labels = append(tasks[i].Labels, l)
+		}
+	}
+
+	return rows.Err()
+}
+
+// ============================================================================
+// Teil-Tasks und Abhängigkeiten
+// ============================================================================
+
+// CreateSubtasks legt die Teil-Tasks eines Epics samt ihrer Abhängigkeiten in
+// einer Transaktion an. Sie übernehmen Projekt, Branches, Typ, Labels und
+// Einstellungen des Epics und landen im Backlog.
+func (d *Database) CreateSubtasks(parent *Task, subtasks []SubtaskProposal, config *Config) ([]Task, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	tasks := make([]Task, len(subtasks))
+	for i, sub := range subtasks {
+		t := Task{
+			ID:                 uuid.New().String(),
+			Title:              sub.Title,
+			Description:        sub.Description,
+			AcceptanceCriteria: sub.AcceptanceCriteria,
+			Status:             StatusBacklog,
+			Priority:           parent.Priority,
+			MaxIterations:      parent.MaxIterations,
+			ProjectDir:         parent.ProjectDir,
+			ProjectID:          parent.ProjectID,
+			TaskTypeID:         parent.TaskTypeID,
+			TargetBranch:       parent.TargetBranch,
+			Env:                parent.Env,
+			WorkDir:            parent.WorkDir,
+			BoardID:            parent.BoardID,
+			ParentID:           parent.ID,
+			Labels:             parent.Labels,
+			// Versetzte Zeitstempel erhalten die Reihenfolge des Vorschlags
+			CreatedAt: now.Add(time.Duration(i) * time.Millisecond),
+			UpdatedAt: now,
+		}
+		if t.MaxIterations == 0 {
+			t.MaxIterations = config.DefaultMaxIterations
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO tasks (id, title, description, acceptance_criteria, status,
+			                   priority, current_iteration, max_iterations, logs,
+			                   error, project_dir, project_id, task_type_id, working_branch,
+			                   target_branch, env, work_dir, board_id, parent_id, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, 0, ?, '', '', ?, ?, ?, '', ?, ?, ?, ?, ?, ?, ?)
+		`,
+			t.ID, t.Title, t.Description, t.AcceptanceCriteria, t.Status,
+			t.Priority, t.MaxIterations, t.ProjectDir, t.ProjectID, t.TaskTypeID,
+			t.TargetBranch, t.Env, t.WorkDir, t.BoardID, t.ParentID, t.CreatedAt, t.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if err := recordTaskStatus(tx, t.ID, t.Status, t.CreatedAt); err != nil {
+			return nil, err
+		}
+		for _, l := range t.Labels {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO task_labels (task_id, label_id) VALUES (?, ?)`, t.ID, l.ID); err != nil {
+				return nil, err
+			}
+		}
+		tasks[i] = t
+	}
+
+	for i, sub := range subtasks {
+		for _, dep := range sub.DependsOn {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO task_dependencies (task_id, depends_on_id) VALUES (?, ?)`,
+				tasks[i].ID, tasks[dep].ID); err != nil {
+				return nil, err
+			}
+			tasks[i].DependsOn = append(tasks[i].DependsOn, tasks[dep].ID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// HasOpenDependencies meldet, ob ein Task auf Tasks wartet, die noch nicht
+// erledigt sind (Spalte mit Rolle terminal). Gelöschte Tasks zählen nicht.
+func (d *Database) HasOpenDependencies(taskID string) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var open int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM task_dependencies dep
+		JOIN tasks t ON t.id = dep.depends_on_id
+		WHERE dep.task_id = ?
+		  AND t.status NOT IN (SELECT status FROM board_columns WHERE role = 'terminal')
+	`, taskID).Scan(&open)
+	return open > 0, err
+}
+
+// loadTaskDependencies hängt die Abhängigkeiten an die Tasks an (Aufrufer hält d.mu).
+// Für einen einzelnen Task wird nur dessen Zuordnung gelesen.
+func (d *Database) loadTaskDependencies(tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	query := `SELECT task_id, depends_on_id FROM task_dependencies`
+	var args []interface{}
+	if len(tasks) == 1 {
+		query += ` WHERE task_id = ?`
+		args = append(args, tasks[0].ID)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	index := make(map[string]int, len(tasks))
+	for i := range tasks {
+		index[tasks[i].ID] = i
+	}
+	for rows.Next() {
+		var taskID, dependsOn string
+		if err := rows.Scan(&taskID, &dependsOn); err != nil {
+			return err
+		}
+		if i, ok := index[taskID]; ok {
+			tasks[i].DependsOn = append(tasks[i].DependsOn, dependsOn)
+		}
+	}
+
+	return rows.Err()
+}
+
+// ============================================================================
+// Status-Verlauf
+// ============================================================================
+
+// sqlExecer wird von Verbindung und Transaktion erfüllt.
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// recordTaskStatus hält fest, dass der Task ab at im Status ist (Aufrufer hält d.mu).
+// Bleibt der Status gleich, wird nichts gespeichert.
+func recordTaskStatus(q sqlExecer, taskID string, status TaskStatus, at time.Time) error {
+	var last TaskStatus
+	err := q.QueryRow(`
+		SELECT status FROM task_events WHERE task_id = ? ORDER BY created_at DESC LIMIT 1
+	`, taskID).Scan(&last)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && last == status {
+		return nil
+	}
+	_, err = q.Exec(`
+		INSERT INTO task_events (id, task_id, status, alerted, created_at) VALUES (?, ?, ?, 0, ?)
+	`, uuid.New().String(), taskID, status, at)
+	return err
+}
+
+// GetTaskEvents gibt die Status-Wechsel eines Tasks in zeitlicher Reihenfolge zurück.
+func (d *Database) GetTaskEvents(taskID string) ([]TaskEvent, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`
+		SELECT id, task_id, status, created_at FROM task_events
+		WHERE task_id = ? ORDER BY created_at ASC
+	`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []TaskEvent{}
+	for rows.Next() {
+		var e TaskEvent
+		if err := rows.Scan(&e.ID, &e.TaskID, &e.Status, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// GetSLABreaches gibt die Tasks zurück, die länger als das SLA ihrer Spalte
+// darin stehen und für diesen Aufenthalt noch nicht gemeldet wurden.
+func (d *Database) GetSLABreaches(now time.Time) ([]SLABreach, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`
+		SELECT e.id, t.id, t.title, t.status, c.name, c.sla_hours, e.created_at
+		FROM task_events e
+		JOIN tasks t ON t.id = e.task_id AND t.status = e.status
+		JOIN board_columns c ON c.status = e.status
+		WHERE c.sla_hours > 0 AND COALESCE(e.alerted, 0) = 0
+		  AND e.created_at = (SELECT MAX(created_at) FROM task_events WHERE task_id = e.task_id)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var breaches []SLABreach
+	for rows.Next() {
+		var b SLABreach
+		if err := rows.Scan(&b.EventID, &b.TaskID, &b.Title, &b.Status, &b.ColumnName, &b.SLAHours, &b.Since); err != nil {
+			return nil, err
+		}
+		if now.Sub(b.Since) >= time.Duration(b.SLAHours)*time.Hour {
+			breaches = append(breaches, b)
+		}
+	}
+	return breaches, rows.Err()
+}
+
+// MarkTaskEventAlerted merkt sich, dass der Aufenthalt bereits gemeldet wurde.
+func (d *Database) MarkTaskEventAlerted(eventID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`UPDATE task_events SET alerted = 1 WHERE id = ?`, eventID)
+	return err
+}
+
+// ============================================================================
+// Board-Spalten CRUD-Operationen
+// ============================================================================
+
+// Status-Filter über die Rollen der Board-Spalten, damit eigene Queue- und
+// Progress-Spalten vom Runner genauso behandelt werden wie queued/progress.
+const (
+	queueStatusFilter    = "status IN (SELECT status FROM board_columns WHERE role = 'queue')"
+	progressStatusFilter = "status IN (SELECT status FROM board_columns WHERE role = 'progress')"
+)
+
+// GetBoardColumns gibt alle Spalten in Board-Reihenfolge zurück.
+func (d *Database) GetBoardColumns() ([]BoardColumn, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`
+		SELECT status, name, color, sort_order, wip_limit, COALESCE(sla_hours, 0), role, is_system, COALESCE(board_id, ''), created_at
+		FROM board_columns
+		ORDER BY sort_order ASC, created_at ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []BoardColumn
+	for rows.Next() {
+		var c BoardColumn
+		err := rows.Scan(&c.Status, &c.Name, &c.Color, &c.Position, &c.WIPLimit, &c.SLAHours, &c.Role, &c.IsSystem, &c.BoardID, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+
+	return columns, rows.Err()
+}
+
+// GetBoardColumn gibt eine Spalte anhand ihres Status zurück (nil wenn unbekannt).
+func (d *Database) GetBoardColumn(status TaskStatus) (*BoardColumn, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.getBoardColumn(status)
+}
+
+func (d *Database) getBoardColumn(status TaskStatus) (*BoardColumn, error) {
+	var c BoardColumn
+	err := d.db.QueryRow(`
+		SELECT status, name, color, sort_order, wip_limit, COALESCE(sla_hours, 0), role, is_system, COALESCE(board_id, ''), created_at
+		FROM board_columns WHERE status = ?
+	`, status).Scan(&c.Status, &c.Name, &c.Color, &c.Position, &c.WIPLimit, &c.SLAHours, &c.Role, &c.IsSystem, &c.BoardID, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CreateBoardColumn legt eine eigene Spalte am Ende des Boards an.
+func (d *Database) CreateBoardColumn(req CreateBoardColumnRequest) (*BoardColumn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	existing, err := d.getBoardColumn(req.Status)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errColumnExists
+	}
+
+	var maxOrder sql.NullInt64
+	if err := d.db.QueryRow(`SELECT MAX(sort_order) FROM board_columns`).Scan(&maxOrder); err != nil {
+		return nil, err
+	}
+
+	column := &BoardColumn{
+		Status:    req.Status,
+		Name:      req.Name,
+		Color:     req.Color,
+		Position:  int(maxOrder.Int64) + 1,
+		WIPLimit:  req.WIPLimit,
+		SLAHours:  req.SLAHours,
+		Role:      req.Role,
+		IsSystem:  false, // Eigene Spalten sind nie System-Spalten
+		BoardID:   req.BoardID,
+		CreatedAt: time.Now(),
+	}
+	if column.BoardID == "" {
+		column.BoardID = DefaultBoardID
+	}
+
+	_, err = d.db.Exec(`
+		INSERT INTO board_columns (status, name, color, sort_order, wip_limit, sla_hours, role, is_system, board_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, column.Status, column.Name, column.Color, column.Position, column.WIPLimit, column.SLAHours, column.Role, column.IsSystem, column.BoardID, column.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return column, nil
+}
+
+// UpdateBoardColumn ändert Name, Farbe, WIP-Limit, SLA oder Rolle einer Spalte.
+// Die Rolle von System-Spalten ist fest, da der Runner diese Status direkt setzt.
+func (d *Database) UpdateBoardColumn(status TaskStatus, req UpdateBoardColumnRequest) (*BoardColumn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, err := d.getBoardColumn(status)
+	if err != nil || c == nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		c.Name = *req.Name
+	}
+	if req.Color != nil {
+		c.Color = *req.Color
+	}
+	if req.WIPLimit != nil {
+		c.WIPLimit = *req.WIPLimit
+	}
+	if req.SLAHours != nil {
+		c.SLAHours = *req.SLAHours
+	}
+	if req.Role != nil && *req.Role != c.Role {
+		if c.IsSystem {
+			return nil, errSystemColumn
+		}
+		c.Role = *req.Role
+	}
+
+	_, err = d.db.Exec(`
+		UPDATE board_columns SET name = ?, color = ?, wip_limit = ?, sla_hours = ?, role = ? WHERE status = ?
+	`, c.Name, c.Color, c.WIPLimit, c.SLAHours, c.Role, c.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// DeleteBoardColumn löscht eine eigene Spalte.
+// System-Spalten und Spalten, die noch Tasks enthalten, können nicht gelöscht werden.
+func (d *Database) DeleteBoardColumn(status TaskStatus) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, err := d.getBoardColumn(status)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return sql.ErrNoRows
+	}
+	if c.IsSystem {
+		return errSystemColumn
+	}
+
+	var count int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE status = ?`, status).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return errColumnNotEmpty
+	}
+
+	_, err = d.db.Exec(`DELETE FROM board_columns WHERE status = ? AND is_system = 0`, status)
+	return err
+}
+
+// CountTasksByStatus zählt die Tasks in einer Spalte (für WIP-Limits).
+func (d *Database) CountTasksByStatus(status TaskStatus) (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE status = ?`, status).Scan(&count)
+	return count, err
+}
+
+// ReorderBoardColumns setzt die Reihenfolge aller Spalten in einer Transaktion.
+func (d *Database) ReorderBoardColumns(statuses []TaskStatus) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, status := range statuses {
+		if _, err := tx.Exec(`UPDATE board_columns SET sort_order = ? WHERE status = ?`, i+1, status); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ============================================================================
+// Board CRUD-Operationen
+// ============================================================================
+
+// boardSelect liefert die Spalten, die scanBoard erwartet, samt Anzahl der Tasks und Projekte
+const boardSelect = `
+	SELECT b.id, b.name, COALESCE(b.description, ''), COALESCE(b.queue_policy, 'fifo'),
+	       COALESCE(b.max_concurrent_tasks, 0), COALESCE(b.priority_aging_hours, 0),
+	       b.created_at, b.updated_at,
+	       (SELECT COUNT(*) FROM tasks WHERE board_id = b.id),
+	       (SELECT COUNT(*) FROM projects WHERE board_id = b.id)
+	FROM boards b`
+
+func scanBoard(row interface{ Scan(...interface{}) error }) (*Board, error) {
+	var b Board
+	err := row.Scan(&b.ID, &b.Name, &b.Description, &b.QueuePolicy, &b.MaxConcurrentTasks, &b.PriorityAgingHours, &b.CreatedAt, &b.UpdatedAt, &b.TaskCount, &b.ProjectCount)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetBoards gibt alle Boards zurück, das Standard-Board zuerst, dann nach Name.
+func (d *Database) GetBoards() ([]Board, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(boardSelect + ` ORDER BY CASE WHEN b.id = 'default' THEN 0 ELSE 1 END, b.name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var boards []Board
+	for rows.Next() {
+		b, err := scanBoard(rows)
+		if err != nil {
+			return nil, err
+		}
+		boards = append(boards, *b)
+	}
+	return boards, rows.Err()
+}
+
+// GetBoard gibt ein Board anhand seiner ID zurück (nil wenn unbekannt).
+func (d *Database) GetBoard(id string) (*Board, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.getBoard(id)
+}
+
+func (d *Database) getBoard(id string) (*Board, error) {
+	b, err := scanBoard(d.db.QueryRow(boardSelect+` WHERE b.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return b, err
+}
+
+// CreateBoard legt ein neues, leeres Board an.
+func (d *Database) CreateBoard(req CreateBoardRequest) (*Board, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	board := &Board{
+		ID:                 uuid.New().String(),
+		Name:               req.Name,
+		Description:        req.Description,
+		QueuePolicy:        req.QueuePolicy,
+		MaxConcurrentTasks: req.MaxConcurrentTasks,
+		PriorityAgingHours: req.PriorityAgingHours,
+		CreatedAt:          time.Now(),
+		UpdatedAt:          time.Now(),
+	}
+
+	_, err := d.db.Exec(`
+		INSERT INTO boards (id, name, description, queue_policy, max_concurrent_tasks, priority_aging_hours, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, board.ID, board.Name, board.Description, board.QueuePolicy, board.MaxConcurrentTasks, board.PriorityAgingHours, board.CreatedAt, board.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return board, nil
+}
+
+// UpdateBoard ändert Name, Beschreibung, Queue-Strategie, Task-Limit oder Priority Aging eines Boards.
+func (d *Database) UpdateBoard(id string, req UpdateBoardRequest) (*Board, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, err := d.getBoard(id)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		b.Name = *req.Name
+	}
+	if req.Description != nil {
+		b.Description = *req.Description
+	}
+	if req.QueuePolicy != nil {
+		b.QueuePolicy = *req.QueuePolicy
+	}
+	if req.MaxConcurrentTasks != nil {
+		b.MaxConcurrentTasks = *req.MaxConcurrentTasks
+	}
+	if req.PriorityAgingHours != nil {
+		b.PriorityAgingHours = *req.PriorityAgingHours
+	}
+	b.UpdatedAt = time.Now()
+
+	_, err = d.db.Exec(`
+		UPDATE boards SET name = ?, description = ?, queue_policy = ?, max_concurrent_tasks = ?, priority_aging_hours = ?, updated_at = ? WHERE id = ?
+	`, b.Name, b.Description, b.QueuePolicy, b.MaxConcurrentTasks, b.PriorityAgingHours, b.UpdatedAt, b.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// DeleteBoard löscht ein leeres Board samt seiner eigenen Spalten.
+// Das Standard-Board und Boards mit Tasks oder Projekten können nicht gelöscht werden.
+func (d *Database) DeleteBoard(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if id == DefaultBoardID {
+		return errDefaultBoard
+	}
+	b, err := d.getBoard(id)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		return sql.ErrNoRows
+	}
+	if b.TaskCount > 0 || b.ProjectCount > 0 {
+		return errBoardNotEmpty
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM board_columns WHERE board_id = ? AND is_system = 0`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM boards WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ============================================================================
+// Benutzer- und Sitzungs-Operationen
+// ============================================================================
+
+// UpsertUser legt einen per GitHub angemeldeten Benutzer an oder aktualisiert
+// Profil, Token und letzte Anmeldung eines bekannten (erkannt an der GitHub-ID).
+// Setzt ID und CreatedAt des übergebenen Benutzers.
+func (d *Database) UpsertUser(user *User) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	token, err := sealUserToken(user.GithubID, "github_token", user.GithubToken)
+	if err != nil {
+		return err
+	}
+	user.LastLoginAt = time.Now()
+
+	var id string
+	var createdAt time.Time
+	err = d.db.QueryRow(`SELECT id, created_at FROM users WHERE github_id = ?`, user.GithubID).Scan(&id, &createdAt)
+	if err == sql.ErrNoRows {
+		user.ID = uuid.New().String()
+		user.CreatedAt = user.LastLoginAt
+		_, err = d.db.Exec(`
+			INSERT INTO users (id, github_id, login, name, avatar_url, github_token, created_at, last_login_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, user.ID, user.GithubID, user.Login, user.Name, user.AvatarURL, token, user.CreatedAt, user.LastLoginAt)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	user.ID, user.CreatedAt = id, createdAt
+	_, err = d.db.Exec(`
+		UPDATE users SET login = ?, name = ?, avatar_url = ?, github_token = ?, last_login_at = ?
+		WHERE id = ?
+	`, user.Login, user.Name, user.AvatarURL, token, user.LastLoginAt, user.ID)
+	return err
+}
+
+// CreateSession speichert eine Sitzung unter dem Hash ihres Tokens und
+// löscht dabei abgelaufene Sitzungen.
+func (d *Database) CreateSession(userID, tokenHash string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.db.Exec(`DELETE FROM sessions WHERE expires_at < ?`, time.Now()); err != nil {
+		return err
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)
+	`, tokenHash, userID, time.Now(), expiresAt)
+	return err
+}
+
+// GetSessionUser gibt den Benutzer einer gültigen Sitzung zurück (nil wenn
+// unbekannt oder abgelaufen), mit entschlüsseltem GitHub-Token.
+func (d *Database) GetSessionUser(tokenHash string) (*User, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	u, err := scanUser(d.db.QueryRow(`
+		SELECT `+userColumns+`
+		FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = ? AND s.expires_at > ?
+	`, tokenHash, time.Now()))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return u, err
+}
+
+// userColumns sind die Spalten (der Tabelle users als u), die scanUser erwartet
+const userColumns = `u.id, u.github_id, u.login, COALESCE(u.name, ''), COALESCE(u.avatar_url, ''),
+		       COALESCE(u.github_token, ''), u.created_at, u.last_login_at,
+		       COALESCE(u.personal_token, ''), COALESCE(u.git_name, ''), COALESCE(u.git_email, '')`
+
+// scanUser liest einen Benutzer und entschlüsselt seine Tokens.
+func scanUser(row interface{ Scan(...interface{}) error }) (*User, error) {
+	var u User
+	if err := row.Scan(&u.ID, &u.GithubID, &u.Login, &u.Name, &u.AvatarURL,
+		&u.GithubToken, &u.CreatedAt, &u.LastLoginAt,
+		&u.PersonalToken, &u.GitName, &u.GitEmail); err != nil {
+		return nil, err
+	}
+	u.GithubToken = openUserToken(u.GithubID, "github_token", u.GithubToken)
+	u.PersonalToken = openUserToken(u.GithubID, "personal_token", u.PersonalToken)
+	u.PersonalTokenSet = u.PersonalToken != ""
+	return &u, nil
+}
+
+// UpdateUserProfile ändert Git-Identität und eigenen GitHub-Token eines
+// Benutzers (nil = unverändert, leerer Token = entfernen). Gibt nil zurück,
+// wenn es den Benutzer nicht gibt.
+func (d *Database) UpdateUserProfile(userID string, req UpdateProfileRequest) (*User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	u, err := scanUser(d.db.QueryRow(`SELECT `+userColumns+` FROM users u WHERE u.id = ?`, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GitName != nil {
+		u.GitName = *req.GitName
+	}
+	if req.GitEmail != nil {
+		u.GitEmail = *req.GitEmail
+	}
+	if req.PersonalToken != nil {
+		u.PersonalToken = *req.PersonalToken
+		u.PersonalTokenSet = u.PersonalToken != ""
+	}
+	token, err := sealUserToken(u.GithubID, "personal_token", u.PersonalToken)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = d.db.Exec(`UPDATE users SET git_name = ?, git_email = ?, personal_token = ? WHERE id = ?`,
+		u.GitName, u.GitEmail, token, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// DeleteSession beendet eine Sitzung (Abmelden).
+func (d *Database) DeleteSession(tokenHash string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+	return err
+}
+
+// ============================================================================
+// Branch-Schutzregel CRUD-Operationen
+// ============================================================================
+
+// GetBranchRules gibt alle Branch-Schutzregeln für ein Projekt zurück.
+func (d *Database) GetBranchRules(projectID string) ([]BranchProtectionRule, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`
+		SELECT id, project_id, branch_pattern, created_at
+		FROM branch_protection_rules
+		WHERE project_id = ?
+		ORDER BY branch_pattern ASC
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []BranchProtectionRule
+	for rows.Next() {
+		var r BranchProtectionRule
+		err := rows.Scan(&r.ID, &r.ProjectID, &r.BranchPattern, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// CreateBranchRule erstellt eine neue Branch-Schutzregel.
+func (d *Database) CreateBranchRule(projectID string, pattern string) (*BranchProtectionRule, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rule := &BranchProtectionRule{
+		ID:            uuid.New().String(),
+		ProjectID:     projectID,
+		BranchPattern: pattern,
+		CreatedAt:     time.Now(),
+	}
+
+	_, err := d.db.Exec(`
+		INSERT INTO branch_protection_rules (id, project_id, branch_pattern, created_at)
+		VALUES (?, ?, ?, ?)
+	`, rule.ID, rule.ProjectID, rule.BranchPattern, rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// UpdateBranchRule ändert das Pattern einer Branch-Schutzregel.
+// Gibt nil zurück, wenn die Regel nicht zum Projekt gehört.
+func (d *Database) UpdateBranchRule(projectID, id, pattern string) (*BranchProtectionRule, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	res, err := d.db.Exec(`
+		UPDATE branch_protection_rules SET branch_pattern = ? WHERE id = ? AND project_id = ?
+	`, pattern, id, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+
+	var r BranchProtectionRule
+	err = d.db.QueryRow(`
+		SELECT id, project_id, branch_pattern, created_at FROM branch_protection_rules WHERE id = ?
+	`, id).Scan(&r.ID, &r.ProjectID, &r.BranchPattern, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// DeleteBranchRule löscht eine Branch-Schutzregel anhand ihrer ID.
+func (d *Database) DeleteBranchRule(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`DELETE FROM branch_protection_rules WHERE id = ?`, id)
+	return err
+}
+
+// ============================================================================
+// Pfad-Schutzregel CRUD-Operationen
+// ============================================================================
+
+// pathRuleColumns sind die Spalten, die scanPathRule erwartet
+const pathRuleColumns = `id, project_id, path_pattern, COALESCE(action, 'revert'), created_at`
+
+func scanPathRule(row interface{ Scan(...interface{}) error }) (*PathProtectionRule, error) {
+	var rule PathProtectionRule
+	if err := row.Scan(&rule.ID, &rule.ProjectID, &rule.PathPattern, &rule.Action, &rule.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetPathRules gibt alle Pfad-Schutzregeln eines Projekts zurück, sortiert nach Pattern.
+func (d *Database) GetPathRules(projectID string) ([]PathProtectionRule, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`SELECT `+pathRuleColumns+` FROM path_protection_rules WHERE project_id = ? ORDER BY path_pattern ASC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []PathProtectionRule{}
+	for rows.Next() {
+		rule, err := scanPathRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// GetPathRule gibt eine Pfad-Schutzregel anhand ihrer ID zurück.
+func (d *Database) GetPathRule(id string) (*PathProtectionRule, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rule, err := scanPathRule(d.db.QueryRow(`SELECT `+pathRuleColumns+` FROM path_protection_rules WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return rule, err
+}
+
+// CreatePathRule speichert eine neue Pfad-Schutzregel. ID und Zeitpunkt werden gesetzt.
+func (d *Database) CreatePathRule(rule *PathProtectionRule) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rule.ID = uuid.New().String()
+	rule.CreatedAt = time.Now()
+
+	_, err := d.db.Exec(`
+		INSERT INTO path_protection_rules (id, project_id, path_pattern, action, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, rule.ID, rule.ProjectID, rule.PathPattern, rule.Action, rule.CreatedAt)
+	return err
+}
+
+// UpdatePathRule speichert Pattern und Aktion einer Pfad-Schutzregel.
+func (d *Database) UpdatePathRule(rule *PathProtectionRule) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE path_protection_rules SET path_pattern = ?, action = ? WHERE id = ?
+	`, rule.PathPattern, rule.Action, rule.ID)
+	return err
+}
+
+// DeletePathRule löscht eine Pfad-Schutzregel anhand ihrer ID.
+func (d *Database) DeletePathRule(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`DELETE FROM path_protection_rules WHERE id = ?`, id)
+	return err
+}
+
+// ============================================================================
+// Projekt-Secret CRUD-Operationen
+// ============================================================================
+
+// GetProjectSecrets gibt alle Secrets eines Projekts zurück (Werte verschlüsselt).
+func (d *Database) GetProjectSecrets(projectID string) ([]ProjectSecret, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`
+		SELECT id, project_id, name, value, COALESCE(inject, 1), created_at, updated_at
+		FROM project_secrets
+		WHERE project_id = ?
+		ORDER BY name ASC
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	secrets := []ProjectSecret{}
+	for rows.Next() {
+		var s ProjectSecret
+		if err := rows.Scan(&s.ID, &s.ProjectID, &s.Name, &s.Value, &s.Inject, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets, rows.Err()
+}
+
+// GetProjectSecret gibt ein einzelnes Secret anhand seiner ID zurück.
+func (d *Database) GetProjectSecret(id string) (*ProjectSecret, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var s ProjectSecret
+	err := d.db.QueryRow(`
+		SELECT id, project_id, name, value, COALESCE(inject, 1), created_at, updated_at
+		FROM project_secrets WHERE id = ?
+	`, id).Scan(&s.ID, &s.ProjectID, &s.Name, &s.Value, &s.Inject, &s.CreatedAt, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// secretNameTaken prüft, ob im Projekt bereits ein anderes Secret diesen Namen trägt
+func (d *Database) secretNameTaken(projectID, name, exceptID string) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM project_secrets WHERE project_id = ? AND name = ? AND id != ?`,
+		projectID, name, exceptID).Scan(&count)
+	return count > 0, err
+}
+
+// CreateProjectSecret speichert ein neues Secret. ID und Zeitstempel werden gesetzt.
+func (d *Database) CreateProjectSecret(secret *ProjectSecret) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	taken, err := d.secretNameTaken(secret.ProjectID, secret.Name, "")
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errSecretExists
+	}
+
+	secret.ID = uuid.New().String()
+	secret.CreatedAt = time.Now()
+	secret.UpdatedAt = secret.CreatedAt
+
+	_, err = d.db.Exec(`
+		INSERT INTO project_secrets (id, project_id, name, value, inject, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, secret.ID, secret.ProjectID, secret.Name, secret.Value, secret.Inject, secret.CreatedAt, secret.UpdatedAt)
+	return err
+}
+
+// UpdateProjectSecret speichert Name, Wert und Inject-Flag eines Secrets.
+func (d *Database) UpdateProjectSecret(secret *ProjectSecret) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	taken, err := d.secretNameTaken(secret.ProjectID, secret.Name, secret.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errSecretExists
+	}
+
+	secret.UpdatedAt = time.Now()
+	_, err = d.db.Exec(`
+		UPDATE project_secrets SET name = ?, value = ?, inject = ?, updated_at = ? WHERE id = ?
+	`, secret.Name, secret.Value, secret.Inject, secret.UpdatedAt, secret.ID)
+	return err
+}
+
+// DeleteProjectSecret löscht ein Secret anhand seiner ID.
+func (d *Database) DeleteProjectSecret(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`DELETE FROM project_secrets WHERE id = ?`, id)
+	return err
+}
+
+// ============================================================================
+// Deploy-Key-Operationen
+// ============================================================================
+
+// deployKeyColumns sind die Spalten, die scanDeployKey erwartet
+const deployKeyColumns = `project_id, public_key, fingerprint, private_key, created_at`
+
+func scanDeployKey(row interface{ Scan(...interface{}) error }) (*DeployKey, error) {
+	var key DeployKey
+	if err := row.Scan(&key.ProjectID, &key.PublicKey, &key.Fingerprint, &key.PrivateKey, &key.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetDeployKey gibt den Deploy-Key eines Projekts zurück (nil wenn keiner).
+func (d *Database) GetDeployKey(projectID string) (*DeployKey, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	key, err := scanDeployKey(d.db.QueryRow(`SELECT `+deployKeyColumns+` FROM deploy_keys WHERE project_id = ?`, projectID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+// GetDeployKeys gibt die Deploy-Keys aller Projekte zurück.
+func (d *Database) GetDeployKeys() ([]DeployKey, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`SELECT ` + deployKeyColumns + ` FROM deploy_keys ORDER BY project_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []DeployKey
+	for rows.Next() {
+		key, err := scanDeployKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// SaveDeployKey speichert den Deploy-Key eines Projekts und ersetzt einen
+// vorhandenen.
+func (d *Database) SaveDeployKey(key *DeployKey) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.db.Exec(`DELETE FROM deploy_keys WHERE project_id = ?`, key.ProjectID); err != nil {
+		return err
+	}
+	key.CreatedAt = time.Now()
+	_, err := d.db.Exec(`
+		INSERT INTO deploy_keys (project_id, public_key, fingerprint, private_key, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, key.ProjectID, key.PublicKey, key.Fingerprint, key.PrivateKey, key.CreatedAt)
+	return err
+}
+
+// DeleteDeployKey löscht den Deploy-Key eines Projekts. Gibt sql.ErrNoRows
+// zurück, wenn es keinen hat.
+func (d *Database) DeleteDeployKey(projectID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.db.Exec(`DELETE FROM deploy_keys WHERE project_id = ?`, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ============================================================================
+// Release-Operationen
+// ============================================================================
+
+// releaseColumns sind die Spalten, die scanRelease erwartet
+const releaseColumns = `id, project_id, tag, COALESCE(name, ''), COALESCE(previous_tag, ''), COALESCE(notes, ''),
+	COALESCE(commit_count, 0), COALESCE(commit_hash, ''), COALESCE(task_id, ''), COALESCE(status, ''),
+	COALESCE(error, ''), COALESCE(github_url, ''), created_at, updated_at`
+
+func scanRelease(row interface{ Scan(...interface{}) error }) (*Release, error) {
+	var r Release
+	err := row.Scan(&r.ID, &r.ProjectID, &r.Tag, &r.Name, &r.PreviousTag, &r.Notes,
+		&r.CommitCount, &r.CommitHash, &r.TaskID, &r.Status,
+		&r.Error, &r.GithubURL, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetReleasesByProject gibt die Releases eines Projekts zurück, neueste zuerst.
+func (d *Database) GetReleasesByProject(projectID string) ([]Release, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`SELECT `+releaseColumns+` FROM releases WHERE project_id = ? ORDER BY created_at DESC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := []Release{}
+	for rows.Next() {
+		r, err := scanRelease(rows)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, *r)
+	}
+	return releases, rows.Err()
+}
+
+// GetRelease gibt ein einzelnes Release anhand seiner ID zurück.
+func (d *Database) GetRelease(id string) (*Release, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	r, err := scanRelease(d.db.QueryRow(`SELECT `+releaseColumns+` FROM releases WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+// CreateRelease speichert ein neues Release. ID und Zeitstempel werden gesetzt.
+func (d *Database) CreateRelease(release *Release) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	release.ID = uuid.New().String()
+	release.CreatedAt = time.Now()
+	release.UpdatedAt = release.CreatedAt
+
+	_, err := d.db.Exec(`
+		INSERT INTO releases (id, project_id, tag, name, previous_tag, notes, commit_count,
+		                      commit_hash, task_id, status, error, github_url, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, release.ID, release.ProjectID, release.Tag, release.Name, release.PreviousTag, release.Notes, release.CommitCount,
+		release.CommitHash, release.TaskID, release.Status, release.Error, release.GithubURL, release.CreatedAt, release.UpdatedAt)
+	return err
+}
+
+// UpdateRelease speichert Notes, Status und Ergebnis eines Releases.
+func (d *Database) UpdateRelease(release *Release) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	release.UpdatedAt = time.Now()
+	_, err := d.db.Exec(`
+		UPDATE releases SET name = ?, notes = ?, commit_hash = ?, task_id = ?, status = ?,
+		                    error = ?, github_url = ?, updated_at = ?
+		WHERE id = ?
+	`, release.Name, release.Notes, release.CommitHash, release.TaskID, release.Status,
+		release.Error, release.GithubURL, release.UpdatedAt, release.ID)
+	return err
+}
+
+// ============================================================================
+// Deployment-Operationen
+// ============================================================================
+
+// deploymentColumns sind die Spalten, die scanDeployment erwartet
+const deploymentColumns = `id, project_id, COALESCE(task_id, ''), COALESCE(environment, ''), COALESCE(commit_hash, ''),
+	command, COALESCE(rollback_of, ''), COALESCE(status, ''), COALESCE(exit_code, 0), COALESCE(output, ''),
+	started_at, finished_at`
+
+func scanDeployment(row interface{ Scan(...interface{}) error }) (*Deployment, error) {
+	var dep Deployment
+	var finishedAt sql.NullTime
+	err := row.Scan(&dep.ID, &dep.ProjectID, &dep.TaskID, &dep.Environment, &dep.CommitHash,
+		&dep.Command, &dep.RollbackOf, &dep.Status, &dep.ExitCode, &dep.Output,
+		&dep.StartedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		dep.FinishedAt = &finishedAt.Time
+	}
+	return &dep, nil
+}
+
+// GetDeploymentsByProject gibt die letzten limit Deployments eines Projekts
+// zurück, neueste zuerst. environment filtert auf eine Umgebung ("" = alle).
+func (d *Database) GetDeploymentsByProject(projectID, environment string, limit int) ([]Deployment, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	query := `SELECT ` + deploymentColumns + ` FROM deployments WHERE project_id = ?`
+	args := []interface{}{projectID}
+	if environment != "" {
+		query += ` AND environment = ?`
+		args = append(args, environment)
+	}
+	query += ` ORDER BY started_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deployments := []Deployment{}
+	for rows.Next() {
+		dep, err := scanDeployment(rows)
+		if err != nil {
+			return nil, err
+		}
+		deployments = append(deployments, *dep)
+	}
+	return deployments, rows.Err()
+}
+
+// GetDeployment gibt ein einzelnes Deployment anhand seiner ID zurück.
+func (d *Database) GetDeployment(id string) (*Deployment, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	dep, err := scanDeployment(d.db.QueryRow(`SELECT `+deploymentColumns+` FROM deployments WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return dep, err
+}
+
+// CreateDeployment speichert ein gestartetes Deployment. ID, Status und Startzeit werden gesetzt.
+func (d *Database) CreateDeployment(deployment *Deployment) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deployment.ID = uuid.New().String()
+	deployment.Status = DeploymentRunning
+	deployment.StartedAt = time.Now()
+
+	_, err := d.db.Exec(`
+		INSERT INTO deployments (id, project_id, task_id, environment, commit_hash, command, rollback_of, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, deployment.ID, deployment.ProjectID, deployment.TaskID, deployment.Environment, deployment.CommitHash,
+		deployment.Command, deployment.RollbackOf, deployment.Status, deployment.StartedAt)
+	return err
+}
+
+// FinishDeployment speichert Ergebnis und Ausgabe eines Deployments und setzt die Endzeit.
+func (d *Database) FinishDeployment(deployment *Deployment) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	deployment.FinishedAt = &now
+	_, err := d.db.Exec(`
+		UPDATE deployments SET status = ?, exit_code = ?, output = ?, finished_at = ? WHERE id = ?
+	`, deployment.Status, deployment.ExitCode, deployment.Output, now, deployment.ID)
+	return err
+}
+
+// InterruptRunningDeployments markiert Deployments, die beim letzten Beenden
+// des Servers noch liefen, als interrupted. Gibt deren Anzahl zurück.
+func (d *Database) InterruptRunningDeployments() (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	res, err := d.db.Exec(`
+		UPDATE deployments SET status = ?, finished_at = ? WHERE status = ?
+	`, DeploymentInterrupted, time.Now(), DeploymentRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ============================================================================
+// Lauf-Historie
+// ============================================================================
+
+// taskRunColumns sind die Spalten, die scanTaskRun erwartet
+const taskRunColumns = `id, task_id, run_number, COALESCE(run_trigger, ''), COALESCE(outcome, ''), COALESCE(error, ''),
+	COALESCE(failure_class, ''), COALESCE(iterations, 0), COALESCE(start_commit, ''), COALESCE(end_commit, ''), COALESCE(turns, 0),
+	COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), COALESCE(cost_usd, 0), started_at, finished_at`
+
+func scanTaskRun(row interface{ Scan(...interface{}) error }) (*TaskRun, error) {
+	var run TaskRun
+	var finishedAt sql.NullTime
+	err := row.Scan(&run.ID, &run.TaskID, &run.Number, &run.Trigger, &run.Outcome, &run.Error,
+		&run.FailureClass, &run.Iterations, &run.StartCommit, &run.EndCommit, &run.Turns,
+		&run.InputTokens, &run.OutputTokens, &run.CostUSD, &run.StartedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	run.LogsURL = fmt.Sprintf("/api/tasks/%s/runs/%d/logs", run.TaskID, run.Number)
+	return &run, nil
+}
+
+// GetTaskRuns gibt alle Läufe eines Tasks zurück, ältester zuerst (ohne Logs).
+func (d *Database) GetTaskRuns(taskID string) ([]TaskRun, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`SELECT `+taskRunColumns+` FROM task_runs WHERE task_id = ? ORDER BY run_number ASC`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []TaskRun{}
+	for rows.Next() {
+		run, err := scanTaskRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// GetTaskRunLogs liefert die Logs eines Laufs. false = den Lauf gibt es nicht.
+func (d *Database) GetTaskRunLogs(taskID string, number int) (string, bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var logs storedLogs
+	err := d.db.QueryRow(`SELECT logs FROM task_runs WHERE task_id = ? AND run_number = ?`, taskID, number).Scan(&logs)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return string(logs), err == nil, err
+}
+
+// CreateTaskRun speichert einen gestarteten Lauf. ID, Nummer, Ergebnis und Startzeit werden gesetzt.
+// Ein noch offener Lauf des Tasks gilt damit als unterbrochen.
+func (d *Database) CreateTaskRun(run *TaskRun) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	run.ID = uuid.New().String()
+	run.Outcome = RunRunning
+	run.StartedAt = time.Now()
+
+	if _, err := d.db.Exec(`
+		UPDATE task_runs SET outcome = ?, finished_at = ? WHERE task_id = ? AND finished_at IS NULL
+	`, RunInterrupted, run.StartedAt, run.TaskID); err != nil {
+		return err
+	}
+	if err := d.db.QueryRow(`
+		SELECT COALESCE(MAX(run_number), 0) + 1 FROM task_runs WHERE task_id = ?
+	`, run.TaskID).Scan(&run.Number); err != nil {
+		return err
+	}
+	run.LogsURL = fmt.Sprintf("/api/tasks/%s/runs/%d/logs", run.TaskID, run.Number)
+
+	_, err := d.db.Exec(`
+		INSERT INTO task_runs (id, task_id, run_number, run_trigger, outcome, start_commit, logs, messages, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, '', '', ?)
+	`, run.ID, run.TaskID, run.Number, run.Trigger, run.Outcome, run.StartCommit, run.StartedAt)
+	return err
+}
+
+// FinishTaskRun speichert Ergebnis, Verbrauch und die (ggf. gekürzten) Logs eines Laufs und setzt die Endzeit.
+func (d *Database) FinishTaskRun(run *TaskRun, logs string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	run.FinishedAt = &now
+	_, err := d.db.Exec(`
+		UPDATE task_runs SET outcome = ?, error = ?, failure_class = ?, iterations = ?, end_commit = ?, turns = ?,
+		                     input_tokens = ?, output_tokens = ?, cost_usd = ?, logs = ?, finished_at = ?
+		WHERE id = ?
+	`, run.Outcome, run.Error, run.FailureClass, run.Iterations, run.EndCommit, run.Turns,
+		run.InputTokens, run.OutputTokens, run.CostUSD, logs, now, run.ID)
+	return err
+}
+
+// AddTaskRunMessage hängt eine an Claude gesendete Nachricht an einen Lauf an.
+func (d *Database) AddTaskRunMessage(runID string, msg RunMessage) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var messages RunMessages
+	if err := d.db.QueryRow(`SELECT messages FROM task_runs WHERE id = ?`, runID).Scan(&messages); err != nil {
+		return err
+	}
+	messages = append(messages, msg)
+	_, err := d.db.Exec(`UPDATE task_runs SET messages = ? WHERE id = ?`, messages, runID)
+	return err
+}
+
+// GetTaskRunMessages liefert die an Claude gesendeten Nachrichten eines Laufs, den Prompt zuerst.
+func (d *Database) GetTaskRunMessages(taskID string, number int) ([]RunMessage, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var messages RunMessages
+	err := d.db.QueryRow(`SELECT messages FROM task_runs WHERE task_id = ? AND run_number = ?`, taskID, number).Scan(&messages)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return messages, err
+}
+
+// InterruptRunningTaskRuns markiert Läufe, die beim letzten Beenden des Servers
+// noch liefen, als interrupted. Gibt deren Anzahl zurück.
+func (d *Database) InterruptRunningTaskRuns() (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	res, err := d.db.Exec(`
+		UPDATE task_runs SET outcome = ?, finished_at = ? WHERE finished_at IS NULL
+	`, RunInterrupted, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ============================================================================
+// Konflikt-Operationen
+// ============================================================================
+
+// taskConflictColumns sind die Spalten, die scanTaskConflict erwartet
+const taskConflictColumns = `id, task_id, COALESCE(resolution_task_id, ''), stage, COALESCE(branch, ''),
+	COALESCE(upstream, ''), COALESCE(upstream_commit, ''), COALESCE(files, ''), COALESCE(status, ''),
+	created_at, resolved_at`
+
+func scanTaskConflict(row interface{ Scan(...interface{}) error }) (*TaskConflict, error) {
+	var c TaskConflict
+	var files string
+	var resolvedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.TaskID, &c.ResolutionTaskID, &c.Stage, &c.Branch,
+		&c.Upstream, &c.UpstreamCommit, &files, &c.Status,
+		&c.CreatedAt, &resolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.Files = []string{}
+	if files != "" {
+		c.Files = strings.Split(files, "\n")
+	}
+	if resolvedAt.Valid {
+		c.ResolvedAt = &resolvedAt.Time
+	}
+	return &c, nil
+}
+
+// GetTaskConflicts gibt die Konflikte zurück, die ein Task hatte oder auflöst, neueste zuerst.
+func (d *Database) GetTaskConflicts(taskID string) ([]TaskConflict, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`SELECT `+taskConflictColumns+` FROM task_conflicts
+		WHERE task_id = ? OR resolution_task_id = ? ORDER BY created_at DESC`, taskID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conflicts := []TaskConflict{}
+	for rows.Next() {
+		c, err := scanTaskConflict(rows)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, *c)
+	}
+	return conflicts, rows.Err()
+}
+
+// GetOpenConflictByResolutionTask gibt den offenen Konflikt zurück, den ein Task auflösen soll.
+func (d *Database) GetOpenConflictByResolutionTask(resolutionTaskID string) (*TaskConflict, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	c, err := scanTaskConflict(d.db.QueryRow(`SELECT `+taskConflictColumns+` FROM task_conflicts
+		WHERE resolution_task_id = ? AND status = ?`, resolutionTaskID, ConflictOpen))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// CreateTaskConflict speichert einen offenen Konflikt. ID, Status und Zeitpunkt werden gesetzt.
+func (d *Database) CreateTaskConflict(conflict *TaskConflict) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	conflict.ID = uuid.New().String()
+	conflict.Status = ConflictOpen
+	conflict.CreatedAt = time.Now()
+
+	_, err := d.db.Exec(`
+		INSERT INTO task_conflicts (id, task_id, resolution_task_id, stage, branch, upstream,
+		                            upstream_commit, files, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, conflict.ID, conflict.TaskID, conflict.ResolutionTaskID, conflict.Stage, conflict.Branch, conflict.Upstream,
+		conflict.UpstreamCommit, strings.Join(conflict.Files, "\n"), conflict.Status, conflict.CreatedAt)
+	return err
+}
+
+// ResolveTaskConflict markiert einen Konflikt als aufgelöst.
+func (d *Database) ResolveTaskConflict(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE task_conflicts SET status = ?, resolved_at = ? WHERE id = ?
+	`, ConflictResolved, time.Now(), id)
+	return err
+}
+
+// ============================================================================
+// Deploy-Umgebungs-Operationen
+// ============================================================================
+
+// projectEnvironmentColumns sind die Spalten, die scanProjectEnvironment erwartet
+const projectEnvironmentColumns = `id, project_id, name, COALESCE(deploy_command, ''), COALESCE(deploy_timeout, 0), created_at, updated_at`
+
+func scanProjectEnvironment(row interface{ Scan(...interface{}) error }) (*ProjectEnvironment, error) {
+	var env ProjectEnvironment
+	err := row.Scan(&env.ID, &env.ProjectID, &env.Name, &env.DeployCommand, &env.DeployTimeout, &env.CreatedAt, &env.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+// GetProjectEnvironments gibt die Deploy-Umgebungen eines Projekts zurück, sortiert nach Name.
+func (d *Database) GetProjectEnvironments(projectID string) ([]ProjectEnvironment, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`SELECT `+projectEnvironmentColumns+` FROM project_environments WHERE project_id = ? ORDER BY name ASC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	envs := []ProjectEnvironment{}
+	for rows.Next() {
+		env, err := scanProjectEnvironment(rows)
+		if err != nil {
+			return nil, err
+		}
+		envs = append(envs, *env)
+	}
+	return envs, rows.Err()
+}
+
+// GetProjectEnvironment gibt eine Deploy-Umgebung anhand ihrer ID zurück.
+func (d *Database) GetProjectEnvironment(id string) (*ProjectEnvironment, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	env, err := scanProjectEnvironment(d.db.QueryRow(`SELECT `+projectEnvironmentColumns+` FROM project_environments WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return env, err
+}
+
+// GetProjectEnvironmentByName gibt die Deploy-Umgebung eines Projekts mit dem Namen zurück.
+func (d *Database) GetProjectEnvironmentByName(projectID, name string) (*ProjectEnvironment, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	env, err := scanProjectEnvironment(d.db.QueryRow(`SELECT `+projectEnvironmentColumns+` FROM project_environments WHERE project_id = ? AND name = ?`, projectID, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return env, err
+}
+
+// environmentNameTaken prüft, ob ein Projekt schon eine andere Umgebung mit dem Namen hat.
+// Der Aufrufer muss d.mu halten.
+func (d *Database) environmentNameTaken(projectID, name, exceptID string) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM project_environments WHERE project_id = ? AND name = ? AND id != ?`,
+		projectID, name, exceptID).Scan(&count)
+	return count > 0, err
+}
+
+// CreateProjectEnvironment speichert eine neue Deploy-Umgebung.
+// Gibt errEnvironmentExists zurück, wenn der Name im Projekt schon vergeben ist.
+func (d *Database) CreateProjectEnvironment(env *ProjectEnvironment) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	taken, err := d.environmentNameTaken(env.ProjectID, env.Name, "")
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errEnvironmentExists
+	}
+
+	env.ID = uuid.New().String()
+	env.CreatedAt = time.Now()
+	env.UpdatedAt = env.CreatedAt
+
+	_, err = d.db.Exec(`
+		INSERT INTO project_environments (id, project_id, name, deploy_command, deploy_timeout, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, env.ID, env.ProjectID, env.Name, env.DeployCommand, env.DeployTimeout, env.CreatedAt, env.UpdatedAt)
+	return err
+}
+
+// UpdateProjectEnvironment speichert Name, Befehle und Timeout einer Deploy-Umgebung.
+func (d *Database) UpdateProjectEnvironment(env *ProjectEnvironment) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	taken, err := d.environmentNameTaken(env.ProjectID, env.Name, env.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errEnvironmentExists
+	}
+
+	env.UpdatedAt = time.Now()
+	_, err = d.db.Exec(`
+		UPDATE project_environments SET name = ?, deploy_command = ?, deploy_timeout = ?, updated_at = ? WHERE id = ?
+	`, env.Name, env.DeployCommand, env.DeployTimeout, env.UpdatedAt, env.ID)
+	return err
+}
+
+// DeleteProjectEnvironment löscht eine Deploy-Umgebung. Ihre Deployments bleiben in der Historie.
+func (d *Database) DeleteProjectEnvironment(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`DELETE FROM project_environments WHERE id = ?`, id)
+	return err
+}
+
+// ============================================================================
+// MCP-Server-Operationen
+// ============================================================================
+
+// projectMCPServerColumns sind die Spalten, die scanProjectMCPServer erwartet
+const projectMCPServerColumns = `id, project_id, name, command, COALESCE(args, ''), COALESCE(env, ''), COALESCE(enabled, 1), created_at, updated_at`
+
+func scanProjectMCPServer(row interface{ Scan(...interface{}) error }) (*ProjectMCPServer, error) {
+	var server ProjectMCPServer
+	err := row.Scan(&server.ID, &server.ProjectID, &server.Name, &server.Command, &server.Args, &server.Env,
+		&server.Enabled, &server.CreatedAt, &server.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// GetProjectMCPServers gibt die MCP-Server eines Projekts zurück, sortiert nach Name.
+func (d *Database) GetProjectMCPServers(projectID string) ([]ProjectMCPServer, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`SELECT `+projectMCPServerColumns+` FROM project_mcp_servers WHERE project_id = ? ORDER BY name ASC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	servers := []ProjectMCPServer{}
+	for rows.Next() {
+		server, err := scanProjectMCPServer(rows)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, *server)
+	}
+	return servers, rows.Err()
+}
+
+// GetProjectMCPServer gibt einen MCP-Server anhand seiner ID zurück.
+func (d *Database) GetProjectMCPServer(id string) (*ProjectMCPServer, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	server, err := scanProjectMCPServer(d.db.QueryRow(`SELECT `+projectMCPServerColumns+` FROM project_mcp_servers WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return server, err
+}
+
+// mcpServerNameTaken prüft, ob ein Projekt schon einen anderen MCP-Server mit dem Namen hat.
+// Der Aufrufer muss d.mu halten.
+func (d *Database) mcpServerNameTaken(projectID, name, exceptID string) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM project_mcp_servers WHERE project_id = ? AND name = ? AND id != ?`,
+		projectID, name, exceptID).Scan(&count)
+	return count > 0, err
+}
+
+// CreateProjectMCPServer speichert einen neuen MCP-Server.
+// Gibt errMCPServerExists zurück, wenn der Name im Projekt schon vergeben ist.
+func (d *Database) CreateProjectMCPServer(server *ProjectMCPServer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	taken, err := d.mcpServerNameTaken(server.ProjectID, server.Name, "")
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errMCPServerExists
+	}
+
+	server.ID = uuid.New().String()
+	server.CreatedAt = time.Now()
+	server.UpdatedAt = server.CreatedAt
+
+	_, err = d.db.Exec(`
+		INSERT INTO project_mcp_servers (id, project_id, name, command, args, env, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, server.ID, server.ProjectID, server.Name, server.Command, server.Args, server.Env, server.Enabled, server.CreatedAt, server.UpdatedAt)
+	return err
+}
+
+// UpdateProjectMCPServer speichert Name, Befehl, Argumente, Umgebung und Aktivierung eines MCP-Servers.
+func (d *Database) UpdateProjectMCPServer(server *ProjectMCPServer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	taken, err := d.mcpServerNameTaken(server.ProjectID, server.Name, server.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errMCPServerExists
+	}
+
+	server.UpdatedAt = time.Now()
+	_, err = d.db.Exec(`
+		UPDATE project_mcp_servers SET name = ?, command = ?, args = ?, env = ?, enabled = ?, updated_at = ? WHERE id = ?
+	`, server.Name, server.Command, server.Args, server.Env, server.Enabled, server.UpdatedAt, server.ID)
+	return err
+}
+
+// DeleteProjectMCPServer löscht einen MCP-Server.
+func (d *Database) DeleteProjectMCPServer(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`DELETE FROM project_mcp_servers WHERE id = ?`, id)
+	return err
+}
+
+// ============================================================================
+// Konfigurations-Operationen
+// ============================================================================
+
+// GetConfig gibt die globale Konfiguration zurück.
+// Es existiert nur ein Datensatz mit id=1.
+func (d *Database) GetConfig() (*Config, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var c Config
+	// Nullable Felder für optionale Spalten
+	var projectsBaseDir, githubToken, defaultBranch, pushStrategy, attachmentTypes sql.NullString
+	var autoCommit, autoPush sql.NullBool
+	var defaultPriority, autoArchiveDays sql.NullInt64
+
+	err := d.db.QueryRow(`
+		SELECT id, default_project_dir, default_max_iterations, claude_command,
+		       COALESCE(projects_base_dir, ''), COALESCE(github_token, ''),
+		       COALESCE(auto_commit, 0), COALESCE(auto_push, 0),
+		       COALESCE(default_branch, 'main'), COALESCE(default_priority, 2),
+		       COALESCE(auto_archive_days, 0), COALESCE(push_strategy, 'manual'),
+		       COALESCE(attachment_types, ''),
+		       COALESCE(jira_url, ''), COALESCE(jira_user, ''), COALESCE(jira_token, ''),
+		       COALESCE(linear_token, ''), COALESCE(claude_settings, ''), COALESCE(log_retention, ''),
+		       COALESCE(max_concurrent_tasks, 1), COALESCE(blocked_triage, 1),
+		       COALESCE(retry_policy, ''), COALESCE(budget, ''), COALESCE(github_user_tokens, 0),
+		       COALESCE(github_endpoints, ''), COALESCE(signing, '')
+		FROM config WHERE id = 1
+	`).Scan(&c.ID, &c.DefaultProjectDir, &c.DefaultMaxIterations, &c.ClaudeCommand,
+		&projectsBaseDir, &githubToken, &autoCommit, &autoPush,
+		&defaultBranch, &defaultPriority, &autoArchiveDays, &pushStrategy, &attachmentTypes,
+		&c.JiraURL, &c.JiraUser, &c.JiraToken, &c.LinearToken, &c.Claude, &c.LogRetention,
+		&c.MaxConcurrentTasks, &c.BlockedTriage, &c.RetryPolicy, &c.Budget, &c.GithubUserTokens, &c.GitHub, &c.Signing)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nullable Werte übertragen
+	if projectsBaseDir.Valid {
+		c.ProjectsBaseDir = projectsBaseDir.String
+	}
+	if githubToken.Valid {
+		c.GithubToken = githubToken.String
+	}
+	if autoCommit.Valid {
+		c.AutoCommit = autoCommit.Bool
+	}
+	if autoPush.Valid {
+		c.AutoPush = autoPush.Bool
+	}
+	if defaultBranch.Valid {
+		c.DefaultBranch = defaultBranch.String
+	}
+	if defaultPriority.Valid {
+		c.DefaultPriority = int(defaultPriority.Int64)
+	}
+	if autoArchiveDays.Valid {
+		c.AutoArchiveDays = int(autoArchiveDays.Int64)
+	}
+	if pushStrategy.Valid {
+		c.PushStrategy = pushStrategy.String
+	}
+	if attachmentTypes.Valid {
+		c.AttachmentTypes = attachmentTypes.String
+	}
+	if c.AttachmentTypes == "" {
+		c.AttachmentTypes = DefaultAttachmentTypes
+	}
+	// Zugangsdaten entschlüsseln (siehe credentials.go)
+	c.openCredentials()
+	c.setFilesystemAccess()
+	return &c, nil
+}
+
+// UpdateConfig aktualisiert die globale Konfiguration.
+// Nur nicht-nil Felder werden aktualisiert.
+func (d *Database) UpdateConfig(req UpdateConfigRequest) (*Config, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Aktuelle Config laden
+	var c Config
+	var projectsBaseDir, githubToken, defaultBranch, pushStrategy, attachmentTypes sql.NullString
+	var autoCommit, autoPush sql.NullBool
+	var defaultPriority, autoArchiveDays sql.NullInt64
+
+	err := d.db.QueryRow(`
+		SELECT id, default_project_dir, default_max_iterations, claude_command,
+		       COALESCE(projects_base_dir, ''), COALESCE(github_token, ''),
+		       COALESCE(auto_commit, 0), COALESCE(auto_push, 0),
+		       COALESCE(default_branch, 'main'), COALESCE(default_priority, 2),
+		       COALESCE(auto_archive_days, 0), COALESCE(push_strategy, 'manual'),
+		       COALESCE(attachment_types, ''),
+		       COALESCE(jira_url, ''), COALESCE(jira_user, ''), COALESCE(jira_token, ''),
+		       COALESCE(linear_token, ''), COALESCE(claude_settings, ''), COALESCE(log_retention, ''),
+		       COALESCE(max_concurrent_tasks, 1), COALESCE(blocked_triage, 1),
+		       COALESCE(retry_policy, ''), COALESCE(budget, ''), COALESCE(github_user_tokens, 0),
+		       COALESCE(github_endpoints, ''), COALESCE(signing, '')
+		FROM config WHERE id = 1
+	`).Scan(&c.ID, &c.DefaultProjectDir, &c.DefaultMaxIterations, &c.ClaudeCommand,
+		&projectsBaseDir, &githubToken, &autoCommit, &autoPush,
+		&defaultBranch, &defaultPriority, &autoArchiveDays, &pushStrategy, &attachmentTypes,
+		&c.JiraURL, &c.JiraUser, &c.JiraToken, &c.LinearToken, &c.Claude, &c.LogRetention,
+		&c.MaxConcurrentTasks, &c.BlockedTriage, &c.RetryPolicy, &c.Budget, &c.GithubUserTokens, &c.GitHub, &c.Signing)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nullable Werte übertragen
+	if projectsBaseDir.Valid {
+		c.ProjectsBaseDir = projectsBaseDir.String
+	}
+	if githubToken.Valid {
+		c.GithubToken = githubToken.String
+	}
+	if autoCommit.Valid {
+		c.AutoCommit = autoCommit.Bool
+	}
+	if autoPush.Valid {
+		c.AutoPush = autoPush.Bool
+	}
+	if defaultBranch.Valid {
+		c.DefaultBranch = defaultBranch.String
+	}
+	if defaultPriority.Valid {
+		c.DefaultPriority = int(defaultPriority.Int64)
+	}
+	if autoArchiveDays.Valid {
+		c.AutoArchiveDays = int(autoArchiveDays.Int64)
+	}
+	if pushStrategy.Valid {
+		c.PushStrategy = pushStrategy.String
+	}
+	if attachmentTypes.Valid {
+		c.AttachmentTypes = attachmentTypes.String
+	}
+
+	// Updates anwenden
+	if req.DefaultProjectDir != nil {
+		c.DefaultProjectDir = *req.DefaultProjectDir
+	}
+	if req.DefaultMaxIterations != nil {
+		c.DefaultMaxIterations = *req.DefaultMaxIterations
+	}
+	if req.ClaudeCommand != nil {
+		c.ClaudeCommand = *req.ClaudeCommand
+	}
+	if req.ProjectsBaseDir != nil {
+		c.ProjectsBaseDir = *req.ProjectsBaseDir
+	}
+	if req.AutoCommit != nil {
+		c.AutoCommit = *req.AutoCommit
+	}
+	if req.AutoPush != nil {
+		c.AutoPush = *req.AutoPush
+	}
+	if req.DefaultBranch != nil {
+		c.DefaultBranch = *req.DefaultBranch
+	}
+	if req.DefaultPriority != nil {
+		c.DefaultPriority = *req.DefaultPriority
+	}
+	if req.AutoArchiveDays != nil {
+		c.AutoArchiveDays = *req.AutoArchiveDays
+	}
+	if req.MaxConcurrentTasks != nil {
+		c.MaxConcurrentTasks = *req.MaxConcurrentTasks
+	}
+	if req.BlockedTriage != nil {
+		c.BlockedTriage = *req.BlockedTriage
+	}
+	if req.GithubUserTokens != nil {
+		c.GithubUserTokens = *req.GithubUserTokens
+	}
+	if req.GitHub != nil {
+		c.GitHub = *req.GitHub
+	}
+	if req.Signing != nil {
+		c.Signing = *req.Signing
+	}
+	if req.AttachmentTypes != nil {
+		c.AttachmentTypes = *req.AttachmentTypes
+	}
+	if req.JiraURL != nil {
+		c.JiraURL = *req.JiraURL
+	}
+	if req.JiraUser != nil {
+		c.JiraUser = *req.JiraUser
+	}
+	if req.Claude != nil {
+		c.Claude = *req.Claude
+	}
+	if req.LogRetention != nil {
+		c.LogRetention = *req.LogRetention
+	}
+	if req.RetryPolicy != nil {
+		c.RetryPolicy = *req.RetryPolicy
+	}
+	if req.Budget != nil {
+		c.Budget = *req.Budget
+	}
+	// Zugangsdaten verschlüsselt speichern, nicht geänderte bleiben wie gespeichert
+	if err := c.sealCredentials(req); err != nil {
+		return nil, err
+	}
+
+	_, err = d.db.Exec(`
+		UPDATE config SET
+			default_project_dir = ?,
+			default_max_iterations = ?,
+			claude_command = ?,
+			projects_base_dir = ?,
+			github_token = ?,
+			auto_commit = ?,
+			auto_push = ?,
+			default_branch = ?,
+			default_priority = ?,
+			auto_archive_days = ?,
+			push_strategy = ?,
+			attachment_types = ?,
+			jira_url = ?,
+			jira_user = ?,
+			jira_token = ?,
+			linear_token = ?,
+			claude_settings = ?,
+			log_retention = ?,
+			max_concurrent_tasks = ?,
+			blocked_triage = ?,
+			retry_policy = ?,
+			budget = ?,
+			github_user_tokens = ?,
+			github_endpoints = ?,
+			signing = ?
+		WHERE id = 1
+	`, c.DefaultProjectDir, c.DefaultMaxIterations, c.ClaudeCommand, c.ProjectsBaseDir, c.GithubToken,
+		c.AutoCommit, c.AutoPush, c.DefaultBranch, c.DefaultPriority, c.AutoArchiveDays, c.PushStrategy,
+		c.AttachmentTypes, c.JiraURL, c.JiraUser, c.JiraToken, c.LinearToken, c.Claude, c.LogRetention, c.MaxConcurrentTasks,
+		c.BlockedTriage, c.RetryPolicy, c.Budget, c.GithubUserTokens, c.GitHub, c.Signing)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.AttachmentTypes == "" {
+		c.AttachmentTypes = DefaultAttachmentTypes
+	}
+	c.openCredentials()
+	c.setFilesystemAccess()
+	return &c, nil
+}
+
+// ============================================================================
+// Kommentar CRUD-Operationen
+// ============================================================================
+
+// commentColumns ist die Spaltenliste, die scanComment erwartet
+const commentColumns = `id, task_id, COALESCE(author, ''), body, COALESCE(sent_to_ralph, 0), sent_at, created_at, updated_at`
+
+// scanComment liest eine Zeile mit commentColumns
+func scanComment(row interface{ Scan(...interface{}) error }) (*TaskComment, error) {
+	var c TaskComment
+	var sentAt sql.NullTime
+	if err := row.Scan(&c.ID, &c.TaskID, &c.Author, &c.Body, &c.SentToRalph, &sentAt, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if sentAt.Valid {
+		c.SentAt = &sentAt.Time
+	}
+	return &c, nil
+}
+
+// queryComments liest alle Kommentare einer Abfrage (Aufrufer hält d.mu)
+func (d *Database) queryComments(query string, args ...interface{}) ([]TaskComment, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []TaskComment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, *c)
+	}
+
+	return comments, rows.Err()
+}
+
+// GetComments gibt den Kommentar-Thread eines Tasks zurück, älteste zuerst.
+func (d *Database) GetComments(taskID string) ([]TaskComment, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.queryComments(`SELECT `+commentColumns+` FROM task_comments WHERE task_id = ? ORDER BY created_at ASC`, taskID)
+}
+
+// GetAllComments gibt die Kommentare aller Tasks zurück (für den Export).
+func (d *Database) GetAllComments() ([]TaskComment, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.queryComments(`SELECT ` + commentColumns + ` FROM task_comments ORDER BY task_id ASC, created_at ASC`)
+}
+
+// GetComment gibt einen einzelnen Kommentar zurück oder nil, wenn er nicht existiert.
+func (d *Database) GetComment(id string) (*TaskComment, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.getComment(id)
+}
+
+// getComment liest einen Kommentar ohne Locking (Aufrufer hält d.mu)
+func (d *Database) getComment(id string) (*TaskComment, error) {
+	c, err := scanComment(d.db.QueryRow(`SELECT `+commentColumns+` FROM task_comments WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// CreateComment hängt einen Kommentar an den Thread eines Tasks an.
+func (d *Database) CreateComment(taskID string, req CreateCommentRequest) (*TaskComment, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	comment := &TaskComment{
+		ID:        uuid.New().String(),
+		TaskID:    taskID,
+		Author:    req.Author,
+		Body:      req.Body,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	_, err := d.db.Exec(`
+		INSERT INTO task_comments (id, task_id, author, body, sent_to_ralph, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?)
+	`, comment.ID, comment.TaskID, comment.Author, comment.Body, comment.CreatedAt, comment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+// UpdateComment ändert den Text eines Kommentars. Gibt nil zurück, wenn er nicht existiert.
+func (d *Database) UpdateComment(id string, req UpdateCommentRequest) (*TaskComment, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, err := d.getComment(id)
+	if err != nil || c == nil {
+		return nil, err
+	}
+
+	if req.Body != nil {
+		c.Body = *req.Body
+	}
+	c.UpdatedAt = time.Now()
+
+	_, err = d.db.Exec(`UPDATE task_comments SET body = ?, updated_at = ? WHERE id = ?`, c.Body, c.UpdatedAt, c.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// MarkCommentSent vermerkt, dass ein Kommentar an RALPH übergeben wurde.
+func (d *Database) MarkCommentSent(id string) (*TaskComment, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	_, err := d.db.Exec(`UPDATE task_comments SET sent_to_ralph = 1, sent_at = ? WHERE id = ?`, now, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.getComment(id)
+}
+
+// DeleteComment löscht einen Kommentar.
+func (d *Database) DeleteComment(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`DELETE FROM task_comments WHERE id = ?`, id)
+	return err
+}
+
+// ============================================================================
+// Attachment CRUD-Operationen
+// ============================================================================
+
+// GetAttachmentsByTask gibt alle Attachments für einen Task zurück.
+func (d *Database) GetAttachmentsByTask(taskID string) ([]Attachment, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`
+		SELECT id, task_id, filename, mime_type, size, path, created_at, thumbnail_path
+		FROM attachments
+		WHERE task_id = ?
+		ORDER BY created_at ASC
+	`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		var a Attachment
+		err := rows.Scan(&a.ID, &a.TaskID, &a.Filename, &a.MimeType, &a.Size, &a.Path, &a.CreatedAt, &a.ThumbnailPath)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// GetAttachment gibt ein einzelnes Attachment anhand seiner ID zurück.
+func (d *Database) GetAttachment(id string) (*Attachment, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var a Attachment
+	err := d.db.QueryRow(`
+		SELECT id, task_id, filename, mime_type, size, path, created_at, thumbnail_path
+		FROM attachments WHERE id = ?
+	`, id).Scan(&a.ID, &a.TaskID, &a.Filename, &a.MimeType, &a.Size, &a.Path, &a.CreatedAt, &a.ThumbnailPath)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateAttachment erstellt einen neuen Attachment-Datensatz.
+func (d *Database) CreateAttachment(attachment *Attachment) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		INSERT INTO attachments (id, task_id, filename, mime_type, size, path, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, attachment.ID, attachment.TaskID, attachment.Filename, attachment.MimeType,
+		attachment.Size, attachment.Path, attachment.CreatedAt)
+	return err
+}
+
+// SetAttachmentThumbnail speichert den Pfad des generierten Vorschaubilds.
+func (d *Database) SetAttachmentThumbnail(id, thumbnailPath string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`UPDATE attachments SET thumbnail_path = ? WHERE id = ?`, thumbnailPath, id)
+	return err
+}
+
+// DeleteAttachment löscht ein Attachment anhand seiner ID.
+func (d *Database) DeleteAttachment(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`DELETE FROM attachments WHERE id = ?`, id)
+	return err
+}
+
+// DeleteAttachmentsByTask löscht alle Attachments für einen Task.
+func (d *Database) DeleteAttachmentsByTask(taskID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`DELETE FROM attachments WHERE task_id = ?`, taskID)
+	return err
+}
+
+// ============================================================================
+// Upload-Operationen (noch keinem Task zugeordnet)
+// ============================================================================
+
+// CreateUpload speichert einen neuen Upload-Datensatz.
+func (d *Database) CreateUpload(upload *Upload) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		INSERT INTO pending_uploads (id, filename, mime_type, size, path, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, upload.ID, upload.Filename, upload.MimeType, upload.Size, upload.Path, upload.CreatedAt)
+	return err
+}
+
+// GetUpload gibt einen Upload anhand seiner ID zurück (nil, wenn nicht vorhanden).
+func (d *Database) GetUpload(id string) (*Upload, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var u Upload
+	err := d.db.QueryRow(`
+		SELECT id, filename, mime_type, size, path, created_at
+		FROM pending_uploads WHERE id = ?
+	`, id).Scan(&u.ID, &u.Filename, &u.MimeType, &u.Size, &u.Path, &u.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUploadsBefore gibt alle Uploads zurück, die vor cutoff hochgeladen wurden.
+func (d *Database) GetUploadsBefore(cutoff time.Time) ([]Upload, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`
+		SELECT id, filename, mime_type, size, path, created_at
+		FROM pending_uploads WHERE created_at < ?
+	`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []Upload
+	for rows.Next() {
+		var u Upload
+		if err := rows.Scan(&u.ID, &u.Filename, &u.MimeType, &u.Size, &u.Path, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, rows.Err()
+}
+
+// DeleteUpload löscht einen Upload-Datensatz.
+func (d *Database) DeleteUpload(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`DELETE FROM pending_uploads WHERE id = ?`, id)
+	return err
+}
+
+// ClaimUpload macht einen Upload zum Attachment des Tasks (gleiche ID, Datei liegt
+// bereits unter path). Gibt sql.ErrNoRows zurück, wenn der Upload nicht mehr existiert.
+func (d *Database) ClaimUpload(id, taskID, path string) (*Attachment, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	a := Attachment{TaskID: taskID, Path: path}
+	if err := tx.QueryRow(`
+		SELECT id, filename, mime_type, size, created_at
+		FROM pending_uploads WHERE id = ?
+	`, id).Scan(&a.ID, &a.Filename, &a.MimeType, &a.Size, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO attachments (id, task_id, filename, mime_type, size, path, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, a.ID, a.TaskID, a.Filename, a.MimeType, a.Size, a.Path, a.CreatedAt); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM pending_uploads WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ============================================================================
+// Task-Commit-Operationen (Index der Forge-Task-Trailer)
+// ============================================================================
+
+// AddTaskCommits speichert Commits im Index; bereits bekannte werden übersprungen.
+func (d *Database) AddTaskCommits(commits []TaskCommit) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, c := range commits {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO task_commits (task_id, hash, project_id, subject, author, committed_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, c.TaskID, c.Hash, c.ProjectID, c.Subject, c.Author, c.Date); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetTaskCommits gibt alle Commits eines Tasks zurück, neueste zuerst.
+func (d *Database) GetTaskCommits(taskID string) ([]TaskCommit, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`
+		SELECT task_id, hash, project_id, subject, author, committed_at
+		FROM task_commits WHERE task_id = ? ORDER BY committed_at DESC
+	`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	commits := []TaskCommit{}
+	for rows.Next() {
+		var c TaskCommit
+		var committedAt sql.NullTime
+		if err := rows.Scan(&c.TaskID, &c.Hash, &c.ProjectID, &c.Subject, &c.Author, &committedAt); err != nil {
+			return nil, err
+		}
+		c.Date = committedAt.Time
+		commits = append(commits, c)
+	}
+	return commits, rows.Err()
+}
+
+// ============================================================================
+// Trunk-Based Development Operations
+// ============================================================================
+
+// UpdateProjectWorkingBranch aktualisiert den persistenten Working-Branch eines Projekts.
+func (d *Database) UpdateProjectWorkingBranch(id string, branch string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE projects SET working_branch = ?, updated_at = ? WHERE id = ?
+	`, branch, time.Now(), id)
+	return err
+}
+
+// SetProjectMissing merkt, seit wann das Verzeichnis eines Projekts fehlt (nil = wieder vorhanden).
+func (d *Database) SetProjectMissing(id string, since *time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE projects SET missing_since = ? WHERE id = ?
+	`, since, id)
+	return err
+}
+
+// SetProjectStack speichert, was im Projektverzeichnis erkannt wurde (Sprache, Framework, Befehle, Größe).
+func (d *Database) SetProjectStack(id string, stack ProjectStack) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE projects SET stack = ? WHERE id = ?
+	`, stack, id)
+	return err
+}
+
+// UpdateTaskRollbackTag aktualisiert den Rollback-Tag eines Tasks.
+func (d *Database) UpdateTaskRollbackTag(id string, tag string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE tasks SET rollback_tag = ?, updated_at = ? WHERE id = ?
+	`, tag, time.Now(), id)
+	return err
+}
+
+// UpdateTaskCommitHash aktualisiert den Commit-Hash eines Tasks.
+// Ein neuer Commit setzt den CI-Status zurück, er gehörte zum alten.
+func (d *Database) UpdateTaskCommitHash(id string, hash string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE tasks SET
+			ci_status = CASE WHEN COALESCE(commit_hash, '') = ? THEN ci_status ELSE '' END,
+			ci_url = CASE WHEN COALESCE(commit_hash, '') = ? THEN ci_url ELSE '' END,
+			commit_hash = ?, updated_at = ?
+		WHERE id = ?
+	`, hash, hash, hash, time.Now(), id)
+	return err
+}
+
+// UpdateTaskPlan speichert den Plan eines Tasks und seinen Status.
+func (d *Database) UpdateTaskPlan(id string, plan string, status string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE tasks SET plan = ?, plan_status = ?, updated_at = ? WHERE id = ?
+	`, plan, status, time.Now(), id)
+	return err
+}
+
+// UpdateTaskCI speichert den CI-Status des Task-Commits und den Link zu den Checks.
+func (d *Database) UpdateTaskCI(id string, status string, url string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE tasks SET ci_status = ?, ci_url = ?, updated_at = ? WHERE id = ?
+	`, status, url, time.Now(), id)
+	return err
+}
+
+// UpdateTaskReviewComments speichert die Anzahl neuer Review-Kommentare am Pull Request eines Tasks.
+func (d *Database) UpdateTaskReviewComments(id string, count int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE tasks SET review_comments = ?, updated_at = ? WHERE id = ?
+	`, count, time.Now(), id)
+	return err
+}
+
+// MarkTaskReviewAddressed merkt sich, dass die Review-Kommentare bis at an RALPH übergeben wurden.
+func (d *Database) MarkTaskReviewAddressed(id string, at time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE tasks SET review_comments = 0, review_addressed_at = ?, updated_at = ? WHERE id = ?
+	`, at, time.Now(), id)
+	return err
+}
+
+// UpdateTaskMergeCommit speichert den Merge-Commit des Pull Requests eines Tasks.
+func (d *Database) UpdateTaskMergeCommit(id string, sha string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE tasks SET merge_commit = ?, updated_at = ? WHERE id = ?
+	`, sha, time.Now(), id)
+	return err
+}
+
+// IncrementTaskCIFixAttempts zählt eine automatische Fortsetzung wegen fehlgeschlagener CI.
+func (d *Database) IncrementTaskCIFixAttempts(id string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	_, err := d.db.Exec(`
-		UPDATE tasks SET commit_hash = ?, updated_at = ? WHERE id = ?
-	`, hash, time.Now(), id)
+		UPDATE tasks SET ci_fix_attempts = COALESCE(ci_fix_attempts, 0) + 1 WHERE id = ?
+	`, id)
 	return err
 }
 
@@ -1833,3 +4996,583 @@ func (d *Database) ClearTaskRollbackTag(id string) error {
 	`, time.Now(), id)
 	return err
 }
+
+// ============================================================================
+// Export/Import Operations
+// ============================================================================
+
+// Import-Modi für Konflikte mit bestehenden Datensätzen
+const (
+	ImportModeSkip      = "skip"      // Bestehende Datensätze behalten
+	ImportModeOverwrite = "overwrite" // Bestehende Datensätze überschreiben
+	ImportModeDuplicate = "duplicate" // Tasks mit neuer ID zusätzlich anlegen
+)
+
+// GetAllBranchRules gibt alle Branch-Schutzregeln aller Projekte zurück.
+func (d *Database) GetAllBranchRules() ([]BranchProtectionRule, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.Query(`
+		SELECT id, project_id, branch_pattern, created_at
+		FROM branch_protection_rules
+		ORDER BY project_id ASC, branch_pattern ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []BranchProtectionRule
+	for rows.Next() {
+		var r BranchProtectionRule
+		if err := rows.Scan(&r.ID, &r.ProjectID, &r.BranchPattern, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// ImportBoard stellt einen Board-Snapshot in einer einzigen Transaktion wieder her.
+// Projekte werden über den Pfad, Task-Typen und Labels über den Namen und Tasks über die ID
+// mit bestehenden Datensätzen abgeglichen. Freie IDs werden beibehalten, belegte
+// IDs werden neu vergeben und in ImportResult.IDMap zurückgegeben.
+// Laufzeit-Zustand (PID, Queue-Position, laufender Status) wird nicht übernommen.
+func (d *Database) ImportBoard(data *BoardExport, mode string, importConfig bool) (*ImportResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := &ImportResult{
+		Mode:         mode,
+		Created:      map[string]int{},
+		Updated:      map[string]int{},
+		Skipped:      map[string]int{},
+		IDMap:        map[string]string{},
+		createdTasks: map[string]string{},
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// exists prüft, ob eine ID in einer Tabelle bereits vergeben ist
+	exists := func(table, id string) (bool, error) {
+		var count int
+		err := tx.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE id = ?", id).Scan(&count)
+		return count > 0, err
+	}
+	// freeID behält die ursprüngliche ID, falls sie noch nicht vergeben ist
+	freeID := func(table, id string) (string, error) {
+		if id == "" {
+			return uuid.New().String(), nil
+		}
+		taken, err := exists(table, id)
+		if err != nil {
+			return "", err
+		}
+		if taken {
+			return uuid.New().String(), nil
+		}
+		return id, nil
+	}
+
+	// ---------- Task-Typen ----------
+	for _, tt := range data.TaskTypes {
+		var existingID string
+		err := tx.QueryRow(`SELECT id FROM task_types WHERE id = ? OR name = ? ORDER BY id = ? DESC LIMIT 1`,
+			tt.ID, tt.Name, tt.ID).Scan(&existingID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		if existingID != "" {
+			result.IDMap[tt.ID] = existingID
+			if mode == ImportModeOverwrite && !tt.IsSystem {
+				if _, err := tx.Exec(`UPDATE task_types SET color = ? WHERE id = ? AND is_system = 0`, tt.Color, existingID); err != nil {
+					return nil, err
+				}
+				result.Updated["task_types"]++
+			} else {
+				result.Skipped["task_types"]++
+			}
+			continue
+		}
+
+		newID, err := freeID("task_types", tt.ID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO task_types (id, name, color, is_system, created_at)
+			VALUES (?, ?, ?, 0, ?)
+		`, newID, tt.Name, tt.Color, tt.CreatedAt); err != nil {
+			return nil, err
+		}
+		result.IDMap[tt.ID] = newID
+		result.Created["task_types"]++
+	}
+
+	// ---------- Labels ----------
+	for _, l := range data.Labels {
+		var existingID string
+		err := tx.QueryRow(`SELECT id FROM labels WHERE id = ? OR LOWER(name) = LOWER(?) ORDER BY id = ? DESC LIMIT 1`,
+			l.ID, l.Name, l.ID).Scan(&existingID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		if existingID != "" {
+			result.IDMap[l.ID] = existingID
+			if mode == ImportModeOverwrite {
+				if _, err := tx.Exec(`UPDATE labels SET color = ? WHERE id = ?`, l.Color, existingID); err != nil {
+					return nil, err
+				}
+				result.Updated["labels"]++
+			} else {
+				result.Skipped["labels"]++
+			}
+			continue
+		}
+
+		newID, err := freeID("labels", l.ID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO labels (id, name, color, created_at)
+			VALUES (?, ?, ?, ?)
+		`, newID, l.Name, l.Color, l.CreatedAt); err != nil {
+			return nil, err
+		}
+		result.IDMap[l.ID] = newID
+		result.Created["labels"]++
+	}
+
+	// setLabels ersetzt die Label-Zuordnung eines importierten Tasks
+	setLabels := func(taskID string, labels []Label) error {
+		if _, err := tx.Exec(`DELETE FROM task_labels WHERE task_id = ?`, taskID); err != nil {
+			return err
+		}
+		for _, l := range labels {
+			labelID, ok := result.IDMap[l.ID]
+			if !ok {
+				continue
+			}
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO task_labels (task_id, label_id) VALUES (?, ?)`,
+				taskID, labelID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// ---------- Boards ----------
+	// Boards behalten ihre ID, damit Spalten, Projekte und Tasks sie wiederfinden
+	for _, b := range data.Boards {
+		// Exporte von vor den Board-Queues haben keine Queue-Strategie
+		if b.QueuePolicy == "" {
+			b.QueuePolicy = QueuePolicyFIFO
+		}
+		taken, err := exists("boards", b.ID)
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			if mode == ImportModeOverwrite {
+				if _, err := tx.Exec(`UPDATE boards SET name = ?, description = ?, queue_policy = ?, max_concurrent_tasks = ?, priority_aging_hours = ?, updated_at = ? WHERE id = ?`,
+					b.Name, b.Description, b.QueuePolicy, b.MaxConcurrentTasks, b.PriorityAgingHours, time.Now(), b.ID); err != nil {
+					return nil, err
+				}
+				result.Updated["boards"]++
+			} else {
+				result.Skipped["boards"]++
+			}
+			continue
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO boards (id, name, description, queue_policy, max_concurrent_tasks, priority_aging_hours, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, b.ID, b.Name, b.Description, b.QueuePolicy, b.MaxConcurrentTasks, b.PriorityAgingHours, b.CreatedAt, time.Now()); err != nil {
+			return nil, err
+		}
+		result.Created["boards"]++
+	}
+
+	// boardOf gibt das Board zurück, auf dem ein importierter Datensatz landet:
+	// Exporte ohne Boards und unbekannte Boards führen auf das Standard-Board
+	boardOf := func(id string) (string, error) {
+		if id == "" {
+			return DefaultBoardID, nil
+		}
+		known, err := exists("boards", id)
+		if err != nil || !known {
+			return DefaultBoardID, err
+		}
+		return id, nil
+	}
+
+	// ---------- Board-Spalten ----------
+	// Nur fehlende eigene Spalten werden angelegt, vorhandene bleiben unverändert
+	for _, c := range data.Columns {
+		if c.IsSystem {
+			continue
+		}
+		boardID, err := boardOf(c.BoardID)
+		if err != nil {
+			return nil, err
+		}
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM board_columns WHERE status = ?`, c.Status).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			result.Skipped["columns"]++
+			continue
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO board_columns (status, name, color, sort_order, wip_limit, sla_hours, role, is_system, board_id, created_at)
+			VALUES (?, ?, ?, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM board_columns), ?, ?, ?, 0, ?, ?)
+		`, c.Status, c.Name, c.Color, c.WIPLimit, c.SLAHours, c.Role, boardID, c.CreatedAt); err != nil {
+			return nil, err
+		}
+		result.Created["columns"]++
+	}
+
+	// ---------- Projekte ----------
+	for _, p := range data.Projects {
+		if p.Workflow == "" {
+			p.Workflow = WorkflowTrunk // Exporte vor dem Branch-per-Task-Workflow
+		}
+		boardID, err := boardOf(p.BoardID)
+		if err != nil {
+			return nil, err
+		}
+		p.BoardID = boardID
+		var existingID string
+		err = tx.QueryRow(`SELECT id FROM projects WHERE path = ?`, p.Path).Scan(&existingID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		if existingID != "" {
+			result.IDMap[p.ID] = existingID
+			if mode == ImportModeOverwrite {
+				if _, err := tx.Exec(`
+					UPDATE projects SET name = ?, description = ?, working_branch = ?, workflow = ?, issue_sync = ?, jira_project_key = ?, push_hook = ?,
+					                    deploy_command = ?, deploy_timeout = ?, claude_settings = ?, screenshot_url = ?, screenshot_command = ?,
+					                    budget = ?, board_id = ?, github = ?, auto_merge = ?, commit_template = ?, conventional_commits = ?,
+					                    sign_commits = ?, stack = ?, test_command = ?, build_command = ?, updated_at = ? WHERE id = ?
+				`, p.Name, p.Description, p.WorkingBranch, p.Workflow, p.IssueSync, p.JiraProjectKey, p.PushHook, p.DeployCommand, p.DeployTimeout, p.Claude,
+					p.ScreenshotURL, p.ScreenshotCommand, p.Budget, p.BoardID, p.GitHub, p.AutoMerge, p.CommitTemplate, p.ConventionalCommits, p.SignCommits,
+					p.Stack, p.TestCommand, p.BuildCommand, time.Now(), existingID); err != nil {
+					return nil, err
+				}
+				result.Updated["projects"]++
+			} else {
+				result.Skipped["projects"]++
+			}
+			continue
+		}
+
+		newID, err := freeID("projects", p.ID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO projects (id, name, path, description, is_auto_detected, working_branch, workflow, issue_sync, jira_project_key, push_hook,
+			                      deploy_command, deploy_timeout, claude_settings, screenshot_url, screenshot_command, budget, board_id, github, auto_merge,
+			                      commit_template, conventional_commits, sign_commits, stack, test_command, build_command, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, newID, p.Name, p.Path, p.Description, p.IsAutoDetected, p.WorkingBranch, p.Workflow, p.IssueSync, p.JiraProjectKey, p.PushHook,
+			p.DeployCommand, p.DeployTimeout, p.Claude, p.ScreenshotURL, p.ScreenshotCommand, p.Budget, p.BoardID, p.GitHub, p.AutoMerge,
+			p.CommitTemplate, p.ConventionalCommits, p.SignCommits, p.Stack, p.TestCommand, p.BuildCommand, p.CreatedAt, time.Now()); err != nil {
+			return nil, err
+		}
+		result.IDMap[p.ID] = newID
+		result.Created["projects"]++
+	}
+
+	// ---------- Branch-Schutzregeln ----------
+	for _, rule := range data.BranchRules {
+		projectID, ok := result.IDMap[rule.ProjectID]
+		if !ok {
+			result.Warnings = append(result.Warnings, "Branch rule "+rule.BranchPattern+" references unknown project "+rule.ProjectID)
+			result.Skipped["branch_rules"]++
+			continue
+		}
+		res, err := tx.Exec(`
+			INSERT OR IGNORE INTO branch_protection_rules (id, project_id, branch_pattern, created_at)
+			VALUES (?, ?, ?, ?)
+		`, uuid.New().String(), projectID, rule.BranchPattern, rule.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			result.Created["branch_rules"]++
+		} else {
+			result.Skipped["branch_rules"]++
+		}
+	}
+
+	// ---------- Tasks ----------
+	for _, t := range data.Tasks {
+		oldID := t.ID
+
+		// Verknüpfungen auf neue IDs umschreiben
+		if t.ProjectID != "" {
+			if newID, ok := result.IDMap[t.ProjectID]; ok {
+				t.ProjectID = newID
+			} else {
+				result.Warnings = append(result.Warnings, "Task "+t.Title+" references unknown project "+t.ProjectID)
+				t.ProjectID = ""
+			}
+		}
+		if t.TaskTypeID != "" {
+			if newID, ok := result.IDMap[t.TaskTypeID]; ok {
+				t.TaskTypeID = newID
+			} else {
+				t.TaskTypeID = ""
+			}
+		}
+		boardID, err := boardOf(t.BoardID)
+		if err != nil {
+			return nil, err
+		}
+		t.BoardID = boardID
+
+		// Laufzeit-Zustand zurücksetzen - importierte Tasks laufen nie.
+		// Unbekannte Status würden auf keinem Board erscheinen.
+		var role sql.NullString
+		err = tx.QueryRow(`SELECT role FROM board_columns WHERE status = ?`, t.Status).Scan(&role)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if !role.Valid || role.String == ColumnRoleQueue || role.String == ColumnRoleProgress {
+			t.Status = StatusBacklog
+		}
+		// Ein Plan, der gerade entsteht, kommt nie an
+		if t.PlanStatus == PlanStatusPlanning {
+			t.PlanStatus = ""
+		}
+
+		taken, err := exists("tasks", t.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if taken && mode == ImportModeOverwrite {
+			if _, err := tx.Exec(`
+				UPDATE tasks SET
+					title = ?, description = ?, acceptance_criteria = ?, status = ?, priority = ?,
+					current_iteration = ?, max_iterations = ?, logs = ?, error = ?, project_dir = ?,
+					project_id = ?, task_type_id = ?, working_branch = ?, target_branch = ?,
+					env = ?, work_dir = ?, plan = ?, plan_status = ?, board_id = ?,
+					rollback_tag = ?, commit_hash = ?, queue_position = 0, process_pid = 0,
+					process_status = 'idle', continue_message = '', updated_at = ?
+				WHERE id = ?
+			`, t.Title, t.Description, t.AcceptanceCriteria, t.Status, t.Priority,
+				t.CurrentIteration, t.MaxIterations, t.Logs, t.Error, t.ProjectDir,
+				t.ProjectID, t.TaskTypeID, t.WorkingBranch, t.TargetBranch,
+				t.Env, t.WorkDir, t.Plan, t.PlanStatus, t.BoardID,
+				t.RollbackTag, t.CommitHash, time.Now(), t.ID); err != nil {
+				return nil, err
+			}
+			if err := setLabels(t.ID, t.Labels); err != nil {
+				return nil, err
+			}
+			if err := recordTaskStatus(tx, t.ID, t.Status, time.Now()); err != nil {
+				return nil, err
+			}
+			result.IDMap[oldID] = t.ID
+			result.Updated["tasks"]++
+			continue
+		}
+		if taken && mode != ImportModeDuplicate {
+			result.IDMap[oldID] = t.ID
+			result.Skipped["tasks"]++
+			continue
+		}
+		if taken || t.ID == "" {
+			t.ID = uuid.New().String()
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO tasks (id, title, description, acceptance_criteria, status,
+			                   priority, current_iteration, max_iterations, logs,
+			                   error, project_dir, project_id, task_type_id, working_branch,
+			                   target_branch, env, work_dir, plan, plan_status, rollback_tag, commit_hash, queue_position,
+			                   process_pid, process_status, started_at, finished_at, board_id,
+			                   created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, 0, 'idle', ?, ?, ?, ?, ?)
+		`,
+			t.ID, t.Title, t.Description, t.AcceptanceCriteria, t.Status,
+			t.Priority, t.CurrentIteration, t.MaxIterations, t.Logs,
+			t.Error, t.ProjectDir, t.ProjectID, t.TaskTypeID, t.WorkingBranch,
+			t.TargetBranch, t.Env, t.WorkDir, t.Plan, t.PlanStatus, t.RollbackTag, t.CommitHash,
+			t.StartedAt, t.FinishedAt, t.BoardID, t.CreatedAt, time.Now(),
+		); err != nil {
+			return nil, err
+		}
+		if err := setLabels(t.ID, t.Labels); err != nil {
+			return nil, err
+		}
+		if err := recordTaskStatus(tx, t.ID, t.Status, time.Now()); err != nil {
+			return nil, err
+		}
+		result.IDMap[oldID] = t.ID
+		result.createdTasks[oldID] = t.ID
+		result.Created["tasks"]++
+	}
+
+	// ---------- Epics und Abhängigkeiten ----------
+	// Erst nach allen Tasks, da sie auf andere Tasks verweisen
+	for _, t := range data.Tasks {
+		taskID, ok := result.createdTasks[t.ID]
+		if !ok && mode == ImportModeOverwrite {
+			taskID, ok = result.IDMap[t.ID]
+		}
+		if !ok {
+			continue
+		}
+		if parentID, found := result.IDMap[t.ParentID]; found && t.ParentID != "" {
+			if _, err := tx.Exec(`UPDATE tasks SET parent_id = ? WHERE id = ?`, parentID, taskID); err != nil {
+				return nil, err
+			}
+		}
+		for _, dep := range t.DependsOn {
+			dependsOn, found := result.IDMap[dep]
+			if !found {
+				continue
+			}
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO task_dependencies (task_id, depends_on_id) VALUES (?, ?)`, taskID, dependsOn); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// ---------- Kommentare ----------
+	// Nur für neu angelegte Tasks, sonst würden Threads bei jedem Import doppelt
+	for _, c := range data.Comments {
+		taskID, ok := result.createdTasks[c.TaskID]
+		if !ok {
+			result.Skipped["comments"]++
+			continue
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO task_comments (id, task_id, author, body, sent_to_ralph, sent_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, uuid.New().String(), taskID, c.Author, c.Body, c.SentToRalph, c.SentAt, c.CreatedAt, c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		result.Created["comments"]++
+	}
+
+	// ---------- Config ----------
+	if importConfig && data.Config != nil {
+		c := data.Config
+		if _, err := tx.Exec(`
+			UPDATE config SET
+				default_project_dir = ?,
+				default_max_iterations = ?,
+				claude_command = ?,
+				projects_base_dir = ?,
+				auto_commit = ?,
+				auto_push = ?,
+				default_branch = ?,
+				default_priority = ?,
+				auto_archive_days = ?,
+				push_strategy = ?,
+				attachment_types = COALESCE(NULLIF(?, ''), attachment_types),
+				jira_url = COALESCE(NULLIF(?, ''), jira_url),
+				jira_user = COALESCE(NULLIF(?, ''), jira_user),
+				claude_settings = ?,
+				log_retention = ?,
+				max_concurrent_tasks = COALESCE(NULLIF(?, 0), max_concurrent_tasks),
+				blocked_triage = ?,
+				retry_policy = ?,
+				budget = ?,
+				github_user_tokens = ?,
+				github_endpoints = ?,
+				signing = ?
+			WHERE id = 1
+		`, c.DefaultProjectDir, c.DefaultMaxIterations, c.ClaudeCommand, c.ProjectsBaseDir,
+			c.AutoCommit, c.AutoPush, c.DefaultBranch, c.DefaultPriority, c.AutoArchiveDays, c.PushStrategy,
+			c.AttachmentTypes, c.JiraURL, c.JiraUser, c.Claude, c.LogRetention, c.MaxConcurrentTasks,
+			c.BlockedTriage, c.RetryPolicy, c.Budget, c.GithubUserTokens, c.GitHub, c.Signing); err != nil {
+			return nil, err
+		}
+		result.ConfigApplied = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ============================================================================
+// Backup Operations
+// ============================================================================
+
+// BackupTo schreibt einen konsistenten Snapshot der Datenbank nach dest.
+// VACUUM INTO läuft als Lese-Transaktion und blockiert andere Leser nicht.
+func (d *Database) BackupTo(dest string) error {
+	if d.driver != DriverSQLite {
+		return errBackupUnsupported
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, err := d.db.Exec("VACUUM INTO ?", dest)
+	return err
+}
+
+// RestoreFrom ersetzt die laufende Datenbank durch die Backup-Datei src.
+// Die Verbindung wird geschlossen, die Datei getauscht und neu geöffnet;
+// anschließend laufen die Migrationen, damit ältere Backups das aktuelle Schema erhalten.
+func (d *Database) RestoreFrom(src string) error {
+	if d.driver != DriverSQLite {
+		return errBackupUnsupported
+	}
+	if err := verifySQLiteBackup(src); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Erst neben die Datenbank kopieren, damit der Tausch per Rename atomar ist
+	staging := d.path + ".restore"
+	if err := copyFile(src, staging); err != nil {
+		return fmt.Errorf("failed to stage backup: %v", err)
+	}
+
+	d.db.Close()
+	os.Remove(d.path + "-wal")
+	os.Remove(d.path + "-shm")
+
+	renameErr := os.Rename(staging, d.path)
+	if renameErr != nil {
+		os.Remove(staging)
+	}
+
+	// Auch bei fehlgeschlagenem Rename neu öffnen, damit der Server weiterläuft
+	db, err := openSQLite(d.path)
+	if err != nil {
+		return fmt.Errorf("failed to reopen database: %v", err)
+	}
+	d.db = &sqlConn{DB: db, dialect: DriverSQLite}
+
+	if renameErr != nil {
+		return fmt.Errorf("failed to replace database: %v", renameErr)
+	}
+	if err := d.initSchema(); err != nil {
+		return err
+	}
+	return d.runMigrations()
+}
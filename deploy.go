@@ -0,0 +1,489 @@
+// deploy.go runs deploy commands after a task was pushed, e.g. `make deploy`
+// or `fly deploy`. A project has its own deploy commands and may define
+// environments such as staging and prod with commands of their own; a deploy
+// names the environment it targets. The commands run one after another with
+// sh in the project directory, with the project's injected secrets in their
+// environment, and stop at the first failure. Their output is streamed to
+// the task log. The task is done once all succeed and blocked if one fails
+// or they exceed the timeout. Every deploy, with or without commands, is
+// kept as a deployment; rolling back to one commits its state of the files
+// on top of the branch, pushes and runs the environment's commands again.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errEnvironmentExists is returned when a project already has an environment with the name
+var errEnvironmentExists = errors.New("an environment with this name already exists")
+
+// errUncommittedChanges refuses a rollback that would discard local changes
+var errUncommittedChanges = errors.New("the project has uncommitted changes, commit or discard them first")
+
+// defaultDeployTimeout applies to deploy commands without a timeout
+const defaultDeployTimeout = 10 * time.Minute
+
+// deployOutputLimit caps the output stored with a deployment, keeping the end
+const deployOutputLimit = 64 * 1024
+
+// Default and maximum number of deployments returned by the history endpoint
+const (
+	defaultDeploymentLimit = 20
+	maxDeploymentLimit     = 200
+)
+
+// deployLocks holds a mutex per project, so its deployments do not overlap
+var deployLocks sync.Map
+
+// deployTarget is what a deploy runs: the project's own deploy commands or
+// those of one of its environments
+type deployTarget struct {
+	environment string // "" for the project's own commands
+	commands    []string
+	timeout     time.Duration
+}
+
+// resolveDeployTarget returns the target for environment ("" = the project's
+// own commands), nil and no error if the environment does not exist
+func (h *Handler) resolveDeployTarget(project *Project, environment string) (*deployTarget, error) {
+	if environment == "" {
+		return &deployTarget{
+			commands: deployCommands(project.DeployCommand),
+			timeout:  deployTimeout(project.DeployTimeout),
+		}, nil
+	}
+	env, err := h.db.GetProjectEnvironmentByName(project.ID, environment)
+	if err != nil || env == nil {
+		return nil, err
+	}
+	return &deployTarget{
+		environment: env.Name,
+		commands:    deployCommands(env.DeployCommand),
+		timeout:     deployTimeout(env.DeployTimeout),
+	}, nil
+}
+
+// deployCommands returns the lines of a deploy command that are not empty or comments
+func deployCommands(command string) []string {
+	var commands []string
+	for _, line := range strings.Split(command, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			commands = append(commands, line)
+		}
+	}
+	return commands
+}
+
+// deployTimeout converts a timeout in seconds, 0 meaning the default
+func deployTimeout(seconds int) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultDeployTimeout
+}
+
+// HandleProjectDeployments handles GET /api/projects/{id}/deployments?environment=&limit=
+func (h *Handler) HandleProjectDeployments(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultDeploymentLimit
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			h.writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(limit, maxDeploymentLimit)
+	}
+
+	deployments, err := h.db.GetDeploymentsByProject(project.ID, query.Get("environment"), limit)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get deployments: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, deployments)
+}
+
+// HandleRollbackDeployment handles POST /api/projects/{id}/deployments/{deploymentId}/rollback
+// It commits the files as they were at the deployment, pushes and deploys to
+// the same environment again.
+func (h *Handler) HandleRollbackDeployment(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	target, err := h.db.GetDeployment(r.PathValue("deploymentId"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get deployment: "+err.Error())
+		return
+	}
+	if target == nil || target.ProjectID != project.ID {
+		h.writeError(w, http.StatusNotFound, "Deployment not found")
+		return
+	}
+	if target.Status != DeploymentSucceeded || target.CommitHash == "" {
+		h.writeError(w, http.StatusBadRequest, "Only successful deployments can be rolled back to")
+		return
+	}
+
+	deployTo, err := h.resolveDeployTarget(project, target.Environment)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get environment: "+err.Error())
+		return
+	}
+	if deployTo == nil {
+		h.writeError(w, http.StatusBadRequest, "Environment "+target.Environment+" no longer exists")
+		return
+	}
+
+	unlock, err := lockRepoWithin(r.Context(), project.Path, "deploy rollback")
+	if err != nil {
+		h.writeLockError(w, err)
+		return
+	}
+	hash, err := h.commitRollback(project, target)
+	unlock()
+	gitStatus.Invalidate(project.Path)
+	if errors.Is(err, errUncommittedChanges) {
+		h.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		h.writeError(w, pushErrorStatus(err), "Failed to roll back: "+err.Error())
+		return
+	}
+
+	deployment := &Deployment{
+		ProjectID:   project.ID,
+		Environment: deployTo.environment,
+		CommitHash:  hash,
+		RollbackOf:  target.ID,
+	}
+	if err := h.startDeployment(nil, project, deployment, deployTo); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Rolled back and pushed, but failed to record the deployment: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, deployment)
+}
+
+// commitRollback commits the files of the project as they were at target
+// and pushes. Returns the commit to deploy. The caller holds the repository lock.
+func (h *Handler) commitRollback(project *Project, target *Deployment) (string, error) {
+	if dirty, err := HasUncommittedChanges(project.Path); err != nil {
+		return "", err
+	} else if dirty {
+		return "", errUncommittedChanges
+	}
+	if err := checkPushAllowed(h.db, project.ID, project.Path); err != nil {
+		return "", err
+	}
+
+	env := target.Environment
+	if env == "" {
+		env = "default"
+	}
+	message := commitMessage(project, nil, fmt.Sprintf("Roll back %s to %s", env, target.CommitHash[:min(7, len(target.CommitHash))]))
+	if _, err := commitRestoredTree(project.Path, target.CommitHash, message); err != nil {
+		return "", err
+	}
+	if err := PushToRemote(project.Path); err != nil {
+		return "", err
+	}
+	return GetCurrentCommitHash(project.Path)
+}
+
+// startDeployment records deployment and runs the target's commands in the
+// background. Without commands the deployment, a plain push, succeeds right
+// away. task is nil for rollbacks.
+func (h *Handler) startDeployment(task *Task, project *Project, deployment *Deployment, target *deployTarget) error {
+	deployment.Command = strings.Join(target.commands, "\n")
+	if err := h.db.CreateDeployment(deployment); err != nil {
+		return err
+	}
+	if len(target.commands) == 0 {
+		deployment.Status = DeploymentSucceeded
+		if err := h.db.FinishDeployment(deployment); err != nil {
+			return err
+		}
+		h.hub.BroadcastDeploymentUpdate(deployment)
+		return nil
+	}
+
+	h.hub.BroadcastDeploymentUpdate(deployment)
+	running := *deployment // runDeployment updates its own copy
+	go h.runDeployment(task, project, &running, target.timeout)
+	return nil
+}
+
+// runDeployment runs the deploy commands and moves the task to done or blocked
+func (h *Handler) runDeployment(task *Task, project *Project, deployment *Deployment, timeout time.Duration) {
+	logger := componentLog("deploy").With("project", project.Name, "environment", deployment.Environment, "deployment_id", deployment.ID)
+	var logs strings.Builder
+	broadcast := func(text string) {
+		logs.WriteString(text)
+		if task != nil {
+			h.hub.BroadcastLog(task.ID, text)
+		}
+	}
+	logf := func(format string, args ...interface{}) {
+		broadcast("[FORGE] " + fmt.Sprintf(format, args...) + "\n")
+	}
+
+	value, _ := deployLocks.LoadOrStore(project.ID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	env, names, err := projectSecretEnv(h.db, project.ID)
+	if err != nil {
+		deployment.Status = DeploymentFailed
+		logf("Deploy failed: could not load project secrets: %v", err)
+	} else {
+		env = append(env, "FORGE_COMMIT="+deployment.CommitHash, "FORGE_ENVIRONMENT="+deployment.Environment)
+		if task != nil {
+			env = append(env, "FORGE_TASK_ID="+task.ID)
+		}
+		if len(names) > 0 {
+			logf("Secrets available as environment variables: %s", strings.Join(names, ", "))
+		}
+		if deployment.Environment != "" {
+			logf("Deploying to %s", deployment.Environment)
+		}
+		deployment.Status = DeploymentSucceeded
+		for _, command := range strings.Split(deployment.Command, "\n") {
+			logf("Deploying: %s", command)
+			code, err := runDeployCommand(ctx, project.Path, command, env, broadcast)
+			if ctx.Err() == context.DeadlineExceeded {
+				deployment.Status = DeploymentTimedOut
+				logf("Deploy timed out after %s", timeout)
+				break
+			}
+			if err != nil {
+				deployment.Status = DeploymentFailed
+				deployment.ExitCode = code
+				logf("Deploy command failed: %v", err)
+				break
+			}
+		}
+	}
+
+	deployment.Output = truncateText(logs.String(), deployOutputLimit)
+	if err := h.db.FinishDeployment(deployment); err != nil {
+		logger.Error("Failed to save deployment", "err", err)
+	}
+	h.hub.BroadcastDeploymentUpdate(deployment)
+	if deployment.Status == DeploymentSucceeded {
+		logger.Info("Deployed")
+	} else {
+		logger.Warn("Deploy failed", "status", deployment.Status, "exit_code", deployment.ExitCode)
+	}
+	if task == nil {
+		return
+	}
+
+	h.db.AppendTaskLogs(task.ID, logs.String())
+	if deployment.Status == DeploymentSucceeded {
+		h.db.UpdateTaskStatus(task.ID, StatusDone)
+		h.hub.BroadcastDeploymentSuccess(task.ID, "Deployed "+project.Name)
+		go finishTrackerIssues(h.db, h.hub, task.ID)
+		jiraSync.Notify()
+		// Tasks depending on this one may start now
+		go h.runner.TryStartNextQueued(context.Background())
+	} else {
+		h.db.UpdateTaskStatus(task.ID, StatusBlocked)
+		h.db.UpdateTaskError(task.ID, "Deploy "+strings.ReplaceAll(deployment.Status, "_", " ")+" after the push, see the task log")
+	}
+	if updated, _ := h.db.GetTask(task.ID); updated != nil {
+		h.hub.BroadcastTaskUpdate(updated)
+	}
+}
+
+// runDeployCommand runs one command with sh in dir and passes every line it
+// writes to onLine. Returns the exit code if the command failed.
+func runDeployCommand(ctx context.Context, dir, command string, env []string, onLine func(string)) (int, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	runInOwnProcessGroup(cmd)
+	// Output pipes may be held open by background children after a kill
+	cmd.WaitDelay = 5 * time.Second
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			onLine(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), err
+	}
+	return 0, err
+}
+
+// HandleProjectEnvironments handles GET/POST /api/projects/{id}/environments
+func (h *Handler) HandleProjectEnvironments(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	project, err := h.db.GetProject(projectID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		envs, err := h.db.GetProjectEnvironments(projectID)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get environments: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusOK, envs)
+
+	case http.MethodPost:
+		var req CreateEnvironmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		env := &ProjectEnvironment{
+			ProjectID:     projectID,
+			Name:          strings.TrimSpace(req.Name),
+			DeployCommand: strings.TrimSpace(req.DeployCommand),
+			DeployTimeout: req.DeployTimeout,
+		}
+		if err := validateEnvironment(env); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := h.db.CreateProjectEnvironment(env); err != nil {
+			h.writeEnvironmentError(w, "create", err)
+			return
+		}
+		h.writeJSON(w, http.StatusCreated, env)
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleProjectEnvironment handles PUT/DELETE /api/projects/{id}/environments/{envId}
+func (h *Handler) HandleProjectEnvironment(w http.ResponseWriter, r *http.Request) {
+	env, err := h.db.GetProjectEnvironment(r.PathValue("envId"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get environment: "+err.Error())
+		return
+	}
+	if env == nil || env.ProjectID != r.PathValue("id") {
+		h.writeError(w, http.StatusNotFound, "Environment not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req UpdateEnvironmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if req.Name != nil {
+			env.Name = strings.TrimSpace(*req.Name)
+		}
+		if req.DeployCommand != nil {
+			env.DeployCommand = strings.TrimSpace(*req.DeployCommand)
+		}
+		if req.DeployTimeout != nil {
+			env.DeployTimeout = *req.DeployTimeout
+		}
+		if err := validateEnvironment(env); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := h.db.UpdateProjectEnvironment(env); err != nil {
+			h.writeEnvironmentError(w, "update", err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, env)
+
+	case http.MethodDelete:
+		if err := h.db.DeleteProjectEnvironment(env.ID); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to delete environment: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// validateEnvironment checks an environment before it is saved
+func validateEnvironment(env *ProjectEnvironment) error {
+	if env.Name == "" {
+		return fmt.Errorf("Name is required")
+	}
+	if len(deployCommands(env.DeployCommand)) == 0 {
+		return fmt.Errorf("Deploy command is required")
+	}
+	if env.DeployTimeout < 0 {
+		return fmt.Errorf("Deploy timeout must not be negative")
+	}
+	return nil
+}
+
+// writeEnvironmentError reports a failed environment write with a matching status
+func (h *Handler) writeEnvironmentError(w http.ResponseWriter, action string, err error) {
+	if errors.Is(err, errEnvironmentExists) {
+		h.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	h.writeError(w, http.StatusInternalServerError, "Failed to "+action+" environment: "+err.Error())
+}
@@ -0,0 +1,245 @@
+// deploykeys.go gives projects their own SSH key for remotes that are not on
+// GitHub or that the server's own credentials cannot reach. POST
+// /api/projects/{id}/deploy-key generates an Ed25519 key pair; the public key
+// is shown to be added as a deploy key at the Git host, the private key is
+// stored encrypted under FORGE_SECRETS_KEY like project secrets and never
+// leaves the server. While a project has a key, FORGE's pushes, pulls and
+// fetches in its repository run with GIT_SSH_COMMAND pointing at a temporary
+// copy of it, removed when git exits. Only SSH remotes use the key; commands
+// Claude runs during a task do not.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// deployKeySSHOptions make ssh use only the deploy key and trust a host on
+// first use, as a headless server has no one to confirm it
+const deployKeySSHOptions = "-o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new -o BatchMode=yes"
+
+// deployKeyEntry is a project with a deploy key
+type deployKeyEntry struct {
+	projectID string
+	path      string // Project directory
+	root      string // Root of its git repository
+	sealed    string // Encrypted private key
+}
+
+// deployKeyRegistry knows the deploy keys by directory, so git commands only
+// need the directory they run in
+type deployKeyRegistry struct {
+	mu      sync.RWMutex
+	entries []deployKeyEntry
+}
+
+// deployKeys holds the deploy keys of all projects
+var deployKeys = &deployKeyRegistry{}
+
+// load reads the deploy keys of all projects
+func (r *deployKeyRegistry) load(db Store) error {
+	keys, err := db.GetDeployKeys()
+	if err != nil {
+		return err
+	}
+	entries := make([]deployKeyEntry, 0, len(keys))
+	for _, key := range keys {
+		project, err := db.GetProject(key.ProjectID)
+		if err != nil {
+			return err
+		}
+		if project == nil || project.Path == "" {
+			continue
+		}
+		path, _ := filepath.Abs(project.Path)
+		entries = append(entries, deployKeyEntry{
+			projectID: key.ProjectID,
+			path:      path,
+			root:      gitRoot(path),
+			sealed:    key.PrivateKey,
+		})
+	}
+
+	r.mu.Lock()
+	r.entries = entries
+	r.mu.Unlock()
+	return nil
+}
+
+// lookup returns the deploy key for a directory: the key of the project in
+// it, else of another project in the same repository, nil if none has one
+func (r *deployKeyRegistry) lookup(dir string) *deployKeyEntry {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	root := gitRoot(abs)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var match *deployKeyEntry
+	for i := range r.entries {
+		entry := &r.entries[i]
+		if entry.path == abs {
+			return entry
+		}
+		if match == nil && root != "" && entry.root == root {
+			match = entry
+		}
+	}
+	return match
+}
+
+// reloadDeployKeys reads the deploy keys again after one changed
+func (h *Handler) reloadDeployKeys() {
+	if err := deployKeys.load(h.db); err != nil {
+		componentLog("git").Warn("Failed to load deploy keys", "err", err)
+	}
+}
+
+// deployKeySecretName is the name the private key of a project is sealed under
+func deployKeySecretName(projectID string) string {
+	return "deploy-key:" + projectID
+}
+
+// generateDeployKey creates an Ed25519 key pair for a project, with the
+// private key sealed for storage
+func generateDeployKey(projectID, comment string) (*DeployKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(private, comment)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := secretsBox.seal(deployKeySecretName(projectID), string(pem.EncodeToMemory(block)))
+	if err != nil {
+		return nil, err
+	}
+
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublic)))
+	return &DeployKey{
+		ProjectID:   projectID,
+		PublicKey:   authorized + " " + comment,
+		Fingerprint: ssh.FingerprintSHA256(sshPublic),
+		PrivateKey:  sealed,
+	}, nil
+}
+
+// withDeployKey makes cmd use the deploy key of the project it runs in, if
+// there is one. The returned function removes the key file; call it once cmd
+// has finished.
+func withDeployKey(cmd *exec.Cmd) func() {
+	entry := deployKeys.lookup(cmd.Dir)
+	if entry == nil {
+		return func() {}
+	}
+	key, err := secretsBox.open(deployKeySecretName(entry.projectID), entry.sealed)
+	if err != nil {
+		componentLog("git").Warn("Cannot use the deploy key", "project_id", entry.projectID, "err", err)
+		return func() {}
+	}
+
+	// CreateTemp creates the file readable by the owner only, as ssh requires
+	file, err := os.CreateTemp("", "forge-deploy-key-*")
+	if err != nil {
+		componentLog("git").Warn("Cannot write the deploy key", "project_id", entry.projectID, "err", err)
+		return func() {}
+	}
+	remove := func() { os.Remove(file.Name()) }
+	_, err = file.WriteString(key)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		remove()
+		componentLog("git").Warn("Cannot write the deploy key", "project_id", entry.projectID, "err", err)
+		return func() {}
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, "GIT_SSH_COMMAND=ssh -i "+shellQuote(file.Name())+" "+deployKeySSHOptions)
+	return remove
+}
+
+// HandleProjectDeployKey handles GET/POST/DELETE /api/projects/{id}/deploy-key
+// Shows, generates (replacing the current one) or removes the project's deploy key.
+func (h *Handler) HandleProjectDeployKey(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		key, err := h.db.GetDeployKey(project.ID)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get deploy key: "+err.Error())
+			return
+		}
+		if key == nil {
+			h.writeError(w, http.StatusNotFound, "Project has no deploy key")
+			return
+		}
+		h.writeJSON(w, http.StatusOK, key)
+
+	case http.MethodPost:
+		key, err := generateDeployKey(project.ID, "forge-"+project.Name)
+		if errors.Is(err, errSecretsDisabled) {
+			h.writeError(w, http.StatusServiceUnavailable, "Deploy keys are stored encrypted: set FORGE_SECRETS_KEY")
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to generate deploy key: "+err.Error())
+			return
+		}
+		if err := h.db.SaveDeployKey(key); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to save deploy key: "+err.Error())
+			return
+		}
+		h.reloadDeployKeys()
+		logFrom(r.Context()).Info("Generated deploy key", "project_id", project.ID, "fingerprint", key.Fingerprint)
+		h.writeJSON(w, http.StatusCreated, key)
+
+	case http.MethodDelete:
+		err := h.db.DeleteDeployKey(project.ID)
+		if err == sql.ErrNoRows {
+			h.writeError(w, http.StatusNotFound, "Project has no deploy key")
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to delete deploy key: "+err.Error())
+			return
+		}
+		h.reloadDeployKeys()
+		logFrom(r.Context()).Info("Removed deploy key", "project_id", project.ID)
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,311 @@
+// doctor.go checks the environment FORGE runs in. GET /api/health is the
+// cheap liveness probe for load balancers and container runtimes: it only
+// checks that the database and the uploads directory are writable and answers
+// 503 otherwise. GET /api/doctor runs every check: the Claude CLI, its
+// version and supported flags, git, the GitHub token, the database, the
+// uploads directory, free disk space and the paths of all projects. Each check reports ok, warning
+// or error with a hint how to fix it, so the UI can show setup warnings. The
+// same checks are logged once at startup.
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Results of a health check
+const (
+	CheckOK      = "ok"
+	CheckWarning = "warning"
+	CheckError   = "error"
+)
+
+// doctorCommandTimeout limits how long a version command or the GitHub token check may take
+const doctorCommandTimeout = 10 * time.Second
+
+// Free disk space below which a warning or an error is reported
+const (
+	diskSpaceWarning = 1 << 30   // 1 GB
+	diskSpaceError   = 100 << 20 // 100 MB
+)
+
+// checkStatusRank orders results so the worst one decides the overall status
+var checkStatusRank = map[string]int{CheckOK: 0, CheckWarning: 1, CheckError: 2}
+
+// newDoctorReport sums up checks; the worst result is the overall status
+func newDoctorReport(checks []HealthCheck) *DoctorReport {
+	report := &DoctorReport{Status: CheckOK, Version: Version, Checks: checks, CheckedAt: time.Now()}
+	for _, check := range checks {
+		if checkStatusRank[check.Status] > checkStatusRank[report.Status] {
+			report.Status = check.Status
+		}
+	}
+	return report
+}
+
+// commandVersion runs "name --version" and returns the first line of its output
+func commandVersion(name string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorCommandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, name, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return version, nil
+}
+
+// checkClaude checks that the configured Claude CLI runs and supports the
+// flags FORGE and the global Claude settings use
+func checkClaude(caps *ClaudeCapabilities, settings ClaudeSettings) HealthCheck {
+	check := HealthCheck{Name: "claude", Status: CheckOK, Message: caps.Version}
+	if caps.Error != "" {
+		check.Status = CheckError
+		check.Message = caps.Error
+		check.Hint = "Install Claude Code (npm install -g @anthropic-ai/claude-code) or set the Claude command in Settings"
+		return check
+	}
+	if _, err := caps.Args(false, settings); err != nil {
+		check.Status = CheckError
+		check.Message = err.Error()
+		check.Hint = "Update Claude Code; tasks cannot run with this version"
+		return check
+	}
+	if missing := caps.Missing(); len(missing) > 0 {
+		check.Status = CheckWarning
+		check.Message = fmt.Sprintf("%s lacks %s", caps.Version, strings.Join(missing, ", "))
+		check.Hint = "Update Claude Code; without stream-json input, feedback restarts the task, and plan mode needs --disallowedTools"
+	}
+	return check
+}
+
+// checkGit checks that git is installed
+func checkGit() HealthCheck {
+	check := HealthCheck{Name: "git", Status: CheckOK}
+	version, err := commandVersion("git")
+	if err != nil {
+		check.Status = CheckError
+		check.Message = "git not available: " + err.Error()
+		check.Hint = "Install git and make sure it is on the PATH of the FORGE process"
+		return check
+	}
+	check.Message = version
+	return check
+}
+
+// checkGitHubToken checks the stored GitHub token against the GitHub API
+func checkGitHubToken(config *Config) HealthCheck {
+	check := HealthCheck{Name: "github", Status: CheckOK}
+	if config.GithubToken == "" {
+		check.Status = CheckWarning
+		check.Message = "No GitHub token configured"
+		check.Hint = "Connect GitHub in the user menu to create repositories, pull requests and releases"
+		return check
+	}
+
+	type result struct {
+		user *GitHubUser
+		err  error
+	}
+	endpoints := githubEndpointsFor(config, nil)
+	done := make(chan result, 1)
+	go func() {
+		user, err := endpoints.client(config.GithubToken).ValidateToken()
+		done <- result{user, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			check.Status = CheckError
+			check.Message = "GitHub token rejected: " + res.err.Error()
+			check.Hint = "Reconnect GitHub with a valid token"
+			return check
+		}
+		check.Message = "Authenticated as " + res.user.Login
+	case <-time.After(doctorCommandTimeout):
+		check.Status = CheckWarning
+		check.Message = "GitHub did not answer in time"
+		check.Hint = "Check the network connection to " + endpoints.APIURL
+	}
+	return check
+}
+
+// checkDatabase checks that the database accepts writes
+func checkDatabase(db Store) HealthCheck {
+	check := HealthCheck{Name: "database", Status: CheckOK, Message: db.Driver()}
+	if err := db.CheckWritable(); err != nil {
+		check.Status = CheckError
+		check.Message = "Database not writable: " + err.Error()
+		check.Hint = "Check the permissions of the database file and its directory, or the database server"
+	}
+	return check
+}
+
+// checkUploadsDir checks that attachments can be stored
+func checkUploadsDir() HealthCheck {
+	check := HealthCheck{Name: "uploads", Status: CheckOK, Message: UploadsDir}
+	fail := func(err error) HealthCheck {
+		check.Status = CheckError
+		check.Message = fmt.Sprintf("Uploads directory %s not writable: %v", UploadsDir, err)
+		check.Hint = "Check the permissions of the directory or point FORGE_UPLOADS_DIR elsewhere"
+		return check
+	}
+	if err := os.MkdirAll(UploadsDir, 0755); err != nil {
+		return fail(err)
+	}
+	f, err := os.CreateTemp(UploadsDir, ".forge-check-*")
+	if err != nil {
+		return fail(err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return check
+}
+
+// checkDiskSpace reports the free space of the file systems holding paths,
+// each file system once
+func checkDiskSpace(paths []string) []HealthCheck {
+	var checks []HealthCheck
+	seen := make(map[uint64]bool)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			if seen[uint64(st.Dev)] {
+				continue
+			}
+			seen[uint64(st.Dev)] = true
+		}
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(path, &stat); err != nil {
+			continue
+		}
+
+		free := stat.Bavail * uint64(stat.Bsize)
+		check := HealthCheck{
+			Name:    "disk",
+			Status:  CheckOK,
+			Message: fmt.Sprintf("%s free on the file system of %s", formatBytes(free), path),
+		}
+		switch {
+		case free < diskSpaceError:
+			check.Status = CheckError
+		case free < diskSpaceWarning:
+			check.Status = CheckWarning
+		}
+		if check.Status != CheckOK {
+			check.Hint = "Free up disk space; old backups and attachments of deleted tasks can be removed"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// formatBytes renders a size like "3.2 GB"
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// checkProjects checks that the path of every project exists and is a git repository
+func checkProjects(db Store) []HealthCheck {
+	projects, err := db.GetAllProjects()
+	if err != nil {
+		return []HealthCheck{{Name: "projects", Status: CheckError, Message: "Failed to get projects: " + err.Error()}}
+	}
+
+	var checks []HealthCheck
+	for _, project := range projects {
+		check := HealthCheck{Name: "project", Status: CheckOK, ProjectID: project.ID, Message: project.Name + ": " + project.Path}
+		if info, err := os.Stat(project.Path); err != nil || !info.IsDir() {
+			check.Status = CheckError
+			check.Message = fmt.Sprintf("%s: %s does not exist", project.Name, project.Path)
+			check.Hint = "Restore the directory or change the project path"
+		} else if !IsGitRepository(project.Path) {
+			check.Status = CheckWarning
+			check.Message = fmt.Sprintf("%s: %s is not a git repository", project.Name, project.Path)
+			check.Hint = "Initialize git in the project; branches, rollbacks and deploys need it"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// runDoctor runs all checks
+func runDoctor(db Store) *DoctorReport {
+	config, err := db.GetConfig()
+	if err != nil {
+		return newDoctorReport([]HealthCheck{{Name: "config", Status: CheckError, Message: "Failed to get config: " + err.Error()}})
+	}
+
+	claude := claudeCLI.Refresh(claudeCommand(config))
+	checks := []HealthCheck{
+		checkClaude(claude, config.Claude),
+		checkGit(),
+		checkGitHubToken(config),
+		checkDatabase(db),
+		checkUploadsDir(),
+	}
+	diskPaths := []string{UploadsDir}
+	if path := db.Path(); path != "" {
+		diskPaths = append(diskPaths, filepath.Dir(path))
+	}
+	checks = append(checks, checkDiskSpace(diskPaths)...)
+	checks = append(checks, checkProjects(db)...)
+	report := newDoctorReport(checks)
+	report.Claude = claude
+	return report
+}
+
+// logStartupChecks runs all checks and logs the ones that did not pass
+func logStartupChecks(db Store) {
+	report := runDoctor(db)
+	logger := componentLog("doctor")
+	for _, check := range report.Checks {
+		switch check.Status {
+		case CheckWarning:
+			logger.Warn(check.Message, "check", check.Name, "hint", check.Hint)
+		case CheckError:
+			logger.Error(check.Message, "check", check.Name, "hint", check.Hint)
+		}
+	}
+	if report.Status == CheckOK {
+		logger.Info("All environment checks passed")
+	}
+}
+
+// HandleHealth handles GET /api/health
+// Answers 200 while the database and the uploads directory are writable, 503 otherwise.
+func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	report := newDoctorReport([]HealthCheck{checkDatabase(h.db), checkUploadsDir()})
+	status := http.StatusOK
+	if report.Status == CheckError {
+		status = http.StatusServiceUnavailable
+	}
+	h.writeJSON(w, status, report)
+}
+
+// HandleDoctor handles GET /api/doctor
+// Runs all environment checks; problems are part of the report, not an error response.
+func (h *Handler) HandleDoctor(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, runDoctor(h.db))
+}
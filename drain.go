@@ -0,0 +1,284 @@
+// drain.go lets FORGE shut down without killing RALPH in the middle of an
+// edit. On SIGINT/SIGTERM the runner stops starting tasks and gives running
+// ones FORGE_DRAIN_TIMEOUT to reach a checkpoint, the end of an iteration.
+// Work that is still uncommitted then goes into a WIP commit on the working
+// branch and the task is marked resumable. With FORGE_AUTO_RESUME=true such
+// tasks, and those a crash interrupted, are continued on the next start with
+// a summary of their progress; otherwise Resume continues them.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ProcessStatusResumable marks a task that was stopped by a shutdown
+const ProcessStatusResumable = "resumable"
+
+// defaultDrainTimeout is how long running tasks get to reach a checkpoint
+const defaultDrainTimeout = 2 * time.Minute
+
+// drainExitWait is how long a stopped process gets to exit before its work is committed
+const drainExitWait = 10 * time.Second
+
+// resumeSummaryLimit caps the parts of the resume message taken from logs and git
+const resumeSummaryLimit = 3000
+
+// errDraining is returned for starts refused while the server shuts down
+var errDraining = errors.New("server is shutting down, tasks cannot be started")
+
+// drainingNote tells the user why a start was turned into a queue entry
+const drainingNote = "Server is shutting down, the task will start after the restart"
+
+// drainTimeoutFromEnv reads FORGE_DRAIN_TIMEOUT (e.g. 5m; 0 stops tasks right away)
+func drainTimeoutFromEnv() time.Duration {
+	v := os.Getenv("FORGE_DRAIN_TIMEOUT")
+	if v == "" {
+		return defaultDrainTimeout
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("runner").Warn("Ignoring invalid FORGE_DRAIN_TIMEOUT", "value", v)
+		return defaultDrainTimeout
+	}
+	return d
+}
+
+// isDraining reports whether the runner refuses new starts
+func (r *RalphRunner) isDraining() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.draining
+}
+
+// Drain stops starting tasks and waits until every running task has finished
+// or reached a checkpoint, at most until ctx is done. Interrupted tasks get a
+// WIP commit and are marked resumable.
+func (r *RalphRunner) Drain(ctx context.Context) {
+	r.mu.Lock()
+	r.draining = true
+	procs := make([]*RalphProcess, 0, len(r.processes))
+	for _, proc := range r.processes {
+		procs = append(procs, proc)
+	}
+	r.mu.Unlock()
+
+	if len(procs) == 0 {
+		return
+	}
+	deadline, _ := ctx.Deadline()
+	componentLog("runner").Info("Draining running tasks", "count", len(procs), "until", deadline.Format(time.TimeOnly))
+
+	done := make(chan struct{}, len(procs))
+	for _, proc := range procs {
+		go func() {
+			r.drainProcess(ctx, proc)
+			done <- struct{}{}
+		}()
+	}
+	for range procs {
+		<-done
+	}
+}
+
+// drainProcess lets one process run to its next checkpoint, then stops it
+// and saves its work
+func (r *RalphRunner) drainProcess(ctx context.Context, proc *RalphProcess) {
+	r.hub.BroadcastLog(proc.TaskID, "\n[FORGE] Server is shutting down, stopping after the current iteration...\n")
+
+	// A paused process makes no progress towards a checkpoint
+	if proc.isPaused() {
+		proc.log.Info("Task is paused, stopping for shutdown")
+	} else {
+		select {
+		case <-proc.done:
+			return // Finished on its own
+		case <-proc.checkpoint:
+			proc.log.Info("Task reached a checkpoint, stopping for shutdown")
+		case <-ctx.Done():
+			proc.log.Warn("Stopping task before its checkpoint (drain timeout or second signal)")
+		}
+	}
+
+	proc.mu.Lock()
+	proc.drained = true
+	started := proc.cmd != nil
+	proc.mu.Unlock()
+
+	r.Stop(proc.TaskID)
+	if !started {
+		return
+	}
+	select {
+	case <-proc.done:
+	case <-time.After(drainExitWait):
+		proc.log.Warn("Process did not exit in time")
+	}
+
+	r.saveInterruptedWork(proc)
+}
+
+// reachedCheckpoint is called when a task finished an iteration. While
+// draining, that is where its process is stopped.
+func (r *RalphRunner) reachedCheckpoint(taskID string) {
+	r.mu.RLock()
+	proc, exists := r.processes[taskID]
+	draining := r.draining
+	r.mu.RUnlock()
+
+	if exists && draining {
+		select {
+		case proc.checkpoint <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// saveInterruptedWork commits what a stopped task left in its working tree
+// and marks the task resumable
+func (r *RalphRunner) saveInterruptedWork(proc *RalphProcess) {
+	taskID := proc.TaskID
+	task, _ := r.db.GetTask(taskID)
+	if task == nil {
+		return
+	}
+
+	note := "Interrupted by server shutdown"
+	if proc.dir != "" && IsGitRepository(proc.dir) {
+		defer lockRepo(proc.dir, "saving interrupted work")()
+		if dirty, err := HasUncommittedChanges(proc.dir); err == nil && dirty {
+			hash, err := CommitAllChanges(proc.dir, taskCommitMessage(r.db, task, "WIP: "+task.Title))
+			if err != nil {
+				proc.log.Error("Failed to commit unfinished work", "err", err)
+				note += ", unfinished changes are left uncommitted"
+			} else {
+				proc.log.Info("Committed unfinished work", "commit", hash)
+				note += ", unfinished work saved in WIP commit " + hash[:min(7, len(hash))]
+			}
+		}
+	}
+
+	r.db.UpdateTaskStatus(taskID, StatusBlocked)
+	r.db.UpdateTaskError(taskID, note+". Resume to continue.")
+	r.db.UpdateTaskProcessInfo(taskID, 0, ProcessStatusResumable)
+	r.hub.BroadcastLog(taskID, "[FORGE] "+note+"\n")
+	if updated, _ := r.db.GetTask(taskID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+}
+
+// resumeMessage is the continuation message for an interrupted task: what it
+// had done according to its logs and the changes since its rollback tag
+func resumeMessage(db Store, task *Task) string {
+	var sb strings.Builder
+	sb.WriteString("FORGE was restarted while you were working on this task, so your previous run was interrupted. ")
+	sb.WriteString("Your changes are still there: uncommitted in the working tree or in a WIP commit on the current branch.\n\n")
+
+	if task.CurrentIteration > 0 {
+		fmt.Fprintf(&sb, "You had reached iteration %d of %d.\n\n", task.CurrentIteration, task.MaxIterations)
+	}
+	if last := lastRalphMessage(task.Logs); last != "" {
+		sb.WriteString("Your last message before the interruption:\n\n")
+		sb.WriteString(truncateText(last, resumeSummaryLimit))
+		sb.WriteString("\n\n")
+	}
+
+	dir := task.ProjectDir
+	if dir == "" && task.ProjectID != "" {
+		if project, _ := db.GetProject(task.ProjectID); project != nil {
+			dir = project.Path
+		}
+	}
+	if dir != "" && task.RollbackTag != "" && IsGitRepository(dir) {
+		if stat, err := DiffStatSince(dir, task.RollbackTag); err == nil && stat != "" {
+			fmt.Fprintf(&sb, "Files changed since the task started (git diff --stat %s):\n\n```\n%s\n```\n\n", task.RollbackTag, truncateText(stat, resumeSummaryLimit))
+		}
+	}
+
+	sb.WriteString("Review the current state of the code first, then continue with the remaining work.")
+	return sb.String()
+}
+
+// truncateText shortens s to about limit bytes, keeping the end, which is
+// the most recent part of logs and the summary line of a diffstat
+func truncateText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := len(s) - limit
+	for cut < len(s) && !utf8.RuneStart(s[cut]) {
+		cut++
+	}
+	return "[...]" + s[cut:]
+}
+
+// deferStart queues a task that was to be started while draining or while
+// the queue is paused (see queuepause.go), so it starts later instead; note
+// tells the user when
+func (r *RalphRunner) deferStart(ctx context.Context, task *Task, feedback, note string) {
+	logFrom(ctx).Info("Queued task instead of starting it", "component", "runner", "task_id", task.ID, "note", note)
+
+	var err error
+	if feedback != "" {
+		err = r.db.AddToQueueWithMessage(task.ID, feedback)
+	} else {
+		err = r.db.AddToQueue(task.ID)
+	}
+	if err != nil {
+		r.taskLog(task.ID).Error("Failed to queue task", "err", err)
+		return
+	}
+	r.hub.BroadcastLog(task.ID, "[FORGE] "+note+"\n")
+	if updated, _ := r.db.GetTask(task.ID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+}
+
+// resumeInterruptedTasks queues the tasks a shutdown or crash interrupted,
+// with a summary of their progress, if FORGE_AUTO_RESUME is set. Call it
+// before the queue is started.
+func resumeInterruptedTasks(db Store, hub *Hub) {
+	logger := componentLog("recovery")
+	tasks, err := db.GetResumableTasks()
+	if err != nil {
+		logger.Warn("Failed to get resumable tasks", "err", err)
+		return
+	}
+
+	var resumable []Task
+	for _, task := range tasks {
+		// Tasks moved elsewhere since the shutdown are left alone
+		if task.Status == StatusBlocked {
+			resumable = append(resumable, task)
+		}
+	}
+	if len(resumable) == 0 {
+		return
+	}
+	if os.Getenv("FORGE_AUTO_RESUME") != "true" {
+		logger.Info("Interrupted tasks can be resumed", "count", len(resumable))
+		return
+	}
+
+	for _, task := range resumable {
+		// The process query leaves out fields the summary needs, like the rollback tag
+		full, err := db.GetTask(task.ID)
+		if err != nil || full == nil {
+			logger.Warn("Failed to get interrupted task", "task_id", task.ID, "err", err)
+			continue
+		}
+		if err := db.AddToQueueWithMessage(task.ID, resumeMessage(db, full)); err != nil {
+			logger.Warn("Failed to queue interrupted task", "task_id", task.ID, "err", err)
+			continue
+		}
+		logger.Info("Resuming interrupted task", "task_id", task.ID)
+		if updated, _ := db.GetTask(task.ID); updated != nil {
+			hub.BroadcastTaskUpdate(updated)
+		}
+	}
+}
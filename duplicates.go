@@ -0,0 +1,111 @@
+// duplicates.go detects tasks that are probably created twice. Before a task
+// is created, its title and description are compared with the open tasks of
+// the same project by trigram similarity. Likely duplicates are returned
+// instead of creating the task, unless the request sets "force", so two
+// queued tasks do not implement the same thing twice.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// duplicateThreshold is the similarity from which a task counts as a likely
+// duplicate
+const duplicateThreshold = 0.5
+
+// maxDuplicates is the most likely duplicates returned for a new task
+const maxDuplicates = 5
+
+// trigrams returns the set of character trigrams of the words in s. Case and
+// punctuation are ignored, every word is padded so short words still count.
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// trigramSimilarity returns the Jaccard similarity of two trigram sets
+func trigramSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for gram := range a {
+		if b[gram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// taskSimilarity compares a new task with an existing one. The title weighs
+// most; the descriptions only count when both tasks have one.
+func taskSimilarity(title, description string, task *Task) float64 {
+	score := trigramSimilarity(trigrams(title), trigrams(task.Title))
+	if strings.TrimSpace(description) != "" && strings.TrimSpace(task.Description) != "" {
+		descScore := trigramSimilarity(trigrams(description), trigrams(task.Description))
+		score = max(score, 0.6*score+0.4*descScore)
+	}
+	return score
+}
+
+// findDuplicateTasks returns the open tasks of the request's project that
+// are most similar to the new task, most similar first
+func (h *Handler) findDuplicateTasks(req CreateTaskRequest) ([]DuplicateTask, error) {
+	tasks, err := h.db.GetAllTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(map[TaskStatus]string)
+	duplicates := []DuplicateTask{}
+	for i := range tasks {
+		task := &tasks[i]
+		if task.ProjectID != req.ProjectID {
+			continue
+		}
+		role, ok := roles[task.Status]
+		if !ok {
+			role = h.columnRole(task.Status)
+			roles[task.Status] = role
+		}
+		if role == ColumnRoleTerminal {
+			continue
+		}
+		score := taskSimilarity(req.Title, req.Description, task)
+		if score >= duplicateThreshold {
+			duplicates = append(duplicates, DuplicateTask{
+				ID:         task.ID,
+				Title:      task.Title,
+				Status:     task.Status,
+				Similarity: score,
+			})
+		}
+	}
+
+	sort.SliceStable(duplicates, func(i, j int) bool { return duplicates[i].Similarity > duplicates[j].Similarity })
+	if len(duplicates) > maxDuplicates {
+		duplicates = duplicates[:maxDuplicates]
+	}
+	return duplicates, nil
+}
+
+// duplicateError describes the likely duplicates of a new task
+func duplicateError(duplicates []DuplicateTask) string {
+	titles := make([]string, 0, len(duplicates))
+	for _, dup := range duplicates {
+		titles = append(titles, fmt.Sprintf("%q (%s, %.0f%% similar)", dup.Title, dup.Status, dup.Similarity*100))
+	}
+	return "Similar tasks already exist: " + strings.Join(titles, ", ") + "; set force to create it anyway"
+}
@@ -0,0 +1,280 @@
+// eta.go estimates when queued tasks start and finish. A run is expected to
+// take as long as the median of the recent runs of the same project and task
+// type, falling back to the project's runs, then to all runs, then to
+// defaultRunEstimate. The queue is then played forward the way the dispatcher
+// works through it: every project lane runs one task at a time, at most
+// Config.MaxConcurrentTasks run at once and the boards' queue policies pick
+// between the lanes that are free first. A task retried after a failed run
+// starts no earlier than its retry is due, and none before a cooldown after a
+// rate limit ends. The estimates are computed whenever tasks are read, so they
+// follow every change of the queue. While the queue is paused there are none;
+// dependencies and projects sharing a repository are not taken into account.
+package main
+
+import (
+	"maps"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// defaultRunEstimate is the expected duration of a run without any history
+const defaultRunEstimate = 30 * time.Minute
+
+// runEstimator predicts the duration of a run from finished runs
+type runEstimator struct {
+	byType    map[string]time.Duration // By project and task type
+	byProject map[string]time.Duration
+	overall   time.Duration
+}
+
+// newRunEstimator computes the median durations of runs
+func newRunEstimator(runs []RunDuration) *runEstimator {
+	byType := make(map[string][]time.Duration)
+	byProject := make(map[string][]time.Duration)
+	var all []time.Duration
+	for _, run := range runs {
+		key := run.ProjectID + "/" + run.TaskTypeID
+		byType[key] = append(byType[key], run.Duration)
+		byProject[run.ProjectID] = append(byProject[run.ProjectID], run.Duration)
+		all = append(all, run.Duration)
+	}
+
+	e := &runEstimator{
+		byType:    make(map[string]time.Duration, len(byType)),
+		byProject: make(map[string]time.Duration, len(byProject)),
+		overall:   defaultRunEstimate,
+	}
+	for key, durations := range byType {
+		e.byType[key] = medianDuration(durations)
+	}
+	for key, durations := range byProject {
+		e.byProject[key] = medianDuration(durations)
+	}
+	if len(all) > 0 {
+		e.overall = medianDuration(all)
+	}
+	return e
+}
+
+// medianDuration returns the median, so a few runs that waited for feedback
+// overnight do not skew the estimate
+func medianDuration(durations []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// estimate returns the expected duration of a run of task
+func (e *runEstimator) estimate(task *Task) time.Duration {
+	if d, ok := e.byType[task.ProjectID+"/"+task.TaskTypeID]; ok {
+		return d
+	}
+	if d, ok := e.byProject[task.ProjectID]; ok {
+		return d
+	}
+	return e.overall
+}
+
+// computeQueueETAs plays the queue forward from now. queued must be ordered as
+// by GetQueuedTasks. Running tasks get an estimated finish, queued ones an
+// estimated start and finish; a running task that takes longer than expected
+// is assumed to finish now. No queued task starts before openAt, the end of a
+// cooldown after a rate limit or of a used-up global budget, nor a task of a
+// project in laneOpenAt before its time, when the project's budget resets.
+func computeQueueETAs(queued, running []Task, config *Config, boards map[string]Board, est *runEstimator, lastProjects map[string]string, now, openAt time.Time, laneOpenAt map[string]time.Time) map[string]QueueETA {
+	etas := make(map[string]QueueETA, len(queued)+len(running))
+	laneFreeAt := make(map[string]time.Time, len(laneOpenAt))
+	for projectID, at := range laneOpenAt {
+		laneFreeAt[projectID] = at
+	}
+	var slots []time.Time // When each of the running tasks ends
+
+	for i := range running {
+		task := &running[i]
+		finish := now
+		if task.StartedAt != nil {
+			finish = laterTime(task.StartedAt.Add(est.estimate(task)), now)
+		}
+		etas[task.ID] = QueueETA{TaskID: task.ID, EtaFinish: &finish}
+		laneFreeAt[task.ProjectID] = laterTime(laneFreeAt[task.ProjectID], finish)
+		slots = append(slots, finish)
+	}
+
+	// Above the limit a task only starts once enough running ones have ended
+	limit := max(config.MaxConcurrentTasks, 1)
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Before(slots[j]) })
+	if len(slots) > limit {
+		slots = slots[len(slots)-limit:]
+	}
+	for len(slots) < limit {
+		slots = append(slots, now)
+	}
+	for i := range slots {
+		slots[i] = laterTime(slots[i], openAt)
+	}
+
+	remaining := append([]Task(nil), queued...)
+	for len(remaining) > 0 {
+		slot := 0
+		for i := range slots {
+			if slots[i].Before(slots[slot]) {
+				slot = i
+			}
+		}
+
+		// The lanes that are free first compete for the slot; a retry waits
+		// for its backoff
+		readyAt := func(task *Task) time.Time {
+			at := laterTime(slots[slot], laneFreeAt[task.ProjectID])
+			if task.RetryAt != nil {
+				at = laterTime(at, *task.RetryAt)
+			}
+			return at
+		}
+		var start time.Time
+		for i := range remaining {
+			at := readyAt(&remaining[i])
+			if i == 0 || at.Before(start) {
+				start = at
+			}
+		}
+		var ready []Task
+		for _, task := range remaining {
+			if !readyAt(&task).After(start) {
+				ready = append(ready, task)
+			}
+		}
+
+		next := selectNextQueued(ready, boards, lastProjects, start)
+		finish := start.Add(est.estimate(next))
+		etas[next.ID] = QueueETA{TaskID: next.ID, EtaStart: &start, EtaFinish: &finish}
+		slots[slot] = finish
+		laneFreeAt[next.ProjectID] = finish
+		lastProjects[next.BoardID] = next.ProjectID
+
+		for i := range remaining {
+			if remaining[i].ID == next.ID {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	return etas
+}
+
+// laterTime returns the later of a and b
+func laterTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// queueETAs estimates the start and finish of the queued and running tasks;
+// nil while the queue is paused
+func (h *Handler) queueETAs() (map[string]QueueETA, error) {
+	state, err := h.db.GetQueueState()
+	if err != nil {
+		return nil, err
+	}
+	if state.Paused {
+		return nil, nil
+	}
+	config, err := h.db.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	queued, err := h.db.GetQueuedTasks()
+	if err != nil {
+		return nil, err
+	}
+	boards, err := h.db.GetBoards()
+	if err != nil {
+		return nil, err
+	}
+	running, lastProjects := h.runner.runningTasks()
+	if len(queued) == 0 && len(running) == 0 {
+		return nil, nil
+	}
+	runs, err := h.db.GetRunDurations()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	openAt := now
+	if state.CooldownUntil != nil {
+		openAt = laterTime(now, *state.CooldownUntil)
+	}
+	// Used-up cost budgets hold their tasks until they reset (see budget.go)
+	exhausted := h.runner.exhaustedBudgets(now)
+	openAt = laterTime(openAt, exhausted.global)
+	return computeQueueETAs(queued, running, config, boardsByID(boards), newRunEstimator(runs), lastProjects, now, openAt, exhausted.projects), nil
+}
+
+// runningTasks returns the tasks with a running process and a copy of the
+// projects of the tasks last started from the boards' queues
+func (r *RalphRunner) runningTasks() ([]Task, map[string]string) {
+	r.mu.RLock()
+	ids := make([]string, 0, len(r.processes))
+	for id := range r.processes {
+		ids = append(ids, id)
+	}
+	lastProjects := maps.Clone(r.lastQueueProject)
+	r.mu.RUnlock()
+
+	var tasks []Task
+	for _, id := range ids {
+		if task, err := r.db.GetTask(id); err == nil && task != nil {
+			tasks = append(tasks, *task)
+		}
+	}
+	return tasks, lastProjects
+}
+
+// applyQueueETAs sets the estimates on the tasks
+func applyQueueETAs(tasks []Task, etas map[string]QueueETA) {
+	for i := range tasks {
+		if eta, ok := etas[tasks[i].ID]; ok {
+			tasks[i].EtaStart = eta.EtaStart
+			tasks[i].EtaFinish = eta.EtaFinish
+		}
+	}
+}
+
+// withQueueETAs sets the estimates on the tasks; without them the tasks are
+// still served
+func (h *Handler) withQueueETAs(r *http.Request, tasks []Task) {
+	etas, err := h.queueETAs()
+	if err != nil {
+		logFrom(r.Context()).Warn("Failed to estimate queue times", "component", "queue", "err", err)
+		return
+	}
+	applyQueueETAs(tasks, etas)
+}
+
+// HandleQueueETAs handles GET /api/queue/eta
+// Returns the estimated finish of the running tasks, then the estimated start
+// and finish of the queued tasks, soonest first.
+func (h *Handler) HandleQueueETAs(w http.ResponseWriter, r *http.Request) {
+	etas, err := h.queueETAs()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to estimate queue times: "+err.Error())
+		return
+	}
+	list := make([]QueueETA, 0, len(etas))
+	for _, eta := range etas {
+		list = append(list, eta)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if (a.EtaStart == nil) != (b.EtaStart == nil) {
+			return a.EtaStart == nil
+		}
+		if a.EtaStart == nil {
+			return a.EtaFinish.Before(*b.EtaFinish)
+		}
+		return a.EtaStart.Before(*b.EtaStart)
+	})
+	h.writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxImportSize is the maximum size of an import upload (JSON or zip, 1GB)
+const MaxImportSize = 1024 * 1024 * 1024
+
+// exportManifestName is the name of the board snapshot inside an export zip
+const exportManifestName = "board.json"
+
+// BuildBoardExport collects a full snapshot of the board from the database.
+// The GitHub, Jira and Linear tokens are stripped from the config before it leaves the server.
+func BuildBoardExport(db Store) (*BoardExport, error) {
+	config, err := db.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %v", err)
+	}
+	config.GithubToken = ""
+	config.JiraToken = ""
+	config.LinearToken = ""
+
+	projects, err := db.GetAllProjects()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get projects: %v", err)
+	}
+	taskTypes, err := db.GetAllTaskTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task types: %v", err)
+	}
+	labels, err := db.GetAllLabels()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get labels: %v", err)
+	}
+	columns, err := db.GetBoardColumns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %v", err)
+	}
+	boards, err := db.GetBoards()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get boards: %v", err)
+	}
+	rules, err := db.GetAllBranchRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branch rules: %v", err)
+	}
+	tasks, err := db.GetAllTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %v", err)
+	}
+	comments, err := db.GetAllComments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %v", err)
+	}
+
+	for i := range tasks {
+		if attachments, err := db.GetAttachmentsByTask(tasks[i].ID); err == nil {
+			tasks[i].Attachments = attachments
+		}
+		// Joined details are re-derived on import
+		tasks[i].TaskType = nil
+		tasks[i].Project = nil
+	}
+
+	export := &BoardExport{
+		Version:      BoardExportVersion,
+		ForgeVersion: Version,
+		ExportedAt:   time.Now(),
+		Config:       config,
+		Projects:     projects,
+		TaskTypes:    taskTypes,
+		Labels:       labels,
+		Columns:      columns,
+		Boards:       boards,
+		BranchRules:  rules,
+		Tasks:        tasks,
+		Comments:     comments,
+	}
+	if export.Projects == nil {
+		export.Projects = []Project{}
+	}
+	if export.TaskTypes == nil {
+		export.TaskTypes = []TaskType{}
+	}
+	if export.BranchRules == nil {
+		export.BranchRules = []BranchProtectionRule{}
+	}
+	if export.Tasks == nil {
+		export.Tasks = []Task{}
+	}
+	return export, nil
+}
+
+// attachmentArchivePath returns the location of an attachment inside an export zip
+func attachmentArchivePath(att Attachment) string {
+	return path.Join("attachments", att.TaskID, att.ID+filepath.Ext(att.Path))
+}
+
+// HandleExport handles GET /api/export
+// Returns the board as JSON, or as a zip including attachment files with ?format=zip.
+func (h *Handler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	export, err := BuildBoardExport(h.db)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to export board: "+err.Error())
+		return
+	}
+
+	stamp := export.ExportedAt.Format("20060102-150405")
+
+	if r.URL.Query().Get("format") != "zip" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"forge-export-%s.json\"", stamp))
+		h.writeJSON(w, http.StatusOK, export)
+		return
+	}
+
+	// Zip exports with large videos can exceed the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(10 * time.Minute))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"forge-export-%s.zip\"", stamp))
+	w.WriteHeader(http.StatusOK)
+
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	manifest, err := zw.Create(exportManifestName)
+	if err != nil {
+		logFrom(r.Context()).Error("Failed to create manifest entry", "component", "export", "err", err)
+		return
+	}
+	encoder := json.NewEncoder(manifest)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		logFrom(r.Context()).Error("Failed to write manifest", "component", "export", "err", err)
+		return
+	}
+
+	for _, task := range export.Tasks {
+		for _, att := range task.Attachments {
+			if err := addFileToZip(zw, att.Path, attachmentArchivePath(att)); err != nil {
+				// Missing files should not abort the whole export
+				logFrom(r.Context()).Warn("Skipping attachment", "component", "export", "path", att.Path, "err", err)
+			}
+		}
+	}
+}
+
+// addFileToZip copies a file from disk into the zip archive
+func addFileToZip(zw *zip.Writer, srcPath string, name string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// HandleImport handles POST /api/import
+// Accepts a JSON snapshot or a zip produced by GET /api/export?format=zip.
+// Query parameters:
+//   - mode: skip (default), overwrite or duplicate - how to handle existing records
+//   - config: true to also restore the exported configuration
+func (h *Handler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = ImportModeSkip
+	}
+	if mode != ImportModeSkip && mode != ImportModeOverwrite && mode != ImportModeDuplicate {
+		h.writeError(w, http.StatusBadRequest, "Invalid mode. Allowed: skip, overwrite, duplicate")
+		return
+	}
+	importConfig := r.URL.Query().Get("config") == "true"
+
+	http.NewResponseController(w).SetReadDeadline(time.Now().Add(10 * time.Minute))
+
+	// Spool the upload to a temp file so zip archives can be read randomly
+	tmp, err := os.CreateTemp("", "forge-import-*")
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create temp file: "+err.Error())
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "No file provided")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	size, err := io.Copy(tmp, body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Failed to read upload: "+err.Error())
+		return
+	}
+
+	// Detect zip by its magic bytes rather than trusting the content type
+	header := make([]byte, 4)
+	tmp.ReadAt(header, 0)
+	isZip := string(header) == "PK\x03\x04"
+
+	var data BoardExport
+	var archive *zip.Reader
+	if isZip {
+		archive, err = zip.NewReader(tmp, size)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid zip archive: "+err.Error())
+			return
+		}
+		manifest, err := archive.Open(exportManifestName)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Zip archive has no "+exportManifestName)
+			return
+		}
+		err = json.NewDecoder(manifest).Decode(&data)
+		manifest.Close()
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON in "+exportManifestName+": "+err.Error())
+			return
+		}
+	} else {
+		tmp.Seek(0, io.SeekStart)
+		if err := json.NewDecoder(tmp).Decode(&data); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+	}
+
+	if data.Version == 0 || data.Version > BoardExportVersion {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported export version %d", data.Version))
+		return
+	}
+
+	// Never overwrite tasks that currently have a running Claude process
+	var skippedRunning []string
+	if mode == ImportModeOverwrite {
+		filtered := data.Tasks[:0]
+		for _, t := range data.Tasks {
+			if h.runner.IsRunning(t.ID) {
+				skippedRunning = append(skippedRunning, t.ID)
+				continue
+			}
+			filtered = append(filtered, t)
+		}
+		data.Tasks = filtered
+	}
+
+	result, err := h.db.ImportBoard(&data, mode, importConfig)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Import failed: "+err.Error())
+		return
+	}
+	if importConfig {
+		loadConfigGitHub(h.db)
+	}
+	h.reloadSigning()
+	for _, id := range skippedRunning {
+		result.Skipped["tasks"]++
+		result.Warnings = append(result.Warnings, "Task "+id+" is running and was not overwritten")
+	}
+
+	// Restore attachment files for newly created tasks
+	for _, t := range data.Tasks {
+		newTaskID, ok := result.createdTasks[t.ID]
+		if !ok {
+			continue
+		}
+		for _, att := range t.Attachments {
+			if archive == nil {
+				result.Skipped["attachments"]++
+				continue
+			}
+			if err := h.restoreAttachment(archive, att, newTaskID); err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Attachment %s: %v", att.Filename, err))
+				result.Skipped["attachments"]++
+				continue
+			}
+			result.Created["attachments"]++
+		}
+	}
+	if archive == nil && result.Skipped["attachments"] > 0 {
+		result.Warnings = append(result.Warnings, "Attachment files are only restored from zip exports")
+	}
+
+	// Let connected clients pick up the imported records
+	if result.Created["tasks"]+result.Updated["tasks"] > 0 {
+		if tasks, err := h.db.GetAllTasks(); err == nil {
+			for i := range tasks {
+				h.hub.BroadcastTaskUpdate(&tasks[i])
+			}
+		}
+	}
+	if result.Created["projects"]+result.Updated["projects"] > 0 {
+		if projects, err := h.db.GetAllProjects(); err == nil {
+			for i := range projects {
+				h.hub.BroadcastProjectUpdate(&projects[i])
+			}
+		}
+	}
+
+	logFrom(r.Context()).Info("Board imported", "component", "import", "mode", mode, "created", result.Created, "updated", result.Updated, "skipped", result.Skipped)
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// restoreAttachment copies an attachment out of the archive into the uploads directory
+// and creates the attachment record for the (possibly remapped) task.
+func (h *Handler) restoreAttachment(archive *zip.Reader, att Attachment, taskID string) error {
+	src, err := archive.Open(attachmentArchivePath(att))
+	if err != nil {
+		return fmt.Errorf("missing in archive")
+	}
+	defer src.Close()
+
+	taskUploadDir := filepath.Join(UploadsDir, taskID)
+	if err := os.MkdirAll(taskUploadDir, 0755); err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(taskUploadDir, uuid.New().String()+filepath.Ext(att.Path))
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(dst, src)
+	dst.Close()
+	if err != nil {
+		os.Remove(filePath)
+		return err
+	}
+
+	attachment := &Attachment{
+		ID:        uuid.New().String(),
+		TaskID:    taskID,
+		Filename:  att.Filename,
+		MimeType:  att.MimeType,
+		Size:      size,
+		Path:      filePath,
+		CreatedAt: att.CreatedAt,
+	}
+	if err := h.db.CreateAttachment(attachment); err != nil {
+		os.Remove(filePath)
+		return err
+	}
+	go h.generateThumbnail(*attachment)
+	return nil
+}
@@ -0,0 +1,224 @@
+// failures.go classifies why a run failed, i.e. why Claude's process ended
+// without a marker and either exited with an error or reported an error
+// result. The class is read from the error result of the stream-json output
+// and from stderr, and for a process killed without a message from how it
+// ended: rate limited, not logged in, out of memory, out of context or any
+// other transient failure. It is stored with the run (TaskRun.FailureClass)
+// and, when the task is blocked because of it, with the task
+// (Task.ErrorClass). Every class is recovered from differently, see
+// recoverFailedRun in retry.go.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// failurePatterns are (lowercase) signs of each class in the error output,
+// checked in this order; everything else is FailureTransient
+var failurePatterns = []struct {
+	class    string
+	patterns []string
+}{
+	{FailureAuth, []string{
+		"invalid api key",
+		"invalid x-api-key",
+		"authentication_error",
+		"authentication failed",
+		"not logged in",
+		"please run /login",
+		"oauth token has expired",
+		"api error: 401",
+		"api error: 403",
+		"credit balance is too low",
+	}},
+	{FailureContextLimit, []string{
+		"prompt is too long",
+		"context window",
+		"context length",
+		"context limit",
+		"maximum context",
+	}},
+	{FailureRateLimit, []string{
+		"rate_limit_error",
+		"rate limit",
+		"usage limit",
+		"too many requests",
+		"api error: 429",
+	}},
+	{FailureOOM, []string{
+		"out of memory",
+		"enomem",
+		"cannot allocate memory",
+	}},
+	{FailureTransient, []string{
+		"api error",
+		"connection error",
+		"network error",
+		"overloaded",
+		"socket hang up",
+		"econnreset",
+		"econnrefused",
+		"etimedout",
+		"enotfound",
+		"eai_again",
+		"fetch failed",
+		"request timed out",
+		"internal server error",
+		"service unavailable",
+		"bad gateway",
+	}},
+}
+
+// failureLabels prefix the task error of each class
+var failureLabels = map[string]string{
+	FailureRateLimit:    "Rate limited",
+	FailureAuth:         "Authentication failed",
+	FailureOOM:          "Out of memory",
+	FailureContextLimit: "Context limit reached",
+	FailureTransient:    "Transient failure",
+}
+
+// maxFailureReason caps the reason of a failed run taken from the output
+const maxFailureReason = 300
+
+// runFailure is why a run failed; the zero value means it did not
+type runFailure struct {
+	Class  string
+	Reason string
+}
+
+// failureClassOf returns the class a line of error output points to, "" if none
+func failureClassOf(text string) string {
+	lower := strings.ToLower(text)
+	for _, fc := range failurePatterns {
+		for _, pattern := range fc.patterns {
+			if strings.Contains(lower, pattern) {
+				return fc.class
+			}
+		}
+	}
+	return ""
+}
+
+// classifyFailure returns why a run that ended without a marker failed: the
+// CLI reported an error result, or the process exited with an error. The
+// reason is the error result, else the last line of output that points to a
+// class, else how the process ended.
+func classifyFailure(logs string, waitErr error) runFailure {
+	var resultErr, hint, hintClass string
+	for _, line := range strings.Split(logs, "\n") {
+		text := strings.TrimSpace(line)
+		if strings.HasPrefix(text, "{") {
+			var event struct {
+				Type    string `json:"type"`
+				Subtype string `json:"subtype"`
+				IsError bool   `json:"is_error"`
+				Result  string `json:"result"`
+			}
+			if json.Unmarshal([]byte(text), &event) != nil || event.Type != "result" {
+				continue
+			}
+			if event.IsError || strings.HasPrefix(event.Subtype, "error") {
+				resultErr = firstNonEmpty(event.Result, event.Subtype)
+			}
+			continue
+		}
+		if strings.HasPrefix(text, "[FORGE") {
+			continue
+		}
+		if class := failureClassOf(text); class != "" {
+			hint, hintClass = text, class
+		}
+	}
+
+	var failure runFailure
+	var exitErr *exec.ExitError
+	switch {
+	case resultErr != "":
+		failure.Reason = resultErr
+		// A generic error result is explained by the output, if at all
+		failure.Class = failureClassOf(resultErr)
+		if failure.Class == "" || failure.Class == FailureTransient {
+			failure.Class = firstNonEmpty(hintClass, FailureTransient)
+		}
+	case waitErr == nil:
+		return runFailure{}
+	case hint != "":
+		failure = runFailure{Class: hintClass, Reason: hint}
+	case killedWithoutMessage(waitErr):
+		// Stops by FORGE end as stopped or blocked runs, so this is most
+		// likely the kernel's out-of-memory killer
+		failure = runFailure{Class: FailureOOM, Reason: "Claude was killed (SIGKILL)"}
+	case errors.As(waitErr, &exitErr) && exitErr.Exited():
+		failure = runFailure{Class: FailureTransient, Reason: fmt.Sprintf("Claude exited with code %d", exitErr.ExitCode())}
+	case errors.As(waitErr, &exitErr):
+		failure = runFailure{Class: FailureTransient, Reason: "Claude crashed: " + exitErr.String()}
+	default:
+		failure = runFailure{Class: FailureTransient, Reason: "Claude failed: " + waitErr.Error()}
+	}
+	if len(failure.Reason) > maxFailureReason {
+		failure.Reason = failure.Reason[:maxFailureReason] + "..."
+	}
+	return failure
+}
+
+// killedWithoutMessage reports whether the process was killed by SIGKILL,
+// either itself or, through a wrapper shell, as exit code 137
+func killedWithoutMessage(waitErr error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(waitErr, &exitErr) {
+		return false
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal() == syscall.SIGKILL
+	}
+	return exitErr.ExitCode() == 128+int(syscall.SIGKILL)
+}
+
+// failureError is the task error of a run that failed with class and reason
+func failureError(class, reason string) string {
+	label := failureLabels[class]
+	if label == "" {
+		label = failureLabels[FailureTransient]
+	}
+	msg := label + ": " + reason
+	if class == FailureAuth {
+		msg += " (log in to Claude again or check the API key, then continue the task)"
+	}
+	return msg
+}
+
+// usageLimitReset matches the time Claude's usage limit resets, which the CLI
+// appends to its message as a Unix timestamp ("usage limit reached|1760630400")
+var usageLimitReset = regexp.MustCompile(`limit reached\|(\d{9,11})`)
+
+// rateLimitReset returns when a rate limit named in reason resets, if it says so
+func rateLimitReset(reason string) (time.Time, bool) {
+	m := usageLimitReset.FindStringSubmatch(strings.ToLower(reason))
+	if m == nil {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
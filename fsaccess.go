@@ -0,0 +1,154 @@
+// fsaccess.go limits which directories FORGE reads and creates on behalf of
+// the API. FORGE_BROWSE_ROOTS lists the allowed root directories: the folder
+// browser, project creation, scans, clones and bootstraps are refused outside
+// of them. FORGE_BROWSE_DISABLED turns the folder browser off entirely.
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// errPathNotAllowed is returned for paths outside of the allowed roots
+	errPathNotAllowed = errors.New("path is outside of the allowed directories")
+	// errBrowseDisabled is returned by the folder browser when it is turned off
+	errBrowseDisabled = errors.New("the folder browser is disabled")
+)
+
+// fsAccessSettings is the filesystem access configuration from the environment
+type fsAccessSettings struct {
+	restrict       bool     // FORGE_BROWSE_ROOTS is set
+	roots          []string // Allowed root directories
+	browseDisabled bool
+}
+
+// fsAccess is the process-wide filesystem access configuration
+var fsAccess = fsAccessFromEnv()
+
+// fsAccessFromEnv reads FORGE_BROWSE_ROOTS (separated like PATH) and
+// FORGE_BROWSE_DISABLED
+func fsAccessFromEnv() fsAccessSettings {
+	s := fsAccessSettings{
+		restrict:       os.Getenv("FORGE_BROWSE_ROOTS") != "",
+		browseDisabled: os.Getenv("FORGE_BROWSE_DISABLED") == "true",
+	}
+	for _, root := range filepath.SplitList(os.Getenv("FORGE_BROWSE_ROOTS")) {
+		if root = strings.TrimSpace(root); root == "" {
+			continue
+		}
+		resolved, err := resolvePath(root)
+		if err != nil {
+			componentLog("fs").Warn("Ignoring browse root", "root", root, "err", err)
+			continue
+		}
+		s.roots = append(s.roots, resolved)
+	}
+	if s.restrict && len(s.roots) == 0 {
+		// A typo must not lift the restriction: no path is allowed then
+		componentLog("fs").Warn("FORGE_BROWSE_ROOTS contains no valid directory, all paths are refused")
+	}
+	return s
+}
+
+// resolvePath makes path absolute and resolves symlinks. For paths that do
+// not exist yet, the longest existing parent is resolved, so a symlink in it
+// cannot lead out of a root.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	existing, rest := abs, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+// restricted reports whether FORGE_BROWSE_ROOTS limits filesystem access
+func (s fsAccessSettings) restricted() bool {
+	return s.restrict
+}
+
+// checkPath returns the resolved path, or errPathNotAllowed if it lies
+// outside of the allowed roots
+func (s fsAccessSettings) checkPath(path string) (string, error) {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if !s.restricted() {
+		return resolved, nil
+	}
+	for _, root := range s.roots {
+		if resolved == root || strings.HasPrefix(resolved, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", errPathNotAllowed
+}
+
+// isRoot reports whether path is one of the allowed roots, above which the
+// folder browser does not go
+func (s fsAccessSettings) isRoot(path string) bool {
+	for _, root := range s.roots {
+		if path == root {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultBrowsePath is where the folder browser starts without a path: the
+// home directory, or the first root if the home directory is not allowed
+func (s fsAccessSettings) defaultBrowsePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err == nil {
+		if _, err = s.checkPath(home); err == nil {
+			return home, nil
+		}
+	}
+	if len(s.roots) > 0 {
+		return s.roots[0], nil
+	}
+	if s.restricted() {
+		return "", errPathNotAllowed
+	}
+	return "", err
+}
+
+// setFilesystemAccess tells the frontend whether the folder browser is
+// available and which roots it may use
+func (c *Config) setFilesystemAccess() {
+	c.BrowseEnabled = !fsAccess.browseDisabled
+	c.BrowseRoots = fsAccess.roots
+}
+
+// allowedPath checks path against the allowed roots and writes 403 if it lies
+// outside. Returns the cleaned path and whether the request may go on.
+func (h *Handler) allowedPath(w http.ResponseWriter, path string) (string, bool) {
+	if _, err := fsAccess.checkPath(path); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errPathNotAllowed) {
+			status = http.StatusForbidden
+		}
+		h.writeError(w, status, "Path not allowed: "+err.Error())
+		return "", false
+	}
+	return filepath.Clean(path), true
+}
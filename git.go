@@ -1,12 +1,15 @@
 package main
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 // GitInfo contains repository information
@@ -17,105 +20,44 @@ type GitInfo struct {
 	RemoteURL     string   `json:"remote_url,omitempty"`
 }
 
-// IsGitRepository checks if a path is a git repository
+// IsGitRepository checks if a path is a git repository or a directory in one
 func IsGitRepository(path string) bool {
-	gitDir := filepath.Join(path, ".git")
-	info, err := os.Stat(gitDir)
-	if err != nil {
-		return false
-	}
-	return info.IsDir()
+	return gitRoot(path) != ""
 }
 
 // GetCurrentBranch returns the current branch name for a repository
 func GetCurrentBranch(path string) (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	cmd.Dir = path
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(string(output)), nil
+	return gitReads.CurrentBranch(path)
 }
 
 // ListBranches returns all local branches in a repository
 func ListBranches(path string) ([]string, error) {
-	cmd := exec.Command("git", "branch", "--format=%(refname:short)")
-	cmd.Dir = path
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var branches []string
-	for _, line := range lines {
-		branch := strings.TrimSpace(line)
-		if branch != "" {
-			branches = append(branches, branch)
-		}
-	}
-	return branches, nil
-}
-
-// ListAllBranches returns all branches including remote branches
-func ListAllBranches(path string) ([]string, error) {
-	cmd := exec.Command("git", "branch", "-a", "--format=%(refname:short)")
-	cmd.Dir = path
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var branches []string
-	for _, line := range lines {
-		branch := strings.TrimSpace(line)
-		if branch != "" {
-			branches = append(branches, branch)
-		}
-	}
-	return branches, nil
+	return gitReads.Branches(path)
 }
 
 // GetRemoteURL returns the remote origin URL
 func GetRemoteURL(path string) (string, error) {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	cmd.Dir = path
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(string(output)), nil
+	return gitReads.RemoteURL(path, "origin")
 }
 
-// ParseGitHubRepoFromURL extracts owner/repo from a GitHub remote URL.
-// Supports both HTTPS and SSH formats:
+// ParseGitHubRepoFromURL extracts owner/repo from the URL of a remote on the
+// GitHub host (github.com if empty, else e.g. a GitHub Enterprise host).
+// Supports HTTPS and SSH formats:
 // - https://github.com/owner/repo.git
 // - git@github.com:owner/repo.git
-func ParseGitHubRepoFromURL(remoteURL string) (string, error) {
-	remoteURL = strings.TrimSpace(remoteURL)
-	remoteURL = strings.TrimSuffix(remoteURL, ".git")
-
-	// HTTPS format: https://github.com/owner/repo
-	if strings.HasPrefix(remoteURL, "https://github.com/") {
-		path := strings.TrimPrefix(remoteURL, "https://github.com/")
-		parts := strings.Split(path, "/")
-		if len(parts) >= 2 {
-			return parts[0] + "/" + parts[1], nil
-		}
+// - ssh://git@github.example.com/owner/repo.git
+func ParseGitHubRepoFromURL(remoteURL string, host string) (string, error) {
+	if host == "" {
+		host = "github.com"
 	}
-
-	// SSH format: git@github.com:owner/repo
-	if strings.HasPrefix(remoteURL, "git@github.com:") {
-		path := strings.TrimPrefix(remoteURL, "git@github.com:")
-		parts := strings.Split(path, "/")
-		if len(parts) >= 2 {
-			return parts[0] + "/" + parts[1], nil
-		}
+	remoteHost, repo, ok := parseGitRemote(remoteURL)
+	if !ok {
+		return "", fmt.Errorf("could not parse GitHub repo from URL: %s", remoteURL)
 	}
-
-	return "", fmt.Errorf("could not parse GitHub repo from URL: %s", remoteURL)
+	if remoteHost != strings.ToLower(host) {
+		return "", fmt.Errorf("remote %s is not on the GitHub host %s", remoteURL, host)
+	}
+	return repo, nil
 }
 
 // GetGitInfo retrieves complete git information for a directory
@@ -143,79 +85,50 @@ func GetGitInfo(path string) *GitInfo {
 	return info
 }
 
-// DetectGitRepos scans a directory for git repositories up to maxDepth
-func DetectGitRepos(basePath string, maxDepth int) ([]string, error) {
-	var repos []string
-
-	basePath = filepath.Clean(basePath)
-	baseDepth := strings.Count(basePath, string(os.PathSeparator))
-
-	err := filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip directories we can't access
-		}
-
-		// Calculate current depth
-		currentDepth := strings.Count(path, string(os.PathSeparator)) - baseDepth
+// IsBranchProtected checks if a branch matches any protection rules
+func IsBranchProtected(branch string, rules []BranchProtectionRule) bool {
+	return ProtectingRule(branch, rules) != nil
+}
 
-		// Skip if too deep
-		if currentDepth > maxDepth {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
+// ProtectingRule returns the rule that protects branch, or nil.
+// Rules starting with "!" are exceptions: a branch matching one of them is
+// never protected, whatever else it matches.
+func ProtectingRule(branch string, rules []BranchProtectionRule) *BranchProtectionRule {
+	var protecting *BranchProtectionRule
+	for _, rule := range MatchingBranchRules(branch, rules) {
+		if strings.HasPrefix(rule.BranchPattern, "!") {
 			return nil
 		}
-
-		// Skip hidden directories (except .git which we check for)
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && info.Name() != ".git" {
-			return filepath.SkipDir
-		}
-
-		// Check if this is a git repo
-		if info.IsDir() && info.Name() != ".git" {
-			if IsGitRepository(path) {
-				repos = append(repos, path)
-				return filepath.SkipDir // Don't descend into git repos
-			}
+		if protecting == nil {
+			protecting = &rule
 		}
-
-		return nil
-	})
-
-	return repos, err
+	}
+	return protecting
 }
 
-// IsBranchProtected checks if a branch matches any protection rules
-// Supports simple glob patterns: * matches anything
-func IsBranchProtected(branch string, rules []BranchProtectionRule) bool {
+// MatchingBranchRules returns the rules (including exceptions) that match branch
+func MatchingBranchRules(branch string, rules []BranchProtectionRule) []BranchProtectionRule {
+	matches := []BranchProtectionRule{}
 	for _, rule := range rules {
 		if matchBranchPattern(branch, rule.BranchPattern) {
-			return true
+			matches = append(matches, rule)
 		}
 	}
-	return false
+	return matches
 }
 
-// matchBranchPattern matches a branch name against a pattern
-// Supports * as wildcard (e.g., "release/*" matches "release/v1.0")
+// matchBranchPattern matches a branch name against a glob pattern. * and ?
+// stay within one path segment, ** spans segments ("release/*/hotfix",
+// "feature/**"). A leading "!" is ignored, see ProtectingRule.
 func matchBranchPattern(branch, pattern string) bool {
-	// Exact match
-	if branch == pattern {
-		return true
-	}
-
-	// Wildcard matching
-	if strings.Contains(pattern, "*") {
-		// Convert glob pattern to simple matching
-		parts := strings.Split(pattern, "*")
-		if len(parts) == 2 {
-			prefix := parts[0]
-			suffix := parts[1]
-			return strings.HasPrefix(branch, prefix) && strings.HasSuffix(branch, suffix)
-		}
-	}
+	ok, err := doublestar.Match(strings.TrimPrefix(pattern, "!"), branch)
+	return err == nil && ok
+}
 
-	return false
+// ValidBranchPattern reports whether pattern is a usable branch rule
+func ValidBranchPattern(pattern string) bool {
+	pattern = strings.TrimPrefix(pattern, "!")
+	return pattern != "" && doublestar.ValidatePattern(pattern)
 }
 
 // GetProjectNameFromPath extracts a project name from a path
@@ -252,11 +165,28 @@ func DetectAllProjects(basePath string, maxDepth int) ([]ProjectInfo, error) {
 		// Check if directory looks like a project
 		if info.IsDir() && path != basePath && isProjectDirectory(path) {
 			isGit := IsGitRepository(path)
+			stack := detectStack(path)
 			projects = append(projects, ProjectInfo{
 				Path:      path,
 				Name:      info.Name(),
 				IsGitRepo: isGit,
+				Language:  stack.Language,
+				Framework: stack.Framework,
 			})
+			// Packages of a workspace become projects of their own
+			if isWorkspaceRoot(path) {
+				for _, dir := range workspacePackages(path) {
+					stack := detectStack(dir)
+					projects = append(projects, ProjectInfo{
+						Path:          dir,
+						Name:          workspacePackageName(path, dir),
+						IsGitRepo:     isGit,
+						Language:      stack.Language,
+						Framework:     stack.Framework,
+						WorkspaceRoot: path,
+					})
+				}
+			}
 			return filepath.SkipDir // Don't descend into projects
 		}
 
@@ -280,6 +210,9 @@ func isProjectDirectory(path string) bool {
 		"Makefile",
 		"CMakeLists.txt",
 		".project",
+		"go.work",
+		"pnpm-workspace.yaml",
+		"nx.json",
 		"composer.json",
 		"Gemfile",
 		"pubspec.yaml",
@@ -310,9 +243,10 @@ func InitGitRepository(path string) error {
 	return nil
 }
 
-// HasUncommittedChanges checks if there are uncommitted changes in the repository
+// HasUncommittedChanges checks if there are uncommitted changes in the
+// repository, or in the project's files if it is a subdirectory of one
 func HasUncommittedChanges(path string) (bool, error) {
-	cmd := exec.Command("git", "status", "--porcelain")
+	cmd := exec.Command("git", append([]string{"status", "--porcelain"}, scopePathspec(path)...)...)
 	cmd.Dir = path
 	output, err := cmd.Output()
 	if err != nil {
@@ -324,33 +258,54 @@ func HasUncommittedChanges(path string) (bool, error) {
 // GetCommitsAhead returns the number of commits fromBranch is ahead of toBranch
 // Returns 0 if the branches are identical or fromBranch is behind
 func GetCommitsAhead(path string, fromBranch string, toBranch string) (int, error) {
-	// Use git rev-list to count commits that are in fromBranch but not in toBranch
-	cmd := exec.Command("git", "rev-list", "--count", fmt.Sprintf("%s..%s", toBranch, fromBranch))
-	cmd.Dir = path
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, err
+	return gitReads.CountCommits(path, toBranch, fromBranch)
+}
+
+// TaskTrailerKey is the commit trailer that links a commit to the FORGE task it belongs to
+const TaskTrailerKey = "Forge-Task"
+
+// WithTaskTrailer appends the Forge-Task trailer for taskID to a commit message
+func WithTaskTrailer(message string, taskID string) string {
+	if taskID == "" {
+		return message
 	}
-	countStr := strings.TrimSpace(string(output))
-	var count int
-	fmt.Sscanf(countStr, "%d", &count)
-	return count, nil
+	return strings.TrimRight(message, "\n") + "\n\n" + TaskTrailerKey + ": " + taskID
+}
+
+// gitAuthor is who a commit is attributed to; the zero value leaves it to
+// git's configuration
+type gitAuthor struct {
+	Name  string
+	Email string
 }
 
-// CommitAllChanges stages all changes and commits them
+// configArgs returns the git options that make the author author and
+// committer of new commits
+func (a gitAuthor) configArgs() []string {
+	if a.Name == "" || a.Email == "" {
+		return nil
+	}
+	return []string{"-c", "user.name=" + a.Name, "-c", "user.email=" + a.Email}
+}
+
+// CommitAllChanges stages all changes and commits them. In a subdirectory
+// project only its own files are committed.
 func CommitAllChanges(path string, message string) (string, error) {
+	return CommitAllChangesAs(path, message, gitAuthor{})
+}
+
+// CommitAllChangesAs is CommitAllChanges with the commit attributed to author
+func CommitAllChangesAs(path string, message string, author gitAuthor) (string, error) {
 	// Stage all changes
-	addCmd := exec.Command("git", "add", "-A")
-	addCmd.Dir = path
-	if output, err := addCmd.CombinedOutput(); err != nil {
+	if output, err := runScopedGit(path, "add", "-A"); err != nil {
 		return "", fmt.Errorf("git add failed: %v, output: %s", err, string(output))
 	}
 
 	// Commit with message
-	commitCmd := exec.Command("git", "commit", "-m", message)
-	commitCmd.Dir = path
-	if output, err := commitCmd.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("git commit failed: %v, output: %s", err, string(output))
+	commit := exec.Command("git", append(append(author.configArgs(), "commit", "-m", message), scopePathspec(path)...)...)
+	commit.Dir = path
+	if output, err := runSigned(commit); err != nil {
+		return "", gitFailed("commit", err, output)
 	}
 
 	// Get the commit hash
@@ -366,13 +321,29 @@ func CommitAllChanges(path string, message string) (string, error) {
 
 // PushToRemote pushes the current branch to the remote
 func PushToRemote(path string) error {
+	return PushToRemoteAs(path, "", "")
+}
+
+// PushToRemoteAs pushes the current branch to the remote, authenticated with
+// a GitHub token for remotes on the GitHub instance at webURL ("" = git's own
+// credentials)
+func PushToRemoteAs(path, token, webURL string) error {
 	branch, err := GetCurrentBranch(path)
 	if err != nil {
 		return fmt.Errorf("failed to get current branch: %v", err)
 	}
+	return PushBranchAs(path, branch, token, webURL)
+}
 
+// PushBranchAs pushes a local branch to the remote and sets it as upstream,
+// authenticated like PushToRemoteAs
+func PushBranchAs(path, branch, token, webURL string) error {
 	cmd := exec.Command("git", "push", "-u", "origin", branch)
 	cmd.Dir = path
+	if token != "" {
+		cmd.Env = append(os.Environ(), githubAuthEnv(token, webURL)...)
+	}
+	defer withDeployKey(cmd)()
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("git push failed: %v, output: %s", err, string(output))
@@ -380,6 +351,29 @@ func PushToRemote(path string) error {
 	return nil
 }
 
+// githubAuthEnv returns the environment that makes git send token to the
+// GitHub instance at webURL ("" = github.com) over HTTPS, and to no other
+// host. Passed via environment so the token is neither visible in ps nor
+// stored in .git/config.
+func githubAuthEnv(token, webURL string) []string {
+	if webURL == "" {
+		webURL = defaultGitHubWebURL
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http." + webURL + "/.extraheader",
+		"GIT_CONFIG_VALUE_0=AUTHORIZATION: basic " + auth,
+	}
+}
+
+// isPushRejected reports whether a PushToRemote error means the remote has
+// commits the local branch lacks
+func isPushRejected(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "[rejected]") && (strings.Contains(msg, "fetch first") || strings.Contains(msg, "non-fast-forward"))
+}
+
 // SetRemoteOrigin sets or updates the remote origin URL
 func SetRemoteOrigin(path string, url string) error {
 	// Check if remote exists
@@ -468,9 +462,8 @@ func MergeBranch(path string, sourceBranch string, message string) error {
 		cmd = exec.Command("git", "merge", sourceBranch, "--no-edit")
 	}
 	cmd.Dir = path
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("git merge failed: %v, output: %s", err, string(output))
+	if output, err := runSigned(cmd); err != nil {
+		return gitFailed("merge", err, output)
 	}
 	return nil
 }
@@ -542,6 +535,7 @@ func GenerateWorkingBranchName(taskID string, taskTitle string) string {
 func PullFromRemote(path string) error {
 	cmd := exec.Command("git", "pull", "--ff-only")
 	cmd.Dir = path
+	defer withDeployKey(cmd)()
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("git pull failed: %v, output: %s", err, string(output))
@@ -549,6 +543,71 @@ func PullFromRemote(path string) error {
 	return nil
 }
 
+// MergeUpstream fetches and merges the upstream of the current branch, a fast-forward
+// if possible. If the merge conflicts it is aborted and the conflict is returned, with
+// nil error. Branches without an upstream are left as they are.
+func MergeUpstream(path string) (*MergeConflict, error) {
+	fetch := exec.Command("git", "fetch", "origin")
+	fetch.Dir = path
+	removeKey := withDeployKey(fetch)
+	fetchOutput, err := fetch.CombinedOutput()
+	removeKey()
+	if err != nil {
+		return nil, fmt.Errorf("git fetch failed: %v, output: %s", err, string(fetchOutput))
+	}
+
+	upstreamCmd := exec.Command("git", "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
+	upstreamCmd.Dir = path
+	output, err := upstreamCmd.Output()
+	if err != nil {
+		return nil, nil
+	}
+	upstream := strings.TrimSpace(string(output))
+
+	ancestor := exec.Command("git", "merge-base", "--is-ancestor", upstream, "HEAD")
+	ancestor.Dir = path
+	if ancestor.Run() == nil {
+		return nil, nil
+	}
+
+	ff := exec.Command("git", "merge", "--ff-only", upstream)
+	ff.Dir = path
+	if ff.Run() == nil {
+		return nil, nil
+	}
+
+	merge := exec.Command("git", "merge", "--no-edit", upstream)
+	merge.Dir = path
+	mergeOutput, mergeErr := runSigned(merge)
+	if mergeErr == nil {
+		return nil, nil
+	}
+
+	files, _ := GetConflictFiles(path)
+	AbortMerge(path)
+	if errors.Is(mergeErr, errSigningFailed) {
+		return nil, mergeErr
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("git merge failed: %v, output: %s", mergeErr, string(mergeOutput))
+	}
+
+	branch, _ := GetCurrentBranch(path)
+	upstreamCommit := ""
+	revCmd := exec.Command("git", "rev-parse", upstream)
+	revCmd.Dir = path
+	if rev, err := revCmd.Output(); err == nil {
+		upstreamCommit = strings.TrimSpace(string(rev))
+	}
+	return &MergeConflict{
+		WorkingBranch:  branch,
+		TargetBranch:   upstream,
+		Files:          files,
+		Message:        fmt.Sprintf("Merging %s into %s conflicts in %d file(s)", upstream, branch, len(files)),
+		UpstreamCommit: upstreamCommit,
+	}, nil
+}
+
 // CreateWorkingBranch creates a working branch for a task based on the default branch
 func CreateWorkingBranch(path string, taskID string, taskTitle string) (string, error) {
 	if !IsGitRepository(path) {
@@ -565,7 +624,7 @@ func CreateWorkingBranch(path string, taskID string, taskTitle string) (string,
 
 	// Pull latest changes from remote to ensure we're creating from fresh main
 	if err := PullFromRemote(path); err != nil {
-		log.Printf("Warning: Failed to pull latest changes: %v (continuing)", err)
+		componentLog("git").Warn("Failed to pull latest changes (continuing)", "path", path, "err", err)
 	}
 
 	// Generate branch name
@@ -588,6 +647,22 @@ func CreateWorkingBranch(path string, taskID string, taskTitle string) (string,
 	return branchName, nil
 }
 
+// CheckoutTaskBranch checks out the task's working branch (working/<id>-<slug>),
+// creating it from the current HEAD if it does not exist yet
+func CheckoutTaskBranch(path string, taskID string, taskTitle string) (string, error) {
+	branchName := GenerateWorkingBranchName(taskID, taskTitle)
+	if BranchExists(path, branchName) {
+		if err := CheckoutBranch(path, branchName); err != nil {
+			return "", fmt.Errorf("failed to checkout existing branch %s: %v", branchName, err)
+		}
+		return branchName, nil
+	}
+	if err := CreateAndCheckoutBranch(path, branchName); err != nil {
+		return "", fmt.Errorf("failed to create branch %s: %v", branchName, err)
+	}
+	return branchName, nil
+}
+
 // PushWorkingBranchForReview commits any changes and pushes the working branch for review
 func PushWorkingBranchForReview(path string, workingBranch string, taskTitle string) error {
 	if !IsGitRepository(path) {
@@ -627,6 +702,18 @@ func PushWorkingBranchForReview(path string, workingBranch string, taskTitle str
 	return nil
 }
 
+// DiffStatSince returns `git diff --stat` of the working tree against ref,
+// covering both commits and uncommitted changes since ref, limited to path
+func DiffStatSince(path string, ref string) (string, error) {
+	cmd := exec.Command("git", "diff", "--stat", "--relative", ref)
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
 // GetConflictFiles returns a list of files with merge conflicts
 func GetConflictFiles(path string) ([]ConflictFile, error) {
 	cmd := exec.Command("git", "diff", "--name-only", "--diff-filter=U")
@@ -661,10 +748,11 @@ func AbortMerge(path string) error {
 // Returns a MergeResult with conflict details if the merge fails.
 // If targetBranch is empty, it auto-detects the default branch.
 func TryMergeWorkingBranch(path string, workingBranch string, targetBranch string, taskID string, taskTitle string) *MergeResult {
-	log.Printf("[Merge] Starting merge: workingBranch=%s, targetBranch=%s, path=%s", workingBranch, targetBranch, path)
+	logger := componentLog("merge").With("path", path, "task_id", taskID)
+	logger.Info("Starting merge", "working_branch", workingBranch, "target_branch", targetBranch)
 
 	if !IsGitRepository(path) {
-		log.Printf("[Merge] Not a git repository: %s", path)
+		logger.Warn("Not a git repository")
 		return &MergeResult{
 			Success: false,
 			Message: fmt.Sprintf("not a git repository: %s", path),
@@ -674,19 +762,19 @@ func TryMergeWorkingBranch(path string, workingBranch string, targetBranch strin
 	// Check for uncommitted changes on the working branch
 	hasChanges, err := HasUncommittedChanges(path)
 	if err != nil {
-		log.Printf("[Merge] Failed to check for uncommitted changes: %v", err)
+		logger.Error("Failed to check for uncommitted changes", "err", err)
 		return &MergeResult{
 			Success: false,
 			Message: fmt.Sprintf("failed to check for uncommitted changes: %v", err),
 		}
 	}
 	if hasChanges {
-		log.Printf("[Merge] Committing pending changes...")
+		logger.Info("Committing pending changes")
 		// Auto-commit any pending changes with task context
-		commitMsg := fmt.Sprintf("Final changes for: %s", taskTitle)
+		commitMsg := WithTaskTrailer(fmt.Sprintf("Final changes for: %s", taskTitle), taskID)
 		_, err := CommitAllChanges(path, commitMsg)
 		if err != nil {
-			log.Printf("[Merge] Failed to commit pending changes: %v", err)
+			logger.Error("Failed to commit pending changes", "err", err)
 			return &MergeResult{
 				Success: false,
 				Message: fmt.Sprintf("failed to commit pending changes: %v", err),
@@ -695,9 +783,9 @@ func TryMergeWorkingBranch(path string, workingBranch string, targetBranch strin
 	}
 
 	// Push working branch to remote first (so the work is saved)
-	log.Printf("[Merge] Pushing working branch to remote...")
+	logger.Info("Pushing working branch to remote")
 	if err := PushToRemote(path); err != nil {
-		log.Printf("[Merge] Push to remote warning: %v", err)
+		logger.Warn("Push to remote failed", "err", err)
 	}
 
 	// Use provided target branch or auto-detect
@@ -705,12 +793,12 @@ func TryMergeWorkingBranch(path string, workingBranch string, targetBranch strin
 	if defaultBranch == "" {
 		defaultBranch = GetDefaultBranch(path)
 	}
-	log.Printf("[Merge] Target branch: %s", defaultBranch)
+	logger.Debug("Target branch", "branch", defaultBranch)
 
 	// Checkout default branch
-	log.Printf("[Merge] Checking out %s...", defaultBranch)
+	logger.Info("Checking out target branch", "branch", defaultBranch)
 	if err := CheckoutBranch(path, defaultBranch); err != nil {
-		log.Printf("[Merge] Failed to checkout %s: %v", defaultBranch, err)
+		logger.Error("Failed to checkout target branch", "branch", defaultBranch, "err", err)
 		return &MergeResult{
 			Success: false,
 			Message: fmt.Sprintf("failed to checkout %s: %v", defaultBranch, err),
@@ -718,21 +806,23 @@ func TryMergeWorkingBranch(path string, workingBranch string, targetBranch strin
 	}
 
 	// Pull latest changes from remote
-	log.Printf("[Merge] Pulling latest changes from remote...")
+	logger.Info("Pulling latest changes from remote")
 	pullCmd := exec.Command("git", "pull", "--ff-only")
 	pullCmd.Dir = path
+	removeKey := withDeployKey(pullCmd)
 	pullOutput, pullErr := pullCmd.CombinedOutput()
+	removeKey()
 	if pullErr != nil {
-		log.Printf("[Merge] Pull warning: %v, output: %s", pullErr, string(pullOutput))
+		logger.Warn("Pull failed", "err", pullErr, "output", string(pullOutput))
 	}
 
 	// Create merge commit message with task info
-	mergeMessage := fmt.Sprintf("Merge: %s\n\nMerged from branch: %s", taskTitle, workingBranch)
+	mergeMessage := WithTaskTrailer(fmt.Sprintf("Merge: %s\n\nMerged from branch: %s", taskTitle, workingBranch), taskID)
 
 	// Try to merge the working branch
-	log.Printf("[Merge] Merging %s into %s...", workingBranch, defaultBranch)
+	logger.Info("Merging", "from", workingBranch, "into", defaultBranch)
 	if err := MergeBranch(path, workingBranch, mergeMessage); err != nil {
-		log.Printf("[Merge] Merge failed: %v", err)
+		logger.Warn("Merge failed", "err", err)
 		// Merge failed - check for conflicts
 		conflictFiles, _ := GetConflictFiles(path)
 
@@ -755,10 +845,10 @@ func TryMergeWorkingBranch(path string, workingBranch string, targetBranch strin
 		}
 	}
 
-	log.Printf("[Merge] Merge successful, pushing to remote...")
+	logger.Info("Merge successful, pushing to remote")
 	// Push to remote
 	if err := PushToRemote(path); err != nil {
-		log.Printf("[Merge] Push after merge failed: %v", err)
+		logger.Error("Push after merge failed", "err", err)
 		return &MergeResult{
 			Success: false,
 			Message: fmt.Sprintf("Merge successful but push failed: %v", err),
@@ -766,19 +856,21 @@ func TryMergeWorkingBranch(path string, workingBranch string, targetBranch strin
 	}
 
 	// Delete the working branch after successful merge
-	log.Printf("[Merge] Deleting local branch %s...", workingBranch)
+	logger.Info("Deleting local branch", "branch", workingBranch)
 	DeleteBranch(path, workingBranch)
 
 	// Also delete remote branch
-	log.Printf("[Merge] Deleting remote branch %s...", workingBranch)
+	logger.Info("Deleting remote branch", "branch", workingBranch)
 	deleteRemoteCmd := exec.Command("git", "push", "origin", "--delete", workingBranch)
 	deleteRemoteCmd.Dir = path
+	removeKey = withDeployKey(deleteRemoteCmd)
 	deleteOutput, deleteErr := deleteRemoteCmd.CombinedOutput()
+	removeKey()
 	if deleteErr != nil {
-		log.Printf("[Merge] Delete remote branch warning: %v, output: %s", deleteErr, string(deleteOutput))
+		logger.Warn("Failed to delete remote branch", "err", deleteErr, "output", string(deleteOutput))
 	}
 
-	log.Printf("[Merge] Successfully merged '%s' into '%s'", workingBranch, defaultBranch)
+	logger.Info("Merged", "from", workingBranch, "into", defaultBranch)
 	return &MergeResult{
 		Success: true,
 		Message: fmt.Sprintf("Successfully merged '%s' into '%s'", workingBranch, defaultBranch),
@@ -797,10 +889,13 @@ func CreateRollbackTag(path string, taskID string) (string, error) {
 	}
 	tagName := fmt.Sprintf("runner-before-%s", shortID)
 	cmd := exec.Command("git", "tag", tagName)
+	if signsIn(path) {
+		// Only annotated tags carry a signature
+		cmd = exec.Command("git", "tag", "-s", "-m", "Before task "+taskID, tagName)
+	}
 	cmd.Dir = path
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("git tag failed: %v, output: %s", err, string(output))
+	if output, err := runSigned(cmd); err != nil {
+		return "", gitFailed("tag", err, output)
 	}
 	return tagName, nil
 }
@@ -813,8 +908,14 @@ func DeleteTag(path string, tagName string) error {
 	return nil
 }
 
-// RollbackToTag führt git reset --hard zum Tag aus
-func RollbackToTag(path string, tagName string) error {
+// RollbackToTag führt git reset --hard zum Tag aus. Ein Projekt in einem
+// Unterverzeichnis teilt den Branch mit anderen Projekten und wird stattdessen
+// mit einem Commit auf den Stand des Tags zurückgesetzt, dessen Nachricht
+// project und task bestimmen (project darf nil sein).
+func RollbackToTag(project *Project, task *Task, path string, tagName string) error {
+	if isRepoSubdir(path) {
+		return rollbackSubdirToTag(project, task, path, tagName)
+	}
 	cmd := exec.Command("git", "reset", "--hard", tagName)
 	cmd.Dir = path
 	output, err := cmd.CombinedOutput()
@@ -829,25 +930,16 @@ func GetUnpushedCommitCount(path string, branch string) (int, error) {
 	// Fetch um Remote-Refs zu aktualisieren
 	fetchCmd := exec.Command("git", "fetch", "origin")
 	fetchCmd.Dir = path
+	removeKey := withDeployKey(fetchCmd)
 	fetchCmd.Run() // Fehler ignorieren falls kein Remote
+	removeKey()
 
-	cmd := exec.Command("git", "rev-list", "--count", fmt.Sprintf("origin/%s..%s", branch, branch))
-	cmd.Dir = path
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, err
-	}
-
-	count := 0
-	fmt.Sscanf(strings.TrimSpace(string(output)), "%d", &count)
-	return count, nil
+	return gitReads.CountCommits(path, "origin/"+branch, branch)
 }
 
 // HasRemote prüft ob ein Remote namens 'origin' existiert
 func HasRemote(path string) bool {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	cmd.Dir = path
-	err := cmd.Run()
+	_, err := gitReads.RemoteURL(path, "origin")
 	return err == nil
 }
 
@@ -860,18 +952,43 @@ func EnsureOnBranch(path string, branch string) error {
 	if current == branch {
 		return nil
 	}
-	return CheckoutBranch(path, branch)
+	return CheckoutBranchWithStash(path, branch)
 }
 
 // GetCurrentCommitHash returns the current HEAD commit hash
 func GetCurrentCommitHash(path string) (string, error) {
-	cmd := exec.Command("git", "rev-parse", "HEAD")
+	return gitReads.HeadCommit(path)
+}
+
+// NameUnbornBranch sets the name of the branch the first commit of a
+// repository without commits goes to
+func NameUnbornBranch(path string, branch string) error {
+	cmd := exec.Command("git", "symbolic-ref", "HEAD", "refs/heads/"+branch)
 	cmd.Dir = path
-	output, err := cmd.Output()
+	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", err
+		return fmt.Errorf("git symbolic-ref failed: %v, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// PullUnrelated merges a branch of origin whose history is unrelated to the
+// local one, e.g. the initial commit of a new GitHub repository. A repository
+// without commits just checks the branch out. The token authenticates with the
+// GitHub instance at webURL ("" = git's own credentials), a merge commit is
+// attributed to author.
+func PullUnrelated(path, branch, token, webURL string, author gitAuthor) error {
+	args := append(author.configArgs(), "pull", "--no-rebase", "--no-edit", "--allow-unrelated-histories", "origin", branch)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+	if token != "" {
+		cmd.Env = append(os.Environ(), githubAuthEnv(token, webURL)...)
 	}
-	return strings.TrimSpace(string(output)), nil
+	defer withDeployKey(cmd)()
+	if output, err := runSigned(cmd); err != nil {
+		return gitFailed("pull", err, output)
+	}
+	return nil
 }
 
 // CreateBranchFromMain erstellt einen neuen Branch von main/master
@@ -885,7 +1002,7 @@ func CreateBranchFromMain(path string, branchName string) error {
 
 	// Pull latest
 	if err := PullFromRemote(path); err != nil {
-		log.Printf("Warning: Failed to pull latest changes: %v (continuing)", err)
+		componentLog("git").Warn("Failed to pull latest changes (continuing)", "path", path, "err", err)
 	}
 
 	// Create and checkout new branch
@@ -0,0 +1,468 @@
+// gitbackend.go reads repositories in-process with go-git instead of
+// starting a git process for every lookup. The current branch and commit,
+// branch lists, the origin URL and commit counts between two revisions (what
+// rev-list --count does) run on every project list, board load and branch
+// picker, where starting git took most of the request time. Everything that
+// changes a repository, and reads go-git does not answer the way git does
+// (status with its ignore rules and index, diffs, logs of a subdirectory),
+// keeps running the git binary. A repository go-git cannot open, e.g. one
+// in the reftable format or with a repository extension it does not know,
+// and revisions it cannot resolve fall back to git for that call.
+// FORGE_GIT_BACKEND=exec turns go-git off.
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// gitReader answers the read-only questions FORGE asks repositories most often
+type gitReader interface {
+	// CurrentBranch returns the checked out branch, "HEAD" if detached
+	CurrentBranch(path string) (string, error)
+	// Branches returns the local branches
+	Branches(path string) ([]string, error)
+	// BranchDetails returns the local and remote-tracking branches with their
+	// upstream, last commit and whether they are merged into defaultBranch
+	BranchDetails(path, defaultBranch string) ([]BranchInfo, error)
+	// RemoteURL returns the URL of a remote
+	RemoteURL(path, remote string) (string, error)
+	// HeadCommit returns the hash of the commit HEAD points to
+	HeadCommit(path string) (string, error)
+	// CountCommits returns the number of commits reachable from head but not from base
+	CountCommits(path, base, head string) (int, error)
+}
+
+// gitReads is the process-wide git reader
+var gitReads = gitReaderFromEnv()
+
+// gitReaderFromEnv reads FORGE_GIT_BACKEND (gogit or exec, default gogit)
+func gitReaderFromEnv() gitReader {
+	switch v := os.Getenv("FORGE_GIT_BACKEND"); v {
+	case "", "gogit":
+		return goGitReader{fallback: execGitReader{}}
+	case "exec":
+		return execGitReader{}
+	default:
+		componentLog("git").Warn("Ignoring invalid FORGE_GIT_BACKEND", "value", v)
+		return goGitReader{fallback: execGitReader{}}
+	}
+}
+
+// validBranchName reports whether name can be a local branch and passed to git as one
+func validBranchName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "-") {
+		return false
+	}
+	return plumbing.NewBranchReferenceName(name).Validate() == nil
+}
+
+// execGitReader runs the git binary
+type execGitReader struct{}
+
+func (execGitReader) output(path string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (g execGitReader) CurrentBranch(path string) (string, error) {
+	return g.output(path, "rev-parse", "--abbrev-ref", "HEAD")
+}
+
+func (g execGitReader) Branches(path string) ([]string, error) {
+	output, err := g.output(path, "branch", "--format=%(refname:short)")
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []string
+	for _, line := range strings.Split(output, "\n") {
+		branch := strings.TrimSpace(line)
+		if branch != "" {
+			branches = append(branches, branch)
+		}
+	}
+	return branches, nil
+}
+
+// branchDetailsFormat is the for-each-ref format BranchDetails parses, fields separated by NUL
+const branchDetailsFormat = "%(refname)%00%(refname:short)%00%(HEAD)%00%(symref)%00%(upstream:short)%00%(upstream:track)%00%(committerdate:iso-strict)%00%(authorname)"
+
+func (g execGitReader) BranchDetails(path, defaultBranch string) ([]BranchInfo, error) {
+	output, err := g.output(path, "for-each-ref", "--format="+branchDetailsFormat, "refs/heads", "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+	// Fails if the default branch does not exist yet; then nothing is merged
+	merged := make(map[string]bool)
+	if out, err := g.output(path, "for-each-ref", "--merged=refs/heads/"+defaultBranch, "--format=%(refname)", "refs/heads", "refs/remotes"); err == nil {
+		for _, ref := range strings.Split(out, "\n") {
+			merged[ref] = true
+		}
+	}
+
+	branches := []BranchInfo{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\x00")
+		// Skips the symbolic origin/HEAD, which only names the remote's default branch
+		if len(fields) != 8 || fields[3] != "" {
+			continue
+		}
+		branch := BranchInfo{
+			Name:             fields[1],
+			IsRemote:         strings.HasPrefix(fields[0], "refs/remotes/"),
+			IsCurrent:        fields[2] == "*",
+			Upstream:         fields[4],
+			LastCommitAuthor: fields[7],
+			Merged:           merged[fields[0]] && fields[0] != "refs/heads/"+defaultBranch,
+		}
+		switch track := fields[5]; {
+		case track == "[gone]":
+			branch.UpstreamGone = true
+		case track != "":
+			for _, part := range strings.Split(strings.Trim(track, "[]"), ", ") {
+				fmt.Sscanf(part, "ahead %d", &branch.Ahead)
+				fmt.Sscanf(part, "behind %d", &branch.Behind)
+			}
+		}
+		if date, err := time.Parse(time.RFC3339, fields[6]); err == nil {
+			branch.LastCommitAt = &date
+		}
+		branches = append(branches, branch)
+	}
+	return branches, nil
+}
+
+func (g execGitReader) RemoteURL(path, remote string) (string, error) {
+	return g.output(path, "remote", "get-url", remote)
+}
+
+func (g execGitReader) HeadCommit(path string) (string, error) {
+	return g.output(path, "rev-parse", "HEAD")
+}
+
+func (g execGitReader) CountCommits(path, base, head string) (int, error) {
+	output, err := g.output(path, "rev-list", "--count", base+".."+head)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	fmt.Sscanf(output, "%d", &count)
+	return count, nil
+}
+
+// goGitReader reads repositories with go-git and asks fallback what it cannot
+type goGitReader struct {
+	fallback gitReader
+}
+
+// open opens the repository containing path, including linked worktrees
+func (goGitReader) open(path string) (*git.Repository, error) {
+	return git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: true,
+	})
+}
+
+func (g goGitReader) CurrentBranch(path string) (string, error) {
+	repo, err := g.open(path)
+	if err != nil {
+		return g.fallback.CurrentBranch(path)
+	}
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() != plumbing.SymbolicReference {
+		return "HEAD", nil
+	}
+	// Like git, a branch without commits yet is no current branch
+	if _, err := repo.Reference(head.Target(), false); err != nil {
+		return "", err
+	}
+	return head.Target().Short(), nil
+}
+
+func (g goGitReader) Branches(path string) ([]string, error) {
+	repo, err := g.open(path)
+	if err != nil {
+		return g.fallback.Branches(path)
+	}
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	defer refs.Close()
+
+	var names []string
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name().IsBranch() {
+			names = append(names, ref.Name().String())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// git lists by full name
+	sort.Strings(names)
+	branches := make([]string, 0, len(names))
+	for _, name := range names {
+		branches = append(branches, plumbing.ReferenceName(name).Short())
+	}
+	return branches, nil
+}
+
+func (g goGitReader) BranchDetails(path, defaultBranch string) ([]BranchInfo, error) {
+	repo, err := g.open(path)
+	if err != nil {
+		return g.fallback.BranchDetails(path, defaultBranch)
+	}
+	config, err := repo.Config()
+	if err != nil {
+		return g.fallback.BranchDetails(path, defaultBranch)
+	}
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	defer refs.Close()
+
+	// Symbolic refs such as origin/HEAD only name another branch
+	var heads []*plumbing.Reference
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && (ref.Name().IsBranch() || ref.Name().IsRemote()) {
+			heads = append(heads, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(heads, func(i, j int) bool { return heads[i].Name() < heads[j].Name() })
+
+	var current plumbing.ReferenceName
+	if head, err := repo.Reference(plumbing.HEAD, false); err == nil && head.Type() == plumbing.SymbolicReference {
+		current = head.Target()
+	}
+	defaultRef := plumbing.NewBranchReferenceName(defaultBranch)
+	defaultHead, defaultErr := repo.Reference(defaultRef, true)
+
+	branches := []BranchInfo{}
+	for _, ref := range heads {
+		branch := BranchInfo{
+			Name:      ref.Name().Short(),
+			IsRemote:  ref.Name().IsRemote(),
+			IsCurrent: ref.Name() == current,
+		}
+		if commit, err := repo.CommitObject(ref.Hash()); err == nil {
+			date := commit.Committer.When
+			branch.LastCommitAt = &date
+			branch.LastCommitAuthor = commit.Author.Name
+		}
+
+		if upstream := branchUpstream(config.Branches[branch.Name]); ref.Name().IsBranch() && upstream != "" {
+			branch.Upstream = upstream.Short()
+			if up, err := repo.Reference(upstream, true); err != nil {
+				branch.UpstreamGone = true
+			} else if up.Hash() != ref.Hash() {
+				if branch.Ahead, err = countCommitsBetween(repo, up.Hash(), ref.Hash()); err != nil {
+					return g.fallback.BranchDetails(path, defaultBranch)
+				}
+				if branch.Behind, err = countCommitsBetween(repo, ref.Hash(), up.Hash()); err != nil {
+					return g.fallback.BranchDetails(path, defaultBranch)
+				}
+			}
+		}
+
+		if defaultErr == nil && ref.Name() != defaultRef {
+			ahead, err := countCommitsBetween(repo, defaultHead.Hash(), ref.Hash())
+			if err != nil {
+				return g.fallback.BranchDetails(path, defaultBranch)
+			}
+			branch.Merged = ahead == 0
+		}
+		branches = append(branches, branch)
+	}
+	return branches, nil
+}
+
+// branchUpstream returns the ref a branch tracks, "" if it tracks none
+func branchUpstream(branch *gitconfig.Branch) plumbing.ReferenceName {
+	if branch == nil || branch.Merge == "" {
+		return ""
+	}
+	if branch.Remote == "" || branch.Remote == "." {
+		return branch.Merge
+	}
+	return plumbing.NewRemoteReferenceName(branch.Remote, branch.Merge.Short())
+}
+
+func (g goGitReader) RemoteURL(path, remote string) (string, error) {
+	repo, err := g.open(path)
+	if err != nil {
+		return g.fallback.RemoteURL(path, remote)
+	}
+	r, err := repo.Remote(remote)
+	if err != nil {
+		return "", err
+	}
+	if urls := r.Config().URLs; len(urls) > 0 {
+		return urls[0], nil
+	}
+	return "", fmt.Errorf("remote %s has no URL", remote)
+}
+
+func (g goGitReader) HeadCommit(path string) (string, error) {
+	repo, err := g.open(path)
+	if err != nil {
+		return g.fallback.HeadCommit(path)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+func (g goGitReader) CountCommits(path, base, head string) (int, error) {
+	repo, err := g.open(path)
+	if err != nil {
+		return g.fallback.CountCommits(path, base, head)
+	}
+	baseHash, err := resolveCommit(repo, base)
+	if err != nil {
+		return g.fallback.CountCommits(path, base, head)
+	}
+	headHash, err := resolveCommit(repo, head)
+	if err != nil {
+		return g.fallback.CountCommits(path, base, head)
+	}
+	return countCommitsBetween(repo, baseHash, headHash)
+}
+
+// resolveCommit resolves a revision to a commit. Like git, and unlike
+// go-git's ResolveRevision, a name is looked up as a ref before it is taken
+// for an abbreviated hash, so a branch named e.g. "feed" is the branch.
+func resolveCommit(repo *git.Repository, rev string) (plumbing.Hash, error) {
+	if !strings.ContainsAny(rev, "~^@:{") {
+		for _, rule := range plumbing.RefRevParseRules {
+			name := plumbing.ReferenceName(fmt.Sprintf(rule, rev))
+			if _, err := repo.Reference(name, true); err == nil {
+				rev = name.String()
+				break
+			}
+		}
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return *hash, nil
+}
+
+// Marks of the walk in countCommitsBetween
+const (
+	reachableFromHead = 1 << iota
+	reachableFromBase
+)
+
+// walkSlop is how many commits the walk goes on after only commits reachable
+// from base are left, to get past commit dates that are out of order (git
+// does the same)
+const walkSlop = 5
+
+// countCommitsBetween counts the commits reachable from head but not from
+// base. Both sides are walked newest first by commit date, so the walk stops
+// shortly after their merge base instead of reading all of history.
+func countCommitsBetween(repo *git.Repository, base, head plumbing.Hash) (int, error) {
+	marks := make(map[plumbing.Hash]int)
+	queue := &commitQueue{}
+	push := func(hash plumbing.Hash, mark int) error {
+		if marks[hash]&mark == mark {
+			return nil
+		}
+		marks[hash] |= mark
+		commit, err := repo.CommitObject(hash)
+		if err != nil {
+			return err
+		}
+		heap.Push(queue, commit)
+		return nil
+	}
+	if err := push(head, reachableFromHead); err != nil {
+		return 0, err
+	}
+	if err := push(base, reachableFromBase); err != nil {
+		return 0, err
+	}
+
+	slop := walkSlop
+	for queue.Len() > 0 {
+		if queue.onlyReachableFrom(marks, reachableFromBase) {
+			if slop == 0 {
+				break
+			}
+			slop--
+		} else {
+			slop = walkSlop
+		}
+		commit := heap.Pop(queue).(*object.Commit)
+		mark := marks[commit.Hash]
+		for _, parent := range commit.ParentHashes {
+			if err := push(parent, mark); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	count := 0
+	for _, mark := range marks {
+		if mark == reachableFromHead {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// commitQueue is a heap of commits, newest commit date first
+type commitQueue []*object.Commit
+
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(i, j int) bool {
+	return q[i].Committer.When.After(q[j].Committer.When)
+}
+func (q commitQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x interface{}) { *q = append(*q, x.(*object.Commit)) }
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	commit := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return commit
+}
+
+// onlyReachableFrom reports whether every queued commit carries mark
+func (q commitQueue) onlyReachableFrom(marks map[plumbing.Hash]int, mark int) bool {
+	for _, commit := range q {
+		if marks[commit.Hash]&mark == 0 {
+			return false
+		}
+	}
+	return true
+}
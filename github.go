@@ -6,18 +6,18 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
-const githubAPIURL = "https://api.github.com"
-
 // GitHubClient handles GitHub API interactions
 type GitHubClient struct {
-	token string
+	token  string
+	apiURL string // REST API of the instance (see githubhost.go)
 }
 
-// NewGitHubClient creates a new GitHub API client
+// NewGitHubClient creates a new GitHub API client for github.com
 func NewGitHubClient(token string) *GitHubClient {
-	return &GitHubClient{token: token}
+	return &GitHubClient{token: token, apiURL: defaultGitHubAPIURL}
 }
 
 // GitHubUser represents a GitHub user
@@ -26,31 +26,44 @@ type GitHubUser struct {
 	Name      string `json:"name"`
 	ID        int    `json:"id"`
 	AvatarURL string `json:"avatar_url"`
+	Type      string `json:"type"` // User, Bot or Organization
 }
 
 // GitHubRepo represents a GitHub repository
 type GitHubRepo struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	FullName    string `json:"full_name"`
-	Description string `json:"description"`
-	Private     bool   `json:"private"`
-	HTMLURL     string `json:"html_url"`
-	CloneURL    string `json:"clone_url"`
-	SSHURL      string `json:"ssh_url"`
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	Description   string `json:"description"`
+	Private       bool   `json:"private"`
+	HTMLURL       string `json:"html_url"`
+	CloneURL      string `json:"clone_url"`
+	SSHURL        string `json:"ssh_url"`
+	DefaultBranch string `json:"default_branch"`
 }
 
 // GitHubCreateRepoRequest represents the request body for creating a repo
 type GitHubCreateRepoRequest struct {
+	Name              string `json:"name"`
+	Description       string `json:"description,omitempty"`
+	Private           bool   `json:"private"`
+	AutoInit          bool   `json:"auto_init"`
+	GitignoreTemplate string `json:"gitignore_template,omitempty"` // e.g. "Go", implies an initial commit
+	LicenseTemplate   string `json:"license_template,omitempty"`   // e.g. "mit", implies an initial commit
+}
+
+// GitHubGenerateRepoRequest represents the request body for creating a repo
+// from a template repository
+type GitHubGenerateRepoRequest struct {
+	Owner       string `json:"owner,omitempty"` // Organization or user, "" = the token's user
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"`
 	Private     bool   `json:"private"`
-	AutoInit    bool   `json:"auto_init"`
 }
 
 // ValidateToken checks if the GitHub token is valid
 func (c *GitHubClient) ValidateToken() (*GitHubUser, error) {
-	req, err := http.NewRequest("GET", githubAPIURL+"/user", nil)
+	req, err := http.NewRequest("GET", c.apiURL+"/user", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -78,21 +91,33 @@ func (c *GitHubClient) ValidateToken() (*GitHubUser, error) {
 	return &user, nil
 }
 
-// CreateRepository creates a new GitHub repository
-func (c *GitHubClient) CreateRepository(name, description string, private bool) (*GitHubRepo, error) {
-	reqBody := GitHubCreateRepoRequest{
-		Name:        name,
-		Description: description,
-		Private:     private,
-		AutoInit:    false, // Don't auto-init since we'll push existing code
+// CreateRepository creates a new GitHub repository, in the organization
+// owner or, if owner is empty, for the token's user. Without AutoInit or a
+// gitignore or license template the repository is empty, so existing code can
+// be pushed to it.
+func (c *GitHubClient) CreateRepository(owner string, reqBody GitHubCreateRepoRequest) (*GitHubRepo, error) {
+	url := c.apiURL + "/user/repos"
+	if owner != "" {
+		url = fmt.Sprintf("%s/orgs/%s/repos", c.apiURL, owner)
 	}
+	return c.postRepository(url, reqBody)
+}
 
+// GenerateRepository creates a new repository from a template repository
+// (owner/repo). GitHub copies the template's files in the background, so
+// they may take a moment to appear.
+func (c *GitHubClient) GenerateRepository(template string, reqBody GitHubGenerateRepoRequest) (*GitHubRepo, error) {
+	return c.postRepository(fmt.Sprintf("%s/repos/%s/generate", c.apiURL, template), reqBody)
+}
+
+// postRepository sends a request that creates a repository and returns it
+func (c *GitHubClient) postRepository(url string, reqBody interface{}) (*GitHubRepo, error) {
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", githubAPIURL+"/user/repos", bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, err
 	}
@@ -121,6 +146,25 @@ func (c *GitHubClient) CreateRepository(name, description string, private bool)
 	return &repo, nil
 }
 
+// ReplaceTopics sets the topics of a repository, replacing all it had
+func (c *GitHubClient) ReplaceTopics(repoFullName string, topics []string) error {
+	jsonBody, err := json.Marshal(map[string][]string{"names": topics})
+	if err != nil {
+		return err
+	}
+	return c.sendJSONRequest("PUT", fmt.Sprintf("%s/repos/%s/topics", c.apiURL, repoFullName), jsonBody, http.StatusOK)
+}
+
+// RenameBranch renames a branch of a repository; renaming the default
+// branch keeps it the default
+func (c *GitHubClient) RenameBranch(repoFullName, branch, newName string) error {
+	jsonBody, err := json.Marshal(map[string]string{"new_name": newName})
+	if err != nil {
+		return err
+	}
+	return c.sendJSONRequest("POST", fmt.Sprintf("%s/repos/%s/branches/%s/rename", c.apiURL, repoFullName, branch), jsonBody, http.StatusCreated)
+}
+
 // GetAuthenticatedUser returns the authenticated user's info
 func (c *GitHubClient) GetAuthenticatedUser() (*GitHubUser, error) {
 	return c.ValidateToken()
@@ -128,16 +172,21 @@ func (c *GitHubClient) GetAuthenticatedUser() (*GitHubUser, error) {
 
 // GitHubPullRequest represents a GitHub pull request
 type GitHubPullRequest struct {
-	ID        int    `json:"id"`
-	Number    int    `json:"number"`
-	State     string `json:"state"`
-	Title     string `json:"title"`
-	Body      string `json:"body"`
-	HTMLURL   string `json:"html_url"`
-	DiffURL   string `json:"diff_url"`
-	CreatedAt string `json:"created_at"`
-	Head      struct {
+	ID             int    `json:"id"`
+	Number         int    `json:"number"`
+	State          string `json:"state"`
+	Merged         bool   `json:"merged"`
+	Draft          bool   `json:"draft"`
+	MergeableState string `json:"mergeable_state"` // clean once checks and required reviews pass
+	MergeCommitSHA string `json:"merge_commit_sha"`
+	Title          string `json:"title"`
+	Body           string `json:"body"`
+	HTMLURL        string `json:"html_url"`
+	DiffURL        string `json:"diff_url"`
+	CreatedAt      string `json:"created_at"`
+	Head           struct {
 		Ref string `json:"ref"`
+		SHA string `json:"sha"`
 	} `json:"head"`
 	Base struct {
 		Ref string `json:"ref"`
@@ -167,7 +216,7 @@ func (c *GitHubClient) CreatePullRequest(repoFullName, title, body, head, base s
 		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/repos/%s/pulls", githubAPIURL, repoFullName)
+	url := fmt.Sprintf("%s/repos/%s/pulls", c.apiURL, repoFullName)
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, err
@@ -197,9 +246,87 @@ func (c *GitHubClient) CreatePullRequest(repoFullName, title, body, head, base s
 	return &pr, nil
 }
 
+// GetPullRequest returns a pull request by number, including whether it was merged
+func (c *GitHubClient) GetPullRequest(repoFullName string, number int) (*GitHubPullRequest, error) {
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d", c.apiURL, repoFullName, number)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var pr GitHubPullRequest
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// MergePullRequest merges a pull request with method merge, squash or rebase,
+// if its head is still sha. Returns the merge commit.
+func (c *GitHubClient) MergePullRequest(repoFullName string, number int, method, sha string) (string, error) {
+	jsonBody, err := json.Marshal(map[string]string{"merge_method": method, "sha": sha})
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d/merge", c.apiURL, repoFullName, number)
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		SHA    string `json:"sha"`
+		Merged bool   `json:"merged"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if !result.Merged {
+		return "", fmt.Errorf("pull request #%d was not merged", number)
+	}
+	return result.SHA, nil
+}
+
+// DeleteBranch deletes a branch of a repository
+func (c *GitHubClient) DeleteBranch(repoFullName, branch string) error {
+	return c.sendJSONRequest("DELETE", fmt.Sprintf("%s/repos/%s/git/refs/heads/%s", c.apiURL, repoFullName, branch), nil, http.StatusNoContent)
+}
+
 // FindExistingPR searches for an existing open PR with the same head and base branches
 func (c *GitHubClient) FindExistingPR(repoFullName, head, base string) (*GitHubPullRequest, error) {
-	url := fmt.Sprintf("%s/repos/%s/pulls?state=open&head=%s&base=%s", githubAPIURL, repoFullName, head, base)
+	url := fmt.Sprintf("%s/repos/%s/pulls?state=open&head=%s&base=%s", c.apiURL, repoFullName, head, base)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -231,3 +358,315 @@ func (c *GitHubClient) FindExistingPR(repoFullName, head, base string) (*GitHubP
 
 	return nil, nil
 }
+
+// GitHubReview represents a review of a pull request
+type GitHubReview struct {
+	ID          int64      `json:"id"`
+	User        GitHubUser `json:"user"`
+	Body        string     `json:"body"`
+	State       string     `json:"state"` // APPROVED, CHANGES_REQUESTED, COMMENTED, DISMISSED or PENDING
+	HTMLURL     string     `json:"html_url"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+}
+
+// GitHubReviewComment represents a comment on a line of a pull request's diff
+type GitHubReviewComment struct {
+	ID           int64      `json:"id"`
+	User         GitHubUser `json:"user"`
+	Body         string     `json:"body"`
+	Path         string     `json:"path"`
+	Line         int        `json:"line"`          // 0 if the line is no longer in the diff
+	OriginalLine int        `json:"original_line"` // Line in the commit the comment was made on
+	HTMLURL      string     `json:"html_url"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ListPullRequestReviews returns the reviews of a pull request
+func (c *GitHubClient) ListPullRequestReviews(repoFullName string, number int) ([]GitHubReview, error) {
+	return getAllPages[GitHubReview](c, fmt.Sprintf("%s/repos/%s/pulls/%d/reviews", c.apiURL, repoFullName, number))
+}
+
+// ListPullRequestComments returns the comments on the diff of a pull request
+func (c *GitHubClient) ListPullRequestComments(repoFullName string, number int) ([]GitHubReviewComment, error) {
+	return getAllPages[GitHubReviewComment](c, fmt.Sprintf("%s/repos/%s/pulls/%d/comments", c.apiURL, repoFullName, number))
+}
+
+// getAllPages fetches every page of a list endpoint
+func getAllPages[T any](c *GitHubClient, url string) ([]T, error) {
+	var items []T
+	for page := 1; ; page++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s?per_page=100&page=%d", url, page), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+		}
+
+		var batch []T
+		err = json.NewDecoder(resp.Body).Decode(&batch)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, batch...)
+		if len(batch) < 100 {
+			return items, nil
+		}
+	}
+}
+
+// GitHubIssue represents a GitHub issue. The issues API also returns pull
+// requests; those carry a pull_request object.
+type GitHubIssue struct {
+	Number      int                    `json:"number"`
+	Title       string                 `json:"title"`
+	Body        string                 `json:"body"`
+	State       string                 `json:"state"`
+	HTMLURL     string                 `json:"html_url"`
+	Labels      []GitHubLabel          `json:"labels"`
+	PullRequest map[string]interface{} `json:"pull_request,omitempty"`
+}
+
+// GitHubLabel represents a label on a GitHub issue
+type GitHubLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color"` // Hex without '#'
+}
+
+// ListOpenIssues returns all open issues of a repository, without pull requests
+func (c *GitHubClient) ListOpenIssues(repoFullName string) ([]GitHubIssue, error) {
+	var issues []GitHubIssue
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/issues?state=open&per_page=100&page=%d", c.apiURL, repoFullName, page)
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+		}
+
+		var batch []GitHubIssue
+		err = json.NewDecoder(resp.Body).Decode(&batch)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, issue := range batch {
+			if issue.PullRequest == nil {
+				issues = append(issues, issue)
+			}
+		}
+		if len(batch) < 100 {
+			return issues, nil
+		}
+	}
+}
+
+// CommentOnIssue adds a comment to an issue (or pull request)
+func (c *GitHubClient) CommentOnIssue(repoFullName string, number int, body string) error {
+	jsonBody, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return err
+	}
+	return c.sendJSONRequest("POST", fmt.Sprintf("%s/repos/%s/issues/%d/comments", c.apiURL, repoFullName, number), jsonBody, http.StatusCreated)
+}
+
+// CloseIssue closes an issue as completed
+func (c *GitHubClient) CloseIssue(repoFullName string, number int) error {
+	jsonBody, err := json.Marshal(map[string]string{"state": "closed", "state_reason": "completed"})
+	if err != nil {
+		return err
+	}
+	return c.sendJSONRequest("PATCH", fmt.Sprintf("%s/repos/%s/issues/%d", c.apiURL, repoFullName, number), jsonBody, http.StatusOK)
+}
+
+// sendJSONRequest sends a JSON request to the API and checks the status code
+func (c *GitHubClient) sendJSONRequest(method, url string, jsonBody []byte, wantStatus int) error {
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// GitHubRelease represents a GitHub release
+type GitHubRelease struct {
+	ID      int64  `json:"id"`
+	TagName string `json:"tag_name"`
+	Name    string `json:"name"`
+	HTMLURL string `json:"html_url"`
+	Draft   bool   `json:"draft"`
+}
+
+// GitHubCreateReleaseRequest represents the request body for creating a release
+type GitHubCreateReleaseRequest struct {
+	TagName    string `json:"tag_name"` // Existing tag to publish
+	Name       string `json:"name"`
+	Body       string `json:"body"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+// CreateRelease publishes a release for an already pushed tag
+func (c *GitHubClient) CreateRelease(repoFullName, tag, name, body string, draft, prerelease bool) (*GitHubRelease, error) {
+	reqBody := GitHubCreateReleaseRequest{
+		TagName:    tag,
+		Name:       name,
+		Body:       body,
+		Draft:      draft,
+		Prerelease: prerelease,
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/releases", c.apiURL, repoFullName)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var release GitHubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// GitHubCheckRun is a check of a commit, e.g. a GitHub Actions job
+type GitHubCheckRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`     // queued, in_progress, completed
+	Conclusion string `json:"conclusion"` // success, failure, neutral, cancelled, skipped, timed_out, action_required
+	HTMLURL    string `json:"html_url"`
+	App        struct {
+		Slug string `json:"slug"`
+	} `json:"app"`
+}
+
+// ListCheckRuns returns the check runs of a commit
+func (c *GitHubClient) ListCheckRuns(repoFullName, ref string) ([]GitHubCheckRun, error) {
+	url := fmt.Sprintf("%s/repos/%s/commits/%s/check-runs?per_page=100", c.apiURL, repoFullName, ref)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		CheckRuns []GitHubCheckRun `json:"check_runs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.CheckRuns, nil
+}
+
+// GetJobLog returns the plain text log of a GitHub Actions job. The check
+// run of an Actions job has the job's ID.
+func (c *GitHubClient) GetJobLog(repoFullName string, jobID int64) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/actions/jobs/%d/logs", c.apiURL, repoFullName, jobID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	// GitHub redirects to a short-lived download URL, which is followed
+	// without the Authorization header
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
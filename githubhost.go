@@ -0,0 +1,192 @@
+// githubhost.go lets FORGE talk to GitHub Enterprise Server as well as
+// github.com. The API and web URL of the GitHub instance are set in the config
+// (Config.GitHub) and can be overridden per project (Project.GitHub); with
+// neither, github.com is used. Setting only one of the two URLs derives the
+// other the way GitHub Enterprise lays them out (<web URL>/api/v3). A
+// project's remote only counts as a GitHub repository if it is on the host of
+// its instance, so pull requests, issues, releases and CI checks go to the
+// instance the code lives on, and tokens are only sent to that host.
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// Endpoints of github.com
+const (
+	defaultGitHubAPIURL = "https://api.github.com"
+	defaultGitHubWebURL = "https://github.com"
+)
+
+// enterpriseAPIPath is where GitHub Enterprise Server serves the REST API
+const enterpriseAPIPath = "/api/v3"
+
+// GitHubEndpoints are the URLs of a GitHub instance, stored as a JSON object
+type GitHubEndpoints struct {
+	APIURL string `json:"api_url,omitempty"` // REST API, e.g. https://github.example.com/api/v3
+	WebURL string `json:"web_url,omitempty"` // Web and git over HTTPS, e.g. https://github.example.com
+}
+
+// Value stores the endpoints as JSON, none as an empty string
+func (e GitHubEndpoints) Value() (driver.Value, error) {
+	if e.isZero() {
+		return "", nil
+	}
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads endpoints stored by Value
+func (e *GitHubEndpoints) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into GitHubEndpoints", src)
+	}
+	*e = GitHubEndpoints{}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, e)
+}
+
+// isZero reports whether no URL is set
+func (e GitHubEndpoints) isZero() bool {
+	return e.APIURL == "" && e.WebURL == ""
+}
+
+// normalize trims the URLs and checks that they are absolute http(s) URLs
+func (e *GitHubEndpoints) normalize() error {
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{{"api_url", &e.APIURL}, {"web_url", &e.WebURL}} {
+		*field.value = strings.TrimRight(strings.TrimSpace(*field.value), "/")
+		if *field.value == "" {
+			continue
+		}
+		u, err := url.Parse(*field.value)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%s must be an http(s) URL such as https://github.example.com", field.name)
+		}
+	}
+	return nil
+}
+
+// complete fills in the URL that is not set from the one that is
+func (e GitHubEndpoints) complete() GitHubEndpoints {
+	switch {
+	case e.WebURL == "" && e.APIURL == defaultGitHubAPIURL:
+		e.WebURL = defaultGitHubWebURL
+	case e.WebURL == "":
+		e.WebURL = strings.TrimSuffix(e.APIURL, enterpriseAPIPath)
+	case e.APIURL == "" && e.WebURL == defaultGitHubWebURL:
+		e.APIURL = defaultGitHubAPIURL
+	case e.APIURL == "":
+		e.APIURL = e.WebURL + enterpriseAPIPath
+	}
+	return e
+}
+
+// configGitHub holds the endpoints of the config for code that has no config
+// at hand, like the github_url of projects
+var configGitHub atomic.Pointer[GitHubEndpoints]
+
+// setConfigGitHub remembers the endpoints of the config after it changed
+func setConfigGitHub(config *Config) {
+	endpoints := config.GitHub
+	configGitHub.Store(&endpoints)
+}
+
+// loadConfigGitHub reads the endpoints of the config
+func loadConfigGitHub(db Store) {
+	config, err := db.GetConfig()
+	if err != nil {
+		componentLog("git").Warn("Failed to read the GitHub endpoints", "err", err)
+		return
+	}
+	setConfigGitHub(config)
+}
+
+// githubEndpointsFor returns the GitHub instance of a project: its own, else
+// the config's, else github.com. Without a config the endpoints last read
+// from it are used; project may be nil.
+func githubEndpointsFor(config *Config, project *Project) GitHubEndpoints {
+	endpoints := GitHubEndpoints{APIURL: defaultGitHubAPIURL, WebURL: defaultGitHubWebURL}
+	if config != nil && !config.GitHub.isZero() {
+		endpoints = config.GitHub.complete()
+	}
+	if config == nil {
+		if stored := configGitHub.Load(); stored != nil && !stored.isZero() {
+			endpoints = stored.complete()
+		}
+	}
+	if project != nil && !project.GitHub.isZero() {
+		endpoints = project.GitHub.complete()
+	}
+	return endpoints
+}
+
+// host returns the host name of the instance, e.g. github.com
+func (e GitHubEndpoints) host() string {
+	u, err := url.Parse(e.WebURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// client returns an API client for the instance
+func (e GitHubEndpoints) client(token string) *GitHubClient {
+	return &GitHubClient{token: token, apiURL: e.APIURL}
+}
+
+// parseRepo returns owner/repo of a remote on the instance
+func (e GitHubEndpoints) parseRepo(remoteURL string) (string, error) {
+	return ParseGitHubRepoFromURL(remoteURL, e.host())
+}
+
+// repoURL returns the web page of a repository on the instance
+func (e GitHubEndpoints) repoURL(repo string) string {
+	return e.WebURL + "/" + repo
+}
+
+// parseGitRemote splits a remote URL into its host name and owner/repo.
+// Supports HTTPS (https://host/owner/repo), SSH URLs (ssh://git@host/owner/repo)
+// and the scp-like form (git@host:owner/repo), with or without .git.
+func parseGitRemote(remoteURL string) (string, string, bool) {
+	remoteURL = strings.TrimSuffix(strings.TrimSpace(remoteURL), ".git")
+
+	var host, path string
+	if u, err := url.Parse(remoteURL); err == nil && u.Host != "" {
+		switch u.Scheme {
+		case "https", "http", "ssh", "git":
+			host, path = u.Hostname(), u.Path
+		default:
+			return "", "", false
+		}
+	} else if at, colon := strings.Index(remoteURL, "@"), strings.Index(remoteURL, ":"); colon > at+1 && !strings.Contains(remoteURL[:colon], "/") {
+		host, path = remoteURL[at+1:colon], remoteURL[colon+1:]
+	} else {
+		return "", "", false
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if host == "" || len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return strings.ToLower(host), parts[0] + "/" + parts[1], true
+}
@@ -0,0 +1,127 @@
+// githubrepo.go holds the options of POST /api/projects/{id}/github-repo
+// beyond name, description and visibility. The repository can be created in
+// an organization instead of the user's account, from a template repository,
+// with a .gitignore and license GitHub adds in an initial commit, with topics
+// and with a default branch other than GitHub's. A repository that starts with
+// files of its own is pulled into the project right away, so the project's
+// next push does not get rejected; a project without commits just checks out
+// the new branch. Steps after the repository exists (topics, renaming the
+// default branch, the pull) do not fail the request but are reported as
+// warnings, since the repository cannot be taken back.
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// maxRepoTopics is the most topics GitHub allows on a repository
+const maxRepoTopics = 20
+
+var (
+	// githubOwnerPattern matches user and organization names
+	githubOwnerPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+
+	// githubRepoPattern matches owner/repo
+	githubRepoPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,38}/[A-Za-z0-9._-]+$`)
+
+	// repoTopicPattern matches a topic: lowercase letters, digits and hyphens
+	repoTopicPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,49}$`)
+)
+
+// normalize trims the options and rejects ones GitHub would refuse
+func (req *CreateGithubRepoRequest) normalize() error {
+	req.RepoName = strings.TrimSpace(req.RepoName)
+	req.Owner = strings.TrimSpace(req.Owner)
+	req.Template = strings.TrimSpace(req.Template)
+	req.DefaultBranch = strings.TrimSpace(req.DefaultBranch)
+	req.Gitignore = strings.TrimSpace(req.Gitignore)
+	req.License = strings.ToLower(strings.TrimSpace(req.License))
+
+	if req.Owner != "" && !githubOwnerPattern.MatchString(req.Owner) {
+		return fmt.Errorf("invalid owner: %s", req.Owner)
+	}
+	if req.Template != "" {
+		if !githubRepoPattern.MatchString(req.Template) {
+			return fmt.Errorf("template must be a repository as owner/repo")
+		}
+		if req.Gitignore != "" || req.License != "" {
+			return fmt.Errorf("a repository from a template gets its files from the template, not from gitignore or license")
+		}
+	}
+	if req.DefaultBranch != "" {
+		cmd := exec.Command("git", "check-ref-format", "refs/heads/"+req.DefaultBranch)
+		if err := cmd.Run(); err != nil || strings.HasPrefix(req.DefaultBranch, "-") {
+			return fmt.Errorf("invalid branch name: %s", req.DefaultBranch)
+		}
+	}
+
+	topics := []string{}
+	seen := make(map[string]bool)
+	for _, topic := range req.Topics {
+		topic = strings.ToLower(strings.TrimSpace(topic))
+		if topic == "" || seen[topic] {
+			continue
+		}
+		if !repoTopicPattern.MatchString(topic) {
+			return fmt.Errorf("invalid topic %q: use up to 50 lowercase letters, digits and hyphens", topic)
+		}
+		seen[topic] = true
+		topics = append(topics, topic)
+	}
+	if len(topics) > maxRepoTopics {
+		return fmt.Errorf("a repository can have at most %d topics", maxRepoTopics)
+	}
+	req.Topics = topics
+	return nil
+}
+
+// hasInitialCommit reports whether GitHub creates the repository with files
+func (req *CreateGithubRepoRequest) hasInitialCommit() bool {
+	return req.Template != "" || req.Gitignore != "" || req.License != ""
+}
+
+// createGitHubRepo creates the repository the request describes, with its
+// topics and default branch. Returns the repository and what could not be set
+// up once it existed.
+func createGitHubRepo(client *GitHubClient, req CreateGithubRepoRequest) (*GitHubRepo, []string, error) {
+	var repo *GitHubRepo
+	var err error
+	if req.Template != "" {
+		repo, err = client.GenerateRepository(req.Template, GitHubGenerateRepoRequest{
+			Owner:       req.Owner,
+			Name:        req.RepoName,
+			Description: req.Description,
+			Private:     req.Private,
+		})
+	} else {
+		repo, err = client.CreateRepository(req.Owner, GitHubCreateRepoRequest{
+			Name:              req.RepoName,
+			Description:       req.Description,
+			Private:           req.Private,
+			GitignoreTemplate: req.Gitignore,
+			LicenseTemplate:   req.License,
+		})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	if len(req.Topics) > 0 {
+		if err := client.ReplaceTopics(repo.FullName, req.Topics); err != nil {
+			warnings = append(warnings, "Could not set topics: "+err.Error())
+		}
+	}
+	// An empty repository gets its default branch with the first push
+	if req.hasInitialCommit() && req.DefaultBranch != "" && req.DefaultBranch != repo.DefaultBranch {
+		if err := client.RenameBranch(repo.FullName, repo.DefaultBranch, req.DefaultBranch); err != nil {
+			warnings = append(warnings, fmt.Sprintf("Could not rename %s to %s: %v", repo.DefaultBranch, req.DefaultBranch, err))
+		} else {
+			repo.DefaultBranch = req.DefaultBranch
+		}
+	}
+	return repo, warnings, nil
+}
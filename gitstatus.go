@@ -0,0 +1,274 @@
+// gitstatus.go caches the git status of projects (branch, dirty flag,
+// ahead/behind, last commit). Listing projects used to run git for every
+// project on every call; now the cache is refreshed in the background every
+// FORGE_GIT_STATUS_INTERVAL and invalidated whenever FORGE itself changes a
+// repository (RALPH runs, pushes, branch switches, rollbacks).
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultGitStatusInterval is how often the cached git status is refreshed
+const defaultGitStatusInterval = 30 * time.Second
+
+// gitStatusTimeout bounds a single git call, e.g. on a hanging network filesystem
+const gitStatusTimeout = 10 * time.Second
+
+// gitStatus is the process-wide git status cache
+var gitStatus = newGitStatusCache(gitStatusIntervalFromEnv())
+
+// gitStatusCache holds the last known status per project path
+type gitStatusCache struct {
+	mu       sync.Mutex
+	entries  map[string]ProjectHealth
+	interval time.Duration // 0 disables caching; every read runs git
+}
+
+func newGitStatusCache(interval time.Duration) *gitStatusCache {
+	return &gitStatusCache{
+		entries:  make(map[string]ProjectHealth),
+		interval: interval,
+	}
+}
+
+// gitStatusIntervalFromEnv reads FORGE_GIT_STATUS_INTERVAL (e.g. 1m; 0 disables the cache)
+func gitStatusIntervalFromEnv() time.Duration {
+	v := os.Getenv("FORGE_GIT_STATUS_INTERVAL")
+	if v == "" {
+		return defaultGitStatusInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("gitstatus").Warn("Ignoring invalid FORGE_GIT_STATUS_INTERVAL", "value", v)
+		return defaultGitStatusInterval
+	}
+	return d
+}
+
+// Get returns the cached status of the repository at path, reading it on a cache miss
+func (c *gitStatusCache) Get(path string) ProjectHealth {
+	if !IsGitRepository(path) {
+		return ProjectHealth{CheckedAt: time.Now()}
+	}
+	if c.interval == 0 {
+		return readGitStatus(path)
+	}
+
+	c.mu.Lock()
+	health, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok {
+		return health
+	}
+	return c.Refresh(path)
+}
+
+// Refresh reads the status of the repository at path and updates the cache
+func (c *gitStatusCache) Refresh(path string) ProjectHealth {
+	health := readGitStatus(path)
+	if c.interval > 0 && health.IsGitRepo {
+		c.mu.Lock()
+		c.entries[path] = health
+		c.mu.Unlock()
+	}
+	return health
+}
+
+// Invalidate drops the cached status of path, and of the other projects in
+// the same repository, and re-reads them in the background
+func (c *gitStatusCache) Invalidate(path string) {
+	if path == "" {
+		return
+	}
+	root := gitRoot(path)
+	paths := []string{path}
+	c.mu.Lock()
+	delete(c.entries, path)
+	if root != "" {
+		for cached := range c.entries {
+			if gitRoot(cached) == root {
+				delete(c.entries, cached)
+				paths = append(paths, cached)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	if c.interval > 0 && root != "" {
+		go func() {
+			for _, p := range paths {
+				c.Refresh(p)
+			}
+		}()
+	}
+}
+
+// Run refreshes the status of all projects every interval until stop is closed
+func (c *gitStatusCache) Run(db Store, stop <-chan struct{}) {
+	if c.interval == 0 {
+		componentLog("gitstatus").Info("Cache disabled")
+		return
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.refreshAll(db)
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// refreshAll re-reads every project and forgets paths that are no longer projects
+func (c *gitStatusCache) refreshAll(db Store) {
+	projects, err := db.GetAllProjects()
+	if err != nil {
+		componentLog("gitstatus").Error("Failed to list projects", "err", err)
+		return
+	}
+
+	known := make(map[string]bool, len(projects))
+	for _, p := range projects {
+		known[p.Path] = true
+		if p.IsGitRepo {
+			c.Refresh(p.Path)
+		}
+	}
+
+	c.mu.Lock()
+	for path := range c.entries {
+		if !known[path] || !IsGitRepository(path) {
+			delete(c.entries, path)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// readGitStatus runs git to determine the status of the repository at path
+func readGitStatus(path string) ProjectHealth {
+	health := ProjectHealth{IsGitRepo: IsGitRepository(path), CheckedAt: time.Now()}
+	if !health.IsGitRepo {
+		return health
+	}
+
+	if isRepoSubdir(path) {
+		health.RepoRoot = gitRoot(path)
+	}
+
+	// The dirty flag and last commit of a subdirectory project cover its own files
+	scope := scopePathspec(path)
+	status, err := runGitStatusCommand(path, append([]string{"status", "--porcelain=v2", "--branch"}, scope...)...)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	for _, line := range strings.Split(status, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.head "):
+			health.Branch = strings.TrimPrefix(line, "# branch.head ")
+			if health.Branch == "(detached)" {
+				health.Branch = "HEAD"
+			}
+		case strings.HasPrefix(line, "# branch.upstream "):
+			health.HasUpstream = true
+		case strings.HasPrefix(line, "# branch.ab "):
+			fmt.Sscanf(strings.TrimPrefix(line, "# branch.ab "), "+%d -%d", &health.Ahead, &health.Behind)
+		case line != "" && !strings.HasPrefix(line, "#"):
+			health.Dirty = true
+		}
+	}
+
+	if url, err := gitReads.RemoteURL(path, "origin"); err == nil {
+		health.RemoteURL = url
+	}
+
+	// Fails in a repository without commits, which simply has no last commit
+	if out, err := runGitStatusCommand(path, append([]string{"log", "-1", "--format=%H%x00%s%x00%an%x00%cI"}, scope...)...); err == nil {
+		if fields := strings.Split(strings.TrimSpace(out), "\x00"); len(fields) == 4 {
+			commit := &CommitInfo{Hash: fields[0], Subject: fields[1], Author: fields[2]}
+			commit.Date, _ = time.Parse(time.RFC3339, fields[3])
+			health.LastCommit = commit
+		}
+	}
+
+	return health
+}
+
+func runGitStatusCommand(path string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitStatusTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(output), nil
+}
+
+// applyGitStatus fills the computed git fields of p from the cache
+func (p *Project) applyGitStatus() {
+	health := gitStatus.Get(p.Path)
+	p.IsGitRepo = health.IsGitRepo
+	p.CurrentBranch = health.Branch
+	p.RepoRoot = health.RepoRoot
+	p.RepoLock = repoLockStatus(p.Path)
+	endpoints := githubEndpointsFor(nil, p)
+	if repoPath, err := endpoints.parseRepo(health.RemoteURL); err == nil {
+		p.GithubURL = endpoints.repoURL(repoPath)
+	}
+}
+
+// invalidateGitStatus drops the cached status of the task's project after RALPH touched it
+func (r *RalphRunner) invalidateGitStatus(task *Task) {
+	if task == nil {
+		return
+	}
+	projectDir := task.ProjectDir
+	if projectDir == "" && task.ProjectID != "" {
+		if project, _ := r.db.GetProject(task.ProjectID); project != nil {
+			projectDir = project.Path
+		}
+	}
+	gitStatus.Invalidate(projectDir)
+}
+
+// HandleProjectHealth handles GET /api/projects/{id}/health
+// With ?refresh=true the status is read from git instead of the cache.
+func (h *Handler) HandleProjectHealth(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	project, err := h.db.GetProject(id)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	var health ProjectHealth
+	if r.URL.Query().Get("refresh") == "true" {
+		health = gitStatus.Refresh(project.Path)
+	} else {
+		health = gitStatus.Get(project.Path)
+	}
+	health.ProjectID = project.ID
+	h.writeJSON(w, http.StatusOK, health)
+}
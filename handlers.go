@@ -4,8 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"mime/multipart"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
@@ -19,17 +18,23 @@ import (
 
 // Handler holds dependencies for HTTP handlers
 type Handler struct {
-	db     *Database
-	hub    *Hub
-	runner *RalphRunner
+	db      Store
+	hub     *Hub
+	runner  *RalphRunner
+	backups *BackupManager
+
+	uploadTTL time.Duration // Unclaimed uploads are deleted after this (0 = never)
 }
 
 // NewHandler creates a new Handler instance
-func NewHandler(db *Database, hub *Hub, runner *RalphRunner) *Handler {
+func NewHandler(db Store, hub *Hub, runner *RalphRunner, backups *BackupManager) *Handler {
 	return &Handler{
-		db:     db,
-		hub:    hub,
-		runner: runner,
+		db:      db,
+		hub:     hub,
+		runner:  runner,
+		backups: backups,
+
+		uploadTTL: uploadTTLFromEnv(),
 	}
 }
 
@@ -45,30 +50,22 @@ func (h *Handler) writeError(w http.ResponseWriter, status int, message string)
 	h.writeJSON(w, status, map[string]string{"error": message})
 }
 
-func extractTaskID(path string) string {
-	// Extract task ID from paths like /api/tasks/{id} or /api/tasks/{id}/action
-	parts := strings.Split(strings.TrimPrefix(path, "/api/tasks/"), "/")
-	if len(parts) > 0 {
-		return parts[0]
-	}
-	return ""
-}
-
 // Task handlers
 
 // HandleTasks handles GET /api/tasks and POST /api/tasks
 func (h *Handler) HandleTasks(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		h.getTasks(w, r)
+		h.getTasks(w, r, r.URL.Query().Get("board_id"))
 	case http.MethodPost:
-		h.createTask(w, r)
+		h.createTask(w, r, "")
 	default:
 		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
 	}
 }
 
-func (h *Handler) getTasks(w http.ResponseWriter, r *http.Request) {
+// getTasks lists the tasks of a board, or of all boards for boardID ""
+func (h *Handler) getTasks(w http.ResponseWriter, r *http.Request, boardID string) {
 	tasks, err := h.db.GetAllTasks()
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to get tasks: "+err.Error())
@@ -78,6 +75,21 @@ func (h *Handler) getTasks(w http.ResponseWriter, r *http.Request) {
 		tasks = []Task{}
 	}
 
+	if boardID != "" {
+		onBoard := tasks[:0]
+		for _, task := range tasks {
+			if task.BoardID == boardID {
+				onBoard = append(onBoard, task)
+			}
+		}
+		tasks = onBoard
+	}
+
+	// ?label=<id|name> (repeatable): only tasks carrying all given labels
+	if refs := r.URL.Query()["label"]; len(refs) > 0 {
+		tasks = filterTasksByLabels(tasks, refs)
+	}
+
 	// Load attachments for each task
 	for i := range tasks {
 		attachments, err := h.db.GetAttachmentsByTask(tasks[i].ID)
@@ -85,11 +97,14 @@ func (h *Handler) getTasks(w http.ResponseWriter, r *http.Request) {
 			tasks[i].Attachments = attachments
 		}
 	}
+	h.withQueueETAs(r, tasks)
 
 	h.writeJSON(w, http.StatusOK, tasks)
 }
 
-func (h *Handler) createTask(w http.ResponseWriter, r *http.Request) {
+// createTask creates a task on boardID, or for "" on the board of the request
+// body, else of the task's project
+func (h *Handler) createTask(w http.ResponseWriter, r *http.Request, boardID string) {
 	var req CreateTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
@@ -100,6 +115,38 @@ func (h *Handler) createTask(w http.ResponseWriter, r *http.Request) {
 		h.writeError(w, http.StatusBadRequest, "Title is required")
 		return
 	}
+	if boardID != "" {
+		req.BoardID = boardID
+	}
+	if req.BoardID != "" && !h.checkBoard(w, req.BoardID) {
+		return
+	}
+	if err := validateTaskEnv(req.Env); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	workDir, err := cleanWorkDir(req.WorkDir)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.WorkDir = workDir
+
+	// Likely duplicates are returned instead of creating the task
+	if !req.Force {
+		duplicates, err := h.findDuplicateTasks(req)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to check for duplicates: "+err.Error())
+			return
+		}
+		if len(duplicates) > 0 {
+			h.writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":      duplicateError(duplicates),
+				"duplicates": duplicates,
+			})
+			return
+		}
+	}
 
 	config, err := h.db.GetConfig()
 	if err != nil {
@@ -109,10 +156,22 @@ func (h *Handler) createTask(w http.ResponseWriter, r *http.Request) {
 
 	task, err := h.db.CreateTask(req, config)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "Failed to create task: "+err.Error())
+		h.writeLabelError(w, "create", err)
 		return
 	}
 
+	// Uploads pasted while composing become attachments of the new task
+	description, criteria := task.Description, task.AcceptanceCriteria
+	if h.claimUploads(task.ID, &description, &criteria) {
+		updated, err := h.db.UpdateTask(task.ID, UpdateTaskRequest{Description: &description, AcceptanceCriteria: &criteria})
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to attach uploads: "+err.Error())
+			return
+		}
+		task = updated
+		task.Attachments, _ = h.db.GetAttachmentsByTask(task.ID)
+	}
+
 	// Broadcast new task
 	h.hub.BroadcastTaskUpdate(task)
 
@@ -121,11 +180,7 @@ func (h *Handler) createTask(w http.ResponseWriter, r *http.Request) {
 
 // HandleTask handles GET/PUT/DELETE /api/tasks/{id}
 func (h *Handler) HandleTask(w http.ResponseWriter, r *http.Request) {
-	id := extractTaskID(r.URL.Path)
-	if id == "" {
-		h.writeError(w, http.StatusBadRequest, "Task ID required")
-		return
-	}
+	id := r.PathValue("id")
 
 	switch r.Method {
 	case http.MethodGet:
@@ -155,6 +210,11 @@ func (h *Handler) getTask(w http.ResponseWriter, r *http.Request, id string) {
 	if err == nil {
 		task.Attachments = attachments
 	}
+	if task.QueuePosition > 0 || h.runner.IsRunning(task.ID) {
+		tasks := []Task{*task}
+		h.withQueueETAs(r, tasks)
+		task = &tasks[0]
+	}
 
 	h.writeJSON(w, http.StatusOK, task)
 }
@@ -176,17 +236,61 @@ func (h *Handler) updateTask(w http.ResponseWriter, r *http.Request, id string)
 		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
 		return
 	}
+	if req.Env != nil {
+		if err := validateTaskEnv(*req.Env); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.WorkDir != nil {
+		workDir, err := cleanWorkDir(*req.WorkDir)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.WorkDir = &workDir
+	}
+	if !h.checkTaskBoard(w, currentTask, req) {
+		return
+	}
 
 	oldStatus := currentTask.Status
 
+	// Custom columns behave like queued/progress according to their role
+	oldRole := h.columnRole(oldStatus)
+	newRole := oldRole
+	if req.Status != nil && *req.Status != oldStatus {
+		column, err := h.db.GetBoardColumn(*req.Status)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get column: "+err.Error())
+			return
+		}
+		if column == nil {
+			h.writeError(w, http.StatusBadRequest, "Unknown status: "+string(*req.Status))
+			return
+		}
+		newRole = column.Role
+	}
+
 	// Check if moving to progress - need to start RALPH and create branch
-	startRalph := req.Status != nil && *req.Status == StatusProgress && oldStatus != StatusProgress
+	startRalph := req.Status != nil && newRole == ColumnRoleProgress && oldRole != ColumnRoleProgress
 
-	// Sequential mode: If moving to progress and there's already a task in progress, redirect to queue
+	// Moving into a queue column appends the task to the queue
+	enqueue := req.Status != nil && newRole == ColumnRoleQueue && oldRole != ColumnRoleQueue
+
+	// Queue lanes: if the task's project is busy or the concurrency limit is reached, redirect to queue
 	if startRalph {
-		hasInProgress, _ := h.db.HasTaskInProgress()
-		if hasInProgress {
+		config, err := h.db.GetConfig()
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+			return
+		}
+		if !h.runner.canStartNow(currentTask, config) {
 			// Redirect to queue instead of progress
+			if err := h.checkWIPLimit(StatusQueued); err != nil {
+				h.writeWIPError(w, err)
+				return
+			}
 			if err := h.db.AddToQueue(id); err != nil {
 				h.writeError(w, http.StatusInternalServerError, "Failed to add task to queue: "+err.Error())
 				return
@@ -205,19 +309,42 @@ func (h *Handler) updateTask(w http.ResponseWriter, r *http.Request, id string)
 		}
 	}
 
+	// Unknown labels are rejected before any queue or runner side effects
+	if req.LabelIDs != nil {
+		if err := h.checkLabelIDs(*req.LabelIDs); err != nil {
+			h.writeLabelError(w, "update", err)
+			return
+		}
+	}
+
+	// Reject moves into a column that is at its WIP limit
+	if req.Status != nil && *req.Status != oldStatus {
+		if err := h.checkWIPLimit(*req.Status); err != nil {
+			h.writeWIPError(w, err)
+			return
+		}
+	}
+
 	// Trunk-based development: No automatic push when moving to review
 	// Users push manually using the Push button in the UI
 
 	// If moving away from progress, stop RALPH
-	if req.Status != nil && *req.Status != StatusProgress && oldStatus == StatusProgress {
+	if req.Status != nil && newRole != ColumnRoleProgress && oldRole == ColumnRoleProgress {
 		h.runner.Stop(id)
 	}
 
 	// If moving away from queued, remove from queue
-	if req.Status != nil && *req.Status != StatusQueued && oldStatus == StatusQueued {
+	if req.Status != nil && newRole != ColumnRoleQueue && oldRole == ColumnRoleQueue {
 		h.db.RemoveFromQueue(id)
 	}
 
+	if enqueue {
+		if err := h.db.AddToQueueWithStatus(id, *req.Status); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to add task to queue: "+err.Error())
+			return
+		}
+	}
+
 	// Reset task if moving to progress
 	if startRalph {
 		if err := h.db.ResetTaskForProgress(id); err != nil {
@@ -227,6 +354,7 @@ func (h *Handler) updateTask(w http.ResponseWriter, r *http.Request, id string)
 	}
 
 	// Trunk-based development: Switch to working branch and create rollback tag
+	var conflict *MergeConflict
 	if startRalph {
 		projectDir := currentTask.ProjectDir
 		var project *Project
@@ -249,33 +377,57 @@ func (h *Handler) updateTask(w http.ResponseWriter, r *http.Request, id string)
 			// Switch to target branch if set
 			if targetBranch != "" {
 				if err := EnsureOnBranch(projectDir, targetBranch); err != nil {
-					log.Printf("Warning: Failed to switch to branch %s: %v", targetBranch, err)
+					logFrom(r.Context()).Warn("Failed to switch to branch", "task_id", id, "branch", targetBranch, "err", err)
 				}
 				// Update task's working branch
 				req.WorkingBranch = &targetBranch
 			}
 
-			// Pull latest changes
-			if err := PullFromRemote(projectDir); err != nil {
-				log.Printf("Warning: Pull failed: %v", err)
+			// Merge the latest changes. A resolution task merges them itself (see conflicts.go).
+			if !h.runner.isResolutionTask(id) {
+				var err error
+				if conflict, err = MergeUpstream(projectDir); err != nil {
+					logFrom(r.Context()).Warn("Pull failed", "task_id", id, "err", err)
+				}
+				if conflict != nil {
+					// The task is blocked instead of started
+					blocked := StatusBlocked
+					req.Status = &blocked
+					startRalph = false
+				} else if branch := checkoutTaskBranch(projectDir, currentTask, project); branch != "" {
+					// Branch-per-task workflow: the task gets its own branch off the target branch
+					req.WorkingBranch = &branch
+				}
 			}
 
 			// Create rollback tag
-			tagName, err := CreateRollbackTag(projectDir, currentTask.ID)
-			if err == nil {
-				h.db.UpdateTaskRollbackTag(currentTask.ID, tagName)
-			} else {
-				log.Printf("Warning: Failed to create rollback tag: %v", err)
+			if conflict == nil {
+				tagName, err := CreateRollbackTag(projectDir, currentTask.ID)
+				if err == nil {
+					h.db.UpdateTaskRollbackTag(currentTask.ID, tagName)
+				} else {
+					logFrom(r.Context()).Warn("Failed to create rollback tag", "task_id", id, "err", err)
+				}
 			}
 		}
 	}
 
+	// Uploads pasted while editing become attachments of the task
+	h.claimUploads(id, req.Description, req.AcceptanceCriteria)
+
 	task, err := h.db.UpdateTask(id, req)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "Failed to update task: "+err.Error())
+		h.writeLabelError(w, "update", err)
 		return
 	}
 
+	// UpdateTask doesn't return the queue position
+	if enqueue {
+		if queued, err := h.db.GetTask(id); err == nil && queued != nil {
+			task = queued
+		}
+	}
+
 	// Load attachments for broadcast
 	if attachments, err := h.db.GetAttachmentsByTask(task.ID); err == nil {
 		task.Attachments = attachments
@@ -284,10 +436,41 @@ func (h *Handler) updateTask(w http.ResponseWriter, r *http.Request, id string)
 	// Broadcast update
 	h.hub.BroadcastTaskUpdate(task)
 
+	// Close the GitHub or Linear issue the task was imported from
+	if task.Status == StatusDone && oldStatus != StatusDone {
+		go finishTrackerIssues(h.db, h.hub, task.ID)
+	}
+
+	// A conflict at start blocks the task behind a resolution task; a resolution
+	// task moved to done resumes the task it unblocks (see conflicts.go), and
+	// any task moved to done may let the tasks depending on it start
+	if conflict != nil {
+		if err := h.runner.openConflict(task, conflict, ConflictStageStart); err != nil {
+			logFrom(r.Context()).Error("Failed to open merge conflict", "task_id", id, "err", err)
+		}
+		if blocked, _ := h.db.GetTask(id); blocked != nil {
+			task = blocked
+		}
+		go h.runner.TryStartNextQueued(r.Context())
+	} else if task.Status == StatusDone && oldStatus != StatusDone {
+		h.runner.checkConflictResolved(task.ID)
+		go h.runner.TryStartNextQueued(r.Context())
+	}
+
+	// Move the Jira issue the task was imported from
+	if task.JiraKey != "" && task.Status != oldStatus {
+		jiraSync.Notify()
+	}
+
 	// Start RALPH if needed
 	if startRalph {
 		config, _ := h.db.GetConfig()
-		go h.runner.Start(task, config)
+		go h.runner.Start(r.Context(), task, config)
+	}
+
+	// Start the queue right away if nothing is running
+	if enqueue {
+		go h.runner.TryStartNextQueued(r.Context())
 	}
 
 	h.writeJSON(w, http.StatusOK, task)
@@ -299,7 +482,7 @@ func (h *Handler) deleteTask(w http.ResponseWriter, r *http.Request, id string)
 
 	// Delete attachments first
 	if err := h.DeleteTaskAttachments(id); err != nil {
-		log.Printf("Warning: Failed to delete task attachments: %v", err)
+		logFrom(r.Context()).Warn("Failed to delete task attachments", "task_id", id, "err", err)
 	}
 
 	if err := h.db.DeleteTask(id); err != nil {
@@ -314,16 +497,7 @@ func (h *Handler) deleteTask(w http.ResponseWriter, r *http.Request, id string)
 
 // HandleTaskPause handles POST /api/tasks/{id}/pause
 func (h *Handler) HandleTaskPause(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	id := extractTaskID(r.URL.Path)
-	if id == "" {
-		h.writeError(w, http.StatusBadRequest, "Task ID required")
-		return
-	}
+	id := r.PathValue("id")
 
 	if err := h.runner.Pause(id); err != nil {
 		h.writeError(w, http.StatusBadRequest, err.Error())
@@ -335,16 +509,7 @@ func (h *Handler) HandleTaskPause(w http.ResponseWriter, r *http.Request) {
 
 // HandleTaskResume handles POST /api/tasks/{id}/resume
 func (h *Handler) HandleTaskResume(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	id := extractTaskID(r.URL.Path)
-	if id == "" {
-		h.writeError(w, http.StatusBadRequest, "Task ID required")
-		return
-	}
+	id := r.PathValue("id")
 
 	if err := h.runner.Resume(id); err != nil {
 		h.writeError(w, http.StatusBadRequest, err.Error())
@@ -356,16 +521,7 @@ func (h *Handler) HandleTaskResume(w http.ResponseWriter, r *http.Request) {
 
 // HandleTaskStop handles POST /api/tasks/{id}/stop
 func (h *Handler) HandleTaskStop(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	id := extractTaskID(r.URL.Path)
-	if id == "" {
-		h.writeError(w, http.StatusBadRequest, "Task ID required")
-		return
-	}
+	id := r.PathValue("id")
 
 	h.runner.Stop(id)
 	h.writeJSON(w, http.StatusOK, map[string]string{"status": "stopped"})
@@ -374,16 +530,7 @@ func (h *Handler) HandleTaskStop(w http.ResponseWriter, r *http.Request) {
 // HandleTaskFeedback handles POST /api/tasks/{id}/feedback
 // This can send feedback to a running task OR continue a non-running task
 func (h *Handler) HandleTaskFeedback(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	id := extractTaskID(r.URL.Path)
-	if id == "" {
-		h.writeError(w, http.StatusBadRequest, "Task ID required")
-		return
-	}
+	id := r.PathValue("id")
 
 	var req FeedbackRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -415,7 +562,7 @@ func (h *Handler) HandleTaskFeedback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Use Continue which handles both running and non-running tasks
-	if err := h.runner.Continue(task, config, req.Message); err != nil {
+	if err := h.runner.Continue(r.Context(), task, config, req.Message); err != nil {
 		h.writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -426,16 +573,7 @@ func (h *Handler) HandleTaskFeedback(w http.ResponseWriter, r *http.Request) {
 // HandleTaskContinue handles POST /api/tasks/{id}/continue
 // This adds a task to the queue with a continue message for RALPH
 func (h *Handler) HandleTaskContinue(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	id := extractTaskID(r.URL.Path)
-	if id == "" {
-		h.writeError(w, http.StatusBadRequest, "Task ID required")
-		return
-	}
+	id := r.PathValue("id")
 
 	var req FeedbackRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -460,6 +598,16 @@ func (h *Handler) HandleTaskContinue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Tasks interrupted by a shutdown are told about their WIP commit
+	if req.Message == "" && task.ProcessStatus == ProcessStatusResumable {
+		req.Message = resumeMessage(h.db, task)
+	}
+
+	if err := h.checkWIPLimit(StatusQueued); err != nil {
+		h.writeWIPError(w, err)
+		return
+	}
+
 	// Add to queue with message
 	if err := h.db.AddToQueueWithMessage(id, req.Message); err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to add task to queue: "+err.Error())
@@ -477,7 +625,7 @@ func (h *Handler) HandleTaskContinue(w http.ResponseWriter, r *http.Request) {
 	h.hub.BroadcastTaskUpdate(updatedTask)
 
 	// Try to start the next queued task (if no task is currently running)
-	go h.runner.TryStartNextQueued()
+	go h.runner.TryStartNextQueued(r.Context())
 
 	h.writeJSON(w, http.StatusOK, map[string]interface{}{
 		"status":         "queued",
@@ -514,6 +662,59 @@ func (h *Handler) updateConfig(w http.ResponseWriter, r *http.Request) {
 		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
 		return
 	}
+	if req.MaxConcurrentTasks != nil && *req.MaxConcurrentTasks < 1 {
+		h.writeError(w, http.StatusBadRequest, "max_concurrent_tasks must be at least 1")
+		return
+	}
+	if req.AttachmentTypes != nil {
+		types, err := normalizeAttachmentTypes(*req.AttachmentTypes)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.AttachmentTypes = &types
+	}
+	if req.Claude != nil {
+		if err := req.Claude.clean(); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid Claude settings: "+err.Error())
+			return
+		}
+	}
+	if req.LogRetention != nil {
+		if err := req.LogRetention.validate(); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.RetryPolicy != nil {
+		if err := req.RetryPolicy.validate(); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Budget != nil {
+		if err := req.Budget.validate(); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.GitHub != nil {
+		if err := req.GitHub.normalize(); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Signing != nil {
+		if err := req.Signing.normalize(); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// A key that cannot sign would fail every commit of the projects that sign
+		if err := req.Signing.test(); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Signing key does not work: "+err.Error())
+			return
+		}
+	}
 
 	config, err := h.db.UpdateConfig(req)
 	if err != nil {
@@ -521,6 +722,21 @@ func (h *Handler) updateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A different Claude command may support different flags
+	if req.ClaudeCommand != nil {
+		go claudeCLI.Refresh(claudeCommand(config))
+	}
+	// A raised budget lets queued tasks start again
+	if req.Budget != nil {
+		go h.runner.TryStartNextQueued(r.Context())
+	}
+	if req.GitHub != nil {
+		setConfigGitHub(config)
+	}
+	if req.Signing != nil {
+		h.reloadSigning()
+	}
+
 	h.writeJSON(w, http.StatusOK, config)
 }
 
@@ -535,25 +751,28 @@ type DirectoryEntry struct {
 
 // HandleBrowse handles GET /api/browse?path=/some/path
 func (h *Handler) HandleBrowse(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	if fsAccess.browseDisabled {
+		h.writeError(w, http.StatusForbidden, errBrowseDisabled.Error())
 		return
 	}
 
 	requestedPath := r.URL.Query().Get("path")
 
-	// Default to home directory if no path specified
+	// Default to home directory (or the first allowed root) if no path specified
 	if requestedPath == "" {
-		home, err := os.UserHomeDir()
+		start, err := fsAccess.defaultBrowsePath()
 		if err != nil {
 			h.writeError(w, http.StatusInternalServerError, "Failed to get home directory")
 			return
 		}
-		requestedPath = home
+		requestedPath = start
 	}
 
-	// Clean and expand the path
-	requestedPath = filepath.Clean(requestedPath)
+	// Clean the path and keep it inside the allowed roots
+	requestedPath, ok := h.allowedPath(w, requestedPath)
+	if !ok {
+		return
+	}
 
 	// Check if path exists and is a directory
 	info, err := os.Stat(requestedPath)
@@ -596,9 +815,15 @@ func (h *Handler) HandleBrowse(w http.ResponseWriter, r *http.Request) {
 		return strings.ToLower(dirs[i].Name) < strings.ToLower(dirs[j].Name)
 	})
 
+	// No way up from an allowed root
+	parentPath := filepath.Dir(requestedPath)
+	if fsAccess.isRoot(requestedPath) {
+		parentPath = ""
+	}
+
 	response := map[string]interface{}{
 		"current_path": requestedPath,
-		"parent_path":  filepath.Dir(requestedPath),
+		"parent_path":  parentPath,
 		"directories":  dirs,
 		"is_repo":      isGitRepo(requestedPath),
 	}
@@ -618,8 +843,8 @@ func isGitRepo(path string) bool {
 
 // HandleCreateDir handles POST /api/browse/create
 func (h *Handler) HandleCreateDir(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	if fsAccess.browseDisabled {
+		h.writeError(w, http.StatusForbidden, errBrowseDisabled.Error())
 		return
 	}
 
@@ -636,8 +861,11 @@ func (h *Handler) HandleCreateDir(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Clean the path
-	cleanPath := filepath.Clean(req.Path)
+	// Clean the path and keep it inside the allowed roots
+	cleanPath, ok := h.allowedPath(w, req.Path)
+	if !ok {
+		return
+	}
 
 	// Create the directory
 	if err := os.MkdirAll(cleanPath, 0755); err != nil {
@@ -668,27 +896,24 @@ func (h *Handler) HandleProjects(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) getProjects(w http.ResponseWriter, r *http.Request) {
+	h.getProjectsOfBoard(w, r.URL.Query().Get("board_id"))
+}
+
+// getProjectsOfBoard lists the projects of a board, or of all boards for boardID ""
+func (h *Handler) getProjectsOfBoard(w http.ResponseWriter, boardID string) {
 	projects, err := h.db.GetAllProjects()
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to get projects: "+err.Error())
 		return
 	}
-	if projects == nil {
-		projects = []Project{}
-	}
-
-	// Enrich projects with GitHub URL if they have a remote
-	for i := range projects {
-		if projects[i].IsGitRepo {
-			if remoteURL, err := GetRemoteURL(projects[i].Path); err == nil && remoteURL != "" {
-				if repoPath, err := ParseGitHubRepoFromURL(remoteURL); err == nil {
-					projects[i].GithubURL = "https://github.com/" + repoPath
-				}
-			}
+	onBoard := []Project{}
+	for _, project := range projects {
+		if boardID == "" || project.BoardID == boardID {
+			onBoard = append(onBoard, project)
 		}
 	}
 
-	h.writeJSON(w, http.StatusOK, projects)
+	h.writeJSON(w, http.StatusOK, onBoard)
 }
 
 func (h *Handler) createProject(w http.ResponseWriter, r *http.Request) {
@@ -706,6 +931,49 @@ func (h *Handler) createProject(w http.ResponseWriter, r *http.Request) {
 		h.writeError(w, http.StatusBadRequest, "Path is required")
 		return
 	}
+	if req.Workflow != "" && !isValidWorkflow(req.Workflow) {
+		h.writeError(w, http.StatusBadRequest, "Invalid workflow (use trunk or branch)")
+		return
+	}
+	if req.DeployTimeout < 0 {
+		h.writeError(w, http.StatusBadRequest, "Deploy timeout must not be negative")
+		return
+	}
+	if err := req.Claude.clean(); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid Claude settings: "+err.Error())
+		return
+	}
+	req.ScreenshotURL = strings.TrimSpace(req.ScreenshotURL)
+	if err := validateScreenshotURL(req.ScreenshotURL); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := req.Budget.validate(); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := req.GitHub.normalize(); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.AutoMerge != "" && !isValidMergeMethod(req.AutoMerge) {
+		h.writeError(w, http.StatusBadRequest, "Invalid auto_merge (use merge, squash or rebase)")
+		return
+	}
+	req.CommitTemplate = strings.TrimSpace(req.CommitTemplate)
+	if err := validateCommitTemplate(req.CommitTemplate); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.TestCommand = strings.TrimSpace(req.TestCommand)
+	req.BuildCommand = strings.TrimSpace(req.BuildCommand)
+	if req.BoardID != "" && !h.checkBoard(w, req.BoardID) {
+		return
+	}
+
+	if _, ok := h.allowedPath(w, req.Path); !ok {
+		return
+	}
 
 	// Check if path exists
 	if _, err := os.Stat(req.Path); os.IsNotExist(err) {
@@ -725,6 +993,11 @@ func (h *Handler) createProject(w http.ResponseWriter, r *http.Request) {
 		h.writeError(w, http.StatusInternalServerError, "Failed to create project: "+err.Error())
 		return
 	}
+	detectNewProjectStack(h.db, project)
+
+	if project.SignCommits {
+		h.reloadSigning()
+	}
 
 	h.hub.BroadcastProjectUpdate(project)
 	h.writeJSON(w, http.StatusCreated, project)
@@ -732,43 +1005,7 @@ func (h *Handler) createProject(w http.ResponseWriter, r *http.Request) {
 
 // HandleProject handles GET/PUT/DELETE /api/projects/{id}
 func (h *Handler) HandleProject(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
-
-	// Handle special routes
-	if strings.HasSuffix(path, "/rules") {
-		h.HandleBranchRules(w, r)
-		return
-	}
-	if strings.Contains(path, "/rules/") {
-		h.HandleBranchRule(w, r)
-		return
-	}
-	if strings.HasSuffix(path, "/git-info") {
-		h.getProjectGitInfo(w, r)
-		return
-	}
-	if strings.HasSuffix(path, "/branches") {
-		h.getProjectBranches(w, r)
-		return
-	}
-	if strings.HasSuffix(path, "/branch-status") {
-		h.getProjectBranchStatus(w, r)
-		return
-	}
-	if strings.HasSuffix(path, "/checkout") {
-		h.handleProjectCheckout(w, r)
-		return
-	}
-	if strings.HasSuffix(path, "/pull") {
-		h.handleProjectPull(w, r)
-		return
-	}
-
-	id := extractProjectID(path)
-	if id == "" {
-		h.writeError(w, http.StatusBadRequest, "Project ID required")
-		return
-	}
+	id := r.PathValue("id")
 
 	switch r.Method {
 	case http.MethodGet:
@@ -782,14 +1019,6 @@ func (h *Handler) HandleProject(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func extractProjectID(path string) string {
-	parts := strings.Split(strings.TrimPrefix(path, "/api/projects/"), "/")
-	if len(parts) > 0 {
-		return parts[0]
-	}
-	return ""
-}
-
 func (h *Handler) getProject(w http.ResponseWriter, r *http.Request, id string) {
 	project, err := h.db.GetProject(id)
 	if err != nil {
@@ -809,6 +1038,63 @@ func (h *Handler) updateProject(w http.ResponseWriter, r *http.Request, id strin
 		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
 		return
 	}
+	if req.Workflow != nil && !isValidWorkflow(*req.Workflow) {
+		h.writeError(w, http.StatusBadRequest, "Invalid workflow (use trunk or branch)")
+		return
+	}
+	if req.DeployTimeout != nil && *req.DeployTimeout < 0 {
+		h.writeError(w, http.StatusBadRequest, "Deploy timeout must not be negative")
+		return
+	}
+	if req.Claude != nil {
+		if err := req.Claude.clean(); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid Claude settings: "+err.Error())
+			return
+		}
+	}
+	if req.ScreenshotURL != nil {
+		screenshotURL := strings.TrimSpace(*req.ScreenshotURL)
+		if err := validateScreenshotURL(screenshotURL); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.ScreenshotURL = &screenshotURL
+	}
+	if req.Budget != nil {
+		if err := req.Budget.validate(); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.GitHub != nil {
+		if err := req.GitHub.normalize(); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.AutoMerge != nil && *req.AutoMerge != "" && !isValidMergeMethod(*req.AutoMerge) {
+		h.writeError(w, http.StatusBadRequest, "Invalid auto_merge (use merge, squash or rebase)")
+		return
+	}
+	if req.CommitTemplate != nil {
+		commitTemplate := strings.TrimSpace(*req.CommitTemplate)
+		if err := validateCommitTemplate(commitTemplate); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.CommitTemplate = &commitTemplate
+	}
+	if req.TestCommand != nil {
+		testCommand := strings.TrimSpace(*req.TestCommand)
+		req.TestCommand = &testCommand
+	}
+	if req.BuildCommand != nil {
+		buildCommand := strings.TrimSpace(*req.BuildCommand)
+		req.BuildCommand = &buildCommand
+	}
+	if req.BoardID != nil && !h.checkBoard(w, *req.BoardID) {
+		return
+	}
 
 	project, err := h.db.UpdateProject(id, req)
 	if err != nil {
@@ -820,6 +1106,16 @@ func (h *Handler) updateProject(w http.ResponseWriter, r *http.Request, id strin
 		return
 	}
 
+	if req.PushHook != nil {
+		syncPushHook(h.db, project.ID)
+	}
+	if req.Budget != nil {
+		go h.runner.TryStartNextQueued(r.Context())
+	}
+	if req.SignCommits != nil {
+		h.reloadSigning()
+	}
+
 	h.hub.BroadcastProjectUpdate(project)
 	h.writeJSON(w, http.StatusOK, project)
 }
@@ -829,16 +1125,13 @@ func (h *Handler) deleteProject(w http.ResponseWriter, r *http.Request, id strin
 		h.writeError(w, http.StatusInternalServerError, "Failed to delete project: "+err.Error())
 		return
 	}
+	h.reloadDeployKeys()
+	h.reloadSigning()
 	h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
 func (h *Handler) getProjectGitInfo(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	id := extractProjectID(r.URL.Path)
+	id := r.PathValue("id")
 	project, err := h.db.GetProject(id)
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
@@ -854,12 +1147,7 @@ func (h *Handler) getProjectGitInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) getProjectBranches(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	id := extractProjectID(r.URL.Path)
+	id := r.PathValue("id")
 	project, err := h.db.GetProject(id)
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
@@ -870,25 +1158,22 @@ func (h *Handler) getProjectBranches(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	branches, err := ListAllBranches(project.Path)
+	defaultBranch := GetDefaultBranch(project.Path)
+	branches, err := gitReads.BranchDetails(project.Path, defaultBranch)
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to list branches: "+err.Error())
 		return
 	}
 
 	h.writeJSON(w, http.StatusOK, map[string]interface{}{
-		"branches": branches,
+		"branches":       branches,
+		"default_branch": defaultBranch,
 	})
 }
 
 // getProjectBranchStatus checks if branch is behind remote
 func (h *Handler) getProjectBranchStatus(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	id := extractProjectID(r.URL.Path)
+	id := r.PathValue("id")
 	project, err := h.db.GetProject(id)
 	if err != nil || project == nil {
 		h.writeError(w, http.StatusNotFound, "Project not found")
@@ -903,15 +1188,12 @@ func (h *Handler) getProjectBranchStatus(w http.ResponseWriter, r *http.Request)
 	// Fetch from remote
 	fetchCmd := exec.Command("git", "fetch", "origin")
 	fetchCmd.Dir = project.Path
+	removeKey := withDeployKey(fetchCmd)
 	fetchCmd.Run()
+	removeKey()
 
 	// Count commits behind
-	behindCmd := exec.Command("git", "rev-list", "--count", fmt.Sprintf("%s..origin/%s", branch, branch))
-	behindCmd.Dir = project.Path
-	output, _ := behindCmd.Output()
-
-	behind := 0
-	fmt.Sscanf(strings.TrimSpace(string(output)), "%d", &behind)
+	behind, _ := gitReads.CountCommits(project.Path, branch, "origin/"+branch)
 
 	h.writeJSON(w, http.StatusOK, map[string]interface{}{
 		"branch": branch,
@@ -921,12 +1203,7 @@ func (h *Handler) getProjectBranchStatus(w http.ResponseWriter, r *http.Request)
 
 // handleProjectCheckout switches to a branch
 func (h *Handler) handleProjectCheckout(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	id := extractProjectID(r.URL.Path)
+	id := r.PathValue("id")
 	project, err := h.db.GetProject(id)
 	if err != nil || project == nil {
 		h.writeError(w, http.StatusNotFound, "Project not found")
@@ -941,16 +1218,15 @@ func (h *Handler) handleProjectCheckout(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Check uncommitted changes
-	if hasChanges, _ := HasUncommittedChanges(project.Path); hasChanges {
-		h.writeJSON(w, http.StatusOK, map[string]interface{}{
-			"success": false,
-			"error":   "Uncommitted changes. Please commit or stash first.",
-		})
+	// Uncommitted changes are carried over to the branch (see stash.go)
+	unlock, err := lockRepoWithin(r.Context(), project.Path, "checkout")
+	if err != nil {
+		h.writeLockError(w, err)
 		return
 	}
-
-	if err := CheckoutBranch(project.Path, req.Branch); err != nil {
+	defer unlock()
+	defer gitStatus.Invalidate(project.Path)
+	if err := CheckoutBranchWithStash(project.Path, req.Branch); err != nil {
 		h.writeJSON(w, http.StatusOK, map[string]interface{}{
 			"success": false,
 			"error":   err.Error(),
@@ -966,30 +1242,26 @@ func (h *Handler) handleProjectCheckout(w http.ResponseWriter, r *http.Request)
 
 // handleProjectPull pulls latest changes
 func (h *Handler) handleProjectPull(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	id := extractProjectID(r.URL.Path)
+	id := r.PathValue("id")
 	project, err := h.db.GetProject(id)
 	if err != nil || project == nil {
 		h.writeError(w, http.StatusNotFound, "Project not found")
 		return
 	}
 
-	// Check uncommitted changes
-	if hasChanges, _ := HasUncommittedChanges(project.Path); hasChanges {
-		h.writeJSON(w, http.StatusOK, map[string]interface{}{
-			"success": false,
-			"error":   "Uncommitted changes. Please commit or stash first.",
-		})
+	// Uncommitted changes are stashed for the pull and restored afterwards
+	unlock, err := lockRepoWithin(r.Context(), project.Path, "pull")
+	if err != nil {
+		h.writeLockError(w, err)
 		return
 	}
-
-	pullCmd := exec.Command("git", "pull", "--ff-only")
+	pullCmd := exec.Command("git", "pull", "--ff-only", "--autostash")
 	pullCmd.Dir = project.Path
+	removeKey := withDeployKey(pullCmd)
 	output, err := pullCmd.CombinedOutput()
+	removeKey()
+	unlock()
+	gitStatus.Invalidate(project.Path)
 
 	if err != nil {
 		h.writeJSON(w, http.StatusOK, map[string]interface{}{
@@ -1006,11 +1278,6 @@ func (h *Handler) handleProjectPull(w http.ResponseWriter, r *http.Request) {
 
 // HandleProjectScan handles POST /api/projects/scan
 func (h *Handler) HandleProjectScan(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
 	var req ScanProjectsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
@@ -1028,43 +1295,26 @@ func (h *Handler) HandleProjectScan(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if req.MaxDepth == 0 {
-		req.MaxDepth = 3 // Default max depth
-	}
-
-	// Detect git repositories
-	repos, err := DetectGitRepos(req.BasePath, req.MaxDepth)
-	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "Failed to scan: "+err.Error())
+	if _, ok := h.allowedPath(w, req.BasePath); !ok {
 		return
 	}
 
-	var created []Project
-	for _, repoPath := range repos {
-		// Check if project already exists
-		existing, _ := h.db.GetProjectByPath(repoPath)
-		if existing != nil {
-			continue
-		}
-
-		// Create project
-		name := GetProjectNameFromPath(repoPath)
-		project, err := h.db.CreateProject(CreateProjectRequest{
-			Name:        name,
-			Path:        repoPath,
-			Description: "",
-		}, true)
-		if err != nil {
-			continue
-		}
-		created = append(created, *project)
-		h.hub.BroadcastProjectUpdate(project)
+	if req.MaxDepth == 0 {
+		req.MaxDepth = defaultScanDepth
+	}
+
+	// Detect git repositories and register the new ones (see scan.go)
+	progress, err := projectScans.scan(h.db, h.hub, req.BasePath, req.MaxDepth, false)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to scan: "+err.Error())
+		return
 	}
 
 	h.writeJSON(w, http.StatusOK, map[string]interface{}{
-		"scanned":  len(repos),
-		"created":  len(created),
-		"projects": created,
+		"scanned":  progress.Found,
+		"created":  progress.Created,
+		"missing":  progress.Missing,
+		"projects": progress.Projects,
 	})
 }
 
@@ -1074,11 +1324,7 @@ func (h *Handler) HandleProjectScan(w http.ResponseWriter, r *http.Request) {
 
 // HandleBranchRules handles GET/POST /api/projects/{id}/rules
 func (h *Handler) HandleBranchRules(w http.ResponseWriter, r *http.Request) {
-	projectID := extractProjectID(r.URL.Path)
-	if projectID == "" {
-		h.writeError(w, http.StatusBadRequest, "Project ID required")
-		return
-	}
+	projectID := r.PathValue("id")
 
 	switch r.Method {
 	case http.MethodGet:
@@ -1103,12 +1349,17 @@ func (h *Handler) HandleBranchRules(w http.ResponseWriter, r *http.Request) {
 			h.writeError(w, http.StatusBadRequest, "Branch pattern is required")
 			return
 		}
+		if !ValidBranchPattern(req.BranchPattern) {
+			h.writeError(w, http.StatusBadRequest, "Invalid branch pattern")
+			return
+		}
 
 		rule, err := h.db.CreateBranchRule(projectID, req.BranchPattern)
 		if err != nil {
 			h.writeError(w, http.StatusInternalServerError, "Failed to create rule: "+err.Error())
 			return
 		}
+		syncPushHook(h.db, projectID)
 
 		h.writeJSON(w, http.StatusCreated, rule)
 
@@ -1117,27 +1368,74 @@ func (h *Handler) HandleBranchRules(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// HandleBranchRule handles DELETE /api/projects/{id}/rules/{ruleId}
+// HandleBranchRule handles PUT/DELETE /api/projects/{id}/rules/{ruleId}
 func (h *Handler) HandleBranchRule(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
+	projectID := r.PathValue("id")
+	ruleID := r.PathValue("ruleId")
+
+	switch r.Method {
+	case http.MethodPut:
+		var req CreateBranchRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if !ValidBranchPattern(req.BranchPattern) {
+			h.writeError(w, http.StatusBadRequest, "Invalid branch pattern")
+			return
+		}
+
+		rule, err := h.db.UpdateBranchRule(projectID, ruleID, req.BranchPattern)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to update rule: "+err.Error())
+			return
+		}
+		if rule == nil {
+			h.writeError(w, http.StatusNotFound, "Rule not found")
+			return
+		}
+		syncPushHook(h.db, projectID)
+
+		h.writeJSON(w, http.StatusOK, rule)
+
+	case http.MethodDelete:
+		if err := h.db.DeleteBranchRule(ruleID); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to delete rule: "+err.Error())
+			return
+		}
+		syncPushHook(h.db, projectID)
+
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+
+	default:
 		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
 	}
+}
 
-	// Extract rule ID from path
-	parts := strings.Split(r.URL.Path, "/rules/")
-	if len(parts) < 2 {
-		h.writeError(w, http.StatusBadRequest, "Rule ID required")
+// HandleTestBranchRules handles POST /api/projects/{id}/rules/test
+// Reports which rules match a branch name and whether FORGE would refuse to push it.
+func (h *Handler) HandleTestBranchRules(w http.ResponseWriter, r *http.Request) {
+	var req TestBranchRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	if req.Branch == "" {
+		h.writeError(w, http.StatusBadRequest, "Branch is required")
 		return
 	}
-	ruleID := parts[1]
 
-	if err := h.db.DeleteBranchRule(ruleID); err != nil {
-		h.writeError(w, http.StatusInternalServerError, "Failed to delete rule: "+err.Error())
+	rules, err := h.db.GetBranchRules(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get rules: "+err.Error())
 		return
 	}
 
-	h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	h.writeJSON(w, http.StatusOK, TestBranchRulesResponse{
+		Branch:    req.Branch,
+		Protected: IsBranchProtected(req.Branch, rules),
+		Matches:   MatchingBranchRules(req.Branch, rules),
+	})
 }
 
 // ============================================================================
@@ -1188,11 +1486,7 @@ func (h *Handler) HandleTaskTypes(w http.ResponseWriter, r *http.Request) {
 
 // HandleTaskType handles GET/PUT/DELETE /api/task-types/{id}
 func (h *Handler) HandleTaskType(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/task-types/")
-	if id == "" {
-		h.writeError(w, http.StatusBadRequest, "Task type ID required")
-		return
-	}
+	id := r.PathValue("id")
 
 	switch r.Method {
 	case http.MethodGet:
@@ -1244,11 +1538,6 @@ func (h *Handler) HandleTaskType(w http.ResponseWriter, r *http.Request) {
 
 // HandleGitHubValidate handles POST /api/github/validate
 func (h *Handler) HandleGitHubValidate(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
 	config, err := h.db.GetConfig()
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to get config")
@@ -1260,7 +1549,7 @@ func (h *Handler) HandleGitHubValidate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client := NewGitHubClient(config.GithubToken)
+	client := githubEndpointsFor(config, nil).client(config.GithubToken)
 	user, err := client.ValidateToken()
 	if err != nil {
 		h.writeError(w, http.StatusUnauthorized, "Invalid GitHub token: "+err.Error())
@@ -1277,12 +1566,7 @@ func (h *Handler) HandleGitHubValidate(w http.ResponseWriter, r *http.Request) {
 
 // HandleGitInit handles POST /api/projects/{id}/git-init
 func (h *Handler) HandleGitInit(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	projectID := extractProjectID(r.URL.Path)
+	projectID := r.PathValue("id")
 	project, err := h.db.GetProject(projectID)
 	if err != nil || project == nil {
 		h.writeError(w, http.StatusNotFound, "Project not found")
@@ -1300,23 +1584,17 @@ func (h *Handler) HandleGitInit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get updated project info
-	project.IsGitRepo = true
-	if branch, err := GetCurrentBranch(project.Path); err == nil {
-		project.CurrentBranch = branch
-	}
+	gitStatus.Invalidate(project.Path)
+	project.applyGitStatus()
 
 	h.hub.BroadcastProjectUpdate(project)
 	h.writeJSON(w, http.StatusOK, project)
 }
 
 // HandleCreateGitHubRepo handles POST /api/projects/{id}/github-repo
+// Options beyond name, description and visibility are described in githubrepo.go.
 func (h *Handler) HandleCreateGitHubRepo(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	projectID := extractProjectID(r.URL.Path)
+	projectID := r.PathValue("id")
 	project, err := h.db.GetProject(projectID)
 	if err != nil || project == nil {
 		h.writeError(w, http.StatusNotFound, "Project not found")
@@ -1328,13 +1606,17 @@ func (h *Handler) HandleCreateGitHubRepo(w http.ResponseWriter, r *http.Request)
 		h.writeError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
+	if err := req.normalize(); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	if req.RepoName == "" {
 		req.RepoName = project.Name
 	}
 
 	config, err := h.db.GetConfig()
-	if err != nil || config.GithubToken == "" {
+	if err != nil || githubTokenFor(r, config) == "" {
 		h.writeError(w, http.StatusBadRequest, "GitHub token not configured")
 		return
 	}
@@ -1348,41 +1630,64 @@ func (h *Handler) HandleCreateGitHubRepo(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Create GitHub repo
-	client := NewGitHubClient(config.GithubToken)
-	repo, err := client.CreateRepository(req.RepoName, req.Description, req.Private)
+	endpoints := githubEndpointsFor(config, project)
+	token := githubTokenFor(r, config)
+	repo, warnings, err := createGitHubRepo(endpoints.client(token), req)
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to create GitHub repo: "+err.Error())
 		return
 	}
 
+	// The first commit of a project without commits goes to the default branch
+	_, err = GetCurrentCommitHash(project.Path)
+	hasCommits := err == nil
+	branch := req.DefaultBranch
+	if req.hasInitialCommit() {
+		branch = repo.DefaultBranch
+	}
+	if branch != "" && !hasCommits {
+		if err := NameUnbornBranch(project.Path, branch); err != nil {
+			warnings = append(warnings, err.Error())
+		}
+	} else if branch != "" && !req.hasInitialCommit() {
+		if current, _ := GetCurrentBranch(project.Path); current != branch {
+			warnings = append(warnings, fmt.Sprintf("The first branch pushed becomes the default branch: push %s before %s", branch, current))
+		}
+	}
+
 	// Set remote origin
 	if err := SetRemoteOrigin(project.Path, repo.CloneURL); err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to set remote: "+err.Error())
 		return
 	}
 
-	// Update project info
-	project.IsGitRepo = true
-	if branch, err := GetCurrentBranch(project.Path); err == nil {
-		project.CurrentBranch = branch
+	// Files GitHub created have to be pulled before the project can push
+	if req.hasInitialCommit() {
+		if err := PullUnrelated(project.Path, repo.DefaultBranch, token, endpoints.WebURL, requestAuthor(r)); err != nil {
+			warnings = append(warnings, "The repository has files of its own, pull "+repo.DefaultBranch+" before pushing: "+err.Error())
+		}
 	}
+
+	// Update project info
+	gitStatus.Invalidate(project.Path)
+	project.applyGitStatus()
 	h.hub.BroadcastProjectUpdate(project)
 
-	h.writeJSON(w, http.StatusCreated, map[string]interface{}{
-		"repo_url":  repo.HTMLURL,
-		"clone_url": repo.CloneURL,
-		"ssh_url":   repo.SSHURL,
-	})
+	response := map[string]interface{}{
+		"repo_url":       repo.HTMLURL,
+		"clone_url":      repo.CloneURL,
+		"ssh_url":        repo.SSHURL,
+		"default_branch": repo.DefaultBranch,
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	h.writeJSON(w, http.StatusCreated, response)
 }
 
 // HandleDeployTask handles POST /api/tasks/{id}/deploy
 func (h *Handler) HandleDeployTask(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	taskID := extractTaskID(r.URL.Path)
+	taskID := r.PathValue("id")
 	task, err := h.db.GetTask(taskID)
 	if err != nil || task == nil {
 		h.writeError(w, http.StatusNotFound, "Task not found")
@@ -1425,6 +1730,27 @@ func (h *Handler) HandleDeployTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The deploy target is checked before anything is committed or pushed
+	project, err := h.db.GetProject(task.ProjectID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	var target *deployTarget
+	if project != nil {
+		if target, err = h.resolveDeployTarget(project, strings.TrimSpace(req.Environment)); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get environment: "+err.Error())
+			return
+		}
+		if target == nil {
+			h.writeError(w, http.StatusBadRequest, "Unknown environment: "+req.Environment)
+			return
+		}
+	} else if req.Environment != "" {
+		h.writeError(w, http.StatusBadRequest, "Environments need a project")
+		return
+	}
+
 	// Check for uncommitted changes
 	hasChanges, err := HasUncommittedChanges(projectDir)
 	if err != nil {
@@ -1432,33 +1758,103 @@ func (h *Handler) HandleDeployTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	unlock, err := lockRepoWithin(r.Context(), projectDir, "deploy")
+	if err != nil {
+		h.writeLockError(w, err)
+		return
+	}
+	defer unlock()
+	defer gitStatus.Invalidate(projectDir)
+
 	var commitHash string
 	if hasChanges {
 		// Commit changes
-		commitHash, err = CommitAllChanges(projectDir, req.CommitMessage)
+		commitHash, err = CommitAllChangesAs(projectDir, taskCommitMessage(h.db, task, req.CommitMessage), requestAuthor(r))
 		if err != nil {
 			h.writeError(w, http.StatusInternalServerError, "Failed to commit: "+err.Error())
 			return
 		}
 	}
 
-	// Push to remote
-	if err := PushToRemote(projectDir); err != nil {
-		h.writeError(w, http.StatusInternalServerError, "Failed to push: "+err.Error())
+	// Push to remote. A push rejected because the remote moved on is retried
+	// after merging it; if that conflicts, a resolution task takes over.
+	pushToken := h.pushToken(r)
+	err = pushUnlessProtectedAs(h.db, task.ProjectID, projectDir, pushToken)
+	if err != nil && isPushRejected(err) {
+		conflict, mergeErr := MergeUpstream(projectDir)
+		switch {
+		case mergeErr != nil:
+			logFrom(r.Context()).Warn("Failed to merge upstream after rejected push", "task_id", taskID, "err", mergeErr)
+		case conflict != nil:
+			if err := h.runner.openConflict(task, conflict, ConflictStagePush); err != nil {
+				h.writeError(w, http.StatusInternalServerError, "Failed to open merge conflict: "+err.Error())
+				return
+			}
+			go h.runner.TryStartNextQueued(r.Context())
+			h.writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":    "Push rejected and merging the remote changes conflicts; a task to resolve it was queued",
+				"conflict": conflict,
+			})
+			return
+		default:
+			commitHash = ""
+			err = pushUnlessProtectedAs(h.db, task.ProjectID, projectDir, pushToken)
+		}
+	}
+	if err != nil {
+		h.writeError(w, pushErrorStatus(err), "Failed to push: "+err.Error())
 		return
 	}
 
+	// The pushed commit is the one CI checks (see ci.go)
+	if commitHash == "" {
+		commitHash, _ = GetCurrentCommitHash(projectDir)
+	}
+	if commitHash != "" {
+		h.db.UpdateTaskCommitHash(taskID, commitHash)
+	}
+
+	// Every deploy of a project is recorded. With deploy commands the task is
+	// done once they succeed (see deploy.go).
+	var deployment *Deployment
+	if project != nil {
+		deployment = &Deployment{
+			ProjectID:   project.ID,
+			TaskID:      taskID,
+			Environment: target.environment,
+			CommitHash:  commitHash,
+		}
+		if err := h.startDeployment(task, project, deployment, target); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Pushed, but failed to record the deployment: "+err.Error())
+			return
+		}
+		if len(target.commands) > 0 {
+			h.writeJSON(w, http.StatusOK, DeploymentResponse{
+				Success:    true,
+				CommitHash: commitHash,
+				PushURL:    remoteURL,
+				Deployment: deployment,
+			})
+			return
+		}
+	}
+
 	// Update task status to done after successful deployment
 	h.db.UpdateTaskStatus(taskID, StatusDone)
 	updatedTask, _ := h.db.GetTask(taskID)
 	if updatedTask != nil {
 		h.hub.BroadcastTaskUpdate(updatedTask)
 	}
+	go finishTrackerIssues(h.db, h.hub, taskID)
+	jiraSync.Notify()
+	// Tasks depending on this one may start now
+	go h.runner.TryStartNextQueued(r.Context())
 
 	h.writeJSON(w, http.StatusOK, DeploymentResponse{
 		Success:    true,
 		CommitHash: commitHash,
 		PushURL:    remoteURL,
+		Deployment: deployment,
 	})
 }
 
@@ -1470,11 +1866,6 @@ func (h *Handler) HandleMergeTask(w http.ResponseWriter, r *http.Request) {
 
 // HandleScanAllProjects handles POST /api/projects/scan-all
 func (h *Handler) HandleScanAllProjects(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
 	var req ScanProjectsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.writeError(w, http.StatusBadRequest, "Invalid JSON")
@@ -1491,6 +1882,10 @@ func (h *Handler) HandleScanAllProjects(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	if _, ok := h.allowedPath(w, req.BasePath); !ok {
+		return
+	}
+
 	if req.MaxDepth == 0 {
 		req.MaxDepth = 3
 	}
@@ -1517,6 +1912,7 @@ func (h *Handler) HandleScanAllProjects(w http.ResponseWriter, r *http.Request)
 		if err != nil {
 			continue
 		}
+		detectNewProjectStack(h.db, project)
 		created = append(created, *project)
 		h.hub.BroadcastProjectUpdate(project)
 	}
@@ -1531,12 +1927,7 @@ func (h *Handler) HandleScanAllProjects(w http.ResponseWriter, r *http.Request)
 // HandleResolveConflict handles POST /api/tasks/{id}/resolve-conflict
 // This triggers RALPH to resolve a merge conflict
 func (h *Handler) HandleResolveConflict(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	taskID := extractTaskID(r.URL.Path)
+	taskID := r.PathValue("id")
 	task, err := h.db.GetTask(taskID)
 	if err != nil || task == nil {
 		h.writeError(w, http.StatusNotFound, "Task not found")
@@ -1600,7 +1991,7 @@ Original Task: %s
 	// Start RALPH to resolve
 	config, _ := h.db.GetConfig()
 	go func() {
-		h.runner.Start(task, config)
+		h.runner.Start(r.Context(), task, config)
 		// Restore original description after RALPH is done
 		h.db.UpdateTask(taskID, UpdateTaskRequest{Description: &originalDesc})
 	}()
@@ -1631,16 +2022,11 @@ type CreatePRResponse struct {
 	Message   string `json:"message,omitempty"`
 	Existing  bool   `json:"existing,omitempty"`
 	Error     string `json:"error,omitempty"`
-	ErrorType string `json:"error_type,omitempty"` // "auth", "identical", "existing", "other"
+	ErrorType string `json:"error_type,omitempty"` // "auth", "identical", "existing", "protected", "other"
 }
 
 // HandleCreatePR handles POST /api/github/create-pr
 func (h *Handler) HandleCreatePR(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
 	var req CreatePRRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.writeJSON(w, http.StatusBadRequest, CreatePRResponse{
@@ -1693,11 +2079,19 @@ func (h *Handler) HandleCreatePR(w http.ResponseWriter, r *http.Request) {
 	// Clean branch names (remove origin/ prefix if present)
 	fromBranch := strings.TrimPrefix(req.FromBranch, "origin/")
 	toBranch := strings.TrimPrefix(req.ToBranch, "origin/")
+	if !validBranchName(fromBranch) || !validBranchName(toBranch) {
+		h.writeJSON(w, http.StatusBadRequest, CreatePRResponse{
+			Success:   false,
+			Error:     "Invalid branch name",
+			ErrorType: "other",
+		})
+		return
+	}
 
 	// Check if there are commits to merge
 	commitsAhead, commitErr := GetCommitsAhead(project.Path, fromBranch, toBranch)
 	if commitErr != nil {
-		log.Printf("[CreatePR] Error checking commits ahead: %v", commitErr)
+		logFrom(r.Context()).Warn("Failed to check commits ahead", "component", "github", "err", commitErr)
 	}
 
 	// If no commits ahead, check why - uncommitted changes or truly nothing to merge
@@ -1734,30 +2128,31 @@ func (h *Handler) HandleCreatePR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse GitHub repo
-	repoFullName, err := ParseGitHubRepoFromURL(remoteURL)
-	if err != nil {
+	// Get config and check GitHub token
+	config, err := h.db.GetConfig()
+	if err != nil || config == nil || githubTokenFor(r, config) == "" {
 		h.writeJSON(w, http.StatusBadRequest, CreatePRResponse{
 			Success:   false,
-			Error:     "Could not parse GitHub repo from remote URL",
-			ErrorType: "other",
+			Error:     "GitHub token not configured. Please add your token in Settings.",
+			ErrorType: "auth",
 		})
 		return
 	}
+	endpoints := githubEndpointsFor(config, project)
 
-	// Get config and check GitHub token
-	config, err := h.db.GetConfig()
-	if err != nil || config == nil || config.GithubToken == "" {
+	// Parse GitHub repo
+	repoFullName, err := endpoints.parseRepo(remoteURL)
+	if err != nil {
 		h.writeJSON(w, http.StatusBadRequest, CreatePRResponse{
 			Success:   false,
-			Error:     "GitHub token not configured. Please add your token in Settings.",
-			ErrorType: "auth",
+			Error:     "Could not parse GitHub repo from remote URL (is it on " + endpoints.host() + "?)",
+			ErrorType: "other",
 		})
 		return
 	}
 
 	// Create GitHub client
-	ghClient := NewGitHubClient(config.GithubToken)
+	ghClient := endpoints.client(githubTokenFor(r, config))
 
 	// Get owner from repo full name for the head branch qualification
 	parts := strings.Split(repoFullName, "/")
@@ -1768,7 +2163,7 @@ func (h *Handler) HandleCreatePR(w http.ResponseWriter, r *http.Request) {
 	// For same-repo PRs, we need to check with just the branch name
 	existingPR, err := ghClient.FindExistingPR(repoFullName, owner+":"+fromBranch, toBranch)
 	if err != nil {
-		log.Printf("[CreatePR] Error checking for existing PR: %v", err)
+		logFrom(r.Context()).Warn("Failed to check for an existing pull request", "component", "github", "err", err)
 	}
 	if existingPR != nil {
 		h.writeJSON(w, http.StatusOK, CreatePRResponse{
@@ -1805,12 +2200,18 @@ func (h *Handler) HandleCreatePR(w http.ResponseWriter, r *http.Request) {
 	// Create PR body
 	body := fmt.Sprintf("## Pull Request\n\nMerging `%s` into `%s`\n\n---\n*Created via RUNNER*", fromBranch, toBranch)
 
-	// First, push the branch to ensure it exists on remote
-	log.Printf("[CreatePR] Pushing branch %s to remote...", fromBranch)
-	pushCmd := fmt.Sprintf("cd %s && git push -u origin %s 2>&1", project.Path, fromBranch)
-	pushOutput, pushErr := exec.Command("bash", "-c", pushCmd).CombinedOutput()
-	if pushErr != nil {
-		log.Printf("[CreatePR] Push warning: %v, output: %s", pushErr, string(pushOutput))
+	// First, push the branch to ensure it exists on remote, unless it is protected
+	if err := checkBranchPushAllowed(h.db, project.ID, project.Path, fromBranch); err != nil {
+		h.writeJSON(w, pushErrorStatus(err), CreatePRResponse{
+			Success:   false,
+			Error:     "Push refused: " + err.Error(),
+			ErrorType: "protected",
+		})
+		return
+	}
+	logFrom(r.Context()).Info("Pushing branch for pull request", "component", "github", "branch", fromBranch)
+	if err := PushBranchAs(project.Path, fromBranch, userGithubToken(r, config), endpoints.WebURL); err != nil {
+		logFrom(r.Context()).Warn("Push failed", "component", "github", "err", err)
 		// Don't fail here, the branch might already exist on remote
 	}
 
@@ -1851,7 +2252,7 @@ func (h *Handler) HandleCreatePR(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		log.Printf("[CreatePR] Error creating PR: %v", err)
+		logFrom(r.Context()).Error("Failed to create pull request", "component", "github", "err", err)
 		h.writeJSON(w, http.StatusInternalServerError, CreatePRResponse{
 			Success:   false,
 			Error:     "Failed to create PR: " + errStr,
@@ -1872,30 +2273,17 @@ func (h *Handler) HandleCreatePR(w http.ResponseWriter, r *http.Request) {
 // Attachment handlers
 // ============================================================================
 
-// Allowed MIME types for attachments
-var allowedMimeTypes = map[string]bool{
-	"image/png":  true,
-	"image/jpeg": true,
-	"image/gif":  true,
-	"image/webp": true,
-	"video/mp4":  true,
-	"video/webm": true,
-	"video/quicktime": true, // MOV files
-}
-
-// MaxUploadSize is the maximum file size for uploads (50MB)
+// MaxUploadSize is the maximum file size for uploads (50MB).
+// Smaller limits apply per attachment kind, see attachmentSizeLimits.
 const MaxUploadSize = 50 * 1024 * 1024
 
-// UploadsDir is the directory where attachments are stored
-const UploadsDir = "uploads"
+// UploadsDir is the directory where attachments are stored.
+// Can be overridden with FORGE_UPLOADS_DIR (see main.go).
+var UploadsDir = "uploads"
 
 // HandleTaskAttachments handles GET /api/tasks/{id}/attachments (list) and POST (upload)
 func (h *Handler) HandleTaskAttachments(w http.ResponseWriter, r *http.Request) {
-	taskID := extractTaskID(r.URL.Path)
-	if taskID == "" {
-		h.writeError(w, http.StatusBadRequest, "Task ID required")
-		return
-	}
+	taskID := r.PathValue("id")
 
 	// Verify task exists
 	task, err := h.db.GetTask(taskID)
@@ -1927,102 +2315,119 @@ func (h *Handler) getTaskAttachments(w http.ResponseWriter, r *http.Request, tas
 }
 
 func (h *Handler) uploadTaskAttachment(w http.ResponseWriter, r *http.Request, taskID string) {
-	// Limit upload size
-	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
+	attachment, ok := h.saveUploadedFile(w, r, filepath.Join(UploadsDir, taskID))
+	if !ok {
+		return
+	}
+	attachment.TaskID = taskID
+
+	// Create attachment record
+	if err := h.db.CreateAttachment(attachment); err != nil {
+		os.Remove(attachment.Path) // Cleanup on failure
+		h.writeError(w, http.StatusInternalServerError, "Failed to save attachment record")
+		return
+	}
+
+	// Broadcast task update with new attachment
+	task, _ := h.db.GetTask(taskID)
+	if task != nil {
+		task.Attachments, _ = h.db.GetAttachmentsByTask(taskID)
+		h.hub.BroadcastTaskUpdate(task)
+	}
+
+	// Preview for board cards; broadcasts the task again once ready
+	go h.generateThumbnail(*attachment)
 
-	// Parse multipart form
+	h.writeJSON(w, http.StatusCreated, attachment)
+}
+
+// saveUploadedFile validates the multipart "file" of r (content type, allowlist and
+// size limit) and stores it in dir. On failure the error response is already written.
+func (h *Handler) saveUploadedFile(w http.ResponseWriter, r *http.Request, dir string) (*Attachment, bool) {
+	// Parse multipart form (the route limits the body to MaxUploadSize)
 	if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
 		h.writeError(w, http.StatusBadRequest, "File too large or invalid form data")
-		return
+		return nil, false
 	}
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
 		h.writeError(w, http.StatusBadRequest, "No file provided")
-		return
+		return nil, false
 	}
 	defer file.Close()
 
-	// Check MIME type
-	mimeType := header.Header.Get("Content-Type")
-	if mimeType == "" {
-		// Try to detect from file content
-		mimeType = detectMimeType(file)
-		file.Seek(0, 0) // Reset file pointer
+	// Detect MIME type from the content; the client's Content-Type is not trusted
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		h.writeError(w, http.StatusBadRequest, "Failed to read file")
+		return nil, false
+	}
+	mimeType, err := sniffAttachment(head[:n], header.Filename)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "File type not allowed: "+err.Error())
+		return nil, false
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read file")
+		return nil, false
 	}
 
-	if !allowedMimeTypes[mimeType] {
-		h.writeError(w, http.StatusBadRequest, "File type not allowed. Allowed: PNG, JPG, GIF, WEBP, MP4, MOV, WEBM")
-		return
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return nil, false
+	}
+	if !attachmentTypeAllowed(config.AttachmentTypes, mimeType) {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("File type %s not allowed. Allowed: %s", mimeType, config.AttachmentTypes))
+		return nil, false
+	}
+	kind := attachmentKind(mimeType)
+	if limit := attachmentSizeLimits[kind]; header.Size > limit {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("File too large: %s attachments are limited to %d MB", kind, limit>>20))
+		return nil, false
 	}
 
-	// Create upload directory for this task
-	taskUploadDir := filepath.Join(UploadsDir, taskID)
-	if err := os.MkdirAll(taskUploadDir, 0755); err != nil {
+	// Create upload directory
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to create upload directory")
-		return
+		return nil, false
 	}
 
-	// Generate unique filename
-	ext := filepath.Ext(header.Filename)
-	if ext == "" {
-		ext = getExtensionFromMime(mimeType)
-	}
-	uniqueFilename := uuid.New().String() + ext
-	filePath := filepath.Join(taskUploadDir, uniqueFilename)
+	// Generate unique filename; the extension follows the detected type, not the upload
+	uniqueFilename := uuid.New().String() + attachmentExtension(mimeType)
+	filePath := filepath.Join(dir, uniqueFilename)
 
 	// Save file
 	dst, err := os.Create(filePath)
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to save file")
-		return
+		return nil, false
 	}
 	defer dst.Close()
 
 	if _, err := io.Copy(dst, file); err != nil {
 		os.Remove(filePath) // Cleanup on failure
 		h.writeError(w, http.StatusInternalServerError, "Failed to save file")
-		return
+		return nil, false
 	}
 
-	// Create attachment record
-	attachment := &Attachment{
+	return &Attachment{
 		ID:        uuid.New().String(),
-		TaskID:    taskID,
 		Filename:  header.Filename,
 		MimeType:  mimeType,
 		Size:      header.Size,
 		Path:      filePath,
 		CreatedAt: time.Now(),
-	}
-
-	if err := h.db.CreateAttachment(attachment); err != nil {
-		os.Remove(filePath) // Cleanup on failure
-		h.writeError(w, http.StatusInternalServerError, "Failed to save attachment record")
-		return
-	}
-
-	// Broadcast task update with new attachment
-	task, _ := h.db.GetTask(taskID)
-	if task != nil {
-		task.Attachments, _ = h.db.GetAttachmentsByTask(taskID)
-		h.hub.BroadcastTaskUpdate(task)
-	}
-
-	h.writeJSON(w, http.StatusCreated, attachment)
+	}, true
 }
 
 // HandleTaskAttachment handles GET/DELETE /api/tasks/{id}/attachments/{attachmentId}
+// and GET /api/tasks/{id}/attachments/{attachmentId}/thumbnail
 func (h *Handler) HandleTaskAttachment(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
-	parts := strings.Split(path, "/attachments/")
-	if len(parts) < 2 {
-		h.writeError(w, http.StatusBadRequest, "Attachment ID required")
-		return
-	}
-
-	taskID := extractTaskID(path)
-	attachmentID := parts[1]
+	taskID := r.PathValue("id")
+	attachmentID, action := r.PathValue("attachmentId"), r.PathValue("action")
 
 	// Verify attachment exists and belongs to the task
 	attachment, err := h.db.GetAttachment(attachmentID)
@@ -2036,9 +2441,20 @@ func (h *Handler) HandleTaskAttachment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if action == "thumbnail" {
+		h.serveThumbnail(w, r, attachment)
+		return
+	}
+	if action != "" {
+		h.writeError(w, http.StatusNotFound, "Unknown attachment action")
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
-		// Serve the file
+		// Serve the file with the detected type, never as markup
+		w.Header().Set("Content-Type", attachmentContentType(attachment.MimeType))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
 		http.ServeFile(w, r, attachment.Path)
 	case http.MethodDelete:
 		h.deleteAttachment(w, r, attachment, taskID)
@@ -2050,8 +2466,9 @@ func (h *Handler) HandleTaskAttachment(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) deleteAttachment(w http.ResponseWriter, r *http.Request, attachment *Attachment, taskID string) {
 	// Delete file from disk
 	if err := os.Remove(attachment.Path); err != nil && !os.IsNotExist(err) {
-		log.Printf("Warning: Failed to delete attachment file %s: %v", attachment.Path, err)
+		logFrom(r.Context()).Warn("Failed to delete attachment file", "path", attachment.Path, "err", err)
 	}
+	removeThumbnail(attachment)
 
 	// Delete from database
 	if err := h.db.DeleteAttachment(attachment.ID); err != nil {
@@ -2081,7 +2498,7 @@ func (h *Handler) HandleServeUpload(w http.ResponseWriter, r *http.Request) {
 	fullPath := filepath.Join(UploadsDir, filePath)
 
 	// Prevent directory traversal
-	if !strings.HasPrefix(filepath.Clean(fullPath), UploadsDir) {
+	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(UploadsDir)) {
 		h.writeError(w, http.StatusForbidden, "Access denied")
 		return
 	}
@@ -2092,42 +2509,11 @@ func (h *Handler) HandleServeUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("Content-Type", uploadContentType(fullPath))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
 	http.ServeFile(w, r, fullPath)
 }
 
-// detectMimeType attempts to detect the MIME type from file content
-func detectMimeType(file multipart.File) string {
-	buffer := make([]byte, 512)
-	_, err := file.Read(buffer)
-	if err != nil {
-		return ""
-	}
-
-	return http.DetectContentType(buffer)
-}
-
-// getExtensionFromMime returns the file extension for a MIME type
-func getExtensionFromMime(mimeType string) string {
-	switch mimeType {
-	case "image/png":
-		return ".png"
-	case "image/jpeg":
-		return ".jpg"
-	case "image/gif":
-		return ".gif"
-	case "image/webp":
-		return ".webp"
-	case "video/mp4":
-		return ".mp4"
-	case "video/webm":
-		return ".webm"
-	case "video/quicktime":
-		return ".mov"
-	default:
-		return ""
-	}
-}
-
 // DeleteTaskAttachments deletes all attachments for a task (called when task is deleted)
 func (h *Handler) DeleteTaskAttachments(taskID string) error {
 	// Get all attachments for this task
@@ -2139,8 +2525,9 @@ func (h *Handler) DeleteTaskAttachments(taskID string) error {
 	// Delete each file
 	for _, attachment := range attachments {
 		if err := os.Remove(attachment.Path); err != nil && !os.IsNotExist(err) {
-			log.Printf("Warning: Failed to delete attachment file %s: %v", attachment.Path, err)
+			slog.Warn("Failed to delete attachment file", "path", attachment.Path, "err", err)
 		}
+		removeThumbnail(&attachment)
 	}
 
 	// Delete records from database
@@ -2150,6 +2537,7 @@ func (h *Handler) DeleteTaskAttachments(taskID string) error {
 
 	// Try to remove the task's upload directory (if empty)
 	taskUploadDir := filepath.Join(UploadsDir, taskID)
+	os.Remove(filepath.Join(taskUploadDir, "thumbnails"))
 	os.Remove(taskUploadDir) // Ignore error if not empty
 
 	return nil
@@ -2162,12 +2550,7 @@ func (h *Handler) DeleteTaskAttachments(taskID string) error {
 // HandleTaskRollback handles POST /api/tasks/{id}/rollback
 // Rolls back all changes made by a task to its rollback tag.
 func (h *Handler) HandleTaskRollback(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	taskID := extractTaskID(r.URL.Path)
+	taskID := r.PathValue("id")
 	task, err := h.db.GetTask(taskID)
 	if err != nil || task == nil {
 		h.writeError(w, http.StatusNotFound, "Task not found")
@@ -2187,12 +2570,13 @@ func (h *Handler) HandleTaskRollback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Determine project directory
+	var project *Project
+	if task.ProjectID != "" {
+		project, _ = h.db.GetProject(task.ProjectID)
+	}
 	projectDir := task.ProjectDir
-	if projectDir == "" && task.ProjectID != "" {
-		project, _ := h.db.GetProject(task.ProjectID)
-		if project != nil {
-			projectDir = project.Path
-		}
+	if projectDir == "" && project != nil {
+		projectDir = project.Path
 	}
 
 	if projectDir == "" {
@@ -2207,13 +2591,23 @@ func (h *Handler) HandleTaskRollback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Rollback to tag
-	if err := RollbackToTag(projectDir, task.RollbackTag); err != nil {
+	unlock, err := lockRepoWithin(r.Context(), projectDir, "rollback")
+	if err != nil {
+		h.writeLockError(w, err)
+		return
+	}
+	defer unlock()
+	defer gitStatus.Invalidate(projectDir)
+	if err := RollbackToTag(project, task, projectDir, task.RollbackTag); err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Rollback failed: "+err.Error())
 		return
 	}
 
-	// Delete the rollback tag
+	// Delete the rollback tag and the checkpoints, which are all after it
 	DeleteTag(projectDir, task.RollbackTag)
+	if err := DeleteCheckpoints(projectDir, taskID); err != nil {
+		logFrom(r.Context()).Warn("Failed to delete checkpoints", "task_id", taskID, "err", err)
+	}
 
 	// Clear rollback tag and move task back to backlog
 	h.db.ClearTaskRollbackTag(taskID)
@@ -2237,12 +2631,7 @@ func (h *Handler) HandleTaskRollback(w http.ResponseWriter, r *http.Request) {
 // HandleProjectPushStatus handles GET /api/projects/{id}/push-status
 // Returns the number of unpushed commits for a project.
 func (h *Handler) HandleProjectPushStatus(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	projectID := extractProjectID(r.URL.Path)
+	projectID := r.PathValue("id")
 	project, err := h.db.GetProject(projectID)
 	if err != nil || project == nil {
 		h.writeError(w, http.StatusNotFound, "Project not found")
@@ -2280,12 +2669,7 @@ func (h *Handler) HandleProjectPushStatus(w http.ResponseWriter, r *http.Request
 // HandleProjectPush handles POST /api/projects/{id}/push
 // Commits any uncommitted changes and pushes to the remote.
 func (h *Handler) HandleProjectPush(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	projectID := extractProjectID(r.URL.Path)
+	projectID := r.PathValue("id")
 	project, err := h.db.GetProject(projectID)
 	if err != nil || project == nil {
 		h.writeError(w, http.StatusNotFound, "Project not found")
@@ -2302,13 +2686,21 @@ func (h *Handler) HandleProjectPush(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	unlock, err := lockRepoWithin(r.Context(), project.Path, "push")
+	if err != nil {
+		h.writeLockError(w, err)
+		return
+	}
+	defer unlock()
+	defer gitStatus.Invalidate(project.Path)
+
 	// First commit any uncommitted changes
 	committed := false
 	hasChanges, _ := HasUncommittedChanges(project.Path)
 	if hasChanges {
 		branch, _ := GetCurrentBranch(project.Path)
-		commitMsg := fmt.Sprintf("Update on %s", branch)
-		if _, err := CommitAllChanges(project.Path, commitMsg); err != nil {
+		commitMsg := commitMessage(project, nil, fmt.Sprintf("Update on %s", branch))
+		if _, err := CommitAllChangesAs(project.Path, commitMsg, requestAuthor(r)); err != nil {
 			h.writeError(w, http.StatusInternalServerError, "Commit failed: "+err.Error())
 			return
 		}
@@ -2316,8 +2708,8 @@ func (h *Handler) HandleProjectPush(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Then push
-	if err := PushToRemote(project.Path); err != nil {
-		h.writeError(w, http.StatusInternalServerError, "Push failed: "+err.Error())
+	if err := pushUnlessProtectedAs(h.db, project.ID, project.Path, h.pushToken(r)); err != nil {
+		h.writeError(w, pushErrorStatus(err), "Push failed: "+err.Error())
 		return
 	}
 
@@ -2336,12 +2728,7 @@ func (h *Handler) HandleProjectPush(w http.ResponseWriter, r *http.Request) {
 // Sets or creates the persistent working branch for a project.
 // When creating a new branch, it commits all changes and pushes to remote.
 func (h *Handler) HandleProjectSetWorkingBranch(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	projectID := extractProjectID(r.URL.Path)
+	projectID := r.PathValue("id")
 	project, err := h.db.GetProject(projectID)
 	if err != nil || project == nil {
 		h.writeError(w, http.StatusNotFound, "Project not found")
@@ -2364,6 +2751,14 @@ func (h *Handler) HandleProjectSetWorkingBranch(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	unlock, err := lockRepoWithin(r.Context(), project.Path, "branch switch")
+	if err != nil {
+		h.writeLockError(w, err)
+		return
+	}
+	defer unlock()
+	defer gitStatus.Invalidate(project.Path)
+
 	// Create new branch if requested
 	if req.Create {
 		// Create new branch from current HEAD (keeps local changes)
@@ -2375,17 +2770,17 @@ func (h *Handler) HandleProjectSetWorkingBranch(w http.ResponseWriter, r *http.R
 		// Check for uncommitted changes and commit them
 		hasChanges, _ := HasUncommittedChanges(project.Path)
 		if hasChanges {
-			commitMsg := fmt.Sprintf("Initial commit on %s", req.Branch)
-			if _, err := CommitAllChanges(project.Path, commitMsg); err != nil {
-				log.Printf("Warning: Failed to commit changes: %v", err)
+			commitMsg := commitMessage(project, nil, fmt.Sprintf("Initial commit on %s", req.Branch))
+			if _, err := CommitAllChangesAs(project.Path, commitMsg, requestAuthor(r)); err != nil {
+				logFrom(r.Context()).Warn("Failed to commit changes", "err", err)
 				// Continue anyway - branch was created
 			}
 		}
 
 		// Push new branch to remote
 		if HasRemote(project.Path) {
-			if err := PushToRemote(project.Path); err != nil {
-				log.Printf("Warning: Failed to push branch: %v", err)
+			if err := pushUnlessProtectedAs(h.db, project.ID, project.Path, h.pushToken(r)); err != nil {
+				logFrom(r.Context()).Warn("Failed to push branch", "err", err)
 				// Don't fail - branch was created locally
 			}
 		}
@@ -0,0 +1,152 @@
+// heartbeat.go notices RALPH processes that hang. Every line of output counts
+// as a heartbeat; a process that stays silent for FORGE_IDLE_TIMEOUT is
+// reported in the task log and as a task_idle WebSocket message. If
+// FORGE_IDLE_STOP is set, a process silent for that long is interrupted, then
+// stopped, and its task is blocked, so the queue moves on.
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// defaultIdleTimeout is how long a process may be silent before a warning
+const defaultIdleTimeout = 10 * time.Minute
+
+// idleInterruptWait is how long an idle process gets to exit after SIGINT
+const idleInterruptWait = 10 * time.Second
+
+// idleSettings is the stuck process detection configuration
+type idleSettings struct {
+	warn time.Duration // FORGE_IDLE_TIMEOUT, 0 disables the warning
+	stop time.Duration // FORGE_IDLE_STOP, 0 never stops a process
+}
+
+// idleConfig is the process-wide stuck process detection configuration
+var idleConfig = idleSettingsFromEnv()
+
+// idleSettingsFromEnv reads FORGE_IDLE_TIMEOUT and FORGE_IDLE_STOP (e.g. 30m)
+func idleSettingsFromEnv() idleSettings {
+	return idleSettings{
+		warn: idleDurationFromEnv("FORGE_IDLE_TIMEOUT", defaultIdleTimeout),
+		stop: idleDurationFromEnv("FORGE_IDLE_STOP", 0),
+	}
+}
+
+// idleDurationFromEnv reads a duration variable, 0 turns the check off
+func idleDurationFromEnv(name string, fallback time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("runner").Warn("Ignoring invalid "+name, "value", v)
+		return fallback
+	}
+	return d
+}
+
+// checkInterval is how often processes are checked: often enough to act
+// close to the configured times, at most every 30 seconds
+func (s idleSettings) checkInterval() time.Duration {
+	shortest := s.warn
+	if shortest == 0 || (s.stop > 0 && s.stop < shortest) {
+		shortest = s.stop
+	}
+	return min(max(shortest/4, time.Second), 30*time.Second)
+}
+
+// recordOutput notes that a task's process wrote a line
+func (r *RalphRunner) recordOutput(taskID string) {
+	r.mu.RLock()
+	proc, exists := r.processes[taskID]
+	r.mu.RUnlock()
+	if !exists {
+		return
+	}
+	proc.mu.Lock()
+	proc.lastOutput = time.Now()
+	proc.idleWarned = false
+	proc.mu.Unlock()
+}
+
+// watchIdle checks a started process for silence until it exits
+func (r *RalphRunner) watchIdle(proc *RalphProcess) {
+	s := idleConfig
+	if s.warn == 0 && s.stop == 0 {
+		return
+	}
+	proc.mu.Lock()
+	proc.lastOutput = time.Now()
+	proc.mu.Unlock()
+
+	ticker := time.NewTicker(s.checkInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-proc.done:
+			return
+		case <-ticker.C:
+		}
+
+		proc.mu.Lock()
+		if proc.paused {
+			// A paused process cannot write; the pause does not count as silence
+			proc.lastOutput = time.Now()
+		}
+		idle := time.Since(proc.lastOutput)
+		warn := s.warn > 0 && idle >= s.warn && !proc.idleWarned
+		if warn {
+			proc.idleWarned = true
+		}
+		proc.mu.Unlock()
+
+		if s.stop > 0 && idle >= s.stop {
+			r.stopIdle(proc, idle)
+			return
+		}
+		if warn {
+			message := fmt.Sprintf("No output for %s, RALPH may be stuck", formatIdle(idle))
+			proc.log.Warn("Process is idle", "idle", idle.Round(time.Second))
+			r.hub.BroadcastLog(proc.TaskID, "\n[FORGE] "+message+"\n")
+			r.hub.BroadcastTaskIdle(proc.TaskID, message)
+		}
+	}
+}
+
+// stopIdle blocks the task of a silent process, interrupts the process and
+// stops it if it does not exit
+func (r *RalphRunner) stopIdle(proc *RalphProcess, idle time.Duration) {
+	message := fmt.Sprintf("No output for %s", formatIdle(idle))
+	proc.log.Warn("Stopping idle process", "idle", idle.Round(time.Second))
+	r.handleBlocked(proc.TaskID, message)
+	r.hub.BroadcastTaskIdle(proc.TaskID, message+", task stopped")
+
+	proc.mu.Lock()
+	cmd := proc.cmd
+	proc.mu.Unlock()
+	if cmd != nil && cmd.Process != nil {
+		// SIGINT lets Claude end its session cleanly; the group includes its tools
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+		select {
+		case <-proc.done:
+			return
+		case <-time.After(idleInterruptWait):
+		}
+	}
+	r.Stop(proc.TaskID)
+}
+
+// formatIdle formats an idle duration as minutes, or seconds below a minute
+func formatIdle(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%d seconds", int(d.Seconds()))
+	}
+	if minutes := int(d.Minutes()); minutes != 1 {
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	return "1 minute"
+}
@@ -0,0 +1,177 @@
+// issues.go syncs GitHub issues with FORGE tasks. POST
+// /api/projects/{id}/import-issues turns the open issues of the project's
+// GitHub repository into backlog tasks, keeping body and labels. Projects with
+// issue_sync enabled comment on and close the issue once its task is done.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// projectGitHubRepo returns owner/repo of the project's GitHub remote
+func projectGitHubRepo(config *Config, project *Project) (string, error) {
+	remoteURL, err := GetRemoteURL(project.Path)
+	if err != nil || remoteURL == "" {
+		return "", fmt.Errorf("no remote origin configured")
+	}
+	return githubEndpointsFor(config, project).parseRepo(remoteURL)
+}
+
+// HandleImportIssues handles POST /api/projects/{id}/import-issues
+// Issues that already have a task in the project are skipped, so the import
+// can be repeated to pick up new issues.
+func (h *Handler) HandleImportIssues(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+	repo, err := projectGitHubRepo(config, project)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Project is not on GitHub: "+err.Error())
+		return
+	}
+	if config.GithubToken == "" {
+		h.writeError(w, http.StatusBadRequest, "GitHub token not configured")
+		return
+	}
+
+	issues, err := githubEndpointsFor(config, project).client(config.GithubToken).ListOpenIssues(repo)
+	if err != nil {
+		h.writeError(w, http.StatusBadGateway, "Failed to list issues: "+err.Error())
+		return
+	}
+
+	tasks, err := h.db.GetTasksByProject(project.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get tasks: "+err.Error())
+		return
+	}
+	imported := make(map[int]bool)
+	for _, t := range tasks {
+		if t.IssueNumber > 0 {
+			imported[t.IssueNumber] = true
+		}
+	}
+
+	labels, err := newLabelIndex(h.db)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get labels: "+err.Error())
+		return
+	}
+
+	result := IssueImportResult{Imported: []Task{}}
+	for _, issue := range issues {
+		if imported[issue.Number] {
+			result.Skipped++
+			continue
+		}
+
+		req := CreateTaskRequest{
+			Title:       issue.Title,
+			Description: issue.Body,
+			ProjectID:   project.ID,
+		}
+		for _, gl := range issue.Labels {
+			name := strings.TrimSpace(gl.Name)
+			if name == "" {
+				continue
+			}
+			color := ""
+			if gl.Color != "" {
+				color = "#" + gl.Color
+			}
+			id, err := labels.id(name, color)
+			if err != nil {
+				h.writeError(w, http.StatusInternalServerError, "Failed to create label: "+err.Error())
+				return
+			}
+			req.LabelIDs = append(req.LabelIDs, id)
+		}
+
+		task, err := h.db.CreateTask(req, config)
+		if err != nil {
+			h.writeLabelError(w, "create", err)
+			return
+		}
+		if err := h.db.UpdateTaskIssue(task.ID, issue.HTMLURL, issue.Number); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to link issue: "+err.Error())
+			return
+		}
+		task.IssueURL, task.IssueNumber = issue.HTMLURL, issue.Number
+
+		h.hub.BroadcastTaskUpdate(task)
+		result.Imported = append(result.Imported, *task)
+	}
+
+	if labels.created {
+		h.broadcastLabels()
+	}
+	logFrom(r.Context()).Info("Issues imported", "component", "issues", "repo", repo, "imported", len(result.Imported), "skipped", result.Skipped)
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// finishTrackerIssues closes the GitHub issue and completes the Linear issue
+// a done task was imported from. Jira issues follow through jiraSync.
+func finishTrackerIssues(db Store, hub *Hub, taskID string) {
+	closeTaskIssue(db, hub, taskID)
+	completeLinearIssue(db, hub, taskID)
+}
+
+// closeTaskIssue comments on and closes the GitHub issue of a task that is
+// done, if its project has issue sync enabled. Failures only end up in the
+// task log.
+func closeTaskIssue(db Store, hub *Hub, taskID string) {
+	task, _ := db.GetTask(taskID)
+	if task == nil || task.Status != StatusDone || task.IssueNumber == 0 || task.ProjectID == "" {
+		return
+	}
+	project, _ := db.GetProject(task.ProjectID)
+	if project == nil || !project.IssueSync {
+		return
+	}
+
+	logf := func(format string, args ...interface{}) {
+		msg := "[FORGE] " + fmt.Sprintf(format, args...) + "\n"
+		db.AppendTaskLogs(taskID, msg)
+		hub.BroadcastLog(taskID, msg)
+	}
+
+	config, err := db.GetConfig()
+	if err != nil || config.GithubToken == "" {
+		logf("Could not close issue #%d: GitHub token not configured", task.IssueNumber)
+		return
+	}
+	repo, err := projectGitHubRepo(config, project)
+	if err != nil {
+		logf("Could not close issue #%d: %v", task.IssueNumber, err)
+		return
+	}
+	client := githubEndpointsFor(config, project).client(config.GithubToken)
+
+	comment := fmt.Sprintf("Done in FORGE (task %s).", task.ID)
+	if task.PRURL != "" {
+		comment += "\n\nPull request: " + task.PRURL
+	}
+	if err := client.CommentOnIssue(repo, task.IssueNumber, comment); err != nil {
+		logf("Could not comment on issue #%d: %v", task.IssueNumber, err)
+		return
+	}
+	if err := client.CloseIssue(repo, task.IssueNumber); err != nil {
+		logf("Could not close issue #%d: %v", task.IssueNumber, err)
+		return
+	}
+	logf("Closed issue #%d", task.IssueNumber)
+}
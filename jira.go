@@ -0,0 +1,495 @@
+// jira.go connects FORGE to a Jira server (jira_url, jira_user and jira_token
+// in the config). POST /api/projects/{id}/import-jira turns the issues of a
+// JQL filter into backlog tasks, mapping Jira issue types to task types. A
+// sync loop then transitions each imported issue whenever its task changes
+// status: backlog and queue columns map to "To Do", progress and review to
+// "In Progress", done to "Done".
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultJiraSyncInterval is how often task status changes are pushed to Jira
+const defaultJiraSyncInterval = time.Minute
+
+// jiraPageSize is the number of issues fetched per search request
+const jiraPageSize = 50
+
+// Jira status categories (statusCategory.key)
+const (
+	jiraCategoryToDo       = "new"
+	jiraCategoryInProgress = "indeterminate"
+	jiraCategoryDone       = "done"
+)
+
+// jiraIssueTypeAliases maps Jira issue types to the built-in task types that
+// do not share their name; other types are matched by name
+var jiraIssueTypeAliases = map[string]string{
+	"story":          "feature",
+	"new feature":    "feature",
+	"epic":           "feature",
+	"improvement":    "refactor",
+	"technical debt": "refactor",
+}
+
+// JiraClient talks to the Jira REST API (v2, available on Server and Cloud)
+type JiraClient struct {
+	baseURL string
+	user    string
+	token   string
+}
+
+// NewJiraClient creates a client from the Jira settings in config. Without a
+// user the token is sent as a bearer token (Jira Server/Data Center personal
+// access tokens); with a user it is an API token for basic auth (Jira Cloud).
+func NewJiraClient(config *Config) *JiraClient {
+	return &JiraClient{
+		baseURL: strings.TrimRight(config.JiraURL, "/"),
+		user:    config.JiraUser,
+		token:   config.JiraToken,
+	}
+}
+
+// jiraConfigured reports whether the Jira connector has a server and a token
+func jiraConfigured(config *Config) bool {
+	return config != nil && config.JiraURL != "" && config.JiraToken != ""
+}
+
+// JiraStatus is the workflow status of an issue or the target of a transition
+type JiraStatus struct {
+	Name           string `json:"name"`
+	StatusCategory struct {
+		Key string `json:"key"` // new, indeterminate or done
+	} `json:"statusCategory"`
+}
+
+// JiraIssue is an issue as returned by the search API
+type JiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string     `json:"summary"`
+		Description string     `json:"description"`
+		Labels      []string   `json:"labels"`
+		Status      JiraStatus `json:"status"`
+		IssueType   struct {
+			Name string `json:"name"`
+		} `json:"issuetype"`
+	} `json:"fields"`
+}
+
+// JiraTransition is a workflow transition available for an issue
+type JiraTransition struct {
+	ID   string     `json:"id"`
+	Name string     `json:"name"`
+	To   JiraStatus `json:"to"`
+}
+
+// do sends a request to the Jira API and decodes the JSON response into out (if not nil)
+func (c *JiraClient) do(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("Jira API error: %d - %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// SearchIssues returns all issues matching jql
+func (c *JiraClient) SearchIssues(jql string) ([]JiraIssue, error) {
+	var issues []JiraIssue
+	for {
+		query := url.Values{}
+		query.Set("jql", jql)
+		query.Set("startAt", fmt.Sprint(len(issues)))
+		query.Set("maxResults", fmt.Sprint(jiraPageSize))
+		query.Set("fields", "summary,description,labels,status,issuetype")
+
+		var page struct {
+			Total  int         `json:"total"`
+			Issues []JiraIssue `json:"issues"`
+		}
+		if err := c.do("GET", "/rest/api/2/search?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		issues = append(issues, page.Issues...)
+		if len(page.Issues) == 0 || len(issues) >= page.Total {
+			return issues, nil
+		}
+	}
+}
+
+// GetIssueStatus returns the current status of an issue
+func (c *JiraClient) GetIssueStatus(key string) (*JiraStatus, error) {
+	var issue JiraIssue
+	if err := c.do("GET", "/rest/api/2/issue/"+url.PathEscape(key)+"?fields=status", nil, &issue); err != nil {
+		return nil, err
+	}
+	return &issue.Fields.Status, nil
+}
+
+// GetTransitions returns the transitions currently available for an issue
+func (c *JiraClient) GetTransitions(key string) ([]JiraTransition, error) {
+	var resp struct {
+		Transitions []JiraTransition `json:"transitions"`
+	}
+	if err := c.do("GET", "/rest/api/2/issue/"+url.PathEscape(key)+"/transitions", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Transitions, nil
+}
+
+// Transition moves an issue through the transition with the given ID
+func (c *JiraClient) Transition(key, transitionID string) error {
+	body := map[string]interface{}{"transition": map[string]string{"id": transitionID}}
+	return c.do("POST", "/rest/api/2/issue/"+url.PathEscape(key)+"/transitions", body, nil)
+}
+
+// jiraTarget returns the Jira status category a task status maps to and a
+// hint for the preferred status name; "" leaves the issue alone (e.g. blocked)
+func jiraTarget(db Store, status TaskStatus) (category, hint string) {
+	switch status {
+	case StatusBacklog, StatusQueued:
+		return jiraCategoryToDo, ""
+	case StatusProgress:
+		return jiraCategoryInProgress, "progress"
+	case StatusReview:
+		return jiraCategoryInProgress, "review"
+	case StatusDone:
+		return jiraCategoryDone, ""
+	case StatusBlocked:
+		return "", ""
+	}
+
+	// Custom columns follow their role
+	column, _ := db.GetBoardColumn(status)
+	if column == nil {
+		return "", ""
+	}
+	switch column.Role {
+	case ColumnRoleQueue:
+		return jiraCategoryToDo, ""
+	case ColumnRoleProgress:
+		return jiraCategoryInProgress, "progress"
+	case ColumnRoleTerminal:
+		return jiraCategoryDone, ""
+	}
+	return "", ""
+}
+
+// transitionJiraIssue moves an issue into a status of category, preferring
+// one whose name contains hint. Returns the new status name, or "" if the
+// issue already was in a matching status.
+func transitionJiraIssue(client *JiraClient, key, category, hint string) (string, error) {
+	current, err := client.GetIssueStatus(key)
+	if err != nil {
+		return "", err
+	}
+	matches := func(s JiraStatus) bool {
+		return hint == "" || strings.Contains(strings.ToLower(s.Name), hint)
+	}
+	if current.StatusCategory.Key == category && matches(*current) {
+		return "", nil
+	}
+
+	transitions, err := client.GetTransitions(key)
+	if err != nil {
+		return "", err
+	}
+	var best *JiraTransition
+	for i := range transitions {
+		t := &transitions[i]
+		if t.To.StatusCategory.Key != category {
+			continue
+		}
+		if best == nil || (matches(t.To) && !matches(best.To)) {
+			best = t
+		}
+	}
+	if best == nil {
+		if current.StatusCategory.Key == category {
+			return "", nil
+		}
+		return "", fmt.Errorf("no transition from %q into a %s status", current.Name, category)
+	}
+	if current.StatusCategory.Key == category && !matches(best.To) {
+		return "", nil
+	}
+
+	if err := client.Transition(key, best.ID); err != nil {
+		return "", err
+	}
+	return best.To.Name, nil
+}
+
+// jiraSync is the process-wide Jira status sync
+var jiraSync = newJiraSyncer(jiraSyncIntervalFromEnv())
+
+// jiraSyncer pushes task status changes to the imported Jira issues
+type jiraSyncer struct {
+	mu       sync.Mutex
+	interval time.Duration         // 0 disables the periodic sync
+	kick     chan struct{}         // Notify requests an immediate sync
+	failed   map[string]TaskStatus // Task ID -> status whose sync failed (logged once)
+}
+
+func newJiraSyncer(interval time.Duration) *jiraSyncer {
+	return &jiraSyncer{
+		interval: interval,
+		kick:     make(chan struct{}, 1),
+		failed:   make(map[string]TaskStatus),
+	}
+}
+
+// jiraSyncIntervalFromEnv reads FORGE_JIRA_SYNC_INTERVAL (e.g. 5m; 0 only syncs moves made on the board)
+func jiraSyncIntervalFromEnv() time.Duration {
+	v := os.Getenv("FORGE_JIRA_SYNC_INTERVAL")
+	if v == "" {
+		return defaultJiraSyncInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("jira").Warn("Ignoring invalid FORGE_JIRA_SYNC_INTERVAL", "value", v)
+		return defaultJiraSyncInterval
+	}
+	return d
+}
+
+// Notify asks the sync loop to run right away, e.g. after a task was moved
+func (s *jiraSyncer) Notify() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Run syncs every interval and on Notify until stop is closed
+func (s *jiraSyncer) Run(db Store, stop <-chan struct{}) {
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-s.kick:
+		case <-stop:
+			return
+		}
+		s.SyncAll(db)
+	}
+}
+
+// SyncAll transitions the Jira issue of every task whose status changed since its last sync
+func (s *jiraSyncer) SyncAll(db Store) {
+	config, err := db.GetConfig()
+	if err != nil || !jiraConfigured(config) {
+		return
+	}
+	tasks, err := db.GetAllTasks()
+	if err != nil {
+		componentLog("jira").Error("Failed to list tasks", "err", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client := NewJiraClient(config)
+	for i := range tasks {
+		task := &tasks[i]
+		if task.JiraKey == "" || task.Status == task.JiraSyncedStatus {
+			continue
+		}
+
+		if category, hint := jiraTarget(db, task.Status); category != "" {
+			name, err := transitionJiraIssue(client, task.JiraKey, category, hint)
+			if err != nil {
+				if s.failed[task.ID] != task.Status {
+					componentLog("jira").Warn("Failed to sync status", "issue", task.JiraKey, "status", task.Status, "err", err)
+					s.failed[task.ID] = task.Status
+				}
+				continue
+			}
+			if name != "" {
+				componentLog("jira").Info("Issue moved", "issue", task.JiraKey, "status", name)
+			}
+		}
+		delete(s.failed, task.ID)
+		if err := db.UpdateTaskJira(task.ID, task.JiraKey, task.Status); err != nil {
+			componentLog("jira").Warn("Failed to sync issue", "issue", task.JiraKey, "err", err)
+		}
+	}
+}
+
+// HandleImportJira handles POST /api/projects/{id}/import-jira
+// Without a JQL filter the open issues of the project's Jira project are
+// imported. Issues that already have a task are skipped.
+func (h *Handler) HandleImportJira(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	var req JiraImportRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+	}
+	jql := strings.TrimSpace(req.JQL)
+	if jql == "" {
+		if project.JiraProjectKey == "" {
+			h.writeError(w, http.StatusBadRequest, "JQL filter or Jira project key required")
+			return
+		}
+		jql = fmt.Sprintf(`project = "%s" AND statusCategory != Done ORDER BY created ASC`, project.JiraProjectKey)
+	}
+
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+	if !jiraConfigured(config) {
+		h.writeError(w, http.StatusBadRequest, "Jira is not configured")
+		return
+	}
+
+	issues, err := NewJiraClient(config).SearchIssues(jql)
+	if err != nil {
+		h.writeError(w, http.StatusBadGateway, "Failed to search Jira: "+err.Error())
+		return
+	}
+
+	// Jira keys are unique across projects
+	tasks, err := h.db.GetAllTasks()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get tasks: "+err.Error())
+		return
+	}
+	imported := make(map[string]bool)
+	for _, t := range tasks {
+		if t.JiraKey != "" {
+			imported[t.JiraKey] = true
+		}
+	}
+
+	taskTypes, err := h.db.GetAllTaskTypes()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task types: "+err.Error())
+		return
+	}
+	typeIDs := make(map[string]string) // Lowercase name -> task type ID
+	for _, tt := range taskTypes {
+		typeIDs[strings.ToLower(tt.Name)] = tt.ID
+	}
+
+	labels, err := newLabelIndex(h.db)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get labels: "+err.Error())
+		return
+	}
+
+	result := IssueImportResult{Imported: []Task{}}
+	for _, issue := range issues {
+		if imported[issue.Key] {
+			result.Skipped++
+			continue
+		}
+
+		req := CreateTaskRequest{
+			Title:       issue.Key + ": " + issue.Fields.Summary,
+			Description: issue.Fields.Description,
+			ProjectID:   project.ID,
+		}
+		issueType := strings.ToLower(issue.Fields.IssueType.Name)
+		if id, ok := typeIDs[issueType]; ok {
+			req.TaskTypeID = id
+		} else if id, ok := typeIDs[jiraIssueTypeAliases[issueType]]; ok {
+			req.TaskTypeID = id
+		}
+		for _, name := range issue.Fields.Labels {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			id, err := labels.id(name, "")
+			if err != nil {
+				h.writeError(w, http.StatusInternalServerError, "Failed to create label: "+err.Error())
+				return
+			}
+			req.LabelIDs = append(req.LabelIDs, id)
+		}
+
+		task, err := h.db.CreateTask(req, config)
+		if err != nil {
+			h.writeLabelError(w, "create", err)
+			return
+		}
+		// The issue keeps its Jira status until the task is moved
+		if err := h.db.UpdateTaskJira(task.ID, issue.Key, task.Status); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to link Jira issue: "+err.Error())
+			return
+		}
+		task.JiraKey, task.JiraSyncedStatus = issue.Key, task.Status
+		imported[issue.Key] = true
+
+		h.hub.BroadcastTaskUpdate(task)
+		result.Imported = append(result.Imported, *task)
+	}
+
+	if labels.created {
+		h.broadcastLabels()
+	}
+	logFrom(r.Context()).Info("Issues imported", "component", "jira", "project", project.Name, "imported", len(result.Imported), "skipped", result.Skipped)
+	h.writeJSON(w, http.StatusOK, result)
+}
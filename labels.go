@@ -0,0 +1,237 @@
+// labels.go implements free-form task labels.
+// Unlike the single task type a task can carry any number of labels; the
+// assignment is stored in task_labels and returned as Task.Labels.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// errLabelExists is returned when a label name is already taken
+	errLabelExists = errors.New("a label with this name already exists")
+
+	// errLabelNotFound is returned when a task references an unknown label ID
+	errLabelNotFound = errors.New("unknown label")
+)
+
+// taskHasLabel reports whether task carries a label whose ID or name (case-insensitive) is ref
+func taskHasLabel(task *Task, ref string) bool {
+	for _, l := range task.Labels {
+		if l.ID == ref || strings.EqualFold(l.Name, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTasksByLabels keeps the tasks that carry every label in refs
+func filterTasksByLabels(tasks []Task, refs []string) []Task {
+	filtered := []Task{}
+	for i := range tasks {
+		match := true
+		for _, ref := range refs {
+			if !taskHasLabel(&tasks[i], ref) {
+				match = false
+				break
+			}
+		}
+		if match {
+			filtered = append(filtered, tasks[i])
+		}
+	}
+	return filtered
+}
+
+// writeLabelError answers a task create/update that failed: 400 for unknown labels, 500 otherwise
+func (h *Handler) writeLabelError(w http.ResponseWriter, action string, err error) {
+	if errors.Is(err, errLabelNotFound) {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.writeError(w, http.StatusInternalServerError, "Failed to "+action+" task: "+err.Error())
+}
+
+// checkLabelIDs returns errLabelNotFound if one of ids is not an existing label
+func (h *Handler) checkLabelIDs(ids []string) error {
+	for _, id := range ids {
+		label, err := h.db.GetLabel(id)
+		if err != nil {
+			return err
+		}
+		if label == nil {
+			return fmt.Errorf("%w: %s", errLabelNotFound, id)
+		}
+	}
+	return nil
+}
+
+// labelIndex finds labels by name (case-insensitive) during an import and
+// creates the ones that do not exist yet
+type labelIndex struct {
+	db      Store
+	ids     map[string]string // Lowercase name -> label ID
+	created bool              // At least one label was created
+}
+
+func newLabelIndex(db Store) (*labelIndex, error) {
+	labels, err := db.GetAllLabels()
+	if err != nil {
+		return nil, err
+	}
+	idx := &labelIndex{db: db, ids: make(map[string]string)}
+	for _, l := range labels {
+		idx.ids[strings.ToLower(l.Name)] = l.ID
+	}
+	return idx, nil
+}
+
+// id returns the ID of the label called name, creating it with color if needed
+func (idx *labelIndex) id(name, color string) (string, error) {
+	key := strings.ToLower(name)
+	if id, ok := idx.ids[key]; ok {
+		return id, nil
+	}
+	if color == "" {
+		color = "#808080" // Default gray
+	}
+	label, err := idx.db.CreateLabel(CreateLabelRequest{Name: name, Color: color})
+	if err != nil {
+		return "", err
+	}
+	idx.ids[key] = label.ID
+	idx.created = true
+	return label.ID, nil
+}
+
+// broadcastLabels sends the current label list to all clients
+func (h *Handler) broadcastLabels() {
+	labels, err := h.db.GetAllLabels()
+	if err == nil {
+		if labels == nil {
+			labels = []Label{}
+		}
+		h.hub.BroadcastLabelsUpdate(labels)
+	}
+}
+
+// HandleLabels handles GET /api/labels and POST /api/labels
+func (h *Handler) HandleLabels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		labels, err := h.db.GetAllLabels()
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get labels: "+err.Error())
+			return
+		}
+		if labels == nil {
+			labels = []Label{}
+		}
+		h.writeJSON(w, http.StatusOK, labels)
+
+	case http.MethodPost:
+		var req CreateLabelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			h.writeError(w, http.StatusBadRequest, "Name is required")
+			return
+		}
+		if req.Color == "" {
+			req.Color = "#808080" // Default gray
+		}
+
+		label, err := h.db.CreateLabel(req)
+		if errors.Is(err, errLabelExists) {
+			h.writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to create label: "+err.Error())
+			return
+		}
+
+		h.broadcastLabels()
+		h.writeJSON(w, http.StatusCreated, label)
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleLabel handles GET/PUT/DELETE /api/labels/{id}
+func (h *Handler) HandleLabel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		label, err := h.db.GetLabel(id)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get label: "+err.Error())
+			return
+		}
+		if label == nil {
+			h.writeError(w, http.StatusNotFound, "Label not found")
+			return
+		}
+		h.writeJSON(w, http.StatusOK, label)
+
+	case http.MethodPut:
+		var req UpdateLabelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				h.writeError(w, http.StatusBadRequest, "Name must not be empty")
+				return
+			}
+			req.Name = &name
+		}
+
+		label, err := h.db.UpdateLabel(id, req)
+		if errors.Is(err, errLabelExists) {
+			h.writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to update label: "+err.Error())
+			return
+		}
+		if label == nil {
+			h.writeError(w, http.StatusNotFound, "Label not found")
+			return
+		}
+
+		h.broadcastLabels()
+		h.writeJSON(w, http.StatusOK, label)
+
+	case http.MethodDelete:
+		err := h.db.DeleteLabel(id)
+		if err == sql.ErrNoRows {
+			h.writeError(w, http.StatusNotFound, "Label not found")
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to delete label: "+err.Error())
+			return
+		}
+
+		h.broadcastLabels()
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
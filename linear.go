@@ -0,0 +1,410 @@
+// linear.go connects FORGE to Linear through its GraphQL API (linear_token in
+// the config). GET /api/linear/teams lists the teams and their projects, POST
+// /api/projects/{id}/import-linear pulls the open issues of a team or project
+// as backlog tasks. When an imported task is done, its issue is moved to the
+// team's completed state and gets a comment with RALPH's summary and the
+// commit hash.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// linearAPIURL is the Linear GraphQL endpoint
+var linearAPIURL = "https://api.linear.app/graphql"
+
+// linearSummaryMaxLen caps RALPH's summary in the completion comment
+const linearSummaryMaxLen = 4000
+
+// LinearClient talks to the Linear GraphQL API
+type LinearClient struct {
+	token string
+}
+
+// NewLinearClient creates a client with a personal API key
+func NewLinearClient(token string) *LinearClient {
+	return &LinearClient{token: token}
+}
+
+// LinearProject is a Linear project
+type LinearProject struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// LinearTeam is a Linear team with its projects
+type LinearTeam struct {
+	ID       string          `json:"id"`
+	Key      string          `json:"key"`
+	Name     string          `json:"name"`
+	Projects []LinearProject `json:"projects"`
+}
+
+// LinearIssue is an issue as returned by the issues query
+type LinearIssue struct {
+	ID          string `json:"id"`
+	Identifier  string `json:"identifier"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	Labels      struct {
+		Nodes []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"nodes"`
+	} `json:"labels"`
+}
+
+// query runs a GraphQL query and decodes its data into out
+func (c *LinearClient) query(query string, variables map[string]interface{}, out interface{}) error {
+	jsonBody, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", linearAPIURL, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("Linear API error: %d - %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("Linear API error: %s", result.Errors[0].Message)
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+// ListTeams returns all teams the API key can see, with their projects
+func (c *LinearClient) ListTeams() ([]LinearTeam, error) {
+	var data struct {
+		Teams struct {
+			Nodes []struct {
+				ID       string `json:"id"`
+				Key      string `json:"key"`
+				Name     string `json:"name"`
+				Projects struct {
+					Nodes []LinearProject `json:"nodes"`
+				} `json:"projects"`
+			} `json:"nodes"`
+		} `json:"teams"`
+	}
+	err := c.query(`query {
+		teams(first: 100) {
+			nodes { id key name projects(first: 100) { nodes { id name } } }
+		}
+	}`, nil, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	teams := []LinearTeam{}
+	for _, t := range data.Teams.Nodes {
+		team := LinearTeam{ID: t.ID, Key: t.Key, Name: t.Name, Projects: t.Projects.Nodes}
+		if team.Projects == nil {
+			team.Projects = []LinearProject{}
+		}
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
+
+// ListOpenIssues returns the issues of a team and/or project that are neither completed nor canceled
+func (c *LinearClient) ListOpenIssues(teamID, projectID string) ([]LinearIssue, error) {
+	filter := map[string]interface{}{
+		"state": map[string]interface{}{"type": map[string]interface{}{"nin": []string{"completed", "canceled"}}},
+	}
+	if teamID != "" {
+		filter["team"] = map[string]interface{}{"id": map[string]string{"eq": teamID}}
+	}
+	if projectID != "" {
+		filter["project"] = map[string]interface{}{"id": map[string]string{"eq": projectID}}
+	}
+
+	var issues []LinearIssue
+	var after *string
+	for {
+		var data struct {
+			Issues struct {
+				Nodes    []LinearIssue `json:"nodes"`
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+			} `json:"issues"`
+		}
+		err := c.query(`query($filter: IssueFilter, $after: String) {
+			issues(filter: $filter, first: 50, after: $after) {
+				nodes { id identifier title description url labels { nodes { name color } } }
+				pageInfo { hasNextPage endCursor }
+			}
+		}`, map[string]interface{}{"filter": filter, "after": after}, &data)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, data.Issues.Nodes...)
+		if !data.Issues.PageInfo.HasNextPage {
+			return issues, nil
+		}
+		cursor := data.Issues.PageInfo.EndCursor
+		after = &cursor
+	}
+}
+
+// CompleteIssue moves an issue to the first completed state of its team
+func (c *LinearClient) CompleteIssue(issueID string) error {
+	var data struct {
+		Issue struct {
+			Team struct {
+				States struct {
+					Nodes []struct {
+						ID       string  `json:"id"`
+						Type     string  `json:"type"`
+						Position float64 `json:"position"`
+					} `json:"nodes"`
+				} `json:"states"`
+			} `json:"team"`
+		} `json:"issue"`
+	}
+	err := c.query(`query($id: String!) {
+		issue(id: $id) { team { states { nodes { id type position } } } }
+	}`, map[string]interface{}{"id": issueID}, &data)
+	if err != nil {
+		return err
+	}
+
+	stateID := ""
+	position := 0.0
+	for _, s := range data.Issue.Team.States.Nodes {
+		if s.Type == "completed" && (stateID == "" || s.Position < position) {
+			stateID, position = s.ID, s.Position
+		}
+	}
+	if stateID == "" {
+		return fmt.Errorf("team has no completed state")
+	}
+
+	var result struct {
+		IssueUpdate struct {
+			Success bool `json:"success"`
+		} `json:"issueUpdate"`
+	}
+	err = c.query(`mutation($id: String!, $stateId: String!) {
+		issueUpdate(id: $id, input: { stateId: $stateId }) { success }
+	}`, map[string]interface{}{"id": issueID, "stateId": stateID}, &result)
+	if err == nil && !result.IssueUpdate.Success {
+		err = fmt.Errorf("issue update failed")
+	}
+	return err
+}
+
+// CreateComment adds a markdown comment to an issue
+func (c *LinearClient) CreateComment(issueID, body string) error {
+	var result struct {
+		CommentCreate struct {
+			Success bool `json:"success"`
+		} `json:"commentCreate"`
+	}
+	err := c.query(`mutation($issueId: String!, $body: String!) {
+		commentCreate(input: { issueId: $issueId, body: $body }) { success }
+	}`, map[string]interface{}{"issueId": issueID, "body": body}, &result)
+	if err == nil && !result.CommentCreate.Success {
+		err = fmt.Errorf("comment creation failed")
+	}
+	return err
+}
+
+// HandleLinearTeams handles GET /api/linear/teams
+func (h *Handler) HandleLinearTeams(w http.ResponseWriter, r *http.Request) {
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+	if config.LinearToken == "" {
+		h.writeError(w, http.StatusBadRequest, "Linear API key not configured")
+		return
+	}
+
+	teams, err := NewLinearClient(config.LinearToken).ListTeams()
+	if err != nil {
+		h.writeError(w, http.StatusBadGateway, "Failed to list Linear teams: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, teams)
+}
+
+// HandleImportLinear handles POST /api/projects/{id}/import-linear
+// Issues that already have a task are skipped, so the import can be repeated.
+func (h *Handler) HandleImportLinear(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	var req LinearImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	if req.TeamID == "" && req.ProjectID == "" {
+		h.writeError(w, http.StatusBadRequest, "team_id or project_id is required")
+		return
+	}
+
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+	if config.LinearToken == "" {
+		h.writeError(w, http.StatusBadRequest, "Linear API key not configured")
+		return
+	}
+
+	issues, err := NewLinearClient(config.LinearToken).ListOpenIssues(req.TeamID, req.ProjectID)
+	if err != nil {
+		h.writeError(w, http.StatusBadGateway, "Failed to list Linear issues: "+err.Error())
+		return
+	}
+
+	tasks, err := h.db.GetAllTasks()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get tasks: "+err.Error())
+		return
+	}
+	imported := make(map[string]bool)
+	for _, t := range tasks {
+		if t.LinearID != "" {
+			imported[t.LinearID] = true
+		}
+	}
+
+	labels, err := newLabelIndex(h.db)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get labels: "+err.Error())
+		return
+	}
+
+	result := IssueImportResult{Imported: []Task{}}
+	for _, issue := range issues {
+		if imported[issue.ID] {
+			result.Skipped++
+			continue
+		}
+
+		createReq := CreateTaskRequest{
+			Title:       issue.Identifier + ": " + issue.Title,
+			Description: issue.Description,
+			ProjectID:   project.ID,
+		}
+		for _, l := range issue.Labels.Nodes {
+			name := strings.TrimSpace(l.Name)
+			if name == "" {
+				continue
+			}
+			id, err := labels.id(name, l.Color)
+			if err != nil {
+				h.writeError(w, http.StatusInternalServerError, "Failed to create label: "+err.Error())
+				return
+			}
+			createReq.LabelIDs = append(createReq.LabelIDs, id)
+		}
+
+		task, err := h.db.CreateTask(createReq, config)
+		if err != nil {
+			h.writeLabelError(w, "create", err)
+			return
+		}
+		if err := h.db.UpdateTaskLinear(task.ID, issue.ID, issue.Identifier, issue.URL); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to link Linear issue: "+err.Error())
+			return
+		}
+		task.LinearID, task.LinearIdentifier, task.LinearURL = issue.ID, issue.Identifier, issue.URL
+
+		h.hub.BroadcastTaskUpdate(task)
+		result.Imported = append(result.Imported, *task)
+	}
+
+	if labels.created {
+		h.broadcastLabels()
+	}
+	logFrom(r.Context()).Info("Issues imported", "component", "linear", "project", project.Name, "imported", len(result.Imported), "skipped", result.Skipped)
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// completeLinearIssue comments RALPH's summary and the commit hash on the
+// Linear issue of a done task and moves the issue to completed. Failures only
+// end up in the task log.
+func completeLinearIssue(db Store, hub *Hub, taskID string) {
+	task, _ := db.GetTask(taskID)
+	if task == nil || task.Status != StatusDone || task.LinearID == "" {
+		return
+	}
+	config, err := db.GetConfig()
+	if err != nil || config.LinearToken == "" {
+		return
+	}
+
+	logf := func(format string, args ...interface{}) {
+		msg := "[FORGE] " + fmt.Sprintf(format, args...) + "\n"
+		db.AppendTaskLogs(taskID, msg)
+		hub.BroadcastLog(taskID, msg)
+	}
+
+	var body strings.Builder
+	body.WriteString("**Completed in FORGE**\n\n")
+	if summary := lastRalphMessage(task.Logs); summary != "" {
+		if len(summary) > linearSummaryMaxLen {
+			summary = string(trimPartialRune([]byte(summary[:linearSummaryMaxLen]))) + "…"
+		}
+		body.WriteString(summary + "\n\n")
+	}
+	if task.CommitHash != "" {
+		body.WriteString("Commit: `" + task.CommitHash + "`\n")
+	}
+	if task.PRURL != "" {
+		body.WriteString("Pull request: " + task.PRURL + "\n")
+	}
+
+	client := NewLinearClient(config.LinearToken)
+	if err := client.CreateComment(task.LinearID, strings.TrimSpace(body.String())); err != nil {
+		logf("Could not comment on %s: %v", task.LinearIdentifier, err)
+		return
+	}
+	if err := client.CompleteIssue(task.LinearID); err != nil {
+		logf("Could not complete %s: %v", task.LinearIdentifier, err)
+		return
+	}
+	logf("Completed %s in Linear", task.LinearIdentifier)
+}
@@ -0,0 +1,332 @@
+// logging.go sets up FORGE's structured logging with log/slog. The level and
+// format come from FORGE_LOG_LEVEL and FORGE_LOG_FORMAT; every API request
+// gets an ID that is passed on to the runner, so all records caused by one
+// request can be found together. The most recent records are kept in memory
+// and served by /api/admin/logs, for debugging without access to the host.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// recentLogSize is the number of log records kept for /api/admin/logs
+const recentLogSize = 2000
+
+// logLevel is the minimum level logged; /api/admin/logs can change it at runtime
+var logLevel = new(slog.LevelVar)
+
+// recentLogs holds the most recent log records
+var recentLogs = &logRing{entries: make([]LogEntry, recentLogSize)}
+
+// requestIDPattern limits the request IDs accepted from X-Request-ID
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// setupLogging installs the slog default logger. The standard log package
+// writes through it as well, at level info.
+func setupLogging() {
+	if v := os.Getenv("FORGE_LOG_LEVEL"); v != "" {
+		if err := logLevel.UnmarshalText([]byte(v)); err != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring invalid FORGE_LOG_LEVEL %q\n", v)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch format := os.Getenv("FORGE_LOG_FORMAT"); format {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		fmt.Fprintf(os.Stderr, "Ignoring invalid FORGE_LOG_FORMAT %q (use text or json)\n", format)
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(&recentLogHandler{next: handler, ring: recentLogs}))
+}
+
+// componentLog returns the logger for a part of FORGE, e.g. "backup"
+func componentLog(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+type loggerKey struct{}
+
+// withLogger returns a context carrying logger
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// logFrom returns the logger of a request (with its request_id), or the
+// default logger outside of requests
+func logFrom(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// requestMiddleware assigns each request an ID, which is returned in
+// X-Request-ID and attached to every record logged for the request. An ID sent
+// by the client or a proxy is kept. API requests are logged when done.
+func requestMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.NewString()[:8]
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		logger := slog.Default().With("request_id", id)
+		r = r.WithContext(withLogger(r.Context(), logger))
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		level := slog.LevelDebug
+		switch {
+		case sw.status >= 500:
+			level = slog.LevelError
+		case sw.status >= 400:
+			level = slog.LevelInfo
+		case r.Method != http.MethodGet && r.Method != http.MethodHead:
+			level = slog.LevelInfo
+		}
+		logger.Log(r.Context(), level, "Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"duration", time.Since(start).Round(time.Millisecond),
+			"remote", r.RemoteAddr)
+	})
+}
+
+// statusWriter records the status code of a response. It keeps hijacking
+// and flushing working for the WebSocket.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// recentLogHandler passes records on and keeps a copy in the ring
+type recentLogHandler struct {
+	next  slog.Handler
+	ring  *logRing
+	attrs map[string]any // Attributes added with With
+	group string         // Prefix from WithGroup
+}
+
+func (h *recentLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *recentLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	entry := LogEntry{
+		Time:    record.Time,
+		Level:   record.Level.String(),
+		Message: record.Message,
+		Attrs:   make(map[string]any, len(h.attrs)+record.NumAttrs()),
+	}
+	for k, v := range h.attrs {
+		entry.Attrs[k] = v
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		addLogAttr(entry.Attrs, h.group, a)
+		return true
+	})
+	h.ring.add(entry)
+	return h.next.Handle(ctx, record)
+}
+
+func (h *recentLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	merged := make(map[string]any, len(h.attrs)+len(attrs))
+	for k, v := range h.attrs {
+		merged[k] = v
+	}
+	for _, a := range attrs {
+		addLogAttr(merged, h.group, a)
+	}
+	return &recentLogHandler{next: h.next.WithAttrs(attrs), ring: h.ring, attrs: merged, group: h.group}
+}
+
+func (h *recentLogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &recentLogHandler{next: h.next.WithGroup(name), ring: h.ring, attrs: h.attrs, group: h.group + name + "."}
+}
+
+// addLogAttr flattens an attribute into m, with groups joined by dots
+func addLogAttr(m map[string]any, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		p := prefix
+		if a.Key != "" {
+			p += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			addLogAttr(m, p, ga)
+		}
+	case slog.KindString, slog.KindInt64, slog.KindUint64, slog.KindFloat64, slog.KindBool:
+		m[prefix+a.Key] = v.Any()
+	default:
+		m[prefix+a.Key] = v.String()
+	}
+}
+
+// logRing is a fixed-size buffer of the most recent log records
+type logRing struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+func (r *logRing) add(entry LogEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// tail returns up to n of the most recent records, oldest first, that are at
+// least minLevel and carry all of the given attribute values
+func (r *logRing) tail(n int, minLevel slog.Level, match map[string]string) []LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+	result := []LogEntry{}
+	for i := 1; i <= count && len(result) < n; i++ {
+		entry := r.entries[(r.next-i+len(r.entries))%len(r.entries)]
+		var level slog.Level
+		if level.UnmarshalText([]byte(entry.Level)) == nil && level < minLevel {
+			continue
+		}
+		matches := true
+		for k, v := range match {
+			if fmt.Sprint(entry.Attrs[k]) != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			result = append(result, entry)
+		}
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// HandleAdminLogs handles GET/PUT /api/admin/logs
+// GET returns recent records: ?n=200, ?level=warn and filters on
+// request_id, task_id and component. PUT {"level": "debug"} changes the level.
+func (h *Handler) HandleAdminLogs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		n := 200
+		if v := q.Get("n"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 1 {
+				h.writeError(w, http.StatusBadRequest, "n must be a positive number")
+				return
+			}
+			n = min(parsed, recentLogSize)
+		}
+		minLevel := slog.LevelDebug
+		if v := q.Get("level"); v != "" {
+			if err := minLevel.UnmarshalText([]byte(v)); err != nil {
+				h.writeError(w, http.StatusBadRequest, "Invalid level (use debug, info, warn or error)")
+				return
+			}
+		}
+		match := make(map[string]string)
+		for _, key := range []string{"request_id", "task_id", "component"} {
+			if v := q.Get(key); v != "" {
+				match[key] = v
+			}
+		}
+		h.writeJSON(w, http.StatusOK, LogsResponse{
+			Level:   strings.ToLower(logLevel.Level().String()),
+			Entries: recentLogs.tail(n, minLevel, match),
+		})
+
+	case http.MethodPut:
+		var req UpdateLogLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid level (use debug, info, warn or error)")
+			return
+		}
+		logLevel.Set(level)
+		logFrom(r.Context()).Info("Log level changed", "level", level.String())
+		h.writeJSON(w, http.StatusOK, LogsResponse{Level: strings.ToLower(level.String()), Entries: []LogEntry{}})
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// fatal logs an error and exits, like log.Fatalf for structured records
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
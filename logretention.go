@@ -0,0 +1,322 @@
+// logretention.go keeps task logs from growing the database without bound.
+// Three settings (Settings, Config.LogRetention) control it: a size cap per
+// task that keeps the beginning and the end of a log and drops the middle,
+// gzip compression of the logs of tasks that finished some days ago, and
+// purging the logs of tasks that have been done for longer. The cap is applied
+// when a run ends; compression and purging run periodically
+// (FORGE_LOG_COMPACT_INTERVAL) and on demand via
+// POST /api/admin/task-logs/compact. Compressed logs are expanded
+// transparently when a task is read.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultLogCompactInterval is how often compression and purging run
+const defaultLogCompactInterval = time.Hour
+
+// compressedLogsPrefix marks logs stored as base64-encoded gzip
+const compressedLogsPrefix = "gzip:"
+
+// purgedLogsPrefix starts the note that replaces purged logs
+const purgedLogsPrefix = "[FORGE] Logs purged"
+
+// minCompressSize skips logs too small to be worth compressing
+const minCompressSize = 4096
+
+// LogRetention configures how long and how much of the task logs is kept,
+// stored as a JSON object
+type LogRetention struct {
+	MaxSizeKB         int `json:"max_size_kb,omitempty"`         // Cap per task, 0 = unlimited
+	CompressAfterDays int `json:"compress_after_days,omitempty"` // Compress logs of finished tasks, 0 = never
+	PurgeAfterDays    int `json:"purge_after_days,omitempty"`    // Delete logs of done tasks, 0 = never
+}
+
+// IsZero reports whether nothing is configured
+func (l LogRetention) IsZero() bool {
+	return l.MaxSizeKB == 0 && l.CompressAfterDays == 0 && l.PurgeAfterDays == 0
+}
+
+// Value stores the settings as JSON, no settings as an empty string
+func (l LogRetention) Value() (driver.Value, error) {
+	if l.IsZero() {
+		return "", nil
+	}
+	raw, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads settings stored by Value
+func (l *LogRetention) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into LogRetention", src)
+	}
+	*l = LogRetention{}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, l)
+}
+
+// validate rejects negative limits
+func (l LogRetention) validate() error {
+	if l.MaxSizeKB < 0 || l.CompressAfterDays < 0 || l.PurgeAfterDays < 0 {
+		return fmt.Errorf("log retention limits must not be negative")
+	}
+	return nil
+}
+
+// storedLogs scans task logs, expanding compressed ones
+type storedLogs string
+
+// Scan reads logs as stored by AppendTaskLogs or compactTaskLogs
+func (l *storedLogs) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into task logs", src)
+	}
+	*l = storedLogs(expandLogs(s))
+	return nil
+}
+
+// compressLogs gzips logs for storage
+func compressLogs(logs string) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(logs)); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return compressedLogsPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// expandLogs returns stored logs as text, decompressing them if needed
+func expandLogs(stored string) string {
+	if !strings.HasPrefix(stored, compressedLogsPrefix) {
+		return stored
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, compressedLogsPrefix))
+	if err != nil {
+		return "[FORGE] Compressed logs could not be read: " + err.Error() + "\n"
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return "[FORGE] Compressed logs could not be read: " + err.Error() + "\n"
+	}
+	defer zr.Close()
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		return "[FORGE] Compressed logs could not be read: " + err.Error() + "\n"
+	}
+	return string(text)
+}
+
+// capLogs shortens logs to at most limit bytes, keeping the first quarter and
+// the last three quarters (where the result is) and cutting at line ends, so
+// stream-json lines stay intact
+func capLogs(logs string, limit int) string {
+	if limit <= 0 || len(logs) <= limit {
+		return logs
+	}
+	const markerSize = 100 // Room for the note on the omitted part
+	keep := max(limit-markerSize, 0)
+	head := logs[:keep/4]
+	if i := strings.LastIndex(head, "\n"); i >= 0 {
+		head = head[:i+1]
+	}
+	tail := logs[len(logs)-(keep-keep/4):]
+	if i := strings.Index(tail, "\n"); i >= 0 {
+		tail = tail[i+1:]
+	}
+	omitted := len(logs) - len(head) - len(tail)
+	return head + fmt.Sprintf("\n[FORGE] %s of log omitted to stay within the size limit\n\n", formatBytes(uint64(omitted))) + tail
+}
+
+// logCompactIntervalFromEnv reads FORGE_LOG_COMPACT_INTERVAL; 0 disables the periodic run
+func logCompactIntervalFromEnv() time.Duration {
+	v := os.Getenv("FORGE_LOG_COMPACT_INTERVAL")
+	if v == "" {
+		return defaultLogCompactInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("logs").Warn("Ignoring invalid FORGE_LOG_COMPACT_INTERVAL", "value", v)
+		return defaultLogCompactInterval
+	}
+	return d
+}
+
+// RunLogCompaction compacts the task logs every interval until stop is closed
+func (r *RalphRunner) RunLogCompaction(interval time.Duration, stop <-chan struct{}) {
+	if interval == 0 {
+		componentLog("logs").Info("Log compaction disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			config, err := r.db.GetConfig()
+			if err != nil {
+				componentLog("logs").Error("Failed to load config for log compaction", "err", err)
+				continue
+			}
+			if config.LogRetention.IsZero() {
+				continue
+			}
+			result, err := compactTaskLogs(r.db, config.LogRetention, time.Now())
+			if err != nil {
+				componentLog("logs").Error("Log compaction failed", "err", err)
+				continue
+			}
+			if result.Capped+result.Compressed+result.Purged > 0 {
+				componentLog("logs").Info("Task logs compacted", "capped", result.Capped, "compressed", result.Compressed,
+					"purged", result.Purged, "freed", formatBytes(uint64(max(result.BytesBefore-result.BytesAfter, 0))))
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// compactTaskLogs applies the retention settings to the logs of all tasks
+// that are not running
+func compactTaskLogs(db Store, retention LogRetention, now time.Time) (*LogCompactionResult, error) {
+	sizes, err := db.GetTaskLogSizes()
+	if err != nil {
+		return nil, err
+	}
+	columns, err := db.GetBoardColumns()
+	if err != nil {
+		return nil, err
+	}
+	roles := make(map[TaskStatus]string, len(columns))
+	for _, c := range columns {
+		roles[c.Status] = c.Role
+	}
+	result := &LogCompactionResult{}
+	for _, size := range sizes {
+		// Tasks in queue and progress columns are not finished, whatever the column
+		if role := roles[size.Status]; role == ColumnRoleQueue || role == ColumnRoleProgress {
+			continue
+		}
+		if err := compactLogsOf(db, retention, size, now, result); err != nil {
+			return result, fmt.Errorf("task %s: %w", size.TaskID, err)
+		}
+	}
+	return result, nil
+}
+
+// compactLogsOf applies the retention settings to the logs of one task
+func compactLogsOf(db Store, retention LogRetention, size TaskLogSize, now time.Time, result *LogCompactionResult) error {
+	age := now.Sub(size.LastActivity)
+	days := func(n int) time.Duration { return time.Duration(n) * 24 * time.Hour }
+
+	var logs string
+	switch {
+	case retention.PurgeAfterDays > 0 && size.Status == StatusDone && age > days(retention.PurgeAfterDays):
+		logs = fmt.Sprintf("%s on %s (done for more than %d days)\n", purgedLogsPrefix, now.Format("2006-01-02"), retention.PurgeAfterDays)
+		result.Purged++
+
+	case !size.Compressed:
+		capped := retention.MaxSizeKB > 0 && size.Size > int64(retention.MaxSizeKB)*1024
+		compress := retention.CompressAfterDays > 0 && age > days(retention.CompressAfterDays) && size.Size >= minCompressSize
+		if !capped && !compress {
+			return nil
+		}
+		current, err := db.GetTaskLogs(size.TaskID)
+		if err != nil {
+			return err
+		}
+		logs = current
+		if capped {
+			logs = capLogs(logs, retention.MaxSizeKB*1024)
+			result.Capped++
+		}
+		if compress {
+			compressed, err := compressLogs(logs)
+			if err != nil {
+				return err
+			}
+			if len(compressed) < len(logs) {
+				logs = compressed
+				result.Compressed++
+			}
+		}
+
+	default:
+		return nil
+	}
+
+	replaced, err := db.ReplaceTaskLogs(size.TaskID, size.Size, logs)
+	if err != nil || !replaced {
+		return err // Not replaced: the task has run again meanwhile
+	}
+	result.BytesBefore += size.Size
+	result.BytesAfter += int64(len(logs))
+	return nil
+}
+
+// capTaskLogs applies the size cap to the logs of a task whose run ended
+func (r *RalphRunner) capTaskLogs(taskID string) {
+	config, err := r.db.GetConfig()
+	if err != nil || config.LogRetention.MaxSizeKB == 0 {
+		return
+	}
+	size, err := r.db.GetTaskLogSize(taskID)
+	if err != nil || size == nil {
+		return
+	}
+	retention := LogRetention{MaxSizeKB: config.LogRetention.MaxSizeKB}
+	if err := compactLogsOf(r.db, retention, *size, time.Now(), &LogCompactionResult{}); err != nil {
+		r.taskLog(taskID).Warn("Failed to cap task logs", "err", err)
+	}
+}
+
+// HandleCompactTaskLogs handles POST /api/admin/task-logs/compact
+// Applies the log retention settings right away instead of waiting for the periodic run.
+func (h *Handler) HandleCompactTaskLogs(w http.ResponseWriter, r *http.Request) {
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+	result, err := compactTaskLogs(h.db, config.LogRetention, time.Now())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to compact task logs: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, result)
+}
@@ -11,12 +11,13 @@ package main
 
 import (
 	"context"
+	"embed"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 )
@@ -30,9 +31,17 @@ const (
 	defaultDBPath = "forge.db" // Default SQLite database path
 )
 
+// embeddedStatic contains the frontend assets, so the binary runs from any directory
+//
+//go:embed static
+var embeddedStatic embed.FS
+
 // main is the application entry point.
 // Initializes all components and starts the HTTP server.
 func main() {
+	// Strukturiertes Logging (FORGE_LOG_LEVEL, FORGE_LOG_FORMAT)
+	setupLogging()
+
 	// Load configuration from environment variables
 	// FORGE_PORT: HTTP server port (default: 3333)
 	port := os.Getenv("FORGE_PORT")
@@ -40,20 +49,54 @@ func main() {
 		port = defaultPort
 	}
 
-	// FORGE_DB: SQLite database path (default: forge.db)
-	dbPath := os.Getenv("FORGE_DB")
-	if dbPath == "" {
-		dbPath = defaultDBPath
+	// FORGE_UPLOADS_DIR: Directory for task attachments (default: uploads)
+	if dir := os.Getenv("FORGE_UPLOADS_DIR"); dir != "" {
+		UploadsDir = dir
+	}
+
+	// Unterbefehle laufen ohne HTTP-Server:
+	// "forge migrate ..." wartet das Schema, "forge task ..." ist der CLI-Client
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(runMigrateCommand(os.Args[2:]))
+		case "task":
+			os.Exit(runTaskCommand(os.Args[2:]))
+		case "login":
+			os.Exit(runLoginCommand(os.Args[2:]))
+		}
 	}
 
 	// Datenbank initialisieren
 	// Erstellt das Schema und führt Migrationen aus
-	log.Println("Initializing database...")
-	db, err := NewDatabase(dbPath)
+	slog.Info("Initializing database")
+	db, err := openDatabaseFromEnv(true)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("Failed to initialize database", "err", err)
 	}
 	defer db.Close()
+	slog.Info("Database ready", "driver", db.Driver())
+
+	// Anmeldung über GitHub nur mit Liste erlaubter Benutzer (FORGE_GITHUB_*)
+	if err := authConfig.validate(); err != nil {
+		fatal("Invalid login configuration", "err", err)
+	}
+
+	// Tokens aus der Config verschlüsseln, falls noch im Klartext gespeichert
+	encryptStoredCredentials(db)
+
+	// Deploy-Keys der Projekte für Push, Pull und Fetch bereitstellen
+	if err := deployKeys.load(db); err != nil {
+		slog.Warn("Failed to load deploy keys", "err", err)
+	}
+
+	// GitHub-Endpunkte der Config für Projekte ohne eigene bereitstellen
+	loadConfigGitHub(db)
+
+	// Signaturschlüssel und signierende Projekte für Commits und Tags laden
+	if err := commitSigning.load(db); err != nil {
+		slog.Warn("Failed to load signing settings", "err", err)
+	}
 
 	// WebSocket-Hub initialisieren
 	// Der Hub verwaltet alle aktiven WebSocket-Verbindungen und
@@ -69,123 +112,312 @@ func main() {
 	// and mark them as blocked if the process is no longer running
 	recoverTasks(db, runner)
 
+	// Backup-Manager initialisieren
+	// Erstellt periodisch Snapshots der Datenbank (FORGE_BACKUP_* Variablen)
+	backups := NewBackupManager(db)
+	stopBackups := make(chan struct{})
+	go backups.Run(stopBackups)
+
+	// Git-Status der Projekte im Hintergrund cachen (FORGE_GIT_STATUS_INTERVAL)
+	stopGitStatus := make(chan struct{})
+	go gitStatus.Run(db, stopGitStatus)
+
+	// Projektverzeichnisse auf Änderungen außerhalb von FORGE überwachen (FORGE_WATCH_PROJECTS)
+	stopWatcher := make(chan struct{})
+	go RunProjectWatcher(db, hub, runner, stopWatcher)
+
+	// Projekt-Basisverzeichnis nach neuen Projekten durchsuchen, fehlende markieren (FORGE_SCAN_INTERVAL)
+	stopScans := make(chan struct{})
+	go projectScans.Run(db, hub, scanIntervalFromEnv(), stopScans)
+
+	// Commits mit Forge-Task-Trailer indexieren (FORGE_COMMIT_INDEX_INTERVAL)
+	stopCommitIndex := make(chan struct{})
+	go commitIndex.Run(db, stopCommitIndex)
+
+	// Pull Requests von Tasks in Review mit GitHub abgleichen (FORGE_PR_SYNC_INTERVAL)
+	stopPRSync := make(chan struct{})
+	go runner.RunPRSync(prSyncIntervalFromEnv(), stopPRSync)
+
+	// CI-Status der Task-Commits von GitHub abfragen (FORGE_CI_SYNC_INTERVAL)
+	stopCISync := make(chan struct{})
+	go runner.RunCISync(ciSyncIntervalFromEnv(), stopCISync)
+
+	// Tasks melden, die länger als das SLA ihrer Spalte darin stehen (FORGE_SLA_CHECK_INTERVAL)
+	stopSLACheck := make(chan struct{})
+	go runner.RunSLACheck(slaCheckIntervalFromEnv(), stopSLACheck)
+
+	// Task-Logs komprimieren und alte Logs löschen (FORGE_LOG_COMPACT_INTERVAL)
+	stopLogCompaction := make(chan struct{})
+	go runner.RunLogCompaction(logCompactIntervalFromEnv(), stopLogCompaction)
+
+	// Status-Änderungen an importierte Jira-Issues übertragen (FORGE_JIRA_SYNC_INTERVAL)
+	stopJiraSync := make(chan struct{})
+	go jiraSync.Run(db, stopJiraSync)
+
+	// Umgebung prüfen (Claude CLI, git, GitHub-Token, Speicherplatz, Projekte) und Probleme loggen
+	go logStartupChecks(db)
+
 	// HTTP-Handler initialisieren
 	// Der Handler verarbeitet alle API-Anfragen
-	handler := NewHandler(db, hub, runner)
+	handler := NewHandler(db, hub, runner, backups)
+
+	// Nicht zugeordnete Uploads nach Ablauf der TTL löschen (FORGE_UPLOAD_TTL)
+	stopUploads := make(chan struct{})
+	go handler.RunUploadCleanup(stopUploads)
 
-	// HTTP-Router konfigurieren
+	// HTTP-Router konfigurieren: API-Routen mit Methode und Pfad-Parametern
+	// ({id} etc., siehe router.go), alles andere über den Standard-Mux
+	api := newRouter(handler)
 	mux := http.NewServeMux()
+	mux.Handle("/api/", api)
 
 	// ==================== API-Routen ====================
 
 	// Task-Routen: CRUD-Operationen für Tasks
-	mux.HandleFunc("/api/tasks", handler.HandleTasks)
-	mux.HandleFunc("/api/tasks/", func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-		log.Printf("[API] %s %s", r.Method, path)
-		// Spezielle Task-Aktionen basierend auf dem URL-Suffix
-		if strings.HasSuffix(path, "/pause") {
-			handler.HandleTaskPause(w, r) // RALPH-Prozess pausieren
-		} else if strings.HasSuffix(path, "/resume") {
-			handler.HandleTaskResume(w, r) // RALPH-Prozess fortsetzen
-		} else if strings.HasSuffix(path, "/stop") {
-			handler.HandleTaskStop(w, r) // RALPH-Prozess stoppen
-		} else if strings.HasSuffix(path, "/feedback") {
-			handler.HandleTaskFeedback(w, r) // Feedback an Claude senden
-		} else if strings.HasSuffix(path, "/continue") {
-			handler.HandleTaskContinue(w, r) // Task in Queue mit Message fortsetzen
-		} else if strings.HasSuffix(path, "/deploy") {
-			handler.HandleDeployTask(w, r) // Task deployen (commit & push)
-		} else if strings.HasSuffix(path, "/merge") {
-			log.Printf("[API] Routing to HandleMergeTask")
-			handler.HandleMergeTask(w, r) // Branch in main mergen (DEPRECATED)
-		} else if strings.HasSuffix(path, "/rollback") {
-			handler.HandleTaskRollback(w, r) // Trunk-based: Rollback zu Tag
-		} else if strings.HasSuffix(path, "/resolve-conflict") {
-			handler.HandleResolveConflict(w, r) // RALPH löst Merge-Konflikt
-		} else if strings.HasSuffix(path, "/attachments") {
-			handler.HandleTaskAttachments(w, r) // GET/POST Attachments
-		} else if strings.Contains(path, "/attachments/") {
-			handler.HandleTaskAttachment(w, r) // GET/DELETE einzelnes Attachment
-		} else {
-			handler.HandleTask(w, r) // Standard GET/PUT/DELETE
-		}
-	})
+	api.handle("GET POST", "/api/tasks", handler.HandleTasks)
+	api.handle("GET PUT DELETE", "/api/tasks/{id}", handler.HandleTask)
+	api.handle("POST", "/api/tasks/assist", handler.HandleTaskAssist) // Formular-Vorschläge von Claude (nichts wird gespeichert)
+	api.handle("POST", "/api/tasks/import", handler.HandleTaskImport, limitBody(maxTaskImportSize)) // Tasks aus CSV oder Markdown-Checkliste anlegen
+
+	// Task-Aktionen
+	api.handle("POST", "/api/tasks/{id}/pause", handler.HandleTaskPause)                  // RALPH-Prozess pausieren
+	api.handle("POST", "/api/tasks/{id}/resume", handler.HandleTaskResume)                // RALPH-Prozess fortsetzen
+	api.handle("POST", "/api/tasks/{id}/stop", handler.HandleTaskStop)                    // RALPH-Prozess stoppen
+	api.handle("POST", "/api/tasks/{id}/feedback", handler.HandleTaskFeedback)            // Feedback an Claude senden
+	api.handle("POST", "/api/tasks/{id}/continue", handler.HandleTaskContinue)            // Task in Queue mit Message fortsetzen
+	api.handle("GET", "/api/tasks/{id}/review-comments", handler.HandleTaskReviewComments) // Neue Review-Kommentare am Pull Request
+	api.handle("POST", "/api/tasks/{id}/address-review", handler.HandleAddressReview)      // Task mit den Review-Kommentaren fortsetzen
+	api.handle("POST", "/api/tasks/{id}/queue-position", handler.HandleTaskQueuePosition) // Task in der Queue verschieben
+	api.handle("POST", "/api/tasks/{id}/deploy", handler.HandleDeployTask)                // Task deployen (commit & push)
+	api.handle("POST", "/api/tasks/{id}/merge", handler.HandleMergeTask)                  // Branch in main mergen (DEPRECATED)
+	api.handle("POST", "/api/tasks/{id}/rollback", handler.HandleTaskRollback)            // Trunk-based: Rollback zu Tag
+	api.handle("POST", "/api/tasks/{id}/resolve-conflict", handler.HandleResolveConflict) // RALPH löst Merge-Konflikt
+	api.handle("GET", "/api/tasks/{id}/conflicts", handler.HandleTaskConflicts)           // Konflikte mit dem Remote und ihre Auflösung
+	api.handle("POST", "/api/tasks/{id}/cherry-pick", handler.HandleTaskCherryPick)       // Commits des Tasks auf anderen Branch übernehmen
+	api.handle("POST", "/api/tasks/{id}/clone", handler.HandleTaskClone)                  // Task kopieren (ohne Laufzustand)
+	api.handle("POST", "/api/tasks/{id}/rerun", handler.HandleTaskRerun)                  // Erledigten Task kopieren und einreihen
+	api.handle("POST PUT DELETE", "/api/tasks/{id}/plan", handler.HandleTaskPlan)         // Plan-Modus: planen, freigeben, verwerfen
+	api.handle("POST", "/api/tasks/{id}/split", handler.HandleTaskSplit)                  // Aufteilung in Teil-Tasks vorschlagen
+	api.handle("POST", "/api/tasks/{id}/triage", handler.HandleTaskTriage)                // Blockierten Task von Claude analysieren lassen
+	api.handle("GET POST", "/api/tasks/{id}/subtasks", handler.HandleTaskSubtasks)        // Teil-Tasks des Epics auflisten/anlegen
+	api.handle("GET", "/api/tasks/{id}/timing", handler.HandleTaskTiming)                 // Verweildauer je Status
+	api.handle("GET", "/api/tasks/{id}/runs", handler.HandleTaskRuns)                     // Alle RALPH-Läufe des Tasks
+	api.handle("GET", "/api/tasks/{id}/runs/compare", handler.HandleTaskRunCompare)       // Zwei Läufe vergleichen
+	api.handle("GET", "/api/tasks/{id}/runs/{run}/logs", handler.HandleTaskRunLogs)       // Logs eines Laufs
+	api.handle("GET", "/api/tasks/{id}/runs/{run}/transcript", handler.HandleTaskRunTranscript) // Prompt und Gesprächsverlauf eines Laufs
+	api.handle("GET", "/api/tasks/{id}/render", handler.HandleTaskRender)                 // Beschreibung & Kommentare als HTML
+	api.handle("GET", "/api/tasks/{id}/commits", handler.HandleTaskCommits)               // Commits des Tasks (Forge-Task-Trailer)
+	api.handle("GET", "/api/tasks/{id}/report", handler.HandleTaskReport)                 // Bericht über Task & Lauf (Markdown/HTML)
+	api.handle("POST", "/api/tasks/{id}/screenshot", handler.HandleTaskScreenshot)        // Screenshot der Projekt-URL anhängen
+	api.handle("POST", "/api/tasks/{id}/share", handler.HandleShareTask)                  // Signierten Lese-Link erzeugen
+
+	// Geteilte Tasks: nur lesend über den signierten Link, ohne Zugang zum Board
+	api.handle("GET", "/api/share/{token}", handler.HandleSharedTask)
+
+	// Anmeldung über GitHub-OAuth (siehe auth.go), ohne Sitzung erreichbar
+	api.handle("GET", "/api/auth/status", handler.HandleAuthStatus)
+	api.handle("GET", "/api/auth/github/login", handler.HandleGitHubLogin)       // Web-Flow: Weiterleitung zu GitHub
+	api.handle("GET", "/api/auth/github/callback", handler.HandleGitHubCallback) // Web-Flow: Rückkehr von GitHub
+	api.handle("POST", "/api/auth/github/device", handler.HandleGitHubDevice)     // Device-Flow: Code anfordern
+	api.handle("POST", "/api/auth/github/device/token", handler.HandleGitHubDeviceToken)
+	api.handle("POST", "/api/auth/logout", handler.HandleLogout)
+
+	// Profil des angemeldeten Benutzers: Git-Identität und eigener GitHub-Token (siehe profile.go)
+	api.handle("GET PUT", "/api/profile", handler.HandleProfile)
+
+	// Checkpoints: Stand nach jeder Iteration, wiederherstellbar
+	api.handle("GET", "/api/tasks/{id}/checkpoints", handler.HandleTaskCheckpoints)
+	api.handle("POST", "/api/tasks/{id}/restore-checkpoint", handler.HandleTaskRestoreCheckpoint)
+
+	// Task-Anhänge: Liste, Upload, Datei, Löschen und Vorschaubild
+	api.handle("GET", "/api/tasks/{id}/attachments", handler.HandleTaskAttachments)
+	api.handle("POST", "/api/tasks/{id}/attachments", handler.HandleTaskAttachments, limitBody(MaxUploadSize))
+	api.handle("GET DELETE", "/api/tasks/{id}/attachments/{attachmentId}", handler.HandleTaskAttachment)
+	api.handle("GET", "/api/tasks/{id}/attachments/{attachmentId}/{action}", handler.HandleTaskAttachment)
+
+	// Task-Kommentare: CRUD und Übergabe an RALPH (.../send)
+	api.handle("GET POST", "/api/tasks/{id}/comments", handler.HandleTaskComments)
+	api.handle("GET PUT DELETE", "/api/tasks/{id}/comments/{commentId}", handler.HandleTaskComment)
+	api.handle("POST", "/api/tasks/{id}/comments/{commentId}/{action}", handler.HandleTaskComment)
+
+	// Queue-Route: mehrere Tasks auf einmal umsortieren
+	api.handle("POST", "/api/queue/reorder", handler.HandleQueueReorder)
+	api.handle("GET", "/api/queue/eta", handler.HandleQueueETAs) // Geschätzte Start- und Endzeiten
+
+	// Statistik-Route: Stand der Kostenbudgets im laufenden Tag und Monat
+	api.handle("GET", "/api/stats/budgets", handler.HandleBudgets)
+
+	// Markdown-Route: Text serverseitig zu bereinigtem HTML rendern
+	api.handle("POST", "/api/render", handler.HandleRenderMarkdown)
+
+	// Upload-Routen: Dateien hochladen, bevor der Task existiert (z.B. eingefügte Screenshots)
+	api.handle("POST", "/api/uploads", handler.HandleUploads, limitBody(MaxUploadSize))
+	api.handle("GET", "/api/uploads/{id}", handler.HandleUpload)
 
 	// Upload-Routen: Statische Dateien für hochgeladene Anhänge
 	mux.HandleFunc("/uploads/", handler.HandleServeUpload)
 
 	// Konfigurations-Route: Globale Einstellungen
-	mux.HandleFunc("/api/config", handler.HandleConfig)
+	api.handle("GET PUT", "/api/config", handler.HandleConfig)
+	api.handle("PUT DELETE", "/api/config/credentials/{name}", handler.HandleConfigCredential)
+
+	// Diagnose-Routen: Liveness-Probe und vollständige Umgebungsprüfung
+	api.handle("GET", "/api/health", handler.HandleHealth)
+	api.handle("GET", "/api/doctor", handler.HandleDoctor)
+
+	// Export/Import-Routen: Board-Snapshot für Migration zwischen Rechnern
+	api.handle("GET", "/api/export", handler.HandleExport)
+	api.handle("POST", "/api/import", handler.HandleImport, limitBody(MaxImportSize))
+
+	// Admin-Routen: Datenbank-Backups, Migrationsstatus und Logs
+	api.handle("POST", "/api/admin/backup", handler.HandleAdminBackup)
+	api.handle("GET", "/api/admin/backups", handler.HandleAdminBackups)
+	api.handle("POST", "/api/admin/restore", handler.HandleAdminRestore)
+	api.handle("GET", "/api/admin/migrations", handler.HandleAdminMigrations)
+	api.handle("GET PUT", "/api/admin/logs", handler.HandleAdminLogs)
+	api.handle("POST", "/api/admin/task-logs/compact", handler.HandleCompactTaskLogs)
+	api.handle("GET", "/api/admin/queue", handler.HandleQueueState)
+	api.handle("POST", "/api/admin/queue/pause", handler.HandlePauseQueue)
+	api.handle("POST", "/api/admin/queue/resume", handler.HandleResumeQueue)
 
 	// Verzeichnis-Browser-Routen: Dateisystem-Navigation
-	mux.HandleFunc("/api/browse", handler.HandleBrowse)
-	mux.HandleFunc("/api/browse/create", handler.HandleCreateDir)
+	api.handle("GET", "/api/browse", handler.HandleBrowse)
+	api.handle("POST", "/api/browse/create", handler.HandleCreateDir)
 
 	// GitHub-Routen: GitHub-Integration
-	mux.HandleFunc("/api/github/validate", handler.HandleGitHubValidate)
-	mux.HandleFunc("/api/github/create-pr", handler.HandleCreatePR)
+	api.handle("POST", "/api/github/validate", handler.HandleGitHubValidate)
+	api.handle("POST", "/api/github/create-pr", handler.HandleCreatePR)
+	api.handle("POST", "/api/webhooks/github", handler.HandleGitHubWebhook, limitBody(maxWebhookSize)) // check_run/check_suite- und Review-Events
+
+	// Linear-Integration
+	api.handle("GET", "/api/linear/teams", handler.HandleLinearTeams)
 
 	// Projekt-Routen: CRUD und spezielle Operationen für Projekte
-	mux.HandleFunc("/api/projects", handler.HandleProjects)
-	mux.HandleFunc("/api/projects/scan", handler.HandleProjectScan)
-	mux.HandleFunc("/api/projects/scan-all", handler.HandleScanAllProjects)
-	mux.HandleFunc("/api/projects/", func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-		// Spezielle Projekt-Aktionen basierend auf dem URL-Suffix
-		if strings.HasSuffix(path, "/git-init") {
-			handler.HandleGitInit(w, r) // Git-Repository initialisieren
-		} else if strings.HasSuffix(path, "/github-repo") {
-			handler.HandleCreateGitHubRepo(w, r) // GitHub-Repository erstellen
-		} else if strings.HasSuffix(path, "/git-info") {
-			handler.getProjectGitInfo(w, r) // Git-Informationen abrufen
-		} else if strings.HasSuffix(path, "/branches") {
-			handler.getProjectBranches(w, r) // Branch-Liste abrufen
-		} else if strings.HasSuffix(path, "/rules") {
-			handler.HandleBranchRules(w, r) // Branch-Schutzregeln
-		} else if strings.Contains(path, "/rules/") {
-			handler.HandleBranchRule(w, r) // Einzelne Branch-Regel
-		} else if strings.HasSuffix(path, "/push-status") {
-			handler.HandleProjectPushStatus(w, r) // Trunk-based: Unpushed commits
-		} else if strings.HasSuffix(path, "/push") {
-			handler.HandleProjectPush(w, r) // Trunk-based: Push zu Remote
-		} else if strings.HasSuffix(path, "/working-branch") {
-			handler.HandleProjectSetWorkingBranch(w, r) // Trunk-based: Working Branch setzen
-		} else {
-			handler.HandleProject(w, r) // Standard GET/PUT/DELETE
-		}
-	})
+	api.handle("GET POST", "/api/projects", handler.HandleProjects)
+	api.handle("POST", "/api/projects/scan", handler.HandleProjectScan)
+	api.handle("POST", "/api/projects/scan-all", handler.HandleScanAllProjects)
+	api.handle("POST", "/api/projects/clone", handler.HandleProjectClone)
+	api.handle("GET POST", "/api/projects/bootstrap", handler.HandleProjectBootstrap)
+	api.handle("GET PUT DELETE", "/api/projects/{id}", handler.HandleProject)
+
+	// Projekt-Aktionen
+	api.handle("POST", "/api/projects/{id}/git-init", handler.HandleGitInit)                       // Git-Repository initialisieren
+	api.handle("POST", "/api/projects/{id}/github-repo", handler.HandleCreateGitHubRepo)           // GitHub-Repository erstellen
+	api.handle("GET", "/api/projects/{id}/git-info", handler.getProjectGitInfo)                    // Git-Informationen abrufen
+	api.handle("GET", "/api/projects/{id}/branches", handler.getProjectBranches)                   // Branch-Liste abrufen
+	api.handle("POST", "/api/projects/{id}/branches/cleanup", handler.HandleBranchCleanup)         // Alte Branches anzeigen bzw. löschen
+	api.handle("POST", "/api/projects/{id}/detect", handler.HandleProjectDetect)                   // Sprache, Framework und Befehle erkennen
+	api.handle("GET", "/api/projects/{id}/branch-status", handler.getProjectBranchStatus)          // Branch hinter Remote?
+	api.handle("POST", "/api/projects/{id}/checkout", handler.handleProjectCheckout)               // Branch wechseln
+	api.handle("POST", "/api/projects/{id}/pull", handler.handleProjectPull)                       // Änderungen holen
+	api.handle("GET POST", "/api/projects/{id}/stash", handler.HandleProjectStash)                 // Stashes auflisten bzw. Änderungen stashen
+	api.handle("POST", "/api/projects/{id}/stash/pop", handler.HandlePopStash)                     // Stash wiederherstellen
+	api.handle("GET", "/api/projects/{id}/conflicts", handler.HandleProjectConflicts)               // Konflikte mit Base/Ours/Theirs
+	api.handle("POST", "/api/projects/{id}/conflicts/{action}", handler.HandleProjectConflictAction) // Konflikt auflösen, fortsetzen, abbrechen
+	api.handle("GET", "/api/projects/{id}/health", handler.HandleProjectHealth)                    // Gecachter Git-Status
+	api.handle("GET", "/api/repo-locks", handler.HandleRepoLocks)                                  // Gesperrte Repositorys und wartende Git-Operationen
+	api.handle("GET", "/api/projects/{id}/files", handler.HandleProjectFiles)                      // Verzeichnis auflisten
+	api.handle("GET", "/api/projects/{id}/file", handler.HandleProjectFile)                        // Dateiinhalt lesen
+	api.handle("GET", "/api/projects/{id}/log", handler.HandleProjectLog)                          // Commit-Historie (optional pro Datei)
+	api.handle("POST", "/api/projects/{id}/import-issues", handler.HandleImportIssues)             // Offene GitHub-Issues als Tasks importieren
+	api.handle("POST", "/api/projects/{id}/import-jira", handler.HandleImportJira)                 // Jira-Issues per JQL als Tasks importieren
+	api.handle("POST", "/api/projects/{id}/import-linear", handler.HandleImportLinear)             // Offene Linear-Issues als Tasks importieren
+	api.handle("GET", "/api/projects/{id}/push-status", handler.HandleProjectPushStatus)           // Trunk-based: Unpushed commits
+	api.handle("POST", "/api/projects/{id}/push", handler.HandleProjectPush)                       // Trunk-based: Push zu Remote
+	api.handle("POST", "/api/projects/{id}/working-branch", handler.HandleProjectSetWorkingBranch) // Trunk-based: Working Branch setzen
+
+	// Branch-Schutzregeln
+	api.handle("GET POST", "/api/projects/{id}/rules", handler.HandleBranchRules)
+	api.handle("POST", "/api/projects/{id}/rules/test", handler.HandleTestBranchRules) // Branch-Namen gegen Regeln prüfen
+	api.handle("PUT DELETE", "/api/projects/{id}/rules/{ruleId}", handler.HandleBranchRule)
+
+	// Pfad-Schutzregeln (nach jeder Iteration geprüft)
+	api.handle("GET POST", "/api/projects/{id}/paths", handler.HandlePathRules)
+	api.handle("PUT DELETE", "/api/projects/{id}/paths/{ruleId}", handler.HandlePathRule)
+
+	// Verschlüsselte Projekt-Secrets (nur schreibbar)
+	api.handle("GET POST", "/api/projects/{id}/secrets", handler.HandleProjectSecrets)
+	api.handle("PUT DELETE", "/api/projects/{id}/secrets/{secretId}", handler.HandleProjectSecret)
+	api.handle("GET POST DELETE", "/api/projects/{id}/deploy-key", handler.HandleProjectDeployKey) // SSH-Deploy-Key (siehe deploykeys.go)
+
+	// Releases mit von RALPH geschriebenen Release Notes
+	api.handle("GET", "/api/projects/{id}/releases", handler.HandleProjectReleases)
+	api.handle("POST", "/api/projects/{id}/release", handler.HandleProjectRelease)
+	api.handle("GET POST", "/api/projects/{id}/version", handler.HandleProjectVersion) // Semver-Vorschlag bzw. Bump mit Tag
+
+	// Deploy-Befehle nach dem Push und deren Historie
+	api.handle("GET", "/api/projects/{id}/deployments", handler.HandleProjectDeployments)
+	api.handle("POST", "/api/projects/{id}/deployments/{deploymentId}/rollback", handler.HandleRollbackDeployment)
+
+	// Deploy-Umgebungen (z.B. staging, prod) mit eigenen Deploy-Befehlen
+	api.handle("GET POST", "/api/projects/{id}/environments", handler.HandleProjectEnvironments)
+	api.handle("PUT DELETE", "/api/projects/{id}/environments/{envId}", handler.HandleProjectEnvironment)
+
+	// MCP-Server, die Claude in den Tasks des Projekts nutzen kann
+	api.handle("GET POST", "/api/projects/{id}/mcp-servers", handler.HandleProjectMCPServers)
+	api.handle("PUT DELETE", "/api/projects/{id}/mcp-servers/{serverId}", handler.HandleProjectMCPServer)
 
 	// Task-Typ-Routen: CRUD für Task-Kategorien
-	mux.HandleFunc("/api/task-types", handler.HandleTaskTypes)
-	mux.HandleFunc("/api/task-types/", handler.HandleTaskType)
+	api.handle("GET POST", "/api/task-types", handler.HandleTaskTypes)
+	api.handle("GET PUT DELETE", "/api/task-types/{id}", handler.HandleTaskType)
+
+	// Label-Routen: Freie Schlagworte für Tasks
+	api.handle("GET POST", "/api/labels", handler.HandleLabels)
+	api.handle("GET PUT DELETE", "/api/labels/{id}", handler.HandleLabel)
+
+	// Board-Spalten-Routen: eigene Status, Reihenfolge und WIP-Limits
+	api.handle("GET POST PUT", "/api/columns", handler.HandleBoardColumns)
+	api.handle("GET PUT DELETE", "/api/columns/{status}", handler.HandleBoardColumn)
+
+	// Board-Routen: mehrere Boards mit eigenen Spalten, Projekten und Queue
+	api.handle("GET POST", "/api/boards", handler.HandleBoards)
+	api.handle("GET PUT DELETE", "/api/boards/{id}", handler.HandleBoard)
+	api.handle("GET POST", "/api/boards/{id}/tasks", handler.HandleBoardTasks)
+	api.handle("GET", "/api/boards/{id}/projects", handler.HandleBoardProjects)
+	api.handle("GET POST PUT", "/api/boards/{id}/columns", handler.HandleBoardColumnsOfBoard)
 
 	// WebSocket-Route: Echtzeit-Kommunikation
 	mux.HandleFunc("/ws", hub.ServeWs)
 
 	// Statische Dateien: Frontend-Assets (HTML, CSS, JS)
-	staticFS := http.FileServer(http.Dir("static"))
+	staticFS := http.FileServer(staticFileSystem())
 	mux.Handle("/", staticFS)
 
 	// HTTP-Server konfigurieren
 	server := &http.Server{
 		Addr:         ":" + port,
-		Handler:      corsMiddleware(mux), // CORS-Middleware für lokale Entwicklung
+		Handler:      proxyMiddleware(requestMiddleware(corsMiddleware(handler.authMiddleware(mux)))), // Reverse-Proxy-Header, Request-IDs, CORS-Allowlist und Anmeldung
 		ReadTimeout:  15 * time.Second,    // Timeout für Request-Lesen
 		WriteTimeout: 15 * time.Second,    // Timeout für Response-Schreiben
 		IdleTimeout:  60 * time.Second,    // Timeout für Keep-Alive-Verbindungen
 	}
 
+	// TLS: Zertifikatsdateien oder autocert, sonst reines HTTP
+	if err := serverConfig.configureTLS(server); err != nil {
+		fatal("Invalid TLS configuration", "err", err)
+	}
+	scheme := "http"
+	if serverConfig.tlsEnabled() {
+		scheme = "https"
+	}
+
 	// Print startup banner
 	fmt.Println()
 	fmt.Println("  FORGE v" + Version)
-	fmt.Printf("  Server running on http://localhost:%s\n", port)
+	fmt.Printf("  Server running on %s://localhost:%s\n", scheme, port)
 	fmt.Println()
 
 	// Server in einer Goroutine starten (non-blocking)
 	go func() {
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+		var err error
+		if serverConfig.tlsEnabled() {
+			// Zertifikate kommen aus server.TLSConfig
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			fatal("Server error", "err", err)
 		}
 	}()
 
@@ -195,10 +427,35 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down...")
+	slog.Info("Shutting down")
 
-	// Alle laufenden RALPH-Prozesse stoppen
+	// Laufende Tasks bis zum nächsten Checkpoint weiterarbeiten lassen (FORGE_DRAIN_TIMEOUT),
+	// dann mit WIP-Commit anhalten. Ein zweites Signal stoppt sofort.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeoutFromEnv())
+	go func() {
+		select {
+		case <-quit:
+			slog.Warn("Received second signal, stopping tasks now")
+			cancelDrain()
+		case <-drainCtx.Done():
+		}
+	}()
+	runner.Drain(drainCtx)
+	cancelDrain()
+
+	// Übrige RALPH-Prozesse stoppen
 	runner.StopAll()
+	close(stopBackups)
+	close(stopUploads)
+	close(stopGitStatus)
+	close(stopWatcher)
+	close(stopScans)
+	close(stopCommitIndex)
+	close(stopPRSync)
+	close(stopCISync)
+	close(stopSLACheck)
+	close(stopLogCompaction)
+	close(stopJiraSync)
 
 	// Graceful Shutdown mit Timeout
 	// Gibt laufenden Requests Zeit zum Abschließen
@@ -206,78 +463,141 @@ func main() {
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+		slog.Error("Server shutdown failed", "err", err)
+	}
+
+	slog.Info("FORGE stopped")
+}
+
+// openDatabaseFromEnv opens the database backend selected by environment variables:
+//   - FORGE_DB_DRIVER: sqlite (default) or postgres
+//   - FORGE_DB: SQLite database path (default: forge.db)
+//   - FORGE_DB_DSN: PostgreSQL connection string, required for postgres
+func openDatabaseFromEnv(migrate bool) (*Database, error) {
+	switch driver := os.Getenv("FORGE_DB_DRIVER"); driver {
+	case "", "sqlite", DriverSQLite:
+		dbPath := os.Getenv("FORGE_DB")
+		if dbPath == "" {
+			dbPath = defaultDBPath
+		}
+		return NewDatabase(dbPath, migrate)
+	case DriverPostgres:
+		dsn := os.Getenv("FORGE_DB_DSN")
+		if dsn == "" {
+			return nil, fmt.Errorf("FORGE_DB_DSN is required when FORGE_DB_DRIVER=postgres")
+		}
+		return NewPostgresDatabase(dsn, migrate)
+	default:
+		return nil, fmt.Errorf("unknown FORGE_DB_DRIVER %q (supported: sqlite, postgres)", driver)
+	}
+}
+
+// staticFileSystem returns the frontend assets embedded in the binary.
+// FORGE_STATIC_DIR serves them from disk instead, so frontend changes
+// show up without rebuilding during development.
+func staticFileSystem() http.FileSystem {
+	if dir := os.Getenv("FORGE_STATIC_DIR"); dir != "" {
+		slog.Info("Serving static files from disk", "dir", dir)
+		return http.Dir(dir)
 	}
 
-	log.Println("FORGE stopped")
+	sub, err := fs.Sub(embeddedStatic, "static")
+	if err != nil {
+		fatal("Failed to load embedded static files", "err", err)
+	}
+	return http.FS(sub)
 }
 
 // recoverTasks handles intelligent task recovery on server restart.
 // It checks tasks that have a non-zero PID stored and verifies if the process is still running.
-// If the process is no longer running, the task is marked as blocked.
+// A process that outlived the old server, running or paused, cannot be re-attached, since its
+// output went to the old server's pipes, so it is terminated. Either way the task is marked as
+// blocked and resumable; with FORGE_AUTO_RESUME=true it is queued again with a summary of the work done.
 // After recovery, it tries to start any queued tasks.
-func recoverTasks(db *Database, runner *RalphRunner) {
-	log.Println("Checking for tasks with stored PIDs...")
+func recoverTasks(db Store, runner *RalphRunner) {
+	logger := componentLog("recovery")
+	logger.Info("Checking for tasks with stored PIDs")
 
 	tasks, err := db.GetTasksWithRunningProcess()
 	if err != nil {
-		log.Printf("Warning: Failed to get tasks with PIDs: %v", err)
+		logger.Warn("Failed to get tasks with PIDs", "err", err)
 		return
 	}
 
 	recoveredCount := 0
 	for _, task := range tasks {
-		// Check if the process is still running using signal 0
-		// Signal 0 doesn't send a signal but checks if the process exists
-		process, err := os.FindProcess(task.ProcessPID)
-		if err != nil {
-			// Process not found - mark as blocked
-			log.Printf("Task %s: Process %d not found, marking as blocked", task.ID, task.ProcessPID)
-			db.UpdateTaskStatus(task.ID, StatusBlocked)
-			db.UpdateTaskError(task.ID, "Server restarted - process was terminated")
-			db.UpdateTaskProcessInfo(task.ID, 0, "error")
-			db.UpdateTaskFinishedAt(task.ID)
-			recoveredCount++
-			continue
-		}
-
-		// On Unix, FindProcess always succeeds, so we need to send signal 0 to check
-		err = process.Signal(syscall.Signal(0))
-		if err != nil {
-			// Process no longer exists
-			log.Printf("Task %s: Process %d no longer running, marking as blocked", task.ID, task.ProcessPID)
-			db.UpdateTaskStatus(task.ID, StatusBlocked)
-			db.UpdateTaskError(task.ID, "Server restarted - process was terminated")
-			db.UpdateTaskProcessInfo(task.ID, 0, "error")
-			db.UpdateTaskFinishedAt(task.ID)
-			recoveredCount++
+		reason := "Server restarted - process was terminated. Resume to continue."
+		if isRalphProcess(task.ProcessPID) {
+			// Still running or paused (SIGSTOP): nobody reads its output any more
+			logger.Warn("Terminating process left over from the previous server", "task_id", task.ID, "pid", task.ProcessPID, "process_status", task.ProcessStatus)
+			terminateOrphan(task.ProcessPID)
+			if task.ProcessStatus == ProcessStatusPaused {
+				reason = "Server restarted while the task was paused - process was terminated. Resume to continue."
+			}
 		} else {
-			// Process is still running - this shouldn't happen after a server restart
-			// but we'll leave it as is
-			log.Printf("Task %s: Process %d still running (unexpected)", task.ID, task.ProcessPID)
+			logger.Warn("Process no longer running, marking task as blocked", "task_id", task.ID, "pid", task.ProcessPID)
 		}
+
+		db.UpdateTaskStatus(task.ID, StatusBlocked)
+		db.UpdateTaskError(task.ID, reason)
+		db.UpdateTaskProcessInfo(task.ID, 0, ProcessStatusResumable)
+		db.UpdateTaskFinishedAt(task.ID)
+		recoveredCount++
 	}
 
 	if recoveredCount > 0 {
-		log.Printf("Recovered %d tasks that were interrupted by server restart", recoveredCount)
+		logger.Info("Recovered tasks interrupted by server restart", "count", recoveredCount)
 	}
 
+	// Deploy commands do not outlive the server that ran them
+	if count, err := db.InterruptRunningDeployments(); err != nil {
+		logger.Warn("Failed to mark interrupted deployments", "err", err)
+	} else if count > 0 {
+		logger.Info("Marked deployments interrupted by server restart", "count", count)
+	}
+
+	// So do Claude runs
+	if count, err := db.InterruptRunningTaskRuns(); err != nil {
+		logger.Warn("Failed to mark interrupted runs", "err", err)
+	} else if count > 0 {
+		logger.Info("Marked runs interrupted by server restart", "count", count)
+	}
+
+	// Interrupted tasks (crash or shutdown) go back into the queue if FORGE_AUTO_RESUME is set
+	resumeInterruptedTasks(db, runner.hub)
+
 	// Try to start any queued tasks after recovery
-	go runner.TryStartNextQueued()
+	go runner.TryStartNextQueued(context.Background())
 }
 
-// corsMiddleware fügt CORS-Header für lokale Entwicklung hinzu.
-// Ermöglicht Cross-Origin-Requests vom Frontend während der Entwicklung.
+// corsMiddleware fügt CORS-Header für erlaubte Origins hinzu.
+// Erlaubt sind der eigene Origin, localhost und FORGE_ALLOWED_ORIGINS (siehe originAllowed).
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+
+		// Fremde Origins abweisen, auch bei einfachen POSTs ohne Preflight
+		if !originAllowed(r, origin) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				logFrom(r.Context()).Warn("Rejected cross-origin request", "component", "cors", "method", r.Method, "path", r.URL.Path, "origin", origin)
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// CORS-Header setzen
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		// Preflight-Requests direkt beantworten
 		if r.Method == "OPTIONS" {
-			log.Printf("[CORS] Preflight request: %s", r.URL.Path)
+			logFrom(r.Context()).Debug("Preflight request", "component", "cors", "path", r.URL.Path)
 			w.WriteHeader(http.StatusOK)
 			return
 		}
@@ -0,0 +1,610 @@
+// markdown.go renders task descriptions and comments to sanitized HTML.
+// The renderer supports the Markdown subset people write in tasks (headings,
+// lists, task lists, code, quotes, links, images, emphasis). All text is
+// escaped and only tags generated here reach the output, so the result can be
+// inserted into a page as-is. `attachment:<id or filename>` references resolve
+// to the task's uploaded files, which lets descriptions embed screenshots;
+// `upload:<id>` references to pasted files that are not attached yet resolve to
+// the pending upload.
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// attachmentScheme prefixes references to a task's uploaded files
+const attachmentScheme = "attachment:"
+
+var (
+	mdHeadingRe  = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?[ \t#]*$`)
+	mdBulletRe   = regexp.MustCompile(`^([ \t]*)([-*+])[ \t]+(.*)$`)
+	mdOrderedRe  = regexp.MustCompile(`^([ \t]*)(\d{1,9})[.)][ \t]+(.*)$`)
+	mdRuleRe     = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdURLSchemes = []string{"http://", "https://", "mailto:"}
+)
+
+// markdownRenderer holds the context needed to resolve attachment references
+type markdownRenderer struct {
+	attachments []Attachment
+	baseURL     string                  // Prefix for attachment URLs, e.g. "http://localhost:3333" for clients outside the browser
+	findUpload  func(id string) *Upload // Looks up pending uploads; nil resolves no upload: references
+}
+
+// Render converts Markdown to sanitized HTML
+func (r *markdownRenderer) Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\t", "    ")
+	var b strings.Builder
+	r.blocks(&b, strings.Split(src, "\n"), false)
+	return b.String()
+}
+
+// ============================================================================
+// Blocks
+// ============================================================================
+
+// blocks renders a sequence of lines. In tight mode (list items without blank
+// lines) paragraphs are emitted without <p> wrapper.
+func (r *markdownRenderer) blocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case isFence(trimmed):
+			fence := trimmed[:3]
+			lang := strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1]))
+			var code []string
+			i++
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // Closing fence (or end of text)
+
+			b.WriteString("<pre><code")
+			if lang != "" {
+				b.WriteString(` class="language-` + html.EscapeString(strings.Fields(lang)[0]) + `"`)
+			}
+			b.WriteString(">")
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			b.WriteString("</code></pre>\n")
+
+		case mdHeadingRe.MatchString(trimmed):
+			m := mdHeadingRe.FindStringSubmatch(trimmed)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + r.inline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case mdRuleRe.MatchString(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			r.blocks(b, quoted, false)
+			b.WriteString("</blockquote>\n")
+
+		case isListItem(line):
+			i = r.list(b, lines, i)
+
+		default:
+			var para []string
+			for i < len(lines) && strings.TrimSpace(lines[i]) != "" && (len(para) == 0 || !startsBlock(lines[i])) {
+				para = append(para, strings.TrimSpace(lines[i]))
+				i++
+			}
+			content := r.inlineLines(para)
+			if tight {
+				b.WriteString(content + "\n")
+			} else {
+				b.WriteString("<p>" + content + "</p>\n")
+			}
+		}
+	}
+}
+
+// list renders the list starting at lines[start] and returns the index after it
+func (r *markdownRenderer) list(b *strings.Builder, lines []string, start int) int {
+	indent, ordered, number, _ := listMarker(lines[start])
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if ordered && number != 1 {
+		b.WriteString(` start="` + strconv.Itoa(number) + `"`)
+	}
+	b.WriteString(">\n")
+
+	i := start
+	for i < len(lines) {
+		itemIndent, itemOrdered, _, content := listMarker(lines[i])
+		if itemIndent != indent || itemOrdered != ordered {
+			break
+		}
+
+		// Collect the item's lines: indented continuations and nested lists
+		item := []string{content}
+		tight := true
+		i++
+		for i < len(lines) {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				next := i + 1
+				for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+					next++
+				}
+				if next < len(lines) && leadingSpaces(lines[next]) > indent {
+					tight = false
+					item = append(item, "")
+					i = next
+					continue
+				}
+				break
+			}
+			if leadingSpaces(line) > indent {
+				item = append(item, dedent(line, indent+2))
+				i++
+				continue
+			}
+			if !startsBlock(line) && strings.TrimSpace(item[len(item)-1]) != "" {
+				item = append(item, strings.TrimSpace(line)) // Lazy continuation
+				i++
+				continue
+			}
+			break
+		}
+
+		checkbox := ""
+		if first := item[0]; len(first) >= 3 && first[0] == '[' && first[2] == ']' && (len(first) == 3 || first[3] == ' ') {
+			switch first[1] {
+			case ' ':
+				checkbox = `<input type="checkbox" disabled> `
+			case 'x', 'X':
+				checkbox = `<input type="checkbox" checked disabled> `
+			}
+			if checkbox != "" {
+				item[0] = strings.TrimSpace(first[3:])
+			}
+		}
+
+		if checkbox != "" {
+			b.WriteString(`<li class="task-list-item">` + checkbox)
+		} else {
+			b.WriteString("<li>")
+		}
+		var inner strings.Builder
+		r.blocks(&inner, item, tight)
+		b.WriteString(strings.TrimSuffix(inner.String(), "\n"))
+		b.WriteString("</li>\n")
+
+		// Skip blank lines between items of the same list
+		next := i
+		for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+			next++
+		}
+		if next < len(lines) && next != i && isListItem(lines[next]) {
+			if nextIndent, nextOrdered, _, _ := listMarker(lines[next]); nextIndent == indent && nextOrdered == ordered {
+				i = next
+			}
+		}
+	}
+
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// listMarker parses a list item line into indentation, list kind, number and content
+func listMarker(line string) (indent int, ordered bool, number int, content string) {
+	if m := mdBulletRe.FindStringSubmatch(line); m != nil && !mdRuleRe.MatchString(strings.TrimSpace(line)) {
+		return len(m[1]), false, 0, m[3]
+	}
+	if m := mdOrderedRe.FindStringSubmatch(line); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		return len(m[1]), true, n, m[3]
+	}
+	return -1, false, 0, ""
+}
+
+func isListItem(line string) bool {
+	indent, _, _, _ := listMarker(line)
+	return indent >= 0
+}
+
+func isFence(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// startsBlock reports whether line interrupts a paragraph
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return isFence(trimmed) || mdHeadingRe.MatchString(trimmed) || mdRuleRe.MatchString(trimmed) ||
+		strings.HasPrefix(trimmed, ">") || isListItem(line)
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// dedent removes up to n leading spaces
+func dedent(line string, n int) string {
+	return line[min(n, leadingSpaces(line)):]
+}
+
+// ============================================================================
+// Inlines
+// ============================================================================
+
+// inlineLines renders the lines of a paragraph, keeping line breaks
+func (r *markdownRenderer) inlineLines(lines []string) string {
+	rendered := make([]string, len(lines))
+	for i, line := range lines {
+		rendered[i] = r.inline(line)
+	}
+	return strings.Join(rendered, "<br>\n")
+}
+
+// inline renders emphasis, code spans, links, images and autolinks
+func (r *markdownRenderer) inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!~>|", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			ticks := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			fence := s[i : i+ticks]
+			if end := strings.Index(s[i+ticks:], fence); end >= 0 {
+				code := strings.TrimSpace(s[i+ticks : i+ticks+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += ticks + end + ticks
+				continue
+			}
+			b.WriteString(fence)
+			i += ticks
+			continue
+
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if text, dest, title, end, ok := parseLink(s, i+1); ok {
+				b.WriteString(r.image(text, dest, title))
+				i = end
+				continue
+			}
+
+		case c == '[':
+			if text, dest, title, end, ok := parseLink(s, i); ok {
+				b.WriteString(r.link(text, dest, title))
+				i = end
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if out, end, ok := r.emphasis(s, i); ok {
+				b.WriteString(out)
+				i = end
+				continue
+			}
+
+		case c == 'h' || c == 'm':
+			if (i == 0 || !isWordByte(s[i-1])) && hasURLScheme(s[i:]) {
+				end := i
+				for end < len(s) && s[end] != ' ' && s[end] != '<' {
+					end++
+				}
+				link := strings.TrimRight(s[i:end], ".,;:!?)'\"")
+				if !strings.HasSuffix(link, ":") {
+					b.WriteString(`<a href="` + html.EscapeString(link) + `" target="_blank" rel="noopener noreferrer">` + html.EscapeString(link) + "</a>")
+					i += len(link)
+					continue
+				}
+			}
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// emphasis renders **strong**, *em*, _em_ and ~~del~~ starting at s[i]
+func (r *markdownRenderer) emphasis(s string, i int) (string, int, bool) {
+	c := s[i]
+	delim, tag := s[i:i+1], "em"
+	if i+1 < len(s) && s[i+1] == c {
+		delim, tag = s[i:i+2], "strong"
+	}
+	if c == '~' {
+		if delim != "~~" {
+			return "", 0, false
+		}
+		tag = "del"
+	}
+	// No underscore emphasis inside words (snake_case)
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", 0, false
+	}
+
+	start := i + len(delim)
+	if start >= len(s) || s[start] == ' ' {
+		return "", 0, false
+	}
+	end := strings.Index(s[start:], delim)
+	if end <= 0 || s[start+end-1] == ' ' {
+		return "", 0, false
+	}
+	after := start + end + len(delim)
+	if c == '_' && after < len(s) && isWordByte(s[after]) {
+		return "", 0, false
+	}
+	return "<" + tag + ">" + r.inline(s[start:start+end]) + "</" + tag + ">", after, true
+}
+
+// parseLink parses [text](dest "title") starting at the '[' in s[open]
+func parseLink(s string, open int) (text, dest, title string, end int, ok bool) {
+	depth := 0
+	closeBracket := -1
+	for j := open; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeBracket = j
+			}
+		}
+		if closeBracket >= 0 {
+			break
+		}
+	}
+	if closeBracket < 0 || closeBracket+1 >= len(s) || s[closeBracket+1] != '(' {
+		return "", "", "", 0, false
+	}
+
+	closeParen := -1
+	depth = 0
+	for j := closeBracket + 1; j < len(s); j++ {
+		if s[j] == '(' {
+			depth++
+		} else if s[j] == ')' {
+			depth--
+			if depth == 0 {
+				closeParen = j
+				break
+			}
+		}
+	}
+	if closeParen < 0 {
+		return "", "", "", 0, false
+	}
+
+	target := strings.TrimSpace(s[closeBracket+2 : closeParen])
+	if strings.HasPrefix(target, "<") {
+		if gt := strings.Index(target, ">"); gt > 0 {
+			dest, title = target[1:gt], strings.TrimSpace(target[gt+1:])
+		}
+	} else if sp := strings.IndexAny(target, " \t"); sp >= 0 {
+		dest, title = target[:sp], strings.TrimSpace(target[sp:])
+	} else {
+		dest = target
+	}
+	if len(title) >= 2 && (title[0] == '"' || title[0] == '\'') && title[len(title)-1] == title[0] {
+		title = title[1 : len(title)-1]
+	} else if title != "" {
+		return "", "", "", 0, false
+	}
+	return s[open+1 : closeBracket], dest, title, closeParen + 1, true
+}
+
+// link renders a link; unsafe URLs fall back to the plain text
+func (r *markdownRenderer) link(text, dest, title string) string {
+	href, att, ok := r.resolveURL(dest)
+	if !ok {
+		return r.missing(text, dest)
+	}
+	if text == "" && att != nil {
+		text = att.Filename
+	}
+
+	out := `<a href="` + html.EscapeString(href) + `"`
+	if title != "" {
+		out += ` title="` + html.EscapeString(title) + `"`
+	}
+	if att == nil && !strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "#") {
+		out += ` target="_blank" rel="noopener noreferrer"`
+	}
+	label := r.inline(text)
+	if hasURLScheme(text) {
+		label = html.EscapeString(text) // No nested autolinks
+	}
+	return out + ">" + label + "</a>"
+}
+
+// image renders an image, or a video player for video attachments
+func (r *markdownRenderer) image(alt, dest, title string) string {
+	src, att, ok := r.resolveURL(dest)
+	if !ok {
+		return r.missing(alt, dest)
+	}
+	if att != nil && strings.HasPrefix(att.MimeType, "video/") {
+		return `<video src="` + html.EscapeString(src) + `" controls></video>`
+	}
+
+	out := `<img src="` + html.EscapeString(src) + `" alt="` + html.EscapeString(alt) + `"`
+	if title != "" {
+		out += ` title="` + html.EscapeString(title) + `"`
+	}
+	return out + ` loading="lazy">`
+}
+
+// missing renders the text of a link whose target is unsafe or an unknown attachment
+func (r *markdownRenderer) missing(text, dest string) string {
+	if strings.HasPrefix(dest, attachmentScheme) || strings.HasPrefix(dest, uploadScheme) {
+		return `<span class="md-missing-attachment" title="Attachment not found">` + html.EscapeString(text+" ("+dest+")") + "</span>"
+	}
+	return r.inline(text)
+}
+
+// resolveURL returns the URL for dest, resolving attachment references.
+// Only http(s), mailto and relative URLs are allowed.
+func (r *markdownRenderer) resolveURL(dest string) (string, *Attachment, bool) {
+	if strings.HasPrefix(dest, attachmentScheme) {
+		att := r.findAttachment(strings.TrimPrefix(dest, attachmentScheme))
+		if att == nil {
+			return "", nil, false
+		}
+		return r.baseURL + "/api/tasks/" + url.PathEscape(att.TaskID) + "/attachments/" + url.PathEscape(att.ID), att, true
+	}
+	if strings.HasPrefix(dest, uploadScheme) {
+		if r.findUpload == nil {
+			return "", nil, false
+		}
+		upload := r.findUpload(strings.TrimPrefix(dest, uploadScheme))
+		if upload == nil {
+			return "", nil, false
+		}
+		att := &Attachment{ID: upload.ID, Filename: upload.Filename, MimeType: upload.MimeType}
+		return r.baseURL + "/api/uploads/" + url.PathEscape(upload.ID), att, true
+	}
+
+	if hasURLScheme(dest) {
+		return dest, nil, true
+	}
+	// Relative URLs: no scheme (javascript:, data:, ...) before the first path separator
+	if colon := strings.IndexByte(dest, ':'); colon >= 0 && !strings.ContainsAny(dest[:colon], "/?#") {
+		return "", nil, false
+	}
+	return dest, nil, true
+}
+
+// findAttachment looks up an attachment of the task by ID or filename
+func (r *markdownRenderer) findAttachment(ref string) *Attachment {
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+	for i := range r.attachments {
+		if r.attachments[i].ID == ref {
+			return &r.attachments[i]
+		}
+	}
+	for i := range r.attachments {
+		if strings.EqualFold(r.attachments[i].Filename, ref) {
+			return &r.attachments[i]
+		}
+	}
+	return nil
+}
+
+func hasURLScheme(s string) bool {
+	lower := strings.ToLower(s)
+	for _, scheme := range mdURLSchemes {
+		if strings.HasPrefix(lower, scheme) && len(s) > len(scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// ============================================================================
+// HTTP Handlers
+// ============================================================================
+
+// markdownRendererFor returns a renderer resolving the attachments of taskID
+func (h *Handler) markdownRendererFor(taskID, baseURL string) (*markdownRenderer, error) {
+	r := &markdownRenderer{baseURL: strings.TrimRight(baseURL, "/")}
+	r.findUpload = func(id string) *Upload {
+		upload, _ := h.db.GetUpload(id)
+		return upload
+	}
+	if taskID == "" {
+		return r, nil
+	}
+	attachments, err := h.db.GetAttachmentsByTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	r.attachments = attachments
+	return r, nil
+}
+
+// HandleRenderMarkdown handles POST /api/render
+func (h *Handler) HandleRenderMarkdown(w http.ResponseWriter, r *http.Request) {
+	var req RenderMarkdownRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+
+	renderer, err := h.markdownRendererFor(req.TaskID, req.BaseURL)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get attachments: "+err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, RenderMarkdownResponse{HTML: renderer.Render(req.Markdown)})
+}
+
+// HandleTaskRender handles GET /api/tasks/{id}/render
+func (h *Handler) HandleTaskRender(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+	task, err := h.db.GetTask(taskID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	comments, err := h.db.GetComments(taskID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get comments: "+err.Error())
+		return
+	}
+
+	renderer, err := h.markdownRendererFor(taskID, r.URL.Query().Get("base_url"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get attachments: "+err.Error())
+		return
+	}
+
+	rendered := RenderedTask{
+		TaskID:             taskID,
+		Description:        renderer.Render(task.Description),
+		AcceptanceCriteria: renderer.Render(task.AcceptanceCriteria),
+		Comments:           []RenderedComment{},
+	}
+	for _, c := range comments {
+		rendered.Comments = append(rendered.Comments, RenderedComment{ID: c.ID, HTML: renderer.Render(c.Body)})
+	}
+	h.writeJSON(w, http.StatusOK, rendered)
+}
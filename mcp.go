@@ -0,0 +1,293 @@
+// mcp.go manages the MCP servers of a project, e.g. a database inspector or a
+// browser, that Claude can use as tools in the project's tasks. Before a run
+// the runner writes the enabled servers to a config file readable only by the
+// FORGE user and passes it with --mcp-config; the file is removed when the run
+// ends. ${NAME} in arguments and environment values is replaced by the
+// project secret NAME, so tokens stay encrypted in the database.
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var errMCPServerExists = errors.New("an MCP server with this name already exists")
+
+// mcpServerNamePattern restricts server names, Claude shows them in tool names (mcp__name__tool)
+var mcpServerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// mcpSecretRefPattern matches ${NAME} references to project secrets
+var mcpSecretRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// MCPArgs holds the arguments of an MCP server command, stored as a JSON array
+type MCPArgs []string
+
+// Value stores the arguments as JSON, no arguments as an empty string
+func (a MCPArgs) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal([]string(a))
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads arguments stored by Value
+func (a *MCPArgs) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into MCPArgs", src)
+	}
+	*a = nil
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, (*[]string)(a))
+}
+
+// mcpConfigPath is the config file of a task's run
+func mcpConfigPath(taskID string) string {
+	return filepath.Join(os.TempDir(), "forge-mcp-"+taskID+".json")
+}
+
+// mcpConfigArgs writes the enabled MCP servers of the task's project to its
+// config file and returns the arguments passing it to Claude, none if the
+// project has no enabled servers
+func (r *RalphRunner) mcpConfigArgs(task *Task, cli *ClaudeCapabilities) ([]string, error) {
+	if task.ProjectID == "" {
+		return nil, nil
+	}
+	servers, err := r.db.GetProjectMCPServers(task.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MCP servers: %w", err)
+	}
+
+	config := map[string]map[string]interface{}{}
+	var names []string
+	var secrets map[string]string
+	for _, server := range servers {
+		if !server.Enabled {
+			continue
+		}
+		if secrets == nil {
+			if secrets, err = projectSecretValues(r.db, task.ProjectID); err != nil {
+				return nil, fmt.Errorf("failed to load project secrets for MCP servers: %w", err)
+			}
+		}
+		entry, err := server.configEntry(secrets)
+		if err != nil {
+			return nil, fmt.Errorf("MCP server %s: %w", server.Name, err)
+		}
+		config[server.Name] = entry
+		names = append(names, server.Name)
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if !cli.MCPConfig {
+		return nil, fmt.Errorf("Claude CLI %s does not support --mcp-config, which the MCP servers of the project need; update Claude Code (npm install -g @anthropic-ai/claude-code)", cli.Version)
+	}
+
+	raw, err := json.MarshalIndent(map[string]interface{}{"mcpServers": config}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	path := mcpConfigPath(task.ID)
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write MCP config: %w", err)
+	}
+	r.hub.BroadcastLog(task.ID, "[FORGE] MCP servers: "+strings.Join(names, ", ")+"\n")
+	return []string{"--mcp-config", path}, nil
+}
+
+// configEntry returns the server in the format of Claude's MCP config, with
+// references to project secrets replaced
+func (s *ProjectMCPServer) configEntry(secrets map[string]string) (map[string]interface{}, error) {
+	var missing []string
+	expand := func(value string) string {
+		return mcpSecretRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+			name := mcpSecretRefPattern.FindStringSubmatch(ref)[1]
+			secret, ok := secrets[name]
+			if !ok && !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+			return secret
+		})
+	}
+
+	args := make([]string, len(s.Args))
+	for i, arg := range s.Args {
+		args[i] = expand(arg)
+	}
+	env := make(map[string]string, len(s.Env))
+	for name, value := range s.Env {
+		env[name] = expand(value)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("unknown project secret %s", strings.Join(missing, ", "))
+	}
+	return map[string]interface{}{"type": "stdio", "command": s.Command, "args": args, "env": env}, nil
+}
+
+// projectSecretValues decrypts all secrets of a project, injected or not
+func projectSecretValues(db Store, projectID string) (map[string]string, error) {
+	list, err := db.GetProjectSecrets(projectID)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(list))
+	for _, s := range list {
+		value, err := secretsBox.open(projectID, s.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", s.Name, err)
+		}
+		values[s.Name] = value
+	}
+	return values, nil
+}
+
+// HandleProjectMCPServers handles GET/POST /api/projects/{id}/mcp-servers
+func (h *Handler) HandleProjectMCPServers(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	project, err := h.db.GetProject(projectID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		servers, err := h.db.GetProjectMCPServers(projectID)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get MCP servers: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusOK, servers)
+
+	case http.MethodPost:
+		var req CreateMCPServerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		server := &ProjectMCPServer{
+			ProjectID: projectID,
+			Name:      strings.TrimSpace(req.Name),
+			Command:   strings.TrimSpace(req.Command),
+			Args:      MCPArgs(req.Args),
+			Env:       req.Env,
+			Enabled:   req.Enabled == nil || *req.Enabled,
+		}
+		if err := validateMCPServer(server); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := h.db.CreateProjectMCPServer(server); err != nil {
+			h.writeMCPServerError(w, "create", err)
+			return
+		}
+		h.writeJSON(w, http.StatusCreated, server)
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleProjectMCPServer handles PUT/DELETE /api/projects/{id}/mcp-servers/{serverId}
+func (h *Handler) HandleProjectMCPServer(w http.ResponseWriter, r *http.Request) {
+	server, err := h.db.GetProjectMCPServer(r.PathValue("serverId"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get MCP server: "+err.Error())
+		return
+	}
+	if server == nil || server.ProjectID != r.PathValue("id") {
+		h.writeError(w, http.StatusNotFound, "MCP server not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req UpdateMCPServerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if req.Name != nil {
+			server.Name = strings.TrimSpace(*req.Name)
+		}
+		if req.Command != nil {
+			server.Command = strings.TrimSpace(*req.Command)
+		}
+		if req.Args != nil {
+			server.Args = MCPArgs(*req.Args)
+		}
+		if req.Env != nil {
+			server.Env = *req.Env
+		}
+		if req.Enabled != nil {
+			server.Enabled = *req.Enabled
+		}
+		if err := validateMCPServer(server); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := h.db.UpdateProjectMCPServer(server); err != nil {
+			h.writeMCPServerError(w, "update", err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, server)
+
+	case http.MethodDelete:
+		if err := h.db.DeleteProjectMCPServer(server.ID); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to delete MCP server: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// validateMCPServer checks an MCP server before it is saved
+func validateMCPServer(server *ProjectMCPServer) error {
+	if !mcpServerNamePattern.MatchString(server.Name) {
+		return fmt.Errorf("Name is required (letters, digits, '_' and '-' only)")
+	}
+	if server.Command == "" {
+		return fmt.Errorf("Command is required")
+	}
+	if err := validateTaskEnv(server.Env); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeMCPServerError reports a failed MCP server write with a matching status
+func (h *Handler) writeMCPServerError(w http.ResponseWriter, action string, err error) {
+	if errors.Is(err, errMCPServerExists) {
+		h.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	h.writeError(w, http.StatusInternalServerError, "Failed to "+action+" MCP server: "+err.Error())
+}
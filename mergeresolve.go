@@ -0,0 +1,357 @@
+// mergeresolve.go lets users resolve merge conflicts by hand instead of
+// sending RALPH at them. GET /api/projects/{id}/conflicts shows the merge,
+// rebase, cherry-pick or revert in progress with the base, ours and theirs
+// version of every conflicted file and its conflict hunks. The hunks come
+// from git merge-file in diff3 style, whatever conflict style the user has
+// configured. POST .../conflicts/resolve takes ours, theirs or edited
+// content for a file and stages it; .../continue finishes the operation once
+// no conflicts are left and .../abort gives up on it.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Resolutions of a conflicted file
+const (
+	ResolutionOurs   = "ours"
+	ResolutionTheirs = "theirs"
+	ResolutionManual = "manual"
+)
+
+// Conflict markers of diff3-style merge output
+const (
+	markerOurs   = "<<<<<<<"
+	markerBase   = "|||||||"
+	markerSplit  = "======="
+	markerTheirs = ">>>>>>>"
+)
+
+// errNoGitOperation is returned for conflict actions without an operation in progress
+var errNoGitOperation = errors.New("No merge, rebase, cherry-pick or revert in progress")
+
+// gitOperationMarkers are the files in the git directory that show which
+// operation is in progress, checked in this order
+var gitOperationMarkers = []struct{ file, operation string }{
+	{"rebase-merge", GitOpRebase},
+	{"rebase-apply", GitOpRebase},
+	{"CHERRY_PICK_HEAD", GitOpCherryPick},
+	{"REVERT_HEAD", GitOpRevert},
+	{"MERGE_HEAD", GitOpMerge},
+}
+
+// gitOperation returns the operation in progress in the repository at root, "" if none
+func gitOperation(root string) string {
+	for _, marker := range gitOperationMarkers {
+		cmd := exec.Command("git", "rev-parse", "--git-path", marker.file)
+		cmd.Dir = root
+		output, err := cmd.Output()
+		if err != nil {
+			continue
+		}
+		file := strings.TrimSpace(string(output))
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(root, file)
+		}
+		if _, err := os.Stat(file); err == nil {
+			return marker.operation
+		}
+	}
+	return ""
+}
+
+// ReadConflictState returns the operation in progress and the details of the
+// files that still have conflicts. Paths are relative to the repository root.
+func ReadConflictState(root string) (*ConflictState, error) {
+	state := &ConflictState{Operation: gitOperation(root), Files: []ConflictDetail{}}
+	files, err := GetConflictFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		detail, err := readConflictDetail(root, file.Path)
+		if err != nil {
+			return nil, err
+		}
+		state.Files = append(state.Files, *detail)
+	}
+	return state, nil
+}
+
+// readConflictDetail reads the index stages of a conflicted file: 1 is the
+// base, 2 ours and 3 theirs. A missing stage means that side deleted the file.
+func readConflictDetail(root, path string) (*ConflictDetail, error) {
+	detail := &ConflictDetail{Path: path, Hunks: []ConflictHunk{}}
+	stages := map[int]*string{1: &detail.Base, 2: &detail.Ours, 3: &detail.Theirs}
+	present := map[int]bool{}
+	for stage, content := range stages {
+		cmd := exec.Command("git", "show", fmt.Sprintf(":%d:%s", stage, path))
+		cmd.Dir = root
+		output, err := cmd.Output()
+		if err != nil {
+			continue
+		}
+		present[stage] = true
+		if bytes.IndexByte(output, 0) >= 0 {
+			detail.Binary = true
+		}
+		*content = string(output)
+	}
+	switch {
+	case !present[2]:
+		detail.Deleted = ResolutionOurs
+	case !present[3]:
+		detail.Deleted = ResolutionTheirs
+	}
+
+	if detail.Binary {
+		detail.Base, detail.Ours, detail.Theirs = "", "", ""
+		return detail, nil
+	}
+	if detail.Deleted != "" {
+		return detail, nil
+	}
+	hunks, err := conflictHunks(detail.Ours, detail.Base, detail.Theirs)
+	if err != nil {
+		return nil, err
+	}
+	detail.Hunks = hunks
+	return detail, nil
+}
+
+// conflictHunks merges the three versions with git merge-file and returns
+// the places where they conflict
+func conflictHunks(ours, base, theirs string) ([]ConflictHunk, error) {
+	dir, err := os.MkdirTemp("", "forge-merge-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	names := []string{"ours", "base", "theirs"}
+	for i, content := range []string{ours, base, theirs} {
+		if err := os.WriteFile(filepath.Join(dir, names[i]), []byte(content), 0600); err != nil {
+			return nil, err
+		}
+	}
+	cmd := exec.Command("git", "merge-file", "-p", "--diff3", "-L", "ours", "-L", "base", "-L", "theirs", "ours", "base", "theirs")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	// merge-file exits with the number of conflicts, negative on errors
+	var exitErr *exec.ExitError
+	if err != nil && (!errors.As(err, &exitErr) || exitErr.ExitCode() > 127) {
+		return nil, fmt.Errorf("git merge-file failed: %v", err)
+	}
+	return parseConflictHunks(string(output)), nil
+}
+
+// parseConflictHunks extracts the conflicts of diff3-style merge output
+func parseConflictHunks(merged string) []ConflictHunk {
+	hunks := []ConflictHunk{}
+	var hunk *ConflictHunk
+	var section *strings.Builder
+	var oursText, baseText, theirsText strings.Builder
+	for i, line := range strings.SplitAfter(merged, "\n") {
+		switch {
+		case strings.HasPrefix(line, markerOurs):
+			hunk = &ConflictHunk{Line: i + 1}
+			oursText.Reset()
+			baseText.Reset()
+			theirsText.Reset()
+			section = &oursText
+		case hunk == nil:
+		case strings.HasPrefix(line, markerBase):
+			section = &baseText
+		case strings.HasPrefix(line, markerSplit):
+			section = &theirsText
+		case strings.HasPrefix(line, markerTheirs):
+			hunk.Ours, hunk.Base, hunk.Theirs = oursText.String(), baseText.String(), theirsText.String()
+			hunks = append(hunks, *hunk)
+			hunk = nil
+		default:
+			section.WriteString(line)
+		}
+	}
+	return hunks
+}
+
+// resolveConflictFile stages the resolution of a conflicted file
+func resolveConflictFile(root string, detail *ConflictDetail, req ResolveConflictRequest) error {
+	var steps [][]string
+	switch {
+	case req.Resolution == ResolutionManual:
+		if err := os.WriteFile(filepath.Join(root, detail.Path), []byte(req.Content), 0644); err != nil {
+			return err
+		}
+		steps = [][]string{{"add", "--", detail.Path}}
+	case req.Resolution == detail.Deleted:
+		// The chosen side deleted the file
+		steps = [][]string{{"rm", "--quiet", "--", detail.Path}}
+	default:
+		steps = [][]string{{"checkout", "--" + req.Resolution, "--", detail.Path}, {"add", "--", detail.Path}}
+	}
+	for _, args := range steps {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s failed: %v, output: %s", args[0], err, string(output))
+		}
+	}
+	return nil
+}
+
+// continueGitOperation finishes the operation in progress with its default
+// commit message
+func continueGitOperation(root, operation string) error {
+	args := []string{operation, "--continue"}
+	if operation == GitOpMerge {
+		args = []string{"commit", "--no-edit"}
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+	if output, err := runSigned(cmd); err != nil {
+		return gitFailed(strings.Join(args, " "), err, output)
+	}
+	return nil
+}
+
+// abortGitOperation gives up on the operation in progress
+func abortGitOperation(root, operation string) error {
+	cmd := exec.Command("git", operation, "--abort")
+	cmd.Dir = root
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s --abort failed: %v, output: %s", operation, err, string(output))
+	}
+	return nil
+}
+
+// HandleProjectConflicts handles GET /api/projects/{id}/conflicts
+func (h *Handler) HandleProjectConflicts(w http.ResponseWriter, r *http.Request) {
+	root := h.conflictRoot(w, r)
+	if root == "" {
+		return
+	}
+	state, err := ReadConflictState(root)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read conflicts: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, state)
+}
+
+// HandleProjectConflictAction handles POST /api/projects/{id}/conflicts/{action}
+// resolve stages ours, theirs or manual content for a file, continue finishes
+// the operation and abort gives up on it. All return the new conflict state.
+func (h *Handler) HandleProjectConflictAction(w http.ResponseWriter, r *http.Request) {
+	root := h.conflictRoot(w, r)
+	if root == "" {
+		return
+	}
+	action := r.PathValue("action")
+	if action != "resolve" && action != "continue" && action != "abort" {
+		h.writeError(w, http.StatusNotFound, "Unknown conflict action")
+		return
+	}
+
+	var req ResolveConflictRequest
+	if action == "resolve" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if req.Resolution != ResolutionOurs && req.Resolution != ResolutionTheirs && req.Resolution != ResolutionManual {
+			h.writeError(w, http.StatusBadRequest, "Invalid resolution: "+req.Resolution+" (use ours, theirs or manual)")
+			return
+		}
+	}
+
+	state, status, err := applyConflictAction(root, action, req)
+	if err != nil {
+		h.writeError(w, status, err.Error())
+		return
+	}
+	componentLog("git").Info("Conflict action", "path", root, "action", action, "file", req.Path, "remaining", len(state.Files))
+	h.writeJSON(w, http.StatusOK, state)
+}
+
+// applyConflictAction runs a conflict action and returns the new conflict
+// state, or the error with the HTTP status to report it with
+func applyConflictAction(root, action string, req ResolveConflictRequest) (*ConflictState, int, error) {
+	defer lockRepo(root, "conflict resolution")()
+	defer gitStatus.Invalidate(root)
+
+	state, err := ReadConflictState(root)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to read conflicts: %v", err)
+	}
+	if state.Operation == "" && len(state.Files) == 0 {
+		return nil, http.StatusBadRequest, errNoGitOperation
+	}
+
+	switch action {
+	case "resolve":
+		var detail *ConflictDetail
+		for i := range state.Files {
+			if state.Files[i].Path == req.Path {
+				detail = &state.Files[i]
+			}
+		}
+		if detail == nil {
+			return nil, http.StatusNotFound, errors.New("No conflict in " + req.Path)
+		}
+		err = resolveConflictFile(root, detail, req)
+	case "continue":
+		if state.Operation == "" {
+			return nil, http.StatusBadRequest, errNoGitOperation
+		}
+		if len(state.Files) > 0 {
+			return nil, http.StatusConflict, fmt.Errorf("%d file(s) still have conflicts", len(state.Files))
+		}
+		err = continueGitOperation(root, state.Operation)
+	case "abort":
+		if state.Operation == "" {
+			return nil, http.StatusBadRequest, errNoGitOperation
+		}
+		err = abortGitOperation(root, state.Operation)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to %s: %v", action, err)
+	}
+
+	// A rebase may stop at the next commit with new conflicts
+	state, err = ReadConflictState(root)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to read conflicts: %v", err)
+	}
+	return state, http.StatusOK, nil
+}
+
+// conflictRoot returns the repository root of the request's project, or
+// writes the error and returns "". Conflicts concern the whole repository,
+// also for projects in a subdirectory.
+func (h *Handler) conflictRoot(w http.ResponseWriter, r *http.Request) string {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return ""
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return ""
+	}
+	root := gitRoot(project.Path)
+	if root == "" {
+		h.writeError(w, http.StatusBadRequest, "Project is not a git repository")
+		return ""
+	}
+	return root
+}
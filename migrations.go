@@ -0,0 +1,1359 @@
+// migrations.go implements the versioned schema migrations for FORGE.
+// Every migration has ordered up and down steps and is applied in its own
+// transaction together with its schema_version entry, so a failing step
+// leaves the database at the previous version.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// migrationStep is a single schema change inside a migration
+type migrationStep struct {
+	desc string // SQL statement (or equivalent) shown in dry runs
+	run  func(tx *sqlTx) error
+}
+
+// Migration is a versioned, reversible schema change
+type Migration struct {
+	Version     int
+	Description string
+	Up          []migrationStep
+	Down        []migrationStep
+}
+
+// sqlStep executes a single SQL statement
+func sqlStep(query string) migrationStep {
+	query = strings.TrimSpace(query)
+	return migrationStep{
+		desc: query,
+		run: func(tx *sqlTx) error {
+			_, err := tx.Exec(query)
+			return err
+		},
+	}
+}
+
+// addColumnStep adds a column unless it already exists.
+// Databases migrated by older FORGE versions may already have some of these columns.
+func addColumnStep(table, column, def string) migrationStep {
+	query := "ALTER TABLE " + table + " ADD COLUMN " + column + " " + def
+	return migrationStep{
+		desc: query,
+		run: func(tx *sqlTx) error {
+			exists, err := columnExists(tx, table, column)
+			if err != nil || exists {
+				return err
+			}
+			_, err = tx.Exec(query)
+			return err
+		},
+	}
+}
+
+// dropColumnStep drops a column if it exists
+func dropColumnStep(table, column string) migrationStep {
+	query := "ALTER TABLE " + table + " DROP COLUMN " + column
+	return migrationStep{
+		desc: query,
+		run: func(tx *sqlTx) error {
+			exists, err := columnExists(tx, table, column)
+			if err != nil || !exists {
+				return err
+			}
+			_, err = tx.Exec(query)
+			return err
+		},
+	}
+}
+
+// columnExists checks the table schema for a column
+func columnExists(tx *sqlTx, table, column string) (bool, error) {
+	var count int
+	var err error
+	if tx.dialect == DriverPostgres {
+		err = tx.QueryRow(`SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`, table, column).Scan(&count)
+	} else {
+		err = tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	}
+	return count > 0, err
+}
+
+// migrations lists all schema migrations in ascending order.
+// Versions up to postgresSchemaVersion only run on SQLite; PostgreSQL starts
+// with the equivalent schema from postgres.go. Newer migrations must be portable.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Add projects, task types and branch protection",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS projects (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				path TEXT NOT NULL UNIQUE,
+				description TEXT DEFAULT '',
+				is_auto_detected INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`),
+			sqlStep(`CREATE TABLE IF NOT EXISTS task_types (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL UNIQUE,
+				color TEXT NOT NULL,
+				is_system INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`),
+			sqlStep(`CREATE TABLE IF NOT EXISTS branch_protection_rules (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL,
+				branch_pattern TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+				UNIQUE(project_id, branch_pattern)
+			)`),
+			sqlStep(`INSERT OR IGNORE INTO task_types (id, name, color, is_system, created_at) VALUES
+				('type-feature', 'Feature', '#3fb950', 1, CURRENT_TIMESTAMP),
+				('type-bug', 'Bug', '#f85149', 1, CURRENT_TIMESTAMP),
+				('type-refactor', 'Refactor', '#d29922', 1, CURRENT_TIMESTAMP),
+				('type-test', 'Test', '#58a6ff', 1, CURRENT_TIMESTAMP)`),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS branch_protection_rules"),
+			sqlStep("DROP TABLE IF EXISTS task_types"),
+			sqlStep("DROP TABLE IF EXISTS projects"),
+		},
+	},
+	{
+		Version:     2,
+		Description: "Add project, task type and branch columns",
+		Up: []migrationStep{
+			addColumnStep("tasks", "project_id", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "task_type_id", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "working_branch", "TEXT DEFAULT ''"),
+			addColumnStep("config", "projects_base_dir", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "projects_base_dir"),
+			dropColumnStep("tasks", "working_branch"),
+			dropColumnStep("tasks", "task_type_id"),
+			dropColumnStep("tasks", "project_id"),
+		},
+	},
+	{
+		Version:     3,
+		Description: "Add GitHub token to config",
+		Up: []migrationStep{
+			addColumnStep("config", "github_token", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "github_token"),
+		},
+	},
+	{
+		Version:     4,
+		Description: "Add extended settings to config",
+		Up: []migrationStep{
+			addColumnStep("config", "auto_commit", "INTEGER DEFAULT 0"),
+			addColumnStep("config", "auto_push", "INTEGER DEFAULT 0"),
+			addColumnStep("config", "default_branch", "TEXT DEFAULT 'main'"),
+			addColumnStep("config", "default_priority", "INTEGER DEFAULT 2"),
+			addColumnStep("config", "auto_archive_days", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "auto_archive_days"),
+			dropColumnStep("config", "default_priority"),
+			dropColumnStep("config", "default_branch"),
+			dropColumnStep("config", "auto_push"),
+			dropColumnStep("config", "auto_commit"),
+		},
+	},
+	{
+		Version:     5,
+		Description: "Add conflict PR tracking to tasks",
+		Up: []migrationStep{
+			addColumnStep("tasks", "conflict_pr_url", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "conflict_pr_number", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "conflict_pr_number"),
+			dropColumnStep("tasks", "conflict_pr_url"),
+		},
+	},
+	{
+		Version:     6,
+		Description: "Create attachments table",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS attachments (
+				id TEXT PRIMARY KEY,
+				task_id TEXT NOT NULL,
+				filename TEXT NOT NULL,
+				mime_type TEXT NOT NULL,
+				size INTEGER NOT NULL,
+				path TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_attachments_task_id ON attachments(task_id)"),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP INDEX IF EXISTS idx_attachments_task_id"),
+			sqlStep("DROP TABLE IF EXISTS attachments"),
+		},
+	},
+	{
+		Version:     7,
+		Description: "Add queue and process tracking to tasks",
+		Up: []migrationStep{
+			addColumnStep("tasks", "queue_position", "INTEGER DEFAULT 0"),
+			addColumnStep("tasks", "process_pid", "INTEGER DEFAULT 0"),
+			addColumnStep("tasks", "process_status", "TEXT DEFAULT 'idle'"),
+			addColumnStep("tasks", "started_at", "DATETIME"),
+			addColumnStep("tasks", "finished_at", "DATETIME"),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_tasks_queue ON tasks(status, queue_position) WHERE status = 'queued'"),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP INDEX IF EXISTS idx_tasks_queue"),
+			dropColumnStep("tasks", "finished_at"),
+			dropColumnStep("tasks", "started_at"),
+			dropColumnStep("tasks", "process_status"),
+			dropColumnStep("tasks", "process_pid"),
+			dropColumnStep("tasks", "queue_position"),
+		},
+	},
+	{
+		Version:     8,
+		Description: "Add trunk-based development fields",
+		Up: []migrationStep{
+			addColumnStep("projects", "working_branch", "TEXT DEFAULT ''"),
+			addColumnStep("config", "push_strategy", "TEXT DEFAULT 'manual'"),
+			addColumnStep("tasks", "rollback_tag", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "commit_hash", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "commit_hash"),
+			dropColumnStep("tasks", "rollback_tag"),
+			dropColumnStep("config", "push_strategy"),
+			dropColumnStep("projects", "working_branch"),
+		},
+	},
+	{
+		Version:     9,
+		Description: "Add continue message to tasks",
+		Up: []migrationStep{
+			addColumnStep("tasks", "continue_message", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "continue_message"),
+		},
+	},
+	{
+		Version:     10,
+		Description: "Add target branch to tasks",
+		Up: []migrationStep{
+			addColumnStep("tasks", "target_branch", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "target_branch"),
+		},
+	},
+	{
+		Version:     11,
+		Description: "Create board columns for custom statuses",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS board_columns (
+				status TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				color TEXT NOT NULL DEFAULT '#808080',
+				sort_order INTEGER DEFAULT 0,
+				wip_limit INTEGER DEFAULT 0,
+				role TEXT DEFAULT '',
+				is_system INTEGER DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`),
+			sqlStep(`INSERT OR IGNORE INTO board_columns (status, name, color, sort_order, role, is_system) VALUES
+				('backlog', 'Backlog', '#8b949e', 1, '', 1),
+				('queued', 'Queue', '#a371f7', 2, 'queue', 1),
+				('progress', 'In Progress', '#58a6ff', 3, 'progress', 1),
+				('review', 'Review', '#d29922', 4, '', 1),
+				('done', 'Done', '#3fb950', 5, 'terminal', 1),
+				('blocked', 'Blocked', '#f85149', 6, '', 1)`),
+		},
+		Down: []migrationStep{
+			// Tasks in eigenen Spalten wären ohne die Tabelle unsichtbar
+			sqlStep(`UPDATE tasks SET status = 'backlog', queue_position = 0
+				WHERE status NOT IN ('backlog', 'queued', 'progress', 'review', 'done', 'blocked')`),
+			sqlStep("DROP TABLE IF EXISTS board_columns"),
+		},
+	},
+	{
+		Version:     12,
+		Description: "Add queue policy to config",
+		Up: []migrationStep{
+			addColumnStep("config", "queue_policy", "TEXT DEFAULT 'fifo'"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "queue_policy"),
+		},
+	},
+	{
+		Version:     13,
+		Description: "Create labels and task labels",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS labels (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL UNIQUE,
+				color TEXT NOT NULL DEFAULT '#808080',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`),
+			sqlStep(`CREATE TABLE IF NOT EXISTS task_labels (
+				task_id TEXT NOT NULL,
+				label_id TEXT NOT NULL,
+				PRIMARY KEY (task_id, label_id)
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_task_labels_label ON task_labels(label_id)"),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS task_labels"),
+			sqlStep("DROP TABLE IF EXISTS labels"),
+		},
+	},
+	{
+		Version:     14,
+		Description: "Create task comments",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS task_comments (
+				id TEXT PRIMARY KEY,
+				task_id TEXT NOT NULL,
+				author TEXT DEFAULT '',
+				body TEXT NOT NULL,
+				sent_to_ralph INTEGER DEFAULT 0,
+				sent_at TIMESTAMP NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_task_comments_task_id ON task_comments(task_id)"),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP INDEX IF EXISTS idx_task_comments_task_id"),
+			sqlStep("DROP TABLE IF EXISTS task_comments"),
+		},
+	},
+	{
+		Version:     15,
+		Description: "Add attachment thumbnails",
+		Up: []migrationStep{
+			addColumnStep("attachments", "thumbnail_path", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("attachments", "thumbnail_path"),
+		},
+	},
+	{
+		Version:     16,
+		Description: "Add attachment type allowlist",
+		Up: []migrationStep{
+			addColumnStep("config", "attachment_types", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "attachment_types"),
+		},
+	},
+	{
+		Version:     17,
+		Description: "Create pending uploads",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS pending_uploads (
+				id TEXT PRIMARY KEY,
+				filename TEXT NOT NULL,
+				mime_type TEXT NOT NULL,
+				size INTEGER NOT NULL,
+				path TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_pending_uploads_created_at ON pending_uploads(created_at)"),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP INDEX IF EXISTS idx_pending_uploads_created_at"),
+			sqlStep("DROP TABLE IF EXISTS pending_uploads"),
+		},
+	},
+	{
+		Version:     18,
+		Description: "Create task commits",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS task_commits (
+				task_id TEXT NOT NULL,
+				hash TEXT NOT NULL,
+				project_id TEXT DEFAULT '',
+				subject TEXT DEFAULT '',
+				author TEXT DEFAULT '',
+				committed_at TIMESTAMP NULL,
+				PRIMARY KEY (task_id, hash)
+			)`),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS task_commits"),
+		},
+	},
+	{
+		Version:     19,
+		Description: "Add branch-per-task workflow",
+		Up: []migrationStep{
+			addColumnStep("projects", "workflow", "TEXT DEFAULT 'trunk'"),
+			addColumnStep("tasks", "pr_url", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "pr_number", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "pr_number"),
+			dropColumnStep("tasks", "pr_url"),
+			dropColumnStep("projects", "workflow"),
+		},
+	},
+	{
+		Version:     20,
+		Description: "Add GitHub issue sync",
+		Up: []migrationStep{
+			addColumnStep("projects", "issue_sync", "INTEGER DEFAULT 0"),
+			addColumnStep("tasks", "issue_url", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "issue_number", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "issue_number"),
+			dropColumnStep("tasks", "issue_url"),
+			dropColumnStep("projects", "issue_sync"),
+		},
+	},
+	{
+		Version:     21,
+		Description: "Add Jira integration",
+		Up: []migrationStep{
+			addColumnStep("config", "jira_url", "TEXT DEFAULT ''"),
+			addColumnStep("config", "jira_user", "TEXT DEFAULT ''"),
+			addColumnStep("config", "jira_token", "TEXT DEFAULT ''"),
+			addColumnStep("projects", "jira_project_key", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "jira_key", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "jira_synced_status", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "jira_synced_status"),
+			dropColumnStep("tasks", "jira_key"),
+			dropColumnStep("projects", "jira_project_key"),
+			dropColumnStep("config", "jira_token"),
+			dropColumnStep("config", "jira_user"),
+			dropColumnStep("config", "jira_url"),
+		},
+	},
+	{
+		Version:     22,
+		Description: "Add Linear integration",
+		Up: []migrationStep{
+			addColumnStep("config", "linear_token", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "linear_id", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "linear_identifier", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "linear_url", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "linear_url"),
+			dropColumnStep("tasks", "linear_identifier"),
+			dropColumnStep("tasks", "linear_id"),
+			dropColumnStep("config", "linear_token"),
+		},
+	},
+	{
+		Version:     23,
+		Description: "Add pre-push hook for branch protection",
+		Up: []migrationStep{
+			addColumnStep("projects", "push_hook", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("projects", "push_hook"),
+		},
+	},
+	{
+		Version:     24,
+		Description: "Create project secrets",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS project_secrets (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL,
+				name TEXT NOT NULL,
+				value TEXT NOT NULL,
+				inject INTEGER DEFAULT 1,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE (project_id, name)
+			)`),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS project_secrets"),
+		},
+	},
+	{
+		Version:     25,
+		Description: "Add task environment and working directory",
+		Up: []migrationStep{
+			addColumnStep("tasks", "env", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "work_dir", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "work_dir"),
+			dropColumnStep("tasks", "env"),
+		},
+	},
+	{
+		Version:     26,
+		Description: "Create releases",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS releases (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL,
+				tag TEXT NOT NULL,
+				name TEXT DEFAULT '',
+				previous_tag TEXT DEFAULT '',
+				notes TEXT DEFAULT '',
+				commit_count INTEGER DEFAULT 0,
+				commit_hash TEXT DEFAULT '',
+				task_id TEXT DEFAULT '',
+				status TEXT DEFAULT 'drafting',
+				error TEXT DEFAULT '',
+				github_url TEXT DEFAULT '',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_releases_project ON releases(project_id)"),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS releases"),
+		},
+	},
+	{
+		Version:     27,
+		Description: "Add deploy commands and deployment history",
+		Up: []migrationStep{
+			addColumnStep("projects", "deploy_command", "TEXT DEFAULT ''"),
+			addColumnStep("projects", "deploy_timeout", "INTEGER DEFAULT 0"),
+			sqlStep(`CREATE TABLE IF NOT EXISTS deployments (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL,
+				task_id TEXT DEFAULT '',
+				commit_hash TEXT DEFAULT '',
+				command TEXT NOT NULL,
+				status TEXT DEFAULT 'running',
+				exit_code INTEGER DEFAULT 0,
+				output TEXT DEFAULT '',
+				started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				finished_at TIMESTAMP
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_deployments_project ON deployments(project_id)"),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS deployments"),
+			dropColumnStep("projects", "deploy_timeout"),
+			dropColumnStep("projects", "deploy_command"),
+		},
+	},
+	{
+		Version:     28,
+		Description: "Add deploy environments",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS project_environments (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL,
+				name TEXT NOT NULL,
+				deploy_command TEXT DEFAULT '',
+				deploy_timeout INTEGER DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE(project_id, name)
+			)`),
+			addColumnStep("deployments", "environment", "TEXT DEFAULT ''"),
+			addColumnStep("deployments", "rollback_of", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("deployments", "rollback_of"),
+			dropColumnStep("deployments", "environment"),
+			sqlStep("DROP TABLE IF EXISTS project_environments"),
+		},
+	},
+	{
+		Version:     29,
+		Description: "Add CI status of tasks",
+		Up: []migrationStep{
+			addColumnStep("tasks", "ci_status", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "ci_url", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "ci_fix_attempts", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "ci_fix_attempts"),
+			dropColumnStep("tasks", "ci_url"),
+			dropColumnStep("tasks", "ci_status"),
+		},
+	},
+	{
+		Version:     30,
+		Description: "Create task conflicts",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS task_conflicts (
+				id TEXT PRIMARY KEY,
+				task_id TEXT NOT NULL,
+				resolution_task_id TEXT DEFAULT '',
+				stage TEXT NOT NULL,
+				branch TEXT DEFAULT '',
+				upstream TEXT DEFAULT '',
+				upstream_commit TEXT DEFAULT '',
+				files TEXT DEFAULT '',
+				status TEXT DEFAULT 'open',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				resolved_at TIMESTAMP
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_task_conflicts_task ON task_conflicts(task_id)"),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_task_conflicts_resolution ON task_conflicts(resolution_task_id)"),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS task_conflicts"),
+		},
+	},
+	{
+		Version:     31,
+		Description: "Create path protection rules",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS path_protection_rules (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL,
+				path_pattern TEXT NOT NULL,
+				action TEXT DEFAULT 'revert',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE(project_id, path_pattern)
+			)`),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS path_protection_rules"),
+		},
+	},
+	{
+		Version:     32,
+		Description: "Add task plans",
+		Up: []migrationStep{
+			addColumnStep("tasks", "plan", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "plan_status", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "plan_status"),
+			dropColumnStep("tasks", "plan"),
+		},
+	},
+	{
+		Version:     33,
+		Description: "Add subtasks and task dependencies",
+		Up: []migrationStep{
+			addColumnStep("tasks", "parent_id", "TEXT DEFAULT ''"),
+			sqlStep(`CREATE TABLE IF NOT EXISTS task_dependencies (
+				task_id TEXT NOT NULL,
+				depends_on_id TEXT NOT NULL,
+				PRIMARY KEY (task_id, depends_on_id)
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_task_dependencies_depends_on ON task_dependencies(depends_on_id)"),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS task_dependencies"),
+			dropColumnStep("tasks", "parent_id"),
+		},
+	},
+	{
+		Version:     34,
+		Description: "Add task status history and column SLAs",
+		Up: []migrationStep{
+			addColumnStep("board_columns", "sla_hours", "INTEGER DEFAULT 0"),
+			sqlStep(`CREATE TABLE IF NOT EXISTS task_events (
+				id TEXT PRIMARY KEY,
+				task_id TEXT NOT NULL,
+				status TEXT NOT NULL,
+				alerted INTEGER DEFAULT 0,
+				created_at TIMESTAMP NOT NULL
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_task_events_task_id ON task_events(task_id)"),
+			// Bestehende Tasks sind seit ihrer letzten Änderung in ihrem Status
+			sqlStep(`INSERT INTO task_events (id, task_id, status, created_at)
+				SELECT 'migrated-' || id, id, status, COALESCE(updated_at, created_at) FROM tasks`),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS task_events"),
+			dropColumnStep("board_columns", "sla_hours"),
+		},
+	},
+	{
+		Version:     35,
+		Description: "Add Claude runner settings",
+		Up: []migrationStep{
+			addColumnStep("config", "claude_settings", "TEXT DEFAULT ''"),
+			addColumnStep("projects", "claude_settings", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("projects", "claude_settings"),
+			dropColumnStep("config", "claude_settings"),
+		},
+	},
+	{
+		Version:     36,
+		Description: "Create project MCP servers",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS project_mcp_servers (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL,
+				name TEXT NOT NULL,
+				command TEXT NOT NULL,
+				args TEXT DEFAULT '',
+				env TEXT DEFAULT '',
+				enabled INTEGER DEFAULT 1,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE (project_id, name)
+			)`),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS project_mcp_servers"),
+		},
+	},
+	{
+		Version:     37,
+		Description: "Add project screenshot verification",
+		Up: []migrationStep{
+			addColumnStep("projects", "screenshot_url", "TEXT DEFAULT ''"),
+			addColumnStep("projects", "screenshot_command", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("projects", "screenshot_command"),
+			dropColumnStep("projects", "screenshot_url"),
+		},
+	},
+	{
+		Version:     38,
+		Description: "Add task log retention settings",
+		Up: []migrationStep{
+			addColumnStep("config", "log_retention", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "log_retention"),
+		},
+	},
+	{
+		Version:     39,
+		Description: "Add queue pause state",
+		Up: []migrationStep{
+			addColumnStep("config", "queue_paused", "INTEGER DEFAULT 0"),
+			addColumnStep("config", "queue_pause_reason", "TEXT DEFAULT ''"),
+			addColumnStep("config", "queue_paused_at", "TIMESTAMP"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "queue_paused_at"),
+			dropColumnStep("config", "queue_pause_reason"),
+			dropColumnStep("config", "queue_paused"),
+		},
+	},
+	{
+		Version:     40,
+		Description: "Add per-project queue lanes",
+		Up: []migrationStep{
+			addColumnStep("config", "max_concurrent_tasks", "INTEGER DEFAULT 1"),
+			addColumnStep("tasks", "queued_at", "TIMESTAMP"),
+			sqlStep("UPDATE tasks SET queued_at = updated_at WHERE queue_position > 0"),
+			renumberQueueStep(true),
+		},
+		Down: []migrationStep{
+			renumberQueueStep(false),
+			dropColumnStep("tasks", "queued_at"),
+			dropColumnStep("config", "max_concurrent_tasks"),
+		},
+	},
+	{
+		Version:     41,
+		Description: "Add priority aging",
+		Up: []migrationStep{
+			addColumnStep("config", "priority_aging_hours", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "priority_aging_hours"),
+		},
+	},
+	{
+		Version:     42,
+		Description: "Add task run history",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS task_runs (
+				id TEXT PRIMARY KEY,
+				task_id TEXT NOT NULL,
+				run_number INTEGER NOT NULL,
+				run_trigger TEXT DEFAULT '',
+				outcome TEXT DEFAULT '',
+				error TEXT DEFAULT '',
+				iterations INTEGER DEFAULT 0,
+				start_commit TEXT DEFAULT '',
+				end_commit TEXT DEFAULT '',
+				turns INTEGER DEFAULT 0,
+				input_tokens BIGINT DEFAULT 0,
+				output_tokens BIGINT DEFAULT 0,
+				cost_usd DOUBLE PRECISION DEFAULT 0,
+				logs TEXT DEFAULT '',
+				started_at TIMESTAMP NOT NULL,
+				finished_at TIMESTAMP
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_task_runs_task_id ON task_runs(task_id)"),
+			// Vom bisher einzigen Lauf eines Tasks sind nur Start und Ende bekannt
+			sqlStep(`INSERT INTO task_runs (id, task_id, run_number, run_trigger, iterations, started_at, finished_at)
+				SELECT 'migrated-' || id, id, 1, 'start', current_iteration, started_at, finished_at FROM tasks
+				WHERE started_at IS NOT NULL AND finished_at IS NOT NULL AND finished_at > started_at`),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS task_runs"),
+		},
+	},
+	{
+		Version:     43,
+		Description: "Add run transcripts",
+		Up: []migrationStep{
+			addColumnStep("task_runs", "messages", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("task_runs", "messages"),
+		},
+	},
+	{
+		Version:     44,
+		Description: "Add blocked task triage",
+		Up: []migrationStep{
+			addColumnStep("tasks", "triage", "TEXT DEFAULT ''"),
+			addColumnStep("config", "blocked_triage", "INTEGER DEFAULT 1"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "triage"),
+			dropColumnStep("config", "blocked_triage"),
+		},
+	},
+	{
+		Version:     45,
+		Description: "Add retries after transient failures",
+		Up: []migrationStep{
+			addColumnStep("tasks", "retry_at", "TIMESTAMP"),
+			addColumnStep("config", "retry_policy", `TEXT DEFAULT '{"max_retries":2,"backoff_seconds":60}'`),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "retry_at"),
+			dropColumnStep("config", "retry_policy"),
+		},
+	},
+	{
+		Version:     46,
+		Description: "Add failure classes of runs and task errors",
+		Up: []migrationStep{
+			addColumnStep("tasks", "error_class", "TEXT DEFAULT ''"),
+			addColumnStep("task_runs", "failure_class", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "error_class"),
+			dropColumnStep("task_runs", "failure_class"),
+		},
+	},
+	{
+		Version:     47,
+		Description: "Add queue cooldown after rate limits",
+		Up: []migrationStep{
+			addColumnStep("config", "queue_cooldown_until", "TIMESTAMP"),
+			addColumnStep("config", "queue_cooldown_reason", "TEXT DEFAULT ''"),
+			addColumnStep("config", "queue_cooldown_level", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "queue_cooldown_until"),
+			dropColumnStep("config", "queue_cooldown_reason"),
+			dropColumnStep("config", "queue_cooldown_level"),
+		},
+	},
+	{
+		Version:     48,
+		Description: "Add cost budgets",
+		Up: []migrationStep{
+			addColumnStep("config", "budget", "TEXT DEFAULT ''"),
+			addColumnStep("projects", "budget", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "budget"),
+			dropColumnStep("projects", "budget"),
+		},
+	},
+	{
+		Version:     49,
+		Description: "Add share link signing key",
+		Up: []migrationStep{
+			addColumnStep("config", "share_key", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "share_key"),
+		},
+	},
+	{
+		Version:     50,
+		Description: "Create boards",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS boards (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				description TEXT DEFAULT '',
+				max_concurrent_tasks INTEGER DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`),
+			sqlStep(`INSERT OR IGNORE INTO boards (id, name) VALUES ('default', 'Default')`),
+			addColumnStep("tasks", "board_id", "TEXT DEFAULT 'default'"),
+			addColumnStep("projects", "board_id", "TEXT DEFAULT 'default'"),
+			// System-Spalten gehören allen Boards, eigene Spalten bisher dem einzigen Board
+			addColumnStep("board_columns", "board_id", "TEXT DEFAULT ''"),
+			sqlStep("UPDATE board_columns SET board_id = 'default' WHERE is_system = 0"),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_tasks_board_id ON tasks(board_id)"),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP INDEX IF EXISTS idx_tasks_board_id"),
+			dropColumnStep("tasks", "board_id"),
+			dropColumnStep("projects", "board_id"),
+			dropColumnStep("board_columns", "board_id"),
+			sqlStep("DROP TABLE IF EXISTS boards"),
+		},
+	},
+	{
+		Version:     51,
+		Description: "Create users and sessions",
+		Up: []migrationStep{
+			sqlStep(`CREATE TABLE IF NOT EXISTS users (
+				id TEXT PRIMARY KEY,
+				github_id BIGINT NOT NULL UNIQUE,
+				login TEXT NOT NULL,
+				name TEXT DEFAULT '',
+				avatar_url TEXT DEFAULT '',
+				github_token TEXT DEFAULT '',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				last_login_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`),
+			// Gespeichert wird nur der SHA-256 des Sitzungs-Tokens
+			sqlStep(`CREATE TABLE IF NOT EXISTS sessions (
+				token_hash TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				expires_at TIMESTAMP NOT NULL
+			)`),
+			sqlStep("CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)"),
+			addColumnStep("config", "github_user_tokens", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("config", "github_user_tokens"),
+			sqlStep("DROP INDEX IF EXISTS idx_sessions_user_id"),
+			sqlStep("DROP TABLE IF EXISTS sessions"),
+			sqlStep("DROP TABLE IF EXISTS users"),
+		},
+	},
+	{
+		Version:     52,
+		Description: "Add personal GitHub token and git identity to users",
+		Up: []migrationStep{
+			addColumnStep("users", "personal_token", "TEXT DEFAULT ''"),
+			addColumnStep("users", "git_name", "TEXT DEFAULT ''"),
+			addColumnStep("users", "git_email", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("users", "git_email"),
+			dropColumnStep("users", "git_name"),
+			dropColumnStep("users", "personal_token"),
+		},
+	},
+	{
+		Version:     53,
+		Description: "Create deploy keys",
+		Up: []migrationStep{
+			// Der private Schlüssel ist mit FORGE_SECRETS_KEY verschlüsselt
+			sqlStep(`CREATE TABLE IF NOT EXISTS deploy_keys (
+				project_id TEXT PRIMARY KEY,
+				public_key TEXT NOT NULL,
+				fingerprint TEXT NOT NULL,
+				private_key TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`),
+		},
+		Down: []migrationStep{
+			sqlStep("DROP TABLE IF EXISTS deploy_keys"),
+		},
+	},
+	{
+		Version:     54,
+		Description: "Add GitHub Enterprise endpoints to config and projects",
+		Up: []migrationStep{
+			addColumnStep("config", "github_endpoints", "TEXT DEFAULT ''"),
+			addColumnStep("projects", "github", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("projects", "github"),
+			dropColumnStep("config", "github_endpoints"),
+		},
+	},
+	{
+		Version:     55,
+		Description: "Add pull request review comments to tasks",
+		Up: []migrationStep{
+			addColumnStep("tasks", "review_comments", "INTEGER DEFAULT 0"),
+			addColumnStep("tasks", "review_addressed_at", "TIMESTAMP"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "review_addressed_at"),
+			dropColumnStep("tasks", "review_comments"),
+		},
+	},
+	{
+		Version:     56,
+		Description: "Add auto-merge to projects and merge commits to tasks",
+		Up: []migrationStep{
+			addColumnStep("projects", "auto_merge", "TEXT DEFAULT ''"),
+			addColumnStep("tasks", "merge_commit", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("tasks", "merge_commit"),
+			dropColumnStep("projects", "auto_merge"),
+		},
+	},
+	{
+		Version:     57,
+		Description: "Add commit message templates to projects",
+		Up: []migrationStep{
+			addColumnStep("projects", "commit_template", "TEXT DEFAULT ''"),
+			addColumnStep("projects", "conventional_commits", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("projects", "conventional_commits"),
+			dropColumnStep("projects", "commit_template"),
+		},
+	},
+	{
+		Version:     58,
+		Description: "Add commit and tag signing",
+		Up: []migrationStep{
+			addColumnStep("config", "signing", "TEXT DEFAULT ''"),
+			addColumnStep("projects", "sign_commits", "INTEGER DEFAULT 0"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("projects", "sign_commits"),
+			dropColumnStep("config", "signing"),
+		},
+	},
+	{
+		Version:     59,
+		Description: "Mark projects whose directory is gone",
+		Up: []migrationStep{
+			addColumnStep("projects", "missing_since", "TIMESTAMP"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("projects", "missing_since"),
+		},
+	},
+	{
+		Version:     60,
+		Description: "Add detected project stack and test/build commands",
+		Up: []migrationStep{
+			addColumnStep("projects", "stack", "TEXT DEFAULT ''"),
+			addColumnStep("projects", "test_command", "TEXT DEFAULT ''"),
+			addColumnStep("projects", "build_command", "TEXT DEFAULT ''"),
+		},
+		Down: []migrationStep{
+			dropColumnStep("projects", "build_command"),
+			dropColumnStep("projects", "test_command"),
+			dropColumnStep("projects", "stack"),
+		},
+	},
+	{
+		Version:     61,
+		Description: "Move priority aging from the config to the boards",
+		Up: []migrationStep{
+			addColumnStep("boards", "priority_aging_hours", "INTEGER DEFAULT 0"),
+			sqlStep("UPDATE boards SET priority_aging_hours = (SELECT COALESCE(priority_aging_hours, 0) FROM config WHERE id = 1)"),
+			dropColumnStep("config", "priority_aging_hours"),
+		},
+		Down: []migrationStep{
+			addColumnStep("config", "priority_aging_hours", "INTEGER DEFAULT 0"),
+			sqlStep("UPDATE config SET priority_aging_hours = (SELECT COALESCE(priority_aging_hours, 0) FROM boards WHERE id = 'default')"),
+			dropColumnStep("boards", "priority_aging_hours"),
+		},
+	},
+	{
+		Version:     62,
+		Description: "Move the queue policy from the config to the boards",
+		Up: []migrationStep{
+			addColumnStep("boards", "queue_policy", "TEXT DEFAULT 'fifo'"),
+			sqlStep("UPDATE boards SET queue_policy = (SELECT COALESCE(queue_policy, 'fifo') FROM config WHERE id = 1)"),
+			dropColumnStep("config", "queue_policy"),
+		},
+		Down: []migrationStep{
+			addColumnStep("config", "queue_policy", "TEXT DEFAULT 'fifo'"),
+			sqlStep("UPDATE config SET queue_policy = (SELECT COALESCE(queue_policy, 'fifo') FROM boards WHERE id = 'default')"),
+			dropColumnStep("boards", "queue_policy"),
+		},
+	},
+}
+
+// latestMigrationVersion returns the highest known migration version
+func latestMigrationVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// migrationFloor is the lowest version the backend can be migrated down to.
+// PostgreSQL has no equivalent of the SQLite-only migrations below its baseline.
+func (d *Database) migrationFloor() int {
+	if d.driver == DriverPostgres {
+		return postgresSchemaVersion
+	}
+	return 0
+}
+
+// schemaVersion returns the current schema version
+func (d *Database) schemaVersion() (int, error) {
+	var version int
+	err := d.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// MigrationStatus returns the current schema version and all known migrations
+func (d *Database) MigrationStatus() (*MigrationStatus, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	version, err := d.schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{
+		Driver:         d.driver,
+		CurrentVersion: version,
+		LatestVersion:  latestMigrationVersion(),
+		Migrations:     []MigrationInfo{},
+	}
+	for _, m := range migrations {
+		status.Migrations = append(status.Migrations, MigrationInfo{
+			Version:     m.Version,
+			Description: m.Description,
+			Applied:     m.Version <= version,
+		})
+		if m.Version > version {
+			status.Pending++
+		}
+	}
+	return status, nil
+}
+
+// MigrateUp applies all pending migrations up to target (0 = latest).
+// With dryRun the migrations are only planned, not applied.
+func (d *Database) MigrateUp(target int, dryRun bool) ([]MigrationInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.migrateUp(target, dryRun)
+}
+
+// MigrateDown reverts applied migrations until the schema is at target version.
+// With dryRun the migrations are only planned, not reverted.
+func (d *Database) MigrateDown(target int, dryRun bool) ([]MigrationInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if target < d.migrationFloor() {
+		return nil, fmt.Errorf("cannot migrate %s below version %d", d.driver, d.migrationFloor())
+	}
+
+	version, err := d.schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	planned := []MigrationInfo{}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > version || m.Version <= target {
+			continue
+		}
+		planned = append(planned, migrationInfo(m, m.Down, dryRun))
+		if dryRun {
+			continue
+		}
+
+		slog.Info("Reverting migration", "version", m.Version, "description", m.Description)
+		if err := d.applyMigration(m.Version, m.Down, "DELETE FROM schema_version WHERE version = ?"); err != nil {
+			return planned, fmt.Errorf("migration %d down failed: %v", m.Version, err)
+		}
+	}
+	return planned, nil
+}
+
+// migrateUp applies pending migrations. Callers must hold d.mu (or have exclusive access).
+func (d *Database) migrateUp(target int, dryRun bool) ([]MigrationInfo, error) {
+	if target == 0 {
+		target = latestMigrationVersion()
+	}
+
+	version, err := d.schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > latestMigrationVersion() {
+		slog.Warn("Schema version is newer than this FORGE build", "version", version, "latest", latestMigrationVersion())
+	}
+
+	planned := []MigrationInfo{}
+	for _, m := range migrations {
+		if m.Version <= version || m.Version > target {
+			continue
+		}
+		planned = append(planned, migrationInfo(m, m.Up, dryRun))
+		if dryRun {
+			continue
+		}
+
+		slog.Info("Running migration", "version", m.Version, "description", m.Description)
+		if err := d.applyMigration(m.Version, m.Up, "INSERT INTO schema_version (version) VALUES (?)"); err != nil {
+			return planned, fmt.Errorf("migration %d failed: %v", m.Version, err)
+		}
+		planned[len(planned)-1].Applied = true
+	}
+	return planned, nil
+}
+
+// applyMigration runs the steps and the schema_version update in one transaction
+func (d *Database) applyMigration(version int, steps []migrationStep, versionQuery string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, step := range steps {
+		if err := step.run(tx); err != nil {
+			return fmt.Errorf("%s: %v", step.desc, err)
+		}
+	}
+	if _, err := tx.Exec(versionQuery, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrationInfo describes a migration, including its statements for dry runs
+func migrationInfo(m Migration, steps []migrationStep, withStatements bool) MigrationInfo {
+	info := MigrationInfo{Version: m.Version, Description: m.Description}
+	if withStatements {
+		for _, step := range steps {
+			info.Statements = append(info.Statements, step.desc)
+		}
+	}
+	return info
+}
+
+// ============================================================================
+// Migrations-Befehl und -Handler
+// ============================================================================
+
+// runMigrateCommand implements "forge migrate [status|up|down] [-to N] [-dry-run]".
+// Returns the process exit code.
+func runMigrateCommand(args []string) int {
+	action := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	to := fs.Int("to", -1, "target schema version (up: default latest, down: default one step back)")
+	dryRun := fs.Bool("dry-run", false, "print the migrations and statements without applying them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: forge migrate [status|up|down] [-to N] [-dry-run]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	db, err := openDatabaseFromEnv(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	var result []MigrationInfo
+	switch action {
+	case "status":
+		status, err := db.MigrationStatus()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Driver: %s, schema version %d of %d (%d pending)\n",
+			status.Driver, status.CurrentVersion, status.LatestVersion, status.Pending)
+		for _, m := range status.Migrations {
+			state := "pending"
+			if m.Applied {
+				state = "applied"
+			}
+			fmt.Printf("  %3d  %-8s %s\n", m.Version, state, m.Description)
+		}
+		return 0
+	case "up":
+		target := *to
+		if target < 0 {
+			target = 0
+		}
+		result, err = db.MigrateUp(target, *dryRun)
+	case "down":
+		target := *to
+		if target < 0 {
+			version, verr := db.schemaVersion()
+			if verr != nil {
+				fmt.Fprintf(os.Stderr, "Failed to read schema version: %v\n", verr)
+				return 1
+			}
+			target = version - 1
+		}
+		result, err = db.MigrateDown(target, *dryRun)
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	for _, m := range result {
+		if *dryRun {
+			fmt.Printf("Would %s migration %d: %s\n", action, m.Version, m.Description)
+			for _, stmt := range m.Statements {
+				fmt.Printf("    %s\n", strings.Join(strings.Fields(stmt), " "))
+			}
+			continue
+		}
+		fmt.Printf("Migrated %s: %d %s\n", action, m.Version, m.Description)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+		return 1
+	}
+	if len(result) == 0 {
+		fmt.Println("Nothing to migrate")
+	}
+	return 0
+}
+
+// HandleAdminMigrations handles GET /api/admin/migrations
+// Returns the schema version and the applied/pending state of every migration.
+func (h *Handler) HandleAdminMigrations(w http.ResponseWriter, r *http.Request) {
+	status, err := h.db.MigrationStatus()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get migration status: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, status)
+}
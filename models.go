@@ -3,6 +3,7 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -24,6 +25,21 @@ const (
 	StatusBlocked  TaskStatus = "blocked"  // Fehler oder blockiert (z.B. max. Iterationen erreicht)
 )
 
+// Zusätzlich zu den System-Status können eigene Spalten (board_columns) angelegt werden.
+// Die Rolle einer Spalte legt fest, wie der Runner Tasks darin behandelt.
+const (
+	ColumnRoleNone     = ""         // Manuelle Spalte ohne Runner-Verhalten
+	ColumnRoleQueue    = "queue"    // Tasks warten auf den Runner (wie queued)
+	ColumnRoleProgress = "progress" // Verschieben startet den Runner (wie progress)
+	ColumnRoleTerminal = "terminal" // Task ist abgeschlossen (wie done)
+)
+
+// Git-Workflow eines Projekts: wie der Runner Branches für Tasks verwendet.
+const (
+	WorkflowTrunk  = "trunk"  // Alle Tasks arbeiten auf dem Ziel-/Arbeits-Branch (Standard)
+	WorkflowBranch = "branch" // Jeder Task bekommt working/<id>-<slug>, Push und PR bei Erfolg
+)
+
 // ============================================================================
 // Kern-Datenmodelle
 // ============================================================================
@@ -51,31 +67,89 @@ type Task struct {
 	TaskTypeID    string `json:"task_type_id,omitempty"`   // Verknüpfter Task-Typ
 	WorkingBranch string `json:"working_branch,omitempty"` // Aktueller Git-Branch (zur Laufzeit)
 	TargetBranch  string `json:"target_branch,omitempty"`  // Ziel-Branch beim Task-Erstellen
+	BoardID       string `json:"board_id"`                 // Board, auf dem der Task liegt (siehe boards.go)
 
 	// Conflict PR tracking - when merge fails and PR is created
 	ConflictPRURL    string `json:"conflict_pr_url,omitempty"`    // GitHub PR URL for conflict resolution
 	ConflictPRNumber int    `json:"conflict_pr_number,omitempty"` // GitHub PR number
 
+	// Branch-per-task workflow: PR des Task-Branches
+	PRURL       string `json:"pr_url,omitempty"`       // GitHub PR URL
+	PRNumber    int    `json:"pr_number,omitempty"`    // GitHub PR number
+	MergeCommit string `json:"merge_commit,omitempty"` // Merge-Commit des gemergten PR
+
+	// GitHub-Issue, aus dem der Task importiert wurde
+	IssueURL    string `json:"issue_url,omitempty"`    // GitHub issue URL
+	IssueNumber int    `json:"issue_number,omitempty"` // GitHub issue number
+
+	// Jira-Issue, aus dem der Task importiert wurde
+	JiraKey          string     `json:"jira_key,omitempty"` // z.B. "PROJ-123"
+	JiraSyncedStatus TaskStatus `json:"-"`                  // Zuletzt an Jira übertragener Status
+
+	// Linear-Issue, aus dem der Task importiert wurde
+	LinearID         string `json:"linear_id,omitempty"`         // Issue-UUID
+	LinearIdentifier string `json:"linear_identifier,omitempty"` // z.B. "ENG-123"
+	LinearURL        string `json:"linear_url,omitempty"`        // Link zum Issue
+
+	// Laufzeitumgebung von RALPH
+	Env     TaskEnv `json:"env,omitempty"`      // Zusätzliche Umgebungsvariablen, überschreiben Projekt-Secrets
+	WorkDir string  `json:"work_dir,omitempty"` // Unterverzeichnis im Projekt (z.B. Paket im Monorepo)
+
 	// Trunk-based development fields
 	RollbackTag string `json:"rollback_tag,omitempty"` // Git tag: runner-before-{taskID}
 	CommitHash  string `json:"commit_hash,omitempty"`  // Commit hash bei Task-Ende
 
+	// CI-Status des Commits (GitHub Check Runs)
+	CIStatus      string `json:"ci_status,omitempty"` // pending, success, failure ("" = unbekannt)
+	CIURL         string `json:"ci_url,omitempty"`    // Link zu den Checks des Commits
+	CIFixAttempts int    `json:"-"`                   // Automatische Fortsetzungen wegen fehlgeschlagener CI
+
+	// Review-Kommentare am Pull Request, die RALPH noch nicht bekommen hat (siehe reviews.go)
+	ReviewComments    int        `json:"review_comments,omitempty"` // Anzahl neuer Kommentare
+	ReviewAddressedAt *time.Time `json:"-"`                         // Kommentare bis hierhin wurden übergeben
+
+	// Plan-Modus: RALPH plant zuerst, der freigegebene Plan fließt in den echten Lauf ein
+	Plan       string `json:"plan,omitempty"`        // Implementierungsplan (Markdown)
+	PlanStatus string `json:"plan_status,omitempty"` // planning, proposed, approved ("" = kein Plan)
+
+	// Aufteilung großer Tasks: Teil-Tasks verweisen auf ihr Epic und starten erst nach ihren Abhängigkeiten
+	ParentID  string   `json:"parent_id,omitempty"`  // Epic, aus dem der Task entstanden ist
+	DependsOn []string `json:"depends_on,omitempty"` // Tasks, die vorher erledigt sein müssen (n:m über task_dependencies)
+
+	// Analyse blockierter Tasks: warum RALPH feststeckt und was als Nächstes zu tun ist
+	Triage     *TaskTriage `json:"triage,omitempty"`      // Gehört zum aktuellen Fehler (siehe triage.go)
+	ErrorClass string      `json:"error_class,omitempty"` // Art des Fehlers nach einem gescheiterten Lauf (siehe failures.go)
+
 	// Queue and Process tracking
 	QueuePosition   int        `json:"queue_position"`             // Position in Queue (0 = not queued)
+	QueuedAt        *time.Time `json:"queued_at,omitempty"`        // When the task was queued (only while queued)
+	RetryAt         *time.Time `json:"retry_at,omitempty"`         // Queued again after a transient failure, starts no earlier (see retry.go)
 	ProcessPID      int        `json:"process_pid,omitempty"`      // PID of running Claude process
-	ProcessStatus   string     `json:"process_status,omitempty"`   // idle, running, finished, error
+	ProcessStatus   string     `json:"process_status,omitempty"`   // idle, running, paused, finished, error, resumable
 	StartedAt       *time.Time `json:"started_at,omitempty"`       // When RALPH started
 	FinishedAt      *time.Time `json:"finished_at,omitempty"`      // When RALPH finished
 	ContinueMessage string     `json:"continue_message,omitempty"` // Message for RALPH when resuming from queue
+	EtaStart        *time.Time `json:"eta_start,omitempty"`        // Estimated start while queued (see eta.go)
+	EtaFinish       *time.Time `json:"eta_finish,omitempty"`       // Estimated finish while queued or running
 
 	// Attachments - optional screenshots/videos for visual context
 	Attachments []Attachment `json:"attachments,omitempty"` // Liste der Anhänge (Bilder/Videos)
 
+	// Labels - freie Schlagworte, unabhängig vom Task-Typ (n:m über task_labels)
+	Labels []Label `json:"labels,omitempty"` // Zugeordnete Labels, nach Name sortiert
+
 	// Berechnete Felder für API-Responses (nicht in DB gespeichert)
 	TaskType *TaskType `json:"task_type,omitempty"` // Task-Typ-Details (bei JOIN)
 	Project  *Project  `json:"project,omitempty"`   // Projekt-Details (bei JOIN)
 }
 
+// Status des Plans eines Tasks
+const (
+	PlanStatusPlanning = "planning" // RALPH erstellt den Plan
+	PlanStatusProposed = "proposed" // Plan wartet auf Freigabe
+	PlanStatusApproved = "approved" // Plan freigegeben, wird beim Start mitgegeben
+)
+
 // Attachment repräsentiert einen Dateianhang (Screenshot/Video) zu einem Task.
 type Attachment struct {
 	ID        string    `json:"id"`         // Eindeutige UUID
@@ -85,6 +159,21 @@ type Attachment struct {
 	Size      int64     `json:"size"`       // Dateigröße in Bytes
 	Path      string    `json:"path"`       // Relativer Pfad zur Datei
 	CreatedAt time.Time `json:"created_at"` // Erstellungszeitpunkt
+
+	ThumbnailPath string `json:"thumbnail_path,omitempty"` // Gecachtes Vorschaubild (leer = noch keins)
+	WorkPath      string `json:"-"`                        // Kopie im Arbeitsverzeichnis eines Laufs (relativ, nur für den Prompt)
+}
+
+// Upload ist eine hochgeladene Datei, die noch keinem Task gehört (z.B. ein beim
+// Schreiben eingefügter Screenshot). Beim Speichern des Tasks wird sie zum Attachment,
+// nicht zugeordnete Uploads werden nach Ablauf der TTL gelöscht.
+type Upload struct {
+	ID        string    `json:"id"`         // Eindeutige UUID (wird zur Attachment-ID)
+	Filename  string    `json:"filename"`   // Originaler Dateiname
+	MimeType  string    `json:"mime_type"`  // Erkannter MIME-Typ
+	Size      int64     `json:"size"`       // Dateigröße in Bytes
+	Path      string    `json:"path"`       // Relativer Pfad zur Datei
+	CreatedAt time.Time `json:"created_at"` // Upload-Zeitpunkt
 }
 
 // Project repräsentiert ein Code-Projekt/Repository.
@@ -99,17 +188,104 @@ type Project struct {
 	UpdatedAt      time.Time `json:"updated_at"`       // Letztes Update
 
 	// Trunk-based development: persistenter Arbeits-Branch
-	WorkingBranch string `json:"working_branch,omitempty"` // Persistenter Arbeits-Branch
+	WorkingBranch  string `json:"working_branch,omitempty"`   // Persistenter Arbeits-Branch
+	Workflow       string `json:"workflow"`                   // trunk oder branch (Branch pro Task)
+	IssueSync      bool   `json:"issue_sync"`                 // Issues bei Done kommentieren und schließen
+	JiraProjectKey string `json:"jira_project_key,omitempty"` // Jira-Projekt (z.B. "PROJ") für Import und Sync
+	PushHook       bool   `json:"push_hook"`                  // pre-push Hook gegen Pushes auf geschützte Branches
+	DeployCommand  string `json:"deploy_command,omitempty"`   // Befehle nach dem Push (eine Zeile pro Befehl)
+	DeployTimeout  int    `json:"deploy_timeout,omitempty"`   // Timeout der Deploy-Befehle in Sekunden (0 = Standard)
+
+	// Claude-Aufruf: ergänzt bzw. überschreibt die globalen Einstellungen
+	Claude ClaudeSettings `json:"claude"`
+
+	// Screenshot nach erfolgreichem Lauf (z.B. Dev-Server des Frontends)
+	ScreenshotURL     string `json:"screenshot_url,omitempty"`     // Seite, die nach dem Lauf aufgenommen wird
+	ScreenshotCommand string `json:"screenshot_command,omitempty"` // Eigener Befehl statt Headless-Chrome (leer = eingebaut)
+
+	// Kostenbudget des Projekts (siehe budget.go)
+	Budget CostBudget `json:"budget"`
+
+	// Board des Projekts, neue Tasks des Projekts landen dort (siehe boards.go)
+	BoardID string `json:"board_id"`
+
+	// Eigene GitHub-Instanz, z.B. GitHub Enterprise Server (leer = die der Config, siehe githubhost.go)
+	GitHub GitHubEndpoints `json:"github"`
+
+	// Branch pro Task: PR mergen, sobald Checks und Approvals da sind (merge, squash, rebase; leer = aus, siehe automerge.go)
+	AutoMerge string `json:"auto_merge,omitempty"`
+
+	// Commit-Nachrichten, die FORGE selbst schreibt (siehe commitmsg.go)
+	CommitTemplate      string `json:"commit_template,omitempty"` // Vorlage mit {summary}, {title}, {id}, {type}, {project}
+	ConventionalCommits bool   `json:"conventional_commits"`      // Betreffzeile als Conventional Commit (feat, fix, ...)
+
+	// Commits und Tags von FORGE mit dem Schlüssel der Config signieren (siehe signing.go)
+	SignCommits bool `json:"sign_commits"`
+
+	// Seit wann das Projektverzeichnis fehlt (nil = vorhanden, siehe scan.go)
+	MissingSince *time.Time `json:"missing_since,omitempty"`
+
+	// Erkannte Sprache, Framework, Befehle und Repo-Größe (siehe projectdetect.go)
+	Stack        ProjectStack `json:"stack"`
+	TestCommand  string       `json:"test_command,omitempty"`  // Eigener Test-Befehl (leer = der erkannte)
+	BuildCommand string       `json:"build_command,omitempty"` // Eigener Build-Befehl (leer = der erkannte)
 
 	// Berechnete Felder (nicht in DB gespeichert, zur Laufzeit ermittelt)
-	CurrentBranch string `json:"current_branch,omitempty"` // Aktuell ausgecheckter Branch
-	IsGitRepo     bool   `json:"is_git_repo"`              // true = .git Verzeichnis existiert
-	TaskCount     int    `json:"task_count,omitempty"`     // Anzahl verknüpfter Tasks
-	GithubURL     string `json:"github_url,omitempty"`     // GitHub Repository URL (z.B. https://github.com/owner/repo)
+	CurrentBranch string    `json:"current_branch,omitempty"` // Aktuell ausgecheckter Branch
+	IsGitRepo     bool      `json:"is_git_repo"`              // true = .git Verzeichnis existiert
+	TaskCount     int       `json:"task_count,omitempty"`     // Anzahl verknüpfter Tasks
+	GithubURL     string    `json:"github_url,omitempty"`     // GitHub Repository URL (z.B. https://github.com/owner/repo oder auf GitHub Enterprise)
+	RepoRoot      string    `json:"repo_root,omitempty"`      // Repository, wenn das Projekt ein Unterverzeichnis davon ist
+	RepoLock      *RepoLock `json:"repo_lock,omitempty"`      // Laufende Git-Operation, die das Repository sperrt
+}
+
+// BranchInfo beschreibt einen lokalen oder Remote-Tracking-Branch für
+// GET /api/projects/{id}/branches.
+type BranchInfo struct {
+	Name             string     `json:"name"`                         // z.B. feature/login oder origin/main
+	IsRemote         bool       `json:"is_remote"`                    // true = Remote-Tracking-Branch
+	IsCurrent        bool       `json:"is_current"`                   // true = ausgecheckt
+	Upstream         string     `json:"upstream,omitempty"`           // Verfolgter Branch, z.B. origin/main
+	UpstreamGone     bool       `json:"upstream_gone,omitempty"`      // Verfolgter Branch wurde gelöscht
+	Ahead            int        `json:"ahead"`                        // Commits, die der Upstream nicht hat
+	Behind           int        `json:"behind"`                       // Commits des Upstreams, die fehlen
+	LastCommitAt     *time.Time `json:"last_commit_at,omitempty"`     // Datum des letzten Commits
+	LastCommitAuthor string     `json:"last_commit_author,omitempty"` // Autor des letzten Commits
+	Merged           bool       `json:"merged"`                       // Vollständig im Default-Branch enthalten
+}
+
+// BranchCleanupRequest für POST /api/projects/{id}/branches/cleanup
+type BranchCleanupRequest struct {
+	OlderThanDays int      `json:"older_than_days,omitempty"` // Auch Branches ohne Commit seit so vielen Tagen (0 = nur gemergte)
+	Delete        []string `json:"delete,omitempty"`          // Zu löschende Kandidaten (leer = nur Vorschau)
+}
+
+// StaleBranch ist ein Branch, der aufgeräumt werden kann.
+type StaleBranch struct {
+	BranchInfo
+	Reasons []string `json:"reasons"`           // merged und/oder old
+	TaskID  string   `json:"task_id,omitempty"` // Abgeschlossener Task, dessen Arbeits-Branch er ist
+}
+
+// BranchCleanupResponse ist die Antwort von POST /api/projects/{id}/branches/cleanup
+type BranchCleanupResponse struct {
+	Candidates []StaleBranch     `json:"candidates"`       // Branches, die (noch) aufgeräumt werden können
+	Deleted    []string          `json:"deleted"`          // Gelöschte Branches
+	Failed     map[string]string `json:"failed,omitempty"` // Nicht gelöschte Branches mit Grund
+}
+
+// RepoLock beschreibt die Sperre eines Repositorys durch eine laufende
+// Git-Operation (siehe repolock.go).
+type RepoLock struct {
+	Repo      string    `json:"repo,omitempty"` // Wurzel des Repositorys
+	Operation string    `json:"operation"`      // z.B. push, checkout, task start
+	Since     time.Time `json:"since"`          // Gesperrt seit
+	Waiting   int       `json:"waiting"`        // Anzahl wartender Operationen
 }
 
 // BranchProtectionRule definiert Branches, auf die RALPH niemals pushen darf.
-// Unterstützt Glob-Pattern wie "release/*" oder exakte Namen wie "main".
+// Unterstützt Glob-Pattern wie "release/*", "release/*/hotfix" oder "**" und
+// exakte Namen wie "main". Mit "!" beginnende Pattern sind Ausnahmen.
 type BranchProtectionRule struct {
 	ID            string    `json:"id"`             // Eindeutige UUID
 	ProjectID     string    `json:"project_id"`     // Zugehöriges Projekt
@@ -117,6 +293,23 @@ type BranchProtectionRule struct {
 	CreatedAt     time.Time `json:"created_at"`     // Erstellungszeitpunkt
 }
 
+// PathProtectionRule definiert Dateien und Verzeichnisse, die RALPH niemals ändern darf,
+// z.B. "migrations/**" oder "infra". Mit "!" beginnende Pattern sind Ausnahmen.
+// FORGE prüft nach jeder Iteration, ob geschützte Pfade geändert wurden.
+type PathProtectionRule struct {
+	ID          string    `json:"id"`           // Eindeutige UUID
+	ProjectID   string    `json:"project_id"`   // Zugehöriges Projekt
+	PathPattern string    `json:"path_pattern"` // Pattern relativ zum Projektverzeichnis
+	Action      string    `json:"action"`       // "revert" oder "block"
+	CreatedAt   time.Time `json:"created_at"`   // Erstellungszeitpunkt
+}
+
+// Aktionen bei Änderungen an geschützten Pfaden
+const (
+	PathActionRevert = "revert" // Änderungen zurücksetzen, RALPH arbeitet weiter
+	PathActionBlock  = "block"  // Task blockieren und RALPH stoppen
+)
+
 // TaskType definiert einen Typ/Kategorie von Tasks mit zugehöriger Farbe.
 // System-Typen (Feature, Bug, Refactor, Test) können nicht gelöscht werden.
 type TaskType struct {
@@ -127,6 +320,63 @@ type TaskType struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// TaskComment ist ein Kommentar im Diskussions-Thread eines Tasks.
+// Mit "an RALPH senden" wird der Text als Fortsetzungs-Nachricht übergeben.
+type TaskComment struct {
+	ID          string     `json:"id"`                // Eindeutige UUID
+	TaskID      string     `json:"task_id"`           // Zugehöriger Task
+	Author      string     `json:"author,omitempty"`  // Optionaler Anzeigename
+	Body        string     `json:"body"`              // Kommentartext (Markdown)
+	SentToRalph bool       `json:"sent_to_ralph"`     // true = wurde an RALPH übergeben
+	SentAt      *time.Time `json:"sent_at,omitempty"` // Zeitpunkt der Übergabe an RALPH
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Label ist ein frei vergebbares Schlagwort mit Farbe.
+// Anders als der Task-Typ kann ein Task beliebig viele Labels tragen.
+type Label struct {
+	ID        string    `json:"id"`    // Eindeutige UUID
+	Name      string    `json:"name"`  // Anzeigename (eindeutig, z.B. "frontend")
+	Color     string    `json:"color"` // Hex-Farbe für den Chip (z.B. "#a371f7")
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BoardColumn ist eine Spalte des Kanban-Boards. Status ist der Wert, den Tasks in tasks.status tragen.
+// System-Spalten (die sechs eingebauten Status) können umbenannt, aber nicht gelöscht werden.
+type BoardColumn struct {
+	Status    TaskStatus `json:"status"`    // Status-Schlüssel (z.B. "backlog", "qa")
+	Name      string     `json:"name"`      // Anzeigename (z.B. "In Progress")
+	Color     string     `json:"color"`     // Hex-Farbe des Spaltenkopfs
+	Position  int        `json:"position"`  // Reihenfolge auf dem Board (aufsteigend)
+	WIPLimit  int        `json:"wip_limit"` // Max. Anzahl Tasks in der Spalte (0 = unbegrenzt)
+	SLAHours  int        `json:"sla_hours"` // Alarm nach so vielen Stunden in der Spalte (0 = aus)
+	Role      string     `json:"role"`      // queue, progress, terminal oder leer
+	IsSystem  bool       `json:"is_system"` // true = eingebauter Status, Rolle fest
+	BoardID   string     `json:"board_id"`  // Board der eigenen Spalte ("" = System-Spalte aller Boards)
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Board ist ein eigenes Kanban-Board mit eigenen Spalten, Projekten und Queue samt Queue-Strategie (siehe boards.go).
+// Das Board "default" existiert immer und nimmt alle Tasks ohne Board auf.
+type Board struct {
+	ID                 string    `json:"id"`                   // "default" oder UUID
+	Name               string    `json:"name"`                 // Anzeigename (z.B. "Team Web")
+	Description        string    `json:"description"`          // Optionale Beschreibung
+	QueuePolicy        string    `json:"queue_policy"`         // "fifo", "priority", "round_robin"
+	MaxConcurrentTasks int       `json:"max_concurrent_tasks"` // Max. gleichzeitig laufende Tasks des Boards (0 = nur globales Limit)
+	PriorityAgingHours int       `json:"priority_aging_hours"` // Wartende Tasks steigen alle n Stunden eine Priorität auf (0 = aus)
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+
+	// Berechnete Felder (nicht in DB gespeichert)
+	TaskCount    int `json:"task_count"`    // Anzahl Tasks auf dem Board
+	ProjectCount int `json:"project_count"` // Anzahl Projekte des Boards
+}
+
+// DefaultBoardID ist das Board, das immer existiert und nicht gelöscht werden kann.
+const DefaultBoardID = "default"
+
 // Config repräsentiert die globalen Konfigurationseinstellungen.
 // Es existiert nur ein Config-Datensatz in der Datenbank (id = 1).
 type Config struct {
@@ -135,7 +385,19 @@ type Config struct {
 	DefaultMaxIterations int    `json:"default_max_iterations"`// Standard für max. Iterationen
 	ClaudeCommand        string `json:"claude_command"`        // Pfad zum Claude CLI
 	ProjectsBaseDir      string `json:"projects_base_dir"`     // Basis-Verzeichnis für Projekt-Scan
-	GithubToken          string `json:"github_token,omitempty"`// GitHub Personal Access Token
+	GithubToken          string `json:"-"`                     // GitHub Personal Access Token (nie ausgeliefert)
+	GithubTokenSet       bool   `json:"github_token_set"`      // true = Token hinterlegt
+	GithubUserTokens     bool   `json:"github_user_tokens"`    // Pushes und PRs mit dem OAuth-Token des angemeldeten Benutzers
+
+	// GitHub-Instanz, z.B. GitHub Enterprise Server (leer = github.com, siehe githubhost.go)
+	GitHub GitHubEndpoints `json:"github"`
+
+	// Schlüssel für signierte Commits und Tags der Projekte mit sign_commits (siehe signing.go)
+	Signing SigningConfig `json:"signing"`
+
+	// Dateisystem-Zugriff (aus FORGE_BROWSE_ROOTS/FORGE_BROWSE_DISABLED, nicht gespeichert)
+	BrowseEnabled bool     `json:"browse_enabled"` // false = Ordner-Browser abgeschaltet
+	BrowseRoots   []string `json:"browse_roots"`   // Erlaubte Wurzelverzeichnisse, leer = alle
 
 	// Erweiterte Einstellungen
 	AutoCommit      bool   `json:"auto_commit"`      // Auto-Commit bei Task-Abschluss
@@ -146,8 +408,62 @@ type Config struct {
 
 	// Trunk-based development
 	PushStrategy string `json:"push_strategy"` // "manual", "auto_task", "auto_commit"
+
+	// Queue
+	MaxConcurrentTasks int    `json:"max_concurrent_tasks"` // Höchstzahl gleichzeitig laufender Tasks, je Projekt einer
+	BlockedTriage      bool   `json:"blocked_triage"`       // Blockierte Tasks automatisch von Claude analysieren lassen
+
+	// Attachments
+	AttachmentTypes string `json:"attachment_types"` // Erlaubte MIME-Typen, kommagetrennt (z.B. "image/*, application/pdf")
+
+	// Jira
+	JiraURL   string `json:"jira_url"`             // Basis-URL des Jira-Servers (z.B. "https://jira.example.com")
+	JiraUser  string `json:"jira_user"`            // Benutzer/E-Mail für Basic Auth (leer = Token als Bearer)
+	JiraToken    string `json:"-"`              // API-Token oder Personal Access Token (nie ausgeliefert)
+	JiraTokenSet bool   `json:"jira_token_set"` // true = Token hinterlegt
+
+	// Linear
+	LinearToken    string `json:"-"`                // Persönlicher API-Key (nie ausgeliefert)
+	LinearTokenSet bool   `json:"linear_token_set"` // true = Key hinterlegt
+
+	// Claude-Aufruf: Tools, Berechtigungsmodus, zusätzliche Argumente
+	Claude ClaudeSettings `json:"claude"`
+
+	// Aufbewahrung der Task-Logs: Größenlimit, Komprimierung, Löschen
+	LogRetention LogRetention `json:"log_retention"`
+
+	// Wiederholung nach vorübergehenden Fehlern: CLI-Absturz, Netzwerkfehler
+	RetryPolicy RetryPolicy `json:"retry_policy"`
+
+	// Kostenbudget aller Projekte zusammen (siehe budget.go)
+	Budget CostBudget `json:"budget"`
 }
 
+// TaskLogSize beschreibt die gespeicherten Logs eines Tasks (für die Log-Aufbewahrung).
+type TaskLogSize struct {
+	TaskID       string     // Task
+	Status       TaskStatus // Aktueller Status
+	Size         int64      // Länge der gespeicherten Logs
+	Compressed   bool       // true = gzip-komprimiert gespeichert
+	LastActivity time.Time  // Ende des letzten Laufs, sonst letzte Änderung
+}
+
+// LogCompactionResult ist die Antwort von POST /api/admin/task-logs/compact.
+type LogCompactionResult struct {
+	Capped      int   `json:"capped"`       // Auf das Größenlimit gekürzte Logs
+	Compressed  int   `json:"compressed"`   // Komprimierte Logs
+	Purged      int   `json:"purged"`       // Gelöschte Logs
+	BytesBefore int64 `json:"bytes_before"` // Größe der bearbeiteten Logs vorher
+	BytesAfter  int64 `json:"bytes_after"`  // und nachher
+}
+
+// Queue-Strategien: in welcher Reihenfolge der Runner wartende Tasks startet.
+const (
+	QueuePolicyFIFO       = "fifo"        // Nach Queue-Position (Standard)
+	QueuePolicyPriority   = "priority"    // Höchste Priorität zuerst, dann Queue-Position
+	QueuePolicyRoundRobin = "round_robin" // Projekte abwechselnd, je Projekt nach Queue-Position
+)
+
 // ============================================================================
 // WebSocket-Nachrichten
 // ============================================================================
@@ -160,10 +476,35 @@ type WSMessage struct {
 	Message   string     `json:"message,omitempty"`   // Textnachricht (für log, deployment_success)
 	Status    TaskStatus `json:"status,omitempty"`    // Neuer Status (für status-Updates)
 	Task      *Task      `json:"task,omitempty"`      // Vollständiger Task (für task_updated)
-	Project   *Project   `json:"project,omitempty"`   // Vollständiges Projekt (für project_updated)
+	Project   *Project   `json:"project,omitempty"`   // Vollständiges Projekt (für project_updated, project_changed_externally)
 	Iteration int        `json:"iteration,omitempty"` // Aktuelle Iteration (für status)
 	Branch    string     `json:"branch,omitempty"`    // Branch-Name (für branch_change)
 	Conflict  *MergeConflict `json:"conflict,omitempty"` // Konflikt-Details (für merge_conflict)
+	Columns   []BoardColumn `json:"columns,omitempty"` // Alle Board-Spalten (für columns_updated)
+	Queue     []QueueEntry  `json:"queue,omitempty"`   // Neue Queue-Reihenfolge (für queue_reordered)
+	Labels    []Label       `json:"labels,omitempty"`  // Alle Labels (für labels_updated)
+	Comment   *TaskComment  `json:"comment,omitempty"` // Kommentar (für comment_created/_updated/_deleted)
+	Clone     *CloneProgress `json:"clone,omitempty"`  // Fortschritt eines Klon-Vorgangs (für clone_progress)
+	Scan      *ScanProgress  `json:"scan,omitempty"`   // Fortschritt eines Projekt-Scans (für scan_progress)
+	Bootstrap *BootstrapProgress `json:"bootstrap,omitempty"` // Fortschritt eines Projekt-Bootstraps (für bootstrap_progress)
+	Release   *Release   `json:"release,omitempty"`   // Release eines Projekts (für release_updated)
+	Deployment *Deployment `json:"deployment,omitempty"` // Deployment eines Projekts (für deployment_updated)
+	QueueState *QueueState `json:"queue_state,omitempty"` // Zustand der Queue (für queue_state)
+	Budget    *BudgetStatus `json:"budget,omitempty"`  // Stand eines Kostenbudgets (für budget_alert)
+	Boards    []Board       `json:"boards,omitempty"`  // Alle Boards (für boards_updated)
+	Seq       int64      `json:"seq,omitempty"`       // Laufende Nummer des Events (für resume nach Reconnect)
+	Epoch     string     `json:"epoch,omitempty"`     // Hub-Instanz, zu der Seq gehört (für hello)
+}
+
+// WSClientMessage ist eine Nachricht vom Client an den Server.
+// Ohne Subscription erhält ein Client alle Nachrichten; nach "subscribe" nur noch
+// Nachrichten, deren Typ oder Task-ID abonniert ist.
+type WSClientMessage struct {
+	Action  string   `json:"action"`             // subscribe, unsubscribe, subscribe_all, resume
+	TaskIDs []string `json:"task_ids,omitempty"` // Tasks, deren Nachrichten (z.B. Logs) gewünscht sind
+	Types   []string `json:"types,omitempty"`    // Nachrichtentypen, die für alle Tasks gewünscht sind
+	Epoch   string   `json:"epoch,omitempty"`    // resume: Epoch aus der hello-Nachricht der alten Verbindung
+	LastSeq int64    `json:"last_seq,omitempty"` // resume: zuletzt empfangene Seq
 }
 
 // ============================================================================
@@ -181,6 +522,11 @@ type CreateTaskRequest struct {
 	ProjectID          string `json:"project_id"`         // Optional: Projekt-Verknüpfung
 	TaskTypeID         string `json:"task_type_id"`       // Optional: Task-Typ
 	TargetBranch       string `json:"target_branch"`      // Optional: Ziel-Branch für den Task
+	LabelIDs           []string `json:"label_ids"`        // Optional: Labels des Tasks
+	Env                TaskEnv  `json:"env"`              // Optional: Umgebungsvariablen für RALPH
+	WorkDir            string   `json:"work_dir"`         // Optional: Unterverzeichnis im Projekt
+	Force              bool     `json:"force,omitempty"`  // Auch anlegen, wenn ähnliche Tasks existieren
+	BoardID            string   `json:"board_id"`         // Optional: Board, sonst das des Projekts oder "default"
 }
 
 // UpdateTaskRequest ist der Request-Body zum Aktualisieren eines Tasks.
@@ -197,6 +543,10 @@ type UpdateTaskRequest struct {
 	TaskTypeID         *string     `json:"task_type_id,omitempty"`
 	WorkingBranch      *string     `json:"working_branch,omitempty"`
 	TargetBranch       *string     `json:"target_branch,omitempty"`
+	LabelIDs           *[]string   `json:"label_ids,omitempty"` // Ersetzt alle Labels des Tasks
+	Env                *TaskEnv    `json:"env,omitempty"`       // Ersetzt alle Umgebungsvariablen
+	WorkDir            *string     `json:"work_dir,omitempty"`
+	BoardID            *string     `json:"board_id,omitempty"` // Verschiebt den Task auf ein anderes Board
 }
 
 // FeedbackRequest ist der Request-Body für Feedback an einen laufenden Task.
@@ -216,6 +566,9 @@ type UpdateConfigRequest struct {
 	ClaudeCommand        *string `json:"claude_command,omitempty"`
 	ProjectsBaseDir      *string `json:"projects_base_dir,omitempty"`
 	GithubToken          *string `json:"github_token,omitempty"`
+	GithubUserTokens     *bool   `json:"github_user_tokens,omitempty"`
+	GitHub               *GitHubEndpoints `json:"github,omitempty"` // Ersetzt beide URLs der GitHub-Instanz
+	Signing              *SigningConfig   `json:"signing,omitempty"` // Ersetzt den Signaturschlüssel, format "" schaltet ab
 
 	// Erweiterte Einstellungen
 	AutoCommit      *bool   `json:"auto_commit,omitempty"`
@@ -223,6 +576,38 @@ type UpdateConfigRequest struct {
 	DefaultBranch   *string `json:"default_branch,omitempty"`
 	DefaultPriority *int    `json:"default_priority,omitempty"`
 	AutoArchiveDays *int    `json:"auto_archive_days,omitempty"`
+
+	// Queue
+	MaxConcurrentTasks *int    `json:"max_concurrent_tasks,omitempty"` // Mindestens 1
+	BlockedTriage      *bool   `json:"blocked_triage,omitempty"`
+
+	// Attachments
+	AttachmentTypes *string `json:"attachment_types,omitempty"` // Leer = Standardliste
+
+	// Jira
+	JiraURL   *string `json:"jira_url,omitempty"`
+	JiraUser  *string `json:"jira_user,omitempty"`
+	JiraToken *string `json:"jira_token,omitempty"`
+
+	// Linear
+	LinearToken *string `json:"linear_token,omitempty"`
+
+	// Claude-Aufruf
+	Claude *ClaudeSettings `json:"claude,omitempty"` // Ersetzt alle Claude-Einstellungen
+
+	// Log-Aufbewahrung
+	LogRetention *LogRetention `json:"log_retention,omitempty"` // Ersetzt alle Aufbewahrungs-Einstellungen
+
+	// Wiederholung nach vorübergehenden Fehlern
+	RetryPolicy *RetryPolicy `json:"retry_policy,omitempty"` // Ersetzt alle Wiederholungs-Einstellungen
+
+	// Kostenbudget
+	Budget *CostBudget `json:"budget,omitempty"` // Ersetzt das gesamte Budget
+}
+
+// CredentialRequest ist der Request-Body für PUT /api/config/credentials/{name}.
+type CredentialRequest struct {
+	Value string `json:"value"` // Neuer Token (Klartext, wird verschlüsselt gespeichert)
 }
 
 // ============================================================================
@@ -231,15 +616,120 @@ type UpdateConfigRequest struct {
 
 // CreateProjectRequest ist der Request-Body zum Erstellen eines neuen Projekts.
 type CreateProjectRequest struct {
-	Name        string `json:"name"`        // Pflichtfeld: Anzeigename
-	Path        string `json:"path"`        // Pflichtfeld: Absoluter Pfad
-	Description string `json:"description"` // Optional: Beschreibung
+	Name           string `json:"name"`             // Pflichtfeld: Anzeigename
+	Path           string `json:"path"`             // Pflichtfeld: Absoluter Pfad
+	Description    string `json:"description"`      // Optional: Beschreibung
+	Workflow       string `json:"workflow"`         // Optional: trunk (Standard) oder branch
+	IssueSync      bool   `json:"issue_sync"`       // Optional: importierte Issues bei Done schließen
+	JiraProjectKey string `json:"jira_project_key"` // Optional: Jira-Projekt-Key
+	PushHook       bool   `json:"push_hook"`        // Optional: pre-push Hook installieren
+	DeployCommand  string `json:"deploy_command"`   // Optional: Deploy-Befehle nach dem Push
+	DeployTimeout  int    `json:"deploy_timeout"`   // Optional: Timeout in Sekunden
+
+	Claude ClaudeSettings `json:"claude"` // Optional: Claude-Aufruf des Projekts
+
+	ScreenshotURL     string `json:"screenshot_url"`     // Optional: Seite für den Screenshot nach dem Lauf
+	ScreenshotCommand string `json:"screenshot_command"` // Optional: eigener Screenshot-Befehl
+
+	Budget CostBudget `json:"budget"` // Optional: Kostenbudget des Projekts
+
+	BoardID string `json:"board_id"` // Optional: Board des Projekts (Standard: "default")
+
+	GitHub GitHubEndpoints `json:"github"` // Optional: eigene GitHub-Instanz (leer = die der Config)
+
+	AutoMerge string `json:"auto_merge"` // Optional: Merge-Methode für Auto-Merge (merge, squash, rebase; leer = aus)
+
+	CommitTemplate      string `json:"commit_template"`      // Optional: Vorlage für Commit-Nachrichten
+	ConventionalCommits bool   `json:"conventional_commits"` // Optional: Conventional-Commits-Präfix
+
+	SignCommits bool `json:"sign_commits"` // Optional: Commits und Tags signieren
+
+	TestCommand  string `json:"test_command"`  // Optional: Test-Befehl (leer = der erkannte)
+	BuildCommand string `json:"build_command"` // Optional: Build-Befehl (leer = der erkannte)
 }
 
 // UpdateProjectRequest ist der Request-Body zum Aktualisieren eines Projekts.
 type UpdateProjectRequest struct {
-	Name        *string `json:"name,omitempty"`
-	Description *string `json:"description,omitempty"`
+	Name           *string `json:"name,omitempty"`
+	Description    *string `json:"description,omitempty"`
+	Workflow       *string `json:"workflow,omitempty"`
+	IssueSync      *bool   `json:"issue_sync,omitempty"`
+	JiraProjectKey *string `json:"jira_project_key,omitempty"`
+	PushHook       *bool   `json:"push_hook,omitempty"`
+	DeployCommand  *string `json:"deploy_command,omitempty"`
+	DeployTimeout  *int    `json:"deploy_timeout,omitempty"`
+
+	Claude *ClaudeSettings `json:"claude,omitempty"` // Ersetzt alle Claude-Einstellungen des Projekts
+
+	ScreenshotURL     *string `json:"screenshot_url,omitempty"`
+	ScreenshotCommand *string `json:"screenshot_command,omitempty"`
+
+	Budget *CostBudget `json:"budget,omitempty"` // Ersetzt das gesamte Budget des Projekts
+
+	BoardID *string `json:"board_id,omitempty"` // Verschiebt das Projekt auf ein anderes Board, seine Tasks bleiben
+
+	GitHub *GitHubEndpoints `json:"github,omitempty"` // Ersetzt beide URLs der GitHub-Instanz des Projekts
+
+	AutoMerge *string `json:"auto_merge,omitempty"` // Merge-Methode für Auto-Merge, "" schaltet es aus
+
+	CommitTemplate      *string `json:"commit_template,omitempty"` // "" = Nachrichten ohne Vorlage
+	ConventionalCommits *bool   `json:"conventional_commits,omitempty"`
+
+	SignCommits *bool `json:"sign_commits,omitempty"`
+
+	TestCommand  *string `json:"test_command,omitempty"`  // "" = der erkannte Test-Befehl
+	BuildCommand *string `json:"build_command,omitempty"` // "" = der erkannte Build-Befehl
+}
+
+// CloneProjectRequest ist der Request-Body für POST /api/projects/clone.
+type CloneProjectRequest struct {
+	URL         string `json:"url"`         // Pflichtfeld: Repository-URL (https://, ssh:// oder git@host:)
+	Branch      string `json:"branch"`      // Optional: auszucheckender Branch
+	Name        string `json:"name"`        // Optional: Projektname/Verzeichnis (Standard: Repository-Name)
+	Description string `json:"description"` // Optional: Beschreibung
+}
+
+// CloneProgress beschreibt den Stand eines Klon-Vorgangs (WebSocket clone_progress).
+type CloneProgress struct {
+	CloneID string   `json:"clone_id"`          // ID aus der Antwort von POST /api/projects/clone
+	URL     string   `json:"url"`               // Geklontes Repository
+	Path    string   `json:"path"`              // Zielverzeichnis
+	Phase   string   `json:"phase,omitempty"`   // z.B. "Receiving objects"
+	Percent int      `json:"percent"`           // Fortschritt der aktuellen Phase (0-100)
+	Done    bool     `json:"done"`              // true = abgeschlossen (erfolgreich oder mit Fehler)
+	Error   string   `json:"error,omitempty"`   // Fehlermeldung bei Abbruch
+	Project *Project `json:"project,omitempty"` // Registriertes Projekt nach Erfolg
+}
+
+// BootstrapProjectRequest ist der Request-Body für POST /api/projects/bootstrap.
+type BootstrapProjectRequest struct {
+	Name             string `json:"name"`               // Pflichtfeld: Projektname/Verzeichnis
+	Description      string `json:"description"`        // Optional: Beschreibung (auch für GitHub)
+	Template         string `json:"template"`           // Pflichtfeld: ID aus GET /api/projects/bootstrap
+	Instructions     string `json:"instructions"`       // Optional: zusätzliche Anweisungen für RALPH
+	CreateGithubRepo bool   `json:"create_github_repo"` // true = GitHub-Repository anlegen und pushen
+	Private          bool   `json:"private"`            // GitHub-Repository privat anlegen
+}
+
+// BootstrapTemplate ist eine Vorlage für die Starter-Struktur eines neuen Projekts.
+type BootstrapTemplate struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Prompt string `json:"-"` // Aufgabe für den Bootstrap-Task
+}
+
+// BootstrapProgress beschreibt den Stand eines Projekt-Bootstraps (WebSocket bootstrap_progress).
+type BootstrapProgress struct {
+	BootstrapID string   `json:"bootstrap_id"`       // ID aus der Antwort von POST /api/projects/bootstrap
+	Name        string   `json:"name"`               // Projektname
+	Path        string   `json:"path"`               // Projektverzeichnis
+	Step        string   `json:"step"`               // Aktueller Schritt (directory, git_init, project, task, commit, github, push, done)
+	Message     string   `json:"message,omitempty"`  // Beschreibung des Schritts
+	Done        bool     `json:"done"`               // true = abgeschlossen (erfolgreich oder mit Fehler)
+	Error       string   `json:"error,omitempty"`    // Fehlermeldung bei Abbruch
+	Project     *Project `json:"project,omitempty"`  // Registriertes Projekt (ab Schritt project)
+	TaskID      string   `json:"task_id,omitempty"`  // Bootstrap-Task (ab Schritt task)
+	RepoURL     string   `json:"repo_url,omitempty"` // GitHub-Repository (ab Schritt github)
 }
 
 // ScanProjectsRequest ist der Request-Body zum Scannen nach Projekten.
@@ -248,6 +738,19 @@ type ScanProjectsRequest struct {
 	MaxDepth int    `json:"max_depth"` // Maximale Suchtiefe (Standard: 3)
 }
 
+// ScanProgress beschreibt den Stand eines Projekt-Scans (WebSocket scan_progress).
+type ScanProgress struct {
+	BasePath  string    `json:"base_path"`          // Durchsuchtes Verzeichnis
+	Scheduled bool      `json:"scheduled"`          // true = geplanter Scan im Hintergrund
+	Dirs      int       `json:"dirs"`               // Bisher durchsuchte Verzeichnisse
+	Found     int       `json:"found"`              // Gefundene Repositories (beim geplanten Scan nur neue)
+	Created   int       `json:"created"`            // Neu angelegte Projekte
+	Missing   int       `json:"missing"`            // Projekte, deren Verzeichnis neu fehlt
+	Done      bool      `json:"done"`               // true = abgeschlossen (erfolgreich oder mit Fehler)
+	Error     string    `json:"error,omitempty"`    // Fehlermeldung bei Abbruch
+	Projects  []Project `json:"projects,omitempty"` // Neu angelegte Projekte (wenn Done)
+}
+
 // ============================================================================
 // API Request/Response Types - Task Type
 // ============================================================================
@@ -264,13 +767,489 @@ type UpdateTaskTypeRequest struct {
 	Color *string `json:"color,omitempty"`
 }
 
+// ============================================================================
+// API Request/Response Types - Comment
+// ============================================================================
+
+// CreateCommentRequest ist der Request-Body für POST /api/tasks/{id}/comments.
+type CreateCommentRequest struct {
+	Author      string `json:"author"`        // Optional: Anzeigename
+	Body        string `json:"body"`          // Pflichtfeld: Kommentartext
+	SendToRalph bool   `json:"send_to_ralph"` // true = direkt als Fortsetzung an RALPH senden
+}
+
+// UpdateCommentRequest ist der Request-Body für PUT /api/tasks/{id}/comments/{commentId}.
+type UpdateCommentRequest struct {
+	Body *string `json:"body,omitempty"`
+}
+
+// ============================================================================
+// API Request/Response Types - Upload
+// ============================================================================
+
+// UploadResponse ist die Antwort von POST /api/uploads.
+type UploadResponse struct {
+	*Upload
+	URL       string    `json:"url"`        // Vorschau-URL, solange der Upload keinem Task gehört
+	Markdown  string    `json:"markdown"`   // Snippet für Beschreibung/Kommentar, z.B. ![shot.png](upload:<id>)
+	ExpiresAt time.Time `json:"expires_at"` // Ohne Zuordnung wird der Upload danach gelöscht
+}
+
+// ============================================================================
+// API Request/Response Types - Markdown
+// ============================================================================
+
+// RenderMarkdownRequest ist der Request-Body für POST /api/render.
+type RenderMarkdownRequest struct {
+	Markdown string `json:"markdown"`           // Zu rendernder Text
+	TaskID   string `json:"task_id,omitempty"`  // Optional: Task, dessen attachment:-Referenzen aufgelöst werden
+	BaseURL  string `json:"base_url,omitempty"` // Optional: Präfix für Attachment-URLs (absolute Links für CLI/Benachrichtigungen)
+}
+
+// RenderMarkdownResponse enthält das bereinigte HTML.
+type RenderMarkdownResponse struct {
+	HTML string `json:"html"`
+}
+
+// RenderedTask ist die Antwort von GET /api/tasks/{id}/render.
+type RenderedTask struct {
+	TaskID             string            `json:"task_id"`
+	Description        string            `json:"description"`         // HTML der Beschreibung
+	AcceptanceCriteria string            `json:"acceptance_criteria"` // HTML der Akzeptanzkriterien
+	Comments           []RenderedComment `json:"comments"`            // HTML aller Kommentare
+}
+
+// RenderedComment ist ein als HTML gerenderter Kommentar.
+type RenderedComment struct {
+	ID   string `json:"id"`
+	HTML string `json:"html"`
+}
+
+// ============================================================================
+// API Request/Response Types - Label
+// ============================================================================
+
+// CreateLabelRequest ist der Request-Body zum Erstellen eines Labels.
+type CreateLabelRequest struct {
+	Name  string `json:"name"`  // Pflichtfeld: Name (eindeutig)
+	Color string `json:"color"` // Hex-Farbe (Standard: grau)
+}
+
+// UpdateLabelRequest ist der Request-Body zum Aktualisieren eines Labels.
+type UpdateLabelRequest struct {
+	Name  *string `json:"name,omitempty"`
+	Color *string `json:"color,omitempty"`
+}
+
+// ============================================================================
+// API Request/Response Types - Board
+// ============================================================================
+
+// CreateBoardRequest ist der Request-Body zum Anlegen eines Boards.
+type CreateBoardRequest struct {
+	Name               string `json:"name"`                 // Pflichtfeld: Anzeigename
+	Description        string `json:"description"`          // Optional: Beschreibung
+	QueuePolicy        string `json:"queue_policy"`         // Optional: Standard "fifo"
+	MaxConcurrentTasks int    `json:"max_concurrent_tasks"` // Optional: 0 = nur globales Limit
+	PriorityAgingHours int    `json:"priority_aging_hours"` // Optional: 0 = kein Priority Aging
+}
+
+// UpdateBoardRequest ist der Request-Body zum Ändern eines Boards.
+type UpdateBoardRequest struct {
+	Name               *string `json:"name,omitempty"`
+	Description        *string `json:"description,omitempty"`
+	QueuePolicy        *string `json:"queue_policy,omitempty"`
+	MaxConcurrentTasks *int    `json:"max_concurrent_tasks,omitempty"`
+	PriorityAgingHours *int    `json:"priority_aging_hours,omitempty"` // 0 = aus
+}
+
+// ============================================================================
+// API Request/Response Types - Queue
+// ============================================================================
+
+// QueueEntry ist die Position eines Tasks in der Queue.
+type QueueEntry struct {
+	TaskID   string `json:"task_id"`
+	Position int    `json:"position"` // Position in der Queue des Projekts, 1 = wird als nächstes gestartet (bei FIFO)
+}
+
+// QueuePositionRequest ist der Request-Body für POST /api/tasks/{id}/queue-position.
+type QueuePositionRequest struct {
+	Position int `json:"position"` // Neue Position (1-basiert, wird auf die Queue-Länge begrenzt)
+}
+
+// ReorderQueueRequest ist der Request-Body für POST /api/queue/reorder.
+// Die genannten Tasks eines Projekts kommen in dieser Reihenfolge an den Anfang seiner Queue.
+type ReorderQueueRequest struct {
+	TaskIDs []string `json:"task_ids"`
+}
+
+// QueueState ist der Zustand der Queue (GET /api/admin/queue, WebSocket queue_state).
+// Angehalten oder in der Abkühlphase nach einem Rate-Limit startet der Runner
+// keine neuen Tasks, laufende werden fertig.
+type QueueState struct {
+	Paused         bool       `json:"paused"`
+	Reason         string     `json:"reason,omitempty"`          // Anlass, z.B. "Wartung"
+	PausedAt       *time.Time `json:"paused_at,omitempty"`       // Seit wann angehalten
+	CooldownUntil  *time.Time `json:"cooldown_until,omitempty"`  // Ende der Abkühlphase (siehe cooldown.go)
+	CooldownReason string     `json:"cooldown_reason,omitempty"` // Fehlermeldung des Rate-Limits
+	CooldownLevel  int        `json:"-"`                         // Rate-Limits in Folge, verdoppelt die Abkühlphase
+	Queued         int        `json:"queued"`                    // Wartende Tasks
+	Running        int        `json:"running"`                   // Laufende Prozesse
+}
+
+// PauseQueueRequest ist der (optionale) Request-Body für POST /api/admin/queue/pause.
+type PauseQueueRequest struct {
+	Reason string `json:"reason"`
+}
+
+// QueueETA ist die geschätzte Start- und Endzeit eines Tasks (GET /api/queue/eta).
+// Laufende Tasks haben nur eine Endzeit.
+type QueueETA struct {
+	TaskID    string     `json:"task_id"`
+	EtaStart  *time.Time `json:"eta_start,omitempty"`
+	EtaFinish *time.Time `json:"eta_finish,omitempty"`
+}
+
+// BudgetStatus ist der Stand eines Kostenbudgets im laufenden Zeitraum
+// (GET /api/stats/budgets). Ein ausgeschöpftes Budget hält die Queue des
+// Bereichs an, bis der Zeitraum endet.
+type BudgetStatus struct {
+	Scope       string    `json:"scope"`                  // global oder project
+	ProjectID   string    `json:"project_id,omitempty"`   // Nur für project
+	ProjectName string    `json:"project_name,omitempty"` // Nur für project
+	Period      string    `json:"period"`                 // daily oder monthly
+	LimitUSD    float64   `json:"limit_usd"`
+	SpentUSD    float64   `json:"spent_usd"` // Kosten der im Zeitraum beendeten Läufe
+	Percent     float64   `json:"percent"`
+	State       string    `json:"state"`     // ok, warning (ab 80%) oder exceeded
+	ResetsAt    time.Time `json:"resets_at"` // Beginn des nächsten Zeitraums
+}
+
+// RunDuration ist die Dauer eines abgeschlossenen RALPH-Laufs (für die ETA-Schätzung).
+type RunDuration struct {
+	ProjectID  string
+	TaskTypeID string
+	Duration   time.Duration
+}
+
+// ============================================================================
+// API Request/Response Types - Board Columns
+// ============================================================================
+
+// CreateBoardColumnRequest ist der Request-Body zum Anlegen einer eigenen Spalte.
+type CreateBoardColumnRequest struct {
+	Status   TaskStatus `json:"status"`    // Pflichtfeld: Schlüssel aus a-z, 0-9, - und _
+	Name     string     `json:"name"`      // Pflichtfeld: Anzeigename
+	Color    string     `json:"color"`     // Hex-Farbe (Standard: grau)
+	WIPLimit int        `json:"wip_limit"` // 0 = unbegrenzt
+	SLAHours int        `json:"sla_hours"` // 0 = kein Alarm
+	Role     string     `json:"role"`      // queue, progress, terminal oder leer
+	BoardID  string     `json:"board_id"`  // Board der Spalte (Standard: "default")
+}
+
+// UpdateBoardColumnRequest ist der Request-Body zum Ändern einer Spalte.
+// Die Rolle von System-Spalten kann nicht geändert werden.
+type UpdateBoardColumnRequest struct {
+	Name     *string `json:"name,omitempty"`
+	Color    *string `json:"color,omitempty"`
+	WIPLimit *int    `json:"wip_limit,omitempty"`
+	SLAHours *int    `json:"sla_hours,omitempty"`
+	Role     *string `json:"role,omitempty"`
+}
+
+// ReorderBoardColumnsRequest ist der Request-Body für PUT /api/columns.
+type ReorderBoardColumnsRequest struct {
+	Statuses []TaskStatus `json:"statuses"` // Alle Spalten in der neuen Reihenfolge
+}
+
 // ============================================================================
 // API Request/Response Types - Branch Protection
 // ============================================================================
 
-// CreateBranchRuleRequest ist der Request-Body zum Erstellen einer Branch-Regel.
+// CreateBranchRuleRequest ist der Request-Body zum Erstellen oder Ändern einer Branch-Regel.
 type CreateBranchRuleRequest struct {
-	BranchPattern string `json:"branch_pattern"` // Pattern (z.B. "main", "release/*")
+	BranchPattern string `json:"branch_pattern"` // Glob-Pattern (z.B. "main", "release/**", "!release/legacy")
+}
+
+// PathRuleRequest ist der Request-Body zum Erstellen oder Ändern einer Pfad-Regel.
+type PathRuleRequest struct {
+	PathPattern *string `json:"path_pattern,omitempty"` // Glob-Pattern (z.B. "migrations/**", "infra", "!infra/docs/**")
+	Action      *string `json:"action,omitempty"`       // "revert" (Standard) oder "block"
+}
+
+// ProjectSecret ist ein verschlüsselt gespeicherter Wert (z.B. API-Key) eines Projekts.
+// Der Wert verlässt den Server nie über die API.
+type ProjectSecret struct {
+	ID        string    `json:"id"`         // Eindeutige UUID
+	ProjectID string    `json:"project_id"` // Zugehöriges Projekt
+	Name      string    `json:"name"`       // Name der Umgebungsvariable (z.B. STRIPE_API_KEY)
+	Inject    bool      `json:"inject"`     // true = wird RALPH als Umgebungsvariable übergeben
+	Value     string    `json:"-"`          // AES-GCM-verschlüsselt, base64-kodiert
+	CreatedAt time.Time `json:"created_at"` // Erstellungszeitpunkt
+	UpdatedAt time.Time `json:"updated_at"` // Letzte Änderung
+}
+
+// DeployKey ist der SSH-Schlüssel, mit dem FORGE die Remotes eines Projekts
+// erreicht (siehe deploykeys.go). Der private Schlüssel verlässt den Server nie.
+type DeployKey struct {
+	ProjectID   string    `json:"project_id"`  // Zugehöriges Projekt
+	PublicKey   string    `json:"public_key"`  // OpenSSH-Format, beim Git-Host als Deploy-Key einzutragen
+	Fingerprint string    `json:"fingerprint"` // SHA256-Fingerprint wie von ssh-keygen -l
+	PrivateKey  string    `json:"-"`           // AES-GCM-verschlüsselt, base64-kodiert
+	CreatedAt   time.Time `json:"created_at"`  // Erstellungszeitpunkt
+}
+
+// Status eines Releases
+const (
+	ReleaseStatusDrafting  = "drafting"  // RALPH schreibt die Release Notes
+	ReleaseStatusPublished = "published" // Tag erstellt (und ggf. gepusht/veröffentlicht)
+	ReleaseStatusFailed    = "failed"    // Abgebrochen, siehe Error
+)
+
+// Release ist ein getaggter Stand eines Projekts mit Release Notes.
+type Release struct {
+	ID          string    `json:"id"`                     // Eindeutige UUID
+	ProjectID   string    `json:"project_id"`             // Zugehöriges Projekt
+	Tag         string    `json:"tag"`                    // Git-Tag (z.B. "v1.2.0")
+	Name        string    `json:"name"`                   // Anzeigename, Standard: Tag
+	PreviousTag string    `json:"previous_tag,omitempty"` // Letzter Tag vor diesem Release
+	Notes       string    `json:"notes"`                  // Release Notes (Markdown)
+	CommitCount int       `json:"commit_count"`           // Commits seit PreviousTag
+	CommitHash  string    `json:"commit_hash,omitempty"`  // Getaggter Commit
+	TaskID      string    `json:"task_id,omitempty"`      // RALPH-Task, der die Notes schreibt
+	Status      string    `json:"status"`                 // drafting, published, failed
+	Error       string    `json:"error,omitempty"`        // Fehlermeldung bei failed
+	GithubURL   string    `json:"github_url,omitempty"`   // GitHub Release
+	CreatedAt   time.Time `json:"created_at"`             // Erstellungszeitpunkt
+	UpdatedAt   time.Time `json:"updated_at"`             // Letzte Änderung
+}
+
+// CreateReleaseRequest ist der Request-Body für POST /api/projects/{id}/release.
+type CreateReleaseRequest struct {
+	Tag           string `json:"tag"`            // Optional: Standard ist die vorgeschlagene Version (siehe VersionProposal)
+	Bump          string `json:"bump"`           // Optional: major, minor oder patch statt des abgeleiteten Bumps
+	Name          string `json:"name"`           // Optional: Standard ist der Tag
+	Push          bool   `json:"push"`           // Branch und Tag zu origin pushen
+	GithubRelease bool   `json:"github_release"` // GitHub Release anlegen (pusht immer)
+	Draft         bool   `json:"draft"`          // GitHub Release als Entwurf
+	Prerelease    bool   `json:"prerelease"`     // GitHub Release als Vorabversion
+}
+
+// VersionFile ist eine Datei, die die Version des Projekts enthält (z.B. package.json).
+type VersionFile struct {
+	Path    string `json:"path"`    // Relativ zum Projektverzeichnis
+	Version string `json:"version"` // Aktuell eingetragene Version
+}
+
+// VersionProposal ist der Vorschlag für die nächste Version (GET/POST /api/projects/{id}/version).
+type VersionProposal struct {
+	Current    string        `json:"current"`               // Letzter Tag ("" = noch kein Release)
+	Next       string        `json:"next"`                  // Vorgeschlagener Tag ("" wenn Current keine Version ist)
+	Bump       string        `json:"bump"`                  // major, minor oder patch
+	Commits    int           `json:"commits"`               // Commits seit Current
+	Breaking   int           `json:"breaking"`              // Davon Breaking Changes
+	Features   int           `json:"features"`              // Davon Features
+	Patches    int           `json:"patches"`               // Davon Fixes und Sonstiges
+	Files      []VersionFile `json:"files"`                 // Versionsdateien, die angepasst werden
+	CommitHash string        `json:"commit_hash,omitempty"` // Getaggter Commit (nur POST)
+}
+
+// HealthCheck ist das Ergebnis einer Umgebungsprüfung (siehe doctor.go).
+type HealthCheck struct {
+	Name      string `json:"name"`                 // claude, git, github, database, uploads, disk, project
+	Status    string `json:"status"`               // ok, warning, error
+	Message   string `json:"message,omitempty"`    // Version, Pfad oder Fehlerbeschreibung
+	Hint      string `json:"hint,omitempty"`       // Wie sich das Problem beheben lässt
+	ProjectID string `json:"project_id,omitempty"` // Bei Projekt-Prüfungen: das Projekt
+}
+
+// ClaudeCapabilities beschreibt, was die installierte Claude CLI unterstützt (siehe claudecli.go).
+type ClaudeCapabilities struct {
+	Command            string    `json:"command"`              // Konfigurierter Befehl
+	Path               string    `json:"path,omitempty"`       // Aufgelöster Pfad
+	Version            string    `json:"version,omitempty"`    // Ausgabe von --version
+	Detected           bool      `json:"detected"`             // false: --help nicht erkannt, alle Flags angenommen
+	StreamJSONInput    bool      `json:"stream_json_input"`    // --input-format stream-json (Feedback im laufenden Task)
+	StreamJSONOutput   bool      `json:"stream_json_output"`   // --output-format stream-json (Pflicht)
+	Verbose            bool      `json:"verbose"`              // --verbose
+	SkipPermissions    bool      `json:"skip_permissions"`     // --dangerously-skip-permissions (Pflicht ohne Berechtigungsmodus)
+	DisallowedTools    bool      `json:"disallowed_tools"`     // --disallowedTools (Plan-Modus)
+	Resume             bool      `json:"resume"`               // --resume
+	AllowedTools       bool      `json:"allowed_tools"`        // --allowedTools (Claude-Einstellungen)
+	PermissionMode     bool      `json:"permission_mode"`      // --permission-mode (Claude-Einstellungen)
+	AppendSystemPrompt bool      `json:"append_system_prompt"` // --append-system-prompt (Claude-Einstellungen)
+	MCPConfig          bool      `json:"mcp_config"`           // --mcp-config (MCP-Server der Projekte)
+	Error              string    `json:"error,omitempty"`      // Befehl nicht gefunden oder nicht ausführbar
+	DetectedAt         time.Time `json:"detected_at"`          // Zeitpunkt der Prüfung
+}
+
+// DoctorReport fasst alle Umgebungsprüfungen zusammen; Status ist das schlechteste Ergebnis.
+type DoctorReport struct {
+	Status    string        `json:"status"`
+	Version   string        `json:"version"`
+	Checks    []HealthCheck `json:"checks"`
+	CheckedAt time.Time     `json:"checked_at"`
+
+	Claude *ClaudeCapabilities `json:"claude,omitempty"` // Erkannte Fähigkeiten der Claude CLI
+}
+
+// CloneTaskRequest ist der Request-Body für POST /api/tasks/{id}/clone.
+type CloneTaskRequest struct {
+	Title           string `json:"title,omitempty"`            // Optional: anderer Titel für die Kopie
+	CopyAttachments bool   `json:"copy_attachments,omitempty"` // Anhänge mitkopieren
+	Enqueue         bool   `json:"enqueue,omitempty"`          // Kopie direkt in die Queue stellen
+}
+
+// PlanRequest ist der Request-Body für PUT /api/tasks/{id}/plan.
+type PlanRequest struct {
+	Plan    *string `json:"plan,omitempty"` // Optional: bearbeiteter Plan
+	Approve bool    `json:"approve"`        // Plan freigeben
+	Start   bool    `json:"start"`          // Freigeben und Task in die Queue stellen
+}
+
+// TaskAssistRequest ist der Request-Body für POST /api/tasks/assist.
+type TaskAssistRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ProjectID   string `json:"project_id,omitempty"`  // Optional: Claude sieht sich den Code des Projekts an
+	ProjectDir  string `json:"project_dir,omitempty"` // Optional: Verzeichnis ohne Projekt
+}
+
+// TaskSuggestion sind die Vorschläge von Claude für das Task-Formular.
+// Leere Felder hat Claude nicht (sinnvoll) vorgeschlagen.
+type TaskSuggestion struct {
+	AcceptanceCriteria string `json:"acceptance_criteria"`
+	TaskTypeID         string `json:"task_type_id,omitempty"`
+	TaskTypeName       string `json:"task_type_name,omitempty"`
+	Priority           int    `json:"priority,omitempty"`       // 1-3
+	MaxIterations      int    `json:"max_iterations,omitempty"` // Geschätzte Iterationen
+	Reason             string `json:"reason,omitempty"`         // Begründung der Schätzung
+}
+
+// DuplicateTask ist ein offener Task, der einem neuen Task sehr ähnlich ist.
+type DuplicateTask struct {
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	Status     TaskStatus `json:"status"`
+	Similarity float64    `json:"similarity"` // 0-1, Trigramm-Ähnlichkeit
+}
+
+// SubtaskProposal ist ein Teil-Task im Aufteilungsvorschlag eines Tasks.
+// DependsOn verweist über den Index auf andere Teil-Tasks desselben Vorschlags.
+type SubtaskProposal struct {
+	Title              string `json:"title"`
+	Description        string `json:"description"`
+	AcceptanceCriteria string `json:"acceptance_criteria"`
+	DependsOn          []int  `json:"depends_on"` // Indizes der Teil-Tasks, die vorher erledigt sein müssen
+}
+
+// SplitProposal ist die Antwort von POST /api/tasks/{id}/split und der
+// Request-Body für POST /api/tasks/{id}/subtasks.
+type SplitProposal struct {
+	Subtasks []SubtaskProposal `json:"subtasks"`
+	Queue    bool              `json:"queue,omitempty"` // Teil-Tasks gleich in die Queue stellen (nur subtasks)
+}
+
+// TaskEvent ist ein Status-Wechsel eines Tasks: ab CreatedAt stand er in Status.
+type TaskEvent struct {
+	ID        string     `json:"id"`
+	TaskID    string     `json:"task_id"`
+	Status    TaskStatus `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TaskTiming ist die Antwort von GET /api/tasks/{id}/timing.
+// Zeiten sind Sekunden Wanduhrzeit, der aktuelle Aufenthalt zählt bis jetzt.
+type TaskTiming struct {
+	TaskID         string               `json:"task_id"`
+	Status         TaskStatus           `json:"status"`
+	Since          time.Time            `json:"since"`           // Seit wann der Task im aktuellen Status ist
+	CurrentSeconds int64                `json:"current_seconds"` // Dauer des aktuellen Aufenthalts
+	TotalSeconds   map[TaskStatus]int64 `json:"total_seconds"`   // Summe je Status über alle Aufenthalte
+	SLAHours       int                  `json:"sla_hours"`       // SLA der aktuellen Spalte (0 = keins)
+	Overdue        bool                 `json:"overdue"`         // true = SLA überschritten
+	Events         []TaskEvent          `json:"events"`
+}
+
+// SLABreach ist ein Task, der länger als das SLA seiner Spalte darin steht.
+type SLABreach struct {
+	EventID    string
+	TaskID     string
+	Title      string
+	Status     TaskStatus
+	ColumnName string
+	SLAHours   int
+	Since      time.Time
+}
+
+// CherryPickRequest ist der Request-Body für POST /api/tasks/{id}/cherry-pick.
+type CherryPickRequest struct {
+	TargetBranch string `json:"target_branch"` // Branch, auf den die Commits des Tasks sollen
+	Push         bool   `json:"push"`          // Ziel-Branch danach zu origin pushen
+}
+
+// CherryPickResponse ist die Antwort auf POST /api/tasks/{id}/cherry-pick.
+type CherryPickResponse struct {
+	Branch  string   `json:"branch"`  // Ziel-Branch
+	Commits []string `json:"commits"` // Neue Commits auf dem Ziel-Branch, älteste zuerst
+	Pushed  bool     `json:"pushed"`  // Ziel-Branch wurde gepusht
+}
+
+// StashEntry ist ein Eintrag im Git-Stash eines Projekts.
+type StashEntry struct {
+	Ref       string    `json:"ref"`        // z.B. stash@{0}
+	Branch    string    `json:"branch"`     // Branch, auf dem gestasht wurde
+	Message   string    `json:"message"`    // Nachricht des Stashes
+	CreatedAt time.Time `json:"created_at"` // Zeitpunkt des Stashes
+}
+
+// StashRequest ist der Request-Body für POST /api/projects/{id}/stash.
+type StashRequest struct {
+	Message string `json:"message"` // Optional: Nachricht, sonst "FORGE stash"
+}
+
+// PopStashRequest ist der Request-Body für POST /api/projects/{id}/stash/pop.
+type PopStashRequest struct {
+	Ref string `json:"ref"` // Optional: Stash-Eintrag, sonst stash@{0}
+}
+
+// BumpVersionRequest ist der Request-Body für POST /api/projects/{id}/version.
+type BumpVersionRequest struct {
+	Bump string `json:"bump"` // Optional: major, minor oder patch statt des abgeleiteten Bumps
+	Push bool   `json:"push"` // Branch und Tag zu origin pushen
+}
+
+// CreateSecretRequest ist der Request-Body für POST /api/projects/{id}/secrets.
+type CreateSecretRequest struct {
+	Name   string `json:"name"`             // Pflichtfeld: Name der Umgebungsvariable
+	Value  string `json:"value"`            // Pflichtfeld: Klartext-Wert (wird verschlüsselt)
+	Inject *bool  `json:"inject,omitempty"` // Optional: Standard true
+}
+
+// UpdateSecretRequest ist der Request-Body für PUT /api/projects/{id}/secrets/{secretId}.
+type UpdateSecretRequest struct {
+	Name   *string `json:"name,omitempty"`
+	Value  *string `json:"value,omitempty"` // Neuer Wert, fehlt = unverändert
+	Inject *bool   `json:"inject,omitempty"`
+}
+
+// ProjectSecretsResponse ist die Response von GET /api/projects/{id}/secrets.
+type ProjectSecretsResponse struct {
+	Enabled bool            `json:"enabled"` // false = FORGE_SECRETS_KEY nicht gesetzt
+	Secrets []ProjectSecret `json:"secrets"`
+}
+
+// TestBranchRulesRequest ist der Request-Body für POST /api/projects/{id}/rules/test.
+type TestBranchRulesRequest struct {
+	Branch string `json:"branch"` // Zu prüfender Branch-Name
+}
+
+// TestBranchRulesResponse zeigt, welche Regeln auf einen Branch passen.
+type TestBranchRulesResponse struct {
+	Branch    string                 `json:"branch"`
+	Protected bool                   `json:"protected"` // true = FORGE verweigert Pushes auf den Branch
+	Matches   []BranchProtectionRule `json:"matches"`   // Passende Regeln inkl. Ausnahmen ("!...")
 }
 
 // ============================================================================
@@ -279,14 +1258,38 @@ type CreateBranchRuleRequest struct {
 
 // CreateGithubRepoRequest ist der Request-Body zum Erstellen eines GitHub-Repos.
 type CreateGithubRepoRequest struct {
-	RepoName    string `json:"repo_name"`    // Repository-Name (optional, sonst Projektname)
-	Description string `json:"description"`  // Optional: Repo-Beschreibung
-	Private     bool   `json:"private"`      // true = privates Repository
+	RepoName      string   `json:"repo_name"`                // Repository-Name (optional, sonst Projektname)
+	Description   string   `json:"description"`              // Optional: Repo-Beschreibung
+	Private       bool     `json:"private"`                  // true = privates Repository
+	Owner         string   `json:"owner,omitempty"`          // Optional: Organisation (leer = eigener Account)
+	Template      string   `json:"template,omitempty"`       // Optional: Template-Repository als owner/repo
+	DefaultBranch string   `json:"default_branch,omitempty"` // Optional: Name des Default-Branches (z.B. "main")
+	Topics        []string `json:"topics,omitempty"`         // Optional: Topics des Repositories
+	Gitignore     string   `json:"gitignore,omitempty"`      // Optional: .gitignore-Vorlage von GitHub (z.B. "Go")
+	License       string   `json:"license,omitempty"`        // Optional: Lizenz-Schlüssel (z.B. "mit")
+}
+
+// PRReviewComment ist ein Review oder ein Kommentar zum Diff am Pull Request eines Tasks.
+type PRReviewComment struct {
+	Author    string    `json:"author"`          // GitHub-Login
+	Body      string    `json:"body"`            // Text des Kommentars
+	Path      string    `json:"path,omitempty"`  // Datei bei Kommentaren zum Diff
+	Line      int       `json:"line,omitempty"`  // Zeile bei Kommentaren zum Diff
+	State     string    `json:"state,omitempty"` // Ergebnis eines Reviews, z.B. CHANGES_REQUESTED
+	URL       string    `json:"url"`             // Link zum Kommentar auf GitHub
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PRReviewFeedback ist die Response von GET /api/tasks/{id}/review-comments.
+type PRReviewFeedback struct {
+	Comments []PRReviewComment `json:"comments"` // Neue Kommentare, älteste zuerst
+	Message  string            `json:"message"`  // Fortsetzungs-Nachricht für RALPH ("" ohne Kommentare)
 }
 
 // DeploymentRequest ist der Request-Body für Task-Deployment.
 type DeploymentRequest struct {
 	CommitMessage string `json:"commit_message,omitempty"` // Optional: Commit-Nachricht
+	Environment   string `json:"environment,omitempty"`    // Optional: Umgebung (z.B. "staging"), sonst die Deploy-Befehle des Projekts
 }
 
 // DeploymentResponse ist die Response nach erfolgreichem Deployment.
@@ -295,8 +1298,312 @@ type DeploymentResponse struct {
 	CommitHash   string `json:"commit_hash,omitempty"`   // SHA des Commits
 	PushURL      string `json:"push_url,omitempty"`      // Remote-URL
 	ErrorMessage string `json:"error_message,omitempty"` // Fehlermeldung falls !success
+	Deployment   *Deployment `json:"deployment,omitempty"` // Gestartete Deploy-Befehle (falls konfiguriert)
 }
 
+// ProjectEnvironment ist eine Deploy-Umgebung eines Projekts (z.B. staging, prod) mit eigenen Befehlen.
+type ProjectEnvironment struct {
+	ID            string    `json:"id"`                       // Eindeutige UUID
+	ProjectID     string    `json:"project_id"`               // Zugehöriges Projekt
+	Name          string    `json:"name"`                     // Eindeutig pro Projekt (z.B. "prod")
+	DeployCommand string    `json:"deploy_command"`           // Befehle nach dem Push (eine Zeile pro Befehl)
+	DeployTimeout int       `json:"deploy_timeout,omitempty"` // Timeout in Sekunden (0 = Standard)
+	CreatedAt     time.Time `json:"created_at"`               // Erstellungszeitpunkt
+	UpdatedAt     time.Time `json:"updated_at"`               // Letzte Änderung
+}
+
+// CreateEnvironmentRequest ist der Request-Body für POST /api/projects/{id}/environments.
+type CreateEnvironmentRequest struct {
+	Name          string `json:"name"`           // Pflichtfeld
+	DeployCommand string `json:"deploy_command"` // Pflichtfeld
+	DeployTimeout int    `json:"deploy_timeout"` // Optional: Sekunden
+}
+
+// UpdateEnvironmentRequest ist der Request-Body für PUT /api/projects/{id}/environments/{envId}.
+type UpdateEnvironmentRequest struct {
+	Name          *string `json:"name,omitempty"`
+	DeployCommand *string `json:"deploy_command,omitempty"`
+	DeployTimeout *int    `json:"deploy_timeout,omitempty"`
+}
+
+// ProjectMCPServer ist ein MCP-Server, den Claude in den Tasks eines Projekts nutzen kann.
+// In Argumenten und Umgebungsvariablen wird ${NAME} durch das Projekt-Secret NAME ersetzt.
+type ProjectMCPServer struct {
+	ID        string    `json:"id"`         // Eindeutige UUID
+	ProjectID string    `json:"project_id"` // Zugehöriges Projekt
+	Name      string    `json:"name"`       // Eindeutig pro Projekt (z.B. "postgres")
+	Command   string    `json:"command"`    // Startbefehl (z.B. "npx")
+	Args      MCPArgs   `json:"args"`       // Argumente des Befehls
+	Env       TaskEnv   `json:"env"`        // Umgebungsvariablen des Servers
+	Enabled   bool      `json:"enabled"`    // false = wird nicht an Claude übergeben
+	CreatedAt time.Time `json:"created_at"` // Erstellungszeitpunkt
+	UpdatedAt time.Time `json:"updated_at"` // Letzte Änderung
+}
+
+// CreateMCPServerRequest ist der Request-Body für POST /api/projects/{id}/mcp-servers.
+type CreateMCPServerRequest struct {
+	Name    string   `json:"name"`    // Pflichtfeld
+	Command string   `json:"command"` // Pflichtfeld
+	Args    []string `json:"args"`    // Optional
+	Env     TaskEnv  `json:"env"`     // Optional
+	Enabled *bool    `json:"enabled"` // Optional: Standard true
+}
+
+// UpdateMCPServerRequest ist der Request-Body für PUT /api/projects/{id}/mcp-servers/{serverId}.
+type UpdateMCPServerRequest struct {
+	Name    *string   `json:"name,omitempty"`
+	Command *string   `json:"command,omitempty"`
+	Args    *[]string `json:"args,omitempty"`    // Ersetzt alle Argumente
+	Env     *TaskEnv  `json:"env,omitempty"`     // Ersetzt alle Umgebungsvariablen
+	Enabled *bool     `json:"enabled,omitempty"`
+}
+
+// Status einer Ausführung der Deploy-Befehle
+const (
+	DeploymentRunning     = "running"
+	DeploymentSucceeded   = "succeeded"
+	DeploymentFailed      = "failed"
+	DeploymentTimedOut    = "timed_out"
+	DeploymentInterrupted = "interrupted" // Server wurde währenddessen beendet
+)
+
+// Deployment ist ein Deploy eines Projekts: Push und ggf. Ausführung der Deploy-Befehle.
+type Deployment struct {
+	ID         string     `json:"id"`                    // Eindeutige UUID
+	ProjectID  string     `json:"project_id"`            // Zugehöriges Projekt
+	TaskID      string     `json:"task_id,omitempty"`     // Deployter Task ("" bei Rollbacks)
+	Environment string     `json:"environment,omitempty"` // Umgebung ("" = Deploy-Befehle des Projekts)
+	CommitHash  string     `json:"commit_hash,omitempty"` // Deployter Commit
+	Command     string     `json:"command"`               // Ausgeführte Befehle ("" = nur Push)
+	RollbackOf  string     `json:"rollback_of,omitempty"` // Deployment, auf dessen Stand zurückgesetzt wurde
+	Status     string     `json:"status"`                // running, succeeded, failed, timed_out, interrupted
+	ExitCode   int        `json:"exit_code"`             // Exit-Code des fehlgeschlagenen Befehls
+	Output     string     `json:"output,omitempty"`      // Ausgabe (gekürzt auf das Ende)
+	StartedAt  time.Time  `json:"started_at"`            // Start
+	FinishedAt *time.Time `json:"finished_at,omitempty"` // Ende (nil solange running)
+}
+
+// TaskRun ist ein einzelner RALPH-Lauf eines Tasks (GET /api/tasks/{id}/runs).
+// Anders als die Logs des Tasks bleibt jeder Lauf erhalten, auch nach einem Neustart des Tasks.
+type TaskRun struct {
+	ID           string     `json:"id"`
+	TaskID       string     `json:"task_id"`
+	Number       int        `json:"number"`                  // Laufende Nummer je Task, 1 = erster Lauf
+	Trigger      string     `json:"trigger"`                 // start, plan, continue, resume
+	Outcome      string     `json:"outcome"`                 // running, success, blocked, ... ("" = vor der Aufzeichnung)
+	Error        string     `json:"error,omitempty"`         // Grund bei blocked/iteration_limit/failed
+	FailureClass string     `json:"failure_class,omitempty"` // Art des Fehlers bei failed (siehe failures.go)
+	Iterations   int        `json:"iterations"`              // Iteration am Ende des Laufs
+	StartCommit  string     `json:"start_commit,omitempty"`  // HEAD beim Start
+	EndCommit    string     `json:"end_commit,omitempty"`    // HEAD am Ende
+	Turns        int        `json:"turns"`                   // Claude-Turns laut result-Events
+	InputTokens  int64      `json:"input_tokens"`
+	OutputTokens int64      `json:"output_tokens"`
+	CostUSD      float64    `json:"cost_usd"`
+	LogsURL      string     `json:"logs_url"` // Logs nur dieses Laufs
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"` // nil solange der Lauf läuft
+}
+
+// RunMessage ist eine Nachricht an Claude während eines Laufs: der Prompt oder späteres Feedback.
+type RunMessage struct {
+	Text   string    `json:"text"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// TranscriptTurn ist ein Schritt des Gesprächs eines Laufs.
+type TranscriptTurn struct {
+	Role    string          `json:"role"`               // user, assistant, thinking, tool_use, tool_result, result, forge, output
+	Text    string          `json:"text,omitempty"`     // Nachricht, Ergebnis oder Ausgabe
+	Tool    string          `json:"tool,omitempty"`     // Name des Tools (tool_use)
+	ToolID  string          `json:"tool_id,omitempty"`  // Verknüpft tool_use und tool_result
+	Input   json.RawMessage `json:"input,omitempty"`    // Parameter des Tool-Aufrufs
+	IsError bool            `json:"is_error,omitempty"` // Fehlgeschlagenes Tool oder Ergebnis
+	SentAt  *time.Time      `json:"sent_at,omitempty"`  // Zeitpunkt bei Nachrichten an Claude
+}
+
+// RunTranscript ist die Antwort von GET /api/tasks/{id}/runs/{run}/transcript.
+type RunTranscript struct {
+	TaskID string           `json:"task_id"`
+	Run    int              `json:"run"`
+	Model  string           `json:"model,omitempty"` // Laut init-Event der CLI
+	Prompt string           `json:"prompt"`          // Genau so an Claude gesendet ("" bei Läufen vor der Aufzeichnung)
+	Turns  []TranscriptTurn `json:"turns"`
+}
+
+// ShareTaskRequest ist der (optionale) Request-Body für POST /api/tasks/{id}/share.
+type ShareTaskRequest struct {
+	ExpiresInHours int `json:"expires_in_hours"` // Gültigkeit des Links (Standard: 7 Tage, höchstens 30)
+}
+
+// TaskShare ist ein signierter Link, über den ein Task ohne Zugang zum Board lesbar ist.
+type TaskShare struct {
+	URL       string    `json:"url"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SharedTask ist die Antwort von GET /api/share/{token}: der Stand eines Tasks,
+// nur lesend und ohne die übrigen Daten des Boards.
+type SharedTask struct {
+	Title            string           `json:"title"`
+	Description      string           `json:"description,omitempty"`
+	Status           TaskStatus       `json:"status"`
+	StatusName       string           `json:"status_name"` // Name der Spalte
+	Running          bool             `json:"running"`     // RALPH arbeitet gerade daran
+	CurrentIteration int              `json:"current_iteration"`
+	MaxIterations    int              `json:"max_iterations"`
+	WorkingBranch    string           `json:"working_branch,omitempty"`
+	Error            string           `json:"error,omitempty"`
+	StartedAt        *time.Time       `json:"started_at,omitempty"`
+	FinishedAt       *time.Time       `json:"finished_at,omitempty"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+	Log              []TranscriptTurn `json:"log"`            // Ende des Gesprächs, aus den Logs gelesen
+	Diff             string           `json:"diff,omitempty"` // Änderungen des Tasks als Patch
+	ExpiresAt        time.Time        `json:"expires_at"`     // Ende der Gültigkeit des Links
+}
+
+// User ist ein über GitHub-OAuth angemeldeter Benutzer (siehe auth.go).
+type User struct {
+	ID          string    `json:"id"`
+	GithubID    int64     `json:"github_id"`
+	Login       string    `json:"login"`
+	Name        string    `json:"name"`
+	AvatarURL   string    `json:"avatar_url"`
+	GithubToken string    `json:"-"` // OAuth-Token des Benutzers (nie ausgeliefert)
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+
+	// Eigene Einstellungen (siehe profile.go)
+	PersonalToken    string `json:"-"`                  // Selbst hinterlegter GitHub-Token, geht dem OAuth-Token vor
+	PersonalTokenSet bool   `json:"personal_token_set"` // true = eigener Token hinterlegt
+	GitName          string `json:"git_name"`           // Autor-Name für Commits (leer = GitHub-Name)
+	GitEmail         string `json:"git_email"`          // Autor-E-Mail für Commits (leer = GitHub-noreply-Adresse)
+}
+
+// UserProfile ist die Antwort von GET/PUT /api/profile: der Benutzer und wie
+// FORGE in seinem Namen committet und sich bei GitHub anmeldet.
+type UserProfile struct {
+	User        *User  `json:"user"`
+	AuthorName  string `json:"author_name"`  // Autor der Commits, die der Benutzer auslöst
+	AuthorEmail string `json:"author_email"`
+	TokenSource string `json:"token_source"` // personal, github_login oder shared
+}
+
+// UpdateProfileRequest ist der Request-Body für PUT /api/profile.
+// Ein leerer personal_token entfernt den eigenen Token.
+type UpdateProfileRequest struct {
+	GitName       *string `json:"git_name,omitempty"`
+	GitEmail      *string `json:"git_email,omitempty"`
+	PersonalToken *string `json:"personal_token,omitempty"`
+}
+
+// AuthStatus ist die Antwort von GET /api/auth/status.
+type AuthStatus struct {
+	Enabled bool  `json:"enabled"`  // Anmeldung erforderlich (FORGE_GITHUB_CLIENT_ID gesetzt)
+	WebFlow bool  `json:"web_flow"` // Anmeldung im Browser möglich (Client-Secret gesetzt)
+	User    *User `json:"user"`     // Angemeldeter Benutzer, nil wenn keiner
+}
+
+// DeviceLogin ist die Antwort von POST /api/auth/github/device: der Code, den
+// der Benutzer auf GitHub eingibt, und der Device-Code zum Abfragen des Ergebnisses.
+type DeviceLogin struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"` // Sekunden
+	Interval        int    `json:"interval"`   // Mindestabstand der Abfragen in Sekunden
+}
+
+// DeviceTokenRequest ist der Request-Body für POST /api/auth/github/device/token.
+type DeviceTokenRequest struct {
+	DeviceCode string `json:"device_code"`
+}
+
+// LoginResult ist die Antwort einer abgeschlossenen Anmeldung per Device-Flow.
+// Token ist das Sitzungs-Token, für die CLI als FORGE_TOKEN.
+type LoginResult struct {
+	User      *User     `json:"user"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TaskTriage ist Claudes Analyse eines blockierten Tasks (siehe triage.go).
+type TaskTriage struct {
+	Summary   string         `json:"summary"` // Warum der Task feststeckt
+	Actions   []TriageAction `json:"actions"` // Vorgeschlagene nächste Schritte
+	Error     string         `json:"error"`   // Fehlermeldung, zu der die Analyse gehört
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// TriageAction ist ein vorgeschlagener nächster Schritt für einen blockierten Task.
+type TriageAction struct {
+	Kind string `json:"kind"` // context, split, manual, retry
+	Text string `json:"text"`
+}
+
+// RunFileChange ist eine Datei, die ein Lauf geändert hat.
+type RunFileChange struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"` // Hinzugefügte Zeilen
+	Deletions int    `json:"deletions"` // Entfernte Zeilen
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// ComparedRun ist ein Lauf im Vergleich, mit den Dateien, die er geändert hat.
+type ComparedRun struct {
+	TaskRun
+	DurationSeconds int64           `json:"duration_seconds"`
+	Files           []RunFileChange `json:"files"`
+	FilesError      string          `json:"files_error,omitempty"` // Warum die Dateien fehlen
+}
+
+// RunComparison ist die Antwort von GET /api/tasks/{id}/runs/compare.
+// Die Differenzen sind jeweils B minus A.
+type RunComparison struct {
+	A                    ComparedRun `json:"a"`
+	B                    ComparedRun `json:"b"`
+	FilesOnlyInA         []string    `json:"files_only_in_a"`
+	FilesOnlyInB         []string    `json:"files_only_in_b"`
+	FilesInBoth          []string    `json:"files_in_both"`
+	IterationsDelta      int         `json:"iterations_delta"`
+	TokensDelta          int64       `json:"tokens_delta"`
+	CostDeltaUSD         float64     `json:"cost_delta_usd"`
+	DurationDeltaSeconds int64       `json:"duration_delta_seconds"`
+	OutcomeChange        string      `json:"outcome_change"` // same, better, worse, unknown
+}
+
+// Auslöser eines RALPH-Laufs
+const (
+	RunTriggerStart    = "start"    // Task nach In Progress verschoben oder aus der Queue gestartet
+	RunTriggerPlan     = "plan"     // Planungslauf
+	RunTriggerContinue = "continue" // Fortsetzung mit Feedback
+	RunTriggerResume   = "resume"   // Fortsetzung ohne Feedback, z.B. nach einem Neustart
+	RunTriggerRetry    = "retry"    // Erneuter Versuch nach einem gescheiterten Lauf
+)
+
+// Ergebnis eines RALPH-Laufs
+const (
+	RunRunning        = "running"
+	RunSuccess        = "success"         // [SUCCESS], Task in Review
+	RunBlocked        = "blocked"         // [BLOCKED] oder Fehler
+	RunIterationLimit = "iteration_limit" // Maximale Iterationen erreicht
+	RunPlanned        = "planned"         // Planungslauf beendet
+	RunExited         = "exited"          // Prozess ohne Marker und ohne Fehler beendet
+	RunFailed         = "failed"          // Fehlerergebnis oder Exit-Code ungleich 0 ohne Marker (Art in FailureClass)
+	RunStopped        = "stopped"         // Vom Benutzer gestoppt
+	RunInterrupted    = "interrupted"     // Server wurde währenddessen beendet
+)
+
+// Art des Fehlers eines gescheiterten Laufs (siehe failures.go)
+const (
+	FailureRateLimit    = "rate_limit"    // Rate- oder Nutzungslimit der API erreicht
+	FailureAuth         = "auth"          // Nicht angemeldet oder ungültiger API-Key
+	FailureOOM          = "oom"           // Prozess wegen Speichermangels beendet
+	FailureContextLimit = "context_limit" // Kontextfenster des Modells voll
+	FailureTransient    = "transient"     // Sonstiger Absturz, API- oder Netzwerkfehler
+)
+
 // MergeResponse is the response from the merge endpoint.
 type MergeResponse struct {
 	Success  bool   `json:"success"`             // true = merge successful
@@ -312,6 +1619,11 @@ type ProjectInfo struct {
 	Path      string `json:"path"`        // Absoluter Pfad
 	Name      string `json:"name"`        // Verzeichnisname
 	IsGitRepo bool   `json:"is_git_repo"` // true = .git existiert
+
+	Language  string `json:"language,omitempty"`  // Erkannte Sprache, z.B. Go (siehe projectdetect.go)
+	Framework string `json:"framework,omitempty"` // Erkanntes Framework, z.B. React
+
+	WorkspaceRoot string `json:"workspace_root,omitempty"` // Workspace, zu dem das Paket gehört
 }
 
 // ============================================================================
@@ -325,6 +1637,39 @@ type MergeConflict struct {
 	TargetBranch  string         `json:"target_branch"`  // Ziel-Branch (z.B. main)
 	Files         []ConflictFile `json:"files"`          // Liste der Dateien mit Konflikten
 	Message       string         `json:"message"`        // Beschreibung des Konflikts
+
+	UpstreamCommit   string `json:"upstream_commit,omitempty"`    // Remote-Stand, der gemergt werden muss
+	ResolutionTaskID string `json:"resolution_task_id,omitempty"` // Task, der den Konflikt auflöst
+}
+
+// Phasen, in denen ein Konflikt mit dem Remote auftreten kann
+const (
+	ConflictStageStart = "start" // Beim Aktualisieren des Branches vor dem Task-Start
+	ConflictStagePush  = "push"  // Beim Push nach dem Deploy eines Tasks
+
+	ConflictStageCherryPick = "cherry-pick" // Beim Cherry-Pick der Commits eines Tasks auf einen anderen Branch
+)
+
+// Status eines TaskConflict
+const (
+	ConflictOpen     = "open"
+	ConflictResolved = "resolved"
+)
+
+// TaskConflict ist ein Merge-Konflikt mit dem Remote, der einen Task aufhält,
+// verknüpft mit dem Task, der ihn auflöst.
+type TaskConflict struct {
+	ID               string     `json:"id"`                     // Eindeutige UUID
+	TaskID           string     `json:"task_id"`                // Aufgehaltener Task
+	ResolutionTaskID string     `json:"resolution_task_id"`     // Task, der den Konflikt auflöst
+	Stage            string     `json:"stage"`                  // start oder push
+	Branch           string     `json:"branch"`                 // Lokaler Branch
+	Upstream         string     `json:"upstream"`               // Upstream des Branches, z.B. origin/main
+	UpstreamCommit   string     `json:"upstream_commit"`        // Remote-Stand zum Zeitpunkt des Konflikts
+	Files            []string   `json:"files"`                  // Dateien mit Konflikten
+	Status           string     `json:"status"`                 // open, resolved
+	CreatedAt        time.Time  `json:"created_at"`             // Zeitpunkt des Konflikts
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"` // Zeitpunkt der Auflösung
 }
 
 // ConflictFile enthält Details zu einer konfliktierenden Datei.
@@ -334,6 +1679,47 @@ type ConflictFile struct {
 	TheirsLines string `json:"theirs_lines"` // Ihre Version (working branch)
 }
 
+// Laufende Git-Operationen, die durch Konflikte angehalten werden können
+const (
+	GitOpMerge      = "merge"
+	GitOpRebase     = "rebase"
+	GitOpCherryPick = "cherry-pick"
+	GitOpRevert     = "revert"
+)
+
+// ConflictState ist der Konfliktzustand eines Projekts (GET /api/projects/{id}/conflicts).
+type ConflictState struct {
+	Operation string           `json:"operation"` // merge, rebase, cherry-pick, revert oder leer
+	Files     []ConflictDetail `json:"files"`     // Dateien, die noch Konflikte haben
+}
+
+// ConflictDetail beschreibt eine Datei mit Konflikten: die drei Versionen und die Konflikt-Hunks.
+// Bei einem Rebase ist "ours" der Stand, auf den rebased wird, und "theirs" der neu angewandte Commit.
+type ConflictDetail struct {
+	Path    string         `json:"path"`              // Pfad relativ zum Repository
+	Base    string         `json:"base"`              // Gemeinsamer Vorfahre
+	Ours    string         `json:"ours"`              // Unsere Version
+	Theirs  string         `json:"theirs"`            // Ihre Version
+	Deleted string         `json:"deleted,omitempty"` // ours oder theirs, wenn eine Seite die Datei gelöscht hat
+	Binary  bool           `json:"binary,omitempty"`  // Binärdatei: keine Inhalte und Hunks
+	Hunks   []ConflictHunk `json:"hunks"`             // Konfliktstellen
+}
+
+// ConflictHunk ist eine Konfliktstelle in einer Datei.
+type ConflictHunk struct {
+	Line   int    `json:"line"`   // Zeile der Konfliktstelle im zusammengeführten Text (1-basiert)
+	Base   string `json:"base"`   // Text des gemeinsamen Vorfahren
+	Ours   string `json:"ours"`   // Unser Text
+	Theirs string `json:"theirs"` // Ihr Text
+}
+
+// ResolveConflictRequest ist der Request-Body für POST /api/projects/{id}/conflicts/resolve.
+type ResolveConflictRequest struct {
+	Path       string `json:"path"`       // Datei mit Konflikten
+	Resolution string `json:"resolution"` // ours, theirs oder manual
+	Content    string `json:"content"`    // Bei manual: neuer Inhalt der Datei
+}
+
 // MergeResult enthält das Ergebnis eines Merge-Versuchs.
 type MergeResult struct {
 	Success  bool           `json:"success"`           // true = Merge erfolgreich
@@ -357,3 +1743,237 @@ type SetWorkingBranchRequest struct {
 	Branch string `json:"branch"`
 	Create bool   `json:"create"` // true = neuen Branch von main erstellen
 }
+
+// ProjectHealth für GET /api/projects/{id}/health.
+// Wird im Hintergrund gecacht, damit die Projektliste nicht für jedes Projekt git aufruft.
+type ProjectHealth struct {
+	ProjectID   string      `json:"project_id"`
+	IsGitRepo   bool        `json:"is_git_repo"`
+	Branch      string      `json:"branch,omitempty"`       // Aktuell ausgecheckter Branch
+	Dirty       bool        `json:"dirty"`                  // true = uncommittete Änderungen
+	HasUpstream bool        `json:"has_upstream"`           // false = Ahead/Behind nicht bekannt
+	Ahead       int         `json:"ahead"`                  // Commits, die noch nicht gepusht sind
+	Behind      int         `json:"behind"`                 // Commits, die noch nicht gepullt sind
+	LastCommit  *CommitInfo `json:"last_commit,omitempty"`  // Letzter Commit auf HEAD
+	RemoteURL   string      `json:"remote_url,omitempty"`   // URL von origin
+	RepoRoot    string      `json:"repo_root,omitempty"`    // Repository, wenn das Projekt ein Unterverzeichnis davon ist
+	Error       string      `json:"error,omitempty"`        // Fehler beim letzten git-Aufruf
+	CheckedAt   time.Time   `json:"checked_at"`             // Zeitpunkt der Ermittlung
+}
+
+// CommitInfo beschreibt einen einzelnen Commit.
+type CommitInfo struct {
+	Hash    string    `json:"hash"`
+	Subject string    `json:"subject"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+}
+
+// ProjectCommit ist ein Commit in GET /api/projects/{id}/log.
+type ProjectCommit struct {
+	CommitInfo
+	TaskID    string `json:"task_id,omitempty"`    // Task aus dem Forge-Task-Trailer
+	TaskTitle string `json:"task_title,omitempty"` // Titel des Tasks (falls er noch existiert)
+}
+
+// TaskCommit ist ein Commit, der per Forge-Task-Trailer einem Task zugeordnet ist.
+type TaskCommit struct {
+	CommitInfo
+	TaskID    string `json:"task_id"`
+	ProjectID string `json:"project_id,omitempty"` // Projekt, in dem der Commit gefunden wurde
+}
+
+// Checkpoint ist der Stand eines Tasks nach einer Iteration (GET /api/tasks/{id}/checkpoints).
+type Checkpoint struct {
+	ID        int       `json:"id"`        // Laufende Nummer pro Task
+	Iteration int       `json:"iteration"` // Abgeschlossene Iteration
+	Commit    string    `json:"commit"`    // Snapshot-Commit mit dem ganzen Working Tree
+	Base      string    `json:"base"`      // HEAD, als der Checkpoint erstellt wurde
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RestoreCheckpointRequest ist der Request-Body für POST /api/tasks/{id}/restore-checkpoint.
+type RestoreCheckpointRequest struct {
+	Checkpoint int `json:"checkpoint"` // ID aus GET /api/tasks/{id}/checkpoints
+}
+
+// ProjectLog ist die Antwort von GET /api/projects/{id}/log.
+type ProjectLog struct {
+	Path    string          `json:"path"` // Datei oder Verzeichnis ("" = ganzes Projekt)
+	Commits []ProjectCommit `json:"commits"`
+}
+
+// IssueImportResult ist die Antwort der Issue-Importe (/import-issues, /import-jira, /import-linear).
+type IssueImportResult struct {
+	Imported []Task `json:"imported"` // Neu angelegte Tasks
+	Skipped  int    `json:"skipped"`  // Issues, die bereits einen Task haben
+}
+
+// Ergebnis einer Zeile beim Task-Import aus CSV oder Markdown.
+const (
+	TaskImportCreated = "created" // Task wurde angelegt
+	TaskImportSkipped = "skipped" // Abgehakt oder ähnlicher Task existiert bereits
+	TaskImportFailed  = "failed"  // Zeile ungültig, siehe Error
+)
+
+// TaskImportRow ist das Ergebnis einer Zeile von POST /api/tasks/import.
+type TaskImportRow struct {
+	Line   int    `json:"line"`              // Zeile in der Datei
+	Title  string `json:"title"`
+	Status string `json:"status"`            // created, skipped oder failed
+	Error  string `json:"error,omitempty"`   // Grund für skipped/failed
+	TaskID string `json:"task_id,omitempty"` // Angelegter Task
+}
+
+// TaskImportReport ist die Antwort von POST /api/tasks/import.
+type TaskImportReport struct {
+	Format  string          `json:"format"` // csv oder markdown
+	Created int             `json:"created"`
+	Skipped int             `json:"skipped"`
+	Failed  int             `json:"failed"`
+	Rows    []TaskImportRow `json:"rows"`
+}
+
+// JiraImportRequest ist der Request-Body für POST /api/projects/{id}/import-jira.
+type JiraImportRequest struct {
+	JQL string `json:"jql"` // Optional: JQL-Filter (Standard: offene Issues des Jira-Projekts)
+}
+
+// LinearImportRequest ist der Request-Body für POST /api/projects/{id}/import-linear.
+// Mindestens eines der Felder ist erforderlich.
+type LinearImportRequest struct {
+	TeamID    string `json:"team_id"`    // Offene Issues des Teams
+	ProjectID string `json:"project_id"` // Offene Issues des Linear-Projekts
+}
+
+// ProjectFileEntry ist ein Eintrag in GET /api/projects/{id}/files.
+type ProjectFileEntry struct {
+	Name string `json:"name"`           // Dateiname
+	Path string `json:"path"`           // Pfad relativ zum Projekt (mit /)
+	Type string `json:"type"`           // file, dir, symlink oder submodule
+	Size int64  `json:"size,omitempty"` // Größe in Bytes (nur Dateien)
+}
+
+// ProjectFileListing ist die Antwort von GET /api/projects/{id}/files.
+type ProjectFileListing struct {
+	Path    string             `json:"path"`          // Aufgelistetes Verzeichnis ("" = Projekt-Root)
+	Ref     string             `json:"ref,omitempty"` // Git-Ref (leer = Arbeitsverzeichnis)
+	Entries []ProjectFileEntry `json:"entries"`
+}
+
+// ProjectFileContent ist die Antwort von GET /api/projects/{id}/file.
+type ProjectFileContent struct {
+	Path      string `json:"path"`
+	Ref       string `json:"ref,omitempty"`     // Git-Ref (leer = Arbeitsverzeichnis)
+	Size      int64  `json:"size"`              // Größe der Datei in Bytes
+	Content   string `json:"content"`           // Inhalt (leer bei Binärdateien)
+	Binary    bool   `json:"binary"`            // true = kein Text, Inhalt wird nicht geliefert
+	Truncated bool   `json:"truncated"`         // true = Inhalt nach dem Limit abgeschnitten
+}
+
+// ============================================================================
+// Export/Import Types
+// ============================================================================
+
+// BoardExportVersion ist die Format-Version des Export-Snapshots.
+// Wird beim Import geprüft, um inkompatible Dateien abzulehnen.
+const BoardExportVersion = 1
+
+// BoardExport ist ein vollständiger Snapshot des Boards für GET /api/export.
+// Der GitHub-Token wird bewusst nicht exportiert.
+type BoardExport struct {
+	Version      int                    `json:"version"`       // Format-Version (BoardExportVersion)
+	ForgeVersion string                 `json:"forge_version"` // FORGE-Version beim Export
+	ExportedAt   time.Time              `json:"exported_at"`   // Zeitpunkt des Exports
+	Config       *Config                `json:"config,omitempty"`
+	Projects     []Project              `json:"projects"`
+	TaskTypes    []TaskType             `json:"task_types"`
+	Labels       []Label                `json:"labels,omitempty"`  // Labels (Zuordnung steckt in Task.Labels)
+	Comments     []TaskComment          `json:"comments,omitempty"` // Kommentare aller Tasks
+	Columns      []BoardColumn          `json:"columns,omitempty"` // Board-Spalten (ab FORGE mit eigenen Status)
+	Boards       []Board                `json:"boards,omitempty"`  // Boards (ab FORGE mit mehreren Boards)
+	BranchRules  []BranchProtectionRule `json:"branch_rules"`
+	Tasks        []Task                 `json:"tasks"` // inkl. Attachment-Metadaten
+}
+
+// ImportResult ist die Response von POST /api/import.
+// Enthält Zähler pro Entität und die Zuordnung alter zu neuen IDs.
+type ImportResult struct {
+	Mode          string            `json:"mode"`    // skip, overwrite oder duplicate
+	Created       map[string]int    `json:"created"` // Neu angelegte Datensätze pro Entität
+	Updated       map[string]int    `json:"updated"` // Überschriebene Datensätze pro Entität
+	Skipped       map[string]int    `json:"skipped"` // Übersprungene Datensätze pro Entität
+	IDMap         map[string]string `json:"id_map"`  // Alte ID -> neue ID
+	Warnings      []string          `json:"warnings,omitempty"`
+	ConfigApplied bool              `json:"config_applied"` // true = Config wurde übernommen
+
+	createdTasks map[string]string // Alte -> neue Task-ID für neu angelegte Tasks (für Attachments)
+}
+
+// ============================================================================
+// Backup Types
+// ============================================================================
+
+// BackupInfo beschreibt ein Datenbank-Backup im Backup-Verzeichnis
+type BackupInfo struct {
+	Name            string    `json:"name"`                       // Dateiname, z.B. forge-20240101-120000.db
+	Size            int64     `json:"size"`                       // Größe der Datenbankdatei in Bytes
+	CreatedAt       time.Time `json:"created_at"`
+	Attachments     string    `json:"attachments,omitempty"`      // Zugehöriges Uploads-Archiv, falls vorhanden
+	AttachmentsSize int64     `json:"attachments_size,omitempty"` // Größe des Uploads-Archivs in Bytes
+}
+
+// BackupRequest ist der Request-Body für POST /api/admin/backup
+type BackupRequest struct {
+	Attachments bool `json:"attachments"` // true = Uploads-Verzeichnis mitsichern
+}
+
+// RestoreRequest ist der Request-Body für POST /api/admin/restore
+type RestoreRequest struct {
+	Name        string `json:"name"`        // Dateiname des Backups
+	Attachments bool   `json:"attachments"` // true = Uploads-Verzeichnis ebenfalls wiederherstellen
+}
+
+// ============================================================================
+// Migration Types
+// ============================================================================
+
+// MigrationInfo beschreibt eine Schema-Migration
+type MigrationInfo struct {
+	Version     int      `json:"version"`
+	Description string   `json:"description"`
+	Applied     bool     `json:"applied"`
+	Statements  []string `json:"statements,omitempty"` // Nur bei Dry-Run: auszuführende SQL-Statements
+}
+
+// MigrationStatus ist die Antwort für GET /api/admin/migrations
+type MigrationStatus struct {
+	Driver         string          `json:"driver"`
+	CurrentVersion int             `json:"current_version"`
+	LatestVersion  int             `json:"latest_version"`
+	Pending        int             `json:"pending"`
+	Migrations     []MigrationInfo `json:"migrations"`
+}
+
+// ============================================================================
+// Log Types
+// ============================================================================
+
+// LogEntry ist ein Log-Eintrag aus dem Speicher von /api/admin/logs
+type LogEntry struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"` // z.B. request_id, task_id, component, err
+}
+
+// LogsResponse ist die Antwort für GET/PUT /api/admin/logs
+type LogsResponse struct {
+	Level   string     `json:"level"`   // Aktuelles Log-Level
+	Entries []LogEntry `json:"entries"` // Älteste zuerst
+}
+
+// UpdateLogLevelRequest ist der Request-Body für PUT /api/admin/logs
+type UpdateLogLevelRequest struct {
+	Level string `json:"level"` // debug, info, warn oder error
+}
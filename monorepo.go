@@ -0,0 +1,285 @@
+// monorepo.go lets several FORGE projects share one git repository, each
+// pointing at a subdirectory such as a package of a pnpm, Go or Nx workspace.
+// Git commands run in the project directory and so act on the shared
+// repository: operations that change it are serialized per repository, and
+// status, diffs, commits and rollbacks of a subdirectory project only cover
+// its own files. Scans add the packages of workspace roots as projects.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// workspaceMarkers are the files that make a directory a workspace root
+var workspaceMarkers = []string{"pnpm-workspace.yaml", "go.work", "nx.json"}
+
+// workspaceScanDepth limits the search for Nx project.json files
+const workspaceScanDepth = 4
+
+// hasGitDir reports whether path is the top level of a repository
+func hasGitDir(path string) bool {
+	_, err := os.Stat(filepath.Join(path, ".git"))
+	return err == nil
+}
+
+// gitRoot returns the top level of the repository containing path, "" if
+// path is not inside a repository
+func gitRoot(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	for {
+		if hasGitDir(dir) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// isRepoSubdir reports whether path is a subdirectory of a repository
+// rather than its top level
+func isRepoSubdir(path string) bool {
+	root := gitRoot(path)
+	if root == "" {
+		return false
+	}
+	abs, _ := filepath.Abs(path)
+	return abs != root
+}
+
+// scopePathspec limits a git command run in path to the files below it if
+// path is a subdirectory project; for a whole repository it adds nothing
+func scopePathspec(path string) []string {
+	if isRepoSubdir(path) {
+		return []string{"--", "."}
+	}
+	return nil
+}
+
+// runScopedGit runs git in path with args followed by the project's pathspec
+func runScopedGit(path string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append(args, scopePathspec(path)...)...)
+	cmd.Dir = path
+	return cmd.CombinedOutput()
+}
+
+// rollbackSubdirToTag restores the files below path to their state at tag
+// and commits that, formatted for the project and with the task's trailer.
+// The branch, its history and the other projects in the repository stay as
+// they are.
+func rollbackSubdirToTag(project *Project, task *Task, path string, tagName string) error {
+	message := WithTaskTrailer(commitMessage(project, task, "Roll back to "+tagName), task.ID)
+	_, err := commitRestoredTree(path, tagName, message)
+	return err
+}
+
+// commitRestoredTree restores the files below path to their state at ref
+// and commits that with message, on top of the current branch. Reports
+// whether there was anything to commit.
+func commitRestoredTree(path, ref, message string) (bool, error) {
+	steps := [][]string{
+		{"restore", "--source", ref, "--staged", "--worktree", "--", "."},
+		{"clean", "-fd", "--", "."},
+	}
+	for _, args := range steps {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = path
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return false, fmt.Errorf("git %s failed: %v, output: %s", args[0], err, string(output))
+		}
+	}
+
+	// Nothing to commit if the files were unchanged since ref
+	diff := exec.Command("git", "diff", "--cached", "--quiet", "--", ".")
+	diff.Dir = path
+	err := diff.Run()
+	var exitErr *exec.ExitError
+	if err == nil {
+		return false, nil
+	}
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return false, fmt.Errorf("git diff failed: %v", err)
+	}
+	commit := exec.Command("git", "commit", "-m", message, "--", ".")
+	commit.Dir = path
+	if output, err := runSigned(commit); err != nil {
+		return false, gitFailed("commit", err, output)
+	}
+	return true, nil
+}
+
+// isWorkspaceRoot reports whether path holds a workspace definition
+func isWorkspaceRoot(path string) bool {
+	for _, marker := range workspaceMarkers {
+		if _, err := os.Stat(filepath.Join(path, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// workspacePackages returns the package directories of the workspace at
+// root, from pnpm-workspace.yaml, go.work and Nx project.json files
+func workspacePackages(root string) []string {
+	seen := map[string]bool{}
+	var packages []string
+	add := func(dir string) {
+		dir = filepath.Clean(dir)
+		if dir == filepath.Clean(root) || seen[dir] {
+			return
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return
+		}
+		seen[dir] = true
+		packages = append(packages, dir)
+	}
+
+	for _, dir := range pnpmPackages(root) {
+		add(dir)
+	}
+	for _, dir := range goWorkModules(root) {
+		add(dir)
+	}
+	if _, err := os.Stat(filepath.Join(root, "nx.json")); err == nil {
+		for _, dir := range nxProjects(root) {
+			add(dir)
+		}
+	}
+
+	sort.Strings(packages)
+	return packages
+}
+
+// pnpmPackages expands the package globs of pnpm-workspace.yaml. Only the
+// packages list is read, so no YAML library is needed.
+func pnpmPackages(root string) []string {
+	file, err := os.Open(filepath.Join(root, "pnpm-workspace.yaml"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var include, exclude []string
+	inPackages := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && !strings.HasPrefix(line, "-") {
+			inPackages = strings.HasPrefix(trimmed, "packages:")
+			continue
+		}
+		if !inPackages || !strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		pattern := strings.Trim(strings.TrimSpace(strings.TrimPrefix(trimmed, "-")), `'"`)
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			exclude = append(exclude, strings.TrimPrefix(negated, "./"))
+		} else if pattern != "" {
+			include = append(include, strings.TrimPrefix(pattern, "./"))
+		}
+	}
+
+	var dirs []string
+	fsys := os.DirFS(root)
+	for _, pattern := range include {
+		matches, err := doublestar.Glob(fsys, strings.TrimSuffix(pattern, "/"))
+		if err != nil {
+			continue
+		}
+	match:
+		for _, match := range matches {
+			if strings.Contains(match, "node_modules") {
+				continue
+			}
+			for _, ex := range exclude {
+				if ok, _ := doublestar.Match(strings.TrimSuffix(ex, "/"), match); ok {
+					continue match
+				}
+			}
+			dirs = append(dirs, filepath.Join(root, match))
+		}
+	}
+	return dirs
+}
+
+// goWorkModules returns the module directories listed in go.work
+func goWorkModules(root string) []string {
+	data, err := os.ReadFile(filepath.Join(root, "go.work"))
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			dirs = append(dirs, filepath.Join(root, strings.Trim(fields[0], `"`)))
+		case fields[0] == "use" && len(fields) > 1 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "use" && len(fields) > 1:
+			dirs = append(dirs, filepath.Join(root, strings.Trim(fields[1], `"`)))
+		}
+	}
+	return dirs
+}
+
+// nxProjects returns the directories with a project.json below an Nx root
+func nxProjects(root string) []string {
+	var dirs []string
+	baseDepth := strings.Count(filepath.Clean(root), string(os.PathSeparator))
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "dist") {
+				return filepath.SkipDir
+			}
+			if strings.Count(path, string(os.PathSeparator))-baseDepth > workspaceScanDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "project.json" && filepath.Dir(path) != root {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	return dirs
+}
+
+// workspacePackageName names a package project after its workspace and its
+// path in it, e.g. "shop/packages/api", since package names alone often repeat
+func workspacePackageName(root, dir string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return filepath.Base(dir)
+	}
+	return GetProjectNameFromPath(root) + "/" + filepath.ToSlash(rel)
+}
@@ -0,0 +1,359 @@
+// pathprotection.go keeps RALPH out of files and directories a project
+// protects, e.g. migrations/** or infra. The rules are listed in the prompt,
+// and after every iteration and before a task moves to review FORGE diffs the
+// working tree against the task's rollback tag. Changes to protected paths are
+// either reverted, with a note to RALPH, or block the task and stop RALPH,
+// depending on the rule. Patterns are doublestar globs relative to the project
+// directory; a pattern naming a directory covers everything below it and "!"
+// makes an exception, as with branch rules.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// pathViolation is a changed file that a protection rule covers
+type pathViolation struct {
+	Path string
+	Rule PathProtectionRule
+}
+
+// ProtectingPathRule returns the rule that protects path, or nil. Exceptions
+// ("!pattern") win over every other rule.
+func ProtectingPathRule(path string, rules []PathProtectionRule) *PathProtectionRule {
+	var protecting *PathProtectionRule
+	for i, rule := range rules {
+		if !matchPathPattern(path, rule.PathPattern) {
+			continue
+		}
+		if strings.HasPrefix(rule.PathPattern, "!") {
+			return nil
+		}
+		if protecting == nil {
+			protecting = &rules[i]
+		}
+	}
+	return protecting
+}
+
+// matchPathPattern matches a slash-separated path against a glob pattern. A
+// pattern also matches everything below the paths it matches, so "infra"
+// protects the whole directory.
+func matchPathPattern(path, pattern string) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "!"), "/")
+	for _, p := range []string{pattern, pattern + "/**"} {
+		if ok, err := doublestar.Match(p, path); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePathRule normalizes and checks a rule before it is saved
+func validatePathRule(rule *PathProtectionRule) error {
+	rule.PathPattern = strings.TrimPrefix(strings.TrimSpace(rule.PathPattern), "./")
+	pattern := strings.TrimPrefix(rule.PathPattern, "!")
+	if pattern == "" {
+		return fmt.Errorf("Path pattern is required")
+	}
+	if strings.HasPrefix(pattern, "/") || pattern == ".." || strings.HasPrefix(pattern, "../") {
+		return fmt.Errorf("Path pattern must be relative to the project directory")
+	}
+	if !doublestar.ValidatePattern(pattern) {
+		return fmt.Errorf("Invalid path pattern")
+	}
+	if rule.Action == "" {
+		rule.Action = PathActionRevert
+	}
+	if rule.Action != PathActionRevert && rule.Action != PathActionBlock {
+		return fmt.Errorf("Action must be %q or %q", PathActionRevert, PathActionBlock)
+	}
+	return nil
+}
+
+// protectedPathPatterns returns the patterns RALPH must not touch, for the prompt
+func protectedPathPatterns(rules []PathProtectionRule) []string {
+	var patterns []string
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.PathPattern, "!") {
+			patterns = append(patterns, rule.PathPattern)
+		}
+	}
+	return patterns
+}
+
+// writeProtectedPaths adds the protected paths section to a prompt
+func writeProtectedPaths(sb *strings.Builder, patterns []string) {
+	if len(patterns) == 0 {
+		return
+	}
+	sb.WriteString("## Protected Paths\n\n")
+	sb.WriteString("IMPORTANT: You must NEVER create, modify or delete files matching these paths (relative to the project directory):\n")
+	for _, pattern := range patterns {
+		sb.WriteString(fmt.Sprintf("- %s\n", pattern))
+	}
+	sb.WriteString("\nFORGE checks every iteration and reverts such changes or blocks the task.\n\n")
+}
+
+// changedPathsSince returns the files in dir that differ from base: changed,
+// added or deleted since, committed or not, and untracked files. Paths are
+// relative to dir.
+func changedPathsSince(dir, base string) ([]string, error) {
+	diff := exec.Command("git", "diff", "--name-only", "--relative", "--no-renames", "-z", base, "--")
+	diff.Dir = dir
+	changed, err := diff.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %v", err)
+	}
+	untracked := exec.Command("git", "ls-files", "--others", "--exclude-standard", "-z")
+	untracked.Dir = dir
+	added, err := untracked.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files failed: %v", err)
+	}
+
+	var paths []string
+	for _, path := range strings.Split(string(changed)+string(added), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// findPathViolations returns the files changed in dir since base that a rule protects
+func findPathViolations(dir, base string, rules []PathProtectionRule) ([]pathViolation, error) {
+	paths, err := changedPathsSince(dir, base)
+	if err != nil {
+		return nil, err
+	}
+	var violations []pathViolation
+	for _, path := range paths {
+		if rule := ProtectingPathRule(path, rules); rule != nil {
+			violations = append(violations, pathViolation{Path: path, Rule: *rule})
+		}
+	}
+	return violations, nil
+}
+
+// revertPaths restores paths in dir to their state at base, in the working
+// tree and the index. Files that did not exist at base are removed.
+func revertPaths(dir, base string, paths []string) error {
+	for _, path := range paths {
+		check := exec.Command("git", "cat-file", "-e", base+":./"+path)
+		check.Dir = dir
+		existed := check.Run() == nil
+
+		cmd := exec.Command("git", "--literal-pathspecs", "rm", "-q", "-f", "--cached", "--ignore-unmatch", "--", path)
+		if existed {
+			cmd = exec.Command("git", "--literal-pathspecs", "checkout", base, "--", path)
+		}
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to revert %s: %v, output: %s", path, err, string(output))
+		}
+		if !existed {
+			file := filepath.Join(dir, filepath.FromSlash(path))
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			// Drop the directories the file leaves empty
+			for parent := filepath.Dir(file); parent != dir && os.Remove(parent) == nil; parent = filepath.Dir(parent) {
+			}
+		}
+	}
+	return nil
+}
+
+// enforcePathRules checks the running task for changes to protected paths
+// and reverts them or blocks the task. Returns false if the task was blocked.
+func (r *RalphRunner) enforcePathRules(taskID string) bool {
+	r.mu.RLock()
+	proc, exists := r.processes[taskID]
+	r.mu.RUnlock()
+	if !exists || proc.dir == "" || !IsGitRepository(proc.dir) {
+		return true
+	}
+	task, _ := r.db.GetTask(taskID)
+	if task == nil || task.ProjectID == "" {
+		return true
+	}
+	rules, err := r.db.GetPathRules(task.ProjectID)
+	if err != nil || len(rules) == 0 {
+		return true
+	}
+
+	base := task.RollbackTag
+	if base == "" {
+		// Without a tag from the start only uncommitted changes are seen
+		base = "HEAD"
+	}
+	unlock := lockRepo(proc.dir, "path rule check")
+	defer unlock()
+	defer gitStatus.Invalidate(proc.dir)
+
+	violations, err := findPathViolations(proc.dir, base, rules)
+	if err != nil {
+		proc.log.Warn("Failed to check protected paths", "err", err)
+		return true
+	}
+	if len(violations) == 0 {
+		return true
+	}
+
+	var paths, blocking []string
+	for _, v := range violations {
+		paths = append(paths, v.Path)
+		if v.Rule.Action == PathActionBlock {
+			blocking = append(blocking, fmt.Sprintf("%s (%s)", v.Path, v.Rule.PathPattern))
+		}
+	}
+
+	if len(blocking) > 0 {
+		reason := "Changed protected paths: " + strings.Join(blocking, ", ")
+		proc.log.Warn("Protected paths changed, blocking task", "paths", len(blocking))
+		r.db.AppendTaskLogs(taskID, "\n[FORGE] "+reason+"\n")
+		r.hub.BroadcastLog(taskID, "\n[FORGE] "+reason+"\n")
+		r.handleBlocked(taskID, reason)
+		go r.Stop(taskID)
+		return false
+	}
+
+	if err := revertPaths(proc.dir, base, paths); err != nil {
+		proc.log.Error("Failed to revert protected paths", "err", err)
+		r.handleBlocked(taskID, "Changed protected paths and reverting them failed: "+err.Error())
+		go r.Stop(taskID)
+		return false
+	}
+	msg := fmt.Sprintf("\n[FORGE] Reverted changes to protected paths: %s\n", strings.Join(paths, ", "))
+	proc.log.Info("Reverted changes to protected paths", "paths", len(paths))
+	r.db.AppendTaskLogs(taskID, msg)
+	r.hub.BroadcastLog(taskID, msg)
+	r.SendFeedback(taskID, fmt.Sprintf("FORGE reverted your changes to these protected paths: %s. "+
+		"Reverts of committed changes are staged; leave them and complete the task without touching these paths.", strings.Join(paths, ", ")))
+	return true
+}
+
+// HandlePathRules handles GET/POST /api/projects/{id}/paths
+func (h *Handler) HandlePathRules(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	project, err := h.db.GetProject(projectID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := h.db.GetPathRules(projectID)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get path rules: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusOK, rules)
+
+	case http.MethodPost:
+		var req PathRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		rule := &PathProtectionRule{ProjectID: projectID}
+		if req.PathPattern != nil {
+			rule.PathPattern = *req.PathPattern
+		}
+		if req.Action != nil {
+			rule.Action = *req.Action
+		}
+		if !h.checkPathRule(w, rule) {
+			return
+		}
+		if err := h.db.CreatePathRule(rule); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to create path rule: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusCreated, rule)
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandlePathRule handles PUT/DELETE /api/projects/{id}/paths/{ruleId}
+func (h *Handler) HandlePathRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.db.GetPathRule(r.PathValue("ruleId"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get path rule: "+err.Error())
+		return
+	}
+	if rule == nil || rule.ProjectID != r.PathValue("id") {
+		h.writeError(w, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req PathRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if req.PathPattern != nil {
+			rule.PathPattern = *req.PathPattern
+		}
+		if req.Action != nil {
+			rule.Action = *req.Action
+		}
+		if !h.checkPathRule(w, rule) {
+			return
+		}
+		if err := h.db.UpdatePathRule(rule); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to update path rule: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusOK, rule)
+
+	case http.MethodDelete:
+		if err := h.db.DeletePathRule(rule.ID); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to delete path rule: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// checkPathRule validates rule and makes sure no other rule of the project has
+// its pattern, or writes the error and returns false
+func (h *Handler) checkPathRule(w http.ResponseWriter, rule *PathProtectionRule) bool {
+	if err := validatePathRule(rule); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	rules, err := h.db.GetPathRules(rule.ProjectID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get path rules: "+err.Error())
+		return false
+	}
+	for _, other := range rules {
+		if other.ID != rule.ID && other.PathPattern == rule.PathPattern {
+			h.writeError(w, http.StatusConflict, "A rule for "+rule.PathPattern+" already exists")
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,207 @@
+// plan.go implements plan mode: RALPH first writes an implementation plan
+// and an outline of the file changes instead of code. A planning run goes
+// through the queue like any other, but Claude runs without its file editing
+// tools and the task's branch is not published. Its final message becomes
+// the task's plan and the task returns to the backlog, where the user edits
+// and approves the plan. The approved plan is part of the prompt of the real
+// run.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// planDisallowedTools are the Claude tools a planning run may not use
+var planDisallowedTools = []string{"Edit", "MultiEdit", "Write", "NotebookEdit"}
+
+// BuildPlanPrompt generates the prompt of a planning run
+func BuildPlanPrompt(task *Task, protectedPaths []string, attachments []Attachment) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("# Plan: %s\n\n", task.Title))
+
+	if task.Description != "" {
+		sb.WriteString("## Description\n\n")
+		sb.WriteString(task.Description)
+		sb.WriteString("\n\n")
+	}
+
+	if task.AcceptanceCriteria != "" {
+		sb.WriteString("## Acceptance Criteria\n\n")
+		sb.WriteString(task.AcceptanceCriteria)
+		sb.WriteString("\n\n")
+	}
+
+	if len(attachments) > 0 {
+		sb.WriteString("## Attachments\n\n")
+		for _, att := range attachments {
+			sb.WriteString(fmt.Sprintf("- %s (Path: %s)\n", att.Filename, att.promptPath()))
+		}
+		sb.WriteString("\n")
+	}
+
+	writeProtectedPaths(&sb, protectedPaths)
+
+	sb.WriteString("## Instructions\n\n")
+	sb.WriteString("This is a planning run. Do NOT create, modify or delete any files and do not commit. ")
+	sb.WriteString("Read the codebase as needed, then write an implementation plan for the task with these sections:\n\n")
+	sb.WriteString("1. **Approach**: how you would solve the task, in a few sentences\n")
+	sb.WriteString("2. **File changes**: every file you would create, modify or delete, each with an outline of the change\n")
+	sb.WriteString("3. **Testing**: how you would verify the acceptance criteria\n")
+	sb.WriteString("4. **Risks and open questions**\n\n")
+	sb.WriteString("Your final message must be the complete plan in Markdown, followed by `[SUCCESS]`. ")
+	sb.WriteString("The plan is reviewed by the user and then handed to the run that implements it. ")
+	sb.WriteString("If the task cannot be planned, output `[BLOCKED]` with an explanation.\n")
+
+	return sb.String()
+}
+
+// writeApprovedPlan adds the task's approved plan to a prompt
+func writeApprovedPlan(sb *strings.Builder, task *Task) {
+	if task.PlanStatus != PlanStatusApproved || strings.TrimSpace(task.Plan) == "" {
+		return
+	}
+	sb.WriteString("## Approved Plan\n\n")
+	sb.WriteString("The user reviewed and approved this plan. Follow it; if it turns out to be wrong somewhere, say where and why you deviate.\n\n")
+	sb.WriteString(strings.TrimSpace(task.Plan))
+	sb.WriteString("\n\n")
+}
+
+// isPlanning reports whether the task's next or current run writes its plan
+func isPlanning(task *Task) bool {
+	return task != nil && task.PlanStatus == PlanStatusPlanning
+}
+
+// finishPlan stores the plan a planning run of the task produced and moves
+// the task back to the backlog for the user to review it. A stopped run
+// leaves no plan. Reports whether it was a planning run.
+func (r *RalphRunner) finishPlan(taskID string, stopped bool) bool {
+	task, _ := r.db.GetTask(taskID)
+	if !isPlanning(task) {
+		return false
+	}
+
+	plan := strings.TrimSpace(strings.ReplaceAll(lastRalphMessage(task.Logs), "[SUCCESS]", ""))
+	var msg string
+	switch {
+	case stopped:
+		r.db.UpdateTaskPlan(taskID, "", "")
+		msg = "\n[FORGE] Planning stopped\n"
+	case plan == "" || task.Status == StatusBlocked:
+		r.db.UpdateTaskPlan(taskID, "", "")
+		r.db.UpdateTaskStatus(taskID, StatusBlocked)
+		if task.Error == "" {
+			r.db.UpdateTaskError(taskID, "RALPH finished without a plan")
+		}
+		msg = "\n[FORGE] No plan was written\n"
+	default:
+		r.db.UpdateTaskPlan(taskID, plan, PlanStatusProposed)
+		r.db.UpdateTaskStatus(taskID, StatusBacklog)
+		msg = "\n[FORGE] Plan ready: review it, edit it if needed and approve it to start the task\n"
+	}
+	r.db.AppendTaskLogs(taskID, msg)
+	r.hub.BroadcastLog(taskID, msg)
+	r.taskLog(taskID).Info("Planning run finished", "plan_length", len(plan), "stopped", stopped)
+	if updated, _ := r.db.GetTask(taskID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+	return true
+}
+
+// HandleTaskPlan handles POST/PUT/DELETE /api/tasks/{id}/plan
+// POST queues a planning run, PUT edits and/or approves the plan (and queues
+// the task with "start"), DELETE discards it.
+func (h *Handler) HandleTaskPlan(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if role := h.columnRole(task.Status); h.runner.IsRunning(task.ID) || role == ColumnRoleProgress || role == ColumnRoleQueue {
+		h.writeError(w, http.StatusConflict, "The task is queued or running")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if err := h.db.UpdateTaskPlan(task.ID, "", PlanStatusPlanning); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to update plan: "+err.Error())
+			return
+		}
+		if err := h.db.AddToQueue(task.ID); err != nil {
+			h.db.UpdateTaskPlan(task.ID, task.Plan, task.PlanStatus)
+			h.writeError(w, http.StatusInternalServerError, "Failed to queue task: "+err.Error())
+			return
+		}
+		logFrom(r.Context()).Info("Queued planning run", "task_id", task.ID)
+		h.writeTaskAndStartQueue(w, r, task.ID, http.StatusAccepted)
+
+	case http.MethodPut:
+		var req PlanRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+				h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+				return
+			}
+		}
+		plan, status := task.Plan, task.PlanStatus
+		if req.Plan != nil {
+			plan = strings.TrimSpace(*req.Plan)
+			if status == "" {
+				status = PlanStatusProposed
+			}
+		}
+		if plan == "" {
+			h.writeError(w, http.StatusBadRequest, "The task has no plan")
+			return
+		}
+		if req.Approve || req.Start {
+			status = PlanStatusApproved
+		}
+		if err := h.db.UpdateTaskPlan(task.ID, plan, status); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to update plan: "+err.Error())
+			return
+		}
+		if req.Start {
+			if err := h.db.AddToQueue(task.ID); err != nil {
+				h.writeError(w, http.StatusInternalServerError, "Failed to queue task: "+err.Error())
+				return
+			}
+		}
+		h.writeTaskAndStartQueue(w, r, task.ID, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := h.db.UpdateTaskPlan(task.ID, "", ""); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to discard plan: "+err.Error())
+			return
+		}
+		h.writeTaskAndStartQueue(w, r, task.ID, http.StatusOK)
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// writeTaskAndStartQueue broadcasts and writes the updated task, then starts
+// the queue in case the task was queued
+func (h *Handler) writeTaskAndStartQueue(w http.ResponseWriter, r *http.Request, taskID string, status int) {
+	task, err := h.db.GetTask(taskID)
+	if err != nil || task == nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task")
+		return
+	}
+	task.Attachments, _ = h.db.GetAttachmentsByTask(task.ID)
+	h.hub.BroadcastTaskUpdate(task)
+	if h.columnRole(task.Status) == ColumnRoleQueue {
+		go h.runner.TryStartNextQueued(r.Context())
+	}
+	h.writeJSON(w, status, task)
+}
@@ -0,0 +1,155 @@
+// profile.go attributes what a signed-in user triggers to that user. The
+// commits of deploys, pushes and new branches started from the board are
+// authored by the user's git identity, and cherry-picks committed by it: the
+// name and email set in the profile, else the GitHub name and the user's
+// noreply address, which GitHub links to the account. Their pushes and pull
+// requests authenticate with the user's own GitHub token if one is set in the
+// profile, else with the OAuth token of the login if Config.GithubUserTokens
+// is on, else with the shared credentials. Without the GitHub login (see
+// auth.go) nothing changes. GET/PUT /api/profile read and change the profile
+// of the signed-in user.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// noreplyDomain is the domain of the email addresses GitHub keeps private
+const noreplyDomain = "users.noreply.github.com"
+
+// Token sources of UserProfile
+const (
+	tokenSourcePersonal    = "personal"
+	tokenSourceGithubLogin = "github_login"
+	tokenSourceShared      = "shared"
+)
+
+// githubToken returns the token the user's pushes and GitHub calls
+// authenticate with and where it comes from; "" means the shared credentials
+func (u *User) githubToken(config *Config) (string, string) {
+	switch {
+	case u == nil:
+		return "", tokenSourceShared
+	case u.PersonalToken != "":
+		return u.PersonalToken, tokenSourcePersonal
+	case config != nil && config.GithubUserTokens && u.GithubToken != "":
+		return u.GithubToken, tokenSourceGithubLogin
+	}
+	return "", tokenSourceShared
+}
+
+// commitAuthor returns who the user's commits are attributed to; the zero
+// gitAuthor for no user
+func (u *User) commitAuthor() gitAuthor {
+	if u == nil {
+		return gitAuthor{}
+	}
+	author := gitAuthor{Name: u.GitName, Email: u.GitEmail}
+	if author.Name == "" {
+		author.Name = u.Name
+	}
+	if author.Name == "" {
+		author.Name = u.Login
+	}
+	if author.Email == "" {
+		author.Email = fmt.Sprintf("%d+%s@%s", u.GithubID, u.Login, noreplyDomain)
+	}
+	return author
+}
+
+// requestAuthor returns who commits made for a request are attributed to
+func requestAuthor(r *http.Request) gitAuthor {
+	return userFrom(r.Context()).commitAuthor()
+}
+
+// validateGitIdentity rejects names and emails git would store mangled
+func validateGitIdentity(req UpdateProfileRequest) error {
+	for field, value := range map[string]*string{"git_name": req.GitName, "git_email": req.GitEmail} {
+		if value == nil {
+			continue
+		}
+		*value = strings.TrimSpace(*value)
+		if strings.ContainsAny(*value, "<>\n\r") {
+			return fmt.Errorf("%s must not contain <, > or line breaks", field)
+		}
+	}
+	if req.GitEmail != nil && *req.GitEmail != "" && !strings.Contains(*req.GitEmail, "@") {
+		return fmt.Errorf("git_email is not an email address")
+	}
+	return nil
+}
+
+// userProfile returns the profile of a user as the API shows it
+func userProfile(user *User, config *Config) UserProfile {
+	author := user.commitAuthor()
+	_, source := user.githubToken(config)
+	return UserProfile{
+		User:        user,
+		AuthorName:  author.Name,
+		AuthorEmail: author.Email,
+		TokenSource: source,
+	}
+}
+
+// HandleProfile handles GET/PUT /api/profile
+// Returns or changes the git identity and GitHub token of the signed-in user.
+func (h *Handler) HandleProfile(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	if user == nil {
+		h.writeError(w, http.StatusNotFound, "Not signed in, profiles need the GitHub login")
+		return
+	}
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.writeJSON(w, http.StatusOK, userProfile(user, config))
+
+	case http.MethodPut:
+		var req UpdateProfileRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if err := validateGitIdentity(req); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// A token of another account would attribute the user's pushes to it
+		if req.PersonalToken != nil && *req.PersonalToken != "" {
+			token := strings.TrimSpace(*req.PersonalToken)
+			owner, err := NewGitHubClient(token).ValidateToken()
+			if err != nil {
+				h.writeError(w, http.StatusBadRequest, "Invalid GitHub token: "+err.Error())
+				return
+			}
+			if int64(owner.ID) != user.GithubID {
+				h.writeError(w, http.StatusBadRequest, fmt.Sprintf("The token belongs to %s, not to %s", owner.Login, user.Login))
+				return
+			}
+			req.PersonalToken = &token
+		}
+
+		updated, err := h.db.UpdateUserProfile(user.ID, req)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to update profile: "+err.Error())
+			return
+		}
+		if updated == nil {
+			h.writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		logFrom(r.Context()).Info("Updated profile", "login", updated.Login, "personal_token", updated.PersonalTokenSet)
+		h.writeJSON(w, http.StatusOK, userProfile(updated, config))
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
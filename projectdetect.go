@@ -0,0 +1,511 @@
+// projectdetect.go works out what a project is built with: the language and
+// framework from its manifests (go.mod, package.json, Cargo.toml, pyproject.toml
+// and the like), the commands that test and build it, and the size of its
+// repository. Scans record this on the projects they create, POST
+// /api/projects/{id}/detect detects it again, and scheduled scans fill it in
+// for projects that have none yet. Projects can replace the detected commands
+// with test_command and build_command. RALPH's prompt names the stack and asks
+// for the test and build commands to pass before a task is reported done.
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxManifestSize limits how much of a manifest is read
+const maxManifestSize = 1 << 20
+
+// ProjectStack is what was detected in a project directory, stored as a JSON object
+type ProjectStack struct {
+	Language     string     `json:"language,omitempty"`      // e.g. Go, TypeScript, Python
+	Framework    string     `json:"framework,omitempty"`     // e.g. Gin, Next.js, Django
+	TestCommand  string     `json:"test_command,omitempty"`  // Runs the tests, e.g. go test ./...
+	BuildCommand string     `json:"build_command,omitempty"` // Builds the project, e.g. npm run build
+	RepoSize     int64      `json:"repo_size,omitempty"`     // Size of the git objects in bytes
+	DetectedAt   *time.Time `json:"detected_at,omitempty"`   // nil = not detected yet
+}
+
+// Value stores the stack as JSON, an undetected one as an empty string
+func (s ProjectStack) Value() (driver.Value, error) {
+	if s.DetectedAt == nil {
+		return "", nil
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads a stack stored by Value
+func (s *ProjectStack) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into ProjectStack", src)
+	}
+	*s = ProjectStack{}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, s)
+}
+
+// EffectiveTestCommand is the project's own test command, else the detected one
+func (p *Project) EffectiveTestCommand() string {
+	if p.TestCommand != "" {
+		return p.TestCommand
+	}
+	return p.Stack.TestCommand
+}
+
+// EffectiveBuildCommand is the project's own build command, else the detected one
+func (p *Project) EffectiveBuildCommand() string {
+	if p.BuildCommand != "" {
+		return p.BuildCommand
+	}
+	return p.Stack.BuildCommand
+}
+
+// detectProjectStack detects the stack of the project at path, with the size of its repository
+func detectProjectStack(path string) ProjectStack {
+	stack := detectStack(path)
+	stack.RepoSize = repoSize(path)
+	now := time.Now()
+	stack.DetectedAt = &now
+	return stack
+}
+
+// detectStack detects language, framework and commands from the manifests in
+// dir. The first manifest found wins, so a Go service with a package.json for
+// its frontend tooling is a Go project.
+func detectStack(dir string) ProjectStack {
+	var stack ProjectStack
+	switch {
+	case exists(dir, "go.mod") || exists(dir, "go.work"):
+		stack = detectGo(dir)
+	case exists(dir, "Cargo.toml"):
+		stack = detectRust(dir)
+	case exists(dir, "pyproject.toml") || exists(dir, "requirements.txt") || exists(dir, "setup.py"):
+		stack = detectPython(dir)
+	case exists(dir, "pom.xml"):
+		stack = detectMaven(dir)
+	case exists(dir, "build.gradle") || exists(dir, "build.gradle.kts"):
+		stack = detectGradle(dir)
+	case exists(dir, "composer.json"):
+		stack = detectPHP(dir)
+	case exists(dir, "Gemfile"):
+		stack = detectRuby(dir)
+	case exists(dir, "pubspec.yaml"):
+		stack = detectDart(dir)
+	case exists(dir, "mix.exs"):
+		stack = detectElixir(dir)
+	case exists(dir, "deno.json") || exists(dir, "deno.jsonc"):
+		stack = detectDeno(dir)
+	case exists(dir, "package.json"):
+		stack = detectNode(dir)
+	case exists(dir, "project.clj"):
+		stack = ProjectStack{Language: "Clojure", TestCommand: "lein test", BuildCommand: "lein uberjar"}
+	case exists(dir, "CMakeLists.txt"):
+		stack = ProjectStack{Language: "C/C++", TestCommand: "ctest --test-dir build", BuildCommand: "cmake -B build && cmake --build build"}
+	}
+
+	// A Makefile fills in what the manifests leave open
+	if stack.TestCommand == "" && hasMakeTarget(dir, "test") {
+		stack.TestCommand = "make test"
+	}
+	if stack.BuildCommand == "" && hasMakeTarget(dir, "build") {
+		stack.BuildCommand = "make build"
+	}
+	return stack
+}
+
+// detectGo detects Go modules and their web framework
+func detectGo(dir string) ProjectStack {
+	stack := ProjectStack{Language: "Go", TestCommand: "go test ./...", BuildCommand: "go build ./..."}
+	stack.Framework = firstContained(readManifest(dir, "go.mod"), [][2]string{
+		{"github.com/gin-gonic/gin", "Gin"},
+		{"github.com/labstack/echo", "Echo"},
+		{"github.com/gofiber/fiber", "Fiber"},
+		{"github.com/go-chi/chi", "chi"},
+		{"github.com/gorilla/mux", "Gorilla"},
+	})
+	return stack
+}
+
+// detectRust detects Cargo crates and their framework
+func detectRust(dir string) ProjectStack {
+	stack := ProjectStack{Language: "Rust", TestCommand: "cargo test", BuildCommand: "cargo build"}
+	stack.Framework = firstContained(readManifest(dir, "Cargo.toml"), [][2]string{
+		{"actix-web", "Actix Web"},
+		{"axum", "Axum"},
+		{"rocket", "Rocket"},
+		{"tauri", "Tauri"},
+	})
+	return stack
+}
+
+// detectPython detects Python projects, their framework and test runner
+func detectPython(dir string) ProjectStack {
+	stack := ProjectStack{Language: "Python"}
+	deps := strings.ToLower(readManifest(dir, "pyproject.toml") + readManifest(dir, "requirements.txt") +
+		readManifest(dir, "requirements-dev.txt") + readManifest(dir, "setup.py") + readManifest(dir, "setup.cfg"))
+	stack.Framework = firstContained(deps, [][2]string{
+		{"django", "Django"},
+		{"fastapi", "FastAPI"},
+		{"flask", "Flask"},
+	})
+
+	switch {
+	case exists(dir, "manage.py"):
+		stack.TestCommand = "python manage.py test"
+	case strings.Contains(deps, "pytest") || exists(dir, "pytest.ini") || exists(dir, "conftest.py"):
+		stack.TestCommand = "pytest"
+	default:
+		stack.TestCommand = "python -m unittest"
+	}
+	if strings.Contains(readManifest(dir, "pyproject.toml"), "[build-system]") {
+		stack.BuildCommand = "python -m build"
+	}
+	return stack
+}
+
+// detectMaven detects Maven builds, using the wrapper if there is one
+func detectMaven(dir string) ProjectStack {
+	mvn := "mvn"
+	if exists(dir, "mvnw") {
+		mvn = "./mvnw"
+	}
+	stack := ProjectStack{Language: "Java", TestCommand: mvn + " test", BuildCommand: mvn + " package"}
+	if exists(dir, "src/main/kotlin") {
+		stack.Language = "Kotlin"
+	}
+	if strings.Contains(readManifest(dir, "pom.xml"), "spring-boot") {
+		stack.Framework = "Spring Boot"
+	}
+	return stack
+}
+
+// detectGradle detects Gradle builds, using the wrapper if there is one
+func detectGradle(dir string) ProjectStack {
+	gradle := "gradle"
+	if exists(dir, "gradlew") {
+		gradle = "./gradlew"
+	}
+	stack := ProjectStack{Language: "Java", TestCommand: gradle + " test", BuildCommand: gradle + " build"}
+	if exists(dir, "src/main/kotlin") {
+		stack.Language = "Kotlin"
+	}
+	stack.Framework = firstContained(readManifest(dir, "build.gradle")+readManifest(dir, "build.gradle.kts"), [][2]string{
+		{"org.springframework.boot", "Spring Boot"},
+		{"com.android", "Android"},
+		{"io.ktor", "Ktor"},
+	})
+	return stack
+}
+
+// detectPHP detects Composer projects and their framework
+func detectPHP(dir string) ProjectStack {
+	stack := ProjectStack{Language: "PHP"}
+	var composer struct {
+		Require    map[string]string      `json:"require"`
+		RequireDev map[string]string      `json:"require-dev"`
+		Scripts    map[string]interface{} `json:"scripts"`
+	}
+	json.Unmarshal([]byte(readManifest(dir, "composer.json")), &composer)
+
+	switch {
+	case composer.Require["laravel/framework"] != "":
+		stack.Framework = "Laravel"
+	case composer.Require["symfony/framework-bundle"] != "":
+		stack.Framework = "Symfony"
+	}
+	switch {
+	case stack.Framework == "Laravel" && exists(dir, "artisan"):
+		stack.TestCommand = "php artisan test"
+	case composer.Scripts["test"] != nil:
+		stack.TestCommand = "composer test"
+	case composer.RequireDev["phpunit/phpunit"] != "" || composer.Require["phpunit/phpunit"] != "":
+		stack.TestCommand = "vendor/bin/phpunit"
+	}
+	return stack
+}
+
+// gemPattern matches a gem line of a Gemfile, e.g. gem "rails", "~> 7.1"
+var gemPattern = regexp.MustCompile(`(?m)^\s*gem\s+["']([\w-]+)["']`)
+
+// detectRuby detects Bundler projects, Rails and the test runner
+func detectRuby(dir string) ProjectStack {
+	stack := ProjectStack{Language: "Ruby"}
+	gems := make(map[string]bool)
+	for _, m := range gemPattern.FindAllStringSubmatch(readManifest(dir, "Gemfile"), -1) {
+		gems[m[1]] = true
+	}
+	switch {
+	case gems["rails"]:
+		stack.Framework = "Rails"
+	case gems["sinatra"]:
+		stack.Framework = "Sinatra"
+	}
+	switch {
+	case gems["rspec"] || gems["rspec-rails"] || exists(dir, "spec"):
+		stack.TestCommand = "bundle exec rspec"
+	case stack.Framework == "Rails":
+		stack.TestCommand = "bin/rails test"
+	default:
+		stack.TestCommand = "bundle exec rake test"
+	}
+	return stack
+}
+
+// detectDart detects Dart packages and Flutter apps
+func detectDart(dir string) ProjectStack {
+	if strings.Contains(readManifest(dir, "pubspec.yaml"), "flutter:") {
+		return ProjectStack{Language: "Dart", Framework: "Flutter", TestCommand: "flutter test"}
+	}
+	return ProjectStack{Language: "Dart", TestCommand: "dart test"}
+}
+
+// detectElixir detects Mix projects and Phoenix
+func detectElixir(dir string) ProjectStack {
+	stack := ProjectStack{Language: "Elixir", TestCommand: "mix test", BuildCommand: "mix compile"}
+	if strings.Contains(readManifest(dir, "mix.exs"), ":phoenix") {
+		stack.Framework = "Phoenix"
+	}
+	return stack
+}
+
+// detectDeno detects Deno projects and Fresh
+func detectDeno(dir string) ProjectStack {
+	stack := ProjectStack{Language: "TypeScript", TestCommand: "deno test"}
+	if strings.Contains(readManifest(dir, "deno.json")+readManifest(dir, "deno.jsonc"), "$fresh") {
+		stack.Framework = "Fresh"
+	}
+	return stack
+}
+
+// detectNode detects JavaScript and TypeScript packages, their framework and
+// package manager, and takes the commands from the package's scripts
+func detectNode(dir string) ProjectStack {
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+		Scripts         map[string]string `json:"scripts"`
+	}
+	json.Unmarshal([]byte(readManifest(dir, "package.json")), &pkg)
+	has := func(name string) bool {
+		return pkg.Dependencies[name] != "" || pkg.DevDependencies[name] != ""
+	}
+
+	stack := ProjectStack{Language: "JavaScript"}
+	if exists(dir, "tsconfig.json") || has("typescript") {
+		stack.Language = "TypeScript"
+	}
+	for _, fw := range [][2]string{
+		{"next", "Next.js"},
+		{"nuxt", "Nuxt"},
+		{"@angular/core", "Angular"},
+		{"@sveltejs/kit", "SvelteKit"},
+		{"svelte", "Svelte"},
+		{"astro", "Astro"},
+		{"@nestjs/core", "NestJS"},
+		{"vue", "Vue"},
+		{"react", "React"},
+		{"express", "Express"},
+	} {
+		if has(fw[0]) {
+			stack.Framework = fw[1]
+			break
+		}
+	}
+
+	pm := "npm"
+	switch {
+	case exists(dir, "pnpm-lock.yaml"):
+		pm = "pnpm"
+	case exists(dir, "yarn.lock"):
+		pm = "yarn"
+	case exists(dir, "bun.lockb") || exists(dir, "bun.lock"):
+		pm = "bun"
+	}
+	// npm init writes a test script that only fails
+	if test := pkg.Scripts["test"]; test != "" && !strings.Contains(test, "no test specified") {
+		stack.TestCommand = pm + " run test"
+	}
+	if pkg.Scripts["build"] != "" {
+		stack.BuildCommand = pm + " run build"
+	}
+	return stack
+}
+
+// makeTargetPattern matches the targets of a Makefile
+var makeTargetPattern = regexp.MustCompile(`(?m)^([\w.-]+)\s*:`)
+
+// hasMakeTarget reports whether the Makefile in dir has the target
+func hasMakeTarget(dir, target string) bool {
+	for _, m := range makeTargetPattern.FindAllStringSubmatch(readManifest(dir, "Makefile"), -1) {
+		if m[1] == target {
+			return true
+		}
+	}
+	return false
+}
+
+// firstContained returns the name of the first pattern contained in text
+func firstContained(text string, patterns [][2]string) string {
+	for _, p := range patterns {
+		if strings.Contains(text, p[0]) {
+			return p[1]
+		}
+	}
+	return ""
+}
+
+// exists reports whether name exists in dir
+func exists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+// readManifest reads up to maxManifestSize of a file in dir, "" if there is none
+func readManifest(dir, name string) string {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	data, _ := io.ReadAll(io.LimitReader(f, maxManifestSize))
+	return string(data)
+}
+
+// repoSize returns the size of the git objects of the repository at path in
+// bytes, 0 outside a repository
+func repoSize(path string) int64 {
+	cmd := exec.Command("git", "count-objects", "-v")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return 0
+	}
+	var kib int64
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok || (key != "size" && key != "size-pack") {
+			continue
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			kib += n
+		}
+	}
+	return kib * 1024
+}
+
+// refreshProjectStack detects the stack of a project and stores it
+func refreshProjectStack(db Store, project *Project) error {
+	stack := detectProjectStack(project.Path)
+	if err := db.SetProjectStack(project.ID, stack); err != nil {
+		return err
+	}
+	project.Stack = stack
+	return nil
+}
+
+// detectNewProjectStack records the stack of a project that was just created
+func detectNewProjectStack(db Store, project *Project) {
+	if err := refreshProjectStack(db, project); err != nil {
+		componentLog("detect").Warn("Failed to store project stack", "project_id", project.ID, "err", err)
+	}
+}
+
+// backfillProjectStacks detects the stack of the projects that have none yet,
+// e.g. those created before detection existed
+func backfillProjectStacks(db Store, hub *Hub) {
+	projects, err := db.GetAllProjects()
+	if err != nil {
+		componentLog("detect").Error("Failed to list projects", "err", err)
+		return
+	}
+	for i := range projects {
+		project := &projects[i]
+		if project.Stack.DetectedAt != nil || project.Path == "" || project.MissingSince != nil {
+			continue
+		}
+		if err := refreshProjectStack(db, project); err != nil {
+			componentLog("detect").Warn("Failed to store project stack", "project_id", project.ID, "err", err)
+			continue
+		}
+		hub.BroadcastProjectUpdate(project)
+	}
+}
+
+// writeProjectStack tells RALPH what the project is built with and how to
+// check its work
+func writeProjectStack(sb *strings.Builder, project *Project) {
+	if project == nil {
+		return
+	}
+	test, build := project.EffectiveTestCommand(), project.EffectiveBuildCommand()
+	if project.Stack.Language == "" && test == "" && build == "" {
+		return
+	}
+
+	sb.WriteString("## Project\n\n")
+	if project.Stack.Language != "" {
+		if project.Stack.Framework != "" {
+			sb.WriteString(fmt.Sprintf("Stack: %s (%s)\n", project.Stack.Language, project.Stack.Framework))
+		} else {
+			sb.WriteString(fmt.Sprintf("Stack: %s\n", project.Stack.Language))
+		}
+	}
+	if build != "" {
+		sb.WriteString(fmt.Sprintf("Build: `%s`\n", build))
+	}
+	if test != "" {
+		sb.WriteString(fmt.Sprintf("Test: `%s`\n", test))
+	}
+	if build != "" || test != "" {
+		sb.WriteString("\nBefore you output `[SUCCESS]`, run these commands and make sure they pass.\n")
+	}
+	sb.WriteString("\n")
+}
+
+// HandleProjectDetect handles POST /api/projects/{id}/detect
+// Detects language, framework, commands and repository size of the project again.
+func (h *Handler) HandleProjectDetect(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if info, err := os.Stat(project.Path); err != nil || !info.IsDir() {
+		h.writeError(w, http.StatusBadRequest, "Project directory does not exist")
+		return
+	}
+
+	if err := refreshProjectStack(h.db, project); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to store project stack: "+err.Error())
+		return
+	}
+	h.hub.BroadcastProjectUpdate(project)
+	h.writeJSON(w, http.StatusOK, project)
+}
@@ -0,0 +1,372 @@
+// projectfiles.go lets the review UI read a project's files: GET
+// /api/projects/{id}/files lists a directory and GET /api/projects/{id}/file
+// returns a file's contents, either from the working tree or, with ?ref=, as
+// committed at a git ref. Paths are relative to the project root and may not
+// leave it, neither via ".." nor via symlinks; .git is never served.
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// projectFileMaxSize is the maximum number of bytes returned for a single file
+const projectFileMaxSize = 1 << 20
+
+// binarySniffLen is how many leading bytes are checked for NUL to detect binary files
+const binarySniffLen = 8000
+
+// HandleProjectFiles handles GET /api/projects/{id}/files?path=&ref=
+func (h *Handler) HandleProjectFiles(w http.ResponseWriter, r *http.Request) {
+	project, rel, ref, ok := h.projectFileRequest(w, r)
+	if !ok {
+		return
+	}
+
+	listing := ProjectFileListing{Path: rel, Ref: r.URL.Query().Get("ref"), Entries: []ProjectFileEntry{}}
+	var status int
+	var err error
+	if ref == "" {
+		listing.Entries, status, err = listWorkingTreeDir(project.Path, rel)
+	} else {
+		listing.Entries, status, err = listGitTreeDir(project.Path, ref, rel)
+	}
+	if err != nil {
+		h.writeError(w, status, err.Error())
+		return
+	}
+
+	sort.Slice(listing.Entries, func(i, j int) bool {
+		a, b := listing.Entries[i], listing.Entries[j]
+		if (a.Type == "dir") != (b.Type == "dir") {
+			return a.Type == "dir"
+		}
+		return a.Name < b.Name
+	})
+	h.writeJSON(w, http.StatusOK, listing)
+}
+
+// HandleProjectFile handles GET /api/projects/{id}/file?path=&ref=
+func (h *Handler) HandleProjectFile(w http.ResponseWriter, r *http.Request) {
+	project, rel, ref, ok := h.projectFileRequest(w, r)
+	if !ok {
+		return
+	}
+	if rel == "" {
+		h.writeError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+
+	var data []byte
+	var size int64
+	var status int
+	var err error
+	if ref == "" {
+		data, size, status, err = readWorkingTreeFile(project.Path, rel)
+	} else {
+		data, size, status, err = readGitFile(project.Path, ref, rel)
+	}
+	if err != nil {
+		h.writeError(w, status, err.Error())
+		return
+	}
+
+	content := ProjectFileContent{Path: rel, Ref: r.URL.Query().Get("ref"), Size: size}
+	if len(data) > projectFileMaxSize {
+		data = trimPartialRune(data[:projectFileMaxSize])
+		content.Truncated = true
+	}
+	if isBinaryContent(data) {
+		content.Binary = true
+	} else {
+		content.Content = string(data)
+	}
+	h.writeJSON(w, http.StatusOK, content)
+}
+
+// projectFileRequest looks up the project and validates the path and ref
+// parameters. ref is returned as the resolved commit hash.
+// On failure the error response is already written.
+func (h *Handler) projectFileRequest(w http.ResponseWriter, r *http.Request) (*Project, string, string, bool) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return nil, "", "", false
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return nil, "", "", false
+	}
+
+	query := r.URL.Query()
+	rel, err := cleanProjectPath(query.Get("path"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return nil, "", "", false
+	}
+
+	ref := query.Get("ref")
+	if ref == "" {
+		return project, rel, "", true
+	}
+	if !IsGitRepository(project.Path) {
+		h.writeError(w, http.StatusBadRequest, "Project is not a git repository")
+		return nil, "", "", false
+	}
+	hash, err := resolveCommitRef(project.Path, ref)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return nil, "", "", false
+	}
+	return project, rel, hash, true
+}
+
+// cleanProjectPath normalizes a client supplied path to a slash separated path
+// relative to the project root ("" is the root). Absolute paths, paths that
+// leave the root and anything inside .git are rejected.
+func cleanProjectPath(raw string) (string, error) {
+	if strings.ContainsRune(raw, 0) || strings.Contains(raw, "\\") {
+		return "", fmt.Errorf("invalid path")
+	}
+	if strings.HasPrefix(raw, "/") || filepath.IsAbs(raw) {
+		return "", fmt.Errorf("path must be relative to the project")
+	}
+	for _, part := range strings.Split(raw, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("path must not leave the project")
+		}
+		if part == ".git" {
+			return "", fmt.Errorf("access to .git is not allowed")
+		}
+	}
+	rel := strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+raw)), "/")
+	return rel, nil
+}
+
+// resolveProjectPath returns the absolute path of rel inside root after
+// following symlinks, and fails if the target lies outside the project
+func resolveProjectPath(root, rel string) (string, int, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("failed to resolve project path: %w", err)
+	}
+	target, err := filepath.EvalSymlinks(filepath.Join(realRoot, filepath.FromSlash(rel)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", http.StatusNotFound, fmt.Errorf("not found: %s", rel)
+		}
+		return "", http.StatusInternalServerError, err
+	}
+	inside, err := filepath.Rel(realRoot, target)
+	if err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return "", http.StatusForbidden, fmt.Errorf("path points outside the project")
+	}
+	if inside == ".git" || strings.HasPrefix(inside, ".git"+string(filepath.Separator)) {
+		return "", http.StatusForbidden, fmt.Errorf("access to .git is not allowed")
+	}
+	return target, 0, nil
+}
+
+// resolveCommitRef resolves a branch, tag or commit to its commit hash
+func resolveCommitRef(dir, ref string) (string, error) {
+	if strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\n:") {
+		return "", fmt.Errorf("invalid ref: %s", ref)
+	}
+	out, err := runGitStatusCommand(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown ref: %s", ref)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// listWorkingTreeDir lists the directory rel of the working tree
+func listWorkingTreeDir(root, rel string) ([]ProjectFileEntry, int, error) {
+	dir, status, err := resolveProjectPath(root, rel)
+	if err != nil {
+		return nil, status, err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, http.StatusBadRequest, fmt.Errorf("not a directory: %s", rel)
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	entries := make([]ProjectFileEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		if de.Name() == ".git" {
+			continue
+		}
+		entry := ProjectFileEntry{Name: de.Name(), Path: joinProjectPath(rel, de.Name())}
+		switch {
+		case de.Type()&os.ModeSymlink != 0:
+			entry.Type = "symlink"
+		case de.IsDir():
+			entry.Type = "dir"
+		default:
+			entry.Type = "file"
+			if info, err := de.Info(); err == nil {
+				entry.Size = info.Size()
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, 0, nil
+}
+
+// listGitTreeDir lists the directory rel as committed at hash
+func listGitTreeDir(root, hash, rel string) ([]ProjectFileEntry, int, error) {
+	if rel != "" {
+		if err := checkGitObjectType(root, hash, rel, "tree"); err != nil {
+			return nil, http.StatusNotFound, err
+		}
+	}
+	out, err := runGitStatusCommand(root, "ls-tree", "-l", "-z", hash, "--", "./"+rel+"/")
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	entries := []ProjectFileEntry{}
+	for _, record := range strings.Split(out, "\x00") {
+		// <mode> <type> <object> <size>\t<path>
+		meta, path, ok := strings.Cut(record, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 {
+			continue
+		}
+		name := path[strings.LastIndex(path, "/")+1:]
+		if name == ".git" {
+			continue
+		}
+		entry := ProjectFileEntry{Name: name, Path: joinProjectPath(rel, name)}
+		switch {
+		case fields[1] == "tree":
+			entry.Type = "dir"
+		case fields[1] == "commit":
+			entry.Type = "submodule"
+		case fields[0] == "120000":
+			entry.Type = "symlink"
+		default:
+			entry.Type = "file"
+			entry.Size, _ = strconv.ParseInt(fields[3], 10, 64)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, 0, nil
+}
+
+// readWorkingTreeFile reads up to projectFileMaxSize+1 bytes of a working tree file
+func readWorkingTreeFile(root, rel string) ([]byte, int64, int, error) {
+	path, status, err := resolveProjectPath(root, rel)
+	if err != nil {
+		return nil, 0, status, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, http.StatusInternalServerError, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, 0, http.StatusBadRequest, fmt.Errorf("not a file: %s", rel)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, http.StatusInternalServerError, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, projectFileMaxSize+1))
+	if err != nil {
+		return nil, 0, http.StatusInternalServerError, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, info.Size(), 0, nil
+}
+
+// readGitFile reads up to projectFileMaxSize+1 bytes of rel as committed at hash
+func readGitFile(root, hash, rel string) ([]byte, int64, int, error) {
+	if err := checkGitObjectType(root, hash, rel, "blob"); err != nil {
+		return nil, 0, http.StatusNotFound, err
+	}
+	object := hash + ":./" + rel
+	out, err := runGitStatusCommand(root, "cat-file", "-s", object)
+	if err != nil {
+		return nil, 0, http.StatusInternalServerError, err
+	}
+	size, _ := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitStatusTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "blob", object)
+	cmd.Dir = root
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, 0, http.StatusInternalServerError, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, 0, http.StatusInternalServerError, err
+	}
+	data, err := io.ReadAll(io.LimitReader(stdout, projectFileMaxSize+1))
+	cancel() // Stops git if the blob is larger than what was read
+	cmd.Wait()
+	if err != nil {
+		return nil, 0, http.StatusInternalServerError, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, size, 0, nil
+}
+
+// checkGitObjectType fails unless rel exists at hash and is of the given type (blob or tree)
+func checkGitObjectType(root, hash, rel, want string) error {
+	out, err := runGitStatusCommand(root, "cat-file", "-t", hash+":./"+rel)
+	if err != nil {
+		return fmt.Errorf("not found: %s", rel)
+	}
+	if got := strings.TrimSpace(out); got != want {
+		if want == "tree" {
+			return fmt.Errorf("not a directory: %s", rel)
+		}
+		return fmt.Errorf("not a file: %s", rel)
+	}
+	return nil
+}
+
+// isBinaryContent reports whether data looks like a binary file: it contains
+// NUL bytes near the start or is not valid UTF-8
+func isBinaryContent(data []byte) bool {
+	if bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+		return true
+	}
+	return !utf8.Valid(data)
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence cut off at the end of data
+func trimPartialRune(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+func joinProjectPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
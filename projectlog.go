@@ -0,0 +1,137 @@
+// projectlog.go serves the commit history of a project or a single file via
+// GET /api/projects/{id}/log. Commits made for a task carry a Forge-Task
+// trailer (see WithTaskTrailer), so the history can tell which task last
+// touched a file.
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default and maximum number of commits returned by the log endpoint
+const (
+	defaultProjectLogLimit = 20
+	maxProjectLogLimit     = 500
+)
+
+// HandleProjectLog handles GET /api/projects/{id}/log?path=&limit=
+func (h *Handler) HandleProjectLog(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if !IsGitRepository(project.Path) {
+		h.writeError(w, http.StatusBadRequest, "Project is not a git repository")
+		return
+	}
+
+	query := r.URL.Query()
+	rel, err := cleanProjectPath(query.Get("path"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := defaultProjectLogLimit
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			h.writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(limit, maxProjectLogLimit)
+	}
+
+	commits, err := readProjectLog(project.Path, rel, limit)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read git log: "+err.Error())
+		return
+	}
+
+	// Link commits to their tasks; deleted tasks keep only the ID
+	titles := make(map[string]string)
+	for i := range commits {
+		id := commits[i].TaskID
+		if id == "" {
+			continue
+		}
+		title, ok := titles[id]
+		if !ok {
+			if task, _ := h.db.GetTask(id); task != nil {
+				title = task.Title
+			}
+			titles[id] = title
+		}
+		commits[i].TaskTitle = title
+	}
+
+	h.writeJSON(w, http.StatusOK, ProjectLog{Path: rel, Commits: commits})
+}
+
+// gitLogFormat prints hash, author, author date, subject and the Forge-Task
+// trailers of each commit; records are separated by \x1e
+const gitLogFormat = "--format=%H%x00%an%x00%aI%x00%s%x00%(trailers:key=" + TaskTrailerKey + ",valueonly,separator=%x2C)%x1e"
+
+// gitLogEntry is a commit parsed from git log output in gitLogFormat
+type gitLogEntry struct {
+	CommitInfo
+	TaskIDs []string
+}
+
+// readProjectLog returns the newest limit commits touching rel ("" = the whole
+// project directory). Renames of a single file are followed.
+func readProjectLog(root, rel string, limit int) ([]ProjectCommit, error) {
+	args := []string{"log", "-n", strconv.Itoa(limit), gitLogFormat}
+	if info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel))); rel != "" && err == nil && info.Mode().IsRegular() {
+		args = append(args, "--follow")
+	}
+	args = append(args, "--", "./"+rel)
+
+	out, err := runGitStatusCommand(root, args...)
+	if err != nil {
+		// A repository without commits has no history yet
+		if _, headErr := GetCurrentCommitHash(root); headErr != nil {
+			return []ProjectCommit{}, nil
+		}
+		return nil, err
+	}
+
+	commits := []ProjectCommit{}
+	for _, entry := range parseGitLog(out) {
+		commit := ProjectCommit{CommitInfo: entry.CommitInfo}
+		// A commit may name several tasks; the first one wins
+		if len(entry.TaskIDs) > 0 {
+			commit.TaskID = entry.TaskIDs[0]
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// parseGitLog parses the output of git log with gitLogFormat
+func parseGitLog(out string) []gitLogEntry {
+	var entries []gitLogEntry
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x00")
+		if len(fields) != 5 {
+			continue
+		}
+		entry := gitLogEntry{CommitInfo: CommitInfo{Hash: fields[0], Author: fields[1], Subject: fields[3]}}
+		entry.Date, _ = time.Parse(time.RFC3339, fields[2])
+		for _, id := range strings.Split(fields[4], ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				entry.TaskIDs = append(entry.TaskIDs, id)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,260 @@
+// protection.go enforces branch protection rules on the server instead of
+// only asking RALPH to respect them in the prompt. Every push FORGE makes for
+// a project is refused while the checked-out branch matches one of the
+// project's rules. Projects with push_hook enabled additionally get a
+// pre-push hook, which also stops pushes RALPH runs itself.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pushHookMarker identifies pre-push hooks written by FORGE. Hooks without it
+// belong to the user and are never touched.
+const pushHookMarker = "# Managed by FORGE: blocks pushes to protected branches."
+
+// ProtectedBranchError is returned when a push targets a protected branch
+type ProtectedBranchError struct {
+	Branch  string
+	Pattern string
+}
+
+func (e *ProtectedBranchError) Error() string {
+	if e.Pattern == e.Branch {
+		return fmt.Sprintf("branch %s is protected", e.Branch)
+	}
+	return fmt.Sprintf("branch %s is protected by rule %s", e.Branch, e.Pattern)
+}
+
+// checkPushAllowed returns a *ProtectedBranchError if the branch checked out
+// in path matches a protection rule of the project
+func checkPushAllowed(db Store, projectID, path string) error {
+	branch, err := GetCurrentBranch(path)
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %v", err)
+	}
+	return checkBranchPushAllowed(db, projectID, path, branch)
+}
+
+// checkBranchPushAllowed returns a *ProtectedBranchError if branch matches a
+// protection rule of the project, for pushes of a branch other than the
+// checked-out one
+func checkBranchPushAllowed(db Store, projectID, path, branch string) error {
+	if projectID == "" {
+		if project, _ := db.GetProjectByPath(path); project != nil {
+			projectID = project.ID
+		}
+	}
+	if projectID == "" {
+		return nil
+	}
+
+	rules, err := db.GetBranchRules(projectID)
+	if err != nil {
+		return fmt.Errorf("failed to get branch rules: %v", err)
+	}
+	if rule := ProtectingRule(branch, rules); rule != nil {
+		return &ProtectedBranchError{Branch: branch, Pattern: rule.BranchPattern}
+	}
+	return nil
+}
+
+// pushUnlessProtected pushes the current branch of path unless it is protected
+func pushUnlessProtected(db Store, projectID, path string) error {
+	return pushUnlessProtectedAs(db, projectID, path, "")
+}
+
+// pushUnlessProtectedAs is pushUnlessProtected authenticated with a GitHub
+// token for the project's GitHub instance, "" for git's own credentials
+func pushUnlessProtectedAs(db Store, projectID, path, token string) error {
+	if err := checkPushAllowed(db, projectID, path); err != nil {
+		return err
+	}
+	var webURL string
+	if token != "" {
+		config, _ := db.GetConfig()
+		project, _ := db.GetProject(projectID)
+		webURL = githubEndpointsFor(config, project).WebURL
+	}
+	return PushToRemoteAs(path, token, webURL)
+}
+
+// pushErrorStatus maps a push error to the HTTP status to report it with
+func pushErrorStatus(err error) int {
+	var protected *ProtectedBranchError
+	if errors.As(err, &protected) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// syncPushHook installs, updates or removes the FORGE pre-push hook of a
+// project so it matches push_hook and the project's branch rules
+func syncPushHook(db Store, projectID string) {
+	project, _ := db.GetProject(projectID)
+	if project == nil || !IsGitRepository(project.Path) {
+		return
+	}
+
+	var patterns []string
+	if project.PushHook {
+		rules, err := db.GetBranchRules(project.ID)
+		if err != nil {
+			componentLog("protection").Error("Failed to get branch rules", "project", project.Name, "err", err)
+			return
+		}
+		for _, rule := range rules {
+			patterns = append(patterns, rule.BranchPattern)
+		}
+	}
+
+	if err := writePushHook(project.Path, patterns); err != nil {
+		componentLog("protection").Error("Failed to update pre-push hook", "project", project.Name, "err", err)
+	}
+}
+
+// writePushHook writes a pre-push hook rejecting the given branch patterns,
+// or removes FORGE's hook if there are none
+func writePushHook(repoPath string, patterns []string) error {
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks/pre-push")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to locate hooks directory: %v", err)
+	}
+	hookPath := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(hookPath) {
+		hookPath = filepath.Join(repoPath, hookPath)
+	}
+
+	existing, err := os.ReadFile(hookPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	managed := err == nil && strings.Contains(string(existing), pushHookMarker)
+	if err == nil && !managed {
+		if len(patterns) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%s exists and is not managed by FORGE", hookPath)
+	}
+
+	if len(patterns) == 0 {
+		if managed {
+			return os.Remove(hookPath)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(hookPath, []byte(pushHookScript(patterns)), 0755)
+}
+
+// pushHookScript renders the pre-push hook. The glob patterns are translated
+// to extended regular expressions for grep -E, so the hook matches exactly like
+// matchBranchPattern.
+func pushHookScript(patterns []string) string {
+	var protected, exceptions []string
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			exceptions = append(exceptions, globToRegexp(pattern[1:]))
+		} else {
+			protected = append(protected, globToRegexp(pattern))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n")
+	sb.WriteString(pushHookMarker + "\n")
+	sb.WriteString("# Edit the branch protection rules in FORGE instead of this file.\n")
+	sb.WriteString("while read local_ref local_sha remote_ref remote_sha; do\n")
+	sb.WriteString("\tbranch=\"${remote_ref#refs/heads/}\"\n")
+	if len(exceptions) > 0 {
+		sb.WriteString("\tif printf '%s\\n' \"$branch\" | grep -Eq " + shellQuote(anchoredRegexp(exceptions)) + "; then\n")
+		sb.WriteString("\t\tcontinue\n")
+		sb.WriteString("\tfi\n")
+	}
+	if len(protected) > 0 {
+		sb.WriteString("\tif printf '%s\\n' \"$branch\" | grep -Eq " + shellQuote(anchoredRegexp(protected)) + "; then\n")
+		sb.WriteString("\t\techo \"FORGE: $branch is a protected branch, push rejected\" >&2\n")
+		sb.WriteString("\t\texit 1\n")
+		sb.WriteString("\tfi\n")
+	}
+	sb.WriteString("done\n")
+	sb.WriteString("exit 0\n")
+	return sb.String()
+}
+
+// anchoredRegexp joins regular expressions into one matching a whole line
+func anchoredRegexp(exprs []string) string {
+	return "^(" + strings.Join(exprs, "|") + ")$"
+}
+
+// shellQuote wraps s in single quotes for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// globToRegexp translates a doublestar glob into a POSIX extended regular
+// expression: ** spans path segments, * and ? stay within one, [...] and
+// {a,b} keep their meaning.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	depth := 0 // open {a,b} alternatives
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && i > 0 && glob[i-1] == '/':
+			// "a/**" also matches "a" itself
+			s := sb.String()
+			sb.Reset()
+			sb.WriteString(strings.TrimSuffix(s, "/") + "(/.*)?")
+			i++
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '{':
+			sb.WriteString("(")
+			depth++
+		case c == '}' && depth > 0:
+			sb.WriteString(")")
+			depth--
+		case c == ',' && depth > 0:
+			sb.WriteString("|")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
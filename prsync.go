@@ -0,0 +1,140 @@
+// prsync.go keeps tasks in review in sync with their GitHub pull requests.
+// Every FORGE_PR_SYNC_INTERVAL the runner looks up the pull request of each
+// task in review: a merged pull request moves the task to done and records its
+// merge commit, one closed without merging moves it to blocked. An open one is
+// merged if its project has auto-merge on and it is ready (see automerge.go),
+// otherwise its new review comments are counted (see reviews.go).
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultPRSyncInterval is how often pull requests of tasks in review are checked
+const defaultPRSyncInterval = time.Minute
+
+// prSyncIntervalFromEnv reads FORGE_PR_SYNC_INTERVAL (e.g. 30s; 0 disables the sync)
+func prSyncIntervalFromEnv() time.Duration {
+	v := os.Getenv("FORGE_PR_SYNC_INTERVAL")
+	if v == "" {
+		return defaultPRSyncInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("prsync").Warn("Ignoring invalid FORGE_PR_SYNC_INTERVAL", "value", v)
+		return defaultPRSyncInterval
+	}
+	return d
+}
+
+// RunPRSync checks the pull requests of tasks in review every interval until stop is closed
+func (r *RalphRunner) RunPRSync(interval time.Duration, stop <-chan struct{}) {
+	if interval == 0 {
+		componentLog("prsync").Info("Pull request sync disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.SyncTaskPRs()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// SyncTaskPRs moves tasks in review on whose pull request was merged or closed
+func (r *RalphRunner) SyncTaskPRs() {
+	tasks, err := r.db.GetAllTasks()
+	if err != nil {
+		componentLog("prsync").Error("Failed to list tasks", "err", err)
+		return
+	}
+
+	config, err := r.db.GetConfig()
+	if err != nil || config.GithubToken == "" {
+		return
+	}
+
+	// Project ID -> owner/repo and a client of its GitHub instance, repo "" if not on GitHub
+	type projectRepo struct {
+		project *Project
+		client  *GitHubClient
+		repo    string
+	}
+	repos := make(map[string]projectRepo)
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Status != StatusReview || task.PRNumber == 0 || task.ProjectID == "" {
+			continue
+		}
+
+		repo, ok := repos[task.ProjectID]
+		if !ok {
+			if project, _ := r.db.GetProject(task.ProjectID); project != nil {
+				repo.project = project
+				endpoints := githubEndpointsFor(config, project)
+				repo.client = endpoints.client(config.GithubToken)
+				if remoteURL, err := GetRemoteURL(project.Path); err == nil {
+					repo.repo, _ = endpoints.parseRepo(remoteURL)
+				}
+			}
+			repos[task.ProjectID] = repo
+		}
+		if repo.repo == "" {
+			continue
+		}
+
+		pr, err := repo.client.GetPullRequest(repo.repo, task.PRNumber)
+		if err != nil {
+			componentLog("prsync").Warn("Failed to get pull request", "task_id", task.ID, "pr", task.PRNumber, "err", err)
+			continue
+		}
+		switch {
+		case pr.Merged:
+			if pr.MergeCommitSHA != "" {
+				r.db.UpdateTaskMergeCommit(task.ID, pr.MergeCommitSHA)
+			}
+			r.finishTaskFromPR(task, StatusDone, "", fmt.Sprintf("Pull request #%d was merged", pr.Number))
+		case pr.State == "closed":
+			reason := fmt.Sprintf("Pull request #%d was closed without merging", pr.Number)
+			r.finishTaskFromPR(task, StatusBlocked, reason, reason)
+		default:
+			if !r.autoMergePR(repo.client, repo.repo, repo.project, task, pr) {
+				r.syncTaskReviews(repo.client, repo.repo, task)
+			}
+		}
+	}
+}
+
+// finishTaskFromPR moves a task to status and tells the board
+func (r *RalphRunner) finishTaskFromPR(task *Task, status TaskStatus, errorMsg, message string) {
+	if err := r.db.UpdateTaskStatus(task.ID, status); err != nil {
+		componentLog("prsync").Error("Failed to update status", "task_id", task.ID, "err", err)
+		return
+	}
+	if errorMsg != "" {
+		r.db.UpdateTaskError(task.ID, errorMsg)
+	}
+	msg := "[FORGE] " + message + "\n"
+	r.db.AppendTaskLogs(task.ID, msg)
+	r.hub.BroadcastLog(task.ID, msg)
+	componentLog("prsync").Info(message, "task_id", task.ID)
+
+	r.hub.BroadcastStatus(task.ID, status, task.CurrentIteration)
+	if updated, _ := r.db.GetTask(task.ID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+	if status == StatusDone {
+		finishTrackerIssues(r.db, r.hub, task.ID)
+	}
+	if task.JiraKey != "" {
+		jiraSync.Notify()
+	}
+}
@@ -0,0 +1,357 @@
+// queue.go implements the queue lanes and the ordering policies used by the
+// dispatcher and the reorder API. Every project has its own lane with its own
+// queue positions and runs one task at a time, since tasks of different
+// repositories cannot collide; projects sharing a repository (see monorepo.go)
+// take turns. Config.MaxConcurrentTasks caps the tasks running at once across
+// all lanes, Board.MaxConcurrentTasks those of a board (see boards.go). The
+// order within a lane (queue_position) is only changed by the reorder
+// endpoints; the queue policy of each board merely decides which queued task
+// of its free lanes TryStartNextQueued starts next. Tasks whose dependencies
+// are not done yet are passed over.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// boardsByID indexes boards by their ID
+func boardsByID(boards []Board) map[string]Board {
+	byID := make(map[string]Board, len(boards))
+	for _, b := range boards {
+		byID[b.ID] = b
+	}
+	return byID
+}
+
+// errTaskNotQueued is returned when reordering a task that is not in the queue
+var errTaskNotQueued = errors.New("task is not in the queue")
+
+// validQueuePolicy reports whether policy is one of the QueuePolicy constants
+func validQueuePolicy(policy string) bool {
+	switch policy {
+	case QueuePolicyFIFO, QueuePolicyPriority, QueuePolicyRoundRobin:
+		return true
+	}
+	return false
+}
+
+// renumberQueueStep renumbers the queue positions 1..n per project or across
+// all projects, keeping the order; of equal positions the task queued first
+// goes first. Used by the migration that introduced the lanes.
+func renumberQueueStep(perProject bool) migrationStep {
+	desc := "UPDATE tasks SET queue_position = <position in the queue>"
+	if perProject {
+		desc = "UPDATE tasks SET queue_position = <position in the queue of the project>"
+	}
+	return migrationStep{
+		desc: desc,
+		run: func(tx *sqlTx) error {
+			rows, err := tx.Query(`SELECT id, COALESCE(project_id, '') FROM tasks
+				WHERE queue_position > 0 ORDER BY queue_position ASC, queued_at ASC, created_at ASC`)
+			if err != nil {
+				return err
+			}
+			type entry struct{ id, lane string }
+			var entries []entry
+			for rows.Next() {
+				var e entry
+				if err := rows.Scan(&e.id, &e.lane); err != nil {
+					rows.Close()
+					return err
+				}
+				if !perProject {
+					e.lane = ""
+				}
+				entries = append(entries, e)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			positions := make(map[string]int)
+			for _, e := range entries {
+				positions[e.lane]++
+				if _, err := tx.Exec(`UPDATE tasks SET queue_position = ? WHERE id = ?`, positions[e.lane], e.id); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// repoKey identifies the repository a directory belongs to
+func repoKey(dir string) string {
+	if root := gitRoot(dir); root != "" {
+		return root
+	}
+	return filepath.Clean(dir)
+}
+
+// busyLanes returns the projects and repositories of the running processes
+func (r *RalphRunner) busyLanes() (projects, repos map[string]bool) {
+	r.mu.RLock()
+	procs := make([]*RalphProcess, 0, len(r.processes))
+	for _, proc := range r.processes {
+		procs = append(procs, proc)
+	}
+	r.mu.RUnlock()
+
+	projects = make(map[string]bool)
+	repos = make(map[string]bool)
+	for _, proc := range procs {
+		projects[proc.projectID] = true
+		if proc.dir != "" {
+			repos[repoKey(proc.dir)] = true
+		}
+	}
+	return projects, repos
+}
+
+// taskProjectDir returns the directory a task runs in: its own or its project's
+func (r *RalphRunner) taskProjectDir(task *Task) string {
+	if task.ProjectDir != "" || task.ProjectID == "" {
+		return task.ProjectDir
+	}
+	if project, _ := r.db.GetProject(task.ProjectID); project != nil {
+		return project.Path
+	}
+	return ""
+}
+
+// laneFree reports whether a task's lane is idle: no task of its project and
+// none in its repository is running
+func laneFree(task *Task, dir string, projects, repos map[string]bool) bool {
+	if projects[task.ProjectID] {
+		return false
+	}
+	return dir == "" || !repos[repoKey(dir)]
+}
+
+// canStartNow reports whether a task moved to In Progress may start right
+// away: its lane is idle and fewer than MaxConcurrentTasks tasks are running,
+// in total and on its board. Otherwise it is queued.
+func (r *RalphRunner) canStartNow(task *Task, config *Config) bool {
+	if r.RunningCount() >= max(config.MaxConcurrentTasks, 1) {
+		return false
+	}
+	if r.fullBoards()[task.BoardID] {
+		return false
+	}
+	projects, repos := r.busyLanes()
+	return laneFree(task, r.taskProjectDir(task), projects, repos)
+}
+
+// nextQueuedTask picks the next task to start from the idle lanes according
+// to the queue policies of the boards
+func (r *RalphRunner) nextQueuedTask(config *Config) (*Task, error) {
+	now := time.Now()
+	queued, err := r.db.GetDispatchQueue(now)
+	if err != nil {
+		return nil, err
+	}
+	boards, err := r.db.GetBoards()
+	if err != nil {
+		return nil, err
+	}
+	projects, repos := r.busyLanes()
+	dirs := make(map[string]string) // Project directories by project
+	ready := queued[:0]
+	exhausted := r.exhaustedBudgets(now)
+	full := r.fullBoards()
+	for _, task := range queued {
+		// Tasks retried after a transient failure wait for their backoff (see retry.go)
+		if retryPending(&task, now) {
+			r.wakeQueueAt(*task.RetryAt)
+			continue
+		}
+		// Used-up cost budgets hold their tasks until the period ends (see budget.go)
+		if exhausted.blocks(task.ProjectID) {
+			continue
+		}
+		// Boards running as many tasks as they may hold theirs (see boards.go)
+		if full[task.BoardID] {
+			continue
+		}
+		dir := task.ProjectDir
+		if dir == "" && task.ProjectID != "" {
+			if _, ok := dirs[task.ProjectID]; !ok {
+				dirs[task.ProjectID] = r.taskProjectDir(&task)
+			}
+			dir = dirs[task.ProjectID]
+		}
+		if !laneFree(&task, dir, projects, repos) {
+			continue
+		}
+		// Tasks wait in the queue until the tasks they depend on are done
+		if open, err := r.db.HasOpenDependencies(task.ID); err != nil || !open {
+			ready = append(ready, task)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// GetDispatchQueue ordered the tasks of priority boards by effective priority already
+	dispatch := boardsByID(boards)
+	for id, b := range dispatch {
+		if b.QueuePolicy == QueuePolicyPriority {
+			b.QueuePolicy = QueuePolicyFIFO
+			dispatch[id] = b
+		}
+	}
+	task := selectNextQueued(ready, dispatch, r.lastQueueProject, now)
+	if task != nil {
+		r.lastQueueProject[task.BoardID] = task.ProjectID
+	}
+	return task, nil
+}
+
+// selectNextQueued returns the task to start next, or nil for an empty queue.
+// Every board picks a candidate from its tasks by its own queue policy (see
+// selectNextOnBoard); of the candidates the one queued first goes next, so a
+// busy board cannot hold back the others. lastProjects holds the project of
+// the task last started from each board's queue.
+func selectNextQueued(queued []Task, boards map[string]Board, lastProjects map[string]string, now time.Time) *Task {
+	byBoard := make(map[string][]Task)
+	var order []string
+	for _, task := range queued {
+		if _, ok := byBoard[task.BoardID]; !ok {
+			order = append(order, task.BoardID)
+		}
+		byBoard[task.BoardID] = append(byBoard[task.BoardID], task)
+	}
+
+	var next *Task
+	for _, id := range order {
+		candidate := selectNextOnBoard(byBoard[id], boards[id].QueuePolicy, boards, lastProjects[id], now)
+		if next == nil || queuedBefore(candidate, next) {
+			next = candidate
+		}
+	}
+	return next
+}
+
+// queuedBefore reports whether task a was queued before task b
+func queuedBefore(a, b *Task) bool {
+	return a.QueuedAt != nil && (b.QueuedAt == nil || a.QueuedAt.Before(*b.QueuedAt))
+}
+
+// selectNextOnBoard returns the task of one board to start next, or nil for an
+// empty queue. queued must be ordered as by GetQueuedTasks: by position within
+// each lane, the lanes interleaved by queue time, so the first task is the
+// head of the lane that has waited longest. boards provides the priority aging
+// of the tasks' boards and is only used by the priority policy, lastProject is
+// the project of the previously started task and only used for round robin.
+func selectNextOnBoard(queued []Task, policy string, boards map[string]Board, lastProject string, now time.Time) *Task {
+	if len(queued) == 0 {
+		return nil
+	}
+
+	switch policy {
+	case QueuePolicyPriority:
+		// Priority 1 = high; the queue position breaks ties
+		next := &queued[0]
+		for i := range queued {
+			if effectivePriority(&queued[i], boards, now) < effectivePriority(next, boards, now) {
+				next = &queued[i]
+			}
+		}
+		return next
+
+	case QueuePolicyRoundRobin:
+		// Projects take turns in a fixed order; each project's oldest task goes first
+		first := make(map[string]*Task)
+		var projects []string
+		for i := range queued {
+			p := queued[i].ProjectID
+			if _, ok := first[p]; !ok {
+				first[p] = &queued[i]
+				projects = append(projects, p)
+			}
+		}
+		sort.Strings(projects)
+		for _, p := range projects {
+			if p > lastProject {
+				return first[p]
+			}
+		}
+		return first[projects[0]]
+
+	default:
+		return &queued[0]
+	}
+}
+
+// effectivePriority is the priority of a queued task raised by one level for
+// every aging interval of its board it has waited, so a stream of
+// high-priority tasks cannot hold back lower ones forever. Boards with
+// priority_aging_hours 0 do not age. The dispatcher orders by the same value
+// in GetDispatchQueue.
+func effectivePriority(task *Task, boards map[string]Board, now time.Time) int {
+	aging := time.Duration(boards[task.BoardID].PriorityAgingHours) * time.Hour
+	if aging <= 0 || task.QueuedAt == nil {
+		return task.Priority
+	}
+	boost := int(now.Sub(*task.QueuedAt) / aging)
+	return max(task.Priority-boost, 1)
+}
+
+// HandleTaskQueuePosition handles POST /api/tasks/{id}/queue-position
+func (h *Handler) HandleTaskQueuePosition(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var req QueuePositionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	if req.Position < 1 {
+		h.writeError(w, http.StatusBadRequest, "Position must be 1 or greater")
+		return
+	}
+
+	queue, err := h.db.MoveInQueue(id, req.Position)
+	h.writeQueueOrder(w, queue, err)
+}
+
+// HandleQueueReorder handles POST /api/queue/reorder
+// The listed tasks must belong to one project, every project has its own queue.
+func (h *Handler) HandleQueueReorder(w http.ResponseWriter, r *http.Request) {
+	var req ReorderQueueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	if len(req.TaskIDs) == 0 {
+		h.writeError(w, http.StatusBadRequest, "task_ids is required")
+		return
+	}
+
+	queue, err := h.db.ReorderQueue(req.TaskIDs)
+	h.writeQueueOrder(w, queue, err)
+}
+
+// writeQueueOrder answers a reorder request and broadcasts the new order
+func (h *Handler) writeQueueOrder(w http.ResponseWriter, queue []QueueEntry, err error) {
+	if errors.Is(err, errTaskNotQueued) {
+		h.writeError(w, http.StatusConflict, "Task is not in the queue of the project (or listed twice)")
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to reorder queue: "+err.Error())
+		return
+	}
+	if queue == nil {
+		queue = []QueueEntry{}
+	}
+
+	h.hub.BroadcastQueueOrder(queue)
+	h.writeJSON(w, http.StatusOK, queue)
+}
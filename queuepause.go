@@ -0,0 +1,96 @@
+// queuepause.go lets the queue be paused, e.g. before maintenance, during a
+// Claude outage or to review the backlog. While paused, running tasks finish
+// but no queued task is started; tasks moved to In Progress or continued with
+// feedback go into the queue instead. The state lives in the config row, so
+// the queue stays paused across restarts, and every change is broadcast as a
+// queue_state message.
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// queuePausedNote tells the user why a start was turned into a queue entry
+const queuePausedNote = "The queue is paused, the task will start when it is resumed"
+
+// queuePaused reports whether the queue is paused. If the state cannot be
+// read the queue runs, as it did before pausing existed.
+func (r *RalphRunner) queuePaused() bool {
+	state, err := r.db.GetQueueState()
+	if err != nil {
+		componentLog("queue").Warn("Failed to read queue state", "err", err)
+		return false
+	}
+	return state.Paused
+}
+
+// queueState returns the pause state with the number of queued and running tasks
+func (h *Handler) queueState() (*QueueState, error) {
+	return h.runner.queueState()
+}
+
+// HandleQueueState handles GET /api/admin/queue
+func (h *Handler) HandleQueueState(w http.ResponseWriter, r *http.Request) {
+	state, err := h.queueState()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get queue state: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, state)
+}
+
+// HandlePauseQueue handles POST /api/admin/queue/pause
+// Body (optional): {"reason": "..."} shown on the board while paused.
+func (h *Handler) HandlePauseQueue(w http.ResponseWriter, r *http.Request) {
+	var req PauseQueueRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	h.setQueuePaused(w, r, true, strings.TrimSpace(req.Reason))
+}
+
+// HandleResumeQueue handles POST /api/admin/queue/resume
+// Starts the next queued task right away if nothing is running. Also ends a
+// cooldown after a rate limit (see cooldown.go).
+func (h *Handler) HandleResumeQueue(w http.ResponseWriter, r *http.Request) {
+	state, err := h.db.GetQueueState()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get queue state: "+err.Error())
+		return
+	}
+	if state.CooldownUntil != nil {
+		if _, err := h.db.SetQueueCooldown(nil, state.CooldownLevel, ""); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to end cooldown: "+err.Error())
+			return
+		}
+		logFrom(r.Context()).Info("Cooldown ended early", "component", "queue")
+	}
+	h.setQueuePaused(w, r, false, "")
+}
+
+// setQueuePaused stores and broadcasts the new state and writes it as the response
+func (h *Handler) setQueuePaused(w http.ResponseWriter, r *http.Request, paused bool, reason string) {
+	if _, err := h.db.SetQueuePaused(paused, reason); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to update queue state: "+err.Error())
+		return
+	}
+	state, err := h.queueState()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get queue state: "+err.Error())
+		return
+	}
+	if paused {
+		logFrom(r.Context()).Info("Queue paused", "component", "queue", "reason", reason, "running", state.Running)
+	} else {
+		logFrom(r.Context()).Info("Queue resumed", "component", "queue", "queued", state.Queued)
+		go h.runner.TryStartNextQueued(r.Context())
+	}
+	h.hub.BroadcastQueueState(state)
+	h.writeJSON(w, http.StatusOK, state)
+}
@@ -3,12 +3,14 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -17,33 +19,63 @@ import (
 
 // RalphProcess represents a running RALPH/Claude process
 type RalphProcess struct {
-	TaskID string
+	TaskID    string
+	log       *slog.Logger // With the task ID and the ID of the request that started the run
+	dir       string       // Project directory the process works in
+	projectID string       // Queue lane of the task (see queue.go)
+	boardID   string       // Board whose task limit the process counts against (see boards.go)
 	cmd    *exec.Cmd
 	stdin  io.WriteCloser
 	cancel context.CancelFunc
 	paused bool
 	mu     sync.Mutex
+
+	done       chan struct{} // Closed when the process has exited and was cleaned up
+	checkpoint chan struct{} // Signaled at the end of an iteration while draining
+	drained    bool          // Stopped by a shutdown rather than by the user
+	run        *TaskRun      // Record of this run (see taskruns.go)
+
+	lastOutput time.Time // When the process last wrote a line
+	idleWarned bool      // The current silence was already reported
+
+	inputMu      sync.Mutex // Serializes writes of user messages to stdin
+	pendingTurns int        // User messages Claude has not answered with a result yet
+	inputClosed  bool       // Stdin was closed, the session takes no more messages
+	textInput    bool       // The CLI lacks stream-json input: the prompt is plain text, no further messages
 }
 
 // RalphRunner manages all running RALPH processes
 type RalphRunner struct {
 	processes map[string]*RalphProcess
-	db        *Database
+	db        Store
 	hub       *Hub
 	mu        sync.RWMutex
+
+	lastQueueProject map[string]string // Project of the last task started from the queue, by board (round robin)
+	draining         bool              // Shutting down: no new starts (see drain.go)
+
+	dispatchMu sync.Mutex // Lets only one TryStartNextQueued pick and start a task at a time
+
+	retryWake   *time.Timer // Looks at the queue again when the next retry is due (see retry.go)
+	retryWakeAt time.Time
+
+	budgetAlerts map[string]string // Budget states already announced, by budget and period (see budget.go)
 }
 
 // NewRalphRunner creates a new RalphRunner
-func NewRalphRunner(db *Database, hub *Hub) *RalphRunner {
+func NewRalphRunner(db Store, hub *Hub) *RalphRunner {
 	return &RalphRunner{
-		processes: make(map[string]*RalphProcess),
-		db:        db,
-		hub:       hub,
+		processes:        make(map[string]*RalphProcess),
+		lastQueueProject: make(map[string]string),
+		db:               db,
+		hub:              hub,
 	}
 }
 
-// BuildPrompt generates the RALPH prompt from a task
-func BuildPrompt(task *Task, protectedBranches []string, attachments []Attachment) string {
+// BuildPrompt generates the RALPH prompt from a task. project is the task's
+// project (nil without one). commitExample shows how the project formats
+// commit messages, "" if it has no commit style.
+func BuildPrompt(task *Task, project *Project, protectedBranches, protectedPaths []string, attachments []Attachment, commitExample string) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("# Task: %s\n\n", task.Title))
@@ -60,21 +92,31 @@ func BuildPrompt(task *Task, protectedBranches []string, attachments []Attachmen
 		sb.WriteString("\n\n")
 	}
 
+	writeApprovedPlan(&sb, task)
+
 	// Add attachments info if any
 	if len(attachments) > 0 {
 		sb.WriteString("## Attachments\n\n")
-		sb.WriteString("This task has visual references attached. See attached files for context:\n\n")
+		sb.WriteString("This task has files attached. See attached files for context:\n\n")
 		for _, att := range attachments {
 			// Determine file type description
 			fileType := "File"
-			if strings.HasPrefix(att.MimeType, "image/") {
+			switch attachmentKind(att.MimeType) {
+			case AttachmentKindImage:
 				fileType = "Screenshot"
-			} else if strings.HasPrefix(att.MimeType, "video/") {
+			case AttachmentKindVideo:
 				fileType = "Video"
+			case AttachmentKindText:
+				fileType = "Text file"
+			case AttachmentKindDocument:
+				fileType = "PDF"
 			}
-			sb.WriteString(fmt.Sprintf("- %s: %s (Path: %s)\n", fileType, att.Filename, att.Path))
+			sb.WriteString(fmt.Sprintf("- %s: %s (Path: %s)\n", fileType, att.Filename, att.promptPath()))
 		}
 		sb.WriteString("\nYou can read these files using the Read tool to view images for visual context.\n\n")
+
+		// Text files (and PDFs, if pdftotext is installed) are inlined
+		writeAttachmentContents(&sb, attachments)
 	}
 
 	// Add branch protection rules if any
@@ -89,6 +131,15 @@ func BuildPrompt(task *Task, protectedBranches []string, attachments []Attachmen
 		}
 		sb.WriteString("\nIf you need to make changes to a protected branch, create a feature branch first.\n\n")
 	}
+	writeProtectedPaths(&sb, protectedPaths)
+
+	// Projects in a monorepo share the repository with other projects
+	if task.ProjectDir != "" && isRepoSubdir(task.ProjectDir) {
+		sb.WriteString("## Repository\n\n")
+		sb.WriteString(fmt.Sprintf("This project is a subdirectory of a larger repository (%s). ", gitRoot(task.ProjectDir)))
+		sb.WriteString("Keep your changes inside the project directory unless the task requires otherwise.\n\n")
+	}
+	writeProjectStack(&sb, project)
 
 	sb.WriteString("## Instructions\n\n")
 	sb.WriteString("1. Analyze this task and the existing codebase\n")
@@ -96,7 +147,12 @@ func BuildPrompt(task *Task, protectedBranches []string, attachments []Attachmen
 	sb.WriteString("3. Test after each significant change\n")
 	sb.WriteString("4. If tests fail: analyze the error and fix it\n")
 	sb.WriteString("5. Iterate until ALL acceptance criteria are met\n")
-	sb.WriteString("6. Output structured status after each iteration\n\n")
+	sb.WriteString("6. Output structured status after each iteration\n")
+	if commitExample != "" {
+		sb.WriteString(fmt.Sprintf("7. If you commit, format the commit message like `%s` and end it with the trailer line `%s: %s`\n\n", commitExample, TaskTrailerKey, task.ID))
+	} else {
+		sb.WriteString(fmt.Sprintf("7. If you commit, end every commit message with the trailer line `%s: %s`\n\n", TaskTrailerKey, task.ID))
+	}
 
 	sb.WriteString("## Output Markers\n\n")
 	sb.WriteString("Use these markers in your output:\n")
@@ -110,26 +166,63 @@ func BuildPrompt(task *Task, protectedBranches []string, attachments []Attachmen
 	return sb.String()
 }
 
-// Start starts a RALPH process for a task
-func (r *RalphRunner) Start(task *Task, config *Config) {
+// Start starts a RALPH process for a task. ctx only passes on the request's
+// logger; the process is not bound to it.
+func (r *RalphRunner) Start(ctx context.Context, task *Task, config *Config) {
+	logger := logFrom(ctx).With("component", "runner", "task_id", task.ID)
+
+	// The branch may have been switched for this run
+	r.invalidateGitStatus(task)
+
+	if r.queuePaused() {
+		r.deferStart(ctx, task, "", queuePausedNote)
+		return
+	}
+	if r.coolingDown() {
+		r.deferStart(ctx, task, "", cooldownNote)
+		return
+	}
+
 	r.mu.Lock()
 
+	if r.draining {
+		r.mu.Unlock()
+		r.deferStart(ctx, task, "", drainingNote)
+		return
+	}
+
 	// Check if already running
 	if _, exists := r.processes[task.ID]; exists {
 		r.mu.Unlock()
-		log.Printf("Task %s already has a running process", task.ID)
+		logger.Warn("Task already has a running process")
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	runCtx, cancel := context.WithCancel(context.Background())
 
 	proc := &RalphProcess{
-		TaskID: task.ID,
-		cancel: cancel,
+		TaskID:     task.ID,
+		log:        logger,
+		dir:        task.ProjectDir,
+		projectID:  task.ProjectID,
+		boardID:    task.BoardID,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		checkpoint: make(chan struct{}, 1),
 	}
 	r.processes[task.ID] = proc
 	r.mu.Unlock()
 
+	// Until Claude runs, returning early ends the process here: whoever waits
+	// on done (drain, checkpoints) must not wait for a run that never comes
+	started := false
+	defer func() {
+		if !started {
+			cancel()
+			close(proc.done)
+		}
+	}()
+
 	// Validate project directory
 	if task.ProjectDir == "" {
 		r.handleError(task.ID, "Project directory not specified")
@@ -147,7 +240,7 @@ func (r *RalphRunner) Start(task *Task, config *Config) {
 			task.WorkingBranch = branch
 			r.db.UpdateTaskWorkingBranch(task.ID, branch)
 			r.hub.BroadcastBranchChange(task.ID, branch)
-			log.Printf("Task %s working on branch: %s", task.ID, branch)
+			logger.Info("Working on branch", "branch", branch)
 		}
 	}
 
@@ -156,36 +249,66 @@ func (r *RalphRunner) Start(task *Task, config *Config) {
 	if task.ProjectID != "" {
 		if rules, err := r.db.GetBranchRules(task.ProjectID); err == nil {
 			for _, rule := range rules {
-				protectedBranches = append(protectedBranches, rule.BranchPattern)
+				// Exceptions ("!pattern") only narrow the rules enforced on push
+				if !strings.HasPrefix(rule.BranchPattern, "!") {
+					protectedBranches = append(protectedBranches, rule.BranchPattern)
+				}
 			}
 		}
 	}
+	var protectedPaths []string
+	var commitExample string
+	var project *Project
+	if task.ProjectID != "" {
+		if rules, err := r.db.GetPathRules(task.ProjectID); err == nil {
+			protectedPaths = protectedPathPatterns(rules)
+		}
+		if project, _ = r.db.GetProject(task.ProjectID); project != nil {
+			commitExample = commitStyleExample(project, task)
+		}
+	}
 
 	// Get attachments for the task
 	attachments, err := r.db.GetAttachmentsByTask(task.ID)
 	if err != nil {
-		log.Printf("Warning: Failed to get attachments for task %s: %v", task.ID, err)
+		logger.Warn("Failed to get attachments", "err", err)
 		attachments = nil
 	}
+	attachments = r.forwardAttachments(task, attachments)
 
-	// Build the command
-	claudeCmd := config.ClaudeCommand
-	if claudeCmd == "" {
-		claudeCmd = "claude"
+	// Build the command from what the installed CLI supports
+	claudeCmd := claudeCommand(config)
+	cli := claudeCLI.Get(claudeCmd)
+	args, err := cli.Args(isPlanning(task), r.claudeSettings(task, config))
+	if err != nil {
+		r.handleError(task.ID, err.Error())
+		return
 	}
+	mcpArgs, err := r.mcpConfigArgs(task, cli)
+	if err != nil {
+		r.handleError(task.ID, err.Error())
+		return
+	}
+	args = append(args, mcpArgs...)
+	proc.textInput = !cli.StreamJSONInput
 
-	log.Printf("Starting RALPH for task %s in directory %s", task.ID, task.ProjectDir)
+	logger.Info("Starting RALPH", "dir", task.ProjectDir)
 	r.hub.BroadcastLog(task.ID, "[FORGE] Preparing to start Claude...\n")
 
 	// Build prompt with branch protection info and attachments
-	prompt := BuildPrompt(task, protectedBranches, attachments)
-	log.Printf("Prompt length: %d characters", len(prompt))
+	prompt := BuildPrompt(task, project, protectedBranches, protectedPaths, attachments, commitExample)
+	if isPlanning(task) {
+		// Plan mode: RALPH only writes the plan
+		prompt = BuildPlanPrompt(task, protectedPaths, attachments)
+	}
+	logger.Debug("Prompt built", "length", len(prompt), "planning", isPlanning(task))
 
-	// Run in interactive mode (no -p flag) so we can send follow-up messages
-	// --dangerously-skip-permissions allows autonomous file operations
-	// --output-format stream-json enables real-time streaming output (requires --verbose)
-	cmd := exec.CommandContext(ctx, claudeCmd, "--dangerously-skip-permissions", "--output-format", "stream-json", "--verbose")
+	cmd := exec.CommandContext(runCtx, claudeCmd, args...)
 	cmd.Dir = task.ProjectDir
+	runInOwnProcessGroup(cmd)
+	if !r.injectSecrets(task, cmd) || !r.applyTaskEnv(task, cmd) {
+		return
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -205,49 +328,59 @@ func (r *RalphRunner) Start(task *Task, config *Config) {
 		return
 	}
 
-	proc.cmd = cmd
-	proc.stdin = stdin
-
 	// Start the process
-	log.Printf("Executing: %s --dangerously-skip-permissions --output-format stream-json --verbose", claudeCmd)
+	logger.Debug("Executing Claude", "command", claudeCmd+" "+strings.Join(args, " "))
 	if err := cmd.Start(); err != nil {
 		r.handleError(task.ID, fmt.Sprintf("Failed to start Claude: %v", err))
 		return
 	}
+	started = true
+	r.attachProcess(proc, cmd, stdin)
+	r.beginRun(proc, task, runTrigger(task, false, ""))
 
-	log.Printf("Claude process started with PID %d", cmd.Process.Pid)
+	logger.Info("Claude process started", "pid", cmd.Process.Pid)
 	r.hub.BroadcastLog(task.ID, fmt.Sprintf("[FORGE] Claude started (PID %d)...\n", cmd.Process.Pid))
 	r.hub.BroadcastStatus(task.ID, StatusProgress, 0)
 
-	// Persist PID and timestamps for process tracking/recovery
-	r.db.UpdateTaskProcessInfo(task.ID, cmd.Process.Pid, "running")
+	// Persist the start time; the PID was stored by attachProcess
 	r.db.UpdateTaskStartedAt(task.ID)
 
-	// Send the initial prompt via stdin and close it to signal EOF
-	// Claude needs EOF to start processing in non-interactive mode
+	// Send the initial prompt as the session's first user message; stdin
+	// stays open for feedback until Claude has answered every message
 	go func() {
-		_, err := stdin.Write([]byte(prompt + "\n"))
-		if err != nil {
-			log.Printf("Error writing initial prompt to stdin: %v", err)
+		if err := r.sendUserMessage(proc, prompt); err != nil {
+			logger.Error("Failed to write initial prompt to stdin", "err", err)
 		}
-		// Close stdin to signal EOF - Claude will start processing
-		stdin.Close()
-		log.Printf("Stdin closed for task %s, Claude should start processing", task.ID)
 	}()
 
 	// Process output
-	go r.processOutput(task.ID, stdout, task.MaxIterations)
-	go r.processOutput(task.ID, stderr, task.MaxIterations)
-
-	// Wait for completion
+	var outputDone sync.WaitGroup
+	outputDone.Add(2)
+	redactor := r.logRedactor(task, config)
+	go func() { defer outputDone.Done(); r.processOutput(logger, task.ID, stdout, task.MaxIterations, redactor) }()
+	go func() { defer outputDone.Done(); r.processOutput(logger, task.ID, stderr, task.MaxIterations, redactor) }()
+	go r.watchIdle(proc)
+
+	// Wait for completion; the output must be read completely before Wait closes the pipes
 	go func() {
+		defer close(proc.done)
+		outputDone.Wait()
 		err := cmd.Wait()
+		r.finishRun(proc, runCtx.Err() == context.Canceled, err)
 		r.cleanup(task.ID)
 
-		if ctx.Err() == context.Canceled {
+		if runCtx.Err() == context.Canceled {
+			proc.mu.Lock()
+			drained := proc.drained
+			proc.mu.Unlock()
+			if drained {
+				r.hub.BroadcastLog(task.ID, "\n[FORGE] Process stopped for server shutdown\n")
+				return
+			}
 			r.hub.BroadcastLog(task.ID, "\n[FORGE] Process stopped by user\n")
+			r.finishPlan(task.ID, true)
 			// Still try to start next queued task after cancellation
-			go r.TryStartNextQueued()
+			go r.TryStartNextQueued(context.Background())
 			return
 		}
 
@@ -262,17 +395,42 @@ func (r *RalphRunner) Start(task *Task, config *Config) {
 			r.hub.BroadcastLog(task.ID, "\n[FORGE] Process completed\n")
 		}
 
+		// Failed runs are retried instead of ending the task (see retry.go)
+		if r.recoverFailedRun(proc) {
+			go r.TryStartNextQueued(context.Background())
+			return
+		}
+
+		// A planning run leaves only the plan, nothing to publish
+		if !r.finishPlan(task.ID, false) {
+			// Branch-per-task workflow: push and open the PR before the next task switches branches
+			r.publishTaskBranch(task.ID)
+		}
+
 		// Try to start next queued task after process cleanup
-		go r.TryStartNextQueued()
+		go r.TryStartNextQueued(context.Background())
 	}()
 }
 
 // Continue stops any running process and restarts with additional feedback
-func (r *RalphRunner) Continue(task *Task, config *Config, feedback string) error {
+func (r *RalphRunner) Continue(ctx context.Context, task *Task, config *Config, feedback string) error {
+	if r.isDraining() {
+		return errDraining
+	}
+
 	r.mu.RLock()
 	_, isRunning := r.processes[task.ID]
 	r.mu.RUnlock()
 
+	// A live session takes the feedback as its next user message
+	if isRunning {
+		err := r.SendFeedback(task.ID, feedback)
+		if err == nil {
+			return nil
+		}
+		r.taskLog(task.ID).Info("Session does not take feedback, restarting", "err", err)
+	}
+
 	// If already running, stop it first (we'll restart with feedback)
 	if isRunning {
 		r.hub.BroadcastLog(task.ID, "\n[FORGE] Stopping current process to apply feedback...\n")
@@ -293,37 +451,80 @@ func (r *RalphRunner) Continue(task *Task, config *Config, feedback string) erro
 	}
 
 	// Start new process with continuation prompt
-	r.startContinuation(task, config, feedback)
+	r.startContinuation(ctx, task, config, feedback)
 	return nil
 }
 
 // startContinuation starts a new Claude process to continue work on a task
-func (r *RalphRunner) startContinuation(task *Task, config *Config, feedback string) {
+func (r *RalphRunner) startContinuation(ctx context.Context, task *Task, config *Config, feedback string) {
+	logger := logFrom(ctx).With("component", "runner", "task_id", task.ID)
+
+	if r.queuePaused() {
+		r.deferStart(ctx, task, feedback, queuePausedNote)
+		return
+	}
+	if r.coolingDown() {
+		r.deferStart(ctx, task, feedback, cooldownNote)
+		return
+	}
+
 	r.mu.Lock()
 
+	if r.draining {
+		r.mu.Unlock()
+		r.deferStart(ctx, task, feedback, drainingNote)
+		return
+	}
+
 	// Check if already running (shouldn't happen, but be safe)
 	if _, exists := r.processes[task.ID]; exists {
 		r.mu.Unlock()
-		log.Printf("Task %s already has a running process", task.ID)
+		logger.Warn("Task already has a running process")
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	runCtx, cancel := context.WithCancel(context.Background())
 
 	proc := &RalphProcess{
-		TaskID: task.ID,
-		cancel: cancel,
+		TaskID:     task.ID,
+		log:        logger,
+		dir:        task.ProjectDir,
+		projectID:  task.ProjectID,
+		boardID:    task.BoardID,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		checkpoint: make(chan struct{}, 1),
 	}
 	r.processes[task.ID] = proc
 	r.mu.Unlock()
 
-	// Build the command
-	claudeCmd := config.ClaudeCommand
-	if claudeCmd == "" {
-		claudeCmd = "claude"
+	// Until Claude runs, returning early ends the process here: whoever waits
+	// on done (drain, checkpoints) must not wait for a run that never comes
+	started := false
+	defer func() {
+		if !started {
+			cancel()
+			close(proc.done)
+		}
+	}()
+
+	// Build the command from what the installed CLI supports
+	claudeCmd := claudeCommand(config)
+	cli := claudeCLI.Get(claudeCmd)
+	args, err := cli.Args(false, r.claudeSettings(task, config))
+	if err != nil {
+		r.handleError(task.ID, err.Error())
+		return
 	}
+	mcpArgs, err := r.mcpConfigArgs(task, cli)
+	if err != nil {
+		r.handleError(task.ID, err.Error())
+		return
+	}
+	args = append(args, mcpArgs...)
+	proc.textInput = !cli.StreamJSONInput
 
-	log.Printf("Continuing RALPH for task %s with feedback", task.ID)
+	logger.Info("Continuing RALPH with feedback")
 	r.hub.BroadcastLog(task.ID, "\n[FORGE] Continuing task with user feedback...\n")
 
 	// Get branch protection rules for the project
@@ -331,17 +532,27 @@ func (r *RalphRunner) startContinuation(task *Task, config *Config, feedback str
 	if task.ProjectID != "" {
 		if rules, err := r.db.GetBranchRules(task.ProjectID); err == nil {
 			for _, rule := range rules {
-				protectedBranches = append(protectedBranches, rule.BranchPattern)
+				// Exceptions ("!pattern") only narrow the rules enforced on push
+				if !strings.HasPrefix(rule.BranchPattern, "!") {
+					protectedBranches = append(protectedBranches, rule.BranchPattern)
+				}
 			}
 		}
 	}
+	var protectedPaths []string
+	if task.ProjectID != "" {
+		if rules, err := r.db.GetPathRules(task.ProjectID); err == nil {
+			protectedPaths = protectedPathPatterns(rules)
+		}
+	}
 
 	// Get attachments for the task
 	attachments, err := r.db.GetAttachmentsByTask(task.ID)
 	if err != nil {
-		log.Printf("Warning: Failed to get attachments for task %s: %v", task.ID, err)
+		logger.Warn("Failed to get attachments", "err", err)
 		attachments = nil
 	}
+	attachments = r.forwardAttachments(task, attachments)
 
 	// Build continuation prompt
 	var sb strings.Builder
@@ -360,6 +571,8 @@ func (r *RalphRunner) startContinuation(task *Task, config *Config, feedback str
 		sb.WriteString("\n\n")
 	}
 
+	writeApprovedPlan(&sb, task)
+
 	// Add attachments info if any
 	if len(attachments) > 0 {
 		sb.WriteString("## Attachments\n\n")
@@ -371,7 +584,7 @@ func (r *RalphRunner) startContinuation(task *Task, config *Config, feedback str
 			} else if strings.HasPrefix(att.MimeType, "video/") {
 				fileType = "Video"
 			}
-			sb.WriteString(fmt.Sprintf("- %s: %s (Path: %s)\n", fileType, att.Filename, att.Path))
+			sb.WriteString(fmt.Sprintf("- %s: %s (Path: %s)\n", fileType, att.Filename, att.promptPath()))
 		}
 		sb.WriteString("\nYou can read these files using the Read tool to view images for visual context.\n\n")
 	}
@@ -388,6 +601,7 @@ func (r *RalphRunner) startContinuation(task *Task, config *Config, feedback str
 		}
 		sb.WriteString("\n")
 	}
+	writeProtectedPaths(&sb, protectedPaths)
 
 	// Only include user feedback section if there's actual feedback
 	if feedback != "" {
@@ -410,8 +624,12 @@ func (r *RalphRunner) startContinuation(task *Task, config *Config, feedback str
 	prompt := sb.String()
 
 	// Run Claude
-	cmd := exec.CommandContext(ctx, claudeCmd, "--dangerously-skip-permissions", "--output-format", "stream-json", "--verbose")
+	cmd := exec.CommandContext(runCtx, claudeCmd, args...)
 	cmd.Dir = task.ProjectDir
+	runInOwnProcessGroup(cmd)
+	if !r.injectSecrets(task, cmd) || !r.applyTaskEnv(task, cmd) {
+		return
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -431,41 +649,52 @@ func (r *RalphRunner) startContinuation(task *Task, config *Config, feedback str
 		return
 	}
 
-	proc.cmd = cmd
-	proc.stdin = stdin
-
 	if err := cmd.Start(); err != nil {
 		r.handleError(task.ID, fmt.Sprintf("Failed to start Claude: %v", err))
 		return
 	}
+	started = true
+	// Persisting the PID also clears the resumable mark
+	r.attachProcess(proc, cmd, stdin)
+	r.beginRun(proc, task, runTrigger(task, true, feedback))
 
-	log.Printf("Claude continuation started with PID %d", cmd.Process.Pid)
+	logger.Info("Claude continuation started", "pid", cmd.Process.Pid)
 	r.hub.BroadcastLog(task.ID, fmt.Sprintf("[FORGE] Claude started (PID %d)...\n", cmd.Process.Pid))
 
-	// Send the continuation prompt via stdin and close it to signal EOF
+	// Send the continuation prompt as the session's first user message
 	go func() {
-		_, err := stdin.Write([]byte(prompt + "\n"))
-		if err != nil {
-			log.Printf("Error writing continuation prompt to stdin: %v", err)
+		if err := r.sendUserMessage(proc, prompt); err != nil {
+			logger.Error("Failed to write continuation prompt to stdin", "err", err)
 		}
-		// Close stdin to signal EOF - Claude will start processing
-		stdin.Close()
-		log.Printf("Stdin closed for continuation task %s", task.ID)
 	}()
 
 	// Process output
-	go r.processOutput(task.ID, stdout, task.MaxIterations)
-	go r.processOutput(task.ID, stderr, task.MaxIterations)
-
-	// Wait for completion
+	var outputDone sync.WaitGroup
+	outputDone.Add(2)
+	redactor := r.logRedactor(task, config)
+	go func() { defer outputDone.Done(); r.processOutput(logger, task.ID, stdout, task.MaxIterations, redactor) }()
+	go func() { defer outputDone.Done(); r.processOutput(logger, task.ID, stderr, task.MaxIterations, redactor) }()
+	go r.watchIdle(proc)
+
+	// Wait for completion; the output must be read completely before Wait closes the pipes
 	go func() {
+		defer close(proc.done)
+		outputDone.Wait()
 		err := cmd.Wait()
+		r.finishRun(proc, runCtx.Err() == context.Canceled, err)
 		r.cleanup(task.ID)
 
-		if ctx.Err() == context.Canceled {
+		if runCtx.Err() == context.Canceled {
+			proc.mu.Lock()
+			drained := proc.drained
+			proc.mu.Unlock()
+			if drained {
+				r.hub.BroadcastLog(task.ID, "\n[FORGE] Process stopped for server shutdown\n")
+				return
+			}
 			r.hub.BroadcastLog(task.ID, "\n[FORGE] Process stopped by user\n")
 			// Still try to start next queued task after cancellation
-			go r.TryStartNextQueued()
+			go r.TryStartNextQueued(context.Background())
 			return
 		}
 
@@ -480,14 +709,23 @@ func (r *RalphRunner) startContinuation(task *Task, config *Config, feedback str
 			r.hub.BroadcastLog(task.ID, "\n[FORGE] Process completed\n")
 		}
 
+		// Failed runs are retried instead of ending the task (see retry.go)
+		if r.recoverFailedRun(proc) {
+			go r.TryStartNextQueued(context.Background())
+			return
+		}
+
+		// Branch-per-task workflow: push and open the PR before the next task switches branches
+		r.publishTaskBranch(task.ID)
+
 		// Try to start next queued task after process cleanup
-		go r.TryStartNextQueued()
+		go r.TryStartNextQueued(context.Background())
 	}()
 }
 
 // processOutput reads and processes output from Claude
-func (r *RalphRunner) processOutput(taskID string, reader io.Reader, maxIterations int) {
-	log.Printf("processOutput started for task %s", taskID)
+func (r *RalphRunner) processOutput(logger *slog.Logger, taskID string, reader io.Reader, maxIterations int, redactor *outputRedactor) {
+	logger.Debug("Reading Claude output")
 	scanner := bufio.NewScanner(reader)
 	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB buffer
 
@@ -497,16 +735,18 @@ func (r *RalphRunner) processOutput(taskID string, reader io.Reader, maxIteratio
 	lineCount := 0
 
 	for scanner.Scan() {
-		line := scanner.Text() + "\n"
+		// Secrets never reach the logs or the browser
+		line := redactor.Redact(scanner.Text()) + "\n"
 		lineCount++
 		preview := line
 		if len(preview) > 100 {
 			preview = preview[:100]
 		}
-		log.Printf("Output line %d: %s", lineCount, preview)
+		logger.Debug("Output", "line", lineCount, "text", preview)
 
 		// Broadcast immediately for real-time updates
 		r.hub.BroadcastLog(taskID, line)
+		r.recordOutput(taskID)
 
 		// Buffer for periodic DB writes
 		logBuffer.WriteString(line)
@@ -522,12 +762,24 @@ func (r *RalphRunner) processOutput(taskID string, reader io.Reader, maxIteratio
 			r.db.UpdateTaskIteration(taskID, iteration)
 			r.hub.BroadcastStatus(taskID, StatusProgress, iteration)
 
+			// A new iteration means the previous one is complete
+			// and protected paths are checked before it is saved
+			if iteration > 1 && r.enforcePathRules(taskID) {
+				r.saveCheckpoint(taskID, iteration-1)
+				r.reachedCheckpoint(taskID)
+			}
+
 			// Check iteration limit
 			if iteration >= maxIterations {
 				r.handleIterationLimit(taskID, maxIterations)
 			}
 		}
 
+		// A result ends Claude's answer to one user message
+		if isResultEvent(line) {
+			r.finishTurn(taskID)
+		}
+
 		// Flush logs to DB periodically (every 5 seconds)
 		if time.Since(lastFlush) > 5*time.Second {
 			if logBuffer.Len() > 0 {
@@ -544,29 +796,83 @@ func (r *RalphRunner) processOutput(taskID string, reader io.Reader, maxIteratio
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("Error reading output for task %s: %v", taskID, err)
+		logger.Error("Failed to read output", "err", err)
+	}
+}
+
+// lastRalphMessage returns RALPH's final message from the stream-json task
+// logs: the text of the result event, or else the last assistant text
+func lastRalphMessage(logs string) string {
+	var last string
+	for _, line := range strings.Split(logs, "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var event struct {
+			Type    string `json:"type"`
+			Result  string `json:"result"`
+			Message struct {
+				Content []struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"message"`
+		}
+		if json.Unmarshal([]byte(line), &event) != nil {
+			continue
+		}
+		switch event.Type {
+		case "result":
+			if event.Result != "" {
+				last = event.Result
+			}
+		case "assistant":
+			for _, c := range event.Message.Content {
+				if c.Type == "text" && strings.TrimSpace(c.Text) != "" {
+					last = c.Text
+				}
+			}
+		}
 	}
+	return strings.TrimSpace(last)
 }
 
 // handleSuccess handles successful task completion
 // Note: TryStartNextQueued is called from cmd.Wait() goroutine after process cleanup
 func (r *RalphRunner) handleSuccess(taskID string) {
+	// A planning run ends with the plan, see finishPlan
+	if task, _ := r.db.GetTask(taskID); isPlanning(task) {
+		return
+	}
+
+	// Changes to protected paths are reverted or block the task first
+	if !r.enforcePathRules(taskID) {
+		return
+	}
+
 	// Get task to find project directory
 	task, _ := r.db.GetTask(taskID)
 	if task != nil {
 		// Record commit hash for trunk-based development
 		projectDir := task.ProjectDir
-		if projectDir == "" && task.ProjectID != "" {
-			project, _ := r.db.GetProject(task.ProjectID)
-			if project != nil {
-				projectDir = project.Path
-			}
+		var project *Project
+		if task.ProjectID != "" {
+			project, _ = r.db.GetProject(task.ProjectID)
+		}
+		if projectDir == "" && project != nil {
+			projectDir = project.Path
 		}
 		if projectDir != "" && IsGitRepository(projectDir) {
 			if commitHash, err := GetCurrentCommitHash(projectDir); err == nil {
 				r.db.UpdateTaskCommitHash(taskID, commitHash)
 			}
 		}
+		// Index the commits RALPH made in this run
+		if project != nil {
+			go commitIndex.IndexProject(r.db, project)
+		}
+		// Frontend projects: reviewers see the page as RALPH left it
+		r.verifyScreenshot(task, project)
 	}
 
 	r.db.UpdateTaskStatus(taskID, StatusReview)
@@ -576,15 +882,85 @@ func (r *RalphRunner) handleSuccess(taskID string) {
 	// Get updated task and broadcast
 	task, _ = r.db.GetTask(taskID)
 	if task != nil {
+		task.Attachments, _ = r.db.GetAttachmentsByTask(taskID)
 		r.hub.BroadcastTaskUpdate(task)
+		if task.JiraKey != "" {
+			jiraSync.Notify()
+		}
 	}
+
+	// A resolution task resumes the task its conflict blocked
+	r.checkConflictResolved(taskID)
+}
+
+// injectSecrets adds the project's injected secrets to the environment of
+// cmd. Returns false (after failing the task) if they cannot be decrypted.
+func (r *RalphRunner) injectSecrets(task *Task, cmd *exec.Cmd) bool {
+	env, names, err := projectSecretEnv(r.db, task.ProjectID)
+	if err != nil {
+		r.handleError(task.ID, fmt.Sprintf("Failed to load project secrets: %v", err))
+		return false
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+		r.hub.BroadcastLog(task.ID, "[FORGE] Secrets available as environment variables: "+strings.Join(names, ", ")+"\n")
+	}
+	return true
+}
+
+// runInOwnProcessGroup keeps terminal signals such as Ctrl+C away from Claude,
+// so a shutdown can drain it, and makes stopping it also kill the tools it started
+func runInOwnProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// signalProcessGroup signals the process group led by pid, or only the
+// process if it has no group of its own (started by an older FORGE)
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pid, sig); err == nil {
+		return nil
+	}
+	return syscall.Kill(pid, sig)
+}
+
+// isRalphProcess reports whether pid is a live Claude process started by
+// FORGE, rather than an exited one or an unrelated process that reused the PID
+func isRalphProcess(pid int) bool {
+	out, err := exec.Command("ps", "-o", "args=", "-p", strconv.Itoa(pid)).Output()
+	return err == nil && strings.Contains(string(out), "--output-format stream-json")
+}
+
+// orphanExitWait is how long a process left over from a previous server gets to exit
+const orphanExitWait = 5 * time.Second
+
+// terminateOrphan ends a RALPH process that outlived the server which started
+// it, waking it first if it was paused, and kills it if it does not exit
+func terminateOrphan(pid int) {
+	signalProcessGroup(pid, syscall.SIGTERM)
+	signalProcessGroup(pid, syscall.SIGCONT)
+	for deadline := time.Now().Add(orphanExitWait); time.Now().Before(deadline); {
+		if !isRalphProcess(pid) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	signalProcessGroup(pid, syscall.SIGKILL)
 }
 
 // handleBlocked handles a blocked task
 // Note: TryStartNextQueued is called from cmd.Wait() goroutine after process cleanup
 func (r *RalphRunner) handleBlocked(taskID string, reason string) {
+	r.blockTask(taskID, reason, "")
+}
+
+// blockTask blocks a task with reason and the class of the failure that
+// blocked it ("" = none, see failures.go)
+func (r *RalphRunner) blockTask(taskID, reason, class string) {
 	r.db.UpdateTaskStatus(taskID, StatusBlocked)
-	r.db.UpdateTaskError(taskID, reason)
+	r.db.UpdateTaskFailure(taskID, reason, class)
 	r.hub.BroadcastStatus(taskID, StatusBlocked, 0)
 	r.hub.BroadcastLog(taskID, "\n[FORGE] Task blocked\n")
 
@@ -592,6 +968,12 @@ func (r *RalphRunner) handleBlocked(taskID string, reason string) {
 	if task != nil {
 		r.hub.BroadcastTaskUpdate(task)
 	}
+
+	// Claude explains why it got stuck once the process has exited, unless
+	// it cannot run at all
+	if class != FailureAuth {
+		go r.triageBlocked(taskID)
+	}
 }
 
 // handleIterationLimit handles reaching the iteration limit
@@ -608,10 +990,25 @@ func (r *RalphRunner) handleIterationLimit(taskID string, limit int) {
 		r.hub.BroadcastTaskUpdate(task)
 	}
 
+	// Claude explains why it got stuck once the process has exited
+	go r.triageBlocked(taskID)
+
 	// Stop the process - this triggers cleanup and TryStartNextQueued via cmd.Wait goroutine
 	r.Stop(taskID)
 }
 
+// taskLog returns the logger of a task's running process, which carries the
+// ID of the request that started it
+func (r *RalphRunner) taskLog(taskID string) *slog.Logger {
+	r.mu.RLock()
+	proc, exists := r.processes[taskID]
+	r.mu.RUnlock()
+	if exists && proc.log != nil {
+		return proc.log
+	}
+	return componentLog("runner").With("task_id", taskID)
+}
+
 // handleError handles an error during startup
 func (r *RalphRunner) handleError(taskID string, message string) {
 	r.db.UpdateTaskStatus(taskID, StatusBlocked)
@@ -624,46 +1021,56 @@ func (r *RalphRunner) handleError(taskID string, message string) {
 		r.hub.BroadcastTaskUpdate(task)
 	}
 
+	r.taskLog(taskID).Error("Task failed", "err", message)
 	r.cleanup(taskID)
 
 	// Try to start next queued task
-	go r.TryStartNextQueued()
+	go r.TryStartNextQueued(context.Background())
 }
 
-// Pause pauses a running RALPH process
-func (r *RalphRunner) Pause(taskID string) error {
-	r.mu.RLock()
-	proc, exists := r.processes[taskID]
-	r.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("no running process for task %s", taskID)
-	}
+// ProcessStatusPaused marks a task whose process is stopped with SIGSTOP
+const ProcessStatusPaused = "paused"
 
+// attachProcess records a started command in its process and persists the PID
+// for recovery. Both happen under the process lock, so a Pause cannot see the
+// command before its status is stored and then be overwritten by "running".
+func (r *RalphRunner) attachProcess(proc *RalphProcess, cmd *exec.Cmd, stdin io.WriteCloser) {
 	proc.mu.Lock()
 	defer proc.mu.Unlock()
+	proc.cmd = cmd
+	proc.stdin = stdin
+	r.db.UpdateTaskProcessInfo(proc.TaskID, cmd.Process.Pid, "running")
+}
 
-	if proc.paused {
-		return fmt.Errorf("process already paused")
-	}
-
-	if proc.cmd != nil && proc.cmd.Process != nil {
-		if err := proc.cmd.Process.Signal(syscall.SIGSTOP); err != nil {
-			return fmt.Errorf("failed to pause: %v", err)
-		}
-		proc.paused = true
-		r.hub.BroadcastLog(taskID, "\n[FORGE] Process paused\n")
+// Pause pauses a running RALPH process
+func (r *RalphRunner) Pause(taskID string) error {
+	if err := r.setPaused(taskID, true); err != nil {
+		return err
 	}
-
+	r.hub.BroadcastLog(taskID, "\n[FORGE] Process paused\n")
+	r.broadcastTask(taskID)
 	return nil
 }
 
 // Resume resumes a paused RALPH process
 func (r *RalphRunner) Resume(taskID string) error {
+	if err := r.setPaused(taskID, false); err != nil {
+		return err
+	}
+	r.hub.BroadcastLog(taskID, "\n[FORGE] Process resumed\n")
+	r.broadcastTask(taskID)
+	return nil
+}
+
+// setPaused stops or continues a process with its whole group, so the tools
+// Claude started pause too, and persists the state. The runner lock is held
+// throughout: cleanup cannot remove the process in between and then have its
+// "finished" overwritten, and the paused process keeps the queue waiting.
+func (r *RalphRunner) setPaused(taskID string, pause bool) error {
 	r.mu.RLock()
-	proc, exists := r.processes[taskID]
-	r.mu.RUnlock()
+	defer r.mu.RUnlock()
 
+	proc, exists := r.processes[taskID]
 	if !exists {
 		return fmt.Errorf("no running process for task %s", taskID)
 	}
@@ -671,21 +1078,45 @@ func (r *RalphRunner) Resume(taskID string) error {
 	proc.mu.Lock()
 	defer proc.mu.Unlock()
 
-	if !proc.paused {
+	if proc.paused == pause {
+		if pause {
+			return fmt.Errorf("process already paused")
+		}
 		return fmt.Errorf("process not paused")
 	}
+	if proc.cmd == nil || proc.cmd.Process == nil {
+		return fmt.Errorf("process has not started yet")
+	}
 
-	if proc.cmd != nil && proc.cmd.Process != nil {
-		if err := proc.cmd.Process.Signal(syscall.SIGCONT); err != nil {
-			return fmt.Errorf("failed to resume: %v", err)
+	sig, status := syscall.SIGCONT, "running"
+	if pause {
+		sig, status = syscall.SIGSTOP, ProcessStatusPaused
+	}
+	if err := signalProcessGroup(proc.cmd.Process.Pid, sig); err != nil {
+		if pause {
+			return fmt.Errorf("failed to pause: %v", err)
 		}
-		proc.paused = false
-		r.hub.BroadcastLog(taskID, "\n[FORGE] Process resumed\n")
+		return fmt.Errorf("failed to resume: %v", err)
 	}
-
+	proc.paused = pause
+	r.db.UpdateTaskProcessInfo(taskID, proc.cmd.Process.Pid, status)
 	return nil
 }
 
+// isPaused reports whether a process is paused
+func (proc *RalphProcess) isPaused() bool {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	return proc.paused
+}
+
+// broadcastTask sends the current state of a task to all clients
+func (r *RalphRunner) broadcastTask(taskID string) {
+	if task, _ := r.db.GetTask(taskID); task != nil {
+		r.hub.BroadcastTaskUpdate(task)
+	}
+}
+
 // Stop stops a running RALPH process
 func (r *RalphRunner) Stop(taskID string) {
 	r.mu.RLock()
@@ -710,12 +1141,22 @@ func (r *RalphRunner) Stop(taskID string) {
 	r.cleanup(taskID)
 }
 
-// SendFeedback is deprecated - stdin is closed after initial prompt
-// Use Continue instead which will stop and restart with feedback
+// SendFeedback injects a message into a task's live Claude session, which
+// answers it after the current turn. It fails with errSessionGone once the
+// session has finished; Continue then restarts the task with the message.
 func (r *RalphRunner) SendFeedback(taskID string, message string) error {
-	// Since we close stdin after the initial prompt to trigger Claude processing,
-	// we can't send feedback via stdin anymore. Return an error indicating this.
-	return fmt.Errorf("cannot send feedback via stdin - use Continue to restart with feedback")
+	r.mu.RLock()
+	proc, exists := r.processes[taskID]
+	r.mu.RUnlock()
+	if !exists {
+		return errSessionGone
+	}
+	if err := r.sendUserMessage(proc, message); err != nil {
+		return err
+	}
+	proc.log.Info("Sent feedback to the running session")
+	r.hub.BroadcastLog(taskID, "\n[FORGE] Feedback sent to the running session\n")
+	return nil
 }
 
 // cleanup removes a process from the map and clears process tracking info
@@ -728,10 +1169,18 @@ func (r *RalphRunner) cleanup(taskID string) {
 	}
 	delete(r.processes, taskID)
 	r.mu.Unlock()
+	os.Remove(mcpConfigPath(taskID))
 
 	// Clear PID and update finished timestamp
 	r.db.UpdateTaskProcessInfo(taskID, 0, "finished")
 	r.db.UpdateTaskFinishedAt(taskID)
+
+	r.capTaskLogs(taskID)
+
+	// RALPH usually committed or changed files
+	task, _ := r.db.GetTask(taskID)
+	removeForwardedAttachments(task)
+	r.invalidateGitStatus(task)
 }
 
 // StopAll stops all running processes (for graceful shutdown)
@@ -746,7 +1195,7 @@ func (r *RalphRunner) StopAll() {
 		if proc.cancel != nil {
 			proc.cancel()
 		}
-		log.Printf("Stopped process for task %s", taskID)
+		componentLog("runner").Info("Stopped process", "task_id", taskID)
 	}
 
 	r.processes = make(map[string]*RalphProcess)
@@ -760,32 +1209,75 @@ func (r *RalphRunner) IsRunning(taskID string) bool {
 	return exists
 }
 
-// TryStartNextQueued checks if there's a queued task and starts it if no process is running.
-// This is called after a task completes (success, blocked, iteration limit) to auto-start the next queued task.
-func (r *RalphRunner) TryStartNextQueued() {
+// RunningCount returns the number of running processes
+func (r *RalphRunner) RunningCount() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.processes)
+}
+
+// TryStartNextQueued starts the next queued task of an idle lane if fewer than
+// MaxConcurrentTasks processes are running (see queue.go), then looks for the
+// next one. This is called after a task completes (success, blocked, iteration
+// limit) to auto-start the next queued task.
+// ctx passes on the logger of the request that triggered it, if any.
+func (r *RalphRunner) TryStartNextQueued(ctx context.Context) {
+	logger := logFrom(ctx).With("component", "queue")
+
+	// Held until the task's process is registered, so a concurrent call
+	// cannot see its lane idle in the meantime and start a second task
+	r.dispatchMu.Lock()
+	defer r.dispatchMu.Unlock()
+
 	r.mu.RLock()
 	runningCount := len(r.processes)
+	draining := r.draining
 	r.mu.RUnlock()
 
-	// Only start next if no process is running
-	if runningCount > 0 {
-		log.Printf("TryStartNextQueued: %d processes still running, skipping", runningCount)
+	// Queued tasks start after the restart
+	if draining {
+		logger.Debug("Server is shutting down, not starting a queued task")
+		return
+	}
+
+	// Running tasks finish, queued ones wait until the queue is resumed
+	if r.queuePaused() {
+		logger.Debug("Queue is paused, not starting a queued task")
+		return
+	}
+
+	// After a rate limit nothing starts until the cooldown ends (see cooldown.go)
+	if r.coolingDown() {
+		logger.Debug("Queue cools down after a rate limit, not starting a queued task")
+		return
+	}
+
+	config, err := r.db.GetConfig()
+	if err != nil {
+		logger.Error("Failed to load config for the queue", "err", err)
 		return
 	}
 
-	// Get next queued task
-	nextTask, err := r.db.GetNextQueuedTask()
+	// Only start next if a slot is free
+	limit := max(config.MaxConcurrentTasks, 1)
+	if runningCount >= limit {
+		logger.Debug("Concurrency limit reached, not starting a queued task", "running", runningCount, "limit", limit)
+		return
+	}
+
+	// Get next queued task of an idle lane according to the queue policy
+	nextTask, err := r.nextQueuedTask(config)
 	if err != nil {
-		log.Printf("TryStartNextQueued: Error getting next queued task: %v", err)
+		logger.Error("Failed to get next queued task", "err", err)
 		return
 	}
 	if nextTask == nil {
-		log.Printf("TryStartNextQueued: No queued tasks")
+		logger.Debug("No queued task in an idle lane")
 		return
 	}
 
-	log.Printf("TryStartNextQueued: Starting task %s (%s) from queue position %d",
-		nextTask.ID, nextTask.Title, nextTask.QueuePosition)
+	logger = logger.With("task_id", nextTask.ID)
+	logger.Info("Starting task from queue", "title", nextTask.Title, "position", nextTask.QueuePosition)
 
 	// Remove from queue and update status
 	r.db.RemoveFromQueue(nextTask.ID)
@@ -804,7 +1296,7 @@ func (r *RalphRunner) TryStartNextQueued() {
 
 	// If still no project directory, block the task
 	if projectDir == "" {
-		log.Printf("TryStartNextQueued: No project directory for task %s", nextTask.ID)
+		logger.Warn("No project directory, blocking task")
 		r.db.UpdateTaskStatus(nextTask.ID, StatusBlocked)
 		r.db.UpdateTaskError(nextTask.ID, "No project directory specified")
 		updatedTask, _ := r.db.GetTask(nextTask.ID)
@@ -812,12 +1304,14 @@ func (r *RalphRunner) TryStartNextQueued() {
 			r.hub.BroadcastTaskUpdate(updatedTask)
 		}
 		// Try the next one
-		go r.TryStartNextQueued()
+		go r.TryStartNextQueued(ctx)
 		return
 	}
 
 	// Trunk-based development: Switch to working branch and create rollback tag
 	if projectDir != "" && IsGitRepository(projectDir) {
+		unlock := lockRepo(projectDir, "task start")
+
 		var project *Project
 		if nextTask.ProjectID != "" {
 			project, _ = r.db.GetProject(nextTask.ProjectID)
@@ -832,16 +1326,33 @@ func (r *RalphRunner) TryStartNextQueued() {
 		// Switch to target branch if set
 		if targetBranch != "" {
 			if err := EnsureOnBranch(projectDir, targetBranch); err != nil {
-				log.Printf("TryStartNextQueued: Failed to switch to branch %s: %v", targetBranch, err)
+				logger.Warn("Failed to switch to branch", "branch", targetBranch, "err", err)
 			} else {
 				r.db.UpdateTaskWorkingBranch(nextTask.ID, targetBranch)
 				nextTask.WorkingBranch = targetBranch
 			}
 		}
 
-		// Pull latest changes
-		if err := PullFromRemote(projectDir); err != nil {
-			log.Printf("TryStartNextQueued: Pull failed (continuing): %v", err)
+		// Merge the latest changes. A resolution task merges them itself (see conflicts.go).
+		if !r.isResolutionTask(nextTask.ID) {
+			conflict, err := MergeUpstream(projectDir)
+			if err != nil {
+				logger.Warn("Pull failed (continuing)", "err", err)
+			}
+			if conflict != nil {
+				if err := r.openConflict(nextTask, conflict, ConflictStageStart); err != nil {
+					logger.Error("Failed to open merge conflict", "err", err)
+				}
+				unlock()
+				go r.TryStartNextQueued(ctx)
+				return
+			}
+
+			// Branch-per-task workflow: the task gets its own branch off the target branch
+			if branch := checkoutTaskBranch(projectDir, nextTask, project); branch != "" {
+				r.db.UpdateTaskWorkingBranch(nextTask.ID, branch)
+				nextTask.WorkingBranch = branch
+			}
 		}
 
 		// Create rollback tag
@@ -849,8 +1360,9 @@ func (r *RalphRunner) TryStartNextQueued() {
 		if err == nil {
 			r.db.UpdateTaskRollbackTag(nextTask.ID, tagName)
 		} else {
-			log.Printf("TryStartNextQueued: Failed to create rollback tag: %v", err)
+			logger.Warn("Failed to create rollback tag", "err", err)
 		}
+		unlock()
 	}
 
 	// Broadcast status update
@@ -867,18 +1379,26 @@ func (r *RalphRunner) TryStartNextQueued() {
 		updatedTask.ProjectDir = projectDir
 	}
 
-	// Get config and start RALPH
-	config, _ := r.db.GetConfig()
+	// A planning run starts over, there is no session to continue
+	if isPlanning(updatedTask) && updatedTask.ContinueMessage != "" {
+		r.db.ClearContinueMessage(updatedTask.ID)
+		updatedTask.ContinueMessage = ""
+	}
 
 	// Check if there's a continue message (from resume action)
 	if updatedTask.ContinueMessage != "" {
-		log.Printf("TryStartNextQueued: Task %s has continue message, using continuation prompt", updatedTask.ID)
+		logger.Info("Task has a continue message, using continuation prompt")
 		// Clear the continue message after reading it
 		r.db.ClearContinueMessage(updatedTask.ID)
 		// Use the continuation prompt which includes the message
-		go r.startContinuation(updatedTask, config, updatedTask.ContinueMessage)
+		r.startContinuation(ctx, updatedTask, config, updatedTask.ContinueMessage)
 	} else {
 		// Regular start
-		go r.Start(updatedTask, config)
+		r.Start(ctx, updatedTask, config)
+	}
+
+	// Another lane may be waiting for a free slot
+	if runningCount+1 < limit {
+		go r.TryStartNextQueued(ctx)
 	}
 }
@@ -0,0 +1,99 @@
+// redact.go masks secrets in RALPH's output before it is stored in the task
+// logs or broadcast over WebSocket. Known values (the tokens of the config and
+// the secrets of the task's project) are replaced wherever they appear, also
+// in their JSON-escaped form since the output is stream-json. Common
+// credential formats such as GitHub tokens, API keys, bearer tokens and
+// NAME_TOKEN=value assignments from environment dumps are masked as well.
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redactedText replaces a masked secret
+const redactedText = "[REDACTED]"
+
+// minRedactLength keeps short values such as "true" or "1" from masking half the log
+const minRedactLength = 6
+
+// credentialPatterns match well-known token formats
+var credentialPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),                                                  // GitHub tokens
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),                                                // GitHub fine-grained tokens
+	regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`),                                                    // GitLab tokens
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}\b`),                                                       // Anthropic and OpenAI API keys
+	regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`),                                               // Slack tokens
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),                                                   // AWS access key IDs
+	regexp.MustCompile(`\blin_api_[A-Za-z0-9]{20,}\b`),                                                    // Linear API keys
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`),                 // JWTs
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----(?:.*?-----END [A-Z ]*PRIVATE KEY-----|[^"]*)`), // Private keys, up to the end of the JSON string
+}
+
+// credentialAssignments match credentials after a name or scheme that is kept,
+// e.g. API_TOKEN=... from an environment dump or "Authorization: Bearer ..."
+var credentialAssignments = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(\b[A-Z0-9_]*(?:TOKEN|SECRET|PASSWORD|PASSWD|API_?KEY|ACCESS_KEY)[A-Z0-9_]*=)[^\s"'\\]{8,}`),
+	regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/-]{20,}=*`),
+}
+
+// outputRedactor masks secrets in lines of output
+type outputRedactor struct {
+	values []string // Known secrets and their JSON-escaped forms, longest first
+}
+
+// newOutputRedactor returns a redactor for the given known secret values
+func newOutputRedactor(secrets []string) *outputRedactor {
+	r := &outputRedactor{}
+	seen := map[string]bool{}
+	add := func(value string) {
+		if len(value) >= minRedactLength && !seen[value] {
+			seen[value] = true
+			r.values = append(r.values, value)
+		}
+	}
+	for _, secret := range secrets {
+		add(secret)
+		if escaped, err := json.Marshal(secret); err == nil {
+			add(strings.Trim(string(escaped), `"`))
+		}
+	}
+	// A secret containing another one must be replaced first
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+	return r
+}
+
+// Redact returns line with all secrets masked
+func (r *outputRedactor) Redact(line string) string {
+	for _, value := range r.values {
+		line = strings.ReplaceAll(line, value, redactedText)
+	}
+	for _, pattern := range credentialPatterns {
+		line = pattern.ReplaceAllString(line, redactedText)
+	}
+	for _, pattern := range credentialAssignments {
+		line = pattern.ReplaceAllString(line, "${1}"+redactedText)
+	}
+	return line
+}
+
+// logRedactor returns the redactor of a run of task, which knows the tokens
+// of the config and all secrets of the task's project
+func (r *RalphRunner) logRedactor(task *Task, config *Config) *outputRedactor {
+	var secrets []string
+	for _, field := range config.credentialFields() {
+		secrets = append(secrets, *field.value)
+	}
+	if task.ProjectID != "" {
+		values, err := projectSecretValues(r.db, task.ProjectID)
+		if err != nil {
+			r.taskLog(task.ID).Warn("Failed to load project secrets for log redaction", "err", err)
+		}
+		for _, value := range values {
+			secrets = append(secrets, value)
+		}
+	}
+	return newOutputRedactor(secrets)
+}
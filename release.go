@@ -0,0 +1,433 @@
+// release.go cuts releases of a project. POST /api/projects/{id}/release
+// collects the commits since the last tag together with the tasks they were
+// made for and has RALPH write a section for the new version at the top of
+// CHANGELOG.md. FORGE then updates the version files (see version.go),
+// commits, creates an annotated tag with the section as its message and, if
+// requested, pushes both and publishes a GitHub Release. Releases are stored per project and reported
+// as release_updated WebSocket messages.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// changelogFile is where RALPH writes the release notes
+const changelogFile = "CHANGELOG.md"
+
+// releaseCommit is a commit that goes into a release
+type releaseCommit struct {
+	CommitInfo
+	TaskTitles  []string
+	TaskTypeIDs []string
+	Breaking    bool // Has a BREAKING CHANGE footer
+}
+
+// HandleProjectReleases handles GET /api/projects/{id}/releases
+func (h *Handler) HandleProjectReleases(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	releases, err := h.db.GetReleasesByProject(project.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get releases: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, releases)
+}
+
+// HandleProjectRelease handles POST /api/projects/{id}/release
+// It responds with 202 and the release while RALPH drafts the notes.
+func (h *Handler) HandleProjectRelease(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if !IsGitRepository(project.Path) {
+		h.writeError(w, http.StatusBadRequest, "Project is not a git repository")
+		return
+	}
+
+	var req CreateReleaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+
+	releases, err := h.db.GetReleasesByProject(project.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get releases: "+err.Error())
+		return
+	}
+	for _, existing := range releases {
+		if existing.Status == ReleaseStatusDrafting {
+			h.writeError(w, http.StatusConflict, "Release "+existing.Tag+" is still being drafted")
+			return
+		}
+	}
+
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+	if req.Bump != "" && bumpRank[req.Bump] == 0 {
+		h.writeError(w, http.StatusBadRequest, "Invalid bump: "+req.Bump+" (use major, minor or patch)")
+		return
+	}
+	if req.GithubRelease {
+		if config.GithubToken == "" {
+			h.writeError(w, http.StatusBadRequest, "GitHub token not configured")
+			return
+		}
+		remoteURL, _ := GetRemoteURL(project.Path)
+		if _, err := githubEndpointsFor(config, project).parseRepo(remoteURL); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Remote origin is not a GitHub repository")
+			return
+		}
+		req.Push = true
+	}
+
+	previous := latestReleaseTag(project.Path)
+	commits, err := h.releaseCommits(project.Path, previous)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read git log: "+err.Error())
+		return
+	}
+	if len(commits) == 0 {
+		if previous != "" {
+			h.writeError(w, http.StatusBadRequest, "No commits since "+previous)
+		} else {
+			h.writeError(w, http.StatusBadRequest, "The project has no commits yet")
+		}
+		return
+	}
+
+	tag := strings.TrimSpace(req.Tag)
+	if tag == "" {
+		tag = proposeVersion(project.Path, previous, commits, req.Bump).Next
+		if tag == "" {
+			h.writeError(w, http.StatusBadRequest, "Cannot derive the next version from tag "+previous+", pass a tag")
+			return
+		}
+	}
+	if err := checkNewTag(project.Path, tag); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	release := &Release{
+		ProjectID:   project.ID,
+		Tag:         tag,
+		Name:        strings.TrimSpace(req.Name),
+		PreviousTag: previous,
+		CommitCount: len(commits),
+		Status:      ReleaseStatusDrafting,
+	}
+	if release.Name == "" {
+		release.Name = tag
+	}
+	if err := h.db.CreateRelease(release); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create release: "+err.Error())
+		return
+	}
+
+	task, err := h.createReleaseTask(project, release, commits, config)
+	if err != nil {
+		h.failRelease(release, fmt.Errorf("failed to create release task: %w", err))
+		h.writeError(w, http.StatusInternalServerError, "Failed to create release task: "+err.Error())
+		return
+	}
+	release.TaskID = task.ID
+	h.db.UpdateRelease(release)
+	h.hub.BroadcastReleaseUpdate(release)
+
+	started := *release // runRelease updates its own copy
+	go h.runRelease(project, release, commits, req, config)
+
+	h.writeJSON(w, http.StatusAccepted, started)
+}
+
+// createReleaseTask queues the RALPH task that writes the changelog section
+func (h *Handler) createReleaseTask(project *Project, release *Release, commits []releaseCommit, config *Config) (*Task, error) {
+	var desc strings.Builder
+	fmt.Fprintf(&desc, "Write the release notes for version **%s** of %s.\n\n", release.Tag, project.Name)
+	fmt.Fprintf(&desc, "Add a section headed `## %s - %s` at the top of %s, above any older versions; create the file with a `# Changelog` title if it does not exist. ",
+		release.Tag, time.Now().Format(time.DateOnly), changelogFile)
+	desc.WriteString("Summarize the changes for users of the project, grouped under `### Added`, `### Changed` and `### Fixed` (leave out empty groups). ")
+	desc.WriteString("Combine related commits, leave out purely internal ones and read the code where a commit subject is unclear.\n\n")
+	desc.WriteString("Do not change any other file, not even version numbers, and do not commit or tag: FORGE does that once you are done.\n\n")
+	if release.PreviousTag != "" {
+		fmt.Fprintf(&desc, "Commits since %s:\n\n", release.PreviousTag)
+	} else {
+		desc.WriteString("Commits:\n\n")
+	}
+	desc.WriteString(formatReleaseCommits(commits))
+
+	if err := h.checkWIPLimit(StatusQueued); err != nil {
+		return nil, err
+	}
+
+	task, err := h.db.CreateTask(CreateTaskRequest{
+		Title:              "Release notes for " + release.Tag,
+		Description:        desc.String(),
+		AcceptanceCriteria: fmt.Sprintf("- %s starts with a section for %s\n- The section covers the user-visible changes", changelogFile, release.Tag),
+		ProjectID:          project.ID,
+		ProjectDir:         project.Path,
+	}, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.db.AddToQueue(task.ID); err != nil {
+		return nil, err
+	}
+
+	if queued, _ := h.db.GetTask(task.ID); queued != nil {
+		task = queued
+	}
+	h.hub.BroadcastTaskUpdate(task)
+	go h.runner.TryStartNextQueued(context.Background())
+	return task, nil
+}
+
+// runRelease waits for the release notes, then commits, tags and publishes
+func (h *Handler) runRelease(project *Project, release *Release, commits []releaseCommit, req CreateReleaseRequest, config *Config) {
+	logger := componentLog("release").With("project", project.Name, "tag", release.Tag)
+
+	if err := h.waitForTask(release.TaskID, "release notes"); err != nil {
+		h.failRelease(release, err)
+		return
+	}
+
+	if data, err := os.ReadFile(filepath.Join(project.Path, changelogFile)); err == nil {
+		release.Notes = changelogSection(string(data), release.Tag)
+	}
+	if release.Notes == "" {
+		logger.Warn("No changelog section found, using the commit list")
+		release.Notes = formatReleaseCommits(commits)
+	}
+
+	if err := h.tagRelease(project, release); err != nil {
+		h.failRelease(release, err)
+		return
+	}
+	if err := h.db.UpdateTaskStatus(release.TaskID, StatusDone); err == nil {
+		if task, _ := h.db.GetTask(release.TaskID); task != nil {
+			h.hub.BroadcastTaskUpdate(task)
+		}
+	}
+
+	if req.Push {
+		if err := pushRelease(h.db, project, release.Tag); err != nil {
+			h.failRelease(release, err)
+			return
+		}
+	}
+
+	if req.GithubRelease {
+		remoteURL, _ := GetRemoteURL(project.Path)
+		endpoints := githubEndpointsFor(config, project)
+		repoFullName, err := endpoints.parseRepo(remoteURL)
+		if err != nil {
+			h.failRelease(release, fmt.Errorf("tagged and pushed, but the remote is not on GitHub"))
+			return
+		}
+		published, err := endpoints.client(config.GithubToken).CreateRelease(repoFullName, release.Tag, release.Name, release.Notes, req.Draft, req.Prerelease)
+		if err != nil {
+			h.failRelease(release, fmt.Errorf("tagged and pushed, but creating the GitHub release failed: %w", err))
+			return
+		}
+		release.GithubURL = published.HTMLURL
+	}
+
+	release.Status = ReleaseStatusPublished
+	if err := h.db.UpdateRelease(release); err != nil {
+		logger.Error("Failed to save release", "err", err)
+	}
+	h.hub.BroadcastReleaseUpdate(release)
+	logger.Info("Release published", "commit", release.CommitHash)
+}
+
+// tagRelease updates the version files, commits them with the changelog and
+// creates the annotated release tag
+func (h *Handler) tagRelease(project *Project, release *Release) error {
+	defer lockRepo(project.Path, "release tag")()
+	defer gitStatus.Invalidate(project.Path)
+
+	changed, err := updateVersionFiles(project.Path, release.Tag)
+	if err != nil {
+		return fmt.Errorf("failed to update version files: %w", err)
+	}
+	if len(changed) > 0 {
+		componentLog("release").Info("Updated version files", "tag", release.Tag, "files", changed)
+	}
+	if hasChanges, _ := HasUncommittedChanges(project.Path); hasChanges {
+		if _, err := CommitAllChanges(project.Path, WithTaskTrailer(commitMessage(project, nil, "Release "+release.Tag), release.TaskID)); err != nil {
+			return err
+		}
+	}
+
+	cmd := exec.Command("git", "tag", "-a", "--cleanup=verbatim", release.Tag, "-F", "-")
+	cmd.Dir = project.Path
+	cmd.Stdin = strings.NewReader(release.Name + "\n\n" + release.Notes)
+	if output, err := runSigned(cmd); err != nil {
+		return gitFailed("tag", err, output)
+	}
+
+	hash, err := GetCurrentCommitHash(project.Path)
+	if err != nil {
+		return err
+	}
+	release.CommitHash = hash
+	return nil
+}
+
+// pushRelease pushes the current branch, unless it is protected, and the tag
+func pushRelease(db Store, project *Project, tag string) error {
+	defer lockRepo(project.Path, "release push")()
+	defer gitStatus.Invalidate(project.Path)
+
+	if err := pushUnlessProtected(db, project.ID, project.Path); err != nil {
+		return fmt.Errorf("tagged, but pushing failed: %w", err)
+	}
+	cmd := exec.Command("git", "push", "origin", "refs/tags/"+tag)
+	cmd.Dir = project.Path
+	defer withDeployKey(cmd)()
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("tagged, but pushing the tag failed: %v, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// failRelease marks a release as failed
+func (h *Handler) failRelease(release *Release, err error) {
+	componentLog("release").Error("Release failed", "tag", release.Tag, "err", err)
+	release.Status = ReleaseStatusFailed
+	release.Error = err.Error()
+	h.db.UpdateRelease(release)
+	h.hub.BroadcastReleaseUpdate(release)
+}
+
+// releaseCommits returns the commits of the project since previous ("" = all),
+// newest first, with the titles of the tasks they were made for
+func (h *Handler) releaseCommits(path, previous string) ([]releaseCommit, error) {
+	var revs []string
+	if previous != "" {
+		revs = []string{previous + "..HEAD"}
+	}
+	logArgs := func(format string, extra ...string) []string {
+		args := append([]string{"log", "--no-merges", format}, extra...)
+		return append(append(args, revs...), "--", ".")
+	}
+
+	out, err := runGitStatusCommand(path, logArgs(gitLogFormat)...)
+	if err != nil {
+		// A repository without commits has nothing to release
+		if _, headErr := GetCurrentCommitHash(path); headErr != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Breaking changes may be declared in the body, which gitLogFormat leaves out
+	breaking := make(map[string]bool)
+	if hashes, err := runGitStatusCommand(path, logArgs("--format=%H", "-E", "--grep=^BREAKING[ -]CHANGE:")...); err == nil {
+		for _, hash := range strings.Fields(hashes) {
+			breaking[hash] = true
+		}
+	}
+
+	tasks := make(map[string]*Task)
+	var commits []releaseCommit
+	for _, entry := range parseGitLog(out) {
+		commit := releaseCommit{CommitInfo: entry.CommitInfo, Breaking: breaking[entry.Hash]}
+		for _, id := range entry.TaskIDs {
+			task, ok := tasks[id]
+			if !ok {
+				task, _ = h.db.GetTask(id)
+				tasks[id] = task
+			}
+			if task != nil {
+				commit.TaskTitles = append(commit.TaskTitles, task.Title)
+				commit.TaskTypeIDs = append(commit.TaskTypeIDs, task.TaskTypeID)
+			}
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// formatReleaseCommits lists commits as Markdown, with their tasks
+func formatReleaseCommits(commits []releaseCommit) string {
+	var sb strings.Builder
+	for _, commit := range commits {
+		fmt.Fprintf(&sb, "- %s (%s)", commit.Subject, commit.Hash[:min(7, len(commit.Hash))])
+		if len(commit.TaskTitles) > 0 {
+			fmt.Fprintf(&sb, ", task: %s", strings.Join(commit.TaskTitles, "; "))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// latestReleaseTag returns the newest tag reachable from HEAD, ignoring
+// FORGE's rollback tags; "" if there is none
+func latestReleaseTag(path string) string {
+	out, err := runGitStatusCommand(path, "describe", "--tags", "--abbrev=0", "--exclude=runner-before-*")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// checkNewTag rejects invalid tag names and tags that already exist
+func checkNewTag(path, tag string) error {
+	cmd := exec.Command("git", "check-ref-format", "refs/tags/"+tag)
+	if err := cmd.Run(); err != nil || strings.HasPrefix(tag, "-") {
+		return fmt.Errorf("invalid tag name: %s", tag)
+	}
+	if _, err := runGitStatusCommand(path, "rev-parse", "-q", "--verify", "refs/tags/"+tag); err == nil {
+		return fmt.Errorf("tag %s already exists", tag)
+	}
+	return nil
+}
+
+// changelogSection returns the body of the "## <tag>" section of a
+// changelog, "" if there is none
+func changelogSection(changelog, tag string) string {
+	var section []string
+	inSection := false
+	for _, line := range strings.Split(changelog, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			if inSection {
+				break
+			}
+			heading := strings.Fields(strings.TrimPrefix(line, "## "))
+			inSection = len(heading) > 0 && strings.Trim(heading[0], "[]") == tag
+			continue
+		}
+		if inSection {
+			section = append(section, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(section, "\n"))
+}
@@ -0,0 +1,209 @@
+// repolock.go serializes the operations that change a repository: pushes,
+// pulls, checkouts, commits, rollbacks, cherry-picks, releases and RALPH's
+// branch switches. Each repository (shared by the projects in it) has one
+// lock; an operation that finds it held waits its turn instead of running
+// into git's index.lock or a half-switched branch. Operations started from
+// the API wait at most FORGE_REPO_LOCK_TIMEOUT and then answer 409 with the
+// operation holding the lock, RALPH's own operations wait as long as it takes.
+// The project list shows a held lock as repo_lock, GET /api/repo-locks lists
+// all of them.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultRepoLockTimeout is how long API requests wait for a repository lock
+const defaultRepoLockTimeout = 2 * time.Minute
+
+// repoLockTimeout is how long API requests wait for a repository lock, 0 = no limit
+var repoLockTimeout = repoLockTimeoutFromEnv()
+
+// repoLockTimeoutFromEnv reads FORGE_REPO_LOCK_TIMEOUT (e.g. 30s; 0 waits without limit)
+func repoLockTimeoutFromEnv() time.Duration {
+	v := os.Getenv("FORGE_REPO_LOCK_TIMEOUT")
+	if v == "" {
+		return defaultRepoLockTimeout
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("git").Warn("Ignoring invalid FORGE_REPO_LOCK_TIMEOUT", "value", v)
+		return defaultRepoLockTimeout
+	}
+	return d
+}
+
+// repoLock is the lock of one repository. The buffered channel is the lock
+// itself, so waiting can be given up; mu guards the rest.
+type repoLock struct {
+	sem       chan struct{}
+	mu        sync.Mutex
+	operation string
+	since     time.Time
+	waiting   int
+}
+
+// repoLocks holds a *repoLock per repository root
+var repoLocks sync.Map
+
+// repoLockFor returns the lock of the repository containing path, nil outside a repository
+func repoLockFor(path string) (*repoLock, string) {
+	root := gitRoot(path)
+	if root == "" {
+		return nil, ""
+	}
+	value, _ := repoLocks.LoadOrStore(root, &repoLock{sem: make(chan struct{}, 1)})
+	return value.(*repoLock), root
+}
+
+// acquire waits for the lock until done is closed. Returns the unlock
+// function, or false if done was closed first.
+func (l *repoLock) acquire(root, operation string, done <-chan struct{}) (func(), bool) {
+	select {
+	case l.sem <- struct{}{}:
+	default:
+		l.mu.Lock()
+		l.waiting++
+		holder, since := l.operation, l.since
+		l.mu.Unlock()
+		componentLog("git").Info("Waiting for repository lock", "repo", root, "operation", operation, "held_by", holder, "held_for", time.Since(since).Round(time.Second))
+
+		acquired := false
+		select {
+		case l.sem <- struct{}{}:
+			acquired = true
+		case <-done:
+		}
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+		if !acquired {
+			return nil, false
+		}
+	}
+
+	l.mu.Lock()
+	l.operation = operation
+	l.since = time.Now()
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.operation = ""
+			l.since = time.Time{}
+			l.mu.Unlock()
+			<-l.sem
+		})
+	}, true
+}
+
+// status returns the held lock, nil if it is free
+func (l *repoLock) status() *RepoLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.operation == "" {
+		return nil
+	}
+	return &RepoLock{Operation: l.operation, Since: l.since, Waiting: l.waiting}
+}
+
+// lockRepo serializes changes to the repository containing path, which
+// other projects may share, waiting as long as the lock is held. operation
+// names the change for whoever waits. Call the returned function to unlock:
+//
+//	defer lockRepo(path, "push")()
+func lockRepo(path, operation string) func() {
+	lock, root := repoLockFor(path)
+	if lock == nil {
+		return func() {}
+	}
+	unlock, _ := lock.acquire(root, operation, nil)
+	return unlock
+}
+
+// repoBusyError is returned when a repository stayed locked by another operation
+type repoBusyError struct {
+	Lock RepoLock
+}
+
+func (e *repoBusyError) Error() string {
+	if e.Lock.Since.IsZero() {
+		return fmt.Sprintf("repository is busy with %s, try again later", e.Lock.Operation)
+	}
+	return fmt.Sprintf("repository is busy with %s (since %s), try again later", e.Lock.Operation, e.Lock.Since.Format("15:04:05"))
+}
+
+// lockRepoWithin is lockRepo for API requests: it gives up when ctx ends or
+// after repoLockTimeout, with a *repoBusyError naming the operation holding
+// the lock
+func lockRepoWithin(ctx context.Context, path, operation string) (func(), error) {
+	lock, root := repoLockFor(path)
+	if lock == nil {
+		return func() {}, nil
+	}
+	if repoLockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, repoLockTimeout)
+		defer cancel()
+	}
+	if unlock, ok := lock.acquire(root, operation, ctx.Done()); ok {
+		return unlock, nil
+	}
+	busy := &repoBusyError{Lock: RepoLock{Operation: "another operation"}}
+	if held := lock.status(); held != nil {
+		busy.Lock = *held
+	}
+	return nil, busy
+}
+
+// repoLockStatus returns the held lock of the repository containing path, nil if it is free
+func repoLockStatus(path string) *RepoLock {
+	root := gitRoot(path)
+	if root == "" {
+		return nil
+	}
+	value, ok := repoLocks.Load(root)
+	if !ok {
+		return nil
+	}
+	status := value.(*repoLock).status()
+	if status != nil {
+		status.Repo = root
+	}
+	return status
+}
+
+// writeLockError answers a failed lockRepoWithin: 409 if the repository
+// stayed busy, else 500 with the message
+func (h *Handler) writeLockError(w http.ResponseWriter, err error) {
+	var busy *repoBusyError
+	if errors.As(err, &busy) {
+		h.writeError(w, http.StatusConflict, busy.Error())
+		return
+	}
+	h.writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// HandleRepoLocks handles GET /api/repo-locks
+// Lists the repositories whose lock is held, with the operation and how many wait.
+func (h *Handler) HandleRepoLocks(w http.ResponseWriter, r *http.Request) {
+	locks := []RepoLock{}
+	repoLocks.Range(func(key, value interface{}) bool {
+		if status := value.(*repoLock).status(); status != nil {
+			status.Repo = key.(string)
+			locks = append(locks, *status)
+		}
+		return true
+	})
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Since.Before(locks[j].Since) })
+	h.writeJSON(w, http.StatusOK, locks)
+}
@@ -0,0 +1,370 @@
+// report.go turns a task and its run into a self-contained report for
+// people without access to the board. GET /api/tasks/{id}/report collects
+// the description, acceptance criteria, the timeline of status changes and
+// iterations, the changed files, the commits, Claude's token usage and the
+// outcome into one Markdown document, or a standalone HTML page with
+// ?format=html.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// reportDiffLimit caps the diff summary of a report
+const reportDiffLimit = 20000
+
+// reportIterationPattern finds iteration markers and their summaries in Claude's messages
+var reportIterationPattern = regexp.MustCompile(`\[ITERATION\s+(\d+)\]\s*:?\s*(.*)`)
+
+// RunUsage is the token usage of all of a task's Claude runs, summed up from
+// the result events in its logs
+type RunUsage struct {
+	Runs                int     `json:"runs"`
+	Turns               int     `json:"turns"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_input_tokens"`
+	CostUSD             float64 `json:"total_cost_usd"`
+}
+
+// reportIteration is an iteration with the summary Claude gave when starting it
+type reportIteration struct {
+	Number  int
+	Summary string
+}
+
+// runUsageFromLogs sums up the usage of the result events in stream-json logs
+func runUsageFromLogs(logs string) RunUsage {
+	var usage RunUsage
+	for _, line := range strings.Split(logs, "\n") {
+		if !isResultEvent(line) {
+			continue
+		}
+		var event struct {
+			NumTurns     int     `json:"num_turns"`
+			TotalCostUSD float64 `json:"total_cost_usd"`
+			Usage        struct {
+				InputTokens              int64 `json:"input_tokens"`
+				OutputTokens             int64 `json:"output_tokens"`
+				CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+				CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+			} `json:"usage"`
+		}
+		if json.Unmarshal([]byte(line), &event) != nil {
+			continue
+		}
+		usage.Runs++
+		usage.Turns += event.NumTurns
+		usage.InputTokens += event.Usage.InputTokens
+		usage.OutputTokens += event.Usage.OutputTokens
+		usage.CacheCreationTokens += event.Usage.CacheCreationInputTokens
+		usage.CacheReadTokens += event.Usage.CacheReadInputTokens
+		usage.CostUSD += event.TotalCostUSD
+	}
+	return usage
+}
+
+// iterationsFromLogs returns the iterations Claude announced in its messages.
+// A restarted iteration keeps the summary of its last start.
+func iterationsFromLogs(logs string) []reportIteration {
+	var iterations []reportIteration
+	index := make(map[int]int)
+	for _, line := range strings.Split(logs, "\n") {
+		if !strings.HasPrefix(line, "{") || !strings.Contains(line, "[ITERATION") {
+			continue
+		}
+		var event struct {
+			Type    string `json:"type"`
+			Message struct {
+				Content []struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"message"`
+		}
+		if json.Unmarshal([]byte(line), &event) != nil || event.Type != "assistant" {
+			continue
+		}
+		for _, c := range event.Message.Content {
+			if c.Type != "text" {
+				continue
+			}
+			for _, m := range reportIterationPattern.FindAllStringSubmatch(c.Text, -1) {
+				var n int
+				fmt.Sscanf(m[1], "%d", &n)
+				it := reportIteration{Number: n, Summary: strings.TrimSpace(m[2])}
+				if i, ok := index[n]; ok {
+					iterations[i] = it
+				} else {
+					index[n] = len(iterations)
+					iterations = append(iterations, it)
+				}
+			}
+		}
+	}
+	return iterations
+}
+
+// reportDiffStat summarizes the task's changes: against its rollback tag if
+// it has one, else over its commits
+func reportDiffStat(dir string, task *Task, commits []TaskCommit) string {
+	if dir == "" || !IsGitRepository(dir) {
+		return ""
+	}
+	if task.RollbackTag != "" {
+		if stat, err := DiffStatSince(dir, task.RollbackTag); err == nil {
+			return stat
+		}
+	}
+	if len(commits) == 0 {
+		return ""
+	}
+	// Commits are newest first; the diff runs from the parent of the oldest
+	oldest, newest := commits[len(commits)-1].Hash, commits[0].Hash
+	stat, err := runGitStatusCommand(dir, "diff", "--stat", "--relative", oldest+"^", newest)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(stat, "\n")
+}
+
+// formatReportTime renders a timestamp of the report
+func formatReportTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// BuildTaskReport renders the Markdown report of a task
+func (h *Handler) BuildTaskReport(task *Task) string {
+	var sb strings.Builder
+	columnName := func(status TaskStatus) string {
+		if column, _ := h.db.GetBoardColumn(status); column != nil {
+			return column.Name
+		}
+		return string(status)
+	}
+
+	fmt.Fprintf(&sb, "# %s\n\n", task.Title)
+
+	// Overview
+	fmt.Fprintf(&sb, "- **Status:** %s\n", columnName(task.Status))
+	dir := task.ProjectDir
+	if task.ProjectID != "" {
+		if project, _ := h.db.GetProject(task.ProjectID); project != nil {
+			fmt.Fprintf(&sb, "- **Project:** %s\n", project.Name)
+			dir = project.Path
+			if err := commitIndex.IndexProject(h.db, project); err != nil {
+				componentLog("commitindex").Warn("Failed to index project", "path", project.Path, "err", err)
+			}
+		}
+	}
+	if task.TaskTypeID != "" {
+		if taskType, _ := h.db.GetTaskType(task.TaskTypeID); taskType != nil {
+			fmt.Fprintf(&sb, "- **Type:** %s\n", taskType.Name)
+		}
+	}
+	fmt.Fprintf(&sb, "- **Priority:** %d\n", task.Priority)
+	if len(task.Labels) > 0 {
+		names := make([]string, 0, len(task.Labels))
+		for _, l := range task.Labels {
+			names = append(names, l.Name)
+		}
+		fmt.Fprintf(&sb, "- **Labels:** %s\n", strings.Join(names, ", "))
+	}
+	if branch := task.WorkingBranch; branch != "" {
+		fmt.Fprintf(&sb, "- **Branch:** `%s`\n", branch)
+	}
+	if task.PRURL != "" {
+		fmt.Fprintf(&sb, "- **Pull request:** [#%d](%s)\n", task.PRNumber, task.PRURL)
+	}
+	fmt.Fprintf(&sb, "- **Iterations:** %d of %d\n", task.CurrentIteration, task.MaxIterations)
+	fmt.Fprintf(&sb, "- **Created:** %s\n", formatReportTime(task.CreatedAt))
+	if task.StartedAt != nil {
+		fmt.Fprintf(&sb, "- **Started:** %s\n", formatReportTime(*task.StartedAt))
+	}
+	if task.FinishedAt != nil {
+		fmt.Fprintf(&sb, "- **Finished:** %s\n", formatReportTime(*task.FinishedAt))
+		if task.StartedAt != nil {
+			fmt.Fprintf(&sb, "- **Run time:** %s\n", task.FinishedAt.Sub(*task.StartedAt).Round(time.Second))
+		}
+	}
+	sb.WriteString("\n")
+
+	if task.Description != "" {
+		sb.WriteString("## Description\n\n")
+		sb.WriteString(strings.TrimSpace(task.Description))
+		sb.WriteString("\n\n")
+	}
+	if task.AcceptanceCriteria != "" {
+		sb.WriteString("## Acceptance Criteria\n\n")
+		sb.WriteString(strings.TrimSpace(task.AcceptanceCriteria))
+		sb.WriteString("\n\n")
+	}
+	if task.PlanStatus == PlanStatusApproved && strings.TrimSpace(task.Plan) != "" {
+		sb.WriteString("## Approved Plan\n\n")
+		sb.WriteString(strings.TrimSpace(task.Plan))
+		sb.WriteString("\n\n")
+	}
+
+	// Timeline: status changes, then the iterations of the run
+	if events, _ := h.db.GetTaskEvents(task.ID); len(events) > 0 {
+		sb.WriteString("## Timeline\n\n")
+		for i, event := range events {
+			line := fmt.Sprintf("- %s: %s", formatReportTime(event.CreatedAt), columnName(event.Status))
+			if i+1 < len(events) {
+				line += fmt.Sprintf(" (%s)", events[i+1].CreatedAt.Sub(event.CreatedAt).Round(time.Minute))
+			}
+			sb.WriteString(line + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	if iterations := iterationsFromLogs(task.Logs); len(iterations) > 0 {
+		sb.WriteString("## Iterations\n\n")
+		for _, it := range iterations {
+			if it.Summary != "" {
+				fmt.Fprintf(&sb, "%d. %s\n", it.Number, it.Summary)
+			} else {
+				fmt.Fprintf(&sb, "%d. -\n", it.Number)
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	// Changes
+	commits, _ := h.db.GetTaskCommits(task.ID)
+	if stat := reportDiffStat(dir, task, commits); stat != "" {
+		sb.WriteString("## Changes\n\n```\n")
+		sb.WriteString(truncateText(stat, reportDiffLimit))
+		sb.WriteString("\n```\n\n")
+	}
+	if len(commits) > 0 {
+		sb.WriteString("## Commits\n\n")
+		for _, c := range commits {
+			fmt.Fprintf(&sb, "- `%s` %s (%s, %s)\n", shortHash(c.Hash), c.Subject, c.Author, formatReportTime(c.Date))
+		}
+		sb.WriteString("\n")
+	}
+
+	attachments, _ := h.db.GetAttachmentsByTask(task.ID)
+	var screenshots []Attachment
+	for _, att := range attachments {
+		if isScreenshot(att) {
+			screenshots = append(screenshots, att)
+		}
+	}
+	if len(screenshots) > 0 {
+		sb.WriteString("## Screenshots\n\n")
+		for _, att := range screenshots {
+			fmt.Fprintf(&sb, "![%s](%s%s)\n\n", att.Filename, attachmentScheme, att.ID)
+		}
+	}
+
+	if usage := runUsageFromLogs(task.Logs); usage.Runs > 0 {
+		sb.WriteString("## Token Usage\n\n")
+		fmt.Fprintf(&sb, "- **Claude runs:** %d\n", usage.Runs)
+		fmt.Fprintf(&sb, "- **Turns:** %d\n", usage.Turns)
+		fmt.Fprintf(&sb, "- **Input tokens:** %d\n", usage.InputTokens)
+		fmt.Fprintf(&sb, "- **Output tokens:** %d\n", usage.OutputTokens)
+		fmt.Fprintf(&sb, "- **Cache write tokens:** %d\n", usage.CacheCreationTokens)
+		fmt.Fprintf(&sb, "- **Cache read tokens:** %d\n", usage.CacheReadTokens)
+		if usage.CostUSD > 0 {
+			fmt.Fprintf(&sb, "- **Cost:** $%.2f\n", usage.CostUSD)
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("## Outcome\n\n")
+	fmt.Fprintf(&sb, "**%s**", columnName(task.Status))
+	if task.CommitHash != "" {
+		fmt.Fprintf(&sb, " at commit `%s`", shortHash(task.CommitHash))
+	}
+	sb.WriteString("\n\n")
+	if task.Error != "" {
+		fmt.Fprintf(&sb, "Error: %s\n\n", task.Error)
+	}
+	last := reportIterationPattern.ReplaceAllString(lastRalphMessage(task.Logs), "")
+	if last = strings.TrimSpace(strings.ReplaceAll(last, "[SUCCESS]", "")); last != "" {
+		sb.WriteString("Final message from RALPH:\n\n")
+		for _, line := range strings.Split(last, "\n") {
+			sb.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	fmt.Fprintf(&sb, "---\n\n*Report generated by FORGE on %s*\n", formatReportTime(time.Now()))
+	return sb.String()
+}
+
+// taskReportHTML wraps the rendered report in a standalone page
+func taskReportHTML(title, body string) string {
+	return `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>` + html.EscapeString(title) + `</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 860px; margin: 2rem auto; padding: 0 1rem; color: #1f2328; line-height: 1.5; }
+h1 { border-bottom: 1px solid #d0d7de; padding-bottom: .3rem; }
+h2 { margin-top: 2rem; border-bottom: 1px solid #d0d7de; padding-bottom: .2rem; }
+code, pre { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: .85em; background: #f6f8fa; border-radius: 4px; }
+code { padding: .1rem .3rem; }
+pre { padding: .8rem; overflow-x: auto; }
+pre code { padding: 0; background: none; }
+blockquote { margin: 0; padding: 0 1rem; color: #59636e; border-left: 4px solid #d0d7de; }
+</style>
+</head>
+<body>
+` + body + `
+</body>
+</html>
+`
+}
+
+// HandleTaskReport handles GET /api/tasks/{id}/report
+// Returns the report as Markdown, or as a standalone HTML page with
+// ?format=html. ?download=true serves it as a file.
+func (h *Handler) HandleTaskReport(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "markdown"
+	}
+	if format != "markdown" && format != "html" {
+		h.writeError(w, http.StatusBadRequest, "Invalid format. Allowed: markdown, html")
+		return
+	}
+
+	report := h.BuildTaskReport(task)
+	body, contentType, ext := report, "text/markdown; charset=utf-8", "md"
+	if format == "html" {
+		renderer, err := h.markdownRendererFor(task.ID, r.URL.Query().Get("base_url"))
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get attachments: "+err.Error())
+			return
+		}
+		body, contentType, ext = taskReportHTML(task.Title, renderer.Render(report)), "text/html; charset=utf-8", "html"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if r.URL.Query().Get("download") == "true" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"forge-task-%s.%s\"", shortID(task.ID), ext))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(body))
+}
@@ -0,0 +1,232 @@
+// retry.go recovers from runs that failed without a marker, see failures.go
+// for how a failure is classified. Such a run is recorded as failed in the
+// run history, and instead of ending up blocked the task is queued again as
+// a continuation of the failed run, after a backoff that doubles with every
+// consecutive failure (Config.RetryPolicy). Runs out of context are retried
+// right away in a new conversation, a Claude that is not logged in blocks the
+// task at once, and a rate limit makes the whole queue cool down instead (see
+// cooldown.go). The queue holds the task until its retry is due. Once the
+// retries are used up, the task is blocked.
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// defaultRetryBackoff is the wait before the first retry without a configured backoff
+const defaultRetryBackoff = time.Minute
+
+// maxRetryBackoff caps the doubling backoff
+const maxRetryBackoff = time.Hour
+
+// maxRetries is the most retries the policy may allow
+const maxRetries = 10
+
+// RetryPolicy configures retries after transient failures, stored as a JSON object
+type RetryPolicy struct {
+	MaxRetries     int `json:"max_retries,omitempty"`     // Consecutive retries per task, 0 = block right away
+	BackoffSeconds int `json:"backoff_seconds,omitempty"` // Wait before the first retry, doubled for every further one
+}
+
+// Value stores the policy as JSON, no retries as an empty string
+func (p RetryPolicy) Value() (driver.Value, error) {
+	if p.MaxRetries == 0 && p.BackoffSeconds == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads a policy stored by Value
+func (p *RetryPolicy) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into RetryPolicy", src)
+	}
+	*p = RetryPolicy{}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, p)
+}
+
+// validate rejects negative values and more retries than maxRetries
+func (p RetryPolicy) validate() error {
+	if p.MaxRetries < 0 || p.BackoffSeconds < 0 {
+		return fmt.Errorf("retry policy values must not be negative")
+	}
+	if p.MaxRetries > maxRetries {
+		return fmt.Errorf("at most %d retries are allowed", maxRetries)
+	}
+	return nil
+}
+
+// backoff returns the wait before the n-th consecutive retry (1-based)
+func (p RetryPolicy) backoff(n int) time.Duration {
+	delay := time.Duration(p.BackoffSeconds) * time.Second
+	if delay == 0 {
+		delay = defaultRetryBackoff
+	}
+	for i := 1; i < n && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryBackoff)
+}
+
+// consecutiveFailures counts the failed runs at the end of a task's history;
+// rate-limited runs are not the task's fault and do not count
+func consecutiveFailures(runs []TaskRun) int {
+	n := 0
+	for i := len(runs) - 1; i >= 0 && runs[i].Outcome == RunFailed; i-- {
+		if runs[i].FailureClass != FailureRateLimit {
+			n++
+		}
+	}
+	return n
+}
+
+// retryDelay returns the wait before the n-th consecutive retry of a run that
+// failed with class. A run out of context is retried right away, since the
+// retry starts a new conversation.
+func retryDelay(policy RetryPolicy, failure runFailure, n int) time.Duration {
+	if failure.Class == FailureContextLimit {
+		return 0
+	}
+	return policy.backoff(n)
+}
+
+// retryMessage is the feedback a retry continues the task with
+func retryMessage(failure runFailure) string {
+	if failure.Class == FailureContextLimit {
+		return fmt.Sprintf("The previous run ran out of context: %s\n\n"+
+			"This is a new conversation. Check the current state of the code and continue the task where you left off. "+
+			"Keep your context small: read only the parts of files you need and avoid large command output.", failure.Reason)
+	}
+	return fmt.Sprintf("The previous run stopped because of an infrastructure failure, not because of your work: %s\n\n"+
+		"Check the current state of the code and continue the task where you left off.", failure.Reason)
+}
+
+// recoverFailedRun handles a task whose run has just failed (see
+// failures.go): a task that is not logged in is blocked right away, a
+// rate-limited one waits for the queue's cooldown, any other is queued again,
+// or blocked when its retries are used up. Returns false if the run did not
+// fail.
+func (r *RalphRunner) recoverFailedRun(proc *RalphProcess) bool {
+	proc.mu.Lock()
+	run := proc.run
+	proc.mu.Unlock()
+	if run == nil || run.Outcome != RunFailed {
+		return false
+	}
+	task, err := r.db.GetTask(run.TaskID)
+	if err != nil || task == nil || statusRole(r.db, task.Status) != ColumnRoleProgress {
+		return false
+	}
+	failure := runFailure{Class: run.FailureClass, Reason: run.Error}
+
+	// Retrying does not log Claude in again
+	if failure.Class == FailureAuth {
+		proc.log.Warn("Run failed, Claude is not authenticated", "reason", failure.Reason)
+		r.blockTask(task.ID, failureError(failure.Class, failure.Reason), failure.Class)
+		return true
+	}
+
+	// A rate limit holds up the whole queue, not just this task; the task
+	// itself is due right away, so it starts as soon as the cooldown ends
+	if failure.Class == FailureRateLimit {
+		until, err := r.startCooldown(failure)
+		if err != nil {
+			proc.log.Error("Failed to start cooldown", "err", err)
+			return false
+		}
+		proc.log.Warn("Run failed, rate limited", "reason", failure.Reason, "until", until)
+		return r.queueRetry(proc, task, failure, time.Now(), fmt.Sprintf("Continuing when the queue's cooldown ends at %s", until.Format("15:04")))
+	}
+
+	config, err := r.db.GetConfig()
+	if err != nil {
+		proc.log.Error("Failed to load config for the retry policy", "err", err)
+		return false
+	}
+	policy := config.RetryPolicy
+	runs, err := r.db.GetTaskRuns(task.ID)
+	if err != nil {
+		proc.log.Error("Failed to count failed runs", "err", err)
+		return false
+	}
+
+	failures := consecutiveFailures(runs)
+	if failures > policy.MaxRetries {
+		reason := failureError(failure.Class, failure.Reason)
+		if policy.MaxRetries > 0 {
+			reason += fmt.Sprintf(" (gave up after %d retries)", policy.MaxRetries)
+		}
+		proc.log.Warn("Run failed, no retries left", "class", failure.Class, "reason", failure.Reason, "failures", failures)
+		r.blockTask(task.ID, reason, failure.Class)
+		return true
+	}
+
+	delay := retryDelay(policy, failure, failures)
+	proc.log.Warn("Run failed, retrying", "class", failure.Class, "reason", failure.Reason,
+		"retry", failures, "max_retries", policy.MaxRetries, "delay", delay)
+	when := "now"
+	if delay > 0 {
+		when = "in " + delay.Round(time.Second).String()
+	}
+	return r.queueRetry(proc, task, failure, time.Now().Add(delay),
+		fmt.Sprintf("Retrying %s (retry %d of %d)", when, failures, policy.MaxRetries))
+}
+
+// queueRetry queues a task again to continue after its failed run at
+// retryAt, and tells the user with note
+func (r *RalphRunner) queueRetry(proc *RalphProcess, task *Task, failure runFailure, retryAt time.Time, note string) bool {
+	if err := r.db.QueueTaskRetry(task.ID, retryMessage(failure), retryAt); err != nil {
+		proc.log.Error("Failed to queue retry", "err", err)
+		return false
+	}
+	msg := fmt.Sprintf("\n[FORGE] %s\n[FORGE] %s\n", failureError(failure.Class, failure.Reason), note)
+	r.db.AppendTaskLogs(task.ID, msg)
+	r.hub.BroadcastLog(task.ID, msg)
+	if updated, _ := r.db.GetTask(task.ID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+	if retryAt.After(time.Now()) {
+		r.wakeQueueAt(retryAt)
+	}
+	return true
+}
+
+// retryPending reports whether a queued task still waits for its retry backoff
+func retryPending(task *Task, now time.Time) bool {
+	return task.RetryAt != nil && task.RetryAt.After(now)
+}
+
+// wakeQueueAt makes the dispatcher look at the queue again at t, when a
+// retry is due. Only the earliest pending wake-up is kept.
+func (r *RalphRunner) wakeQueueAt(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.retryWake != nil && !r.retryWakeAt.After(t) && r.retryWakeAt.After(time.Now()) {
+		return
+	}
+	if r.retryWake != nil {
+		r.retryWake.Stop()
+	}
+	r.retryWakeAt = t
+	r.retryWake = time.AfterFunc(time.Until(t), func() {
+		r.TryStartNextQueued(context.Background())
+	})
+}
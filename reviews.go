@@ -0,0 +1,257 @@
+// reviews.go hands review comments on a task's pull request to RALPH. While
+// a task is in review, the pull request sync (see prsync.go) also counts the
+// reviews and diff comments that came in since the task last addressed its
+// review, and shows the count on the task; a GitHub webhook for
+// pull_request_review and pull_request_review_comment events triggers the
+// sync right away. GET /api/tasks/{id}/review-comments lists the new comments
+// with the continue message they make, POST /api/tasks/{id}/address-review
+// queues the task with it. Comments by bots and approvals without text are
+// left out.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// reviewMessageLimit caps the continue message built from review comments in bytes
+const reviewMessageLimit = 20000
+
+// newReviewComments returns the reviews and diff comments on the task's pull
+// request since the task last addressed its review, oldest first
+func newReviewComments(client *GitHubClient, repo string, task *Task) ([]PRReviewComment, error) {
+	var since time.Time
+	if task.ReviewAddressedAt != nil {
+		since = *task.ReviewAddressedAt
+	}
+
+	reviews, err := client.ListPullRequestReviews(repo, task.PRNumber)
+	if err != nil {
+		return nil, err
+	}
+	diffComments, err := client.ListPullRequestComments(repo, task.PRNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := []PRReviewComment{}
+	for _, review := range reviews {
+		if review.State == "PENDING" || review.User.Type == "Bot" || strings.TrimSpace(review.Body) == "" || !review.SubmittedAt.After(since) {
+			continue
+		}
+		comments = append(comments, PRReviewComment{
+			Author:    review.User.Login,
+			Body:      strings.TrimSpace(review.Body),
+			State:     review.State,
+			URL:       review.HTMLURL,
+			CreatedAt: review.SubmittedAt,
+		})
+	}
+	for _, comment := range diffComments {
+		if comment.User.Type == "Bot" || !comment.CreatedAt.After(since) {
+			continue
+		}
+		line := comment.Line
+		if line == 0 {
+			line = comment.OriginalLine
+		}
+		comments = append(comments, PRReviewComment{
+			Author:    comment.User.Login,
+			Body:      strings.TrimSpace(comment.Body),
+			Path:      comment.Path,
+			Line:      line,
+			URL:       comment.HTMLURL,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+	})
+	return comments, nil
+}
+
+// reviewMessage is the continue message that asks RALPH to address comments
+func reviewMessage(task *Task, comments []PRReviewComment) string {
+	if len(comments) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Your pull request #%d received review comments. Address each of them: change the code where the reviewer asks for it, and where you disagree, say why in your summary instead.\n\n", task.PRNumber)
+	for _, c := range comments {
+		switch {
+		case c.Path != "" && c.Line > 0:
+			fmt.Fprintf(&sb, "## %s:%d (@%s)\n\n", c.Path, c.Line, c.Author)
+		case c.Path != "":
+			fmt.Fprintf(&sb, "## %s (@%s)\n\n", c.Path, c.Author)
+		default:
+			fmt.Fprintf(&sb, "## Review by @%s (%s)\n\n", c.Author, strings.ToLower(strings.ReplaceAll(c.State, "_", " ")))
+		}
+		for _, line := range strings.Split(c.Body, "\n") {
+			sb.WriteString("> " + line + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	return truncateText(strings.TrimSpace(sb.String()), reviewMessageLimit)
+}
+
+// syncTaskReviews stores how many new review comments the task's open pull
+// request has and tells the board when that changed
+func (r *RalphRunner) syncTaskReviews(client *GitHubClient, repo string, task *Task) {
+	comments, err := newReviewComments(client, repo, task)
+	if err != nil {
+		componentLog("prsync").Warn("Failed to get review comments", "task_id", task.ID, "pr", task.PRNumber, "err", err)
+		return
+	}
+	if len(comments) == task.ReviewComments {
+		return
+	}
+	if err := r.db.UpdateTaskReviewComments(task.ID, len(comments)); err != nil {
+		componentLog("prsync").Error("Failed to save review comments", "task_id", task.ID, "err", err)
+		return
+	}
+	if len(comments) > task.ReviewComments {
+		msg := fmt.Sprintf("[FORGE] Pull request #%d has %d new review comment(s)\n", task.PRNumber, len(comments))
+		r.db.AppendTaskLogs(task.ID, msg)
+		r.hub.BroadcastLog(task.ID, msg)
+	}
+	if updated, _ := r.db.GetTask(task.ID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+}
+
+// taskReviewComments looks up the new review comments of the task in the
+// request path, or answers with an error
+func (h *Handler) taskReviewComments(w http.ResponseWriter, r *http.Request) (*Task, []PRReviewComment, bool) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return nil, nil, false
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return nil, nil, false
+	}
+	if task.PRNumber == 0 {
+		h.writeError(w, http.StatusBadRequest, "Task has no pull request")
+		return nil, nil, false
+	}
+
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return nil, nil, false
+	}
+	token := githubTokenFor(r, config)
+	if token == "" {
+		h.writeError(w, http.StatusBadRequest, "GitHub token not configured")
+		return nil, nil, false
+	}
+	project, _ := h.db.GetProject(task.ProjectID)
+	if project == nil {
+		h.writeError(w, http.StatusBadRequest, "Task has no project")
+		return nil, nil, false
+	}
+	repo, err := projectGitHubRepo(config, project)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Project is not on GitHub: "+err.Error())
+		return nil, nil, false
+	}
+
+	comments, err := newReviewComments(githubEndpointsFor(config, project).client(token), repo, task)
+	if err != nil {
+		h.writeError(w, http.StatusBadGateway, "Failed to get review comments: "+err.Error())
+		return nil, nil, false
+	}
+	return task, comments, true
+}
+
+// HandleTaskReviewComments handles GET /api/tasks/{id}/review-comments
+// Returns the review comments the task has not addressed yet and the continue message they make.
+func (h *Handler) HandleTaskReviewComments(w http.ResponseWriter, r *http.Request) {
+	task, comments, ok := h.taskReviewComments(w, r)
+	if !ok {
+		return
+	}
+	h.writeJSON(w, http.StatusOK, PRReviewFeedback{
+		Comments: comments,
+		Message:  reviewMessage(task, comments),
+	})
+}
+
+// HandleAddressReview handles POST /api/tasks/{id}/address-review
+// Queues a task in review or blocked with its new review comments as continue message.
+func (h *Handler) HandleAddressReview(w http.ResponseWriter, r *http.Request) {
+	task, comments, ok := h.taskReviewComments(w, r)
+	if !ok {
+		return
+	}
+	if task.Status != StatusReview && task.Status != StatusBlocked {
+		h.writeError(w, http.StatusBadRequest, "Task must be in review or blocked status to continue")
+		return
+	}
+	if len(comments) == 0 {
+		h.writeError(w, http.StatusBadRequest, "No new review comments")
+		return
+	}
+	if err := h.checkWIPLimit(StatusQueued); err != nil {
+		h.writeWIPError(w, err)
+		return
+	}
+
+	if err := h.db.AddToQueueWithMessage(task.ID, reviewMessage(task, comments)); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to add task to queue: "+err.Error())
+		return
+	}
+	// Comments made while RALPH works count as new next time
+	if err := h.db.MarkTaskReviewAddressed(task.ID, comments[len(comments)-1].CreatedAt); err != nil {
+		logFrom(r.Context()).Error("Failed to save addressed review", "task_id", task.ID, "err", err)
+	}
+	msg := fmt.Sprintf("[FORGE] Addressing %d review comment(s) on pull request #%d\n", len(comments), task.PRNumber)
+	h.db.AppendTaskLogs(task.ID, msg)
+	h.hub.BroadcastLog(task.ID, msg)
+
+	updatedTask, err := h.db.GetTask(task.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get updated task: "+err.Error())
+		return
+	}
+	h.hub.BroadcastTaskUpdate(updatedTask)
+	go h.runner.TryStartNextQueued(r.Context())
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         "queued",
+		"queue_position": updatedTask.QueuePosition,
+		"comments":       len(comments),
+	})
+}
+
+// reviewWebhookEvents are the webhook events that mean a pull request got reviewed
+var reviewWebhookEvents = map[string]bool{
+	"pull_request_review":         true,
+	"pull_request_review_comment": true,
+}
+
+// handleReviewWebhook syncs the pull requests of tasks in review after a
+// review webhook event
+func (h *Handler) handleReviewWebhook(w http.ResponseWriter, body []byte) {
+	var payload struct {
+		PullRequest *struct {
+			Number int `json:"number"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	if payload.PullRequest == nil || payload.PullRequest.Number == 0 {
+		h.writeError(w, http.StatusBadRequest, "Event has no pull request")
+		return
+	}
+
+	go h.runner.SyncTaskPRs()
+	h.writeJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+}
@@ -0,0 +1,79 @@
+// router.go maps API requests to their handlers. Routes use the patterns of
+// net/http: the methods and a path with {parameters}, which handlers read with
+// r.PathValue. Requests no route matches get the API's JSON errors, 404 or
+// 405 with an Allow header, instead of the plain text of http.ServeMux.
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// router is the http.Handler for /api/
+type router struct {
+	mux *http.ServeMux
+	h   *Handler
+}
+
+// newRouter creates an empty router that writes its errors through h
+func newRouter(h *Handler) *router {
+	return &router{mux: http.NewServeMux(), h: h}
+}
+
+// handle registers handler for pattern under each of the space separated
+// methods (GET also answers HEAD). The middleware wraps only this route, the
+// first one outermost.
+func (rt *router) handle(methods, pattern string, handler http.HandlerFunc, middleware ...func(http.Handler) http.Handler) {
+	var next http.Handler = handler
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i](next)
+	}
+	for _, method := range strings.Fields(methods) {
+		rt.mux.Handle(method+" "+pattern, next)
+	}
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern == "" {
+		rt.notRouted(w, r)
+		return
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// notRouted answers a request without a route: 405 if the path has routes
+// for other methods, 404 otherwise
+func (rt *router) notRouted(w http.ResponseWriter, r *http.Request) {
+	// ServeMux only tells which of the two through the response of its
+	// fallback handler, so that response is recorded and translated
+	fallback, _ := rt.mux.Handler(r)
+	probe := &routeProbe{header: make(http.Header), status: http.StatusOK}
+	fallback.ServeHTTP(probe, r)
+
+	if probe.status == http.StatusMethodNotAllowed {
+		w.Header().Set("Allow", probe.header.Get("Allow"))
+		rt.h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	rt.h.writeError(w, http.StatusNotFound, "Not found: "+r.Method+" "+r.URL.Path)
+}
+
+// routeProbe records the status and headers of ServeMux's fallback response
+type routeProbe struct {
+	header http.Header
+	status int
+}
+
+func (p *routeProbe) Header() http.Header         { return p.header }
+func (p *routeProbe) Write(b []byte) (int, error) { return len(b), nil }
+func (p *routeProbe) WriteHeader(status int)      { p.status = status }
+
+// limitBody is route middleware that caps the request body at n bytes
+func limitBody(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// runcompare.go compares two runs of a task (see taskruns.go), e.g. to see
+// whether running it again with a different prompt or model did better: the
+// files each run changed, which of them both touched, and the differences in
+// iterations, tokens, cost, duration and outcome. A run's files are the diff
+// between the commit it started from and the one it ended on, so changes it
+// left uncommitted are not included.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runFileChanges returns the files changed between the start and end commit of a run
+func runFileChanges(dir string, run *TaskRun) ([]RunFileChange, error) {
+	if run.StartCommit == "" || run.EndCommit == "" {
+		return nil, fmt.Errorf("the run has no recorded commit range")
+	}
+	if dir == "" || !IsGitRepository(dir) {
+		return nil, fmt.Errorf("the project is not a git repository")
+	}
+	if run.StartCommit == run.EndCommit {
+		return []RunFileChange{}, nil
+	}
+	output, err := runGitStatusCommand(dir, "diff", "--numstat", run.StartCommit, run.EndCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []RunFileChange{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		change := RunFileChange{Path: fields[2]}
+		if fields[0] == "-" {
+			change.Binary = true
+		} else {
+			change.Additions, _ = strconv.Atoi(fields[0])
+			change.Deletions, _ = strconv.Atoi(fields[1])
+		}
+		files = append(files, change)
+	}
+	return files, nil
+}
+
+// compareRun adds the changed files and duration to a run
+func compareRun(dir string, run TaskRun, now time.Time) ComparedRun {
+	compared := ComparedRun{TaskRun: run, Files: []RunFileChange{}}
+	end := now
+	if run.FinishedAt != nil {
+		end = *run.FinishedAt
+	}
+	compared.DurationSeconds = int64(max(end.Sub(run.StartedAt), 0) / time.Second)
+	files, err := runFileChanges(dir, &run)
+	if err != nil {
+		compared.FilesError = err.Error()
+	} else {
+		compared.Files = files
+	}
+	return compared
+}
+
+// outcomeRank orders outcomes by how well the run went; 0 = says nothing
+// about it, e.g. stopped by the user
+func outcomeRank(outcome string) int {
+	switch outcome {
+	case RunSuccess, RunPlanned:
+		return 2
+	case RunBlocked, RunIterationLimit, RunExited, RunFailed:
+		return 1
+	}
+	return 0
+}
+
+// compareRuns compares run b with run a
+func compareRuns(dir string, a, b TaskRun, now time.Time) *RunComparison {
+	cmp := &RunComparison{
+		A:            compareRun(dir, a, now),
+		B:            compareRun(dir, b, now),
+		FilesOnlyInA: []string{},
+		FilesOnlyInB: []string{},
+		FilesInBoth:  []string{},
+	}
+
+	inA := make(map[string]bool)
+	for _, f := range cmp.A.Files {
+		inA[f.Path] = true
+	}
+	for _, f := range cmp.B.Files {
+		if inA[f.Path] {
+			cmp.FilesInBoth = append(cmp.FilesInBoth, f.Path)
+			delete(inA, f.Path)
+		} else {
+			cmp.FilesOnlyInB = append(cmp.FilesOnlyInB, f.Path)
+		}
+	}
+	for path := range inA {
+		cmp.FilesOnlyInA = append(cmp.FilesOnlyInA, path)
+	}
+	sort.Strings(cmp.FilesOnlyInA)
+	sort.Strings(cmp.FilesOnlyInB)
+	sort.Strings(cmp.FilesInBoth)
+
+	cmp.IterationsDelta = b.Iterations - a.Iterations
+	cmp.TokensDelta = (b.InputTokens + b.OutputTokens) - (a.InputTokens + a.OutputTokens)
+	cmp.CostDeltaUSD = b.CostUSD - a.CostUSD
+	cmp.DurationDeltaSeconds = cmp.B.DurationSeconds - cmp.A.DurationSeconds
+
+	rankA, rankB := outcomeRank(a.Outcome), outcomeRank(b.Outcome)
+	switch {
+	case rankA == 0 || rankB == 0:
+		cmp.OutcomeChange = "unknown"
+	case rankB > rankA:
+		cmp.OutcomeChange = "better"
+	case rankB < rankA:
+		cmp.OutcomeChange = "worse"
+	default:
+		cmp.OutcomeChange = "same"
+	}
+	return cmp
+}
+
+// HandleTaskRunCompare handles GET /api/tasks/{id}/runs/compare
+// ?a=<run>&b=<run> pick the runs by number; without them the last two runs are compared.
+func (h *Handler) HandleTaskRunCompare(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	runs, err := h.db.GetTaskRuns(task.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get runs: "+err.Error())
+		return
+	}
+	if len(runs) < 2 {
+		h.writeError(w, http.StatusBadRequest, "The task needs at least two runs to compare")
+		return
+	}
+
+	// Runs are numbered 1..n in order
+	pick := func(param string, fallback int) (*TaskRun, error) {
+		number := fallback
+		if v := r.URL.Query().Get(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid run number %q", v)
+			}
+			number = n
+		}
+		for i := range runs {
+			if runs[i].Number == number {
+				return &runs[i], nil
+			}
+		}
+		return nil, fmt.Errorf("Run %d not found", number)
+	}
+	last := runs[len(runs)-1].Number
+	a, err := pick("a", last-1)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	b, err := pick("b", last)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, compareRuns(h.runner.taskProjectDir(task), *a, *b, time.Now()))
+}
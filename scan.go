@@ -0,0 +1,280 @@
+// scan.go finds git repositories below a directory and registers them as
+// projects. POST /api/projects/scan scans a directory fully; in addition the
+// projects base directory is scanned every FORGE_SCAN_INTERVAL. Scheduled
+// scans are incremental: the first one after startup only takes stock, later
+// ones check just the directories that appeared or changed since, so projects
+// removed from FORGE are not added back. Every scan also marks projects whose
+// directory is gone as missing (missing_since) and clears the mark once it is
+// back. Progress is streamed as scan_progress WebSocket messages. New projects
+// get their stack detected (see projectdetect.go).
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultScanInterval is how often the projects base directory is scanned
+const defaultScanInterval = time.Hour
+
+// defaultScanDepth is how deep scans look for repositories
+const defaultScanDepth = 3
+
+// scanProgressInterval throttles progress messages of a scan
+const scanProgressInterval = 500 * time.Millisecond
+
+// scanIntervalFromEnv reads FORGE_SCAN_INTERVAL (e.g. 15m; 0 disables scheduled scans)
+func scanIntervalFromEnv() time.Duration {
+	v := os.Getenv("FORGE_SCAN_INTERVAL")
+	if v == "" {
+		return defaultScanInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("scan").Warn("Ignoring invalid FORGE_SCAN_INTERVAL", "value", v)
+		return defaultScanInterval
+	}
+	return d
+}
+
+// scanDir is what a scan learned about a directory
+type scanDir struct {
+	modTime time.Time // Changes when entries are added to or removed from it
+	repo    bool      // The directory is a git repository
+}
+
+// scanKey identifies the directories of one scan setting
+type scanKey struct {
+	base  string
+	depth int
+}
+
+// projectScanner runs one scan at a time and remembers the directories of
+// the last scan of each base directory
+type projectScanner struct {
+	mu   sync.Mutex
+	dirs map[scanKey]map[string]scanDir
+}
+
+// projectScans is the process-wide scanner
+var projectScans = &projectScanner{dirs: make(map[scanKey]map[string]scanDir)}
+
+// Run scans the projects base directory every interval until stop is closed
+func (s *projectScanner) Run(db Store, hub *Hub, interval time.Duration, stop <-chan struct{}) {
+	if interval == 0 {
+		componentLog("scan").Info("Scheduled project scans disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.scheduledScan(db, hub)
+		backfillProjectStacks(db, hub)
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// scheduledScan scans the projects base directory incrementally, or only
+// marks missing projects if there is none
+func (s *projectScanner) scheduledScan(db Store, hub *Hub) {
+	logger := componentLog("scan")
+	config, err := db.GetConfig()
+	if err != nil {
+		logger.Error("Failed to get config", "err", err)
+		return
+	}
+	base := config.ProjectsBaseDir
+	if base != "" {
+		if _, err := fsAccess.checkPath(base); err != nil {
+			logger.Warn("Not scanning projects base directory", "path", base, "err", err)
+			base = ""
+		} else if info, err := os.Stat(base); err != nil || !info.IsDir() {
+			base = ""
+		}
+	}
+	if base == "" {
+		s.mu.Lock()
+		markMissingProjects(db, hub)
+		s.mu.Unlock()
+		return
+	}
+
+	progress, err := s.scan(db, hub, base, defaultScanDepth, true)
+	if err != nil {
+		logger.Warn("Scheduled project scan failed", "path", base, "err", err)
+		return
+	}
+	if progress.Created > 0 || progress.Missing > 0 {
+		logger.Info("Scheduled project scan", "path", base, "dirs", progress.Dirs, "created", progress.Created, "missing", progress.Missing)
+	}
+}
+
+// scan looks for git repositories below base, registers the new ones as
+// projects and marks missing projects. incremental only checks directories
+// that are new or changed since the last scan of base; without a last scan it
+// only takes stock.
+func (s *projectScanner) scan(db Store, hub *Hub, base string, maxDepth int, incremental bool) (*ScanProgress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	base = filepath.Clean(base)
+	key := scanKey{base: base, depth: maxDepth}
+	previous, known := s.dirs[key]
+	progress := &ScanProgress{BasePath: base, Scheduled: incremental}
+	hub.BroadcastScanProgress(progress)
+
+	lastSent := time.Now()
+	repos, dirs, err := scanGitRepos(base, maxDepth, previous, incremental, func(dirs, found int) {
+		progress.Dirs, progress.Found = dirs, found
+		if time.Since(lastSent) >= scanProgressInterval {
+			lastSent = time.Now()
+			hub.BroadcastScanProgress(progress)
+		}
+	})
+	if err != nil {
+		progress.Done = true
+		progress.Error = err.Error()
+		hub.BroadcastScanProgress(progress)
+		return nil, err
+	}
+	s.dirs[key] = dirs
+	progress.Found = len(repos)
+
+	// The first incremental scan only takes stock, everything in it is old
+	if incremental && !known {
+		repos = nil
+		progress.Found = 0
+	}
+
+	progress.Projects = []Project{}
+	for _, repoPath := range repos {
+		if existing, _ := db.GetProjectByPath(repoPath); existing != nil {
+			continue
+		}
+		name := GetProjectNameFromPath(repoPath)
+		if isRepoSubdir(repoPath) {
+			name = workspacePackageName(gitRoot(repoPath), repoPath)
+		}
+		project, err := db.CreateProject(CreateProjectRequest{
+			Name:        name,
+			Path:        repoPath,
+			Description: "",
+		}, true)
+		if err != nil {
+			componentLog("scan").Warn("Failed to create project", "path", repoPath, "err", err)
+			continue
+		}
+		detectNewProjectStack(db, project)
+		progress.Projects = append(progress.Projects, *project)
+		hub.BroadcastProjectUpdate(project)
+	}
+	progress.Created = len(progress.Projects)
+	progress.Missing = markMissingProjects(db, hub)
+	progress.Done = true
+	hub.BroadcastScanProgress(progress)
+	return progress, nil
+}
+
+// scanGitRepos finds the git repositories (and the packages of workspaces in
+// them) below base up to maxDepth, calling report for every directory. With
+// incremental, directories unchanged since previous are not checked again and
+// repositories found before are left out. Returns the repositories and the
+// directories seen, for the next scan.
+func scanGitRepos(base string, maxDepth int, previous map[string]scanDir, incremental bool, report func(dirs, found int)) ([]string, map[string]scanDir, error) {
+	var repos []string
+	seen := make(map[string]scanDir)
+	baseDepth := strings.Count(base, string(os.PathSeparator))
+	count := 0
+
+	err := filepath.WalkDir(base, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil // Skip directories we can't access
+		}
+		if strings.Count(path, string(os.PathSeparator))-baseDepth > maxDepth {
+			return filepath.SkipDir
+		}
+		if path != base && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		count++
+		report(count, len(repos))
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		dir := scanDir{modTime: info.ModTime()}
+		before, known := previous[path]
+		known = incremental && known
+		if known && before.modTime.Equal(dir.modTime) {
+			seen[path] = before
+			if before.repo {
+				return filepath.SkipDir // Found before, don't descend into git repos
+			}
+			return nil
+		}
+
+		if hasGitDir(path) {
+			dir.repo = true
+			seen[path] = dir
+			if !(known && before.repo) {
+				repos = append(repos, path)
+				// Packages of a workspace become projects of their own
+				if isWorkspaceRoot(path) {
+					repos = append(repos, workspacePackages(path)...)
+				}
+			}
+			return filepath.SkipDir // Don't descend into git repos
+		}
+		seen[path] = dir
+		return nil
+	})
+	return repos, seen, err
+}
+
+// markMissingProjects marks projects whose directory is gone as missing and
+// clears the mark of those that are back. Returns how many went missing.
+func markMissingProjects(db Store, hub *Hub) int {
+	projects, err := db.GetAllProjects()
+	if err != nil {
+		componentLog("scan").Error("Failed to list projects", "err", err)
+		return 0
+	}
+
+	missing := 0
+	for i := range projects {
+		project := &projects[i]
+		if project.Path == "" {
+			continue
+		}
+		_, err := os.Stat(project.Path)
+		gone := os.IsNotExist(err)
+		if gone == (project.MissingSince != nil) || (err != nil && !gone) {
+			continue
+		}
+
+		var since *time.Time
+		if gone {
+			now := time.Now()
+			since = &now
+			missing++
+			componentLog("scan").Warn("Project directory is missing", "project_id", project.ID, "path", project.Path)
+		}
+		if err := db.SetProjectMissing(project.ID, since); err != nil {
+			componentLog("scan").Error("Failed to mark project", "project_id", project.ID, "err", err)
+			continue
+		}
+		project.MissingSince = since
+		hub.BroadcastProjectUpdate(project)
+	}
+	return missing
+}
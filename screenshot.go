@@ -0,0 +1,248 @@
+// screenshot.go verifies UI tasks visually. A project can name the URL of its
+// running frontend (e.g. the dev server); after RALPH finishes a task
+// successfully FORGE captures that page and attaches the screenshot to the
+// task, so reviewers see the visual result next to the diff. By default a
+// headless Chrome is driven through chromedp; a project can configure its own
+// command instead, which gets the URL and the output file in
+// FORGE_SCREENSHOT_URL and FORGE_SCREENSHOT_FILE. A failed capture is logged
+// and does not keep the task from moving to Review.
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/google/uuid"
+)
+
+// screenshotTimeout bounds a capture, including starting the browser
+const screenshotTimeout = 60 * time.Second
+
+// screenshotSettle gives client-side rendering time after the page loaded
+const screenshotSettle = time.Second
+
+// Viewport of the built-in capture
+const (
+	screenshotWidth  = 1280
+	screenshotHeight = 800
+)
+
+// screenshotFilePrefix marks attachments captured by FORGE
+const screenshotFilePrefix = "forge-screenshot-"
+
+// validateScreenshotURL accepts http and https URLs
+func validateScreenshotURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("screenshot URL must be an http or https URL")
+	}
+	return nil
+}
+
+// isScreenshot reports whether att was captured by FORGE
+func isScreenshot(att Attachment) bool {
+	return strings.HasPrefix(att.Filename, screenshotFilePrefix)
+}
+
+// captureTaskScreenshot captures the screenshot URL of project and stores it
+// as an attachment of task
+func captureTaskScreenshot(db Store, task *Task, project *Project) (*Attachment, error) {
+	if project == nil || project.ScreenshotURL == "" {
+		return nil, fmt.Errorf("no screenshot URL configured for the project")
+	}
+	dir := filepath.Join(UploadsDir, task.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, uuid.New().String()+".png")
+
+	ctx, cancel := context.WithTimeout(context.Background(), screenshotTimeout)
+	defer cancel()
+
+	var err error
+	if project.ScreenshotCommand != "" {
+		err = runScreenshotCommand(ctx, project, task, path)
+	} else {
+		err = chromeScreenshot(ctx, project.ScreenshotURL, path)
+	}
+	if err == nil {
+		err = checkScreenshotFile(path)
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	// A custom command may have written a JPEG
+	mimeType := sniffFile(path)
+	ext := binaryAttachmentTypes[mimeType].ext
+	if ext != ".png" {
+		renamed := strings.TrimSuffix(path, ".png") + ext
+		if err := os.Rename(path, renamed); err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+		path = renamed
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	att := &Attachment{
+		ID:        uuid.New().String(),
+		TaskID:    task.ID,
+		Filename:  screenshotFilePrefix + time.Now().Format("20060102-150405") + ext,
+		MimeType:  mimeType,
+		Size:      info.Size(),
+		Path:      path,
+		CreatedAt: time.Now(),
+	}
+	if err := db.CreateAttachment(att); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return att, nil
+}
+
+// chromeScreenshot captures the full page at pageURL with a headless Chrome
+func chromeScreenshot(ctx context.Context, pageURL, path string) error {
+	opts := chromedp.DefaultExecAllocatorOptions[:]
+	if os.Geteuid() == 0 {
+		opts = append(opts, chromedp.NoSandbox) // Chrome refuses to run as root with its sandbox
+	}
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
+	defer cancelAlloc()
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	defer cancelBrowser()
+
+	var png []byte
+	err := chromedp.Run(browserCtx,
+		chromedp.EmulateViewport(screenshotWidth, screenshotHeight),
+		chromedp.Navigate(pageURL),
+		chromedp.WaitReady("body"),
+		chromedp.Sleep(screenshotSettle),
+		chromedp.FullScreenshot(&png, 100),
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "executable file not found") {
+			return fmt.Errorf("no Chrome or Chromium found; install one or set a screenshot command for the project")
+		}
+		return fmt.Errorf("headless Chrome: %w", err)
+	}
+	return os.WriteFile(path, png, 0644)
+}
+
+// runScreenshotCommand runs the project's screenshot command in the task's directory
+func runScreenshotCommand(ctx context.Context, project *Project, task *Task, path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", project.ScreenshotCommand)
+	cmd.Dir = task.ProjectDir
+	if task.WorkDir != "" {
+		cmd.Dir = filepath.Join(task.ProjectDir, task.WorkDir)
+	}
+	cmd.Env = append(os.Environ(), "FORGE_SCREENSHOT_URL="+project.ScreenshotURL, "FORGE_SCREENSHOT_FILE="+absPath)
+	runInOwnProcessGroup(cmd)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("screenshot command timed out after %s", screenshotTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("screenshot command failed: %v: %s", err, truncateText(strings.TrimSpace(string(output)), 500))
+	}
+	return nil
+}
+
+// checkScreenshotFile makes sure a capture produced a PNG or JPEG of acceptable size
+func checkScreenshotFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("no screenshot was written")
+	}
+	if info.Size() > attachmentSizeLimits[AttachmentKindImage] {
+		return fmt.Errorf("screenshot is too large (%s)", formatBytes(uint64(info.Size())))
+	}
+	if mimeType := sniffFile(path); mimeType != "image/png" && mimeType != "image/jpeg" {
+		return fmt.Errorf("screenshot is not a PNG or JPEG image (%s)", mimeType)
+	}
+	return nil
+}
+
+// sniffFile detects the content type of a file from its first bytes
+func sniffFile(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	return http.DetectContentType(head[:n])
+}
+
+// verifyScreenshot captures the project's screenshot URL after a successful
+// run; problems are reported in the task log only
+func (r *RalphRunner) verifyScreenshot(task *Task, project *Project) {
+	if project == nil || project.ScreenshotURL == "" {
+		return
+	}
+	r.hub.BroadcastLog(task.ID, "\n[FORGE] Capturing screenshot of "+project.ScreenshotURL+"...\n")
+	att, err := captureTaskScreenshot(r.db, task, project)
+	if err != nil {
+		r.taskLog(task.ID).Warn("Screenshot failed", "url", project.ScreenshotURL, "err", err)
+		r.hub.BroadcastLog(task.ID, "[FORGE] Screenshot failed: "+err.Error()+"\n")
+		return
+	}
+	r.hub.BroadcastLog(task.ID, "[FORGE] Screenshot attached: "+att.Filename+"\n")
+}
+
+// HandleTaskScreenshot handles POST /api/tasks/{id}/screenshot
+// Captures the screenshot URL of the task's project again, e.g. once the dev server runs.
+func (h *Handler) HandleTaskScreenshot(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if task.ProjectID == "" {
+		h.writeError(w, http.StatusBadRequest, "Task has no project")
+		return
+	}
+	project, err := h.db.GetProject(task.ProjectID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil || project.ScreenshotURL == "" {
+		h.writeError(w, http.StatusBadRequest, "No screenshot URL configured for the project")
+		return
+	}
+
+	att, err := captureTaskScreenshot(h.db, task, project)
+	if err != nil {
+		h.writeError(w, http.StatusBadGateway, "Failed to capture screenshot: "+err.Error())
+		return
+	}
+
+	task.Attachments, _ = h.db.GetAttachmentsByTask(task.ID)
+	h.hub.BroadcastTaskUpdate(task)
+	go h.generateThumbnail(*att)
+	h.writeJSON(w, http.StatusCreated, att)
+}
@@ -0,0 +1,255 @@
+// secrets.go stores per-project secrets such as the API keys a task needs to
+// run its tests. Values are encrypted with AES-GCM under the master key from
+// FORGE_SECRETS_KEY, can be written but never read back through the API, and
+// the ones marked for injection are passed to RALPH as environment variables.
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	// errSecretsDisabled is returned when a secret has to be encrypted or
+	// decrypted but no master key is configured
+	errSecretsDisabled = errors.New("secrets are disabled: set FORGE_SECRETS_KEY")
+	// errSecretExists is returned when a project already has a secret with the name
+	errSecretExists = errors.New("a secret with this name already exists")
+)
+
+// secretNamePattern restricts secret names to valid environment variable names
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretsBox is the process-wide secret box, nil if FORGE_SECRETS_KEY is not set
+var secretsBox = secretBoxFromEnv()
+
+// secretBox encrypts and decrypts secret values with the master key
+type secretBox struct {
+	aead cipher.AEAD
+}
+
+// secretBoxFromEnv reads FORGE_SECRETS_KEY (32 bytes, base64, e.g. openssl rand -base64 32)
+func secretBoxFromEnv() *secretBox {
+	v := os.Getenv("FORGE_SECRETS_KEY")
+	if v == "" {
+		return nil
+	}
+	box, err := newSecretBox(v)
+	if err != nil {
+		componentLog("secrets").Warn("Ignoring invalid FORGE_SECRETS_KEY", "err", err)
+		return nil
+	}
+	return box
+}
+
+// newSecretBox creates a secret box from a base64 encoded 256 bit key
+func newSecretBox(key string) (*secretBox, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, base64 encoded")
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &secretBox{aead: aead}, nil
+}
+
+// seal encrypts value for a project. The project ID is authenticated, so a
+// sealed value does not decrypt when copied to another project.
+func (b *secretBox) seal(projectID, value string) (string, error) {
+	if b == nil {
+		return "", errSecretsDisabled
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(value), []byte(projectID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a value sealed for the project
+func (b *secretBox) open(projectID, sealed string) (string, error) {
+	if b == nil {
+		return "", errSecretsDisabled
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return "", fmt.Errorf("malformed secret")
+	}
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	value, err := b.aead.Open(nil, nonce, ciphertext, []byte(projectID))
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt secret (wrong FORGE_SECRETS_KEY?)")
+	}
+	return string(value), nil
+}
+
+// projectSecretEnv returns NAME=value for the project's secrets marked for
+// injection, and their names for the task log
+func projectSecretEnv(db Store, projectID string) ([]string, []string, error) {
+	if projectID == "" {
+		return nil, nil, nil
+	}
+	list, err := db.GetProjectSecrets(projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var env, names []string
+	for _, s := range list {
+		if !s.Inject {
+			continue
+		}
+		value, err := secretsBox.open(projectID, s.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", s.Name, err)
+		}
+		env = append(env, s.Name+"="+value)
+		names = append(names, s.Name)
+	}
+	return env, names, nil
+}
+
+// HandleProjectSecrets handles GET/POST /api/projects/{id}/secrets
+func (h *Handler) HandleProjectSecrets(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	project, err := h.db.GetProject(projectID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := h.db.GetProjectSecrets(projectID)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get secrets: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusOK, ProjectSecretsResponse{Enabled: secretsBox != nil, Secrets: list})
+
+	case http.MethodPost:
+		var req CreateSecretRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if !secretNamePattern.MatchString(req.Name) {
+			h.writeError(w, http.StatusBadRequest, "Secret name must be a valid environment variable name")
+			return
+		}
+		if req.Value == "" {
+			h.writeError(w, http.StatusBadRequest, "Secret value is required")
+			return
+		}
+
+		sealed, err := secretsBox.seal(projectID, req.Value)
+		if err != nil {
+			h.writeSecretError(w, "create", err)
+			return
+		}
+		secret := &ProjectSecret{ProjectID: projectID, Name: req.Name, Value: sealed, Inject: true}
+		if req.Inject != nil {
+			secret.Inject = *req.Inject
+		}
+		if err := h.db.CreateProjectSecret(secret); err != nil {
+			h.writeSecretError(w, "create", err)
+			return
+		}
+		h.writeJSON(w, http.StatusCreated, secret)
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleProjectSecret handles PUT/DELETE /api/projects/{id}/secrets/{secretId}
+func (h *Handler) HandleProjectSecret(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	secret, err := h.db.GetProjectSecret(r.PathValue("secretId"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get secret: "+err.Error())
+		return
+	}
+	if secret == nil || secret.ProjectID != projectID {
+		h.writeError(w, http.StatusNotFound, "Secret not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req UpdateSecretRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if req.Name != nil {
+			if !secretNamePattern.MatchString(*req.Name) {
+				h.writeError(w, http.StatusBadRequest, "Secret name must be a valid environment variable name")
+				return
+			}
+			secret.Name = *req.Name
+		}
+		if req.Value != nil {
+			if *req.Value == "" {
+				h.writeError(w, http.StatusBadRequest, "Secret value is required")
+				return
+			}
+			sealed, err := secretsBox.seal(projectID, *req.Value)
+			if err != nil {
+				h.writeSecretError(w, "update", err)
+				return
+			}
+			secret.Value = sealed
+		}
+		if req.Inject != nil {
+			secret.Inject = *req.Inject
+		}
+		if err := h.db.UpdateProjectSecret(secret); err != nil {
+			h.writeSecretError(w, "update", err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, secret)
+
+	case http.MethodDelete:
+		if err := h.db.DeleteProjectSecret(secret.ID); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to delete secret: "+err.Error())
+			return
+		}
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// writeSecretError reports a failed secret write with a matching status
+func (h *Handler) writeSecretError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, errSecretsDisabled):
+		h.writeError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, errSecretExists):
+		h.writeError(w, http.StatusConflict, err.Error())
+	default:
+		h.writeError(w, http.StatusInternalServerError, "Failed to "+action+" secret: "+err.Error())
+	}
+}
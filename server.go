@@ -0,0 +1,210 @@
+// server.go configures how FORGE is exposed beyond localhost: native TLS
+// (certificate files or Let's Encrypt via autocert), running behind a reverse
+// proxy that sets X-Forwarded-* headers, and the origins browsers may use to
+// call the API and open the WebSocket.
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// serverSettings is the TLS and proxy configuration read from the environment
+type serverSettings struct {
+	certFile    string   // FORGE_TLS_CERT
+	keyFile     string   // FORGE_TLS_KEY
+	autocert    []string // FORGE_TLS_HOSTNAME: hostnames to get certificates for
+	cacheDir    string   // FORGE_TLS_CACHE_DIR: where autocert keeps certificates
+	trustProxy  bool     // FORGE_TRUST_PROXY: honor X-Forwarded-* headers
+	originsList []string // FORGE_ALLOWED_ORIGINS
+}
+
+// serverConfig is the process-wide server configuration
+var serverConfig = serverSettingsFromEnv()
+
+// serverSettingsFromEnv reads the FORGE_TLS_*, FORGE_TRUST_PROXY and
+// FORGE_ALLOWED_ORIGINS environment variables
+func serverSettingsFromEnv() serverSettings {
+	s := serverSettings{
+		certFile:    os.Getenv("FORGE_TLS_CERT"),
+		keyFile:     os.Getenv("FORGE_TLS_KEY"),
+		autocert:    splitList(os.Getenv("FORGE_TLS_HOSTNAME")),
+		cacheDir:    os.Getenv("FORGE_TLS_CACHE_DIR"),
+		trustProxy:  os.Getenv("FORGE_TRUST_PROXY") == "true",
+		originsList: splitList(os.Getenv("FORGE_ALLOWED_ORIGINS")),
+	}
+	if s.cacheDir == "" {
+		s.cacheDir = "certs"
+	}
+	for i, origin := range s.originsList {
+		s.originsList[i] = strings.TrimRight(strings.ToLower(origin), "/")
+	}
+	return s
+}
+
+// splitList splits a comma separated environment variable, dropping empty entries
+func splitList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// tlsEnabled reports whether the server serves HTTPS itself
+func (s serverSettings) tlsEnabled() bool {
+	return s.certFile != "" || len(s.autocert) > 0
+}
+
+// configureTLS sets up server for HTTPS. It fails on incomplete settings, so
+// FORGE never silently falls back to plain HTTP.
+func (s serverSettings) configureTLS(server *http.Server) error {
+	switch {
+	case s.certFile != "" && len(s.autocert) > 0:
+		return fmt.Errorf("set either FORGE_TLS_CERT/FORGE_TLS_KEY or FORGE_TLS_HOSTNAME, not both")
+	case s.certFile != "" || s.keyFile != "":
+		if s.certFile == "" || s.keyFile == "" {
+			return fmt.Errorf("FORGE_TLS_CERT and FORGE_TLS_KEY must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate: %v", err)
+		}
+		server.TLSConfig = &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		}
+	case len(s.autocert) > 0:
+		// TLS-ALPN-01 challenges are answered on the HTTPS port, which must
+		// therefore be reachable as port 443 under the hostnames
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(s.autocert...),
+			Cache:      autocert.DirCache(s.cacheDir),
+		}
+		server.TLSConfig = m.TLSConfig()
+		server.TLSConfig.MinVersion = tls.VersionTLS12
+	}
+	return nil
+}
+
+// proxyMiddleware applies the X-Forwarded-For, -Proto and -Host headers of a
+// trusted reverse proxy to the request, so logs, origin checks and links see
+// the client's view instead of the proxy's. Without FORGE_TRUST_PROXY the
+// headers are ignored, since any client could set them.
+func proxyMiddleware(next http.Handler) http.Handler {
+	if !serverConfig.trustProxy {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The proxy appends the address it saw, the entries before it come from the client
+		if forwarded := lastHeaderValue(r, "X-Forwarded-For"); forwarded != "" {
+			if ip := net.ParseIP(forwarded); ip != nil {
+				r.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+			}
+		}
+		if proto := strings.ToLower(firstHeaderValue(r, "X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			r.URL.Scheme = proto
+		}
+		if host := firstHeaderValue(r, "X-Forwarded-Host"); host != "" {
+			r.Host = host
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// firstHeaderValue returns the first entry of a comma separated header, which
+// proxies append to: the client's value comes first
+func firstHeaderValue(r *http.Request, name string) string {
+	v, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(v)
+}
+
+// lastHeaderValue returns the last entry of a comma separated header, over
+// all its lines: the one the nearest proxy appended
+func lastHeaderValue(r *http.Request, name string) string {
+	values := r.Header.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+	v := values[len(values)-1]
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+// requestScheme returns http or https as seen by the client
+func requestScheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return r.URL.Scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// originAllowed reports whether a browser on origin may call the API and open
+// the WebSocket. Requests without an Origin (the CLI, curl, same-origin GETs)
+// are always allowed, as are the server's own origin and loopback origins for
+// local development. FORGE_ALLOWED_ORIGINS lists further origins; "*" allows any.
+func originAllowed(r *http.Request, origin string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) && u.Scheme == requestScheme(r) {
+		return true
+	}
+	if isLoopbackHost(u.Hostname()) && isLoopbackHost(hostWithoutPort(r.Host)) {
+		return true
+	}
+
+	origin = strings.TrimRight(strings.ToLower(origin), "/")
+	for _, allowed := range serverConfig.originsList {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// hostWithoutPort strips the port from a Host header
+func hostWithoutPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// isLoopbackHost reports whether host names the local machine
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkWebSocketOrigin is the WebSocket upgrader's origin check
+func checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if !originAllowed(r, origin) {
+		logFrom(r.Context()).Warn("Rejected WebSocket connection", "component", "websocket", "origin", origin)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+// session.go keeps the stdin of a RALPH process open as a message channel.
+// Claude runs with stream-json input: the prompt is the first user message,
+// and feedback sent while the task runs becomes the next one, answered after
+// the current turn instead of restarting the process. Every message ends with
+// a result event; once all are answered, stdin is closed and Claude exits.
+// A CLI without stream-json input gets the prompt as plain text; feedback
+// then restarts the task (see claudecli.go).
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// errSessionGone is returned for messages to a process that takes no more input
+var errSessionGone = errors.New("the RALPH session has ended")
+
+// userMessage is a user turn in Claude's stream-json input
+type userMessage struct {
+	Type    string `json:"type"`
+	Message struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// encodeUserMessage returns text as a line of stream-json input
+func encodeUserMessage(text string) ([]byte, error) {
+	var msg userMessage
+	msg.Type = "user"
+	msg.Message.Role = "user"
+	msg.Message.Content = append(msg.Message.Content, struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}{Type: "text", Text: text})
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// sendUserMessage writes a user message to a process's stdin. Without
+// stream-json input the first message is written as plain text and stdin is
+// closed right away.
+func (r *RalphRunner) sendUserMessage(proc *RalphProcess, text string) error {
+	line, err := encodeUserMessage(text)
+	if err != nil {
+		return err
+	}
+
+	proc.inputMu.Lock()
+	defer proc.inputMu.Unlock()
+
+	proc.mu.Lock()
+	stdin := proc.stdin
+	if stdin == nil || proc.inputClosed {
+		proc.mu.Unlock()
+		return errSessionGone
+	}
+	if proc.textInput {
+		proc.inputClosed = true
+		proc.mu.Unlock()
+		_, err := io.WriteString(stdin, text)
+		stdin.Close()
+		if err == nil {
+			r.recordRunMessage(proc, text)
+		}
+		return err
+	}
+	proc.pendingTurns++
+	proc.mu.Unlock()
+
+	if _, err := stdin.Write(line); err != nil {
+		proc.mu.Lock()
+		proc.pendingTurns--
+		proc.mu.Unlock()
+		return fmt.Errorf("%w: %v", errSessionGone, err)
+	}
+	r.recordRunMessage(proc, text)
+	return nil
+}
+
+// finishTurn is called for every result event. When Claude has answered all
+// messages, stdin is closed so the process exits.
+func (r *RalphRunner) finishTurn(taskID string) {
+	r.mu.RLock()
+	proc, exists := r.processes[taskID]
+	r.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	if proc.pendingTurns > 0 {
+		proc.pendingTurns--
+	}
+	if proc.pendingTurns == 0 && !proc.inputClosed && proc.stdin != nil {
+		proc.inputClosed = true
+		proc.stdin.Close()
+		proc.log.Debug("All messages answered, closed stdin")
+	}
+}
+
+// isResultEvent reports whether an output line is the result event that ends a turn
+func isResultEvent(line string) bool {
+	if !strings.HasPrefix(line, "{") || !strings.Contains(line, `"result"`) {
+		return false
+	}
+	var event struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal([]byte(line), &event) == nil && event.Type == "result"
+}
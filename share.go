@@ -0,0 +1,231 @@
+// share.go lets users show a task to someone without access to the board.
+// POST /api/tasks/{id}/share creates a link to /share.html that is valid for
+// a limited time; anyone with the link can follow the task's status, the end
+// of its conversation and its diff, read-only and without the rest of the
+// board. The link carries the task ID and its expiry, signed with HMAC-SHA256
+// under a key stored with the config, so no link is stored and none can be
+// made up or extended. GET /api/share/{token} returns what the page shows.
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultShareTTL is how long a share link is valid without expires_in_hours
+const defaultShareTTL = 7 * 24 * time.Hour
+
+// maxShareTTL caps how long a share link can be valid
+const maxShareTTL = 30 * 24 * time.Hour
+
+// shareLogTurns is how many turns from the end of the conversation a shared
+// task shows, shareTurnSize how much of each
+const (
+	shareLogTurns = 200
+	shareTurnSize = 4000
+)
+
+// shareDiffLimit caps the diff of a shared task
+const shareDiffLimit = 200000
+
+var (
+	// errShareInvalid is returned for a token that was not signed by this server
+	errShareInvalid = errors.New("share link is invalid")
+	// errShareExpired is returned for a token whose expiry has passed
+	errShareExpired = errors.New("share link has expired")
+)
+
+// shareSignature signs the task ID and expiry of a share token
+func shareSignature(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signShareToken returns the token of a link to the task that expires at expires:
+// <task ID>.<expiry as Unix time>.<signature>
+func signShareToken(key []byte, taskID string, expires time.Time) string {
+	payload := taskID + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + shareSignature(key, payload)
+}
+
+// verifyShareToken returns the task ID and expiry of a token signed with key
+func verifyShareToken(key []byte, token string, now time.Time) (string, time.Time, error) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return "", time.Time{}, errShareInvalid
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(shareSignature(key, payload))) {
+		return "", time.Time{}, errShareInvalid
+	}
+	taskID, exp, ok := strings.Cut(payload, ".")
+	sec, err := strconv.ParseInt(exp, 10, 64)
+	if !ok || err != nil {
+		return "", time.Time{}, errShareInvalid
+	}
+	expires := time.Unix(sec, 0)
+	if !now.Before(expires) {
+		return "", time.Time{}, errShareExpired
+	}
+	return taskID, expires, nil
+}
+
+// taskDiff returns the task's changes as a patch: against its rollback tag if
+// it has one, which includes changes not committed yet, else over its commits
+func taskDiff(dir string, task *Task, commits []TaskCommit) string {
+	if dir == "" || !IsGitRepository(dir) {
+		return ""
+	}
+	if task.RollbackTag != "" {
+		if diff, err := runGitStatusCommand(dir, "diff", "--relative", task.RollbackTag); err == nil {
+			return diff
+		}
+	}
+	if len(commits) == 0 {
+		return ""
+	}
+	// Commits are newest first; the diff runs from the parent of the oldest
+	oldest, newest := commits[len(commits)-1].Hash, commits[0].Hash
+	diff, err := runGitStatusCommand(dir, "diff", "--relative", oldest+"^", newest)
+	if err != nil {
+		return ""
+	}
+	return diff
+}
+
+// sharedLog returns the end of the task's conversation, without Claude's
+// thinking and with long messages and tool calls cut short
+func sharedLog(logs string) []TranscriptTurn {
+	turns, _ := buildTranscript(logs, nil)
+	log := make([]TranscriptTurn, 0, len(turns))
+	for _, turn := range turns {
+		if turn.Role == "thinking" {
+			continue
+		}
+		if turn.Role == "tool_use" {
+			turn.Text, turn.Input = string(turn.Input), nil
+		}
+		if len(turn.Text) > shareTurnSize {
+			turn.Text = turn.Text[:shareTurnSize] + " [...]"
+		}
+		log = append(log, turn)
+	}
+	if len(log) > shareLogTurns {
+		log = log[len(log)-shareLogTurns:]
+	}
+	return log
+}
+
+// HandleShareTask handles POST /api/tasks/{id}/share
+// Creates a read-only link to the task, valid for expires_in_hours (default 7 days).
+func (h *Handler) HandleShareTask(w http.ResponseWriter, r *http.Request) {
+	var req ShareTaskRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	ttl := defaultShareTTL
+	if req.ExpiresInHours != 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	if ttl <= 0 || ttl > maxShareTTL {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("expires_in_hours must be between 1 and %d", int(maxShareTTL.Hours())))
+		return
+	}
+
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	key, err := h.db.GetShareKey()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get share key: "+err.Error())
+		return
+	}
+
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	token := signShareToken(key, task.ID, expires)
+	share := TaskShare{
+		URL:       requestScheme(r) + "://" + r.Host + "/share.html?token=" + token,
+		Token:     token,
+		ExpiresAt: expires,
+	}
+	logFrom(r.Context()).Info("Shared task", "task_id", task.ID, "expires_at", expires)
+	h.writeJSON(w, http.StatusCreated, share)
+}
+
+// HandleSharedTask handles GET /api/share/{token}
+// Returns the status, conversation and diff of a shared task.
+func (h *Handler) HandleSharedTask(w http.ResponseWriter, r *http.Request) {
+	key, err := h.db.GetShareKey()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get share key: "+err.Error())
+		return
+	}
+	taskID, expires, err := verifyShareToken(key, r.PathValue("token"), time.Now())
+	if errors.Is(err, errShareExpired) {
+		h.writeError(w, http.StatusGone, "This share link has expired")
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "This share link is invalid")
+		return
+	}
+
+	task, err := h.db.GetTask(taskID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "The shared task no longer exists")
+		return
+	}
+
+	shared := SharedTask{
+		Title:            task.Title,
+		Description:      task.Description,
+		Status:           task.Status,
+		StatusName:       string(task.Status),
+		Running:          h.runner.IsRunning(task.ID),
+		CurrentIteration: task.CurrentIteration,
+		MaxIterations:    task.MaxIterations,
+		WorkingBranch:    task.WorkingBranch,
+		Error:            task.Error,
+		StartedAt:        task.StartedAt,
+		FinishedAt:       task.FinishedAt,
+		UpdatedAt:        task.UpdatedAt,
+		Log:              sharedLog(task.Logs),
+		ExpiresAt:        expires,
+	}
+	if column, _ := h.db.GetBoardColumn(task.Status); column != nil {
+		shared.StatusName = column.Name
+	}
+	commits, _ := h.db.GetTaskCommits(task.ID)
+	shared.Diff = taskDiff(h.runner.taskProjectDir(task), task, commits)
+	if len(shared.Diff) > shareDiffLimit {
+		cut := strings.LastIndex(shared.Diff[:shareDiffLimit], "\n") + 1
+		shared.Diff = shared.Diff[:cut] + "[...]\n"
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	h.writeJSON(w, http.StatusOK, shared)
+}
@@ -0,0 +1,269 @@
+// signing.go signs the commits and tags FORGE creates. The config holds the
+// signing key (Config.Signing): a GPG key ID from the keyring of the user
+// FORGE runs as, or the path of an SSH private key. Projects opt in with
+// sign_commits. In their repositories FORGE's commits, merges, cherry-picks,
+// rollback tags and release tags then run with commit.gpgSign and
+// tag.gpgSign set through GIT_CONFIG_* variables, so the repository's own
+// configuration stays untouched. The key is tried when it is saved, and a
+// commit or tag that cannot be signed fails with an error naming the key
+// rather than being created unsigned. Commits Claude makes during a task are
+// signed by whatever the repository is configured with.
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Signing formats
+const (
+	SigningFormatGPG = "gpg"
+	SigningFormatSSH = "ssh"
+)
+
+// errSigningFailed is wrapped by errors of commits and tags that could not be signed
+var errSigningFailed = errors.New("signing failed")
+
+// signingFailureMarkers are what git and the signing programs print when a signature fails
+var signingFailureMarkers = []string{
+	"failed to sign", "gpg failed", "signing failed", "no secret key", "No private key", "Couldn't load", "Load key", "ssh-keygen",
+}
+
+// SigningConfig is the key FORGE signs with, stored as a JSON object
+type SigningConfig struct {
+	Format string `json:"format,omitempty"` // gpg or ssh, "" = no signing
+	Key    string `json:"key,omitempty"`    // GPG key ID ("" = the default key) or path of the SSH private key
+}
+
+// Value stores the signing config as JSON, none as an empty string
+func (s SigningConfig) Value() (driver.Value, error) {
+	if s.Format == "" {
+		return "", nil
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads a signing config stored by Value
+func (s *SigningConfig) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into SigningConfig", src)
+	}
+	*s = SigningConfig{}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, s)
+}
+
+// normalize trims the key and checks that format and key go together
+func (s *SigningConfig) normalize() error {
+	s.Format = strings.ToLower(strings.TrimSpace(s.Format))
+	s.Key = strings.TrimSpace(s.Key)
+	switch s.Format {
+	case "":
+		s.Key = ""
+	case SigningFormatGPG:
+		if strings.ContainsAny(s.Key, "\n\r") {
+			return fmt.Errorf("invalid GPG key ID")
+		}
+	case SigningFormatSSH:
+		if s.Key == "" {
+			return fmt.Errorf("SSH signing needs the path of the private key")
+		}
+		if !filepath.IsAbs(s.Key) {
+			return fmt.Errorf("the SSH key must be an absolute path")
+		}
+		if _, err := os.Stat(s.Key); err != nil {
+			return fmt.Errorf("cannot read the SSH key: %v", err)
+		}
+	default:
+		return fmt.Errorf("invalid signing format %q (use gpg or ssh)", s.Format)
+	}
+	return nil
+}
+
+// describe names the key in messages
+func (s SigningConfig) describe() string {
+	if s.Key == "" {
+		return "the default " + strings.ToUpper(s.Format) + " key"
+	}
+	return strings.ToUpper(s.Format) + " key " + s.Key
+}
+
+// test signs a few bytes with the key, the way git would
+func (s SigningConfig) test() error {
+	var cmd *exec.Cmd
+	switch s.Format {
+	case SigningFormatGPG:
+		args := []string{"--batch", "--status-fd=2", "-bsau", s.Key}
+		if s.Key == "" {
+			args = []string{"--batch", "--status-fd=2", "-bsa"}
+		}
+		cmd = exec.Command("gpg", args...)
+	case SigningFormatSSH:
+		cmd = exec.Command("ssh-keygen", "-Y", "sign", "-n", "git", "-f", s.Key)
+	default:
+		return nil
+	}
+	cmd.Stdin = strings.NewReader("forge signing test\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cannot sign with %s: %v: %s", s.describe(), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// gitConfig returns the git settings that make git sign with the key
+func (s SigningConfig) gitConfig() [][2]string {
+	format := "openpgp"
+	if s.Format == SigningFormatSSH {
+		format = SigningFormatSSH
+	}
+	settings := [][2]string{{"commit.gpgSign", "true"}, {"tag.gpgSign", "true"}, {"gpg.format", format}}
+	if s.Key != "" {
+		settings = append(settings, [2]string{"user.signingKey", s.Key})
+	}
+	return settings
+}
+
+// signingEntry is a project that signs
+type signingEntry struct {
+	path string // Project directory
+	root string // Root of its git repository
+}
+
+// signingRegistry knows the signing key and the projects that sign by
+// directory, so git commands only need the directory they run in
+type signingRegistry struct {
+	mu      sync.RWMutex
+	config  SigningConfig
+	entries []signingEntry
+}
+
+// commitSigning holds the signing key and the projects that use it
+var commitSigning = &signingRegistry{}
+
+// load reads the signing key and the projects that sign
+func (s *signingRegistry) load(db Store) error {
+	config, err := db.GetConfig()
+	if err != nil {
+		return err
+	}
+	projects, err := db.GetAllProjects()
+	if err != nil {
+		return err
+	}
+	var entries []signingEntry
+	for _, project := range projects {
+		if !project.SignCommits || project.Path == "" {
+			continue
+		}
+		path, _ := filepath.Abs(project.Path)
+		entries = append(entries, signingEntry{path: path, root: gitRoot(path)})
+	}
+
+	s.mu.Lock()
+	s.config = config.Signing
+	s.entries = entries
+	s.mu.Unlock()
+	return nil
+}
+
+// lookup returns the signing key for a directory if the project in it, or
+// another project in the same repository, signs
+func (s *signingRegistry) lookup(dir string) (SigningConfig, bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return SigningConfig{}, false
+	}
+	root := gitRoot(abs)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.config.Format == "" {
+		return SigningConfig{}, false
+	}
+	for _, entry := range s.entries {
+		if entry.path == abs || (root != "" && entry.root == root) {
+			return s.config, true
+		}
+	}
+	return SigningConfig{}, false
+}
+
+// reloadSigning reads the signing key and projects again after one changed
+func (h *Handler) reloadSigning() {
+	if err := commitSigning.load(h.db); err != nil {
+		componentLog("git").Warn("Failed to load signing settings", "err", err)
+	}
+}
+
+// signsIn reports whether commits and tags in dir are signed
+func signsIn(dir string) bool {
+	_, ok := commitSigning.lookup(dir)
+	return ok
+}
+
+// gitFailed is the error of a failed git command that creates commits or
+// tags: a signing error as it is, else the command with its output
+func gitFailed(op string, err error, output []byte) error {
+	if errors.Is(err, errSigningFailed) {
+		return err
+	}
+	return fmt.Errorf("git %s failed: %v, output: %s", op, err, string(output))
+}
+
+// runSigned runs a git command that creates commits or tags, signing them
+// if the project in cmd.Dir signs. A failed signature is reported as an
+// error wrapping errSigningFailed.
+func runSigned(cmd *exec.Cmd) ([]byte, error) {
+	signing, ok := commitSigning.lookup(cmd.Dir)
+	if !ok {
+		return cmd.CombinedOutput()
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	settings := signing.gitConfig()
+	env = append(env, "GIT_CONFIG_COUNT="+strconv.Itoa(len(settings)))
+	for i, setting := range settings {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, setting[0]),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, setting[1]))
+	}
+	cmd.Env = env
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		for _, marker := range signingFailureMarkers {
+			if strings.Contains(string(output), marker) {
+				componentLog("git").Warn("Signing failed", "dir", cmd.Dir, "key", signing.describe(), "output", strings.TrimSpace(string(output)))
+				return output, fmt.Errorf("%w with %s: %s", errSigningFailed, signing.describe(), strings.TrimSpace(string(output)))
+			}
+		}
+	}
+	return output, err
+}
@@ -0,0 +1,262 @@
+// split.go breaks large tasks into subtasks. A short Claude run without its
+// file editing tools (see askClaude in assist.go) reads the task and the
+// codebase and proposes smaller tasks with the dependencies between them.
+// Nothing is created until the user has reviewed and edited the proposal;
+// then all subtasks are created in one transaction, linked to the original
+// task as their epic. A queued subtask only starts once the tasks it depends
+// on are done (see queue.go).
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// splitTimeout bounds the Claude run that proposes subtasks
+const splitTimeout = 5 * time.Minute
+
+// maxSubtasks is the most subtasks a task can be split into at once
+const maxSubtasks = 20
+
+// BuildSplitPrompt generates the prompt of the run that proposes subtasks
+func BuildSplitPrompt(task *Task, attachments []Attachment) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("# Split: %s\n\n", task.Title))
+
+	if task.Description != "" {
+		sb.WriteString("## Description\n\n")
+		sb.WriteString(task.Description)
+		sb.WriteString("\n\n")
+	}
+
+	if task.AcceptanceCriteria != "" {
+		sb.WriteString("## Acceptance Criteria\n\n")
+		sb.WriteString(task.AcceptanceCriteria)
+		sb.WriteString("\n\n")
+	}
+
+	if len(attachments) > 0 {
+		sb.WriteString("## Attachments\n\n")
+		for _, att := range attachments {
+			sb.WriteString(fmt.Sprintf("- %s (Path: %s)\n", att.Filename, att.Path))
+		}
+		sb.WriteString("\n")
+	}
+
+	writeApprovedPlan(&sb, task)
+
+	sb.WriteString("## Instructions\n\n")
+	sb.WriteString("This task is too large for one run. Do NOT create, modify or delete any files and do not commit. ")
+	sb.WriteString("Read the codebase as needed, then break the task into 2 to 8 smaller tasks. ")
+	sb.WriteString("Each subtask must be implementable and testable on its own, and together they must fulfil the acceptance criteria. ")
+	sb.WriteString("Give every subtask a self-contained description, since it runs without this context.\n\n")
+	sb.WriteString("Answer with a single JSON object and nothing else, in this format:\n\n")
+	sb.WriteString("```json\n")
+	sb.WriteString(`{"subtasks": [{"title": "...", "description": "...", "acceptance_criteria": "- ...", "depends_on": []}]}`)
+	sb.WriteString("\n```\n\n")
+	sb.WriteString("`depends_on` lists the zero-based indexes of the subtasks that must be done first. There must be no cycles.\n")
+
+	return sb.String()
+}
+
+// proposeSplit runs Claude in the task's project to propose subtasks
+func (r *RalphRunner) proposeSplit(ctx context.Context, task *Task) (*SplitProposal, error) {
+	projectDir := task.ProjectDir
+	if projectDir == "" && task.ProjectID != "" {
+		if project, _ := r.db.GetProject(task.ProjectID); project != nil {
+			projectDir = project.Path
+		}
+	}
+	attachments, _ := r.db.GetAttachmentsByTask(task.ID)
+
+	answer, err := r.askClaude(ctx, projectDir, BuildSplitPrompt(task, attachments), splitTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseSplitProposal(answer)
+}
+
+// parseSplitProposal reads the JSON object of Claude's answer
+func parseSplitProposal(text string) (*SplitProposal, error) {
+	data, err := extractJSONObject(text)
+	if err != nil {
+		return nil, err
+	}
+	var proposal SplitProposal
+	if err := json.Unmarshal(data, &proposal); err != nil {
+		return nil, fmt.Errorf("Claude's answer is not a valid proposal: %v", err)
+	}
+	proposal.Queue = false
+	if err := validateSubtasks(proposal.Subtasks); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// validateSubtasks trims the subtasks and checks that every one has a title
+// and that the dependencies point to other subtasks without forming a cycle
+func validateSubtasks(subtasks []SubtaskProposal) error {
+	if len(subtasks) == 0 {
+		return errors.New("At least one subtask is required")
+	}
+	if len(subtasks) > maxSubtasks {
+		return fmt.Errorf("At most %d subtasks are allowed", maxSubtasks)
+	}
+
+	for i := range subtasks {
+		sub := &subtasks[i]
+		sub.Title = strings.TrimSpace(sub.Title)
+		sub.Description = strings.TrimSpace(sub.Description)
+		sub.AcceptanceCriteria = strings.TrimSpace(sub.AcceptanceCriteria)
+		if sub.Title == "" {
+			return fmt.Errorf("Subtask %d has no title", i)
+		}
+		seen := make(map[int]bool)
+		deps := []int{}
+		for _, dep := range sub.DependsOn {
+			if dep < 0 || dep >= len(subtasks) || dep == i {
+				return fmt.Errorf("Subtask %q depends on an invalid subtask index %d", sub.Title, dep)
+			}
+			if !seen[dep] {
+				seen[dep] = true
+				deps = append(deps, dep)
+			}
+		}
+		sub.DependsOn = deps
+	}
+
+	// Depth-first search; a subtask reached again while its own dependencies
+	// are still being visited closes a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(subtasks))
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		switch state[i] {
+		case visiting:
+			return false
+		case visited:
+			return true
+		}
+		state[i] = visiting
+		for _, dep := range subtasks[i].DependsOn {
+			if !visit(dep) {
+				return false
+			}
+		}
+		state[i] = visited
+		return true
+	}
+	for i := range subtasks {
+		if !visit(i) {
+			return fmt.Errorf("The dependencies of subtask %q form a cycle", subtasks[i].Title)
+		}
+	}
+	return nil
+}
+
+// HandleTaskSplit handles POST /api/tasks/{id}/split
+// Asks Claude to break the task into subtasks and returns the proposal
+// without creating anything.
+func (h *Handler) HandleTaskSplit(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	proposal, err := h.runner.proposeSplit(r.Context(), task)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to propose subtasks: "+err.Error())
+		return
+	}
+	logFrom(r.Context()).Info("Proposed subtasks", "task_id", task.ID, "subtasks", len(proposal.Subtasks))
+	h.writeJSON(w, http.StatusOK, proposal)
+}
+
+// HandleTaskSubtasks handles GET/POST /api/tasks/{id}/subtasks
+// GET lists the subtasks of the epic, POST creates them from a reviewed
+// proposal and queues them with "queue".
+func (h *Handler) HandleTaskSubtasks(w http.ResponseWriter, r *http.Request) {
+	parent, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if parent == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		tasks, err := h.db.GetAllTasks()
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to get tasks: "+err.Error())
+			return
+		}
+		subtasks := []Task{}
+		for _, task := range tasks {
+			if task.ParentID == parent.ID {
+				subtasks = append(subtasks, task)
+			}
+		}
+		sort.Slice(subtasks, func(i, j int) bool { return subtasks[i].CreatedAt.Before(subtasks[j].CreatedAt) })
+		h.writeJSON(w, http.StatusOK, subtasks)
+		return
+	}
+
+	var req SplitProposal
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	if err := validateSubtasks(req.Subtasks); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+
+	created, err := h.db.CreateSubtasks(parent, req.Subtasks, config)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create subtasks: "+err.Error())
+		return
+	}
+	for i := range created {
+		if req.Queue {
+			if err := h.db.AddToQueue(created[i].ID); err != nil {
+				logFrom(r.Context()).Warn("Failed to queue subtask", "task_id", created[i].ID, "err", err)
+			}
+		}
+		if task, _ := h.db.GetTask(created[i].ID); task != nil {
+			created[i] = *task
+		}
+		h.hub.BroadcastTaskUpdate(&created[i])
+	}
+
+	msg := fmt.Sprintf("\n[FORGE] Split into %d subtask(s)\n", len(created))
+	h.db.AppendTaskLogs(parent.ID, msg)
+	h.hub.BroadcastLog(parent.ID, msg)
+	logFrom(r.Context()).Info("Created subtasks", "task_id", parent.ID, "subtasks", len(created), "queued", req.Queue)
+	if req.Queue {
+		go h.runner.TryStartNextQueued(r.Context())
+	}
+	h.writeJSON(w, http.StatusCreated, created)
+}
@@ -0,0 +1,275 @@
+// stash.go manages the git stash of a project so uncommitted changes don't
+// block branch switches and pulls. GET /api/projects/{id}/stash lists the
+// stashes, POST stashes the current changes, untracked files included, and
+// POST /api/projects/{id}/stash/pop restores one. Checkouts through FORGE
+// stash the changes, switch and restore them on the new branch; if they
+// don't apply there, they stay in the stash.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stashRefPattern matches the stash entries FORGE accepts, e.g. stash@{2}
+var stashRefPattern = regexp.MustCompile(`^stash@\{\d+\}$`)
+
+// stashBranchPattern extracts the branch from a stash subject like "WIP on main: ..." or "On main: ..."
+var stashBranchPattern = regexp.MustCompile(`^(?:WIP on|On) ([^:]+): (.*)$`)
+
+// errNothingToStash is returned when the working tree has no changes
+var errNothingToStash = errors.New("no local changes to stash")
+
+// defaultStashMessage is the message of stashes made without one
+const defaultStashMessage = "FORGE stash"
+
+// ListStashes returns the stash entries of the repository, newest first
+func ListStashes(path string) ([]StashEntry, error) {
+	cmd := exec.Command("git", "stash", "list", "--format=%gd%x1f%gs%x1f%ct")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	stashes := []StashEntry{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 3 {
+			continue
+		}
+		entry := StashEntry{Ref: fields[0], Message: fields[1]}
+		if m := stashBranchPattern.FindStringSubmatch(fields[1]); m != nil {
+			entry.Branch, entry.Message = m[1], m[2]
+		}
+		if seconds, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			entry.CreatedAt = time.Unix(seconds, 0)
+		}
+		stashes = append(stashes, entry)
+	}
+	return stashes, nil
+}
+
+// StashChanges stashes the uncommitted changes of the project, untracked files
+// included, and returns the stash commit, or errNothingToStash if there are
+// none. The stash is shared by all projects of a repository, so the changes
+// are restored by that commit (see popStashCommit), not as stash@{0}.
+func StashChanges(path, message string) (string, error) {
+	hasChanges, err := HasUncommittedChanges(path)
+	if err != nil {
+		return "", err
+	}
+	if !hasChanges {
+		return "", errNothingToStash
+	}
+	args := append([]string{"stash", "push", "--include-untracked", "-m", message}, scopePathspec(path)...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git stash failed: %v, output: %s", err, string(output))
+	}
+	rev := exec.Command("git", "rev-parse", "stash@{0}")
+	rev.Dir = path
+	output, err := rev.Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse stash@{0} failed: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// stashRefOf returns the stash entry of a stash commit, e.g. stash@{1}; the
+// entry moves down as other stashes are pushed
+func stashRefOf(path, commit string) (string, error) {
+	cmd := exec.Command("git", "stash", "list", "--format=%gd %H")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if ref, hash, ok := strings.Cut(line, " "); ok && hash == commit {
+			return ref, nil
+		}
+	}
+	return "", fmt.Errorf("stash %s not found", commit)
+}
+
+// popStashCommit applies the stash entry of a stash commit and removes it
+func popStashCommit(path, commit string) error {
+	ref, err := stashRefOf(path, commit)
+	if err != nil {
+		return err
+	}
+	return PopStash(path, ref)
+}
+
+// PopStash applies the stash entry ref and removes it. If it does not apply
+// cleanly, git keeps the entry.
+func PopStash(path, ref string) error {
+	cmd := exec.Command("git", "stash", "pop", ref)
+	cmd.Dir = path
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git stash pop failed: %v, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// CheckoutBranchWithStash switches to branch, carrying uncommitted changes
+// over: they are stashed, the branch is checked out and the stash popped.
+// If the checkout fails the changes are restored on the original branch.
+func CheckoutBranchWithStash(path, branch string) error {
+	stash, err := StashChanges(path, "FORGE: before checkout of "+branch)
+	if errors.Is(err, errNothingToStash) {
+		return CheckoutBranch(path, branch)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := CheckoutBranch(path, branch); err != nil {
+		if popErr := popStashCommit(path, stash); popErr != nil {
+			return fmt.Errorf("%v; restoring the stashed changes failed, they are kept in the stash: %v", err, popErr)
+		}
+		return err
+	}
+	if err := popStashCommit(path, stash); err != nil {
+		return fmt.Errorf("switched to %s, but the changes don't apply there and are kept in the stash: %v", branch, err)
+	}
+	return nil
+}
+
+// HandleProjectStash handles GET/POST /api/projects/{id}/stash
+// GET lists the stashes, POST stashes the uncommitted changes.
+func (h *Handler) HandleProjectStash(w http.ResponseWriter, r *http.Request) {
+	project := h.stashProject(w, r)
+	if project == nil {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req StashRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+				h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+				return
+			}
+		}
+		message := strings.TrimSpace(req.Message)
+		if message == "" {
+			message = defaultStashMessage
+		}
+
+		unlock, err := lockRepoWithin(r.Context(), project.Path, "stash")
+		if err != nil {
+			h.writeLockError(w, err)
+			return
+		}
+		_, err = StashChanges(project.Path, message)
+		unlock()
+		gitStatus.Invalidate(project.Path)
+		if errors.Is(err, errNothingToStash) {
+			h.writeError(w, http.StatusBadRequest, "No local changes to stash")
+			return
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to stash: "+err.Error())
+			return
+		}
+		componentLog("git").Info("Stashed changes", "project", project.Name)
+	}
+
+	stashes, err := ListStashes(project.Path)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list stashes: "+err.Error())
+		return
+	}
+	if r.Method == http.MethodPost {
+		h.writeJSON(w, http.StatusCreated, stashes[0])
+		return
+	}
+	h.writeJSON(w, http.StatusOK, stashes)
+}
+
+// HandlePopStash handles POST /api/projects/{id}/stash/pop
+// Restores a stash entry, stash@{0} by default, and returns the remaining ones.
+func (h *Handler) HandlePopStash(w http.ResponseWriter, r *http.Request) {
+	project := h.stashProject(w, r)
+	if project == nil {
+		return
+	}
+
+	var req PopStashRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+	}
+	if req.Ref == "" {
+		req.Ref = "stash@{0}"
+	}
+	if !stashRefPattern.MatchString(req.Ref) {
+		h.writeError(w, http.StatusBadRequest, "Invalid stash: "+req.Ref)
+		return
+	}
+
+	stashes, err := ListStashes(project.Path)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list stashes: "+err.Error())
+		return
+	}
+	found := false
+	for _, stash := range stashes {
+		found = found || stash.Ref == req.Ref
+	}
+	if !found {
+		h.writeError(w, http.StatusNotFound, "Stash not found: "+req.Ref)
+		return
+	}
+
+	unlock, err := lockRepoWithin(r.Context(), project.Path, "stash pop")
+	if err != nil {
+		h.writeLockError(w, err)
+		return
+	}
+	err = PopStash(project.Path, req.Ref)
+	unlock()
+	gitStatus.Invalidate(project.Path)
+	if err != nil {
+		h.writeError(w, http.StatusConflict, "Failed to pop "+req.Ref+", it is kept in the stash: "+err.Error())
+		return
+	}
+
+	stashes, err = ListStashes(project.Path)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list stashes: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, stashes)
+}
+
+// stashProject returns the git project of the request, or writes the error and returns nil
+func (h *Handler) stashProject(w http.ResponseWriter, r *http.Request) *Project {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return nil
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return nil
+	}
+	if !IsGitRepository(project.Path) {
+		h.writeError(w, http.StatusBadRequest, "Project is not a git repository")
+		return nil
+	}
+	return project
+}
@@ -3,12 +3,22 @@ $(document).ready(function() {
     let tasks = [];
     let projects = [];
     let taskTypes = [];
+    let labels = []; // Free-form task labels from /api/labels
     let config = {};
     let ws = null;
+    let boards = []; // Boards from /api/boards, the default board first
+    let currentBoardId = loadSelectedBoard(); // Board shown, its tasks, columns and projects
+    let allBoardColumns = []; // Columns of all boards from /api/columns
+    let boardColumns = []; // Columns of the current board, in display order
+    let logSubscriptionTaskId = null; // Task whose logs are streamed over the WebSocket
+    let wsEpoch = null; // Server instance the sequence numbers belong to
+    let wsLastSeq = 0; // Last event seq received, used to resume after a reconnect
     let currentTaskId = null;
+    let taskComments = []; // Comment thread of the open task
     let currentProjectId = null;  // For project modal editing
     let currentTaskTypeId = null; // For task type modal editing
     let selectedProjectFilter = ''; // For filtering tasks by project
+    let selectedLabelFilter = ''; // Label ID the board is filtered by
     let autoScroll = true;
     let isProgrammaticScroll = false; // Flag to ignore programmatic scrolls
     let scrollTimeout = null; // Debounce timer for scroll detection
@@ -16,7 +26,13 @@ $(document).ready(function() {
     let selectedFolderPath = '';
     let folderBrowserTarget = 'task'; // 'task', 'project', or 'scan'
     let branchRules = []; // Branch rules for current project being edited
+    let projectSecrets = []; // Secrets (without values) of the project being edited
+    let projectEnvironments = []; // Deploy environments of the project being edited
+    let projectMCPServers = []; // MCP servers of the project being edited
+    let pathRules = []; // Protected paths of the project being edited
     let scannedRepos = []; // Scan results
+    let activeCloneId = null; // Clone started from the clone modal
+    let activeBootstrapId = null; // Bootstrap started from the bootstrap modal
     let collapsedFolders = {}; // Track collapsed state of folders
     let githubUser = null; // GitHub user info (username, avatar_url, etc.)
     let sidebarOpen = false; // Track sidebar state
@@ -24,9 +40,10 @@ $(document).ready(function() {
     let activeMobileTab = 'backlog'; // Active tab for mobile view
     let currentAttachments = []; // Attachments for current task
     let lightboxIndex = 0; // Current lightbox image index
+    let authStatus = { enabled: false, user: null }; // GitHub login, from /api/auth/status
 
-    // Initialize
-    init();
+    // Initialize once signed in
+    checkAuth();
 
     // Load collapsed state from localStorage
     function loadCollapsedState() {
@@ -40,6 +57,96 @@ $(document).ready(function() {
         }
     }
 
+    /**
+     * Setup warnings: environment checks that did not pass. Dismissing hides
+     * them until the set of problems changes.
+     */
+    function loadSetupChecks() {
+        $.get('/api/doctor').done(function(report) {
+            const problems = report.checks.filter(c => c.status !== 'ok');
+            const key = problems.map(c => c.name + ':' + c.message).join('|');
+            let dismissed = null;
+            try {
+                dismissed = localStorage.getItem('forge_dismissed_setup');
+            } catch (e) {
+                // Ignore storage errors
+            }
+            if (!problems.length || key === dismissed) {
+                $('#setupBanner').addClass('hidden');
+                return;
+            }
+
+            const $list = $('#setupBannerList').empty();
+            problems.forEach(function(check) {
+                const $item = $('<li>').addClass(check.status).text(check.message);
+                if (check.hint) {
+                    $item.append($('<div>').addClass('help-text').text(check.hint));
+                }
+                $list.append($item);
+            });
+            $('#setupBanner').data('key', key).removeClass('hidden');
+        });
+    }
+
+    /**
+     * Queue pause: running tasks finish, but nothing new starts until the
+     * queue is resumed. The banner shows the state and the reason, or the
+     * cooldown after Claude was rate limited (cooldown.go).
+     */
+    let queuePaused = false;
+
+    function loadQueueState() {
+        $.get('/api/admin/queue').done(renderQueueState);
+    }
+
+    function renderQueueState(state) {
+        queuePaused = !!(state && state.paused);
+        $('#btnQueuePause').text(queuePaused ? 'Resume queue' : 'Pause queue').toggleClass('active', queuePaused);
+        const cooldownUntil = state && state.cooldown_until && new Date(state.cooldown_until);
+        const coolingDown = cooldownUntil && cooldownUntil > new Date();
+        if (!queuePaused && !coolingDown) {
+            $('#queuePausedBanner').addClass('hidden');
+            return;
+        }
+        let text = queuePaused
+            ? 'Queue paused' + (state.reason ? ': ' + state.reason : '') + '. '
+            : 'Claude is rate limited, the queue resumes at ' + cooldownUntil.toLocaleTimeString() + '. ';
+        text += state.running ? 'Running tasks finish, ' : '';
+        text += state.queued + ' queued task' + (state.queued === 1 ? '' : 's') + ' waiting.';
+        $('#queuePausedText').text(text);
+        $('#queuePausedBanner').removeClass('hidden');
+    }
+
+    function toggleQueuePause() {
+        if (queuePaused) {
+            resumeQueue();
+            return;
+        }
+        const reason = prompt('Pause the queue? Running tasks finish, nothing new starts.\n\nReason (optional):', '');
+        if (reason === null) return;
+        $.ajax({
+            url: '/api/admin/queue/pause',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ reason: reason })
+        })
+        .done(renderQueueState)
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Failed to pause the queue', 'error');
+        });
+    }
+
+    function resumeQueue() {
+        $.ajax({
+            url: '/api/admin/queue/resume',
+            method: 'POST'
+        })
+        .done(renderQueueState)
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Failed to resume the queue', 'error');
+        });
+    }
+
     // Save collapsed state to localStorage
     function saveCollapsedState() {
         try {
@@ -164,6 +271,30 @@ $(document).ready(function() {
         }
     }
 
+    /**
+     * Load the board shown last from localStorage
+     * @returns {string} Board ID, 'default' if none was saved
+     */
+    function loadSelectedBoard() {
+        try {
+            return localStorage.getItem('forge-selected-board') || 'default';
+        } catch (e) {
+            return 'default';
+        }
+    }
+
+    /**
+     * Save the board shown to localStorage
+     * @param {string} boardId - Board ID
+     */
+    function saveSelectedBoard(boardId) {
+        try {
+            localStorage.setItem('forge-selected-board', boardId);
+        } catch (e) {
+            // Ignore storage errors
+        }
+    }
+
     /**
      * Load sidebar state from localStorage
      * @returns {boolean} true if sidebar should be open, false otherwise
@@ -399,32 +530,31 @@ $(document).ready(function() {
         const $list = $('#branchDropdownList');
         $list.html('<div class="branch-dropdown-item">Loading...</div>');
 
-        $.when(
-            $.get('/api/projects/' + projectId + '/branches'),
-            $.get('/api/projects/' + projectId + '/git-info')
-        ).done(function(branchRes, gitRes) {
-            const branches = branchRes[0].branches || [];
-            const currentBranch = gitRes[0].current_branch || '';
+        $.get('/api/projects/' + projectId + '/branches').done(function(data) {
+            const branches = data.branches || [];
 
             $list.empty();
 
             // Only local branches, sorted with main/master first
-            const localBranches = branches.filter(b => !b.startsWith('origin/'));
+            const localBranches = branches.filter(b => !b.is_remote);
             localBranches.sort((a, b) => {
-                if (a === 'main' || a === 'master') return -1;
-                if (b === 'main' || b === 'master') return 1;
-                return a.localeCompare(b);
+                if (a.name === 'main' || a.name === 'master') return -1;
+                if (b.name === 'main' || b.name === 'master') return 1;
+                return a.name.localeCompare(b.name);
             });
 
-            localBranches.forEach(function(branch) {
-                const isActive = branch === currentBranch;
+            localBranches.forEach(function(info) {
+                const branch = info.name;
+                const isActive = info.is_current;
                 $list.append(`
-                    <div class="branch-dropdown-item ${isActive ? 'active' : ''}" data-branch="${escapeHtml(branch)}">
+                    <div class="branch-dropdown-item ${isActive ? 'active' : ''}" data-branch="${escapeHtml(branch)}" title="${escapeHtml(branchInfoTitle(info))}">
                         <svg class="branch-item-icon" viewBox="0 0 16 16" fill="currentColor">
                             <path d="M9.5 3.25a2.25 2.25 0 1 1 3 2.122V6A2.5 2.5 0 0 1 10 8.5H6a1 1 0 0 0-1 1v1.128a2.251 2.251 0 1 1-1.5 0V5.372a2.25 2.25 0 1 1 1.5 0v1.836A2.493 2.493 0 0 1 6 7h4a1 1 0 0 0 1-1v-.628A2.25 2.25 0 0 1 9.5 3.25Zm-6 0a.75.75 0 1 0 1.5 0 .75.75 0 0 0-1.5 0Zm8.25-.75a.75.75 0 1 0 0 1.5.75.75 0 0 0 0-1.5ZM4.25 12a.75.75 0 1 0 0 1.5.75.75 0 0 0 0-1.5Z"/>
                         </svg>
                         <span class="branch-item-name">${escapeHtml(branch)}</span>
+                        ${branchTrackHtml(info)}
                         ${isActive ? '<span class="branch-item-badge">current</span>' : ''}
+                        ${!isActive && info.merged ? '<span class="branch-item-badge merged">merged</span>' : ''}
                     </div>
                 `);
             });
@@ -433,7 +563,36 @@ $(document).ready(function() {
                 $list.html('<div class="branch-dropdown-item">No branches</div>');
             }
 
-            // Separator and "Create new branch" option
+            // Stash: stash the uncommitted changes or restore earlier stashes
+            $list.append('<div class="branch-dropdown-separator"></div>');
+            $list.append(`
+                <div class="branch-dropdown-item" data-action="stash">
+                    <svg class="branch-item-icon" viewBox="0 0 16 16" fill="currentColor">
+                        <path d="M0 2.75C0 1.784.784 1 1.75 1h12.5c.966 0 1.75.784 1.75 1.75v1.5A1.75 1.75 0 0 1 15 5.907v7.343A1.75 1.75 0 0 1 13.25 15H2.75A1.75 1.75 0 0 1 1 13.25V5.907A1.75 1.75 0 0 1 0 4.25Zm1.75-.25a.25.25 0 0 0-.25.25v1.5c0 .138.112.25.25.25h12.5a.25.25 0 0 0 .25-.25v-1.5a.25.25 0 0 0-.25-.25ZM2.5 6v7.25c0 .138.112.25.25.25h10.5a.25.25 0 0 0 .25-.25V6ZM6 8.75A.75.75 0 0 1 6.75 8h2.5a.75.75 0 0 1 0 1.5h-2.5A.75.75 0 0 1 6 8.75Z"/>
+                    </svg>
+                    <span class="branch-item-name">Stash changes</span>
+                </div>
+            `);
+            $.get('/api/projects/' + projectId + '/stash').done(function(stashes) {
+                // Inserted bottom-up so the newest stash comes first
+                (stashes || []).slice().reverse().forEach(function(stash) {
+                    const $item = $(`
+                        <div class="branch-dropdown-item" data-action="pop-stash" title="Restore these changes">
+                            <svg class="branch-item-icon" viewBox="0 0 16 16" fill="currentColor">
+                                <path d="M0 2.75C0 1.784.784 1 1.75 1h12.5c.966 0 1.75.784 1.75 1.75v1.5A1.75 1.75 0 0 1 15 5.907v7.343A1.75 1.75 0 0 1 13.25 15H2.75A1.75 1.75 0 0 1 1 13.25V5.907A1.75 1.75 0 0 1 0 4.25Zm1.75-.25a.25.25 0 0 0-.25.25v1.5c0 .138.112.25.25.25h12.5a.25.25 0 0 0 .25-.25v-1.5a.25.25 0 0 0-.25-.25ZM2.5 6v7.25c0 .138.112.25.25.25h10.5a.25.25 0 0 0 .25-.25V6ZM6 8.75A.75.75 0 0 1 6.75 8h2.5a.75.75 0 0 1 0 1.5h-2.5A.75.75 0 0 1 6 8.75Z"/>
+                            </svg>
+                            <span class="branch-item-name"></span>
+                            <span class="branch-item-badge"></span>
+                        </div>
+                    `);
+                    $item.attr('data-ref', stash.ref);
+                    $item.find('.branch-item-name').text(stash.message);
+                    $item.find('.branch-item-badge').text(stash.branch || stash.ref);
+                    $item.insertAfter($list.find('[data-action="stash"]'));
+                });
+            });
+
+            // Separator, "Create new branch" and "Clean up branches" options
             $list.append('<div class="branch-dropdown-separator"></div>');
             $list.append(`
                 <div class="branch-dropdown-item branch-create-new" data-action="create-branch">
@@ -442,12 +601,38 @@ $(document).ready(function() {
                     </svg>
                     <span class="branch-item-name">Create new branch</span>
                 </div>
+                <div class="branch-dropdown-item" data-action="cleanup-branches">
+                    <svg class="branch-item-icon" viewBox="0 0 16 16" fill="currentColor">
+                        <path d="M11 1.75V3h2.25a.75.75 0 0 1 0 1.5H2.75a.75.75 0 0 1 0-1.5H5V1.75C5 .784 5.784 0 6.75 0h2.5C10.216 0 11 .784 11 1.75ZM4.496 6.675l.66 6.6a.25.25 0 0 0 .249.225h5.19a.25.25 0 0 0 .249-.225l.66-6.6a.75.75 0 0 1 1.492.149l-.66 6.6A1.748 1.748 0 0 1 10.595 15h-5.19a1.75 1.75 0 0 1-1.741-1.575l-.66-6.6a.75.75 0 1 1 1.492-.15ZM6.5 1.75V3h3V1.75a.25.25 0 0 0-.25-.25h-2.5a.25.25 0 0 0-.25.25Z"/>
+                    </svg>
+                    <span class="branch-item-name">Clean up branches...</span>
+                </div>
             `);
         }).fail(function() {
             $list.html('<div class="branch-dropdown-item">Error loading</div>');
         });
     }
 
+    // Ahead/behind counts of a branch against its upstream
+    function branchTrackHtml(info) {
+        if (info.upstream_gone) return '<span class="branch-item-track">gone</span>';
+        let track = '';
+        if (info.ahead) track += `&uarr;${info.ahead}`;
+        if (info.behind) track += `&darr;${info.behind}`;
+        return track ? `<span class="branch-item-track">${track}</span>` : '';
+    }
+
+    // Tooltip of a branch: upstream and last commit
+    function branchInfoTitle(info) {
+        const parts = [];
+        if (info.upstream) parts.push('Tracks ' + info.upstream + (info.upstream_gone ? ' (deleted)' : ''));
+        if (info.last_commit_at) {
+            parts.push('Last commit ' + formatRelativeTime(new Date(info.last_commit_at).getTime()) +
+                (info.last_commit_author ? ' by ' + info.last_commit_author : ''));
+        }
+        return parts.join(', ');
+    }
+
     /**
      * Switch to a branch
      */
@@ -469,6 +654,118 @@ $(document).ready(function() {
             });
     }
 
+    /**
+     * Stash the uncommitted changes or pop a stash entry
+     */
+    function updateStash(projectId, action, ref) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/' + action,
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify(ref ? { ref: ref } : {})
+        })
+        .done(function() {
+            showToast(action === 'stash' ? 'Changes stashed' : 'Stash restored', 'success');
+            loadBranchDropdown(projectId);
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Stash failed', 'error');
+        });
+    }
+
+    // Branch cleanup: preview merged (and old) branches, delete the selected ones
+    function openBranchCleanupModal(projectId) {
+        $('#branchCleanupModal').data('project', projectId).addClass('active');
+        $('#branchCleanupDays').val('0');
+        loadBranchCleanup();
+    }
+
+    function closeBranchCleanupModal() {
+        $('#branchCleanupModal').removeClass('active');
+    }
+
+    function branchCleanupRequest(deleteBranches) {
+        const projectId = $('#branchCleanupModal').data('project');
+        return $.ajax({
+            url: '/api/projects/' + projectId + '/branches/cleanup',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({
+                older_than_days: parseInt($('#branchCleanupDays').val(), 10) || 0,
+                delete: deleteBranches
+            })
+        });
+    }
+
+    function loadBranchCleanup() {
+        $('#branchCleanupList').html('<div class="scan-result-item">Loading...</div>');
+        branchCleanupRequest([])
+            .done(renderBranchCleanup)
+            .fail(function(xhr) {
+                $('#branchCleanupList').empty();
+                showToast(xhr.responseJSON?.error || 'Failed to list branches', 'error');
+            });
+    }
+
+    function renderBranchCleanup(data) {
+        const $list = $('#branchCleanupList');
+        const candidates = data.candidates || [];
+        $list.empty();
+        $('#btnDeleteBranches').prop('disabled', candidates.length === 0);
+
+        if (candidates.length === 0) {
+            $list.html('<div style="padding: 1rem; text-align: center; color: var(--text-secondary);">Nothing to clean up</div>');
+            return;
+        }
+
+        candidates.forEach(function(info) {
+            const $item = $(`
+                <label class="scan-result-item" title="${escapeHtml(branchInfoTitle(info))}">
+                    <input type="checkbox" checked>
+                    <span class="scan-result-path"></span>
+                    ${info.is_remote ? '<span class="branch-item-badge">remote</span>' : ''}
+                    ${info.reasons.includes('merged') ? '<span class="branch-item-badge merged">merged</span>' : ''}
+                    ${info.reasons.includes('old') && info.last_commit_at ? `<span class="branch-item-badge">${escapeHtml(formatRelativeTime(new Date(info.last_commit_at).getTime()))}</span>` : ''}
+                </label>
+            `);
+            $item.find('input').attr('data-branch', info.name);
+            $item.find('.scan-result-path').text(info.name);
+            $list.append($item);
+        });
+    }
+
+    function deleteSelectedBranches() {
+        const branches = $('#branchCleanupList input:checked').map(function() {
+            return $(this).attr('data-branch');
+        }).get();
+        if (branches.length === 0) {
+            showToast('No branches selected', 'info');
+            return;
+        }
+        if (!confirm('Delete ' + branches.length + ' branch(es)? Remote branches are deleted on the remote as well.')) {
+            return;
+        }
+
+        const $btn = $('#btnDeleteBranches').prop('disabled', true);
+        branchCleanupRequest(branches)
+            .done(function(data) {
+                const failed = Object.keys(data.failed || {});
+                if (failed.length) {
+                    showToast('Could not delete ' + failed.join(', ') + ': ' + data.failed[failed[0]], 'error');
+                } else {
+                    showToast('Deleted ' + data.deleted.length + ' branch(es)', 'success');
+                }
+                renderBranchCleanup(data);
+                loadBranchDropdown($('#branchCleanupModal').data('project'));
+            })
+            .fail(function(xhr) {
+                showToast(xhr.responseJSON?.error || 'Failed to delete branches', 'error');
+            })
+            .always(function() {
+                $btn.prop('disabled', $('#branchCleanupList input').length === 0);
+            });
+    }
+
     /**
      * Pull latest changes
      */
@@ -565,15 +862,167 @@ $(document).ready(function() {
 
     // ============================================================================
 
+    // With a GitHub login configured, show the login screen until signed in (see auth.go)
+    function checkAuth() {
+        $.get('/api/auth/status')
+            .done(function(status) {
+                authStatus = status;
+                if (status.enabled && !status.user) {
+                    showLoginScreen(status);
+                    return;
+                }
+                init();
+            })
+            .fail(function() {
+                init();
+            });
+    }
+
+    function showLoginScreen(status) {
+        const error = new URLSearchParams(window.location.search).get('login_error');
+        if (error) {
+            $('#loginError').text(error).removeClass('hidden');
+            history.replaceState(null, '', window.location.pathname);
+        }
+        $('#btnLoginGithub').toggleClass('hidden', !status.web_flow);
+        $('#loginCliHint').toggleClass('hidden', status.web_flow);
+        $('#loginScreen').removeClass('hidden');
+    }
+
+    function logout() {
+        $.post('/api/auth/logout')
+            .always(function() {
+                window.location.reload();
+            });
+    }
+
+    const tokenSourceText = {
+        personal: 'Your pushes and pull requests use this token.',
+        github_login: 'Not set: your pushes and pull requests use the token of your GitHub login.',
+        shared: 'Not set: your pushes and pull requests use the shared credentials.'
+    };
+
+    function renderProfile(profile) {
+        $('#profileGitName').val(profile.user.git_name).attr('placeholder', profile.author_name);
+        $('#profileGitEmail').val(profile.user.git_email).attr('placeholder', profile.author_email);
+        $('#profileToken').val('').attr('placeholder', profile.user.personal_token_set ? '••••••••' : 'ghp_...');
+        $('#profileTokenSource').text(tokenSourceText[profile.token_source] || '');
+        $('#profileClearToken').prop('checked', false);
+        $('#profileClearTokenRow').toggleClass('hidden', !profile.user.personal_token_set);
+    }
+
+    function openProfileModal() {
+        $.get('/api/profile')
+            .done(function(profile) {
+                renderProfile(profile);
+                $('#profileModal').addClass('active');
+            })
+            .fail(function(xhr) {
+                showToast(xhr.responseJSON?.error || 'Error loading profile', 'error');
+            });
+    }
+
+    function closeProfileModal() {
+        $('#profileModal').removeClass('active');
+    }
+
+    function saveProfile() {
+        const data = {
+            git_name: $('#profileGitName').val().trim(),
+            git_email: $('#profileGitEmail').val().trim()
+        };
+        const token = $('#profileToken').val().trim();
+        if (token) {
+            data.personal_token = token;
+        } else if ($('#profileClearToken').is(':checked')) {
+            data.personal_token = '';
+        }
+
+        $('#btnSaveProfile').prop('disabled', true);
+        $.ajax({
+            url: '/api/profile',
+            method: 'PUT',
+            contentType: 'application/json',
+            data: JSON.stringify(data)
+        })
+        .done(function() {
+            showToast('Profile saved', 'success');
+            closeProfileModal();
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Error saving profile', 'error');
+        })
+        .always(function() {
+            $('#btnSaveProfile').prop('disabled', false);
+        });
+    }
+
     function init() {
+        if (authStatus.user) {
+            $('#logoutLabel').text('Log out ' + authStatus.user.login);
+            $('#btnLogout').removeClass('hidden').on('click', logout);
+            $('#btnOpenProfile').removeClass('hidden').on('click', openProfileModal);
+            // An expired session sends the board back to the login screen
+            $(document).ajaxError(function(event, xhr) {
+                if (xhr.status === 401) window.location.reload();
+            });
+        }
         loadCollapsedState();
         loadConfig();
+        loadBoards();
         loadProjects();
         loadTaskTypes();
+        loadLabels();
+        loadColumns();
         loadTasks();
+        loadSetupChecks();
+        loadQueueState();
         connectWebSocket();
         setupEventListeners();
         setupDragAndDrop();
+        setupColumnSettings();
+        setupLabelSettings();
+        setupBoardSettings();
+        setupComments();
+        $('#btnPreviewDescription').on('click', toggleDescriptionPreview);
+        $('.split-close, #btnCancelSplit').on('click', closeSplitModal);
+        $('#splitModal').on('click', function(e) {
+            if (e.target === this) closeSplitModal();
+        });
+        $('#btnCreateSubtasks').on('click', createSubtasks);
+        $('#btnAddSubtask').on('click', function() {
+            readSplitSubtasks();
+            splitSubtasks.push({ title: '', description: '', acceptance_criteria: '', depends_on: [] });
+            renderSplitSubtasks();
+        });
+        $('#splitSubtaskList').on('click', '.split-remove', function() {
+            removeSplitSubtask(parseInt($(this).closest('.split-subtask').attr('data-index'), 10));
+        });
+        $('#btnTriageTask').on('click', triageTask);
+        $('#btnQueuePause').on('click', toggleQueuePause);
+        $('#btnResumeQueue').on('click', resumeQueue);
+        $('#btnRecheckSetup').on('click', loadSetupChecks);
+        $('#btnDismissSetup').on('click', function() {
+            try {
+                localStorage.setItem('forge_dismissed_setup', $('#setupBanner').data('key'));
+            } catch (e) {
+                // Ignore storage errors
+            }
+            $('#setupBanner').addClass('hidden');
+        });
+        $('#btnSuggestTask').on('click', suggestTaskFields);
+        $('#btnApplySuggestion').on('click', applyTaskSuggestion);
+        $('#btnDismissSuggestion').on('click', hideTaskSuggestion);
+        $('#btnSavePlan').on('click', function() {
+            if (currentTaskId) updateTaskPlan(currentTaskId, 'PUT', { plan: $('#taskPlan').val() }, 'Plan saved');
+        });
+        $('#btnApprovePlan').on('click', function() {
+            if (currentTaskId) updateTaskPlan(currentTaskId, 'PUT', { plan: $('#taskPlan').val(), start: true }, 'Plan approved, task queued');
+        });
+        $('#btnDiscardPlan').on('click', function() {
+            if (currentTaskId && confirm('Discard the plan?')) updateTaskPlan(currentTaskId, 'DELETE', null, 'Plan discarded');
+        });
+        $('#taskDescription, #taskCriteria').on('paste', handleUploadPaste);
         setupSidebarResize();
         setupMobileTabNavigation();
 
@@ -594,6 +1043,7 @@ $(document).ready(function() {
         $.get('/api/config')
             .done(function(data) {
                 config = data;
+                applyBrowseAccess();
                 // Check GitHub connection after config is loaded
                 checkGithubConnection();
             })
@@ -602,6 +1052,11 @@ $(document).ready(function() {
             });
     }
 
+    // Hides the folder browser buttons if the server disabled browsing
+    function applyBrowseAccess() {
+        $('#btnBrowse, #btnBrowseProject, #btnBrowseScan, #btnBrowseSettingsDir').toggleClass('hidden', !config.browse_enabled);
+    }
+
     function loadProjects() {
         $.get('/api/projects')
             .done(function(data) {
@@ -623,7 +1078,7 @@ $(document).ready(function() {
 
         $.get('/api/projects/' + projectId + '/branches')
             .done(function(data) {
-                const branches = data.branches || [];
+                const branches = (data.branches || []).map(b => b.name);
 
                 // Sort branches: main/master first, then alphabetically
                 branches.sort(function(a, b) {
@@ -680,20 +1135,50 @@ $(document).ready(function() {
             });
     }
 
+    function loadColumns() {
+        $.get('/api/columns')
+            .done(function(data) {
+                applyColumns(data || []);
+                renderBoardColumns();
+                renderAllTasks();
+                renderColumnSettings();
+            })
+            .fail(function(xhr) {
+                showToast('Error loading columns', 'error');
+            });
+    }
+
+    function loadLabels() {
+        $.get('/api/labels')
+            .done(function(data) {
+                applyLabels(data || []);
+            })
+            .fail(function(xhr) {
+                showToast('Error loading labels', 'error');
+            });
+    }
+
     function loadTasks() {
-        $.get('/api/tasks')
+        const boardId = currentBoardId;
+        $.get('/api/boards/' + encodeURIComponent(boardId) + '/tasks')
             .done(function(data) {
+                if (boardId !== currentBoardId) return; // Switched boards meanwhile
                 tasks = data || [];
                 renderAllTasks();
             })
             .fail(function(xhr) {
+                // The saved board was deleted meanwhile
+                if (xhr.status === 404 && boardId !== 'default') {
+                    switchBoard('default');
+                    return;
+                }
                 showToast('Error loading tasks', 'error');
             });
     }
 
     function saveTask(taskData) {
         const isNew = !taskData.id;
-        const url = isNew ? '/api/tasks' : '/api/tasks/' + taskData.id;
+        const url = isNew ? '/api/boards/' + encodeURIComponent(currentBoardId) + '/tasks' : '/api/tasks/' + taskData.id;
         const method = isNew ? 'POST' : 'PUT';
 
         $.ajax({
@@ -718,6 +1203,15 @@ $(document).ready(function() {
             showToast(isNew ? 'Task created' : 'Task saved', 'success');
         })
         .fail(function(xhr) {
+            const duplicates = xhr.responseJSON?.duplicates;
+            if (isNew && xhr.status === 409 && duplicates && duplicates.length) {
+                const list = duplicates.map(d => '- ' + d.title + ' (' + d.status + ', ' +
+                    Math.round(d.similarity * 100) + '% similar)').join('\n');
+                if (confirm('Similar tasks already exist:\n\n' + list + '\n\nCreate this task anyway?')) {
+                    saveTask(Object.assign({}, taskData, { force: true }));
+                }
+                return;
+            }
             const msg = xhr.responseJSON?.error || 'Error saving';
             showToast(msg, 'error');
         });
@@ -754,7 +1248,7 @@ $(document).ready(function() {
 
             // Check if task was redirected to queue (requested progress but got queued)
             if (newStatus === 'progress' && task.status === 'queued') {
-                showToast('Task is running. Added to queue at position ' + task.queue_position, 'info');
+                showToast('Another task is running. Added to the project\'s queue at position ' + task.queue_position, 'info');
             } else if (newStatus === 'progress') {
                 openEditTaskModal(task);
             }
@@ -762,19 +1256,101 @@ $(document).ready(function() {
         .fail(function(xhr) {
             const msg = xhr.responseJSON?.error || 'Error updating';
             showToast(msg, 'error');
-            renderAllTasks();
+            // The card was already moved locally (e.g. rejected by a WIP limit)
+            loadTasks();
         });
     }
 
+    // Move a queued task to a new queue position (1 = next)
+    function moveTaskInQueue(taskId, position) {
+        $.ajax({
+            url: '/api/tasks/' + taskId + '/queue-position',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ position: position })
+        })
+        .done(applyQueueOrder)
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error reordering queue';
+            showToast(msg, 'error');
+            loadTasks();
+        });
+    }
+
+    // Apply a complete queue order from the API or a queue_reordered event
+    function applyQueueOrder(queue) {
+        queue.forEach(function(entry) {
+            const task = tasks.find(t => t.id === entry.task_id);
+            if (task) task.queue_position = entry.position;
+        });
+        renderAllTasks();
+    }
+
+    // Queue position a card dropped at clientY would get in its project's queue
+    function queueDropPosition($container, task, clientY) {
+        let target = null;
+        let last = null;
+        $container.find('.task-card').each(function() {
+            const id = $(this).attr('data-id');
+            if (id === task.id) return;
+            const other = tasks.find(t => t.id === id);
+            if (!other || !other.queue_position || other.project_id !== task.project_id) return;
+            last = other.queue_position;
+            const rect = this.getBoundingClientRect();
+            if (target === null && clientY < rect.top + rect.height / 2) {
+                target = other.queue_position;
+            }
+        });
+
+        if (target === null) {
+            // Dropped below the last card
+            return last || task.queue_position;
+        }
+        // The task itself frees a slot above the target when moving down
+        return target > task.queue_position ? target - 1 : target;
+    }
+
+    // Priority of a queued task raised by one level per aging interval of its
+    // board it has waited, as the server orders the queue
+    function effectivePriority(task) {
+        const priority = task.priority || 2;
+        const board = boards.find(b => b.id === (task.board_id || 'default'));
+        const hours = (board && board.priority_aging_hours) || 0;
+        if (!hours || !task.queued_at) return priority;
+        const waited = (Date.now() - new Date(task.queued_at).getTime()) / 3600000;
+        return Math.max(priority - Math.floor(waited / hours), 1);
+    }
+
     function saveSettings() {
         const settingsData = {
             default_project_dir: $('#settingsProjectDir').val().trim(),
             claude_command: $('#settingsClaudeCommand').val().trim(),
             default_max_iterations: parseInt($('#settingsMaxIterations').val()) || 10,
-            github_token: $('#settingsGithubToken').val().trim(),
             default_branch: $('#settingsDefaultBranch').val().trim(),
             default_priority: parseInt($('#settingsDefaultPriority').val()) || 2,
-            auto_archive_days: parseInt($('#settingsAutoArchive').val()) || 0
+            auto_archive_days: parseInt($('#settingsAutoArchive').val()) || 0,
+            max_concurrent_tasks: parseInt($('#settingsMaxConcurrent').val()) || 1,
+            blocked_triage: $('#settingsBlockedTriage').is(':checked'),
+            github_user_tokens: $('#settingsGithubUserTokens').is(':checked'),
+            github: readGitHubEndpoints('#settingsGithub'),
+            signing: {
+                format: $('#settingsSigningFormat').val(),
+                key: $('#settingsSigningKey').val().trim()
+            },
+            attachment_types: $('#settingsAttachmentTypes').val().trim(),
+            jira_url: $('#settingsJiraUrl').val().trim(),
+            jira_user: $('#settingsJiraUser').val().trim(),
+            claude: readClaudeSettings('#settingsClaude'),
+            log_retention: {
+                max_size_kb: parseInt($('#settingsLogMaxSize').val(), 10) || 0,
+                compress_after_days: parseInt($('#settingsLogCompressDays').val(), 10) || 0,
+                purge_after_days: parseInt($('#settingsLogPurgeDays').val(), 10) || 0
+            },
+            retry_policy: {
+                max_retries: parseInt($('#settingsRetryMax').val(), 10) || 0,
+                backoff_seconds: parseInt($('#settingsRetryBackoff').val(), 10) || 0
+            },
+            budget: readBudget('#settingsBudget')
         };
 
         $.ajax({
@@ -783,18 +1359,72 @@ $(document).ready(function() {
             contentType: 'application/json',
             data: JSON.stringify(settingsData)
         })
-        .done(function(data) {
+        .then(function(data) {
             config = data;
+            return saveCredentials();
+        })
+        .done(function() {
             showToast('Settings saved', 'success');
             closeSettingsModal();
+            renderAllTasks(); // Queue order depends on the queue policy
             // Re-check GitHub connection after saving
             checkGithubConnection();
         })
         .fail(function(xhr) {
-            showToast('Error saving settings', 'error');
+            showToast(xhr.responseJSON?.error || 'Error saving settings', 'error');
+        });
+    }
+
+    // Applies the saved log retention settings right away
+    function compactTaskLogs() {
+        $.ajax({
+            url: '/api/admin/task-logs/compact',
+            method: 'POST'
+        })
+        .done(function(result) {
+            const freed = Math.max(result.bytes_before - result.bytes_after, 0);
+            showToast('Logs compacted: ' + result.capped + ' capped, ' + result.compressed + ' compressed, ' +
+                result.purged + ' purged (' + Math.round(freed / 1024) + ' KB freed)', 'success');
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Log compaction failed', 'error');
         });
     }
 
+    // Tokens are write-only: only filled in inputs replace the stored token
+    function saveCredentials() {
+        const requests = [];
+        [['github', '#settingsGithubToken'], ['jira', '#settingsJiraToken'], ['linear', '#settingsLinearToken']].forEach(function([name, input]) {
+            const value = $(input).val().trim();
+            if (value) {
+                requests.push(saveCredential(name, value));
+            }
+        });
+        return $.when.apply($, requests);
+    }
+
+    // Stores a token (github, jira or linear), or clears it if value is empty
+    function saveCredential(name, value) {
+        return $.ajax({
+            url: '/api/config/credentials/' + name,
+            method: value ? 'PUT' : 'DELETE',
+            contentType: 'application/json',
+            data: value ? JSON.stringify({ value: value }) : undefined
+        })
+        .done(function(data) {
+            config = data;
+        });
+    }
+
+    // Empties a token input and tells whether a token is stored
+    function showCredentialInput($input, isSet) {
+        if ($input.data('placeholder') === undefined) {
+            $input.data('placeholder', $input.attr('placeholder') || '');
+        }
+        $input.val('').attr('placeholder', isSet ? 'Saved - enter a new token to replace it' : $input.data('placeholder'));
+        $input.siblings('.btn-clear-credential').toggleClass('hidden', !isSet);
+    }
+
     // Project API Functions
     function saveProject(projectData) {
         const isNew = !projectData.id;
@@ -843,28 +1473,140 @@ $(document).ready(function() {
         });
     }
 
-    function loadBranchRules(projectId) {
-        $.get('/api/projects/' + projectId + '/rules')
-            .done(function(data) {
-                branchRules = data || [];
-                renderBranchRules();
-            })
+    function importIssues(projectId) {
+        const $btn = $('#btnImportIssues').prop('disabled', true);
+        $.post('/api/projects/' + projectId + '/import-issues')
+            .done(showImportResult)
             .fail(function(xhr) {
-                branchRules = [];
-                renderBranchRules();
+                const msg = xhr.responseJSON?.error || 'Error importing issues';
+                showToast(msg, 'error');
+            })
+            .always(function() {
+                $btn.prop('disabled', false);
             });
     }
 
-    function addBranchRule(projectId, pattern) {
+    function importJira(projectId, jql) {
+        const $btn = $('#btnImportJira').prop('disabled', true);
         $.ajax({
-            url: '/api/projects/' + projectId + '/rules',
+            url: '/api/projects/' + projectId + '/import-jira',
             method: 'POST',
             contentType: 'application/json',
-            data: JSON.stringify({ branch_pattern: pattern })
+            data: JSON.stringify({ jql: jql })
         })
-        .done(function(rule) {
-            branchRules.push(rule);
-            renderBranchRules();
+        .done(showImportResult)
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error importing from Jira';
+            showToast(msg, 'error');
+        })
+        .always(function() {
+            $btn.prop('disabled', false);
+        });
+    }
+
+    function loadLinearSources() {
+        $('#linearImportGroup').addClass('hidden');
+        if (!config.linear_token_set) return;
+
+        $.get('/api/linear/teams')
+            .done(function(teams) {
+                const $select = $('#linearImportSource').empty();
+                (teams || []).forEach(function(team) {
+                    $select.append($('<option>').val('team:' + team.id).text(team.name + ' (' + team.key + ')'));
+                    team.projects.forEach(function(p) {
+                        $select.append($('<option>').val('project:' + p.id).text('\u00a0\u00a0' + p.name));
+                    });
+                });
+                if (teams && teams.length) {
+                    $('#linearImportGroup').removeClass('hidden');
+                }
+            })
+            .fail(function(xhr) {
+                const msg = xhr.responseJSON?.error || 'Error loading Linear teams';
+                showToast(msg, 'error');
+            });
+    }
+
+    function importLinear(projectId, source) {
+        const [kind, id] = source.split(':');
+        const $btn = $('#btnImportLinear').prop('disabled', true);
+        $.ajax({
+            url: '/api/projects/' + projectId + '/import-linear',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify(kind === 'team' ? { team_id: id } : { project_id: id })
+        })
+        .done(showImportResult)
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error importing from Linear';
+            showToast(msg, 'error');
+        })
+        .always(function() {
+            $btn.prop('disabled', false);
+        });
+    }
+
+    function importTaskFile(projectId, file) {
+        const formData = new FormData();
+        formData.append('file', file);
+        const $btn = $('#btnImportTaskFile').prop('disabled', true);
+        $.ajax({
+            url: '/api/tasks/import?project_id=' + encodeURIComponent(projectId),
+            method: 'POST',
+            data: formData,
+            processData: false,
+            contentType: false
+        })
+        .done(function(report) {
+            let msg = report.created === 1 ? '1 task created' : report.created + ' tasks created';
+            if (report.skipped > 0) msg += ', ' + report.skipped + ' skipped';
+            if (report.failed > 0) {
+                const first = report.rows.find(row => row.status === 'failed');
+                msg += ', ' + report.failed + ' failed (line ' + first.line + ': ' + first.error + ')';
+            }
+            showToast(msg, report.failed > 0 ? 'warning' : 'success');
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error importing tasks';
+            showToast(msg, 'error');
+        })
+        .always(function() {
+            $btn.prop('disabled', false);
+            $('#taskImportFile').val('');
+        });
+    }
+
+    function showImportResult(result) {
+        const count = result.imported.length;
+        let msg = count === 1 ? '1 issue imported' : count + ' issues imported';
+        if (result.skipped > 0) {
+            msg += ', ' + result.skipped + ' already on the board';
+        }
+        showToast(msg, 'success');
+    }
+
+    function loadBranchRules(projectId) {
+        $.get('/api/projects/' + projectId + '/rules')
+            .done(function(data) {
+                branchRules = data || [];
+                renderBranchRules();
+            })
+            .fail(function(xhr) {
+                branchRules = [];
+                renderBranchRules();
+            });
+    }
+
+    function addBranchRule(projectId, pattern) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/rules',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ branch_pattern: pattern })
+        })
+        .done(function(rule) {
+            branchRules.push(rule);
+            renderBranchRules();
             $('#newBranchRule').val('');
         })
         .fail(function(xhr) {
@@ -873,6 +1615,362 @@ $(document).ready(function() {
         });
     }
 
+    function updateBranchRule(ruleId, pattern) {
+        $.ajax({
+            url: '/api/projects/' + currentProjectId + '/rules/' + ruleId,
+            method: 'PUT',
+            contentType: 'application/json',
+            data: JSON.stringify({ branch_pattern: pattern })
+        })
+        .done(function(rule) {
+            branchRules = branchRules.map(r => r.id === rule.id ? rule : r);
+            renderBranchRules();
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error updating';
+            showToast(msg, 'error');
+            renderBranchRules();
+        });
+    }
+
+    function testBranchRules(projectId, branch) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/rules/test',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ branch: branch })
+        })
+        .done(function(result) {
+            const ids = result.matches.map(r => r.id);
+            $('#branchRulesList .branch-rule-tag').each(function() {
+                $(this).toggleClass('matched', ids.includes($(this).data('rule-id')));
+            });
+            $('#branchRuleTestResult').text(result.protected
+                ? result.branch + ' is protected'
+                : result.branch + ' is not protected');
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error testing';
+            showToast(msg, 'error');
+        });
+    }
+
+    function loadSecrets(projectId) {
+        $.get('/api/projects/' + projectId + '/secrets')
+            .done(function(data) {
+                projectSecrets = data.secrets || [];
+                $('#secretsDisabled').toggleClass('hidden', data.enabled);
+                $('#newSecretName, #newSecretValue, #btnAddSecret').prop('disabled', !data.enabled);
+                renderSecrets();
+                $('#secretsGroup').removeClass('hidden');
+            })
+            .fail(function() {
+                $('#secretsGroup').addClass('hidden');
+            });
+    }
+
+    function saveSecret(projectId, secretId, data) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/secrets' + (secretId ? '/' + secretId : ''),
+            method: secretId ? 'PUT' : 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify(data)
+        })
+        .done(function(secret) {
+            projectSecrets = projectSecrets.filter(s => s.id !== secret.id);
+            projectSecrets.push(secret);
+            projectSecrets.sort((a, b) => a.name.localeCompare(b.name));
+            renderSecrets();
+            if (!secretId) {
+                $('#newSecretName, #newSecretValue').val('');
+            }
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error saving secret';
+            showToast(msg, 'error');
+            renderSecrets();
+        });
+    }
+
+    function deleteSecret(projectId, secretId) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/secrets/' + secretId,
+            method: 'DELETE'
+        })
+        .done(function() {
+            projectSecrets = projectSecrets.filter(s => s.id !== secretId);
+            renderSecrets();
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error deleting secret';
+            showToast(msg, 'error');
+        });
+    }
+
+    function renderDeployKey(key) {
+        $('#deployKeyPublic').val(key ? key.public_key : '').toggleClass('hidden', !key);
+        $('#deployKeyFingerprint').text(key ? key.fingerprint : '').toggleClass('hidden', !key);
+        $('#btnGenerateDeployKey').text(key ? 'Replace' : 'Generate');
+        $('#btnCopyDeployKey, #btnRemoveDeployKey').toggleClass('hidden', !key);
+    }
+
+    function loadDeployKey(projectId) {
+        $.get('/api/projects/' + projectId + '/deploy-key')
+            .done(function(key) {
+                renderDeployKey(key);
+                $('#deployKeyGroup').removeClass('hidden');
+            })
+            .fail(function(xhr) {
+                renderDeployKey(null);
+                $('#deployKeyGroup').toggleClass('hidden', xhr.status !== 404);
+            });
+    }
+
+    function generateDeployKey(projectId) {
+        if ($('#deployKeyPublic').val() && !confirm('Replace the deploy key? Pushes fail until the new key is added at the Git host.')) {
+            return;
+        }
+        $.post('/api/projects/' + projectId + '/deploy-key')
+            .done(function(key) {
+                renderDeployKey(key);
+                showToast('Deploy key generated, add the public key at your Git host', 'success');
+            })
+            .fail(function(xhr) {
+                showToast(xhr.responseJSON?.error || 'Error generating deploy key', 'error');
+            });
+    }
+
+    function removeDeployKey(projectId) {
+        if (!confirm('Remove the deploy key? Pushes then use the server\'s own credentials.')) return;
+        $.ajax({
+            url: '/api/projects/' + projectId + '/deploy-key',
+            method: 'DELETE'
+        })
+        .done(function() {
+            renderDeployKey(null);
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Error removing deploy key', 'error');
+        });
+    }
+
+    function copyDeployKey() {
+        const key = $('#deployKeyPublic').val();
+        if (navigator.clipboard && window.isSecureContext) {
+            navigator.clipboard.writeText(key)
+                .then(() => showToast('Public key copied', 'success'))
+                .catch(() => $('#deployKeyPublic').trigger('select'));
+        } else {
+            $('#deployKeyPublic').trigger('select');
+        }
+    }
+
+    function loadEnvironments(projectId) {
+        $.get('/api/projects/' + projectId + '/environments')
+            .done(function(envs) {
+                projectEnvironments = envs || [];
+                renderEnvironments();
+                $('#environmentsGroup').removeClass('hidden');
+            })
+            .fail(function() {
+                $('#environmentsGroup').addClass('hidden');
+            });
+    }
+
+    function saveEnvironment(projectId, envId, data) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/environments' + (envId ? '/' + envId : ''),
+            method: envId ? 'PUT' : 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify(data)
+        })
+        .done(function(env) {
+            projectEnvironments = projectEnvironments.filter(e => e.id !== env.id);
+            projectEnvironments.push(env);
+            projectEnvironments.sort((a, b) => a.name.localeCompare(b.name));
+            renderEnvironments();
+            if (!envId) {
+                $('#newEnvironmentName, #newEnvironmentCommand').val('');
+            }
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error saving environment';
+            showToast(msg, 'error');
+        });
+    }
+
+    function deleteEnvironment(projectId, envId) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/environments/' + envId,
+            method: 'DELETE'
+        })
+        .done(function() {
+            projectEnvironments = projectEnvironments.filter(e => e.id !== envId);
+            renderEnvironments();
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error deleting environment';
+            showToast(msg, 'error');
+        });
+    }
+
+    function loadMCPServers(projectId) {
+        $.get('/api/projects/' + projectId + '/mcp-servers')
+            .done(function(servers) {
+                projectMCPServers = servers || [];
+                renderMCPServers();
+                $('#mcpServersGroup').removeClass('hidden');
+            })
+            .fail(function() {
+                $('#mcpServersGroup').addClass('hidden');
+            });
+    }
+
+    function saveMCPServer(projectId, serverId, data) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/mcp-servers' + (serverId ? '/' + serverId : ''),
+            method: serverId ? 'PUT' : 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify(data)
+        })
+        .done(function(server) {
+            projectMCPServers = projectMCPServers.filter(s => s.id !== server.id);
+            projectMCPServers.push(server);
+            projectMCPServers.sort((a, b) => a.name.localeCompare(b.name));
+            renderMCPServers();
+            if (!serverId) {
+                $('#newMcpName, #newMcpCommand, #newMcpEnv').val('');
+            }
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error saving MCP server';
+            showToast(msg, 'error');
+        });
+    }
+
+    function deleteMCPServer(projectId, serverId) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/mcp-servers/' + serverId,
+            method: 'DELETE'
+        })
+        .done(function() {
+            projectMCPServers = projectMCPServers.filter(s => s.id !== serverId);
+            renderMCPServers();
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error deleting MCP server';
+            showToast(msg, 'error');
+        });
+    }
+
+    // Splits a command line at spaces, keeping quoted parts together
+    function splitCommandLine(line) {
+        const words = [];
+        const re = /"([^"]*)"|'([^']*)'|(\S+)/g;
+        let match;
+        while ((match = re.exec(line)) !== null) {
+            words.push(match[1] ?? match[2] ?? match[3]);
+        }
+        return words;
+    }
+
+    // Command and environment of an MCP server as entered in the form
+    function mcpServerInput(commandLine, envLine) {
+        const words = splitCommandLine(commandLine);
+        const env = {};
+        splitCommandLine(envLine || '').forEach(function(pair) {
+            const i = pair.indexOf('=');
+            if (i > 0) {
+                env[pair.slice(0, i)] = pair.slice(i + 1);
+            }
+        });
+        return { command: words[0] || '', args: words.slice(1), env: env };
+    }
+
+    function mcpCommandLine(server) {
+        return [server.command].concat(server.args || []).map(function(word) {
+            return /\s/.test(word) ? '"' + word + '"' : word;
+        }).join(' ');
+    }
+
+    function loadPathRules(projectId) {
+        $.get('/api/projects/' + projectId + '/paths')
+            .done(function(rules) {
+                pathRules = rules || [];
+                renderPathRules();
+                $('#pathRulesGroup').removeClass('hidden');
+            })
+            .fail(function() {
+                $('#pathRulesGroup').addClass('hidden');
+            });
+    }
+
+    function savePathRule(projectId, ruleId, data) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/paths' + (ruleId ? '/' + ruleId : ''),
+            method: ruleId ? 'PUT' : 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify(data)
+        })
+        .done(function(rule) {
+            pathRules = pathRules.filter(r => r.id !== rule.id);
+            pathRules.push(rule);
+            pathRules.sort((a, b) => a.path_pattern.localeCompare(b.path_pattern));
+            renderPathRules();
+            if (!ruleId) {
+                $('#newPathRule').val('');
+            }
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error saving path rule';
+            showToast(msg, 'error');
+            renderPathRules();
+        });
+    }
+
+    function deletePathRule(projectId, ruleId) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/paths/' + ruleId,
+            method: 'DELETE'
+        })
+        .done(function() {
+            pathRules = pathRules.filter(r => r.id !== ruleId);
+            renderPathRules();
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error deleting path rule';
+            showToast(msg, 'error');
+        });
+    }
+
+    function loadDeployments(projectId) {
+        $.get('/api/projects/' + projectId + '/deployments?limit=10')
+            .done(function(deployments) {
+                renderDeployments(deployments || []);
+                $('#deploymentsGroup').toggleClass('hidden', !deployments || deployments.length === 0);
+            })
+            .fail(function() {
+                $('#deploymentsGroup').addClass('hidden');
+            });
+    }
+
+    function rollbackDeployment(projectId, deploymentId) {
+        $.ajax({
+            url: '/api/projects/' + projectId + '/deployments/' + deploymentId + '/rollback',
+            method: 'POST'
+        })
+        .done(function(deployment) {
+            if (deployment.status === 'running') {
+                showToast('Rolled back and pushed, running deploy commands...', 'info');
+            }
+            loadDeployments(projectId);
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Rollback failed';
+            showToast(msg, 'error');
+        });
+    }
+
     function deleteBranchRule(ruleId) {
         $.ajax({
             url: '/api/projects/' + currentProjectId + '/rules/' + ruleId,
@@ -961,6 +2059,24 @@ $(document).ready(function() {
         });
     }
 
+    // Progress of a manual scan in the scan modal, results of scheduled scans as toasts
+    function handleScanProgress(progress) {
+        if (!progress) return;
+        if (!progress.scheduled) {
+            if (!progress.done && $('#btnStartScan').prop('disabled')) {
+                $('#btnStartScan').text(`Scanning... ${progress.dirs} folders, ${progress.found} found`);
+            }
+            return;
+        }
+        if (!progress.done || progress.error) return;
+        if (progress.created > 0) {
+            showToast(`Found ${progress.created} new project${progress.created === 1 ? '' : 's'} in ${progress.base_path}`, 'success');
+        }
+        if (progress.missing > 0) {
+            showToast(`${progress.missing} project folder${progress.missing === 1 ? ' is' : 's are'} missing`, 'warning');
+        }
+    }
+
     function importScannedProjects() {
         const selected = [];
         $('#scanResultsList input:checked').each(function() {
@@ -1060,6 +2176,23 @@ $(document).ready(function() {
         });
     }
 
+    // Queue the task with the new review comments of its pull request
+    function addressReview(taskId) {
+        const $btn = $('#btnAddressReview').prop('disabled', true);
+        $.post('/api/tasks/' + taskId + '/address-review')
+            .done(function(response) {
+                closeModal();
+                showToast('Task queued to address ' + response.comments + ' review comment(s)', 'success');
+                loadTasks();
+            })
+            .fail(function(xhr) {
+                showToast(xhr.responseJSON?.error || 'Error addressing review', 'error');
+            })
+            .always(function() {
+                $btn.prop('disabled', false);
+            });
+    }
+
     // Continue task by adding it to queue with a message
     function continueTaskWithMessage(taskId, message) {
         const $btn = $('#btnContinueTask');
@@ -1099,6 +2232,17 @@ $(document).ready(function() {
         ws.onopen = function() {
             $('#reconnectBanner').addClass('hidden');
             console.log('WebSocket connected');
+
+            // Only receive board events, plus logs of the task that is open
+            sendWSMessage({ action: 'subscribe', types: BOARD_EVENT_TYPES });
+            if (logSubscriptionTaskId) {
+                sendWSMessage({ action: 'subscribe', task_ids: [logSubscriptionTaskId] });
+            }
+
+            // Replay events missed while disconnected
+            if (wsEpoch) {
+                sendWSMessage({ action: 'resume', epoch: wsEpoch, last_seq: wsLastSeq });
+            }
         };
 
         ws.onclose = function() {
@@ -1122,7 +2266,56 @@ $(document).ready(function() {
         };
     }
 
+    // Message types every client needs; 'log' is only subscribed per open task
+    const BOARD_EVENT_TYPES = [
+        'status', 'task_updated', 'project_updated', 'branch_change',
+        'deployment_success', 'merge_conflict', 'columns_updated', 'queue_reordered',
+        'labels_updated', 'clone_progress', 'bootstrap_progress', 'task_idle', 'sla_alert',
+        'release_updated', 'deployment_updated', 'queue_state', 'queue_cooldown', 'task_triaged',
+        'budget_alert', 'boards_updated', 'project_changed_externally', 'scan_progress'
+    ];
+
+    function sendWSMessage(msg) {
+        if (ws && ws.readyState === WebSocket.OPEN) {
+            ws.send(JSON.stringify(msg));
+        }
+    }
+
+    // Stream logs for taskId only (null = no task logs)
+    function setLogSubscription(taskId) {
+        if (logSubscriptionTaskId === taskId) return;
+        if (logSubscriptionTaskId) {
+            sendWSMessage({ action: 'unsubscribe', task_ids: [logSubscriptionTaskId] });
+        }
+        logSubscriptionTaskId = taskId;
+        if (taskId) {
+            sendWSMessage({ action: 'subscribe', task_ids: [taskId] });
+        }
+    }
+
     function handleWSMessage(msg) {
+        if (msg.type === 'hello') {
+            if (wsEpoch !== msg.epoch) {
+                // First connection or server restart: start counting from the current seq
+                wsEpoch = msg.epoch;
+                wsLastSeq = msg.seq || 0;
+            }
+            return;
+        }
+        if (msg.type === 'resync') {
+            // Gap could not be replayed, reload the board
+            wsEpoch = msg.epoch;
+            wsLastSeq = msg.seq || 0;
+            loadTasks();
+            loadQueueState();
+            return;
+        }
+        if (msg.seq) {
+            // Replayed events can overlap with live ones
+            if (msg.seq <= wsLastSeq) return;
+            wsLastSeq = msg.seq;
+        }
+
         switch (msg.type) {
             case 'log':
                 appendLog(msg.task_id, msg.message);
@@ -1136,6 +2329,8 @@ $(document).ready(function() {
                         statusTask.current_iteration = msg.iteration;
                     }
                     renderAllTasks();
+                    refreshQueueETAs();
+                    if (currentTaskId === msg.task_id) loadTaskRuns(msg.task_id);
                 } else {
                     // Just update badge if status didn't change (e.g., iteration update)
                     updateStatusBadge(msg.task_id, msg.status, msg.iteration);
@@ -1144,10 +2339,64 @@ $(document).ready(function() {
             }
             case 'task_updated':
                 updateTask(msg.task);
+                refreshQueueETAs();
                 break;
             case 'project_updated':
                 updateProject(msg.project);
                 break;
+            case 'project_changed_externally':
+                updateProject(msg.project);
+                // Branch and push status of the header follow edits made in an IDE or terminal
+                if (msg.project.id === selectedProjectFilter) {
+                    updateBranchSelector(msg.project.id);
+                }
+                break;
+            case 'clone_progress':
+                handleCloneProgress(msg.clone);
+                break;
+            case 'scan_progress':
+                handleScanProgress(msg.scan);
+                break;
+            case 'bootstrap_progress':
+                handleBootstrapProgress(msg.bootstrap);
+                break;
+            case 'release_updated':
+                handleReleaseUpdate(msg.release);
+                break;
+            case 'deployment_updated':
+                handleDeploymentUpdate(msg.deployment);
+                break;
+            case 'boards_updated':
+                applyBoards(msg.boards || []);
+                renderAllTasks(); // Queue order and aging sort the queue column
+                break;
+            case 'columns_updated':
+                applyColumns(msg.columns || []);
+                renderBoardColumns();
+                renderAllTasks();
+                renderColumnSettings();
+                break;
+            case 'comment_created':
+            case 'comment_updated':
+            case 'comment_deleted':
+                applyCommentEvent(msg.type, msg.comment);
+                break;
+            case 'labels_updated':
+                applyLabels(msg.labels || []);
+                break;
+            case 'queue_reordered':
+                applyQueueOrder(msg.queue || []);
+                refreshQueueETAs();
+                break;
+            case 'queue_state':
+                renderQueueState(msg.queue_state);
+                refreshQueueETAs();
+                break;
+            case 'queue_cooldown':
+                renderQueueState(msg.queue_state);
+                refreshQueueETAs();
+                showToast(msg.message, 'warning');
+                break;
             case 'branch_change':
                 updateTaskBranch(msg.task_id, msg.branch);
                 break;
@@ -1157,9 +2406,41 @@ $(document).ready(function() {
             case 'merge_conflict':
                 showMergeConflictModal(msg.conflict);
                 break;
+            case 'task_idle':
+                showTaskIdle(msg.task_id, msg.message);
+                break;
+            case 'sla_alert':
+                showSLAAlert(msg.task_id, msg.message);
+                break;
+            case 'task_triaged':
+                showTaskTriaged(msg.task_id, msg.message);
+                break;
+            case 'budget_alert':
+                showToast(msg.message, msg.budget && msg.budget.state === 'exceeded' ? 'error' : 'warning');
+                refreshQueueETAs();
+                break;
         }
     }
 
+    function showTaskIdle(taskId, message) {
+        const task = tasks.find(t => t.id === taskId);
+        const taskTitle = task ? task.title : 'Task';
+        showToast(`${taskTitle}: ${message}`, 'error');
+    }
+
+    function showTaskTriaged(taskId, summary) {
+        const task = tasks.find(t => t.id === taskId);
+        const taskTitle = task ? task.title : 'Task';
+        showToast(`${taskTitle} is blocked: ${summary}`, 'warning');
+    }
+
+    function showSLAAlert(taskId, message) {
+        const task = tasks.find(t => t.id === taskId);
+        const taskTitle = task ? task.title : 'Task';
+        showToast(`${taskTitle}: ${message}`, 'warning');
+        if (currentTaskId === taskId) loadTaskTiming(taskId);
+    }
+
     function showDeploymentSuccess(taskId, message) {
         // Find the task and show success animation
         const task = tasks.find(t => t.id === taskId);
@@ -1170,6 +2451,13 @@ $(document).ready(function() {
     function showMergeConflictModal(conflict) {
         if (!conflict) return;
 
+        // FORGE already queued a task that resolves the conflict
+        if (conflict.resolution_task_id) {
+            const blocked = tasks.find(t => t.id === conflict.task_id);
+            showToast(`Merge conflict in ${blocked ? blocked.title : 'task'}: queued a task to resolve it`, 'warning');
+            return;
+        }
+
         const task = tasks.find(t => t.id === conflict.task_id);
         const taskTitle = task ? task.title : 'Task';
 
@@ -1909,6 +3197,134 @@ git rebase --continue
     }
 
     // Format duration
+    // Compact wall-clock duration, e.g. "45m", "5h 20m", "3d 4h"
+    function formatStay(seconds) {
+        const mins = Math.floor(seconds / 60);
+        if (mins < 60) return `${mins}m`;
+        const hours = Math.floor(mins / 60);
+        if (hours < 24) return `${hours}h ${mins % 60}m`;
+        return `${Math.floor(hours / 24)}d ${hours % 24}h`;
+    }
+
+    // Estimated time from now, e.g. "in 45m" or "in 3d 4h"
+    function formatEta(date) {
+        const seconds = Math.floor((date.getTime() - Date.now()) / 1000);
+        return seconds < 60 ? 'soon' : 'in ~' + formatStay(seconds);
+    }
+
+    // Reloads the estimated start and finish times, which change with every
+    // change of the queue; bursts of events cause a single request
+    let queueEtaTimer = null;
+    function refreshQueueETAs() {
+        clearTimeout(queueEtaTimer);
+        queueEtaTimer = setTimeout(function() {
+            $.get('/api/queue/eta').done(function(etas) {
+                const byTask = {};
+                (etas || []).forEach(eta => { byTask[eta.task_id] = eta; });
+                tasks.forEach(function(task) {
+                    const eta = byTask[task.id] || {};
+                    task.eta_start = eta.eta_start;
+                    task.eta_finish = eta.eta_finish;
+                });
+                renderAllTasks();
+            });
+        }, 1000);
+    }
+
+    function loadTaskTiming(taskId) {
+        $.get('/api/tasks/' + taskId + '/timing')
+            .done(function(timing) {
+                if (currentTaskId !== taskId) return;
+                let current = `${columnName(timing.status)} for ${formatStay(timing.current_seconds)}`;
+                if (timing.sla_hours) current += ` (SLA ${timing.sla_hours}h)`;
+                $('#taskTimingCurrent').text(current).toggleClass('overdue', !!timing.overdue);
+                const $totals = $('#taskTimingTotals').empty();
+                boardColumns.forEach(function(column) {
+                    const seconds = timing.total_seconds[column.status];
+                    if (seconds) $totals.append($('<span>').text(`${column.name}: ${formatStay(seconds)}`));
+                });
+                $('#timingInfoGroup').removeClass('hidden');
+            })
+            .fail(function() {
+                $('#timingInfoGroup').addClass('hidden');
+            });
+    }
+
+    // Every RALPH run of the task, newest first, with links to its logs and transcript
+    function loadTaskRuns(taskId) {
+        $.get('/api/tasks/' + taskId + '/runs')
+            .done(function(runs) {
+                if (currentTaskId !== taskId) return;
+                const $list = $('#taskRunsList').empty();
+                (runs || []).slice().reverse().forEach(function(run) {
+                    const started = new Date(run.started_at);
+                    const end = run.finished_at ? new Date(run.finished_at) : new Date();
+                    const parts = [run.trigger, formatStay((end - started) / 1000)];
+                    if (run.failure_class) parts.push(FAILURE_CLASS_LABELS[run.failure_class] || run.failure_class);
+                    if (run.iterations) parts.push(`${run.iterations} iteration${run.iterations === 1 ? '' : 's'}`);
+                    if (run.input_tokens || run.output_tokens) {
+                        parts.push(`${(run.input_tokens + run.output_tokens).toLocaleString()} tokens`);
+                    }
+                    if (run.start_commit && run.end_commit && run.start_commit !== run.end_commit) {
+                        parts.push(`${run.start_commit.substring(0, 7)}..${run.end_commit.substring(0, 7)}`);
+                    }
+                    const $row = $('<div class="task-run"></div>')
+                        .attr('title', started.toLocaleString() + (run.error ? '\n' + run.error : ''));
+                    $row.append($('<span class="task-run-number"></span>').text('#' + run.number));
+                    $row.append($('<span class="task-run-outcome"></span>').addClass('outcome-' + (run.outcome || 'unknown')).text(run.outcome || 'unknown'));
+                    $row.append($('<span class="task-run-details"></span>').text(parts.join(' · ')));
+                    $row.append($('<a target="_blank">logs</a>').attr('href', run.logs_url));
+                    $row.append($('<a target="_blank">transcript</a>')
+                        .attr('href', `/api/tasks/${taskId}/runs/${run.number}/transcript`)
+                        .attr('title', 'Prompt and conversation of the run'));
+                    if (run.number > 1) {
+                        $row.append($('<a href="#" class="task-run-compare-link">compare</a>')
+                            .attr('title', `Compare with run #${run.number - 1}`)
+                            .on('click', function(e) {
+                                e.preventDefault();
+                                compareTaskRuns(taskId, run.number - 1, run.number);
+                            }));
+                    }
+                    $list.append($row);
+                });
+                $('#taskRunCompare').addClass('hidden').empty();
+                $('#runsInfoGroup').toggleClass('hidden', !runs || runs.length === 0);
+            })
+            .fail(function() {
+                $('#runsInfoGroup').addClass('hidden');
+            });
+    }
+
+    // Shows how run b differs from run a: outcome, iterations, tokens, cost,
+    // duration and the files each of them changed
+    function compareTaskRuns(taskId, a, b) {
+        $.get(`/api/tasks/${taskId}/runs/compare?a=${a}&b=${b}`)
+            .done(function(cmp) {
+                if (currentTaskId !== taskId) return;
+                const signed = (n, text) => (n > 0 ? '+' : '') + text;
+                const tokens = r => r.input_tokens + r.output_tokens;
+                const $box = $('#taskRunCompare').empty().removeClass('hidden');
+                $box.append($('<div class="task-run-compare-title"></div>')
+                    .text(`Run #${b} vs #${a}: outcome ${cmp.outcome_change} (${cmp.a.outcome || 'unknown'} → ${cmp.b.outcome || 'unknown'})`));
+                [
+                    ['Iterations', `${cmp.a.iterations} → ${cmp.b.iterations} (${signed(cmp.iterations_delta, cmp.iterations_delta)})`],
+                    ['Tokens', `${tokens(cmp.a).toLocaleString()} → ${tokens(cmp.b).toLocaleString()} (${signed(cmp.tokens_delta, cmp.tokens_delta.toLocaleString())})`],
+                    ['Cost', `$${cmp.a.cost_usd.toFixed(2)} → $${cmp.b.cost_usd.toFixed(2)} (${signed(cmp.cost_delta_usd, '$' + cmp.cost_delta_usd.toFixed(2))})`],
+                    ['Duration', `${formatStay(cmp.a.duration_seconds)} → ${formatStay(cmp.b.duration_seconds)}`],
+                    ['Files only in #' + a, cmp.a.files_error || cmp.files_only_in_a.join(', ') || '–'],
+                    ['Files only in #' + b, cmp.b.files_error || cmp.files_only_in_b.join(', ') || '–'],
+                    ['Files in both', cmp.files_in_both.join(', ') || '–']
+                ].forEach(function([label, value]) {
+                    $box.append($('<div class="task-run-compare-row"></div>')
+                        .append($('<span></span>').text(label))
+                        .append($('<span></span>').text(value)));
+                });
+            })
+            .fail(function(xhr) {
+                showToast(xhr.responseJSON?.error || 'Failed to compare runs', 'error');
+            });
+    }
+
     function formatDuration(start, end) {
         const diff = Math.floor((end - start) / 1000);
         if (diff < 60) return `${diff}s`;
@@ -2226,7 +3642,10 @@ git rebase --continue
 
     function updateTask(task) {
         const idx = tasks.findIndex(t => t.id === task.id);
-        if (idx !== -1) {
+        if ((task.board_id || 'default') !== currentBoardId) {
+            // Moved to or created on another board
+            if (idx !== -1) tasks.splice(idx, 1);
+        } else if (idx !== -1) {
             tasks[idx] = task;
         } else {
             tasks.push(task);
@@ -2277,26 +3696,94 @@ git rebase --continue
     }
 
     // Rendering
-    function renderAllTasks() {
-        const statuses = ['backlog', 'queued', 'progress', 'review', 'done', 'blocked'];
 
+    // Column display name for a status (falls back to the raw status)
+    function columnName(status) {
+        const column = boardColumns.find(c => c.status === status);
+        return column ? column.name : status;
+    }
+
+    // Runner role of a status ('' for unknown statuses)
+    function columnRole(status) {
+        const column = boardColumns.find(c => c.status === status);
+        return column ? column.role : '';
+    }
+
+    // Build the board columns and mobile tabs from boardColumns
+    function renderBoardColumns() {
+        const $board = $('.board');
+        const $tabs = $('.mobile-column-tabs-inner');
+        $board.empty();
+        $tabs.empty();
+
+        // Keep the active mobile tab if its column still exists
+        if (boardColumns.length && !boardColumns.some(c => c.status === activeMobileTab)) {
+            activeMobileTab = boardColumns[0].status;
+        }
+
+        boardColumns.forEach(function(column) {
+            const active = column.status === activeMobileTab ? ' mobile-active' : '';
+            const addButton = column.status === 'backlog'
+                ? `<button class="btn btn-add" data-status="backlog">+</button>`
+                : '';
+            $board.append(`
+                <div class="column${active}" data-status="${escapeHtml(column.status)}" style="border-top: 3px solid ${escapeHtml(column.color)}">
+                    <div class="column-header">
+                        <h2>${escapeHtml(column.name)}</h2>
+                        <span class="column-count" data-count="${escapeHtml(column.status)}"></span>
+                        ${addButton}
+                    </div>
+                    <div class="tasks-container"></div>
+                </div>
+            `);
+            $tabs.append(`
+                <button class="mobile-tab${column.status === activeMobileTab ? ' active' : ''}" data-status="${escapeHtml(column.status)}">
+                    <span class="mobile-tab-label">${escapeHtml(column.name)}</span>
+                    <span class="mobile-tab-count" data-count="${escapeHtml(column.status)}">0</span>
+                </button>
+            `);
+        });
+    }
+
+    function renderAllTasks() {
         // Update logo icon pulsating state
         updateLogoIconState();
 
-        statuses.forEach(function(status) {
+        boardColumns.forEach(function(column) {
+            const status = column.status;
             const $container = $(`.column[data-status="${status}"] .tasks-container`);
             $container.empty();
 
             let statusTasks = tasks.filter(t => t.status === status);
 
+            // WIP limits count every task in the column, regardless of the project filter
+            const $count = $(`.column-count[data-count="${status}"]`);
+            if (column.wip_limit > 0) {
+                $count.text(statusTasks.length + ' / ' + column.wip_limit)
+                    .toggleClass('wip-exceeded', statusTasks.length > column.wip_limit);
+            } else {
+                $count.text(statusTasks.length || '').removeClass('wip-exceeded');
+            }
+
             // Filter by project if selected
             if (selectedProjectFilter) {
                 statusTasks = statusTasks.filter(t => t.project_id === selectedProjectFilter);
             }
 
-            // Sort queued tasks by queue position
-            if (status === 'queued') {
+            // Filter by label if selected
+            if (selectedLabelFilter) {
+                statusTasks = statusTasks.filter(t => (t.labels || []).some(l => l.id === selectedLabelFilter));
+            }
+
+            // Sort queued tasks by project, every project has its own queue, then by
+            // queue position (priority first if that's the board's queue policy)
+            if (column.role === 'queue') {
+                const board = boards.find(b => b.id === currentBoardId);
                 statusTasks.sort((a, b) => (a.queue_position || 0) - (b.queue_position || 0));
+                if (board && board.queue_policy === 'priority') {
+                    statusTasks.sort((a, b) => effectivePriority(a) - effectivePriority(b));
+                }
+                statusTasks.sort((a, b) => getProjectNameForSearch(a.project_id).localeCompare(getProjectNameForSearch(b.project_id)));
             }
 
             statusTasks.forEach(function(task) {
@@ -2315,7 +3802,7 @@ git rebase --continue
     function setupMobileTabNavigation() {
         // Handle mobile tab clicks
         $(document).on('click', '.mobile-tab', function() {
-            const status = $(this).data('status');
+            const status = $(this).attr('data-status');
             switchMobileTab(status);
         });
 
@@ -2359,140 +3846,627 @@ git rebase --continue
     function buildTaskDropdownItems(task) {
         const items = [];
         // Trunk-based development: Merge option removed
-        // Dropdown now empty - but kept for potential future actions
-        return items;
-    }
-
-    function createTaskCard(task) {
-        const taskType = task.task_type || taskTypes.find(t => t.id === task.task_type_id);
-        const typeBadge = taskType ?
-            `<span class="task-type-badge" style="background-color: ${taskType.color}">${escapeHtml(taskType.name)}</span>` : '';
-
-        // Build status badge HTML
-        let statusBadge = '';
-        if (task.status === 'progress') {
-            const badgeText = task.current_iteration > 0
-                ? `Iteration ${task.current_iteration}`
-                : 'Running...';
-            statusBadge = `<span class="status-badge running">${badgeText}</span>`;
-        } else if (task.status === 'queued') {
-            const queuePos = task.queue_position || '?';
-            statusBadge = `<span class="status-badge queued"><span class="queue-position-badge">${queuePos}</span> QUEUED</span>`;
-        } else if (task.status === 'review') {
-            statusBadge = `<span class="status-badge review">REVIEW</span>`;
-        } else if (task.status === 'done') {
-            statusBadge = `<span class="status-badge done">DONE</span>`;
-        } else if (task.status === 'blocked') {
-            statusBadge = `<span class="status-badge blocked">BLOCKED</span>`;
+        // Plan mode: let RALPH write a plan to approve before the real run
+        if ((task.project_id || task.project_dir) && (task.status === 'backlog' || task.status === 'blocked')) {
+            items.push(`
+                <button class="task-dropdown-item" data-action="plan" data-id="${task.id}">
+                    <svg viewBox="0 0 16 16" fill="currentColor">
+                        <path d="M5 1.75a.75.75 0 0 1 .75-.75h4.5a.75.75 0 0 1 .75.75V2h1.25c.966 0 1.75.784 1.75 1.75v10.5A1.75 1.75 0 0 1 12.25 16h-8.5A1.75 1.75 0 0 1 2 14.25V3.75C2 2.784 2.784 2 3.75 2H5Zm0 1.75H3.75a.25.25 0 0 0-.25.25v10.5c0 .138.112.25.25.25h8.5a.25.25 0 0 0 .25-.25V3.75a.25.25 0 0 0-.25-.25H11v.75a.75.75 0 0 1-.75.75h-4.5A.75.75 0 0 1 5 4.25Zm1.5-1V3.5h3v-1ZM5 7.75A.75.75 0 0 1 5.75 7h4.5a.75.75 0 0 1 0 1.5h-4.5A.75.75 0 0 1 5 7.75Zm0 3a.75.75 0 0 1 .75-.75h2.5a.75.75 0 0 1 0 1.5h-2.5a.75.75 0 0 1-.75-.75Z"/>
+                    </svg>
+                    ${task.plan_status === 'proposed' || task.plan_status === 'approved' ? 'Plan again' : 'Plan first'}
+                </button>`);
         }
-
-        // Trunk-based development: Branch row removed - all tasks work on the same branch
-
-        // Build rollback button for review/blocked tasks with rollback_tag
-        let rollbackButtonHtml = '';
-        if (task.rollback_tag && (task.status === 'review' || task.status === 'blocked')) {
-            rollbackButtonHtml = `
-                <button class="btn-rollback" title="Rollback changes">
+        // Let Claude break a large task into subtasks
+        if (task.status === 'backlog' || task.status === 'blocked') {
+            items.push(`
+                <button class="task-dropdown-item" data-action="split" data-id="${task.id}">
                     <svg viewBox="0 0 16 16" fill="currentColor">
-                        <path d="M9.78 12.78a.75.75 0 0 1-1.06 0L4.47 8.53a.75.75 0 0 1 0-1.06l4.25-4.25a.751.751 0 0 1 1.042.018.751.751 0 0 1 .018 1.042L6.06 8l3.72 3.72a.75.75 0 0 1 0 1.06Z"/>
+                        <path d="M1 2.75C1 1.784 1.784 1 2.75 1h2.5C6.216 1 7 1.784 7 2.75v2.5A1.75 1.75 0 0 1 5.25 7H4.5v3.25c0 .138.112.25.25.25H9v-.75c0-.966.784-1.75 1.75-1.75h2.5c.966 0 1.75.784 1.75 1.75v2.5A1.75 1.75 0 0 1 13.25 14h-2.5A1.75 1.75 0 0 1 9 12.25V12H4.75A1.75 1.75 0 0 1 3 10.25V7h-.25A1.75 1.75 0 0 1 1 5.25Zm1.75-.25a.25.25 0 0 0-.25.25v2.5c0 .138.112.25.25.25h2.5a.25.25 0 0 0 .25-.25v-2.5a.25.25 0 0 0-.25-.25Zm8 8a.25.25 0 0 0-.25.25v2.5c0 .138.112.25.25.25h2.5a.25.25 0 0 0 .25-.25v-2.5a.25.25 0 0 0-.25-.25Z"/>
                     </svg>
-                    Rollback
-                </button>`;
+                    Split into subtasks...
+                </button>`);
         }
-
-        // Build dropdown menu items
-        const dropdownItems = buildTaskDropdownItems(task);
-        const dropdownHtml = dropdownItems.length > 0 ? `
-            <div class="task-card-actions">
-                <button class="task-dropdown-toggle" data-id="${task.id}">
+        // Backport the task's commits onto another branch
+        if (task.project_id && (task.status === 'review' || task.status === 'done')) {
+            items.push(`
+                <button class="task-dropdown-item" data-action="cherry-pick" data-id="${task.id}">
                     <svg viewBox="0 0 16 16" fill="currentColor">
-                        <path d="M8 9a1.5 1.5 0 1 0 0-3 1.5 1.5 0 0 0 0 3ZM1.5 9a1.5 1.5 0 1 0 0-3 1.5 1.5 0 0 0 0 3Zm13 0a1.5 1.5 0 1 0 0-3 1.5 1.5 0 0 0 0 3Z"/>
+                        <path d="M9.5 3.25a2.25 2.25 0 1 1 3 2.122V6A2.5 2.5 0 0 1 10 8.5H6a1 1 0 0 0-1 1v1.128a2.251 2.251 0 1 1-1.5 0V5.372a2.25 2.25 0 1 1 1.5 0v1.836A2.493 2.493 0 0 1 6 7h4a1 1 0 0 0 1-1v-.628A2.25 2.25 0 0 1 9.5 3.25Zm-6 0a.75.75 0 1 0 1.5 0 .75.75 0 0 0-1.5 0Zm8.25-.75a.75.75 0 1 0 0 1.5.75.75 0 0 0 0-1.5ZM4.25 12a.75.75 0 1 0 0 1.5.75.75 0 0 0 0-1.5Z"/>
                     </svg>
-                </button>
-                <div class="task-dropdown-menu" data-task-id="${task.id}">
-                    ${dropdownItems.join('')}
-                </div>
-            </div>
-        ` : '';
-
-        // Build the card with new layout structure
-        // - Header: priority + title
-        // - Badge row: type badge (left) + status badge (right)
-        // - Footer: LIVE button, rollback button, and attachment badge
-        const badgeRowHtml = (typeBadge || statusBadge) ?
-            `<div class="task-card-badges">${typeBadge}<div class="badge-spacer"></div>${statusBadge}</div>` : '';
-
-        const $card = $(`
-            <div class="task-card" data-id="${task.id}" draggable="true">
-                ${dropdownHtml}
-                <div class="task-card-header">
-                    <div class="priority-indicator priority-${task.priority}"></div>
-                    <span class="task-title">${escapeHtml(task.title)}</span>
-                </div>
-                ${badgeRowHtml}
-                <div class="task-card-footer"></div>
-            </div>
-        `);
-
-        // Add LIVE button for running tasks
-        if (task.status === 'progress') {
-            $card.find('.task-card-footer').append(
-                `<button class="btn-live" data-id="${task.id}">LIVE</button>`
-            );
+                    Cherry-pick to branch...
+                </button>`);
         }
-
-        // Add rollback button for review/blocked tasks with rollback tag (Trunk-based development)
-        if (rollbackButtonHtml) {
-            $card.find('.task-card-footer').append(rollbackButtonHtml);
+        // Capture the project's UI again, e.g. once its dev server is up
+        const project = task.project_id && projects.find(p => p.id === task.project_id);
+        if (project && project.screenshot_url && task.status === 'review') {
+            items.push(`
+                <button class="task-dropdown-item" data-action="screenshot" data-id="${task.id}">
+                    <svg viewBox="0 0 16 16" fill="currentColor">
+                        <path d="M16 13.25A1.75 1.75 0 0 1 14.25 15H1.75A1.75 1.75 0 0 1 0 13.25V2.75C0 1.784.784 1 1.75 1h12.5c.966 0 1.75.784 1.75 1.75ZM1.75 2.5a.25.25 0 0 0-.25.25v10.5c0 .138.112.25.25.25h.94l.03-.03 6.077-6.078a1.75 1.75 0 0 1 2.412-.06L14.5 10.31V2.75a.25.25 0 0 0-.25-.25Zm12.5 11a.25.25 0 0 0 .25-.25v-.917l-4.298-3.889a.25.25 0 0 0-.344.009L4.81 13.5ZM7 6a2 2 0 1 1-3.999.001A2 2 0 0 1 7 6ZM5.5 6a.5.5 0 1 0-1 0 .5.5 0 0 0 1 0Z"/>
+                    </svg>
+                    Capture screenshot
+                </button>`);
         }
-
-        // Show attachment badge if task has attachments
-        if (task.attachments && task.attachments.length > 0) {
-            $card.find('.task-card-footer').append(`
-                <span class="attachment-badge" title="${task.attachments.length} attachment${task.attachments.length > 1 ? 's' : ''}">
-                    <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2">
-                        <path d="M21.44 11.05l-9.19 9.19a6 6 0 0 1-8.49-8.49l9.19-9.19a4 4 0 0 1 5.66 5.66l-9.2 9.19a2 2 0 0 1-2.83-2.83l8.49-8.48"/>
+        // Copy the task without its run state; finished tasks can be run again
+        items.push(`
+            <button class="task-dropdown-item" data-action="clone" data-id="${task.id}">
+                <svg viewBox="0 0 16 16" fill="currentColor">
+                    <path d="M0 6.75C0 5.784.784 5 1.75 5h1.5a.75.75 0 0 1 0 1.5h-1.5a.25.25 0 0 0-.25.25v7.5c0 .138.112.25.25.25h7.5a.25.25 0 0 0 .25-.25v-1.5a.75.75 0 0 1 1.5 0v1.5A1.75 1.75 0 0 1 9.25 16h-7.5A1.75 1.75 0 0 1 0 14.25Z"/><path d="M5 1.75C5 .784 5.784 0 6.75 0h7.5C15.216 0 16 .784 16 1.75v7.5A1.75 1.75 0 0 1 14.25 11h-7.5A1.75 1.75 0 0 1 5 9.25Zm1.75-.25a.25.25 0 0 0-.25.25v7.5c0 .138.112.25.25.25h7.5a.25.25 0 0 0 .25-.25v-7.5a.25.25 0 0 0-.25-.25Z"/>
+                </svg>
+                Clone
+            </button>`);
+        if (task.status === 'done') {
+            items.push(`
+                <button class="task-dropdown-item" data-action="rerun" data-id="${task.id}">
+                    <svg viewBox="0 0 16 16" fill="currentColor">
+                        <path d="M1.705 8.005a.75.75 0 0 1 .834.656 5.5 5.5 0 0 0 9.592 2.97l-1.204-1.204a.25.25 0 0 1 .177-.427h3.646a.25.25 0 0 1 .25.25v3.646a.25.25 0 0 1-.427.177l-1.38-1.38A7.002 7.002 0 0 1 1.05 8.84a.75.75 0 0 1 .656-.834ZM8 2.5a5.487 5.487 0 0 0-4.131 1.869l1.204 1.204A.25.25 0 0 1 4.896 6H1.25A.25.25 0 0 1 1 5.75V2.104a.25.25 0 0 1 .427-.177l1.38 1.38A7.002 7.002 0 0 1 14.95 7.16a.75.75 0 0 1-1.49.178A5.5 5.5 0 0 0 8 2.5Z"/>
                     </svg>
-                    ${task.attachments.length}
-                </span>
-            `);
+                    Re-run
+                </button>`);
         }
-
-        // Hide footer if empty
-        if ($card.find('.task-card-footer').children().length === 0) {
-            $card.find('.task-card-footer').addClass('hidden');
+        // Shareable report of the task and its run
+        if (task.status === 'review' || task.status === 'done' || task.status === 'blocked') {
+            items.push(`
+                <button class="task-dropdown-item" data-action="report" data-id="${task.id}">
+                    <svg viewBox="0 0 16 16" fill="currentColor">
+                        <path d="M2.75 14A1.75 1.75 0 0 1 1 12.25v-2.5a.75.75 0 0 1 1.5 0v2.5c0 .138.112.25.25.25h10.5a.25.25 0 0 0 .25-.25v-2.5a.75.75 0 0 1 1.5 0v2.5A1.75 1.75 0 0 1 13.25 14Z"/><path d="M7.25 7.689V2a.75.75 0 0 1 1.5 0v5.689l1.97-1.969a.749.749 0 1 1 1.06 1.06l-3.25 3.25a.749.749 0 0 1-1.06 0L4.22 6.78a.749.749 0 1 1 1.06-1.06l1.97 1.969Z"/>
+                    </svg>
+                    Download report
+                </button>`);
         }
-
-        // Show conflict section if task has a conflict PR open
-        if (task.working_branch && task.conflict_pr_url && task.conflict_pr_number) {
-            $card.append(`
-                <div class="merge-conflict-section">
-                    <div class="merge-conflict-header">
-                        <span class="conflict-icon">&#9888;</span>
-                        <span class="conflict-text">Conflict</span>
-                    </div>
-                    <a href="${escapeHtml(task.conflict_pr_url)}" target="_blank" class="btn-resolve-github">
-                        &#128279; Resolve in GitHub
-                    </a>
-                    <span class="pr-number">#${task.conflict_pr_number}</span>
-                </div>
-            `);
+        if (boards.length > 1) {
+            items.push(`
+                <button class="task-dropdown-item" data-action="move-board" data-id="${task.id}">
+                    <svg viewBox="0 0 16 16" fill="currentColor">
+                        <path d="M8.22 2.97a.75.75 0 0 1 1.06 0l4.25 4.25a.75.75 0 0 1 0 1.06l-4.25 4.25a.751.751 0 0 1-1.042-.018.751.751 0 0 1-.018-1.042l2.97-2.97H3.75a.75.75 0 0 1 0-1.5h7.44L8.22 4.03a.75.75 0 0 1 0-1.06Z"/>
+                    </svg>
+                    Move to board...
+                </button>`);
         }
+        // Read-only link for people without access to the board
+        items.push(`
+            <button class="task-dropdown-item" data-action="share" data-id="${task.id}">
+                <svg viewBox="0 0 16 16" fill="currentColor">
+                    <path d="m7.775 3.275 1.25-1.25a3.5 3.5 0 1 1 4.95 4.95l-2.5 2.5a3.5 3.5 0 0 1-4.95 0 .751.751 0 0 1 .018-1.042.751.751 0 0 1 1.042-.018 1.998 1.998 0 0 0 2.83 0l2.5-2.5a2.002 2.002 0 0 0-2.83-2.83l-1.25 1.25a.751.751 0 0 1-1.042-.018.751.751 0 0 1-.018-1.042Zm-4.69 9.64a1.998 1.998 0 0 0 2.83 0l1.25-1.25a.751.751 0 0 1 1.042.018.751.751 0 0 1 .018 1.042l-1.25 1.25a3.5 3.5 0 1 1-4.95-4.95l2.5-2.5a3.5 3.5 0 0 1 4.95 0 .751.751 0 0 1-.018 1.042.751.751 0 0 1-1.042.018 1.998 1.998 0 0 0-2.83 0l-2.5 2.5a1.998 1.998 0 0 0 0 2.83Z"/>
+                </svg>
+                Copy share link
+            </button>`);
+        return items;
+    }
 
-        return $card;
+    /**
+     * Create a read-only link to a task (valid for 7 days) and copy it
+     */
+    function shareTask(taskId) {
+        $.ajax({
+            url: '/api/tasks/' + taskId + '/share',
+            method: 'POST'
+        })
+        .done(function(share) {
+            const expires = new Date(share.expires_at).toLocaleDateString();
+            if (navigator.clipboard && window.isSecureContext) {
+                navigator.clipboard.writeText(share.url)
+                    .then(() => showToast('Share link copied, valid until ' + expires, 'success'))
+                    .catch(() => prompt('Share link, valid until ' + expires + ':', share.url));
+            } else {
+                prompt('Share link, valid until ' + expires + ':', share.url);
+            }
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Failed to create share link', 'error');
+        });
     }
 
-    function renderProjectList() {
-        const $list = $('.project-list');
-        // Keep "All Projects" item, remove others
-        $list.find('.project-item:not([data-project-id=""]), .project-folder').remove();
+    /**
+     * Move a task to another board
+     */
+    function moveTaskToBoard(taskId) {
+        const others = boards.filter(b => b.id !== currentBoardId);
+        const list = others.map((b, i) => (i + 1) + '. ' + b.name).join('\n');
+        const choice = parseInt(prompt('Move this task to which board?\n\n' + list), 10);
+        const board = others[choice - 1];
+        if (!board) return;
 
-        if (projects.length === 0) {
-            return;
-        }
+        $.ajax({
+            url: '/api/tasks/' + taskId,
+            method: 'PUT',
+            contentType: 'application/json',
+            data: JSON.stringify({ board_id: board.id })
+        })
+        .done(function(task) {
+            updateTask(task);
+            showToast('Task moved to ' + board.name, 'success');
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Error moving task', 'error');
+        });
+    }
+
+    /**
+     * Cherry-pick a task's commits onto another branch
+     */
+    function cherryPickTask(taskId) {
+        const branch = prompt('Cherry-pick the commits of this task onto which branch?');
+        if (!branch || !branch.trim()) return;
+        const push = confirm('Push ' + branch.trim() + ' afterwards?');
+
+        showToast('Cherry-picking...', 'info');
+        $.ajax({
+            url: '/api/tasks/' + taskId + '/cherry-pick',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ target_branch: branch.trim(), push: push })
+        })
+        .done(function(data) {
+            showToast('Cherry-picked ' + data.commits.length + ' commit(s) onto ' + data.branch, 'success');
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Cherry-pick failed', xhr.status === 409 ? 'warning' : 'error');
+        });
+    }
+
+    /**
+     * Capture a screenshot of the task's project and attach it to the task
+     */
+    function captureScreenshot(taskId) {
+        showToast('Capturing screenshot...', 'info');
+        $.ajax({
+            url: '/api/tasks/' + taskId + '/screenshot',
+            method: 'POST'
+        })
+        .done(function(att) {
+            showToast('Screenshot attached: ' + att.filename, 'success');
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Screenshot failed', 'error');
+        });
+    }
+
+    /**
+     * Clone a task, or re-run a finished one: the copy goes straight into the queue
+     */
+    function cloneTask(taskId, rerun) {
+        let data = {};
+        if (!rerun) {
+            const task = tasks.find(t => t.id === taskId);
+            data.copy_attachments = !!(task && task.attachments && task.attachments.length) &&
+                confirm('Copy the attachments too?');
+        }
+        $.ajax({
+            url: '/api/tasks/' + taskId + (rerun ? '/rerun' : '/clone'),
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify(data)
+        })
+        .done(function(task) {
+            showToast(rerun ? 'Queued a new run of "' + task.title + '"' : 'Cloned "' + task.title + '"', 'success');
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || (rerun ? 'Re-run failed' : 'Clone failed'), 'error');
+        });
+    }
+
+    /**
+     * Task assist: Claude drafts acceptance criteria, type, priority and an
+     * iteration estimate; the user decides whether to apply them
+     */
+    let taskSuggestion = null;
+
+    function suggestTaskFields() {
+        const title = $('#taskTitle').val().trim();
+        if (!title) {
+            showToast('Enter a title first', 'warning');
+            return;
+        }
+        const $btn = $('#btnSuggestTask').prop('disabled', true).text('Thinking...');
+        $.ajax({
+            url: '/api/tasks/assist',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({
+                title: title,
+                description: $('#taskDescription').val(),
+                project_id: $('#taskProject').val() || '',
+                project_dir: $('#taskProjectDir').val().trim()
+            })
+        })
+        .done(showTaskSuggestion)
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Failed to get suggestions', 'error');
+        })
+        .always(function() {
+            $btn.prop('disabled', false).text('Suggest');
+        });
+    }
+
+    function showTaskSuggestion(suggestion) {
+        taskSuggestion = suggestion;
+        const priorities = { 1: 'High', 2: 'Medium', 3: 'Low' };
+        const meta = [];
+        if (suggestion.task_type_name) meta.push('Type: ' + suggestion.task_type_name);
+        if (suggestion.priority) meta.push('Priority: ' + priorities[suggestion.priority]);
+        if (suggestion.max_iterations) meta.push('Iterations: ' + suggestion.max_iterations);
+        $('#taskSuggestionCriteria').text(suggestion.acceptance_criteria || '').toggleClass('hidden', !suggestion.acceptance_criteria);
+        $('#taskSuggestionMeta').text(meta.join(' · '));
+        $('#taskSuggestionReason').text(suggestion.reason || '');
+        $('#taskSuggestion').removeClass('hidden');
+    }
+
+    function applyTaskSuggestion() {
+        const suggestion = taskSuggestion;
+        if (!suggestion) return;
+        if (suggestion.acceptance_criteria) $('#taskCriteria').val(suggestion.acceptance_criteria);
+        if (suggestion.task_type_id) $('#taskType').val(suggestion.task_type_id);
+        if (suggestion.priority) $('#taskPriority').val(String(suggestion.priority));
+        if (suggestion.max_iterations) $('#taskMaxIterations').val(suggestion.max_iterations);
+        hideTaskSuggestion();
+    }
+
+    function hideTaskSuggestion() {
+        taskSuggestion = null;
+        $('#taskSuggestion').addClass('hidden');
+    }
+
+    /**
+     * Plan mode: queue a planning run, then edit, approve or discard its plan
+     */
+    function planTask(taskId) {
+        $.post('/api/tasks/' + taskId + '/plan')
+            .done(function() {
+                showToast('Planning run queued', 'success');
+            })
+            .fail(function(xhr) {
+                showToast(xhr.responseJSON?.error || 'Failed to queue planning run', 'error');
+            });
+    }
+
+    function updateTaskPlan(taskId, method, body, message) {
+        $.ajax({
+            url: '/api/tasks/' + taskId + '/plan',
+            method: method,
+            contentType: 'application/json',
+            data: body ? JSON.stringify(body) : undefined
+        })
+        .done(function(task) {
+            showToast(message, 'success');
+            if (task.status === 'queued') {
+                closeModal();
+            } else {
+                renderPlanGroup(task);
+            }
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Failed to update plan', 'error');
+        });
+    }
+
+    function renderPlanGroup(task) {
+        if (!task || !task.plan_status) {
+            $('#taskPlan').val('');
+            $('#planGroup').addClass('hidden');
+            return;
+        }
+        const labels = { planning: 'being written', proposed: 'waiting for approval', approved: 'approved' };
+        // Keep the user's edits when a task update arrives while typing
+        if (!$('#taskPlan').is(':focus')) {
+            $('#taskPlan').val(task.plan || '');
+        }
+        $('#taskPlanStatus').text('(' + (labels[task.plan_status] || task.plan_status) + ')');
+        const editable = task.plan_status !== 'planning' && task.status !== 'progress' && task.status !== 'queued';
+        $('#taskPlan').prop('readonly', !editable);
+        $('#planGroup .plan-actions button').prop('disabled', !editable);
+        $('#planGroup').removeClass('hidden');
+    }
+
+    /**
+     * Task breakdown: Claude proposes subtasks, the user reviews them in the
+     * split modal and FORGE creates them as subtasks of the task
+     */
+    let splitSubtasks = [];
+
+    function proposeSplit(taskId) {
+        showToast('Asking Claude for subtasks, this can take a minute...', 'info');
+        $.post('/api/tasks/' + taskId + '/split')
+            .done(function(proposal) {
+                const task = tasks.find(t => t.id === taskId);
+                openSplitModal(taskId, task ? task.title : '', proposal.subtasks || []);
+            })
+            .fail(function(xhr) {
+                showToast(xhr.responseJSON?.error || 'Failed to propose subtasks', 'error');
+            });
+    }
+
+    function openSplitModal(taskId, title, subtasks) {
+        $('#splitTaskId').val(taskId);
+        $('#splitTaskTitle').text(title);
+        $('#splitQueue').prop('checked', false);
+        splitSubtasks = subtasks.map(sub => ({
+            title: sub.title || '',
+            description: sub.description || '',
+            acceptance_criteria: sub.acceptance_criteria || '',
+            depends_on: sub.depends_on || []
+        }));
+        renderSplitSubtasks();
+        $('#splitModal').addClass('active');
+    }
+
+    function closeSplitModal() {
+        $('#splitModal').removeClass('active');
+        splitSubtasks = [];
+    }
+
+    // Subtasks are numbered from 1 in the modal, the API counts from 0
+    function renderSplitSubtasks() {
+        const $list = $('#splitSubtaskList').empty();
+        splitSubtasks.forEach((sub, i) => {
+            const $row = $(`
+                <div class="split-subtask">
+                    <div class="split-subtask-header">
+                        <span class="split-subtask-number"></span>
+                        <input type="text" class="split-title" placeholder="Title">
+                        <button type="button" class="split-remove" title="Remove subtask">&times;</button>
+                    </div>
+                    <textarea class="split-description" rows="3" placeholder="Description"></textarea>
+                    <textarea class="split-criteria" rows="2" placeholder="Acceptance criteria"></textarea>
+                    <label class="split-depends">After subtasks <input type="text" class="split-depends-on" placeholder="e.g. 1, 2"></label>
+                </div>
+            `).attr('data-index', i);
+            $row.find('.split-subtask-number').text('#' + (i + 1));
+            $row.find('.split-title').val(sub.title);
+            $row.find('.split-description').val(sub.description);
+            $row.find('.split-criteria').val(sub.acceptance_criteria);
+            $row.find('.split-depends-on').val(sub.depends_on.map(d => d + 1).join(', '));
+            $list.append($row);
+        });
+    }
+
+    function readSplitSubtasks() {
+        splitSubtasks = $('#splitSubtaskList .split-subtask').map(function() {
+            const $row = $(this);
+            return {
+                title: $row.find('.split-title').val().trim(),
+                description: $row.find('.split-description').val(),
+                acceptance_criteria: $row.find('.split-criteria').val(),
+                depends_on: $row.find('.split-depends-on').val().split(/[\s,]+/)
+                    .filter(Boolean).map(n => parseInt(n, 10) - 1).filter(n => !isNaN(n))
+            };
+        }).get();
+    }
+
+    function removeSplitSubtask(index) {
+        readSplitSubtasks();
+        splitSubtasks.splice(index, 1);
+        // Renumber the dependencies on the subtasks after the removed one
+        splitSubtasks.forEach(sub => {
+            sub.depends_on = sub.depends_on.filter(d => d !== index).map(d => d > index ? d - 1 : d);
+        });
+        renderSplitSubtasks();
+    }
+
+    function createSubtasks() {
+        readSplitSubtasks();
+        const taskId = $('#splitTaskId').val();
+        $('#btnCreateSubtasks').prop('disabled', true);
+        $.ajax({
+            url: '/api/tasks/' + taskId + '/subtasks',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ subtasks: splitSubtasks, queue: $('#splitQueue').is(':checked') })
+        })
+        .done(function(created) {
+            showToast('Created ' + created.length + ' subtask(s)', 'success');
+            closeSplitModal();
+            loadTasks();
+        })
+        .fail(function(xhr) {
+            showToast(xhr.responseJSON?.error || 'Failed to create subtasks', 'error');
+        })
+        .always(function() {
+            $('#btnCreateSubtasks').prop('disabled', false);
+        });
+    }
+
+    function createTaskCard(task) {
+        const taskType = task.task_type || taskTypes.find(t => t.id === task.task_type_id);
+        const typeBadge = taskType ?
+            `<span class="task-type-badge" style="background-color: ${taskType.color}">${escapeHtml(taskType.name)}</span>` : '';
+
+        // Build status badge HTML
+        let statusBadge = '';
+        if (task.status === 'progress') {
+            const badgeText = task.current_iteration > 0
+                ? `Iteration ${task.current_iteration}`
+                : 'Running...';
+            statusBadge = `<span class="status-badge running">${badgeText}</span>`;
+        } else if (task.status === 'queued') {
+            const queuePos = task.queue_position || '?';
+            statusBadge = `<span class="status-badge queued"><span class="queue-position-badge">${queuePos}</span> QUEUED</span>`;
+        } else if (task.status === 'review') {
+            statusBadge = `<span class="status-badge review">REVIEW</span>`;
+        } else if (task.status === 'done') {
+            statusBadge = `<span class="status-badge done">DONE</span>`;
+        } else if (task.status === 'blocked') {
+            statusBadge = `<span class="status-badge blocked">BLOCKED</span>`;
+        } else if (task.plan_status === 'proposed') {
+            statusBadge = `<span class="status-badge plan">PLAN READY</span>`;
+        }
+
+        // Trunk-based development: Branch row removed - all tasks work on the same branch
+
+        // Build rollback button for review/blocked tasks with rollback_tag
+        let rollbackButtonHtml = '';
+        if (task.rollback_tag && (task.status === 'review' || task.status === 'blocked')) {
+            rollbackButtonHtml = `
+                <button class="btn-rollback" title="Rollback changes">
+                    <svg viewBox="0 0 16 16" fill="currentColor">
+                        <path d="M9.78 12.78a.75.75 0 0 1-1.06 0L4.47 8.53a.75.75 0 0 1 0-1.06l4.25-4.25a.751.751 0 0 1 1.042.018.751.751 0 0 1 .018 1.042L6.06 8l3.72 3.72a.75.75 0 0 1 0 1.06Z"/>
+                    </svg>
+                    Rollback
+                </button>`;
+        }
+
+        // Build dropdown menu items
+        const dropdownItems = buildTaskDropdownItems(task);
+        const dropdownHtml = dropdownItems.length > 0 ? `
+            <div class="task-card-actions">
+                <button class="task-dropdown-toggle" data-id="${task.id}">
+                    <svg viewBox="0 0 16 16" fill="currentColor">
+                        <path d="M8 9a1.5 1.5 0 1 0 0-3 1.5 1.5 0 0 0 0 3ZM1.5 9a1.5 1.5 0 1 0 0-3 1.5 1.5 0 0 0 0 3Zm13 0a1.5 1.5 0 1 0 0-3 1.5 1.5 0 0 0 0 3Z"/>
+                    </svg>
+                </button>
+                <div class="task-dropdown-menu" data-task-id="${task.id}">
+                    ${dropdownItems.join('')}
+                </div>
+            </div>
+        ` : '';
+
+        // Build the card with new layout structure
+        // - Header: priority + title
+        // - Badge row: type badge (left) + status badge (right)
+        // - Footer: LIVE button, rollback button, and attachment badge
+        const badgeRowHtml = (typeBadge || statusBadge) ?
+            `<div class="task-card-badges">${typeBadge}<div class="badge-spacer"></div>${statusBadge}</div>` : '';
+        const labelsHtml = (task.labels && task.labels.length) ?
+            `<div class="task-card-labels">${task.labels.map(l =>
+                `<span class="label-chip" style="background-color: ${escapeHtml(l.color)}">${escapeHtml(l.name)}</span>`
+            ).join('')}</div>` : '';
+        const preview = (task.attachments || []).find(a => a.thumbnail_path);
+        const previewHtml = preview ? `
+            <div class="task-card-preview">
+                <img src="${attachmentThumbnailUrl(preview)}" alt="${escapeHtml(preview.filename)}" loading="lazy" draggable="false">
+                ${preview.mime_type.startsWith('video/') ? '<div class="video-play-overlay"><svg viewBox="0 0 24 24" fill="currentColor"><path d="M8 5v14l11-7z"/></svg></div>' : ''}
+            </div>` : '';
+
+        const $card = $(`
+            <div class="task-card" data-id="${task.id}" draggable="true">
+                ${dropdownHtml}
+                <div class="task-card-header">
+                    <div class="priority-indicator priority-${task.priority}"></div>
+                    <span class="task-title">${escapeHtml(task.title)}</span>
+                </div>
+                ${badgeRowHtml}
+                ${labelsHtml}
+                ${previewHtml}
+                <div class="task-card-footer"></div>
+            </div>
+        `);
+
+        // Add LIVE button for running tasks (PAUSED while the process is stopped)
+        if (task.status === 'progress') {
+            const paused = task.process_status === 'paused';
+            $card.find('.task-card-footer').append(
+                `<button class="btn-live${paused ? ' paused' : ''}" data-id="${task.id}">${paused ? 'PAUSED' : 'LIVE'}</button>`
+            );
+        }
+
+        // Add rollback button for review/blocked tasks with rollback tag (Trunk-based development)
+        if (rollbackButtonHtml) {
+            $card.find('.task-card-footer').append(rollbackButtonHtml);
+        }
+
+        // CI status of the task's commit (see ci.go)
+        if (task.ci_status) {
+            const ciLabels = { pending: 'CI running', success: 'CI passed', failure: 'CI failed' };
+            $card.find('.task-card-footer').append(
+                $('<a class="ci-badge" target="_blank"></a>')
+                    .addClass('ci-' + task.ci_status)
+                    .attr('href', task.ci_url || '#')
+                    .attr('title', 'GitHub checks of ' + (task.commit_hash || '').substring(0, 7))
+                    .text(ciLabels[task.ci_status] || task.ci_status)
+            );
+        }
+
+        // New review comments on the task's pull request (see reviews.go)
+        if (task.review_comments > 0) {
+            $card.find('.task-card-footer').append(
+                $('<span class="review-badge"></span>')
+                    .attr('title', 'New review comments on pull request #' + task.pr_number)
+                    .text(task.review_comments + (task.review_comments === 1 ? ' review comment' : ' review comments'))
+            );
+        }
+
+        // Subtask of an epic, waiting while the tasks it depends on are not done
+        if (task.parent_id || (task.depends_on && task.depends_on.length)) {
+            const parent = tasks.find(t => t.id === task.parent_id);
+            const open = (task.depends_on || []).filter(id => {
+                const dep = tasks.find(t => t.id === id);
+                return dep && dep.status !== 'done';
+            });
+            const $badge = $('<span class="subtask-badge"></span>')
+                .text(open.length ? 'Waits for ' + open.length : 'Subtask')
+                .toggleClass('waiting', open.length > 0);
+            if (parent) $badge.attr('title', 'Part of: ' + parent.title);
+            $card.find('.task-card-footer').append($badge);
+        }
+
+        // Queued again after a transient failure, waiting for its backoff
+        if (task.retry_at) {
+            const retryAt = new Date(task.retry_at);
+            $card.find('.task-card-footer').append($('<span class="retry-badge"></span>')
+                .text('Retry ' + formatEta(retryAt))
+                .attr('title', 'Retried after a transient failure at ' + retryAt.toLocaleString()));
+        }
+
+        // Estimated start of a queued task, estimated finish of a running one
+        if (task.eta_start || task.eta_finish) {
+            const start = task.eta_start && new Date(task.eta_start);
+            const finish = task.eta_finish && new Date(task.eta_finish);
+            const $eta = $('<span class="eta-badge"></span>')
+                .text(start ? 'Starts ' + formatEta(start) : 'Done ' + formatEta(finish))
+                .attr('title', (start ? 'Estimated start: ' + start.toLocaleString() + '\n' : '') +
+                    (finish ? 'Estimated finish: ' + finish.toLocaleString() : ''));
+            $card.find('.task-card-footer').append($eta);
+        }
+
+        // Show attachment badge if task has attachments
+        if (task.attachments && task.attachments.length > 0) {
+            $card.find('.task-card-footer').append(`
+                <span class="attachment-badge" title="${task.attachments.length} attachment${task.attachments.length > 1 ? 's' : ''}">
+                    <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2">
+                        <path d="M21.44 11.05l-9.19 9.19a6 6 0 0 1-8.49-8.49l9.19-9.19a4 4 0 0 1 5.66 5.66l-9.2 9.19a2 2 0 0 1-2.83-2.83l8.49-8.48"/>
+                    </svg>
+                    ${task.attachments.length}
+                </span>
+            `);
+        }
+
+        // Hide footer if empty
+        if ($card.find('.task-card-footer').children().length === 0) {
+            $card.find('.task-card-footer').addClass('hidden');
+        }
+
+        // Show conflict section if task has a conflict PR open
+        if (task.working_branch && task.conflict_pr_url && task.conflict_pr_number) {
+            $card.append(`
+                <div class="merge-conflict-section">
+                    <div class="merge-conflict-header">
+                        <span class="conflict-icon">&#9888;</span>
+                        <span class="conflict-text">Conflict</span>
+                    </div>
+                    <a href="${escapeHtml(task.conflict_pr_url)}" target="_blank" class="btn-resolve-github">
+                        &#128279; Resolve in GitHub
+                    </a>
+                    <span class="pr-number">#${task.conflict_pr_number}</span>
+                </div>
+            `);
+        }
+
+        return $card;
+    }
+
+    function renderProjectList() {
+        const $list = $('.project-list');
+        // Keep "All Projects" item, remove others
+        $list.find('.project-item:not([data-project-id=""]), .project-folder').remove();
+
+        // Only the projects of the current board
+        const boardProjects = projects.filter(p => (p.board_id || 'default') === currentBoardId);
+        if (boardProjects.length === 0) {
+            return;
+        }
 
         // Build tree structure from project paths
-        const tree = buildProjectTree(projects);
+        const tree = buildProjectTree(boardProjects);
 
         // Render the tree
         renderProjectTree($list, tree);
@@ -2561,6 +4535,25 @@ git rebase --continue
         return node;
     }
 
+    // Git badge of a project, or the missing badge if its directory is gone
+    function projectBadgeHtml(project) {
+        if (project.missing_since) {
+            const title = `${project.path} not found since ${formatRelativeTime(new Date(project.missing_since).getTime())}`;
+            return `<span class="project-git-badge missing" title="${escapeHtml(title)}">Missing</span>`;
+        }
+        return project.is_git_repo
+            ? '<span class="project-git-badge git">Git</span>'
+            : '<span class="project-git-badge no-git">No Git</span>';
+    }
+
+    // Lock icon of a project whose repository a git operation holds
+    function repoLockHtml(lock) {
+        if (!lock) return '';
+        let title = `Busy: ${lock.operation} since ${formatRelativeTime(new Date(lock.since).getTime())}`;
+        if (lock.waiting) title += `, ${lock.waiting} waiting`;
+        return `<span class="project-lock" title="${escapeHtml(title)}">&#128274;</span>`;
+    }
+
     // Render tree recursively
     function renderProjectTree($container, node, depth) {
         depth = depth || 0;
@@ -2598,20 +4591,19 @@ git rebase --continue
             folder.projects.forEach(function(project) {
                 const branchHtml = project.current_branch ?
                     `<span class="project-branch">${escapeHtml(project.current_branch)}</span>` : '';
+                const lockHtml = repoLockHtml(project.repo_lock);
                 const icon = project.is_git_repo ? '&#128193;' : '&#128194;';
                 const taskCount = tasks.filter(t => t.project_id === project.id).length;
                 const countHtml = taskCount > 0 ? `<span class="project-task-count">${taskCount}</span>` : '';
 
                 // Git status badge
-                const gitBadge = project.is_git_repo
-                    ? '<span class="project-git-badge git">Git</span>'
-                    : '<span class="project-git-badge no-git">No Git</span>';
+                const gitBadge = projectBadgeHtml(project);
 
                 // Action buttons
                 let actionsHtml = '<div class="project-actions">';
-                if (!project.is_git_repo) {
+                if (!project.is_git_repo && !project.missing_since) {
                     actionsHtml += '<button class="btn btn-small btn-secondary btn-init-git" title="Initialize Git">Init</button>';
-                } else {
+                } else if (project.is_git_repo) {
                     actionsHtml += '<button class="btn btn-small btn-secondary btn-create-repo" title="Create GitHub Repo">+GH</button>';
                 }
                 actionsHtml += '</div>';
@@ -2622,6 +4614,7 @@ git rebase --continue
                         <span class="project-name" title="${escapeHtml(project.name)}">${escapeHtml(project.name)}</span>
                         ${gitBadge}
                         ${branchHtml}
+                        ${lockHtml}
                         ${countHtml}
                         ${actionsHtml}
                     </div>
@@ -2639,20 +4632,19 @@ git rebase --continue
             node.projects.forEach(function(project) {
                 const branchHtml = project.current_branch ?
                     `<span class="project-branch">${escapeHtml(project.current_branch)}</span>` : '';
+                const lockHtml = repoLockHtml(project.repo_lock);
                 const icon = project.is_git_repo ? '&#128193;' : '&#128194;';
                 const taskCount = tasks.filter(t => t.project_id === project.id).length;
                 const countHtml = taskCount > 0 ? `<span class="project-task-count">${taskCount}</span>` : '';
 
                 // Git status badge
-                const gitBadge = project.is_git_repo
-                    ? '<span class="project-git-badge git">Git</span>'
-                    : '<span class="project-git-badge no-git">No Git</span>';
+                const gitBadge = projectBadgeHtml(project);
 
                 // Action buttons
                 let actionsHtml = '<div class="project-actions">';
-                if (!project.is_git_repo) {
+                if (!project.is_git_repo && !project.missing_since) {
                     actionsHtml += '<button class="btn btn-small btn-secondary btn-init-git" title="Initialize Git">Init</button>';
-                } else {
+                } else if (project.is_git_repo) {
                     actionsHtml += '<button class="btn btn-small btn-secondary btn-create-repo" title="Create GitHub Repo">+GH</button>';
                 }
                 actionsHtml += '</div>';
@@ -2663,6 +4655,7 @@ git rebase --continue
                         <span class="project-name" title="${escapeHtml(project.name)}">${escapeHtml(project.name)}</span>
                         ${gitBadge}
                         ${branchHtml}
+                        ${lockHtml}
                         ${countHtml}
                         ${actionsHtml}
                     </div>
@@ -2748,13 +4741,133 @@ git rebase --continue
         }
 
         branchRules.forEach(function(rule) {
+            const exception = rule.branch_pattern.startsWith('!');
             $list.append(`
-                <span class="branch-rule-tag" data-rule-id="${rule.id}">
-                    ${escapeHtml(rule.branch_pattern)}
+                <span class="branch-rule-tag${exception ? ' exception' : ''}" data-rule-id="${rule.id}">
+                    <span class="rule-pattern" title="Click to edit">${escapeHtml(rule.branch_pattern)}</span>
                     <button class="remove-rule" data-rule-id="${rule.id}">&times;</button>
                 </span>
             `);
         });
+        $('#branchRuleTestResult').text('');
+    }
+
+    function renderSecrets() {
+        const $list = $('#secretsList');
+        $list.empty();
+
+        if (projectSecrets.length === 0) {
+            $list.html('<span style="color: var(--text-secondary); font-size: 0.8rem;">No secrets defined</span>');
+            return;
+        }
+
+        projectSecrets.forEach(function(secret) {
+            $list.append(`
+                <div class="secret-row" data-secret-id="${secret.id}">
+                    <label class="checkbox-label" title="Pass to RALPH as environment variable">
+                        <input type="checkbox" class="secret-inject" ${secret.inject ? 'checked' : ''}>
+                        <code>${escapeHtml(secret.name)}</code>
+                    </label>
+                    <span class="secret-value">••••••••</span>
+                    <button type="button" class="btn btn-secondary btn-small secret-replace">Replace</button>
+                    <button type="button" class="remove-rule secret-remove">&times;</button>
+                </div>
+            `);
+        });
+    }
+
+    function renderEnvironments() {
+        const $list = $('#environmentsList');
+        $list.empty();
+
+        if (projectEnvironments.length === 0) {
+            $list.html('<span style="color: var(--text-secondary); font-size: 0.8rem;">No environments, deploys use the deploy commands above</span>');
+            return;
+        }
+
+        projectEnvironments.forEach(function(env) {
+            $list.append(`
+                <div class="secret-row" data-environment-id="${env.id}">
+                    <code>${escapeHtml(env.name)}</code>
+                    <span class="secret-value" style="flex: 1;">${escapeHtml(env.deploy_command.split('\n')[0])}</span>
+                    <button type="button" class="btn btn-secondary btn-small environment-edit">Edit</button>
+                    <button type="button" class="remove-rule environment-remove">&times;</button>
+                </div>
+            `);
+        });
+    }
+
+    function renderMCPServers() {
+        const $list = $('#mcpServersList');
+        $list.empty();
+
+        if (projectMCPServers.length === 0) {
+            $list.html('<span style="color: var(--text-secondary); font-size: 0.8rem;">No MCP servers</span>');
+            return;
+        }
+
+        projectMCPServers.forEach(function(server) {
+            const $row = $(`
+                <div class="secret-row" data-mcp-server-id="${server.id}">
+                    <label class="checkbox-label" title="Pass to Claude">
+                        <input type="checkbox" class="mcp-enabled">
+                        <code></code>
+                    </label>
+                    <span class="secret-value" style="flex: 1;"></span>
+                    <button type="button" class="btn btn-secondary btn-small mcp-edit">Edit</button>
+                    <button type="button" class="remove-rule mcp-remove">&times;</button>
+                </div>
+            `);
+            $row.find('.mcp-enabled').prop('checked', server.enabled);
+            $row.find('code').text(server.name);
+            const envNames = Object.keys(server.env || {});
+            $row.find('.secret-value').text(mcpCommandLine(server) + (envNames.length ? ' (' + envNames.join(', ') + ')' : ''));
+            $list.append($row);
+        });
+    }
+
+    function renderPathRules() {
+        const $list = $('#pathRulesList');
+        $list.empty();
+
+        if (pathRules.length === 0) {
+            $list.html('<span style="color: var(--text-secondary); font-size: 0.8rem;">No protected paths</span>');
+            return;
+        }
+
+        pathRules.forEach(function(rule) {
+            const $row = $(`
+                <div class="secret-row" data-path-rule-id="${rule.id}">
+                    <code style="flex: 1;"></code>
+                    <select class="path-rule-action">
+                        <option value="revert">Revert changes</option>
+                        <option value="block">Block task</option>
+                    </select>
+                    <button type="button" class="remove-rule path-rule-remove">&times;</button>
+                </div>
+            `);
+            $row.find('code').text(rule.path_pattern);
+            $row.find('.path-rule-action').val(rule.action);
+            $list.append($row);
+        });
+    }
+
+    function renderDeployments(deployments) {
+        const $list = $('#deploymentsList');
+        $list.empty();
+
+        deployments.forEach(function(dep) {
+            const label = dep.rollback_of ? 'rollback' : (dep.environment || 'default');
+            const canRollback = dep.status === 'succeeded' && dep.commit_hash;
+            $list.append(`
+                <div class="secret-row" data-deployment-id="${dep.id}">
+                    <code>${escapeHtml(dep.commit_hash.substring(0, 7) || '-')}</code>
+                    <span style="flex: 1;">${escapeHtml(label)} &middot; ${escapeHtml(dep.status.replace('_', ' '))}</span>
+                    <span class="secret-value">${formatRelativeTime(new Date(dep.started_at).getTime())}</span>
+                    ${canRollback ? '<button type="button" class="btn btn-secondary btn-small deployment-rollback">Roll back</button>' : ''}
+                </div>
+            `);
+        });
     }
 
     function renderScanResults() {
@@ -2767,10 +4880,13 @@ git rebase --continue
         }
 
         scannedRepos.forEach(function(repo) {
+            const stack = repo.stack || {};
+            const stackLabel = stack.language ? stack.language + (stack.framework ? ' (' + stack.framework + ')' : '') : '';
             $list.append(`
                 <div class="scan-result-item">
                     <input type="checkbox" checked data-path="${escapeHtml(repo.path)}">
                     <span class="scan-result-path">${escapeHtml(repo.path)}</span>
+                    <span class="scan-result-stack">${escapeHtml(stackLabel)}</span>
                 </div>
             `);
         });
@@ -2817,6 +4933,7 @@ git rebase --continue
             openSettingsModal();
         });
         $('#btnSaveSettings').on('click', saveSettings);
+        $('#btnCompactLogs').on('click', compactTaskLogs);
         $('#btnValidateSettings').on('click', validateSettingsToken);
         $('.settings-close').on('click', closeSettingsModal);
 
@@ -2900,9 +5017,9 @@ git rebase --continue
             $('.theme-toggle-label').text(labels[nextTheme]);
         });
 
-        // Add task buttons
-        $('.btn-add').on('click', function() {
-            const status = $(this).data('status');
+        // Add task buttons (columns are rendered dynamically)
+        $(document).on('click', '.column-header .btn-add', function() {
+            const status = $(this).attr('data-status');
             openNewTaskModal(status);
         });
 
@@ -2976,6 +5093,24 @@ git rebase --continue
 
             if (action === 'merge') {
                 mergeTaskToMain(taskId, $(this));
+            } else if (action === 'cherry-pick') {
+                cherryPickTask(taskId);
+            } else if (action === 'move-board') {
+                moveTaskToBoard(taskId);
+            } else if (action === 'plan') {
+                planTask(taskId);
+            } else if (action === 'split') {
+                proposeSplit(taskId);
+            } else if (action === 'screenshot') {
+                captureScreenshot(taskId);
+            } else if (action === 'clone') {
+                cloneTask(taskId, false);
+            } else if (action === 'rerun') {
+                cloneTask(taskId, true);
+            } else if (action === 'report') {
+                window.location.href = '/api/tasks/' + taskId + '/report?download=true';
+            } else if (action === 'share') {
+                shareTask(taskId);
             }
         });
 
@@ -3013,6 +5148,21 @@ git rebase --continue
                 return;
             }
 
+            if (action === 'cleanup-branches') {
+                if (selectedProjectFilter) {
+                    closeBranchDropdown();
+                    openBranchCleanupModal(selectedProjectFilter);
+                }
+                return;
+            }
+
+            if (action === 'stash' || action === 'pop-stash') {
+                if (selectedProjectFilter) {
+                    updateStash(selectedProjectFilter, action === 'stash' ? 'stash' : 'stash/pop', $(this).attr('data-ref'));
+                }
+                return;
+            }
+
             // Handle branch switch
             const branch = $(this).data('branch');
             if (branch && selectedProjectFilter && !$(this).hasClass('active')) {
@@ -3103,6 +5253,16 @@ git rebase --continue
             openScanModal();
         });
 
+        // Clone project button
+        $('#btnCloneProject').on('click', openCloneModal);
+        $('#btnStartClone').on('click', startClone);
+        $('#btnCancelClone').on('click', closeCloneModal);
+
+        // New project from scratch
+        $('#btnBootstrapProject').on('click', openBootstrapModal);
+        $('#btnStartBootstrap').on('click', startBootstrap);
+        $('#btnCancelBootstrap').on('click', closeBootstrapModal);
+
         // Add task type button
         $('#btnAddTaskType').on('click', function() {
             openNewTaskTypeModal();
@@ -3122,6 +5282,13 @@ git rebase --continue
 
         $('.project-close').on('click', closeProjectModal);
         $('.scan-close').on('click', closeScanModal);
+        $('.branch-cleanup-close, #btnCancelBranchCleanup').on('click', closeBranchCleanupModal);
+        $('#btnDeleteBranches').on('click', deleteSelectedBranches);
+        $('#branchCleanupDays').on('change', loadBranchCleanup);
+        $('.clone-close').on('click', closeCloneModal);
+        $('.profile-close, #btnCancelProfile').on('click', closeProfileModal);
+        $('#btnSaveProfile').on('click', saveProfile);
+        $('.bootstrap-close').on('click', closeBootstrapModal);
         $('.tasktype-close').on('click', closeTaskTypeModal);
         $('.github-close').on('click', closeGithubModal);
         $('.repo-close').on('click', closeCreateRepoModal);
@@ -3136,6 +5303,18 @@ git rebase --continue
         $('#scanModal').on('click', function(e) {
             if (e.target === this) closeScanModal();
         });
+        $('#branchCleanupModal').on('click', function(e) {
+            if (e.target === this) closeBranchCleanupModal();
+        });
+        $('#cloneModal').on('click', function(e) {
+            if (e.target === this) closeCloneModal();
+        });
+        $('#profileModal').on('click', function(e) {
+            if (e.target === this) closeProfileModal();
+        });
+        $('#bootstrapModal').on('click', function(e) {
+            if (e.target === this) closeBootstrapModal();
+        });
         $('#taskTypeModal').on('click', function(e) {
             if (e.target === this) closeTaskTypeModal();
         });
@@ -3157,11 +5336,13 @@ git rebase --continue
                 closeModal();
                 closeProjectModal();
                 closeScanModal();
+                closeBranchCleanupModal();
                 closeTaskTypeModal();
                 closeSettingsModal();
                 closeGithubModal();
                 closeCreateRepoModal();
                 closeDeployModal();
+                closeProfileModal();
             }
         });
 
@@ -3221,11 +5402,219 @@ git rebase --continue
             }
         });
 
-        $(document).on('click', '.remove-rule', function() {
+        $('#btnDetectStack').on('click', function() {
+            if (currentProjectId) {
+                detectProjectStack(currentProjectId);
+            }
+        });
+
+        // GitHub issues
+        $('#btnImportIssues').on('click', function() {
+            if (currentProjectId) {
+                importIssues(currentProjectId);
+            }
+        });
+
+        // CSV / Markdown import
+        $('#btnImportTaskFile').on('click', function() {
+            $('#taskImportFile').trigger('click');
+        });
+        $('#taskImportFile').on('change', function() {
+            const file = this.files[0];
+            if (currentProjectId && file) {
+                importTaskFile(currentProjectId, file);
+            }
+        });
+
+        // Linear
+        $('#btnImportLinear').on('click', function() {
+            const source = $('#linearImportSource').val();
+            if (currentProjectId && source) {
+                importLinear(currentProjectId, source);
+            }
+        });
+
+        // Jira
+        $('#btnImportJira').on('click', function() {
+            if (currentProjectId) {
+                importJira(currentProjectId, $('#jiraImportJql').val().trim());
+            }
+        });
+
+        $(document).on('click', '.branch-rule-tag .remove-rule', function() {
             const ruleId = $(this).data('rule-id');
             deleteBranchRule(ruleId);
         });
 
+        $(document).on('click', '.branch-rule-tag .rule-pattern', function() {
+            const $pattern = $(this);
+            const ruleId = $pattern.closest('.branch-rule-tag').data('rule-id');
+            const rule = branchRules.find(r => r.id === ruleId);
+            if (!rule) return;
+
+            const $input = $('<input type="text" class="rule-edit">').val(rule.branch_pattern);
+            $pattern.replaceWith($input);
+            $input.trigger('focus').on('keydown', function(e) {
+                if (e.key === 'Enter') {
+                    e.preventDefault();
+                    $input.trigger('blur');
+                } else if (e.key === 'Escape') {
+                    e.stopPropagation();
+                    $input.off('blur');
+                    renderBranchRules();
+                }
+            }).on('blur', function() {
+                const pattern = $input.val().trim();
+                if (pattern && pattern !== rule.branch_pattern) {
+                    updateBranchRule(ruleId, pattern);
+                } else {
+                    renderBranchRules();
+                }
+            });
+        });
+
+        // Deploy key
+        $('#btnGenerateDeployKey').on('click', function() {
+            if (currentProjectId) generateDeployKey(currentProjectId);
+        });
+        $('#btnRemoveDeployKey').on('click', function() {
+            if (currentProjectId) removeDeployKey(currentProjectId);
+        });
+        $('#btnCopyDeployKey').on('click', copyDeployKey);
+
+        // Secrets
+        $('#btnAddSecret').on('click', function() {
+            const name = $('#newSecretName').val().trim();
+            const value = $('#newSecretValue').val();
+            if (currentProjectId && name && value) {
+                saveSecret(currentProjectId, null, { name: name, value: value });
+            }
+        });
+
+        $(document).on('change', '.secret-inject', function() {
+            const secretId = $(this).closest('.secret-row').data('secret-id');
+            saveSecret(currentProjectId, secretId, { inject: $(this).is(':checked') });
+        });
+
+        $(document).on('click', '.secret-replace', function() {
+            const secretId = $(this).closest('.secret-row').data('secret-id');
+            const secret = projectSecrets.find(s => s.id === secretId);
+            const value = secret && prompt('New value for ' + secret.name + ':');
+            if (value) {
+                saveSecret(currentProjectId, secretId, { value: value });
+            }
+        });
+
+        // Deploy environments
+        $('#btnAddEnvironment').on('click', function() {
+            const name = $('#newEnvironmentName').val().trim();
+            const command = $('#newEnvironmentCommand').val().trim();
+            if (currentProjectId && name && command) {
+                saveEnvironment(currentProjectId, null, { name: name, deploy_command: command });
+            }
+        });
+
+        $(document).on('click', '.environment-edit', function() {
+            const envId = $(this).closest('.secret-row').data('environment-id');
+            const env = projectEnvironments.find(e => e.id === envId);
+            const command = env && prompt('Deploy command for ' + env.name + ':', env.deploy_command);
+            if (command) {
+                saveEnvironment(currentProjectId, envId, { deploy_command: command });
+            }
+        });
+
+        $(document).on('click', '.environment-remove', function() {
+            const envId = $(this).closest('.secret-row').data('environment-id');
+            const env = projectEnvironments.find(e => e.id === envId);
+            if (env && confirm('Delete environment ' + env.name + '?')) {
+                deleteEnvironment(currentProjectId, envId);
+            }
+        });
+
+        // MCP servers
+        $('#btnAddMcpServer').on('click', function() {
+            const name = $('#newMcpName').val().trim();
+            const input = mcpServerInput($('#newMcpCommand').val(), $('#newMcpEnv').val());
+            if (currentProjectId && name && input.command) {
+                saveMCPServer(currentProjectId, null, Object.assign({ name: name }, input));
+            }
+        });
+
+        $(document).on('change', '.mcp-enabled', function() {
+            const serverId = $(this).closest('.secret-row').data('mcp-server-id');
+            saveMCPServer(currentProjectId, serverId, { enabled: $(this).is(':checked') });
+        });
+
+        $(document).on('click', '.mcp-edit', function() {
+            const serverId = $(this).closest('.secret-row').data('mcp-server-id');
+            const server = projectMCPServers.find(s => s.id === serverId);
+            const commandLine = server && prompt('Command for ' + server.name + ':', mcpCommandLine(server));
+            if (commandLine) {
+                const input = mcpServerInput(commandLine);
+                saveMCPServer(currentProjectId, serverId, { command: input.command, args: input.args });
+            }
+        });
+
+        $(document).on('click', '.mcp-remove', function() {
+            const serverId = $(this).closest('.secret-row').data('mcp-server-id');
+            const server = projectMCPServers.find(s => s.id === serverId);
+            if (server && confirm('Delete MCP server ' + server.name + '?')) {
+                deleteMCPServer(currentProjectId, serverId);
+            }
+        });
+
+        // Protected paths
+        $('#btnAddPathRule').on('click', function() {
+            const pattern = $('#newPathRule').val().trim();
+            if (pattern && currentProjectId) {
+                savePathRule(currentProjectId, null, { path_pattern: pattern, action: $('#newPathRuleAction').val() });
+            }
+        });
+
+        $(document).on('change', '.path-rule-action', function() {
+            const ruleId = $(this).closest('.secret-row').data('path-rule-id');
+            savePathRule(currentProjectId, ruleId, { action: $(this).val() });
+        });
+
+        $(document).on('click', '.path-rule-remove', function() {
+            const ruleId = $(this).closest('.secret-row').data('path-rule-id');
+            deletePathRule(currentProjectId, ruleId);
+        });
+
+        $(document).on('click', '.deployment-rollback', function() {
+            const deploymentId = $(this).closest('.secret-row').data('deployment-id');
+            if (confirm('Commit the files as they were at this deployment, push and deploy again?')) {
+                rollbackDeployment(currentProjectId, deploymentId);
+            }
+        });
+
+        $(document).on('click', '.secret-remove', function() {
+            const secretId = $(this).closest('.secret-row').data('secret-id');
+            const secret = projectSecrets.find(s => s.id === secretId);
+            if (secret && confirm('Delete secret ' + secret.name + '?')) {
+                deleteSecret(currentProjectId, secretId);
+            }
+        });
+
+        $(document).on('click', '.btn-clear-credential', function() {
+            const $btn = $(this);
+            saveCredential($btn.data('credential'), '')
+                .done(function() {
+                    showCredentialInput($($btn.data('input')), false);
+                    showToast('Token removed', 'success');
+                })
+                .fail(function(xhr) {
+                    showToast(xhr.responseJSON?.error || 'Error removing token', 'error');
+                });
+        });
+
+        $('#btnTestBranchRules').on('click', function() {
+            const branch = $('#testBranchName').val().trim();
+            if (currentProjectId && branch) {
+                testBranchRules(currentProjectId, branch);
+            }
+        });
+
         // Scan form
         $('#btnStartScan').on('click', function() {
             const basePath = $('#scanBasePath').val().trim();
@@ -3287,6 +5676,10 @@ git rebase --continue
             }
         });
 
+        $('#btnAddressReview').on('click', function() {
+            addressReview(currentTaskId);
+        });
+
         // Continue Task button (for review/blocked tasks)
         $('#btnContinueTask').on('click', function() {
             const message = $('#continueTaskInput').val().trim();
@@ -3330,8 +5723,9 @@ git rebase --continue
         });
 
         $('#btnParentDir').on('click', function() {
-            if (folderBrowserPath) {
-                loadFolder($('#currentPath').data('parent') || '');
+            const parent = $('#currentPath').data('parent');
+            if (folderBrowserPath && parent) {
+                loadFolder(parent);
             }
         });
 
@@ -3397,11 +5791,19 @@ git rebase --continue
         $('#btnCancelRepo').on('click', closeCreateRepoModal);
         $('#btnCreateRepo').on('click', function() {
             const projectId = $('#createRepoProjectId').val();
-            const repoName = $('#repoName').val().trim();
-            const description = $('#repoDescription').val().trim();
-            const isPrivate = $('#repoPrivate').is(':checked');
-            if (repoName) {
-                createGithubRepo(projectId, repoName, description, isPrivate);
+            const repo = {
+                repo_name: $('#repoName').val().trim(),
+                description: $('#repoDescription').val().trim(),
+                private: $('#repoPrivate').is(':checked'),
+                owner: $('#repoOwner').val().trim(),
+                template: $('#repoTemplate').val().trim(),
+                gitignore: $('#repoGitignore').val().trim(),
+                license: $('#repoLicense').val().trim(),
+                default_branch: $('#repoDefaultBranch').val().trim(),
+                topics: $('#repoTopics').val().split(',').map(t => t.trim()).filter(Boolean)
+            };
+            if (repo.repo_name) {
+                createGithubRepo(projectId, repo);
             }
         });
 
@@ -3410,7 +5812,7 @@ git rebase --continue
         $('#btnConfirmDeploy').on('click', function() {
             const taskId = $('#deployTaskId').val();
             const message = $('#deployCommitMessage').val().trim();
-            deployTask(taskId, message);
+            deployTask(taskId, message, $('#deployEnvironment').val() || '');
         });
 
         // Project action buttons (delegated)
@@ -3441,24 +5843,34 @@ git rebase --continue
             $(this).removeClass('dragging');
         });
 
-        $('.tasks-container').on('dragover', function(e) {
+        $(document).on('dragover', '.tasks-container', function(e) {
             e.preventDefault();
             e.originalEvent.dataTransfer.dropEffect = 'move';
             $(this).addClass('drag-over');
         });
 
-        $('.tasks-container').on('dragleave', function() {
+        $(document).on('dragleave', '.tasks-container', function() {
             $(this).removeClass('drag-over');
         });
 
-        $('.tasks-container').on('drop', function(e) {
+        $(document).on('drop', '.tasks-container', function(e) {
             e.preventDefault();
             $(this).removeClass('drag-over');
 
             const taskId = e.originalEvent.dataTransfer.getData('text/plain');
-            const newStatus = $(this).closest('.column').data('status');
+            const newStatus = $(this).closest('.column').attr('data-status');
             const task = tasks.find(t => t.id === taskId);
 
+            // Dropped within the same queue column: reorder instead of a status change
+            if (task && task.status === newStatus && task.queue_position &&
+                columnRole(newStatus) === 'queue') {
+                const position = queueDropPosition($(this), task, e.originalEvent.clientY);
+                if (position !== task.queue_position) {
+                    moveTaskInQueue(taskId, position);
+                }
+                return;
+            }
+
             if (task && task.status !== newStatus) {
                 task.status = newStatus;
                 renderAllTasks();
@@ -3611,16 +6023,22 @@ git rebase --continue
     // Task Modal Functions
     function openNewTaskModal(status) {
         currentTaskId = null;
+        setLogSubscription(null);
         $('#modalTitle').text('New Task');
         $('#taskId').val('');
         $('#taskTitle').val('');
         $('#taskDescription').val('');
+        showDescriptionEditor();
         $('#taskCriteria').val('');
+        hideTaskSuggestion();
         $('#taskProject').val(selectedProjectFilter || '');
         $('#taskType').val('');
+        renderLabelPicker([]);
         $('#taskPriority').val('2');
         $('#taskMaxIterations').val(config.default_max_iterations || 10);
         $('#taskProjectDir').val('');
+        $('#taskWorkDir').val('');
+        $('#taskEnv').val('');
         $('#taskTargetBranch').html('<option value="">Default</option>');
 
         // Show/hide project dir and load branches based on project selection
@@ -3643,6 +6061,15 @@ git rebase --continue
         $('#logSection').addClass('hidden');
         $('#errorSection').addClass('hidden');
         $('#branchInfoGroup').addClass('hidden');
+        $('#prInfoGroup').addClass('hidden');
+        $('#issueInfoGroup').addClass('hidden');
+        $('#jiraInfoGroup').addClass('hidden');
+        $('#linearInfoGroup').addClass('hidden');
+        $('#timingInfoGroup').addClass('hidden');
+        $('#runsInfoGroup').addClass('hidden');
+        renderPlanGroup(null);
+        $('#commentsSection').addClass('hidden');
+        taskComments = [];
 
         // Clear attachments for new task
         clearAttachmentList();
@@ -3653,16 +6080,23 @@ git rebase --continue
 
     function openEditTaskModal(task) {
         currentTaskId = task.id;
+        setLogSubscription(task.id);
         $('#modalTitle').text('Edit Task');
         $('#taskId').val(task.id);
         $('#taskTitle').val(task.title);
         $('#taskDescription').val(task.description || '');
+        showDescriptionEditor();
         $('#taskCriteria').val(task.acceptance_criteria || '');
+        hideTaskSuggestion();
+        renderPlanGroup(task);
         $('#taskProject').val(task.project_id || '');
         $('#taskType').val(task.task_type_id || '');
+        renderLabelPicker((task.labels || []).map(l => l.id));
         $('#taskPriority').val(task.priority);
         $('#taskMaxIterations').val(task.max_iterations);
         $('#taskProjectDir').val(task.project_dir || '');
+        $('#taskWorkDir').val(task.work_dir || '');
+        $('#taskEnv').val(formatTaskEnv(task.env));
 
         // Show/hide project dir based on project selection
         if (task.project_id) {
@@ -3696,8 +6130,47 @@ git rebase --continue
             $('#branchInfoGroup').addClass('hidden');
         }
 
-        // Load attachments
+        if (task.pr_url) {
+            $('#taskPRLink').attr('href', task.pr_url).text('#' + task.pr_number + ' on GitHub' +
+                (task.merge_commit ? ', merged as ' + task.merge_commit.substring(0, 7) : ''));
+            const canAddress = task.review_comments > 0 && (task.status === 'review' || task.status === 'blocked');
+            $('#btnAddressReview')
+                .toggleClass('hidden', !canAddress)
+                .text('Address review (' + (task.review_comments || 0) + ')');
+            $('#prInfoGroup').removeClass('hidden');
+        } else {
+            $('#prInfoGroup').addClass('hidden');
+        }
+
+        if (task.issue_url) {
+            $('#taskIssueLink').attr('href', task.issue_url).text('#' + task.issue_number + ' on GitHub');
+            $('#issueInfoGroup').removeClass('hidden');
+        } else {
+            $('#issueInfoGroup').addClass('hidden');
+        }
+
+        if (task.jira_key) {
+            const jiraBase = (config.jira_url || '').replace(/\/+$/, '');
+            $('#taskJiraLink').attr('href', jiraBase + '/browse/' + encodeURIComponent(task.jira_key)).text(task.jira_key);
+            $('#jiraInfoGroup').removeClass('hidden');
+        } else {
+            $('#jiraInfoGroup').addClass('hidden');
+        }
+
+        if (task.linear_url) {
+            $('#taskLinearLink').attr('href', task.linear_url).text(task.linear_identifier);
+            $('#linearInfoGroup').removeClass('hidden');
+        } else {
+            $('#linearInfoGroup').addClass('hidden');
+        }
+
+        // Load attachments, comments, time in status and run history
         loadAttachments(task.id);
+        loadComments(task.id);
+        $('#timingInfoGroup').addClass('hidden');
+        loadTaskTiming(task.id);
+        $('#runsInfoGroup').addClass('hidden');
+        loadTaskRuns(task.id);
 
         $('#btnDelete').removeClass('hidden');
 
@@ -3717,11 +6190,14 @@ git rebase --continue
     }
 
     function updateModalForTask(task) {
+        renderPlanGroup(task);
+
         // RALPH controls (pause/resume/stop) - only for running tasks
         if (task.status === 'progress') {
+            const paused = task.process_status === 'paused';
             $('#ralphControls').removeClass('hidden');
-            $('#btnPause').removeClass('hidden');
-            $('#btnResume').addClass('hidden');
+            $('#btnPause').toggleClass('hidden', paused);
+            $('#btnResume').toggleClass('hidden', !paused);
         } else {
             $('#ralphControls').addClass('hidden');
         }
@@ -3740,6 +6216,10 @@ git rebase --continue
         if (task.status === 'review' || task.status === 'blocked') {
             $('#continueTaskSection').removeClass('hidden');
             $('#continueTaskInput').val(''); // Clear previous input
+            // Interrupted by a restart: an empty message resumes with a summary of the work done
+            $('#continueTaskInput').attr('placeholder', task.process_status === 'resumable'
+                ? 'Optional: instructions for RALPH. Leave empty to resume where the restart interrupted the task...'
+                : 'Enter your feedback or instructions for RALPH...');
         } else {
             $('#continueTaskSection').addClass('hidden');
         }
@@ -3789,15 +6269,71 @@ git rebase --continue
         // Error section
         if (task.status === 'blocked' && task.error) {
             $('#errorSection').removeClass('hidden');
+            $('#errorTitle').text(FAILURE_CLASS_LABELS[task.error_class] || 'Error');
             $('#errorMessage').text(task.error);
+            renderTriage(task);
         } else {
             $('#errorSection').addClass('hidden');
         }
+
+        // Comments - "send to RALPH" depends on the status
+        renderComments();
+    }
+
+    // Headings of errors of failed runs, by failure class
+    const FAILURE_CLASS_LABELS = {
+        rate_limit: 'Rate limited',
+        auth: 'Not logged in',
+        oom: 'Out of memory',
+        context_limit: 'Out of context',
+        transient: 'Run failed'
+    };
+
+    const TRIAGE_ACTION_LABELS = {
+        context: 'More context',
+        split: 'Split task',
+        manual: 'Manual step',
+        retry: 'Retry'
+    };
+
+    // Claude's analysis of why the blocked task got stuck (triage.go)
+    function renderTriage(task) {
+        const triage = task.triage;
+        $('#triageBox').toggleClass('hidden', !triage);
+        $('#btnTriageTask').text(triage ? 'Ask again' : 'Ask Claude why').prop('disabled', false);
+        if (!triage) return;
+        $('#triageSummary').text(triage.summary);
+        const $actions = $('#triageActions').empty();
+        (triage.actions || []).forEach(function(action) {
+            $actions.append($('<li></li>')
+                .append($('<span class="triage-kind"></span>').addClass('triage-' + action.kind)
+                    .text(TRIAGE_ACTION_LABELS[action.kind] || action.kind))
+                .append(document.createTextNode(' ' + action.text)));
+        });
+    }
+
+    function triageTask() {
+        if (!currentTaskId) return;
+        const taskId = currentTaskId;
+        $('#btnTriageTask').prop('disabled', true).text('Analyzing...');
+        $.ajax({ url: `/api/tasks/${taskId}/triage`, method: 'POST' })
+            .done(function() {
+                // The task_updated event shows the triage
+            })
+            .fail(function(xhr) {
+                showToast(xhr.responseJSON?.error || 'Failed to triage task', 'error');
+            })
+            .always(function() {
+                if (currentTaskId !== taskId) return;
+                const task = tasks.find(t => t.id === taskId);
+                if (task) renderTriage(task);
+            });
     }
 
     function closeModal() {
         $('#taskModal').removeClass('active');
         currentTaskId = null;
+        setLogSubscription(null);
         clearPendingAttachments(); // Clear any pending attachments when modal closes
         stopTimestampUpdates(); // Stop updating timestamps when modal closes
     }
@@ -3818,16 +6354,23 @@ git rebase --continue
             acceptance_criteria: $('#taskCriteria').val(),
             project_id: projectId || '',
             task_type_id: $('#taskType').val() || '',
+            label_ids: selectedPickerLabels(),
             priority: parseInt($('#taskPriority').val()),
             max_iterations: parseInt($('#taskMaxIterations').val()),
             project_dir: projectDir,
-            target_branch: $('#taskTargetBranch').val() || ''
+            target_branch: $('#taskTargetBranch').val() || '',
+            work_dir: $('#taskWorkDir').val().trim(),
+            env: parseTaskEnv($('#taskEnv').val())
         };
 
         if (!taskData.title) {
             showToast('Title is required', 'error');
             return;
         }
+        if (!taskData.env) {
+            showToast('Environment variables must be NAME=value lines', 'error');
+            return;
+        }
 
         const taskId = $('#taskId').val();
         if (taskId) {
@@ -3837,6 +6380,23 @@ git rebase --continue
         saveTask(taskData);
     }
 
+    // Task environment: one NAME=value per line in the form
+    function formatTaskEnv(env) {
+        return Object.keys(env || {}).sort().map(name => `${name}=${env[name]}`).join('\n');
+    }
+
+    // Returns null if a line is not NAME=value
+    function parseTaskEnv(text) {
+        const env = {};
+        for (const line of text.split('\n')) {
+            if (!line.trim()) continue;
+            const eq = line.indexOf('=');
+            if (eq <= 0) return null;
+            env[line.slice(0, eq).trim()] = line.slice(eq + 1);
+        }
+        return env;
+    }
+
     // Project Modal Functions
     function openNewProjectModal() {
         currentProjectId = null;
@@ -3846,6 +6406,34 @@ git rebase --continue
         $('#projectName').val('');
         $('#projectPath').val('');
         $('#projectDescription').val('');
+        $('#projectWorkflow').val('trunk');
+        $('#projectAutoMerge').val('');
+        $('#projectCommitTemplate').val('');
+        $('#projectConventionalCommits').prop('checked', false);
+        $('#projectSignCommits').prop('checked', false);
+        $('#projectTestCommand, #projectBuildCommand').val('');
+        fillProjectStack(null);
+        $('#projectIssueSync').prop('checked', false);
+        $('#projectPushHook').prop('checked', false);
+        $('#projectDeployCommand').val('');
+        $('#projectDeployTimeout').val('');
+        $('#projectScreenshotUrl').val('');
+        $('#projectScreenshotCommand').val('');
+        fillBudget('#projectBudget', null);
+        fillGitHubEndpoints('#projectGithub', null);
+        fillClaudeSettings('#projectClaude', null);
+        $('#projectBoard').val(currentBoardId);
+        $('#btnImportIssues').addClass('hidden');
+        $('#projectJiraKey').val('');
+        $('#jiraImportJql').val('');
+        $('#jiraImportRow').addClass('hidden');
+        $('#linearImportGroup').addClass('hidden');
+        $('#secretsGroup, #deployKeyGroup').addClass('hidden');
+        $('#environmentsGroup, #deploymentsGroup, #pathRulesGroup, #mcpServersGroup').addClass('hidden');
+        projectSecrets = [];
+        projectEnvironments = [];
+        projectMCPServers = [];
+        pathRules = [];
         renderBranchRules();
         $('#btnDeleteProject').addClass('hidden');
         $('#projectModal').addClass('active');
@@ -3858,22 +6446,127 @@ git rebase --continue
         $('#projectName').val(project.name);
         $('#projectPath').val(project.path);
         $('#projectDescription').val(project.description || '');
+        $('#projectWorkflow').val(project.workflow || 'trunk');
+        $('#projectAutoMerge').val(project.auto_merge || '');
+        $('#projectCommitTemplate').val(project.commit_template || '');
+        $('#projectConventionalCommits').prop('checked', !!project.conventional_commits);
+        $('#projectSignCommits').prop('checked', !!project.sign_commits);
+        $('#projectTestCommand').val(project.test_command || '');
+        $('#projectBuildCommand').val(project.build_command || '');
+        fillProjectStack(project);
+        $('#projectIssueSync').prop('checked', !!project.issue_sync);
+        $('#projectPushHook').prop('checked', !!project.push_hook);
+        $('#projectDeployCommand').val(project.deploy_command || '');
+        $('#projectDeployTimeout').val(project.deploy_timeout || '');
+        $('#projectScreenshotUrl').val(project.screenshot_url || '');
+        $('#projectScreenshotCommand').val(project.screenshot_command || '');
+        fillBudget('#projectBudget', project.budget);
+        fillGitHubEndpoints('#projectGithub', project.github);
+        fillClaudeSettings('#projectClaude', project.claude);
+        $('#projectBoard').val(project.board_id || 'default');
+        $('#btnImportIssues').removeClass('hidden');
+        $('#projectJiraKey').val(project.jira_project_key || '');
+        $('#jiraImportJql').val('');
+        $('#jiraImportRow').removeClass('hidden');
+        loadLinearSources();
         loadBranchRules(project.id);
+        loadPathRules(project.id);
+        loadSecrets(project.id);
+        loadDeployKey(project.id);
+        loadEnvironments(project.id);
+        loadMCPServers(project.id);
+        loadDeployments(project.id);
         $('#btnDeleteProject').removeClass('hidden');
         $('#projectModal').addClass('active');
     }
 
+    // Detected stack: summary line, and the detected commands as placeholders
+    function fillProjectStack(project) {
+        const stack = (project && project.stack) || {};
+        let summary = 'Detected when the project is created';
+        if (stack.detected_at) {
+            const parts = [];
+            if (stack.language) parts.push(stack.language + (stack.framework ? ' (' + stack.framework + ')' : ''));
+            if (stack.repo_size) parts.push(formatFileSize(stack.repo_size) + ' repository');
+            summary = parts.length ? parts.join(', ') : 'No language detected';
+        }
+        $('#projectStackSummary').text(summary);
+        $('#projectTestCommand').attr('placeholder', stack.test_command || 'Test command');
+        $('#projectBuildCommand').attr('placeholder', stack.build_command || 'Build command');
+        $('#btnDetectStack').toggleClass('hidden', !project);
+    }
+
+    function detectProjectStack(projectId) {
+        const $btn = $('#btnDetectStack').prop('disabled', true);
+        $.post('/api/projects/' + projectId + '/detect')
+            .done(function(project) {
+                updateProject(project);
+                if (currentProjectId === project.id) {
+                    fillProjectStack(project);
+                }
+                showToast('Stack detected', 'success');
+            })
+            .fail(function(xhr) {
+                const msg = xhr.responseJSON?.error || 'Error detecting stack';
+                showToast(msg, 'error');
+            })
+            .always(function() {
+                $btn.prop('disabled', false);
+            });
+    }
+
     function closeProjectModal() {
         $('#projectModal').removeClass('active');
         currentProjectId = null;
         branchRules = [];
     }
 
+    // Claude invocation settings share their inputs in settings and project form
+    function fillClaudeSettings(prefix, settings) {
+        settings = settings || {};
+        $(prefix + 'PermissionMode').val(settings.permission_mode || '');
+        $(prefix + 'AllowedTools').val((settings.allowed_tools || []).join(', '));
+        $(prefix + 'DisallowedTools').val((settings.disallowed_tools || []).join(', '));
+        $(prefix + 'ExtraArgs').val(settings.extra_args || '');
+        $(prefix + 'AppendSystemPrompt').val(settings.append_system_prompt || '');
+    }
+
+    function readClaudeSettings(prefix) {
+        const tools = function(input) {
+            return $(input).val().split(',').map(function(tool) { return tool.trim(); }).filter(Boolean);
+        };
+        return {
+            permission_mode: $(prefix + 'PermissionMode').val(),
+            allowed_tools: tools(prefix + 'AllowedTools'),
+            disallowed_tools: tools(prefix + 'DisallowedTools'),
+            extra_args: $(prefix + 'ExtraArgs').val().trim(),
+            append_system_prompt: $(prefix + 'AppendSystemPrompt').val().trim()
+        };
+    }
+
     function submitProjectForm() {
         const projectData = {
             name: $('#projectName').val().trim(),
             path: $('#projectPath').val().trim(),
-            description: $('#projectDescription').val()
+            description: $('#projectDescription').val(),
+            workflow: $('#projectWorkflow').val(),
+            auto_merge: $('#projectAutoMerge').val(),
+            commit_template: $('#projectCommitTemplate').val().trim(),
+            conventional_commits: $('#projectConventionalCommits').is(':checked'),
+            sign_commits: $('#projectSignCommits').is(':checked'),
+            test_command: $('#projectTestCommand').val().trim(),
+            build_command: $('#projectBuildCommand').val().trim(),
+            issue_sync: $('#projectIssueSync').is(':checked'),
+            push_hook: $('#projectPushHook').is(':checked'),
+            deploy_command: $('#projectDeployCommand').val().trim(),
+            deploy_timeout: parseInt($('#projectDeployTimeout').val(), 10) || 0,
+            screenshot_url: $('#projectScreenshotUrl').val().trim(),
+            screenshot_command: $('#projectScreenshotCommand').val().trim(),
+            budget: readBudget('#projectBudget'),
+            github: readGitHubEndpoints('#projectGithub'),
+            board_id: $('#projectBoard').val() || currentBoardId,
+            jira_project_key: $('#projectJiraKey').val().trim(),
+            claude: readClaudeSettings('#projectClaude')
         };
 
         if (!projectData.name || !projectData.path) {
@@ -3905,44 +6598,242 @@ git rebase --continue
         scannedRepos = [];
     }
 
-    // Task Type Modal Functions
-    function openNewTaskTypeModal() {
-        currentTaskTypeId = null;
-        $('#taskTypeModalTitle').text('New Task Type');
-        $('#taskTypeId').val('');
-        $('#taskTypeName').val('');
-        $('#taskTypeColor').val('#58a6ff');
-        $('#taskTypeColorPreview').css('background-color', '#58a6ff');
-        $('#btnDeleteTaskType').addClass('hidden');
-        $('#taskTypeModal').addClass('active');
+    // Clone Modal Functions
+    function openCloneModal() {
+        activeCloneId = null;
+        $('#cloneUrl, #cloneBranch, #cloneName').val('').prop('disabled', false);
+        $('#cloneProgress').addClass('hidden');
+        $('#btnStartClone').prop('disabled', false);
+        $('#cloneModal').addClass('active');
     }
 
-    function openEditTaskTypeModal(type) {
-        currentTaskTypeId = type.id;
-        $('#taskTypeModalTitle').text('Edit Task Type');
-        $('#taskTypeId').val(type.id);
-        $('#taskTypeName').val(type.name);
-        $('#taskTypeColor').val(type.color);
-        $('#taskTypeColorPreview').css('background-color', type.color);
+    // The clone keeps running on the server; the project appears once it is done
+    function closeCloneModal() {
+        $('#cloneModal').removeClass('active');
+        activeCloneId = null;
+    }
 
-        // Can't delete system types
-        if (type.is_system) {
-            $('#btnDeleteTaskType').addClass('hidden');
-        } else {
-            $('#btnDeleteTaskType').removeClass('hidden');
+    function startClone() {
+        const url = $('#cloneUrl').val().trim();
+        if (!url) {
+            showToast('Repository URL is required', 'error');
+            return;
         }
 
-        $('#taskTypeModal').addClass('active');
+        $('#btnStartClone').prop('disabled', true);
+        $.ajax({
+            url: '/api/projects/clone',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({
+                url: url,
+                branch: $('#cloneBranch').val().trim(),
+                name: $('#cloneName').val().trim()
+            })
+        })
+        .done(function(data) {
+            activeCloneId = data.clone_id;
+            $('#cloneUrl, #cloneBranch, #cloneName').prop('disabled', true);
+            renderCloneProgress(data);
+        })
+        .fail(function(xhr) {
+            $('#btnStartClone').prop('disabled', false);
+            const msg = xhr.responseJSON?.error || 'Error starting clone';
+            showToast(msg, 'error');
+        });
     }
 
-    function closeTaskTypeModal() {
-        $('#taskTypeModal').removeClass('active');
-        currentTaskTypeId = null;
+    function handleCloneProgress(progress) {
+        if (!progress) return;
+        if (progress.done) {
+            if (progress.error) {
+                showToast('Clone failed: ' + progress.error, 'error');
+            } else if (progress.project) {
+                updateProject(progress.project);
+                showToast('Cloned ' + progress.project.name, 'success');
+            }
+        }
+        if (progress.clone_id !== activeCloneId) return;
+
+        renderCloneProgress(progress);
+        if (progress.done) {
+            if (progress.error) {
+                activeCloneId = null;
+                $('#cloneUrl, #cloneBranch, #cloneName').prop('disabled', false);
+                $('#btnStartClone').prop('disabled', false);
+            } else {
+                closeCloneModal();
+            }
+        }
     }
 
-    function submitTaskTypeForm() {
-        const typeData = {
-            name: $('#taskTypeName').val().trim(),
+    function renderCloneProgress(progress) {
+        $('#cloneProgress').removeClass('hidden');
+        $('#cloneProgress .clone-progress-fill').css('width', (progress.percent || 0) + '%');
+        const text = progress.error || ((progress.phase || 'Starting') + (progress.percent ? ' ' + progress.percent + '%' : ''));
+        $('#cloneProgress .clone-progress-text').text(text).toggleClass('error', !!progress.error);
+    }
+
+    // Bootstrap Modal Functions
+    const BOOTSTRAP_STEPS = [
+        ['directory', 'Create directory'],
+        ['git_init', 'Initialize git'],
+        ['project', 'Register project'],
+        ['task', 'Generate starter structure (RALPH)'],
+        ['commit', 'Commit'],
+        ['github', 'Create GitHub repository'],
+        ['push', 'Push']
+    ];
+
+    function openBootstrapModal() {
+        activeBootstrapId = null;
+        $('#bootstrapForm').removeClass('hidden');
+        $('#bootstrapSteps').addClass('hidden').empty();
+        $('#bootstrapName, #bootstrapDescription, #bootstrapInstructions').val('');
+        $('#bootstrapGithub').prop('checked', false);
+        $('#btnStartBootstrap').removeClass('hidden').prop('disabled', false);
+        $('#bootstrapModal').addClass('active');
+
+        $.get('/api/projects/bootstrap').done(function(templates) {
+            const $select = $('#bootstrapTemplate').empty();
+            (templates || []).forEach(function(t) {
+                $select.append($('<option>').val(t.id).text(t.name));
+            });
+        });
+    }
+
+    // The bootstrap keeps running on the server; progress is shown as toasts then
+    function closeBootstrapModal() {
+        $('#bootstrapModal').removeClass('active');
+        activeBootstrapId = null;
+    }
+
+    function startBootstrap() {
+        const name = $('#bootstrapName').val().trim();
+        if (!name) {
+            showToast('Name is required', 'error');
+            return;
+        }
+
+        $('#btnStartBootstrap').prop('disabled', true);
+        $.ajax({
+            url: '/api/projects/bootstrap',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({
+                name: name,
+                description: $('#bootstrapDescription').val().trim(),
+                template: $('#bootstrapTemplate').val(),
+                instructions: $('#bootstrapInstructions').val().trim(),
+                create_github_repo: $('#bootstrapGithub').is(':checked'),
+                private: $('#bootstrapPrivate').is(':checked')
+            })
+        })
+        .done(function(data) {
+            activeBootstrapId = data.bootstrap_id;
+            $('#bootstrapForm').addClass('hidden');
+            $('#btnStartBootstrap').addClass('hidden');
+            renderBootstrapSteps(data, $('#bootstrapGithub').is(':checked'));
+        })
+        .fail(function(xhr) {
+            $('#btnStartBootstrap').prop('disabled', false);
+            const msg = xhr.responseJSON?.error || 'Error creating project';
+            showToast(msg, 'error');
+        });
+    }
+
+    function handleBootstrapProgress(progress) {
+        if (!progress) return;
+        if (progress.project) updateProject(progress.project);
+        if (progress.done) {
+            if (progress.error) {
+                showToast('Creating ' + progress.name + ' failed: ' + progress.error, 'error');
+            } else {
+                showToast('Project ' + progress.name + ' is ready', 'success');
+            }
+        }
+        if (progress.bootstrap_id !== activeBootstrapId) return;
+        renderBootstrapSteps(progress, $('#bootstrapGithub').is(':checked'));
+    }
+
+    function handleReleaseUpdate(release) {
+        if (!release) return;
+        if (release.status === 'published') {
+            showToast('Released ' + release.tag, 'success');
+        } else if (release.status === 'failed') {
+            showToast('Release ' + release.tag + ' failed: ' + release.error, 'error');
+        }
+    }
+
+    function handleDeploymentUpdate(deployment) {
+        if (!deployment) return;
+        if (currentProjectId === deployment.project_id && $('#projectModal').hasClass('active')) {
+            loadDeployments(deployment.project_id);
+        }
+        // Task deploys report through the task itself; rollbacks have no task
+        if (deployment.task_id || deployment.status === 'running') return;
+        const target = deployment.environment || 'default';
+        if (deployment.status === 'succeeded') {
+            showToast('Rolled back ' + target + ' to ' + deployment.commit_hash.substring(0, 7), 'success');
+        } else {
+            showToast('Rollback of ' + target + ' ' + deployment.status.replace('_', ' '), 'error');
+        }
+    }
+
+    function renderBootstrapSteps(progress, withGithub) {
+        const current = BOOTSTRAP_STEPS.findIndex(s => s[0] === progress.step);
+        const $list = $('#bootstrapSteps').empty().removeClass('hidden');
+        BOOTSTRAP_STEPS.forEach(function(step, i) {
+            if (!withGithub && (step[0] === 'github' || step[0] === 'push')) return;
+            let state = 'pending';
+            if (progress.step === 'done' || i < current) state = 'done';
+            else if (i === current) state = progress.error ? 'failed' : 'active';
+            const $item = $('<li>').addClass(state).text(step[1]);
+            if (i === current && (progress.error || progress.message)) {
+                $item.append($('<div class="bootstrap-step-message">').text(progress.error || progress.message));
+            }
+            $list.append($item);
+        });
+    }
+
+    // Task Type Modal Functions
+    function openNewTaskTypeModal() {
+        currentTaskTypeId = null;
+        $('#taskTypeModalTitle').text('New Task Type');
+        $('#taskTypeId').val('');
+        $('#taskTypeName').val('');
+        $('#taskTypeColor').val('#58a6ff');
+        $('#taskTypeColorPreview').css('background-color', '#58a6ff');
+        $('#btnDeleteTaskType').addClass('hidden');
+        $('#taskTypeModal').addClass('active');
+    }
+
+    function openEditTaskTypeModal(type) {
+        currentTaskTypeId = type.id;
+        $('#taskTypeModalTitle').text('Edit Task Type');
+        $('#taskTypeId').val(type.id);
+        $('#taskTypeName').val(type.name);
+        $('#taskTypeColor').val(type.color);
+        $('#taskTypeColorPreview').css('background-color', type.color);
+
+        // Can't delete system types
+        if (type.is_system) {
+            $('#btnDeleteTaskType').addClass('hidden');
+        } else {
+            $('#btnDeleteTaskType').removeClass('hidden');
+        }
+
+        $('#taskTypeModal').addClass('active');
+    }
+
+    function closeTaskTypeModal() {
+        $('#taskTypeModal').removeClass('active');
+        currentTaskTypeId = null;
+    }
+
+    function submitTaskTypeForm() {
+        const typeData = {
+            name: $('#taskTypeName').val().trim(),
             color: $('#taskTypeColor').val()
         };
 
@@ -4044,9 +6935,15 @@ git rebase --continue
                 selectedFolderPath = data.current_path;
                 $('#currentPath').val(data.current_path);
                 $('#currentPath').data('parent', data.parent_path);
+                $('#btnParentDir').prop('disabled', !data.parent_path);
                 renderFolderList(data.directories, data.is_repo);
             })
             .fail(function(xhr) {
+                // Start over at the default folder if the path is outside the allowed roots
+                if (xhr.status === 403 && path && config.browse_enabled) {
+                    loadFolder('');
+                    return;
+                }
                 const msg = xhr.responseJSON?.error || 'Error loading';
                 showToast(msg, 'error');
             });
@@ -4097,14 +6994,8 @@ git rebase --continue
     // ============================================================================
 
     function saveGithubToken(token) {
-        $.ajax({
-            url: '/api/config',
-            method: 'PUT',
-            contentType: 'application/json',
-            data: JSON.stringify({ github_token: token })
-        })
-        .done(function(data) {
-            config = data;
+        saveCredential('github', token)
+        .done(function() {
             showToast('GitHub token saved', 'success');
             closeGithubModal();
         })
@@ -4122,12 +7013,7 @@ git rebase --continue
         }
 
         // Temporarily save and validate
-        $.ajax({
-            url: '/api/config',
-            method: 'PUT',
-            contentType: 'application/json',
-            data: JSON.stringify({ github_token: token })
-        })
+        saveCredential('github', token)
         .done(function() {
             $.post('/api/github/validate')
                 .done(function(data) {
@@ -4161,205 +7047,939 @@ git rebase --continue
             });
     }
 
-    function createGithubRepo(projectId, repoName, description, isPrivate) {
-        $('#btnCreateRepo').prop('disabled', true).text('Creating...');
+    function createGithubRepo(projectId, repo) {
+        $('#btnCreateRepo').prop('disabled', true).text('Creating...');
+
+        $.ajax({
+            url: '/api/projects/' + projectId + '/github-repo',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify(repo)
+        })
+        .done(function(data) {
+            showToast('GitHub repository created: ' + data.repo_url, 'success');
+            (data.warnings || []).forEach(function(warning) {
+                showToast(warning, 'warning');
+            });
+            closeCreateRepoModal();
+            loadProjects();
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error creating';
+            showToast(msg, 'error');
+        })
+        .always(function() {
+            $('#btnCreateRepo').prop('disabled', false).text('Create Repository');
+        });
+    }
+
+    function deployTask(taskId, commitMessage, environment) {
+        $('#btnConfirmDeploy').prop('disabled', true);
+        $('#deployStatus').removeClass('hidden');
+
+        $.ajax({
+            url: '/api/tasks/' + taskId + '/deploy',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ commit_message: commitMessage, environment: environment })
+        })
+        .done(function(data) {
+            const commit = data.commit_hash ? ' Commit: ' + data.commit_hash.substring(0, 7) : '';
+            if (data.deployment && data.deployment.status === 'running') {
+                showToast('Pushed, running deploy commands...' + commit, 'info');
+            } else {
+                showToast('Deployment successful!' + commit, 'success');
+            }
+            closeDeployModal();
+            loadTasks();
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Deployment failed';
+            showToast(msg, 'error');
+            $('#deployStatus').addClass('hidden');
+        })
+        .always(function() {
+            $('#btnConfirmDeploy').prop('disabled', false);
+        });
+    }
+
+    // ============================================================================
+    // Merge to Main Functions
+    // ============================================================================
+
+    function mergeTaskToMain(taskId, $element) {
+        const task = tasks.find(t => t.id === taskId);
+        if (!task) return;
+
+        // Show toast to indicate action started
+        showToast('Merging branch...');
+
+        // If element is a button with visible state, update it
+        const isButton = $element && $element.hasClass('btn-merge');
+        let originalText = '';
+        if (isButton) {
+            originalText = $element.html();
+            $element.html('<span class="spinner-small"></span> Merging...');
+            $element.prop('disabled', true);
+            $element.addClass('merging');
+        }
+
+        $.ajax({
+            url: '/api/tasks/' + taskId + '/merge',
+            type: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({})
+        })
+        .done(function(data) {
+            if (data.success) {
+                // Success!
+                if (isButton) {
+                    $element.html('&#10003; Merged');
+                    $element.removeClass('merging').addClass('merged');
+                }
+                showToast('Merged successfully!', 'success');
+
+                // Reload tasks to update UI (branch badge gone, merge option gone)
+                setTimeout(function() {
+                    loadTasks();
+                }, 1000);
+            } else if (data.conflict && data.pr_url) {
+                // Conflict - PR was created
+                showToast('Conflict detected - PR created', 'warning');
+
+                // Update task in local state
+                const idx = tasks.findIndex(t => t.id === taskId);
+                if (idx !== -1) {
+                    tasks[idx].conflict_pr_url = data.pr_url;
+                    tasks[idx].conflict_pr_number = data.pr_number;
+                }
+
+                // Re-render to show conflict state
+                renderAllTasks();
+            }
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Merge failed';
+            showToast(msg, 'error');
+
+            // Restore button if applicable
+            if (isButton) {
+                $element.html(originalText);
+                $element.prop('disabled', false);
+                $element.removeClass('merging');
+            }
+        });
+    }
+
+    // GitHub Modal Functions
+    function openGithubModal() {
+        showCredentialInput($('#githubToken'), config.github_token_set);
+        $('#githubStatus').addClass('hidden');
+        $('#githubModal').addClass('active');
+    }
+
+    function closeGithubModal() {
+        $('#githubModal').removeClass('active');
+    }
+
+    function openCreateRepoModal(projectId) {
+        const project = projects.find(p => p.id === projectId);
+        if (!project) return;
+
+        $('#createRepoProjectId').val(projectId);
+        $('#repoName').val(project.name.toLowerCase().replace(/\s+/g, '-'));
+        $('#repoDescription').val(project.description || '');
+        $('#repoPrivate').prop('checked', false);
+        $('#repoOwner, #repoTemplate, #repoGitignore, #repoLicense, #repoDefaultBranch, #repoTopics').val('');
+        $('#createRepoModal').addClass('active');
+    }
+
+    function closeCreateRepoModal() {
+        $('#createRepoModal').removeClass('active');
+    }
+
+    function openDeployModal(taskId) {
+        const task = tasks.find(t => t.id === taskId);
+        if (!task) return;
+
+        $('#deployTaskId').val(taskId);
+        $('#deployTaskTitle').text(task.title);
+        $('#deployCommitMessage').val('Deploy: ' + task.title);
+        $('#deployStatus').addClass('hidden');
+        $('#deployEnvironment').empty();
+        $('#deployEnvironmentGroup').addClass('hidden');
+        if (task.project_id) {
+            $.get('/api/projects/' + task.project_id + '/environments').done(function(envs) {
+                if (!envs || envs.length === 0) return;
+                const $select = $('#deployEnvironment').append('<option value="">Default deploy commands</option>');
+                envs.forEach(env => $select.append($('<option>').val(env.name).text(env.name)));
+                $('#deployEnvironmentGroup').removeClass('hidden');
+            });
+        }
+        $('#deployModal').addClass('active');
+    }
+
+    function closeDeployModal() {
+        $('#deployModal').removeClass('active');
+    }
+
+    // ============================================================================
+    // Settings Modal Functions
+    // ============================================================================
+
+    // Cost budgets: the inputs <prefix>Daily and <prefix>Monthly
+    function readBudget(prefix) {
+        return {
+            daily_usd: parseFloat($(prefix + 'Daily').val()) || 0,
+            monthly_usd: parseFloat($(prefix + 'Monthly').val()) || 0
+        };
+    }
+
+    function fillBudget(prefix, budget) {
+        budget = budget || {};
+        $(prefix + 'Daily').val(budget.daily_usd || '');
+        $(prefix + 'Monthly').val(budget.monthly_usd || '');
+    }
+
+    function readGitHubEndpoints(prefix) {
+        return {
+            web_url: $(prefix + 'WebUrl').val().trim(),
+            api_url: $(prefix + 'ApiUrl').val().trim()
+        };
+    }
+
+    function fillGitHubEndpoints(prefix, endpoints) {
+        endpoints = endpoints || {};
+        $(prefix + 'WebUrl').val(endpoints.web_url || '');
+        $(prefix + 'ApiUrl').val(endpoints.api_url || '');
+    }
+
+    // Shows what every budget has used up in the current day or month
+    function loadBudgetStatus() {
+        const $status = $('#settingsBudgetStatus').empty();
+        $.get('/api/stats/budgets').done(function(budgets) {
+            $status.html((budgets || []).map(function(b) {
+                const name = b.scope === 'global' ? 'All projects' : b.project_name;
+                const state = b.state === 'ok' ? '' : ` (${b.state})`;
+                return escapeHtml(`${name}, ${b.period}: $${b.spent_usd.toFixed(2)} of $${b.limit_usd.toFixed(2)}${state}`);
+            }).join('<br>'));
+        });
+    }
+
+    function openSettingsModal() {
+        // Populate form fields from config
+        $('#settingsProjectDir').val(config.default_project_dir || '');
+        $('#settingsClaudeCommand').val(config.claude_command || 'claude');
+        $('#settingsMaxIterations').val(config.default_max_iterations || 10);
+        showCredentialInput($('#settingsGithubToken'), config.github_token_set);
+        $('#settingsDefaultBranch').val(config.default_branch || 'main');
+        $('#settingsDefaultPriority').val(config.default_priority || 2);
+        $('#settingsAutoArchive').val(config.auto_archive_days || 0);
+        $('#settingsMaxConcurrent').val(config.max_concurrent_tasks || 1);
+        $('#settingsBlockedTriage').prop('checked', config.blocked_triage !== false);
+        $('#settingsGithubUserTokens').prop('checked', !!config.github_user_tokens);
+        fillGitHubEndpoints('#settingsGithub', config.github);
+        $('#settingsSigningFormat').val((config.signing && config.signing.format) || '');
+        $('#settingsSigningKey').val((config.signing && config.signing.key) || '');
+        $('#settingsAttachmentTypes').val(config.attachment_types || '');
+        $('#settingsJiraUrl').val(config.jira_url || '');
+        $('#settingsJiraUser').val(config.jira_user || '');
+        fillClaudeSettings('#settingsClaude', config.claude);
+        const retention = config.log_retention || {};
+        $('#settingsLogMaxSize').val(retention.max_size_kb || '');
+        $('#settingsLogCompressDays').val(retention.compress_after_days || '');
+        $('#settingsLogPurgeDays').val(retention.purge_after_days || '');
+        const retry = config.retry_policy || {};
+        $('#settingsRetryMax').val(retry.max_retries || 0);
+        $('#settingsRetryBackoff').val(retry.backoff_seconds || '');
+        fillBudget('#settingsBudget', config.budget);
+        loadBudgetStatus();
+        showCredentialInput($('#settingsJiraToken'), config.jira_token_set);
+        showCredentialInput($('#settingsLinearToken'), config.linear_token_set);
+
+        // Set theme radio button based on saved preference
+        const savedTheme = getSavedTheme();
+        $('input[name="themeChoice"]').prop('checked', false);
+        $('input[name="themeChoice"][value="' + savedTheme + '"]').prop('checked', true);
+
+        // Reset token input to password type
+        $('#settingsGithubToken').attr('type', 'password');
+        $('#btnToggleToken').text('Show');
+
+        // Reset to first tab
+        $('.settings-tab').removeClass('active');
+        $('.settings-tab[data-tab="general"]').addClass('active');
+        $('.settings-content').removeClass('active');
+        $('#settings-general').addClass('active');
+
+        // Clear GitHub status
+        $('#settingsGithubStatus').addClass('hidden');
+
+        $('#settingsModal').addClass('active');
+    }
+
+    function closeSettingsModal() {
+        $('#settingsModal').removeClass('active');
+    }
+
+    // ============================================================================
+    // Board Column Settings
+    // ============================================================================
+
+    const COLUMN_ROLE_LABELS = { '': 'Manual', 'queue': 'Queue', 'progress': 'In Progress', 'terminal': 'Done' };
+
+    function renderColumnSettings() {
+        const $list = $('#boardColumnList');
+        if (!$list.length) return;
+
+        $list.html(boardColumns.map(function(column, idx) {
+            const roleOptions = Object.keys(COLUMN_ROLE_LABELS).map(role =>
+                `<option value="${role}" ${column.role === role ? 'selected' : ''}>${COLUMN_ROLE_LABELS[role]}</option>`
+            ).join('');
+            return `
+                <div class="board-column-row" data-status="${escapeHtml(column.status)}">
+                    <input type="color" class="column-color" value="${escapeHtml(column.color)}" title="Color">
+                    <input type="text" class="column-name" value="${escapeHtml(column.name)}" title="Name">
+                    <input type="number" class="column-wip" value="${column.wip_limit || 0}" min="0" title="WIP limit">
+                    <input type="number" class="column-sla" value="${column.sla_hours || 0}" min="0" title="SLA in hours">
+                    <select class="column-role" title="Role" ${column.is_system ? 'disabled' : ''}>${roleOptions}</select>
+                    <button type="button" class="btn btn-small btn-secondary column-move" data-dir="-1" ${idx === 0 ? 'disabled' : ''} title="Move left">&#8592;</button>
+                    <button type="button" class="btn btn-small btn-secondary column-move" data-dir="1" ${idx === boardColumns.length - 1 ? 'disabled' : ''} title="Move right">&#8594;</button>
+                    ${column.is_system ? '' : '<button type="button" class="btn btn-small btn-danger column-delete" title="Delete column">&times;</button>'}
+                </div>
+            `;
+        }).join(''));
+    }
+
+    function updateColumn(status, data) {
+        $.ajax({
+            url: '/api/columns/' + encodeURIComponent(status),
+            method: 'PUT',
+            contentType: 'application/json',
+            data: JSON.stringify(data)
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error updating column';
+            showToast(msg, 'error');
+            renderColumnSettings();
+        });
+    }
+
+    function moveColumn(status, dir) {
+        const order = boardColumns.map(c => c.status);
+        const idx = order.indexOf(status);
+        const target = idx + dir;
+        if (idx === -1 || target < 0 || target >= order.length) return;
+        order.splice(idx, 1);
+        order.splice(target, 0, status);
+
+        $.ajax({
+            url: '/api/boards/' + encodeURIComponent(currentBoardId) + '/columns',
+            method: 'PUT',
+            contentType: 'application/json',
+            data: JSON.stringify({ statuses: order })
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error reordering columns';
+            showToast(msg, 'error');
+        });
+    }
+
+    function addColumn() {
+        const name = $('#newColumnName').val().trim();
+        if (!name) {
+            showToast('Column name is required', 'error');
+            return;
+        }
+        // Derive the status key from the name, e.g. "Code Review" -> "code-review"
+        let status = name.toLowerCase().replace(/[^a-z0-9]+/g, '-').replace(/^[^a-z]+|-+$/g, '').substring(0, 32);
+        if (!status) {
+            showToast('Column name must contain a letter', 'error');
+            return;
+        }
+        // Status keys are unique across boards; suffix the board if another board has the key
+        if (allBoardColumns.some(c => c.status === status && c.board_id && c.board_id !== currentBoardId)) {
+            const suffix = '-' + currentBoardId.replace(/[^a-z0-9]/g, '').substring(0, 4);
+            status = status.substring(0, 32 - suffix.length) + suffix;
+        }
+
+        $.ajax({
+            url: '/api/boards/' + encodeURIComponent(currentBoardId) + '/columns',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({
+                status: status,
+                name: name,
+                color: $('#newColumnColor').val(),
+                role: $('#newColumnRole').val()
+            })
+        })
+        .done(function() {
+            $('#newColumnName').val('');
+            showToast('Column added', 'success');
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error adding column';
+            showToast(msg, 'error');
+        });
+    }
+
+    function deleteColumn(status) {
+        if (!confirm('Delete column "' + columnName(status) + '"?')) return;
+
+        $.ajax({
+            url: '/api/columns/' + encodeURIComponent(status),
+            method: 'DELETE'
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error deleting column';
+            showToast(msg, 'error');
+        });
+    }
+
+    // ============================================================================
+    // Labels
+    // ============================================================================
+
+    // Take over a new label list (load or labels_updated) and patch the tasks' copies
+    function applyLabels(newLabels) {
+        const previous = selectedPickerLabels();
+        labels = newLabels;
+
+        const byId = {};
+        labels.forEach(l => { byId[l.id] = l; });
+        tasks.forEach(function(task) {
+            if (task.labels) {
+                task.labels = task.labels.map(l => byId[l.id]).filter(Boolean);
+            }
+        });
+        if (selectedLabelFilter && !byId[selectedLabelFilter]) {
+            selectedLabelFilter = '';
+        }
+
+        renderLabelFilter();
+        renderLabelSettings();
+        renderLabelPicker(previous.filter(id => byId[id]));
+        renderAllTasks();
+    }
+
+    function renderLabelFilter() {
+        const $select = $('#labelFilter');
+        $select.find('option:not(:first)').remove();
+        labels.forEach(function(label) {
+            $select.append(`<option value="${escapeHtml(label.id)}">${escapeHtml(label.name)}</option>`);
+        });
+        $select.val(selectedLabelFilter);
+        $select.toggleClass('hidden', labels.length === 0);
+    }
+
+    // Label chips in the task form; selectedIds are highlighted
+    function renderLabelPicker(selectedIds) {
+        const $picker = $('#taskLabels');
+        if (!labels.length) {
+            $picker.html('<span class="label-picker-empty">No labels yet (Settings &rarr; Board)</span>');
+            return;
+        }
+        $picker.html(labels.map(label => `
+            <button type="button" class="label-chip ${selectedIds.includes(label.id) ? 'selected' : ''}"
+                    data-label-id="${escapeHtml(label.id)}" style="background-color: ${escapeHtml(label.color)}">${escapeHtml(label.name)}</button>
+        `).join(''));
+    }
+
+    function selectedPickerLabels() {
+        return $('#taskLabels .label-chip.selected').map(function() {
+            return $(this).attr('data-label-id');
+        }).get();
+    }
+
+    function renderLabelSettings() {
+        const $list = $('#labelList');
+        if (!$list.length) return;
+
+        $list.html(labels.map(label => `
+            <div class="board-column-row label-row" data-label-id="${escapeHtml(label.id)}">
+                <input type="color" class="label-color" value="${escapeHtml(label.color)}" title="Color">
+                <input type="text" class="label-name" value="${escapeHtml(label.name)}" title="Name">
+                <button type="button" class="btn btn-small btn-danger label-delete" title="Delete label">&times;</button>
+            </div>
+        `).join(''));
+    }
+
+    function updateLabel(id, data) {
+        $.ajax({
+            url: '/api/labels/' + encodeURIComponent(id),
+            method: 'PUT',
+            contentType: 'application/json',
+            data: JSON.stringify(data)
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error updating label';
+            showToast(msg, 'error');
+            renderLabelSettings();
+        });
+    }
+
+    function addLabel() {
+        const name = $('#newLabelName').val().trim();
+        if (!name) {
+            showToast('Label name is required', 'error');
+            return;
+        }
+
+        $.ajax({
+            url: '/api/labels',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ name: name, color: $('#newLabelColor').val() })
+        })
+        .done(function() {
+            $('#newLabelName').val('');
+            showToast('Label added', 'success');
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error adding label';
+            showToast(msg, 'error');
+        });
+    }
+
+    function deleteLabel(id) {
+        const label = labels.find(l => l.id === id);
+        if (!label || !confirm('Delete label "' + label.name + '"? It is removed from all tasks.')) return;
+
+        $.ajax({
+            url: '/api/labels/' + encodeURIComponent(id),
+            method: 'DELETE'
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error deleting label';
+            showToast(msg, 'error');
+        });
+    }
+
+    function setupLabelSettings() {
+        $(document).on('change', '.label-row .label-name', function() {
+            const name = $(this).val().trim();
+            if (!name) {
+                renderLabelSettings();
+                return;
+            }
+            updateLabel($(this).closest('.label-row').attr('data-label-id'), { name: name });
+        });
+        $(document).on('change', '.label-row .label-color', function() {
+            updateLabel($(this).closest('.label-row').attr('data-label-id'), { color: $(this).val() });
+        });
+        $(document).on('click', '.label-row .label-delete', function() {
+            deleteLabel($(this).closest('.label-row').attr('data-label-id'));
+        });
+        $('#btnAddLabel').on('click', addLabel);
+
+        $(document).on('click', '#taskLabels .label-chip', function() {
+            $(this).toggleClass('selected');
+        });
+        $('#labelFilter').on('change', function() {
+            selectedLabelFilter = $(this).val();
+            renderAllTasks();
+        });
+    }
+
+    // ============================================================================
+    // Boards
+    // ============================================================================
+
+    function loadBoards() {
+        $.get('/api/boards')
+            .done(function(data) {
+                applyBoards(data || []);
+            })
+            .fail(function(xhr) {
+                showToast('Error loading boards', 'error');
+            });
+    }
+
+    // Take over a new board list (load or boards_updated); falls back to the
+    // default board if the current one is gone
+    function applyBoards(newBoards) {
+        boards = newBoards;
+        if (!boards.some(b => b.id === currentBoardId)) {
+            switchBoard('default');
+        }
+
+        const options = boards.map(b => `<option value="${escapeHtml(b.id)}">${escapeHtml(b.name)}</option>`).join('');
+        const project = $('#projectBoard').val();
+        $('#boardSelect').html(options).val(currentBoardId).toggleClass('hidden', boards.length < 2);
+        $('#projectBoard').html(options).val(project || currentBoardId);
+        renderBoardSettings();
+    }
+
+    // Keep the columns of all boards and show those of the current one
+    function applyColumns(columns) {
+        allBoardColumns = columns;
+        boardColumns = columns.filter(c => !c.board_id || c.board_id === currentBoardId);
+    }
+
+    function switchBoard(boardId) {
+        if (boardId === currentBoardId) return;
+        currentBoardId = boardId;
+        saveSelectedBoard(boardId);
+        $('#boardSelect').val(boardId);
+
+        // The project filter only applies to projects of the board
+        const project = projects.find(p => p.id === selectedProjectFilter);
+        if (project && (project.board_id || 'default') !== boardId) {
+            selectProject('', false);
+        }
+        applyColumns(allBoardColumns);
+        renderBoardColumns();
+        renderColumnSettings();
+        renderProjectList();
+        tasks = [];
+        renderAllTasks();
+        loadTasks();
+    }
 
+    const QUEUE_POLICY_LABELS = { 'fifo': 'First in, first out', 'priority': 'Highest priority first', 'round_robin': 'Round robin across projects' };
+
+    function renderBoardSettings() {
+        const $list = $('#boardList');
+        if (!$list.length) return;
+
+        $list.html(boards.map(board => `
+            <div class="board-column-row board-row" data-board-id="${escapeHtml(board.id)}">
+                <input type="text" class="board-name" value="${escapeHtml(board.name)}" title="Name">
+                <select class="board-policy" title="Queue order">${Object.keys(QUEUE_POLICY_LABELS).map(policy =>
+                    `<option value="${policy}" ${(board.queue_policy || 'fifo') === policy ? 'selected' : ''}>${QUEUE_POLICY_LABELS[policy]}</option>`
+                ).join('')}</select>
+                <input type="number" class="board-max" value="${board.max_concurrent_tasks || 0}" min="0" title="Max running tasks">
+                <input type="number" class="board-aging" value="${board.priority_aging_hours || 0}" min="0" title="Priority aging: with highest priority first, a queued task rises one level for every that many hours it waits (0 = off)">
+                <span class="help-text">${board.task_count} tasks, ${board.project_count} projects</span>
+                ${board.id === 'default' ? '' : '<button type="button" class="btn btn-small btn-danger board-delete" title="Delete board">&times;</button>'}
+            </div>
+        `).join(''));
+    }
+
+    function updateBoard(id, data) {
         $.ajax({
-            url: '/api/projects/' + projectId + '/github-repo',
-            method: 'POST',
+            url: '/api/boards/' + encodeURIComponent(id),
+            method: 'PUT',
             contentType: 'application/json',
-            data: JSON.stringify({
-                repo_name: repoName,
-                description: description,
-                private: isPrivate
-            })
-        })
-        .done(function(data) {
-            showToast('GitHub repository created: ' + data.repo_url, 'success');
-            closeCreateRepoModal();
-            loadProjects();
+            data: JSON.stringify(data)
         })
         .fail(function(xhr) {
-            const msg = xhr.responseJSON?.error || 'Error creating';
+            const msg = xhr.responseJSON?.error || 'Error updating board';
             showToast(msg, 'error');
-        })
-        .always(function() {
-            $('#btnCreateRepo').prop('disabled', false).text('Create Repository');
+            renderBoardSettings();
         });
     }
 
-    function deployTask(taskId, commitMessage) {
-        $('#btnConfirmDeploy').prop('disabled', true);
-        $('#deployStatus').removeClass('hidden');
+    function addBoard() {
+        const name = $('#newBoardName').val().trim();
+        if (!name) {
+            showToast('Board name is required', 'error');
+            return;
+        }
 
         $.ajax({
-            url: '/api/tasks/' + taskId + '/deploy',
+            url: '/api/boards',
             method: 'POST',
             contentType: 'application/json',
-            data: JSON.stringify({ commit_message: commitMessage })
+            data: JSON.stringify({ name: name })
         })
-        .done(function(data) {
-            const msg = 'Deployment successful!' + (data.commit_hash ? ' Commit: ' + data.commit_hash.substring(0, 7) : '');
-            showToast(msg, 'success');
-            closeDeployModal();
-            loadTasks();
+        .done(function() {
+            $('#newBoardName').val('');
+            showToast('Board added', 'success');
         })
         .fail(function(xhr) {
-            const msg = xhr.responseJSON?.error || 'Deployment failed';
+            const msg = xhr.responseJSON?.error || 'Error adding board';
             showToast(msg, 'error');
-            $('#deployStatus').addClass('hidden');
+        });
+    }
+
+    function deleteBoard(id) {
+        const board = boards.find(b => b.id === id);
+        if (!board || !confirm('Delete board "' + board.name + '" and its columns?')) return;
+
+        $.ajax({
+            url: '/api/boards/' + encodeURIComponent(id),
+            method: 'DELETE'
         })
-        .always(function() {
-            $('#btnConfirmDeploy').prop('disabled', false);
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error deleting board';
+            showToast(msg, 'error');
+        });
+    }
+
+    function setupBoardSettings() {
+        $(document).on('change', '.board-row .board-name', function() {
+            const name = $(this).val().trim();
+            if (!name) {
+                renderBoardSettings();
+                return;
+            }
+            updateBoard($(this).closest('.board-row').attr('data-board-id'), { name: name });
+        });
+        $(document).on('change', '.board-row .board-policy', function() {
+            updateBoard($(this).closest('.board-row').attr('data-board-id'), { queue_policy: $(this).val() });
+        });
+        $(document).on('change', '.board-row .board-max', function() {
+            const max = Math.max(0, parseInt($(this).val(), 10) || 0);
+            updateBoard($(this).closest('.board-row').attr('data-board-id'), { max_concurrent_tasks: max });
+        });
+        $(document).on('change', '.board-row .board-aging', function() {
+            const hours = Math.max(0, parseInt($(this).val(), 10) || 0);
+            updateBoard($(this).closest('.board-row').attr('data-board-id'), { priority_aging_hours: hours });
+        });
+        $(document).on('click', '.board-row .board-delete', function() {
+            deleteBoard($(this).closest('.board-row').attr('data-board-id'));
+        });
+        $('#btnAddBoard').on('click', addBoard);
+        $('#boardSelect').on('change', function() {
+            switchBoard($(this).val());
         });
     }
 
     // ============================================================================
-    // Merge to Main Functions
+    // Markdown Preview
     // ============================================================================
 
-    function mergeTaskToMain(taskId, $element) {
-        const task = tasks.find(t => t.id === taskId);
-        if (!task) return;
-
-        // Show toast to indicate action started
-        showToast('Merging branch...');
+    function showDescriptionEditor() {
+        $('#taskDescriptionPreview').addClass('hidden').empty();
+        $('#taskDescription').removeClass('hidden');
+        $('#btnPreviewDescription').text('Preview');
+    }
 
-        // If element is a button with visible state, update it
-        const isButton = $element && $element.hasClass('btn-merge');
-        let originalText = '';
-        if (isButton) {
-            originalText = $element.html();
-            $element.html('<span class="spinner-small"></span> Merging...');
-            $element.prop('disabled', true);
-            $element.addClass('merging');
+    // Rendered server-side, so attachment: references resolve to the task's uploads
+    function toggleDescriptionPreview() {
+        if (!$('#taskDescriptionPreview').hasClass('hidden')) {
+            showDescriptionEditor();
+            return;
         }
 
         $.ajax({
-            url: '/api/tasks/' + taskId + '/merge',
-            type: 'POST',
+            url: '/api/render',
+            method: 'POST',
             contentType: 'application/json',
-            data: JSON.stringify({})
+            data: JSON.stringify({ markdown: $('#taskDescription').val(), task_id: currentTaskId || '' })
         })
         .done(function(data) {
-            if (data.success) {
-                // Success!
-                if (isButton) {
-                    $element.html('&#10003; Merged');
-                    $element.removeClass('merging').addClass('merged');
-                }
-                showToast('Merged successfully!', 'success');
-
-                // Reload tasks to update UI (branch badge gone, merge option gone)
-                setTimeout(function() {
-                    loadTasks();
-                }, 1000);
-            } else if (data.conflict && data.pr_url) {
-                // Conflict - PR was created
-                showToast('Conflict detected - PR created', 'warning');
-
-                // Update task in local state
-                const idx = tasks.findIndex(t => t.id === taskId);
-                if (idx !== -1) {
-                    tasks[idx].conflict_pr_url = data.pr_url;
-                    tasks[idx].conflict_pr_number = data.pr_number;
-                }
-
-                // Re-render to show conflict state
-                renderAllTasks();
-            }
+            $('#taskDescriptionPreview').html(data.html || '<p>Nothing to preview</p>').removeClass('hidden');
+            $('#taskDescription').addClass('hidden');
+            $('#btnPreviewDescription').text('Edit');
         })
         .fail(function(xhr) {
-            const msg = xhr.responseJSON?.error || 'Merge failed';
+            const msg = xhr.responseJSON?.error || 'Error rendering preview';
             showToast(msg, 'error');
-
-            // Restore button if applicable
-            if (isButton) {
-                $element.html(originalText);
-                $element.prop('disabled', false);
-                $element.removeClass('merging');
-            }
         });
     }
 
-    // GitHub Modal Functions
-    function openGithubModal() {
-        $('#githubToken').val(config.github_token || '');
-        $('#githubStatus').addClass('hidden');
-        $('#githubModal').addClass('active');
-    }
+    // ============================================================================
+    // Pasted Uploads
+    // ============================================================================
 
-    function closeGithubModal() {
-        $('#githubModal').removeClass('active');
-    }
+    // Pasted files are uploaded before the task exists; the server attaches them
+    // to the task on save and rewrites the upload: references
+    function handleUploadPaste(e) {
+        const items = e.originalEvent.clipboardData?.files;
+        if (!items || items.length === 0) return;
+        e.preventDefault();
 
-    function openCreateRepoModal(projectId) {
-        const project = projects.find(p => p.id === projectId);
-        if (!project) return;
+        const textarea = this;
+        Array.from(items).forEach(function(file) {
+            const formData = new FormData();
+            formData.append('file', file, file.name || 'pasted-' + Date.now() + '.png');
 
-        $('#createRepoProjectId').val(projectId);
-        $('#repoName').val(project.name.toLowerCase().replace(/\s+/g, '-'));
-        $('#repoDescription').val(project.description || '');
-        $('#repoPrivate').prop('checked', false);
-        $('#createRepoModal').addClass('active');
+            $.ajax({
+                url: '/api/uploads',
+                type: 'POST',
+                data: formData,
+                processData: false,
+                contentType: false
+            })
+            .done(function(data) {
+                insertAtCursor(textarea, data.markdown);
+            })
+            .fail(function(xhr) {
+                const msg = xhr.responseJSON?.error || 'Error uploading file';
+                showToast(msg, 'error');
+            });
+        });
     }
 
-    function closeCreateRepoModal() {
-        $('#createRepoModal').removeClass('active');
+    function insertAtCursor(textarea, text) {
+        const start = textarea.selectionStart;
+        const end = textarea.selectionEnd;
+        const value = textarea.value;
+        const before = start > 0 && value[start - 1] !== '\n' ? '\n' : '';
+        textarea.value = value.slice(0, start) + before + text + value.slice(end);
+        textarea.selectionStart = textarea.selectionEnd = start + before.length + text.length;
+        $(textarea).trigger('input');
     }
 
-    function openDeployModal(taskId) {
-        const task = tasks.find(t => t.id === taskId);
-        if (!task) return;
+    // ============================================================================
+    // Comments
+    // ============================================================================
 
-        $('#deployTaskId').val(taskId);
-        $('#deployTaskTitle').text(task.title);
-        $('#deployCommitMessage').val('Deploy: ' + task.title);
-        $('#deployStatus').addClass('hidden');
-        $('#deployModal').addClass('active');
+    // RALPH accepts comments from running tasks (as feedback) and review/blocked tasks (queued)
+    function canSendToRalph(task) {
+        return !!task && (columnRole(task.status) === 'progress' ||
+            task.status === 'review' || task.status === 'blocked');
     }
 
-    function closeDeployModal() {
-        $('#deployModal').removeClass('active');
+    function loadComments(taskId) {
+        taskComments = [];
+        $('#commentInput').val('');
+        $('#commentSendToRalph').prop('checked', false);
+        $('#commentsSection').removeClass('hidden');
+        renderComments();
+
+        $.get('/api/tasks/' + taskId + '/comments')
+            .done(function(data) {
+                if (currentTaskId !== taskId) return;
+                taskComments = data || [];
+                renderComments();
+            })
+            .fail(function() {
+                showToast('Error loading comments', 'error');
+            });
     }
 
-    // ============================================================================
-    // Settings Modal Functions
-    // ============================================================================
+    function renderComments() {
+        const task = tasks.find(t => t.id === currentTaskId);
+        const sendable = canSendToRalph(task);
+        $('#commentSendToRalph').prop('disabled', !sendable);
+        if (!sendable) $('#commentSendToRalph').prop('checked', false);
+
+        $('#commentList').html(taskComments.map(comment => `
+            <div class="comment-item" data-comment-id="${escapeHtml(comment.id)}">
+                <div class="comment-meta">
+                    <span class="comment-author">${escapeHtml(comment.author || 'You')}</span>
+                    <span title="${escapeHtml(new Date(comment.created_at).toLocaleString())}">${formatRelativeTime(new Date(comment.created_at).getTime())}</span>
+                    ${comment.sent_to_ralph ? '<span class="comment-sent-badge">sent to RALPH</span>' : ''}
+                    <span class="comment-actions">
+                        ${!comment.sent_to_ralph && sendable ? '<button type="button" class="comment-send" title="Send to RALPH">Send</button>' : ''}
+                        <button type="button" class="comment-edit" title="Edit">Edit</button>
+                        <button type="button" class="comment-delete" title="Delete">&times;</button>
+                    </span>
+                </div>
+                <div class="comment-body">${escapeHtml(comment.body)}</div>
+            </div>
+        `).join(''));
+    }
 
-    function openSettingsModal() {
-        // Populate form fields from config
-        $('#settingsProjectDir').val(config.default_project_dir || '');
-        $('#settingsClaudeCommand').val(config.claude_command || 'claude');
-        $('#settingsMaxIterations').val(config.default_max_iterations || 10);
-        $('#settingsGithubToken').val(config.github_token || '');
-        $('#settingsDefaultBranch').val(config.default_branch || 'main');
-        $('#settingsDefaultPriority').val(config.default_priority || 2);
-        $('#settingsAutoArchive').val(config.auto_archive_days || 0);
+    // Keep the open thread in sync with comment_* events
+    function applyCommentEvent(type, comment) {
+        if (!comment || comment.task_id !== currentTaskId) return;
+        const idx = taskComments.findIndex(c => c.id === comment.id);
+        if (type === 'comment_deleted') {
+            if (idx !== -1) taskComments.splice(idx, 1);
+        } else if (idx !== -1) {
+            taskComments[idx] = comment;
+        } else {
+            taskComments.push(comment);
+        }
+        renderComments();
+    }
 
-        // Set theme radio button based on saved preference
-        const savedTheme = getSavedTheme();
-        $('input[name="themeChoice"]').prop('checked', false);
-        $('input[name="themeChoice"][value="' + savedTheme + '"]').prop('checked', true);
+    function addComment() {
+        const body = $('#commentInput').val().trim();
+        if (!body || !currentTaskId) return;
+        const sendToRalph = $('#commentSendToRalph').is(':checked');
 
-        // Reset token input to password type
-        $('#settingsGithubToken').attr('type', 'password');
-        $('#btnToggleToken').text('Show');
+        $.ajax({
+            url: '/api/tasks/' + currentTaskId + '/comments',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ body: body, send_to_ralph: sendToRalph })
+        })
+        .done(function(comment) {
+            $('#commentInput').val('');
+            $('#commentSendToRalph').prop('checked', false);
+            applyCommentEvent('comment_created', comment);
+            if (sendToRalph) showToast('Comment sent to RALPH', 'success');
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error adding comment';
+            showToast(msg, 'error');
+        });
+    }
 
-        // Reset to first tab
-        $('.settings-tab').removeClass('active');
-        $('.settings-tab[data-tab="general"]').addClass('active');
-        $('.settings-content').removeClass('active');
-        $('#settings-general').addClass('active');
+    function commentRequest(commentId, method, suffix, data) {
+        return $.ajax({
+            url: '/api/tasks/' + currentTaskId + '/comments/' + encodeURIComponent(commentId) + suffix,
+            method: method,
+            contentType: 'application/json',
+            data: data ? JSON.stringify(data) : undefined
+        })
+        .fail(function(xhr) {
+            const msg = xhr.responseJSON?.error || 'Error updating comment';
+            showToast(msg, 'error');
+        });
+    }
 
-        // Clear GitHub status
-        $('#settingsGithubStatus').addClass('hidden');
+    function setupComments() {
+        $('#btnAddComment').on('click', addComment);
+        $('#commentInput').on('keydown', function(e) {
+            if (e.key === 'Enter' && (e.ctrlKey || e.metaKey)) {
+                e.preventDefault();
+                addComment();
+            }
+        });
 
-        $('#settingsModal').addClass('active');
+        $(document).on('click', '.comment-item .comment-edit', function() {
+            const id = $(this).closest('.comment-item').attr('data-comment-id');
+            const comment = taskComments.find(c => c.id === id);
+            if (!comment) return;
+            const body = prompt('Edit comment', comment.body);
+            if (body === null || !body.trim()) return;
+            commentRequest(id, 'PUT', '', { body: body.trim() })
+                .done(updated => applyCommentEvent('comment_updated', updated));
+        });
+        $(document).on('click', '.comment-item .comment-delete', function() {
+            const id = $(this).closest('.comment-item').attr('data-comment-id');
+            if (!confirm('Delete this comment?')) return;
+            const comment = taskComments.find(c => c.id === id);
+            commentRequest(id, 'DELETE', '')
+                .done(() => applyCommentEvent('comment_deleted', comment));
+        });
+        $(document).on('click', '.comment-item .comment-send', function() {
+            const id = $(this).closest('.comment-item').attr('data-comment-id');
+            commentRequest(id, 'POST', '/send')
+                .done(function(sent) {
+                    applyCommentEvent('comment_updated', sent);
+                    showToast('Comment sent to RALPH', 'success');
+                });
+        });
     }
 
-    function closeSettingsModal() {
-        $('#settingsModal').removeClass('active');
+    function setupColumnSettings() {
+        $(document).on('change', '.board-column-row .column-name', function() {
+            const name = $(this).val().trim();
+            if (!name) {
+                renderColumnSettings();
+                return;
+            }
+            updateColumn($(this).closest('.board-column-row').attr('data-status'), { name: name });
+        });
+        $(document).on('change', '.board-column-row .column-color', function() {
+            updateColumn($(this).closest('.board-column-row').attr('data-status'), { color: $(this).val() });
+        });
+        $(document).on('change', '.board-column-row .column-wip', function() {
+            const limit = Math.max(0, parseInt($(this).val()) || 0);
+            updateColumn($(this).closest('.board-column-row').attr('data-status'), { wip_limit: limit });
+        });
+        $(document).on('change', '.board-column-row .column-sla', function() {
+            const hours = Math.max(0, parseInt($(this).val()) || 0);
+            updateColumn($(this).closest('.board-column-row').attr('data-status'), { sla_hours: hours });
+        });
+        $(document).on('change', '.board-column-row .column-role', function() {
+            updateColumn($(this).closest('.board-column-row').attr('data-status'), { role: $(this).val() });
+        });
+        $(document).on('click', '.board-column-row .column-move', function() {
+            moveColumn($(this).closest('.board-column-row').attr('data-status'), parseInt($(this).data('dir')));
+        });
+        $(document).on('click', '.board-column-row .column-delete', function() {
+            deleteColumn($(this).closest('.board-column-row').attr('data-status'));
+        });
+        $('#btnAddColumn').on('click', addColumn);
     }
 
     function validateSettingsToken() {
@@ -4370,12 +7990,7 @@ git rebase --continue
         }
 
         // Temporarily save and validate
-        $.ajax({
-            url: '/api/config',
-            method: 'PUT',
-            contentType: 'application/json',
-            data: JSON.stringify({ github_token: token })
-        })
+        saveCredential('github', token)
         .done(function() {
             $.post('/api/github/validate')
                 .done(function(data) {
@@ -4402,7 +8017,7 @@ git rebase --continue
     // ============================================================================
 
     function checkGithubConnection() {
-        if (!config.github_token) {
+        if (!config.github_token_set) {
             updateUserProfile(null);
             return;
         }
@@ -4450,14 +8065,8 @@ git rebase --continue
     }
 
     function disconnectGithub() {
-        $.ajax({
-            url: '/api/config',
-            method: 'PUT',
-            contentType: 'application/json',
-            data: JSON.stringify({ github_token: '' })
-        })
-        .done(function(data) {
-            config = data;
+        saveCredential('github', '')
+        .done(function() {
             githubUser = null;
             updateUserProfile(null);
             showToast('GitHub connection disconnected', 'success');
@@ -4570,7 +8179,7 @@ git rebase --continue
         // Get branches for this project
         $.get('/api/projects/' + projectId + '/branches')
             .done(function(data) {
-                const branches = data.branches || [];
+                const branches = (data.branches || []).map(b => b.name);
 
                 // Get current branch info
                 $.get('/api/projects/' + projectId + '/git-info')
@@ -4757,6 +8366,20 @@ git rebase --continue
             });
     }
 
+    function isMediaAttachment(attachment) {
+        return attachment.mime_type.startsWith('image/') || attachment.mime_type.startsWith('video/');
+    }
+
+    // Placeholder for files without a visual preview (logs, PDFs, CSVs, ...)
+    function attachmentFileIcon(filename) {
+        const ext = filename.includes('.') ? filename.split('.').pop().toUpperCase().slice(0, 5) : 'FILE';
+        return `<div class="attachment-file-icon"><span>${escapeHtml(ext)}</span></div>`;
+    }
+
+    function attachmentThumbnailUrl(attachment) {
+        return '/api/tasks/' + attachment.task_id + '/attachments/' + attachment.id + '/thumbnail';
+    }
+
     // Clear the attachment list UI
     function clearAttachmentList() {
         $('#attachmentList').empty();
@@ -4775,11 +8398,16 @@ git rebase --continue
 
             let thumbnailHtml = '';
             if (isImage) {
-                thumbnailHtml = `<img class="attachment-thumbnail" src="/uploads/${attachment.task_id}/${attachment.path.split('/').pop()}" alt="${escapeHtml(attachment.filename)}">`;
+                const src = attachment.thumbnail_path ? attachmentThumbnailUrl(attachment) : `/uploads/${attachment.task_id}/${attachment.path.split('/').pop()}`;
+                thumbnailHtml = `<img class="attachment-thumbnail" src="${src}" alt="${escapeHtml(attachment.filename)}">`;
             } else if (isVideo) {
+                // Poster frame instead of loading the video itself
+                const media = attachment.thumbnail_path
+                    ? `<img src="${attachmentThumbnailUrl(attachment)}" alt="${escapeHtml(attachment.filename)}">`
+                    : `<video src="/uploads/${attachment.task_id}/${attachment.path.split('/').pop()}" preload="metadata" muted></video>`;
                 thumbnailHtml = `
                     <div class="attachment-video-thumbnail">
-                        <video src="/uploads/${attachment.task_id}/${attachment.path.split('/').pop()}" muted></video>
+                        ${media}
                         <div class="video-play-overlay">
                             <svg viewBox="0 0 24 24" fill="currentColor">
                                 <path d="M8 5v14l11-7z"/>
@@ -4787,6 +8415,8 @@ git rebase --continue
                         </div>
                     </div>
                 `;
+            } else {
+                thumbnailHtml = attachmentFileIcon(attachment.filename);
             }
 
             const $item = $(`
@@ -4911,14 +8541,21 @@ git rebase --continue
         }
     }
 
-    // Navigate lightbox
+    // Navigate lightbox (skipping files without a visual preview)
     function showPrevLightboxItem() {
-        lightboxIndex = (lightboxIndex - 1 + currentAttachments.length) % currentAttachments.length;
-        showLightboxItem(lightboxIndex);
+        stepLightbox(-1);
     }
 
     function showNextLightboxItem() {
-        lightboxIndex = (lightboxIndex + 1) % currentAttachments.length;
+        stepLightbox(1);
+    }
+
+    function stepLightbox(step) {
+        const count = currentAttachments.length;
+        for (let i = 0; i < count; i++) {
+            lightboxIndex = (lightboxIndex + step + count) % count;
+            if (isMediaAttachment(currentAttachments[lightboxIndex])) break;
+        }
         showLightboxItem(lightboxIndex);
     }
 
@@ -4962,14 +8599,8 @@ git rebase --continue
         for (let i = 0; i < files.length; i++) {
             const file = files[i];
 
-            // Validate file type
-            const allowedTypes = ['image/png', 'image/jpeg', 'image/gif', 'image/webp', 'video/mp4', 'video/webm', 'video/quicktime'];
-            if (!allowedTypes.includes(file.type)) {
-                showToast('File type not allowed: ' + file.name, 'error');
-                continue;
-            }
-
-            // Validate file size (50MB)
+            // File type and per-type limits are checked by the server (content sniffing + allowlist)
+            // Validate file size (50MB overall)
             if (file.size > 50 * 1024 * 1024) {
                 showToast('File too large (max 50MB): ' + file.name, 'error');
                 continue;
@@ -5017,6 +8648,8 @@ git rebase --continue
                 preview = `<img src="${item.dataUrl}" alt="${escapeHtml(item.name)}">`;
             } else if (isVideo) {
                 preview = `<video src="${item.dataUrl}" muted></video>`;
+            } else {
+                preview = attachmentFileIcon(item.name);
             }
 
             $list.append(`
@@ -5068,6 +8701,13 @@ git rebase --continue
     $(document).on('click', '.attachment-item', function(e) {
         if ($(e.target).hasClass('attachment-delete')) return;
         const index = $(this).data('index');
+
+        // Other files open in a new tab (pending ones are not uploaded yet)
+        const attachment = $(this).data('id') ? currentAttachments[index] : null;
+        if (attachment && !isMediaAttachment(attachment)) {
+            window.open('/api/tasks/' + attachment.task_id + '/attachments/' + attachment.id, '_blank');
+            return;
+        }
         openLightbox(index);
     });
 
@@ -5174,7 +8814,7 @@ git rebase --continue
             }
 
             const selectedClass = index === 0 ? 'selected' : '';
-            const statusLabel = task.status === 'progress' ? 'In Progress' : task.status;
+            const statusLabel = columnName(task.status);
 
             $results.append(`
                 <div class="search-result-item ${selectedClass}" data-task-id="${task.id}" data-index="${index}">
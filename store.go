@@ -0,0 +1,372 @@
+// store.go defines the storage abstraction used by handlers, runner and
+// background jobs, plus the SQL dialect layer that lets the same Database
+// implementation run on SQLite and PostgreSQL.
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Store is the persistence interface of FORGE.
+// Database implements it for both SQLite and PostgreSQL.
+type Store interface {
+	Close() error
+	Driver() string
+	Path() string
+	CheckWritable() error
+
+	// Tasks
+	GetAllTasks() ([]Task, error)
+	GetTask(id string) (*Task, error)
+	GetTasksByProject(projectID string) ([]Task, error)
+	CreateTask(req CreateTaskRequest, config *Config) (*Task, error)
+	UpdateTask(id string, req UpdateTaskRequest) (*Task, error)
+	UpdateTaskStatus(id string, status TaskStatus) error
+	UpdateTaskIteration(id string, iteration int) error
+	UpdateTaskWorkingBranch(id string, branch string) error
+	UpdateTaskError(id string, errorMsg string) error
+	UpdateTaskFailure(id string, errorMsg string, class string) error
+	UpdateTaskTriage(id string, triage *TaskTriage) (bool, error)
+	UpdateTaskConflictPR(id string, prURL string, prNumber int) error
+	UpdateTaskPR(id string, prURL string, prNumber int) error
+	UpdateTaskIssue(id string, issueURL string, issueNumber int) error
+	UpdateTaskJira(id string, jiraKey string, syncedStatus TaskStatus) error
+	UpdateTaskLinear(id string, linearID, identifier, url string) error
+	AppendTaskLogs(id string, logs string) error
+	GetTaskLogs(id string) (string, error)
+	GetTaskLogSize(id string) (*TaskLogSize, error)
+	GetTaskLogSizes() ([]TaskLogSize, error)
+	ReplaceTaskLogs(id string, oldSize int64, logs string) (bool, error)
+	ResetTaskForProgress(id string) error
+	DeleteTask(id string) error
+	MarkRunningTasksAsBlocked(reason string) error
+	UpdateTaskRollbackTag(id string, tag string) error
+	UpdateTaskCommitHash(id string, hash string) error
+	UpdateTaskCI(id string, status string, url string) error
+	UpdateTaskPlan(id string, plan string, status string) error
+
+	// Teil-Tasks eines Epics und ihre Abhängigkeiten
+	CreateSubtasks(parent *Task, subtasks []SubtaskProposal, config *Config) ([]Task, error)
+	HasOpenDependencies(taskID string) (bool, error)
+	GetTaskEvents(taskID string) ([]TaskEvent, error)
+	GetSLABreaches(now time.Time) ([]SLABreach, error)
+	MarkTaskEventAlerted(eventID string) error
+
+	// Konflikte mit dem Remote
+	GetTaskConflicts(taskID string) ([]TaskConflict, error)
+	GetOpenConflictByResolutionTask(resolutionTaskID string) (*TaskConflict, error)
+	CreateTaskConflict(conflict *TaskConflict) error
+	ResolveTaskConflict(id string) error
+	IncrementTaskCIFixAttempts(id string) error
+	UpdateTaskReviewComments(id string, count int) error
+	MarkTaskReviewAddressed(id string, at time.Time) error
+	UpdateTaskMergeCommit(id string, sha string) error
+	ClearTaskRollbackTag(id string) error
+
+	// Queue and process tracking
+	GetQueuedTasks() ([]Task, error)
+	GetDispatchQueue(now time.Time) ([]Task, error)
+	GetRunDurations() ([]RunDuration, error)
+	GetRunCosts(since time.Time) (map[string]float64, error)
+	GetNextQueuedTask() (*Task, error)
+	GetMaxQueuePosition() (int, error)
+	AddToQueue(taskID string) error
+	AddToQueueWithStatus(taskID string, status TaskStatus) error
+	AddToQueueWithMessage(taskID string, message string) error
+	QueueTaskRetry(taskID string, message string, retryAt time.Time) error
+	ClearTaskRetry(taskID string) error
+	ClearContinueMessage(taskID string) error
+	RemoveFromQueue(taskID string) error
+	MoveInQueue(taskID string, position int) ([]QueueEntry, error)
+	ReorderQueue(taskIDs []string) ([]QueueEntry, error)
+	GetQueueState() (*QueueState, error)
+	SetQueuePaused(paused bool, reason string) (*QueueState, error)
+	SetQueueCooldown(until *time.Time, level int, reason string) (*QueueState, error)
+	UpdateTaskProcessInfo(id string, pid int, status string) error
+	UpdateTaskStartedAt(id string) error
+	UpdateTaskFinishedAt(id string) error
+	GetTasksWithRunningProcess() ([]Task, error)
+	GetResumableTasks() ([]Task, error)
+
+	// Projects
+	GetAllProjects() ([]Project, error)
+	GetProject(id string) (*Project, error)
+	GetProjectByPath(path string) (*Project, error)
+	CreateProject(req CreateProjectRequest, isAutoDetected bool) (*Project, error)
+	UpdateProject(id string, req UpdateProjectRequest) (*Project, error)
+	DeleteProject(id string) error
+	UpdateProjectWorkingBranch(id string, branch string) error
+	SetProjectMissing(id string, since *time.Time) error
+	SetProjectStack(id string, stack ProjectStack) error
+
+	// Task types
+	GetAllTaskTypes() ([]TaskType, error)
+	GetTaskType(id string) (*TaskType, error)
+	CreateTaskType(req CreateTaskTypeRequest) (*TaskType, error)
+	UpdateTaskType(id string, req UpdateTaskTypeRequest) (*TaskType, error)
+	DeleteTaskType(id string) error
+
+	// Comments
+	GetComments(taskID string) ([]TaskComment, error)
+	GetAllComments() ([]TaskComment, error)
+	GetComment(id string) (*TaskComment, error)
+	CreateComment(taskID string, req CreateCommentRequest) (*TaskComment, error)
+	UpdateComment(id string, req UpdateCommentRequest) (*TaskComment, error)
+	MarkCommentSent(id string) (*TaskComment, error)
+	DeleteComment(id string) error
+
+	// Labels
+	GetAllLabels() ([]Label, error)
+	GetLabel(id string) (*Label, error)
+	CreateLabel(req CreateLabelRequest) (*Label, error)
+	UpdateLabel(id string, req UpdateLabelRequest) (*Label, error)
+	DeleteLabel(id string) error
+
+	// Board columns
+	GetBoardColumns() ([]BoardColumn, error)
+	GetBoardColumn(status TaskStatus) (*BoardColumn, error)
+	CreateBoardColumn(req CreateBoardColumnRequest) (*BoardColumn, error)
+	UpdateBoardColumn(status TaskStatus, req UpdateBoardColumnRequest) (*BoardColumn, error)
+	DeleteBoardColumn(status TaskStatus) error
+	ReorderBoardColumns(statuses []TaskStatus) error
+	CountTasksByStatus(status TaskStatus) (int, error)
+
+	// Boards
+	GetBoards() ([]Board, error)
+	GetBoard(id string) (*Board, error)
+	CreateBoard(req CreateBoardRequest) (*Board, error)
+	UpdateBoard(id string, req UpdateBoardRequest) (*Board, error)
+	DeleteBoard(id string) error
+
+	// Users and sessions
+	UpsertUser(user *User) error
+	CreateSession(userID, tokenHash string, expiresAt time.Time) error
+	GetSessionUser(tokenHash string) (*User, error)
+	DeleteSession(tokenHash string) error
+	UpdateUserProfile(userID string, req UpdateProfileRequest) (*User, error)
+
+	// Branch protection
+	GetBranchRules(projectID string) ([]BranchProtectionRule, error)
+	GetAllBranchRules() ([]BranchProtectionRule, error)
+	CreateBranchRule(projectID string, pattern string) (*BranchProtectionRule, error)
+	UpdateBranchRule(projectID, id, pattern string) (*BranchProtectionRule, error)
+	DeleteBranchRule(id string) error
+
+	// Path protection
+	GetPathRules(projectID string) ([]PathProtectionRule, error)
+	GetPathRule(id string) (*PathProtectionRule, error)
+	CreatePathRule(rule *PathProtectionRule) error
+	UpdatePathRule(rule *PathProtectionRule) error
+	DeletePathRule(id string) error
+
+	// Project secrets
+	GetProjectSecrets(projectID string) ([]ProjectSecret, error)
+	GetProjectSecret(id string) (*ProjectSecret, error)
+	CreateProjectSecret(secret *ProjectSecret) error
+	UpdateProjectSecret(secret *ProjectSecret) error
+	DeleteProjectSecret(id string) error
+
+	// Deploy keys
+	GetDeployKey(projectID string) (*DeployKey, error)
+	GetDeployKeys() ([]DeployKey, error)
+	SaveDeployKey(key *DeployKey) error
+	DeleteDeployKey(projectID string) error
+
+	// Releases
+	GetReleasesByProject(projectID string) ([]Release, error)
+	GetRelease(id string) (*Release, error)
+	CreateRelease(release *Release) error
+	UpdateRelease(release *Release) error
+
+	// Deployments
+	GetDeploymentsByProject(projectID, environment string, limit int) ([]Deployment, error)
+	GetDeployment(id string) (*Deployment, error)
+	CreateDeployment(deployment *Deployment) error
+	FinishDeployment(deployment *Deployment) error
+	InterruptRunningDeployments() (int64, error)
+
+	// Run history
+	GetTaskRuns(taskID string) ([]TaskRun, error)
+	GetTaskRunLogs(taskID string, number int) (string, bool, error)
+	CreateTaskRun(run *TaskRun) error
+	FinishTaskRun(run *TaskRun, logs string) error
+	InterruptRunningTaskRuns() (int64, error)
+	AddTaskRunMessage(runID string, msg RunMessage) error
+	GetTaskRunMessages(taskID string, number int) ([]RunMessage, error)
+
+	// Deploy-Umgebungen
+	GetProjectEnvironments(projectID string) ([]ProjectEnvironment, error)
+	GetProjectEnvironment(id string) (*ProjectEnvironment, error)
+	GetProjectEnvironmentByName(projectID, name string) (*ProjectEnvironment, error)
+	CreateProjectEnvironment(env *ProjectEnvironment) error
+	UpdateProjectEnvironment(env *ProjectEnvironment) error
+	DeleteProjectEnvironment(id string) error
+
+	// MCP-Server
+	GetProjectMCPServers(projectID string) ([]ProjectMCPServer, error)
+	GetProjectMCPServer(id string) (*ProjectMCPServer, error)
+	CreateProjectMCPServer(server *ProjectMCPServer) error
+	UpdateProjectMCPServer(server *ProjectMCPServer) error
+	DeleteProjectMCPServer(id string) error
+
+	// Config
+	GetConfig() (*Config, error)
+	GetShareKey() ([]byte, error)
+	UpdateConfig(req UpdateConfigRequest) (*Config, error)
+
+	// Attachments
+	GetAttachmentsByTask(taskID string) ([]Attachment, error)
+	GetAttachment(id string) (*Attachment, error)
+	CreateAttachment(attachment *Attachment) error
+	SetAttachmentThumbnail(id, thumbnailPath string) error
+	DeleteAttachment(id string) error
+	DeleteAttachmentsByTask(taskID string) error
+
+	// Uploads (not yet attached to a task)
+	CreateUpload(upload *Upload) error
+	GetUpload(id string) (*Upload, error)
+	GetUploadsBefore(cutoff time.Time) ([]Upload, error)
+	DeleteUpload(id string) error
+	ClaimUpload(id, taskID, path string) (*Attachment, error)
+
+	// Task commits (indexed from Forge-Task trailers)
+	AddTaskCommits(commits []TaskCommit) error
+	GetTaskCommits(taskID string) ([]TaskCommit, error)
+
+	// Export/Import and backups
+	ImportBoard(data *BoardExport, mode string, importConfig bool) (*ImportResult, error)
+	BackupTo(dest string) error
+	RestoreFrom(src string) error
+
+	// Schema migrations
+	MigrationStatus() (*MigrationStatus, error)
+	MigrateUp(target int, dryRun bool) ([]MigrationInfo, error)
+	MigrateDown(target int, dryRun bool) ([]MigrationInfo, error)
+}
+
+var _ Store = (*Database)(nil)
+
+// Supported database drivers (FORGE_DB_DRIVER)
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+)
+
+// ============================================================================
+// SQL-Dialekt
+// ============================================================================
+
+// dialect translates the SQLite-flavoured queries in db.go for the active driver.
+// Queries are written once with ? placeholders; PostgreSQL gets them rebound to $n.
+type dialect string
+
+// rebind rewrites a query for the dialect
+func (dl dialect) rebind(query string) string {
+	if dl != DriverPostgres {
+		return query
+	}
+
+	// INSERT OR IGNORE -> INSERT ... ON CONFLICT DO NOTHING (single statements only)
+	if strings.Contains(query, "INSERT OR IGNORE") {
+		query = strings.Replace(query, "INSERT OR IGNORE", "INSERT", 1)
+		query = strings.TrimRight(strings.TrimSpace(query), ";") + " ON CONFLICT DO NOTHING"
+	}
+
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	// Replace ? placeholders with $1, $2, ... outside of string literals
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+			b.WriteRune(r)
+		case r == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// args converts query arguments for the dialect.
+// Boolean columns are INTEGER (0/1) in both schemas, but lib/pq sends Go bools as true/false.
+func (dl dialect) args(args []interface{}) []interface{} {
+	if dl != DriverPostgres {
+		return args
+	}
+	converted := make([]interface{}, len(args))
+	for i, a := range args {
+		if v, ok := a.(bool); ok {
+			if v {
+				a = 1
+			} else {
+				a = 0
+			}
+		}
+		converted[i] = a
+	}
+	return converted
+}
+
+// sqlConn wraps *sql.DB and rewrites every query for the active dialect
+type sqlConn struct {
+	*sql.DB
+	dialect dialect
+}
+
+// Exec executes a query without returning rows
+func (c *sqlConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.DB.Exec(c.dialect.rebind(query), c.dialect.args(args)...)
+}
+
+// Query executes a query that returns rows
+func (c *sqlConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.DB.Query(c.dialect.rebind(query), c.dialect.args(args)...)
+}
+
+// QueryRow executes a query that returns at most one row
+func (c *sqlConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.DB.QueryRow(c.dialect.rebind(query), c.dialect.args(args)...)
+}
+
+// Begin starts a transaction that rewrites queries like its connection
+func (c *sqlConn) Begin() (*sqlTx, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, dialect: c.dialect}, nil
+}
+
+// sqlTx wraps *sql.Tx and rewrites every query for the active dialect
+type sqlTx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+// Exec executes a query without returning rows
+func (t *sqlTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.dialect.rebind(query), t.dialect.args(args)...)
+}
+
+// Query executes a query that returns rows
+func (t *sqlTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.dialect.rebind(query), t.dialect.args(args)...)
+}
+
+// QueryRow executes a query that returns at most one row
+func (t *sqlTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.dialect.rebind(query), t.dialect.args(args)...)
+}
@@ -0,0 +1,189 @@
+// taskclone.go copies tasks. A clone gets the content and settings of the task
+// (title, description, acceptance criteria, project, type, labels, env, an
+// approved plan) but none of its run state: it starts in the backlog without
+// logs, branch, commits or PR. Attachments are copied on request. Re-running
+// a finished task clones it with its attachments and queues the clone, which
+// is how recurring maintenance jobs like dependency bumps are repeated.
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HandleTaskClone handles POST /api/tasks/{id}/clone
+// Creates a copy of the task in the backlog, or in the queue with "enqueue".
+func (h *Handler) HandleTaskClone(w http.ResponseWriter, r *http.Request) {
+	var req CloneTaskRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+	}
+	h.cloneTask(w, r, r.PathValue("id"), req)
+}
+
+// HandleTaskRerun handles POST /api/tasks/{id}/rerun
+// Clones a finished task with its attachments and queues the clone.
+func (h *Handler) HandleTaskRerun(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if h.columnRole(task.Status) != ColumnRoleTerminal {
+		h.writeError(w, http.StatusConflict, "Only finished tasks can be re-run")
+		return
+	}
+	h.cloneTask(w, r, task.ID, CloneTaskRequest{CopyAttachments: true, Enqueue: true})
+}
+
+// cloneTask creates the clone of a task and writes it
+func (h *Handler) cloneTask(w http.ResponseWriter, r *http.Request, id string, req CloneTaskRequest) {
+	task, err := h.db.GetTask(id)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	// Checked before creating anything, so a full queue leaves no clone behind
+	if req.Enqueue {
+		if err := h.checkWIPLimit(StatusQueued); err != nil {
+			h.writeWIPError(w, err)
+			return
+		}
+	}
+
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+
+	createReq := CreateTaskRequest{
+		Title:              task.Title,
+		Description:        task.Description,
+		AcceptanceCriteria: task.AcceptanceCriteria,
+		Priority:           task.Priority,
+		MaxIterations:      task.MaxIterations,
+		ProjectDir:         task.ProjectDir,
+		ProjectID:          task.ProjectID,
+		TaskTypeID:         task.TaskTypeID,
+		TargetBranch:       task.TargetBranch,
+		Env:                task.Env,
+		WorkDir:            task.WorkDir,
+		BoardID:            task.BoardID,
+	}
+	for _, label := range task.Labels {
+		createReq.LabelIDs = append(createReq.LabelIDs, label.ID)
+	}
+	if req.Title != "" {
+		createReq.Title = req.Title
+	}
+
+	clone, err := h.db.CreateTask(createReq, config)
+	if err != nil {
+		h.writeLabelError(w, "create", err)
+		return
+	}
+	logFrom(r.Context()).Info("Cloned task", "task_id", task.ID, "clone_id", clone.ID)
+
+	// An approved plan still describes the work; a proposed one has to be reviewed again
+	if task.PlanStatus == PlanStatusApproved {
+		if err := h.db.UpdateTaskPlan(clone.ID, task.Plan, PlanStatusApproved); err != nil {
+			logFrom(r.Context()).Warn("Failed to copy plan", "task_id", clone.ID, "err", err)
+		}
+	}
+
+	if req.CopyAttachments {
+		description, criteria := clone.Description, clone.AcceptanceCriteria
+		if h.copyTaskAttachments(task.ID, clone.ID, &description, &criteria) {
+			if _, err := h.db.UpdateTask(clone.ID, UpdateTaskRequest{Description: &description, AcceptanceCriteria: &criteria}); err != nil {
+				h.writeError(w, http.StatusInternalServerError, "Failed to copy attachments: "+err.Error())
+				return
+			}
+		}
+	}
+
+	if req.Enqueue {
+		if err := h.db.AddToQueue(clone.ID); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to add task to queue: "+err.Error())
+			return
+		}
+	}
+
+	h.writeTaskAndStartQueue(w, r, clone.ID, http.StatusCreated)
+}
+
+// copyTaskAttachments copies the attachment files of a task to another task
+// and points the references in texts to the copies. Returns true if a text
+// changed. Attachments that fail to copy are skipped.
+func (h *Handler) copyTaskAttachments(fromID, toID string, texts ...*string) bool {
+	attachments, err := h.db.GetAttachmentsByTask(fromID)
+	if err != nil {
+		componentLog("attachments").Error("Failed to get attachments", "task_id", fromID, "err", err)
+		return false
+	}
+
+	dir := filepath.Join(UploadsDir, toID)
+	copied := make(map[string]string)
+	for _, att := range attachments {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			componentLog("attachments").Error("Failed to create upload directory", "err", err)
+			return false
+		}
+		dst := filepath.Join(dir, uuid.New().String()+filepath.Ext(att.Path))
+		if err := copyFile(att.Path, dst); err != nil {
+			componentLog("attachments").Warn("Failed to copy attachment", "attachment", att.ID, "err", err)
+			continue
+		}
+
+		clone := Attachment{
+			ID:        uuid.New().String(),
+			TaskID:    toID,
+			Filename:  att.Filename,
+			MimeType:  att.MimeType,
+			Size:      att.Size,
+			Path:      dst,
+			CreatedAt: time.Now(),
+		}
+		if err := h.db.CreateAttachment(&clone); err != nil {
+			componentLog("attachments").Warn("Failed to save attachment record", "attachment", att.ID, "err", err)
+			os.Remove(dst)
+			continue
+		}
+		copied[att.ID] = clone.ID
+
+		// Preview for board cards; broadcasts the task again once ready
+		go h.generateThumbnail(clone)
+	}
+
+	changed := false
+	for _, text := range texts {
+		rewritten := *text
+		for oldID, newID := range copied {
+			rewritten = strings.ReplaceAll(rewritten, attachmentScheme+oldID, attachmentScheme+newID)
+		}
+		if rewritten != *text {
+			*text = rewritten
+			changed = true
+		}
+	}
+	return changed
+}
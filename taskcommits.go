@@ -0,0 +1,199 @@
+// taskcommits.go indexes which commits belong to which task. Every commit with
+// a Forge-Task trailer is stored in the task_commits table; an indexer scans
+// the repositories of all projects every FORGE_COMMIT_INDEX_INTERVAL, but only
+// the commits that became reachable since its last scan. GET
+// /api/tasks/{id}/commits lists everything a task actually committed.
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCommitIndexInterval is how often all projects are scanned for new task commits
+const defaultCommitIndexInterval = 5 * time.Minute
+
+// commitIndexTimeout bounds a single scan; the first scan of a large repository walks its whole history
+const commitIndexTimeout = 2 * time.Minute
+
+// commitIndex is the process-wide task commit indexer
+var commitIndex = newCommitIndexer(commitIndexIntervalFromEnv())
+
+// commitIndexer remembers the ref tips of each repository at its last scan,
+// so the next scan only walks commits that are new since then
+type commitIndexer struct {
+	mu       sync.Mutex
+	tips     map[string][]string // Project path -> ref tips at the last scan
+	interval time.Duration       // 0 disables the background scan
+}
+
+func newCommitIndexer(interval time.Duration) *commitIndexer {
+	return &commitIndexer{
+		tips:     make(map[string][]string),
+		interval: interval,
+	}
+}
+
+// commitIndexIntervalFromEnv reads FORGE_COMMIT_INDEX_INTERVAL (e.g. 10m; 0 disables the background scan)
+func commitIndexIntervalFromEnv() time.Duration {
+	v := os.Getenv("FORGE_COMMIT_INDEX_INTERVAL")
+	if v == "" {
+		return defaultCommitIndexInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("commitindex").Warn("Ignoring invalid FORGE_COMMIT_INDEX_INTERVAL", "value", v)
+		return defaultCommitIndexInterval
+	}
+	return d
+}
+
+// Run scans all projects every interval until stop is closed
+func (c *commitIndexer) Run(db Store, stop <-chan struct{}) {
+	if c.interval == 0 {
+		componentLog("commitindex").Info("Background scan disabled")
+		return
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		projects, err := db.GetAllProjects()
+		if err != nil {
+			componentLog("commitindex").Error("Failed to list projects", "err", err)
+		}
+		for i := range projects {
+			if err := c.IndexProject(db, &projects[i]); err != nil {
+				componentLog("commitindex").Warn("Failed to index project", "path", projects[i].Path, "err", err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// IndexProject stores the task commits that became reachable in the project's
+// repository since the last scan
+func (c *commitIndexer) IndexProject(db Store, project *Project) error {
+	if project == nil || !IsGitRepository(project.Path) {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out, err := runGitStatusCommand(project.Path, "for-each-ref", "--format=%(objectname)")
+	if err != nil {
+		return err
+	}
+	tips := strings.Fields(out)
+	if head, err := GetCurrentCommitHash(project.Path); err == nil {
+		tips = append(tips, head) // Covers a detached HEAD
+	}
+	sort.Strings(tips)
+	old := c.tips[project.Path]
+	if len(tips) == 0 || strings.Join(tips, " ") == strings.Join(old, " ") {
+		return nil
+	}
+
+	entries, err := scanTaskCommits(project.Path, tips, old)
+	if err != nil {
+		return err
+	}
+
+	// Only commits of tasks that exist; trailers may name deleted or foreign tasks
+	known := make(map[string]bool)
+	var commits []TaskCommit
+	for _, entry := range entries {
+		for _, taskID := range entry.TaskIDs {
+			exists, ok := known[taskID]
+			if !ok {
+				task, _ := db.GetTask(taskID)
+				exists = task != nil
+				known[taskID] = exists
+			}
+			if exists {
+				commits = append(commits, TaskCommit{CommitInfo: entry.CommitInfo, TaskID: taskID, ProjectID: project.ID})
+			}
+		}
+	}
+	if len(commits) > 0 {
+		if err := db.AddTaskCommits(commits); err != nil {
+			return err
+		}
+		componentLog("commitindex").Info("Indexed task commits", "path", project.Path, "count", len(commits))
+	}
+
+	c.tips[project.Path] = tips
+	return nil
+}
+
+// scanTaskCommits lists the commits with a Forge-Task trailer that are
+// reachable from tips but not from old. The revisions go through stdin, as a
+// repository can have more refs than fit on a command line.
+func scanTaskCommits(path string, tips, old []string) ([]gitLogEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commitIndexTimeout)
+	defer cancel()
+
+	var revs strings.Builder
+	for _, tip := range tips {
+		revs.WriteString(tip + "\n")
+	}
+	for _, tip := range old {
+		revs.WriteString("^" + tip + "\n")
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "log", "--stdin", "--ignore-missing", "--grep=^"+TaskTrailerKey+":", gitLogFormat)
+	cmd.Dir = path
+	cmd.Stdin = strings.NewReader(revs.String())
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git log: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	return parseGitLog(string(output)), nil
+}
+
+// HandleTaskCommits handles GET /api/tasks/{id}/commits
+// The task's project is scanned first, so commits made moments ago are included.
+func (h *Handler) HandleTaskCommits(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+	task, err := h.db.GetTask(taskID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	if task.ProjectID != "" {
+		if project, _ := h.db.GetProject(task.ProjectID); project != nil {
+			if err := commitIndex.IndexProject(h.db, project); err != nil {
+				componentLog("commitindex").Warn("Failed to index project", "path", project.Path, "err", err)
+			}
+		}
+	}
+
+	commits, err := h.db.GetTaskCommits(task.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get commits: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, commits)
+}
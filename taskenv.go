@@ -0,0 +1,114 @@
+// taskenv.go lets a task configure the environment RALPH runs in: extra
+// environment variables, merged over the project's injected secrets, and a
+// subdirectory of the project, such as a package in a monorepo, to run in.
+// Git operations, checkpoints and rollbacks still work on the whole project.
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TaskEnv holds a task's environment variables, stored as a JSON object
+type TaskEnv map[string]string
+
+// Value stores the variables as JSON, no variables as an empty string
+func (e TaskEnv) Value() (driver.Value, error) {
+	if len(e) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(map[string]string(e))
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads variables stored by Value
+func (e *TaskEnv) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into TaskEnv", src)
+	}
+	*e = nil
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, (*map[string]string)(e))
+}
+
+// names returns the variable names, sorted
+func (e TaskEnv) names() []string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateTaskEnv rejects variable names a shell could not export
+func validateTaskEnv(env TaskEnv) error {
+	for _, name := range env.names() {
+		if !secretNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// cleanWorkDir normalizes a task's working directory, which has to be a
+// relative path inside the project. "" and "." mean the project root.
+func cleanWorkDir(dir string) (string, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return "", nil
+	}
+	if !filepath.IsLocal(dir) {
+		return "", fmt.Errorf("working directory must be a relative path inside the project")
+	}
+	if dir = filepath.Clean(dir); dir == "." {
+		return "", nil
+	}
+	return dir, nil
+}
+
+// applyTaskEnv points cmd at the task's working directory and adds its
+// environment variables, which take precedence over project secrets of the
+// same name. Returns false (after failing the task) if the directory is missing.
+func (r *RalphRunner) applyTaskEnv(task *Task, cmd *exec.Cmd) bool {
+	if task.WorkDir != "" {
+		dir := filepath.Join(task.ProjectDir, task.WorkDir)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			r.handleError(task.ID, fmt.Sprintf("Working directory does not exist: %s", task.WorkDir))
+			return false
+		}
+		cmd.Dir = dir
+		r.hub.BroadcastLog(task.ID, "[FORGE] Working directory: "+task.WorkDir+"\n")
+	}
+
+	if len(task.Env) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		names := task.Env.names()
+		for _, name := range names {
+			// exec uses the last value of a duplicate variable
+			cmd.Env = append(cmd.Env, name+"="+task.Env[name])
+		}
+		r.hub.BroadcastLog(task.ID, "[FORGE] Task environment variables: "+strings.Join(names, ", ")+"\n")
+	}
+	return true
+}
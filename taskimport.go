@@ -0,0 +1,439 @@
+// taskimport.go creates tasks in bulk from an existing TODO backlog. POST
+// /api/tasks/import accepts a CSV file with a header row or a Markdown file
+// of checkboxes. CSV columns are matched to task fields by their header (or
+// an explicit mapping); every open checkbox of a Markdown file becomes a
+// task, with the indented lines below it as description and nested
+// checkboxes as acceptance criteria. Rows are imported independently and the
+// response reports the result of each one.
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxTaskImportSize caps a CSV or Markdown upload (5MB)
+const maxTaskImportSize = 5 * 1024 * 1024
+
+// Formats of a task import
+const (
+	TaskImportCSV      = "csv"
+	TaskImportMarkdown = "markdown"
+)
+
+// taskImportFields are the task fields a CSV column can be mapped to, with
+// the headers that map to them without an explicit mapping
+var taskImportFields = map[string][]string{
+	"title":               {"title", "name", "summary", "task", "todo"},
+	"description":         {"description", "body", "details", "notes"},
+	"acceptance_criteria": {"acceptance_criteria", "acceptance criteria", "criteria", "done when"},
+	"priority":            {"priority", "prio"},
+	"project":             {"project"},
+	"type":                {"type", "task type", "kind"},
+	"labels":              {"labels", "label", "tags"},
+	"max_iterations":      {"max_iterations", "iterations"},
+}
+
+// checkboxPattern matches a Markdown checkbox item and captures its
+// indentation, its state and its text
+var checkboxPattern = regexp.MustCompile(`^(\s*)[-*+]\s+\[([ xX])\]\s+(.*)$`)
+
+// taskImportRow is a parsed row of an import before it is turned into a task
+type taskImportRow struct {
+	line   int
+	fields map[string]string
+	done   bool // Checked Markdown checkbox, nothing left to do
+}
+
+// parseTaskImportCSV reads the records of a CSV file. mapping assigns task
+// fields to headers; fields without one use the headers of taskImportFields.
+func parseTaskImportCSV(data []byte, mapping map[string]string) ([]taskImportRow, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("The CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CSV: %v", err)
+	}
+
+	columns := make(map[string]int) // Task field -> column index
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for field, aliases := range taskImportFields {
+			if target, ok := mapping[field]; ok {
+				if strings.EqualFold(strings.TrimSpace(target), name) {
+					columns[field] = i
+				}
+				continue
+			}
+			for _, alias := range aliases {
+				if name == alias {
+					if _, taken := columns[field]; !taken {
+						columns[field] = i
+					}
+				}
+			}
+		}
+	}
+	for field, target := range mapping {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("Column %q mapped to %s is not in the header", target, field)
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("The CSV file has no title column; name one title or map it with map=title:<column>")
+	}
+
+	var rows []taskImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		row := taskImportRow{line: line, fields: make(map[string]string)}
+		empty := true
+		for field, i := range columns {
+			if i < len(record) {
+				row.fields[field] = strings.TrimSpace(record[i])
+				if row.fields[field] != "" {
+					empty = false
+				}
+			}
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// parseTaskImportMarkdown turns the checkboxes of a Markdown file into rows.
+// Top-level checkboxes are tasks; indented checkboxes below one become its
+// acceptance criteria and other indented lines its description.
+func parseTaskImportMarkdown(data []byte) []taskImportRow {
+	var rows []taskImportRow
+	var current *taskImportRow
+	var indent int
+	var description, criteria []string
+
+	flush := func() {
+		if current != nil {
+			current.fields["description"] = strings.TrimSpace(strings.Join(description, "\n"))
+			current.fields["acceptance_criteria"] = strings.Join(criteria, "\n")
+			rows = append(rows, *current)
+		}
+		current, description, criteria = nil, nil, nil
+	}
+
+	for i, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if m := checkboxPattern.FindStringSubmatch(line); m != nil {
+			if current != nil && len(m[1]) > indent {
+				criteria = append(criteria, "- "+strings.TrimSpace(m[3]))
+				continue
+			}
+			flush()
+			indent = len(m[1])
+			current = &taskImportRow{
+				line:   i + 1,
+				fields: map[string]string{"title": strings.TrimSpace(m[3])},
+				done:   m[2] != " ",
+			}
+			continue
+		}
+		switch {
+		case current == nil:
+		case strings.TrimSpace(line) == "":
+			description = append(description, "")
+		case lineIndent > indent:
+			description = append(description, strings.TrimSpace(line))
+		default:
+			// Headings and other text end the item
+			flush()
+		}
+	}
+	flush()
+	return rows
+}
+
+// parseImportPriority reads 1-3 or high/medium/low (also P1-P3)
+func parseImportPriority(value string) (int, error) {
+	switch strings.TrimPrefix(strings.ToLower(value), "p") {
+	case "1", "high":
+		return 1, nil
+	case "2", "medium", "normal":
+		return 2, nil
+	case "3", "low":
+		return 3, nil
+	}
+	return 0, fmt.Errorf("Invalid priority %q, use 1-3 or high/medium/low", value)
+}
+
+// parseImportMapping reads "title:Summary,priority:Prio" into task field -> header
+func parseImportMapping(value string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, column, ok := strings.Cut(pair, ":")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if _, known := taskImportFields[field]; !ok || !known || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("Invalid mapping %q, use <field>:<column> with a field of title, description, acceptance_criteria, priority, project, type, labels, max_iterations", pair)
+		}
+		mapping[field] = strings.TrimSpace(column)
+	}
+	return mapping, nil
+}
+
+// detectTaskImportFormat guesses the format from the file name, then from
+// the content: a file with checkboxes is Markdown
+func detectTaskImportFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return TaskImportCSV
+	case ".md", ".markdown":
+		return TaskImportMarkdown
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if checkboxPattern.MatchString(strings.TrimRight(line, "\r")) {
+			return TaskImportMarkdown
+		}
+	}
+	return TaskImportCSV
+}
+
+// taskImporter resolves the project, type and label references of rows
+type taskImporter struct {
+	h         *Handler
+	projects  []Project
+	taskTypes []TaskType
+	labels    *labelIndex
+	defaults  CreateTaskRequest
+}
+
+// request builds the create request of a row
+func (imp *taskImporter) request(row taskImportRow) (CreateTaskRequest, error) {
+	req := imp.defaults
+	req.Title = row.fields["title"]
+	req.Description = row.fields["description"]
+	req.AcceptanceCriteria = row.fields["acceptance_criteria"]
+	if req.Title == "" {
+		return req, errors.New("Title is required")
+	}
+
+	if v := row.fields["priority"]; v != "" {
+		priority, err := parseImportPriority(v)
+		if err != nil {
+			return req, err
+		}
+		req.Priority = priority
+	}
+	if v := row.fields["max_iterations"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSuggestedIterations {
+			return req, fmt.Errorf("Invalid max_iterations %q, use 1-%d", v, maxSuggestedIterations)
+		}
+		req.MaxIterations = n
+	}
+	if v := row.fields["project"]; v != "" {
+		req.ProjectID = ""
+		for _, p := range imp.projects {
+			if p.ID == v || strings.EqualFold(p.Name, v) || p.Path == v {
+				req.ProjectID = p.ID
+				break
+			}
+		}
+		if req.ProjectID == "" {
+			return req, fmt.Errorf("Unknown project %q", v)
+		}
+	}
+	if v := row.fields["type"]; v != "" {
+		req.TaskTypeID = ""
+		for _, tt := range imp.taskTypes {
+			if tt.ID == v || strings.EqualFold(tt.Name, v) {
+				req.TaskTypeID = tt.ID
+				break
+			}
+		}
+		if req.TaskTypeID == "" {
+			return req, fmt.Errorf("Unknown task type %q", v)
+		}
+	}
+	if v := row.fields["labels"]; v != "" {
+		req.LabelIDs = nil
+		for _, name := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ';' }) {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			id, err := imp.labels.id(name, "")
+			if err != nil {
+				return req, fmt.Errorf("Failed to create label %q: %v", name, err)
+			}
+			req.LabelIDs = append(req.LabelIDs, id)
+		}
+	}
+	return req, nil
+}
+
+// HandleTaskImport handles POST /api/tasks/import
+// Accepts the file as body or as multipart field "file". Query parameters:
+//   - format: csv or markdown (default: from the file name or content)
+//   - project_id: project of rows that name none
+//   - map: explicit CSV mapping, e.g. title:Summary,priority:Prio
+//   - force: true to also import rows that look like existing tasks
+func (h *Handler) HandleTaskImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var body io.Reader = r.Body
+	filename := ""
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "No file provided")
+			return
+		}
+		defer file.Close()
+		body, filename = file, header.Filename
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Failed to read upload: "+err.Error())
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = detectTaskImportFormat(filename, data)
+	}
+	if format == "md" {
+		format = TaskImportMarkdown
+	}
+	mapping, err := parseImportMapping(query.Get("map"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var rows []taskImportRow
+	switch format {
+	case TaskImportCSV:
+		rows, err = parseTaskImportCSV(data, mapping)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case TaskImportMarkdown:
+		rows = parseTaskImportMarkdown(data)
+	default:
+		h.writeError(w, http.StatusBadRequest, "Invalid format. Allowed: csv, markdown")
+		return
+	}
+	if len(rows) == 0 {
+		h.writeError(w, http.StatusBadRequest, "The file contains no tasks")
+		return
+	}
+
+	config, err := h.db.GetConfig()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get config: "+err.Error())
+		return
+	}
+	imp := &taskImporter{h: h}
+	if imp.projects, err = h.db.GetAllProjects(); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get projects: "+err.Error())
+		return
+	}
+	if imp.taskTypes, err = h.db.GetAllTaskTypes(); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task types: "+err.Error())
+		return
+	}
+	if imp.labels, err = newLabelIndex(h.db); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get labels: "+err.Error())
+		return
+	}
+	if projectID := query.Get("project_id"); projectID != "" {
+		project, _ := h.db.GetProject(projectID)
+		if project == nil {
+			h.writeError(w, http.StatusBadRequest, "Project not found")
+			return
+		}
+		imp.defaults.ProjectID = project.ID
+	}
+	force := query.Get("force") == "true"
+
+	report := TaskImportReport{Format: format, Rows: []TaskImportRow{}}
+	for _, row := range rows {
+		result := TaskImportRow{Line: row.line, Title: row.fields["title"]}
+		switch {
+		case row.done:
+			result.Status = TaskImportSkipped
+			result.Error = "Already checked off"
+		default:
+			task, status, err := imp.importRow(row, config, force)
+			result.Status = status
+			if err != nil {
+				result.Error = err.Error()
+			}
+			if task != nil {
+				result.TaskID = task.ID
+			}
+		}
+		switch result.Status {
+		case TaskImportCreated:
+			report.Created++
+		case TaskImportSkipped:
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	if imp.labels.created {
+		h.broadcastLabels()
+	}
+	logFrom(r.Context()).Info("Tasks imported", "format", report.Format, "created", report.Created, "skipped", report.Skipped, "failed", report.Failed)
+	h.writeJSON(w, http.StatusOK, report)
+}
+
+// importRow creates the task of a row. Rows that look like an open task are
+// skipped unless force is set.
+func (imp *taskImporter) importRow(row taskImportRow, config *Config, force bool) (*Task, string, error) {
+	req, err := imp.request(row)
+	if err != nil {
+		return nil, TaskImportFailed, err
+	}
+	if !force {
+		duplicates, err := imp.h.findDuplicateTasks(req)
+		if err != nil {
+			return nil, TaskImportFailed, err
+		}
+		if len(duplicates) > 0 {
+			return nil, TaskImportSkipped, fmt.Errorf("Similar to %q", duplicates[0].Title)
+		}
+	}
+
+	task, err := imp.h.db.CreateTask(req, config)
+	if err != nil {
+		return nil, TaskImportFailed, fmt.Errorf("Failed to create task: %v", err)
+	}
+	imp.h.hub.BroadcastTaskUpdate(task)
+	return task, TaskImportCreated, nil
+}
@@ -0,0 +1,166 @@
+// taskruns.go keeps every RALPH run of a task as a record of its own. The
+// task itself only holds the state of its latest run: a new start wipes its
+// logs and iterations. A run is recorded when Claude's process starts, with
+// what triggered it and the commit it started from, and completed when the
+// process exits, with its outcome, the iteration reached, the commit it ended
+// on, the token usage of its result events and its own logs (within the log
+// size cap). GET /api/tasks/{id}/runs lists them,
+// GET /api/tasks/{id}/runs/{run}/logs returns the logs of one.
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// runTrigger names what starts a run of task; feedback is the message a
+// continuation was started with
+func runTrigger(task *Task, continuation bool, feedback string) string {
+	switch {
+	case task.RetryAt != nil:
+		return RunTriggerRetry
+	case !continuation && isPlanning(task):
+		return RunTriggerPlan
+	case !continuation:
+		return RunTriggerStart
+	case feedback != "":
+		return RunTriggerContinue
+	default:
+		return RunTriggerResume
+	}
+}
+
+// headCommit returns the commit checked out in dir, "" outside a git repository
+func headCommit(dir string) string {
+	if dir == "" || !IsGitRepository(dir) {
+		return ""
+	}
+	hash, _ := GetCurrentCommitHash(dir)
+	return hash
+}
+
+// beginRun records the run of a process that has just started
+func (r *RalphRunner) beginRun(proc *RalphProcess, task *Task, trigger string) {
+	run := &TaskRun{TaskID: task.ID, Trigger: trigger, StartCommit: headCommit(proc.dir)}
+	if err := r.db.CreateTaskRun(run); err != nil {
+		proc.log.Warn("Failed to record run", "err", err)
+		return
+	}
+	proc.mu.Lock()
+	proc.run = run
+	proc.mu.Unlock()
+
+	if trigger == RunTriggerRetry {
+		if err := r.db.ClearTaskRetry(task.ID); err != nil {
+			proc.log.Warn("Failed to clear retry", "err", err)
+		}
+	}
+}
+
+// finishRun completes the run of a process that has exited. stopped means it
+// was cancelled, by the user, the iteration limit or a shutdown; waitErr is
+// the error of the process, if any.
+func (r *RalphRunner) finishRun(proc *RalphProcess, stopped bool, waitErr error) {
+	proc.mu.Lock()
+	run, drained := proc.run, proc.drained
+	proc.mu.Unlock()
+	if run == nil {
+		return
+	}
+
+	logs, _, err := r.db.GetTaskRunLogs(run.TaskID, run.Number)
+	if err != nil {
+		proc.log.Warn("Failed to read run logs", "err", err)
+	}
+
+	task, _ := r.db.GetTask(run.TaskID)
+	run.Outcome, run.Error, run.FailureClass = runOutcome(task, stopped, drained, waitErr, logs)
+	if task != nil {
+		run.Iterations = task.CurrentIteration
+	}
+	run.EndCommit = headCommit(proc.dir)
+
+	usage := runUsageFromLogs(logs)
+	run.Turns = usage.Turns
+	run.InputTokens = usage.InputTokens
+	run.OutputTokens = usage.OutputTokens
+	run.CostUSD = usage.CostUSD
+	if config, err := r.db.GetConfig(); err == nil {
+		logs = capLogs(logs, config.LogRetention.MaxSizeKB*1024)
+	}
+
+	if err := r.db.FinishTaskRun(run, logs); err != nil {
+		proc.log.Warn("Failed to complete run record", "err", err)
+	}
+	r.endRateLimitStreak(run)
+	r.checkBudgets()
+}
+
+// runOutcome derives how a run ended from the state it left the task in and,
+// without a marker, from its logs; class is set for failed runs
+func runOutcome(task *Task, stopped, drained bool, waitErr error, logs string) (outcome, reason, class string) {
+	switch {
+	case drained:
+		return RunInterrupted, "", ""
+	case task == nil:
+		return RunStopped, "", ""
+	case task.Status == StatusBlocked && strings.HasPrefix(task.Error, "Reached maximum iterations"):
+		return RunIterationLimit, task.Error, ""
+	case task.Status == StatusBlocked:
+		return RunBlocked, task.Error, ""
+	case stopped:
+		return RunStopped, "", ""
+	case isPlanning(task):
+		return RunPlanned, "", ""
+	case task.Status == StatusReview:
+		return RunSuccess, "", ""
+	}
+	if failure := classifyFailure(logs, waitErr); failure.Class != "" {
+		return RunFailed, failure.Reason, failure.Class
+	}
+	return RunExited, "", ""
+}
+
+// HandleTaskRuns handles GET /api/tasks/{id}/runs
+// Lists every run of the task, oldest first, without their logs.
+func (h *Handler) HandleTaskRuns(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	runs, err := h.db.GetTaskRuns(task.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get runs: "+err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, runs)
+}
+
+// HandleTaskRunLogs handles GET /api/tasks/{id}/runs/{run}/logs
+// Returns the logs of one run as plain text.
+func (h *Handler) HandleTaskRunLogs(w http.ResponseWriter, r *http.Request) {
+	number, err := strconv.Atoi(r.PathValue("run"))
+	if err != nil || number < 1 {
+		h.writeError(w, http.StatusBadRequest, "Invalid run number")
+		return
+	}
+	logs, found, err := h.db.GetTaskRunLogs(r.PathValue("id"), number)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get run logs: "+err.Error())
+		return
+	}
+	if !found {
+		h.writeError(w, http.StatusNotFound, "Run not found")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(logs))
+}
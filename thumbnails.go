@@ -0,0 +1,234 @@
+// thumbnails.go generates preview images for attachments: a scaled-down copy
+// for images and a poster frame for videos. Thumbnails are cached next to the
+// uploads in <uploads>/<task>/thumbnails, so board cards can show previews
+// without downloading the original file. Video frames (and image formats the
+// standard library cannot decode, like WebP) need ffmpeg on the PATH.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	thumbnailMaxSize   = 320        // Longest side in pixels
+	thumbnailMaxPixels = 50_000_000 // Larger images are not decoded
+)
+
+var (
+	// errNoThumbnail is returned for attachments that cannot have a preview
+	errNoThumbnail = errors.New("attachment type has no thumbnail")
+
+	// errFFmpegMissing is returned when a thumbnail needs ffmpeg but it is not installed
+	errFFmpegMissing = errors.New("ffmpeg not installed")
+)
+
+// thumbnailMu serializes thumbnail generation, which also bounds the number of ffmpeg processes
+var thumbnailMu sync.Mutex
+
+// thumbnailFilePath returns where the thumbnail of att is cached
+func thumbnailFilePath(att *Attachment) string {
+	return filepath.Join(UploadsDir, att.TaskID, "thumbnails", att.ID+".jpg")
+}
+
+// ensureThumbnail returns the path of the thumbnail of att, generating it if needed
+func (h *Handler) ensureThumbnail(att *Attachment) (string, error) {
+	thumbnailMu.Lock()
+	defer thumbnailMu.Unlock()
+
+	if att.ThumbnailPath != "" {
+		if _, err := os.Stat(att.ThumbnailPath); err == nil {
+			return att.ThumbnailPath, nil
+		}
+	}
+
+	dst := thumbnailFilePath(att)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file so a half-written thumbnail is never served
+	tmp := strings.TrimSuffix(dst, ".jpg") + ".tmp.jpg"
+	var err error
+	switch {
+	case strings.HasPrefix(att.MimeType, "image/"):
+		if err = writeImageThumbnail(att.Path, tmp); err != nil {
+			err = writeFFmpegThumbnail(att.Path, tmp, false)
+		}
+	case strings.HasPrefix(att.MimeType, "video/"):
+		err = writeFFmpegThumbnail(att.Path, tmp, true)
+	default:
+		err = errNoThumbnail
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	if err := h.db.SetAttachmentThumbnail(att.ID, dst); err != nil {
+		return "", err
+	}
+	att.ThumbnailPath = dst
+	return dst, nil
+}
+
+// generateThumbnail creates the thumbnail after an upload and pushes the task to the board
+func (h *Handler) generateThumbnail(att Attachment) {
+	if _, err := h.ensureThumbnail(&att); err != nil {
+		if !errors.Is(err, errNoThumbnail) {
+			componentLog("thumbnails").Debug("No preview", "file", att.Filename, "err", err)
+		}
+		return
+	}
+
+	task, _ := h.db.GetTask(att.TaskID)
+	if task != nil {
+		task.Attachments, _ = h.db.GetAttachmentsByTask(att.TaskID)
+		h.hub.BroadcastTaskUpdate(task)
+	}
+}
+
+// removeThumbnail deletes the cached thumbnail of att
+func removeThumbnail(att *Attachment) {
+	if att.ThumbnailPath == "" {
+		return
+	}
+	if err := os.Remove(att.ThumbnailPath); err != nil && !os.IsNotExist(err) {
+		componentLog("thumbnails").Warn("Failed to delete thumbnail", "path", att.ThumbnailPath, "err", err)
+	}
+}
+
+// writeImageThumbnail scales a PNG, JPEG or GIF (first frame) down to a JPEG
+func writeImageThumbnail(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return err
+	}
+	if config.Width*config.Height > thumbnailMaxPixels {
+		return fmt.Errorf("image too large (%dx%d)", config.Width, config.Height)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(out, scaleImage(img, thumbnailMaxSize), &jpeg.Options{Quality: 80}); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// scaleImage fits src into maxSize x maxSize by averaging the covered pixels.
+// Transparent areas are flattened onto white, since JPEG has no alpha channel.
+func scaleImage(src image.Image, maxSize int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if longest := max(w, h); longest > maxSize {
+		dw = max(1, w*maxSize/longest)
+		dh = max(1, h*maxSize/longest)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0 := b.Min.Y + y*h/dh
+		sy1 := max(sy0+1, b.Min.Y+(y+1)*h/dh)
+		for x := 0; x < dw; x++ {
+			sx0 := b.Min.X + x*w/dw
+			sx1 := max(sx0+1, b.Min.X+(x+1)*w/dw)
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			// Colors are alpha-premultiplied, so adding the missing alpha composites onto white
+			white := 0xffff - a/n
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8((r/n + white) >> 8),
+				G: uint8((g/n + white) >> 8),
+				B: uint8((bl/n + white) >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
+
+// writeFFmpegThumbnail extracts a single frame with ffmpeg. For videos the frame
+// at one second is preferred over the often black first frame.
+func writeFFmpegThumbnail(src, dst string, video bool) error {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return errFFmpegMissing
+	}
+
+	seeks := []string{""}
+	if video {
+		seeks = []string{"1", "0"} // Clips shorter than a second fall back to the first frame
+	}
+	scale := fmt.Sprintf("scale='min(%d,iw)':'min(%d,ih)':force_original_aspect_ratio=decrease", thumbnailMaxSize, thumbnailMaxSize)
+
+	for _, seek := range seeks {
+		args := []string{"-y", "-v", "error"}
+		if seek != "" {
+			args = append(args, "-ss", seek)
+		}
+		args = append(args, "-i", src, "-frames:v", "1", "-vf", scale, "-q:v", "4", dst)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		output, runErr := exec.CommandContext(ctx, ffmpeg, args...).CombinedOutput()
+		cancel()
+
+		if info, statErr := os.Stat(dst); runErr == nil && statErr == nil && info.Size() > 0 {
+			return nil
+		}
+		err = fmt.Errorf("ffmpeg: %v %s", runErr, strings.TrimSpace(string(output)))
+	}
+	return err
+}
+
+// serveThumbnail handles GET /api/tasks/{id}/attachments/{attachmentId}/thumbnail
+func (h *Handler) serveThumbnail(w http.ResponseWriter, r *http.Request, att *Attachment) {
+	path, err := h.ensureThumbnail(att)
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "No thumbnail available: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeFile(w, r, path)
+}
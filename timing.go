@@ -0,0 +1,133 @@
+// timing.go tracks how long tasks spend in each status. Every status change
+// is stored as a task event; the wall-clock time per status is derived from
+// them. A column can have an SLA in hours: every FORGE_SLA_CHECK_INTERVAL the
+// runner looks for tasks that sit in such a column for longer, reports each
+// stay once in the task log and as an sla_alert WebSocket message.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// defaultSLACheckInterval is how often tasks are checked against column SLAs
+const defaultSLACheckInterval = 5 * time.Minute
+
+// slaCheckIntervalFromEnv reads FORGE_SLA_CHECK_INTERVAL (e.g. 1m; 0 disables the check)
+func slaCheckIntervalFromEnv() time.Duration {
+	v := os.Getenv("FORGE_SLA_CHECK_INTERVAL")
+	if v == "" {
+		return defaultSLACheckInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("sla").Warn("Ignoring invalid FORGE_SLA_CHECK_INTERVAL", "value", v)
+		return defaultSLACheckInterval
+	}
+	return d
+}
+
+// computeTaskTiming sums up the time between the task's status changes. The
+// current stay lasts until now.
+func computeTaskTiming(task *Task, events []TaskEvent, now time.Time) *TaskTiming {
+	timing := &TaskTiming{
+		TaskID:       task.ID,
+		Status:       task.Status,
+		TotalSeconds: make(map[TaskStatus]int64),
+		Events:       events,
+	}
+	for i, event := range events {
+		end := now
+		if i+1 < len(events) {
+			end = events[i+1].CreatedAt
+		}
+		timing.TotalSeconds[event.Status] += int64(max(end.Sub(event.CreatedAt), 0) / time.Second)
+	}
+	if len(events) > 0 {
+		timing.Since = events[len(events)-1].CreatedAt
+	} else {
+		timing.Since = task.UpdatedAt
+	}
+	timing.CurrentSeconds = int64(max(now.Sub(timing.Since), 0) / time.Second)
+	return timing
+}
+
+// formatStay renders how long a task has been in a column, e.g. "26h" or "3d 4h"
+func formatStay(d time.Duration) string {
+	hours := int(d / time.Hour)
+	if hours < 24 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dd %dh", hours/24, hours%24)
+}
+
+// HandleTaskTiming handles GET /api/tasks/{id}/timing
+// Returns the time the task spent in each status and whether it is overdue.
+func (h *Handler) HandleTaskTiming(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	events, err := h.db.GetTaskEvents(task.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task events: "+err.Error())
+		return
+	}
+	now := time.Now()
+	timing := computeTaskTiming(task, events, now)
+	if column, _ := h.db.GetBoardColumn(task.Status); column != nil && column.SLAHours > 0 {
+		timing.SLAHours = column.SLAHours
+		timing.Overdue = now.Sub(timing.Since) >= time.Duration(column.SLAHours)*time.Hour
+	}
+	h.writeJSON(w, http.StatusOK, timing)
+}
+
+// RunSLACheck checks tasks against the SLAs of their columns every interval until stop is closed
+func (r *RalphRunner) RunSLACheck(interval time.Duration, stop <-chan struct{}) {
+	if interval == 0 {
+		componentLog("sla").Info("SLA check disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.CheckSLAs()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// CheckSLAs reports tasks that sit in a column longer than its SLA, once per stay
+func (r *RalphRunner) CheckSLAs() {
+	breaches, err := r.db.GetSLABreaches(time.Now())
+	if err != nil {
+		componentLog("sla").Error("Failed to check SLAs", "err", err)
+		return
+	}
+
+	for _, b := range breaches {
+		if err := r.db.MarkTaskEventAlerted(b.EventID); err != nil {
+			componentLog("sla").Error("Failed to mark SLA alert", "task_id", b.TaskID, "err", err)
+			continue
+		}
+		stay := formatStay(time.Since(b.Since))
+		msg := fmt.Sprintf("\n[FORGE] SLA exceeded: in %s for %s (SLA %dh)\n", b.ColumnName, stay, b.SLAHours)
+		r.db.AppendTaskLogs(b.TaskID, msg)
+		r.hub.BroadcastLog(b.TaskID, msg)
+		r.hub.BroadcastSLAAlert(b.TaskID, fmt.Sprintf("in %s for %s, SLA is %dh", b.ColumnName, stay, b.SLAHours))
+		r.taskLog(b.TaskID).Warn("SLA exceeded", "status", b.Status, "since", b.Since, "sla_hours", b.SLAHours)
+	}
+}
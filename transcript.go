@@ -0,0 +1,222 @@
+// transcript.go reconstructs the conversation of a run (see taskruns.go), so
+// users can audit exactly what Claude was told and did. Every message written
+// to Claude's stdin, the prompt with its protected-branch, protected-path and
+// attachment sections as well as feedback sent to the live session, is stored
+// with the run as sent. The rest of the conversation is parsed from the run's
+// stream-json logs: Claude's text, its tool calls and their results, and the
+// result that ends each answer. Feedback is answered after the current answer,
+// so message n+1 is placed after the n-th result event.
+// GET /api/tasks/{id}/runs/{run}/transcript returns it.
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunMessages are the messages sent to Claude during a run, stored as a JSON array
+type RunMessages []RunMessage
+
+// Value stores the messages as JSON, none as an empty string
+func (m RunMessages) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal([]RunMessage(m))
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads messages stored by Value
+func (m *RunMessages) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into RunMessages", src)
+	}
+	*m = nil
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, (*[]RunMessage)(m))
+}
+
+// recordRunMessage stores a message written to the process's stdin with its run
+func (r *RalphRunner) recordRunMessage(proc *RalphProcess, text string) {
+	proc.mu.Lock()
+	run := proc.run
+	proc.mu.Unlock()
+	if run == nil {
+		return
+	}
+	if err := r.db.AddTaskRunMessage(run.ID, RunMessage{Text: text, SentAt: time.Now()}); err != nil {
+		proc.log.Warn("Failed to record message in the run transcript", "err", err)
+	}
+}
+
+// transcriptEvent is the part of a stream-json output line the transcript uses
+type transcriptEvent struct {
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"`
+	Model   string `json:"model"`
+	Result  string `json:"result"`
+	IsError bool   `json:"is_error"`
+	Message struct {
+		Model   string `json:"model"`
+		Content []struct {
+			Type      string          `json:"type"`
+			Text      string          `json:"text"`
+			Thinking  string          `json:"thinking"`
+			ID        string          `json:"id"`
+			Name      string          `json:"name"`
+			Input     json.RawMessage `json:"input"`
+			ToolUseID string          `json:"tool_use_id"`
+			Content   json.RawMessage `json:"content"`
+			IsError   bool            `json:"is_error"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// toolResultText returns the content of a tool result, which is a string or a
+// list of content blocks
+func toolResultText(content json.RawMessage) string {
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return text
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(content, &blocks) != nil {
+		return string(content)
+	}
+	var parts []string
+	for _, block := range blocks {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		} else {
+			parts = append(parts, "["+block.Type+"]")
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// buildTranscript interleaves the messages sent to Claude with the turns
+// parsed from the run's logs and returns the model that ran
+func buildTranscript(logs string, messages []RunMessage) ([]TranscriptTurn, string) {
+	turns := []TranscriptTurn{}
+	var model string
+	next := 0
+	sendNext := func() {
+		if next < len(messages) {
+			msg := messages[next]
+			turns = append(turns, TranscriptTurn{Role: "user", Text: msg.Text, SentAt: &msg.SentAt})
+			next++
+		}
+	}
+	// Lines that are not stream-json, e.g. stderr or plain text output
+	addText := func(role, line string) {
+		if last := len(turns) - 1; last >= 0 && turns[last].Role == role {
+			turns[last].Text += "\n" + line
+			return
+		}
+		turns = append(turns, TranscriptTurn{Role: role, Text: line})
+	}
+
+	sendNext()
+	for _, line := range strings.Split(logs, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var event transcriptEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil || event.Type == "" {
+			if strings.HasPrefix(strings.TrimSpace(line), "[FORGE") {
+				addText("forge", strings.TrimSpace(line))
+			} else {
+				addText("output", line)
+			}
+			continue
+		}
+
+		switch event.Type {
+		case "system":
+			if event.Subtype == "init" && event.Model != "" {
+				model = event.Model
+			}
+		case "assistant":
+			if model == "" {
+				model = event.Message.Model
+			}
+			for _, c := range event.Message.Content {
+				switch c.Type {
+				case "text":
+					turns = append(turns, TranscriptTurn{Role: "assistant", Text: c.Text})
+				case "thinking":
+					turns = append(turns, TranscriptTurn{Role: "thinking", Text: c.Thinking})
+				case "tool_use":
+					turns = append(turns, TranscriptTurn{Role: "tool_use", Tool: c.Name, ToolID: c.ID, Input: c.Input})
+				}
+			}
+		case "user":
+			for _, c := range event.Message.Content {
+				if c.Type == "tool_result" {
+					turns = append(turns, TranscriptTurn{Role: "tool_result", ToolID: c.ToolUseID,
+						Text: toolResultText(c.Content), IsError: c.IsError})
+				}
+			}
+		case "result":
+			turns = append(turns, TranscriptTurn{Role: "result", Text: event.Result, IsError: event.IsError})
+			sendNext()
+		}
+	}
+	// Messages Claude had not answered yet when the run ended
+	for next < len(messages) {
+		sendNext()
+	}
+	return turns, model
+}
+
+// HandleTaskRunTranscript handles GET /api/tasks/{id}/runs/{run}/transcript
+// Returns the prompt exactly as sent and the conversation of the run.
+func (h *Handler) HandleTaskRunTranscript(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+	number, err := strconv.Atoi(r.PathValue("run"))
+	if err != nil || number < 1 {
+		h.writeError(w, http.StatusBadRequest, "Invalid run number")
+		return
+	}
+	logs, found, err := h.db.GetTaskRunLogs(taskID, number)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get run logs: "+err.Error())
+		return
+	}
+	if !found {
+		h.writeError(w, http.StatusNotFound, "Run not found")
+		return
+	}
+	messages, err := h.db.GetTaskRunMessages(taskID, number)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get run messages: "+err.Error())
+		return
+	}
+
+	transcript := RunTranscript{TaskID: taskID, Run: number}
+	if len(messages) > 0 {
+		transcript.Prompt = messages[0].Text
+	}
+	transcript.Turns, transcript.Model = buildTranscript(logs, messages)
+	h.writeJSON(w, http.StatusOK, transcript)
+}
@@ -0,0 +1,272 @@
+// triage.go explains why a task got stuck. When RALPH reports [BLOCKED] or
+// runs into the iteration limit, a short Claude run without its file editing
+// tools (see askClaude in assist.go) reads the task, the reason it stopped
+// and the end of its conversation, and proposes what to do next: add context,
+// split the task, do a step by hand or retry with a change. The triage waits
+// for the blocked process to exit, is stored on the task together with the
+// error it belongs to, and is announced with a task_triaged notification.
+// A new error or a new run discards it. Config.BlockedTriage turns the
+// automatic triage off; POST /api/tasks/{id}/triage runs it on demand.
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// triageTimeout bounds the Claude run that triages a blocked task
+const triageTimeout = 3 * time.Minute
+
+// triageTurns is how many turns from the end of the conversation Claude sees,
+// triageTurnSize how much of each
+const (
+	triageTurns    = 40
+	triageTurnSize = 1500
+)
+
+// maxTriageActions is the most next actions a triage keeps
+const maxTriageActions = 5
+
+// triageActionKinds are the kinds of next actions a triage can propose
+var triageActionKinds = map[string]bool{
+	"context": true, // The task needs information the user has to add
+	"split":   true, // The task is too large and should be split (see split.go)
+	"manual":  true, // A step the user has to do outside of RALPH
+	"retry":   true, // Run it again, with a change to the task
+}
+
+// Value stores the triage as JSON
+func (t TaskTriage) Value() (driver.Value, error) {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// triageColumn scans the stored triage of a task, nil if there is none
+type triageColumn struct{ triage **TaskTriage }
+
+// Scan reads a triage stored by TaskTriage.Value
+func (c triageColumn) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into a triage", src)
+	}
+	*c.triage = nil
+	if len(raw) == 0 {
+		return nil
+	}
+	var triage TaskTriage
+	if err := json.Unmarshal(raw, &triage); err != nil {
+		return err
+	}
+	*c.triage = &triage
+	return nil
+}
+
+// conversationExcerpt renders the last turns of a run for the triage prompt
+func conversationExcerpt(logs string) string {
+	turns, _ := buildTranscript(logs, nil)
+	if len(turns) > triageTurns {
+		turns = turns[len(turns)-triageTurns:]
+	}
+
+	var sb strings.Builder
+	for _, turn := range turns {
+		text := turn.Text
+		switch turn.Role {
+		case "thinking":
+			continue
+		case "tool_use":
+			text = turn.Tool + " " + string(turn.Input)
+		case "tool_result":
+			if turn.IsError {
+				text = "(error) " + text
+			}
+		}
+		text = strings.TrimSpace(text)
+		if len(text) > triageTurnSize {
+			text = text[:triageTurnSize] + " [...]"
+		}
+		sb.WriteString(fmt.Sprintf("[%s] %s\n\n", turn.Role, text))
+	}
+	return sb.String()
+}
+
+// BuildTriagePrompt generates the prompt of the run that triages a blocked task
+func BuildTriagePrompt(task *Task) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("# Triage: %s\n\n", task.Title))
+
+	if task.Description != "" {
+		sb.WriteString("## Description\n\n")
+		sb.WriteString(task.Description)
+		sb.WriteString("\n\n")
+	}
+
+	if task.AcceptanceCriteria != "" {
+		sb.WriteString("## Acceptance Criteria\n\n")
+		sb.WriteString(task.AcceptanceCriteria)
+		sb.WriteString("\n\n")
+	}
+
+	sb.WriteString("## Why It Stopped\n\n")
+	sb.WriteString(fmt.Sprintf("%s (after iteration %d of %d)\n\n", strings.TrimSpace(task.Error), task.CurrentIteration, task.MaxIterations))
+
+	if excerpt := conversationExcerpt(task.Logs); excerpt != "" {
+		sb.WriteString("## End of the Run\n\n")
+		sb.WriteString(excerpt)
+	}
+
+	sb.WriteString("## Instructions\n\n")
+	sb.WriteString("An autonomous agent worked on this task and got stuck. Do NOT create, modify or delete any files and do not commit. ")
+	sb.WriteString("Read the codebase as needed, then explain in two or three sentences why the agent got stuck, ")
+	sb.WriteString("and propose one to three next actions for the user, most promising first. Each action has one of these kinds:\n\n")
+	sb.WriteString("- `context`: information or a decision the task is missing\n")
+	sb.WriteString("- `split`: the task is too large and should be split into smaller tasks\n")
+	sb.WriteString("- `manual`: a step the user has to do themselves, e.g. credentials, access or a service that is down\n")
+	sb.WriteString("- `retry`: run the task again with a concrete change to its description\n\n")
+	sb.WriteString("Answer with a single JSON object and nothing else, in this format:\n\n")
+	sb.WriteString("```json\n")
+	sb.WriteString(`{"summary": "...", "actions": [{"kind": "context", "text": "..."}]}`)
+	sb.WriteString("\n```\n")
+
+	return sb.String()
+}
+
+// parseTriage reads the JSON object of Claude's answer
+func parseTriage(text string) (*TaskTriage, error) {
+	data, err := extractJSONObject(text)
+	if err != nil {
+		return nil, err
+	}
+	var triage TaskTriage
+	if err := json.Unmarshal(data, &triage); err != nil {
+		return nil, fmt.Errorf("Claude's answer is not a valid triage: %v", err)
+	}
+	triage.Summary = strings.TrimSpace(triage.Summary)
+	if triage.Summary == "" {
+		return nil, fmt.Errorf("Claude's triage has no summary")
+	}
+
+	actions := []TriageAction{}
+	for _, action := range triage.Actions {
+		action.Kind = strings.ToLower(strings.TrimSpace(action.Kind))
+		action.Text = strings.TrimSpace(action.Text)
+		if action.Text == "" {
+			continue
+		}
+		if !triageActionKinds[action.Kind] {
+			action.Kind = "manual"
+		}
+		actions = append(actions, action)
+	}
+	if len(actions) > maxTriageActions {
+		actions = actions[:maxTriageActions]
+	}
+	triage.Actions = actions
+	return &triage, nil
+}
+
+// triageTask asks Claude why the blocked task got stuck and stores the
+// answer; nil without an error if the task is no longer blocked with the
+// same error
+func (r *RalphRunner) triageTask(ctx context.Context, task *Task) (*TaskTriage, error) {
+	answer, err := r.askClaude(ctx, r.taskProjectDir(task), BuildTriagePrompt(task), triageTimeout)
+	if err != nil {
+		return nil, err
+	}
+	triage, err := parseTriage(answer)
+	if err != nil {
+		return nil, err
+	}
+	triage.Error = task.Error
+	triage.CreatedAt = time.Now()
+
+	saved, err := r.db.UpdateTaskTriage(task.ID, triage)
+	if err != nil || !saved {
+		return nil, err
+	}
+	if updated, _ := r.db.GetTask(task.ID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+	r.hub.BroadcastTaskTriaged(task.ID, triage.Summary)
+	return triage, nil
+}
+
+// triageBlocked triages a task RALPH has just blocked, once its process has
+// exited, if Config.BlockedTriage is on
+func (r *RalphRunner) triageBlocked(taskID string) {
+	config, err := r.db.GetConfig()
+	if err != nil || !config.BlockedTriage {
+		return
+	}
+	logger := r.taskLog(taskID)
+
+	r.mu.RLock()
+	proc, running := r.processes[taskID]
+	r.mu.RUnlock()
+	if running {
+		<-proc.done
+	}
+
+	task, err := r.db.GetTask(taskID)
+	if err != nil || task == nil || task.Status != StatusBlocked {
+		return
+	}
+	triage, err := r.triageTask(context.Background(), task)
+	if err != nil {
+		logger.Warn("Failed to triage blocked task", "err", err)
+		return
+	}
+	if triage != nil {
+		logger.Info("Triaged blocked task", "actions", len(triage.Actions))
+	}
+}
+
+// HandleTaskTriage handles POST /api/tasks/{id}/triage
+// Asks Claude why the blocked task got stuck, stores and returns the triage.
+func (h *Handler) HandleTaskTriage(w http.ResponseWriter, r *http.Request) {
+	task, err := h.db.GetTask(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get task: "+err.Error())
+		return
+	}
+	if task == nil {
+		h.writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if task.Status != StatusBlocked {
+		h.writeError(w, http.StatusBadRequest, "Only blocked tasks can be triaged")
+		return
+	}
+	if h.runner.IsRunning(task.ID) {
+		h.writeError(w, http.StatusConflict, "The task is still running")
+		return
+	}
+
+	triage, err := h.runner.triageTask(r.Context(), task)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to triage task: "+err.Error())
+		return
+	}
+	if triage == nil {
+		h.writeError(w, http.StatusConflict, "The task changed while it was triaged")
+		return
+	}
+	logFrom(r.Context()).Info("Triaged blocked task", "task_id", task.ID, "actions", len(triage.Actions))
+	h.writeJSON(w, http.StatusOK, triage)
+}
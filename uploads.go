@@ -0,0 +1,225 @@
+// uploads.go handles files that are uploaded before their task exists, e.g.
+// screenshots pasted while composing a task. POST /api/uploads stores the file
+// as a pending upload and returns a Markdown snippet with an `upload:<id>`
+// reference. When a task is saved with such a reference, the upload becomes an
+// attachment of the task and the reference is rewritten to `attachment:<id>`.
+// Uploads that are never claimed are deleted after FORGE_UPLOAD_TTL.
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// uploadScheme prefixes references to pending uploads
+const uploadScheme = "upload:"
+
+// defaultUploadTTL is how long unclaimed uploads are kept
+const defaultUploadTTL = 24 * time.Hour
+
+// uploadRefRe matches upload references in task text
+var uploadRefRe = regexp.MustCompile(uploadScheme + `([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})`)
+
+// uploadMu serializes claiming uploads and the cleanup, so a file is never
+// deleted while it is moved to a task
+var uploadMu sync.Mutex
+
+// pendingUploadsDir is where uploads are stored until a task claims them
+func pendingUploadsDir() string {
+	return filepath.Join(UploadsDir, "pending")
+}
+
+// uploadTTLFromEnv reads FORGE_UPLOAD_TTL (e.g. 2h; 0 keeps unclaimed uploads forever)
+func uploadTTLFromEnv() time.Duration {
+	v := os.Getenv("FORGE_UPLOAD_TTL")
+	if v == "" {
+		return defaultUploadTTL
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		componentLog("uploads").Warn("Ignoring invalid FORGE_UPLOAD_TTL", "value", v)
+		return defaultUploadTTL
+	}
+	return d
+}
+
+// HandleUploads handles POST /api/uploads
+func (h *Handler) HandleUploads(w http.ResponseWriter, r *http.Request) {
+	saved, ok := h.saveUploadedFile(w, r, pendingUploadsDir())
+	if !ok {
+		return
+	}
+	upload := &Upload{
+		ID:        saved.ID,
+		Filename:  saved.Filename,
+		MimeType:  saved.MimeType,
+		Size:      saved.Size,
+		Path:      saved.Path,
+		CreatedAt: saved.CreatedAt,
+	}
+	if err := h.db.CreateUpload(upload); err != nil {
+		os.Remove(upload.Path) // Cleanup on failure
+		h.writeError(w, http.StatusInternalServerError, "Failed to save upload: "+err.Error())
+		return
+	}
+
+	resp := UploadResponse{
+		Upload:   upload,
+		URL:      "/api/uploads/" + upload.ID,
+		Markdown: uploadMarkdown(upload),
+	}
+	if h.uploadTTL > 0 {
+		resp.ExpiresAt = upload.CreatedAt.Add(h.uploadTTL)
+	}
+	h.writeJSON(w, http.StatusCreated, resp)
+}
+
+// HandleUpload handles GET /api/uploads/{id}
+func (h *Handler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	upload, err := h.db.GetUpload(id)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get upload: "+err.Error())
+		return
+	}
+	if upload == nil {
+		h.writeError(w, http.StatusNotFound, "Upload not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", attachmentContentType(upload.MimeType))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, upload.Path)
+}
+
+// uploadMarkdown returns the snippet that embeds upload in a description
+func uploadMarkdown(upload *Upload) string {
+	name := strings.NewReplacer("[", "", "]", "", "\n", " ").Replace(upload.Filename)
+	ref := "[" + name + "](" + uploadScheme + upload.ID + ")"
+	switch attachmentKind(upload.MimeType) {
+	case AttachmentKindImage, AttachmentKindVideo:
+		return "!" + ref
+	}
+	return ref
+}
+
+// claimUploads attaches the uploads referenced in texts to the task and rewrites
+// their references to attachment references. Returns true if a text changed.
+// Unknown or expired references are left as they are.
+func (h *Handler) claimUploads(taskID string, texts ...*string) bool {
+	uploadMu.Lock()
+	defer uploadMu.Unlock()
+
+	claimed := make(map[string]bool)
+	for _, text := range texts {
+		if text == nil {
+			continue
+		}
+		for _, m := range uploadRefRe.FindAllStringSubmatch(*text, -1) {
+			id := m[1]
+			if _, seen := claimed[id]; seen {
+				continue
+			}
+			claimed[id] = h.claimUpload(taskID, id)
+		}
+	}
+
+	changed := false
+	for _, text := range texts {
+		if text == nil {
+			continue
+		}
+		rewritten := uploadRefRe.ReplaceAllStringFunc(*text, func(ref string) string {
+			id := strings.TrimPrefix(ref, uploadScheme)
+			if claimed[id] {
+				return attachmentScheme + id
+			}
+			return ref
+		})
+		if rewritten != *text {
+			*text = rewritten
+			changed = true
+		}
+	}
+	return changed
+}
+
+// claimUpload moves a pending upload into the task's directory and turns it into
+// an attachment. The caller holds uploadMu.
+func (h *Handler) claimUpload(taskID, id string) bool {
+	upload, err := h.db.GetUpload(id)
+	if err != nil || upload == nil {
+		return false
+	}
+
+	dir := filepath.Join(UploadsDir, taskID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		componentLog("uploads").Error("Failed to create upload directory", "err", err)
+		return false
+	}
+	dst := filepath.Join(dir, filepath.Base(upload.Path))
+	if err := os.Rename(upload.Path, dst); err != nil {
+		componentLog("uploads").Error("Failed to move upload", "upload", id, "err", err)
+		return false
+	}
+
+	att, err := h.db.ClaimUpload(id, taskID, dst)
+	if err != nil {
+		componentLog("uploads").Error("Failed to attach upload", "upload", id, "err", err)
+		os.Rename(dst, upload.Path)
+		return false
+	}
+
+	// Preview for board cards; broadcasts the task again once ready
+	go h.generateThumbnail(*att)
+	return true
+}
+
+// RunUploadCleanup deletes unclaimed uploads older than the TTL until stop is closed
+func (h *Handler) RunUploadCleanup(stop <-chan struct{}) {
+	if h.uploadTTL == 0 {
+		componentLog("uploads").Info("Cleanup of unclaimed uploads disabled")
+		return
+	}
+
+	ticker := time.NewTicker(min(h.uploadTTL, time.Hour))
+	defer ticker.Stop()
+
+	for {
+		h.cleanupUploads()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// cleanupUploads deletes all uploads that expired without being claimed
+func (h *Handler) cleanupUploads() {
+	uploadMu.Lock()
+	defer uploadMu.Unlock()
+
+	expired, err := h.db.GetUploadsBefore(time.Now().Add(-h.uploadTTL))
+	if err != nil {
+		componentLog("uploads").Error("Cleanup failed", "err", err)
+		return
+	}
+	for _, upload := range expired {
+		if err := os.Remove(upload.Path); err != nil && !os.IsNotExist(err) {
+			componentLog("uploads").Warn("Failed to delete upload file", "path", upload.Path, "err", err)
+			continue
+		}
+		if err := h.db.DeleteUpload(upload.ID); err != nil {
+			componentLog("uploads").Error("Failed to delete upload", "upload", upload.ID, "err", err)
+		}
+	}
+	if len(expired) > 0 {
+		componentLog("uploads").Info("Deleted unclaimed uploads", "count", len(expired))
+	}
+}
@@ -0,0 +1,316 @@
+// version.go proposes and applies semantic version bumps. The commits since
+// the last tag decide the bump: conventional-commit prefixes first (a "!" or
+// a BREAKING CHANGE footer is major, feat is minor, everything else patch),
+// the type of the commit's task otherwise (Feature is minor). Before 1.0.0 a
+// breaking change only bumps the minor version. Version files such as
+// package.json or version.go are updated with a targeted edit of their
+// version string. GET /api/projects/{id}/version shows the proposal, POST
+// applies it: edit, commit and tag. Releases use the same proposal.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Semver bumps, from smallest to largest
+const (
+	BumpPatch = "patch"
+	BumpMinor = "minor"
+	BumpMajor = "major"
+)
+
+// defaultReleaseTag is the tag of a project's first release
+const defaultReleaseTag = "v0.1.0"
+
+// semverPattern matches versions with an optional "v" prefix
+var semverPattern = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)$`)
+
+// conventionalPattern matches a conventional commit subject like "feat(api)!: ..."
+var conventionalPattern = regexp.MustCompile(`^(\w+)(\([^)]*\))?(!)?:\s`)
+
+// versionFileTemplate describes where a kind of version file keeps its version:
+// the first submatch of pattern is replaced
+type versionFileTemplate struct {
+	glob    string
+	pattern *regexp.Regexp
+}
+
+// versionFileTemplates are the version files FORGE keeps in sync with tags
+var versionFileTemplates = []versionFileTemplate{
+	{"package.json", regexp.MustCompile(`"version"\s*:\s*"([^"]+)"`)},
+	{"Cargo.toml", regexp.MustCompile(`(?m)^version\s*=\s*"([^"]+)"`)},
+	{"pyproject.toml", regexp.MustCompile(`(?m)^version\s*=\s*"([^"]+)"`)},
+	{"VERSION", regexp.MustCompile(`^\s*(\S+)`)},
+	{"{,cmd/*/,internal/**/,pkg/**/}version.go", regexp.MustCompile(`(?m)^\s*(?:const\s+|var\s+)?[Vv]ersion\s*(?:string\s*)?=\s*"([^"]+)"`)},
+}
+
+// bumpRank orders bumps so the largest one of a release wins
+var bumpRank = map[string]int{BumpPatch: 1, BumpMinor: 2, BumpMajor: 3}
+
+// HandleProjectVersion handles GET/POST /api/projects/{id}/version
+// GET proposes the next version; POST applies it, optionally with a fixed bump.
+func (h *Handler) HandleProjectVersion(w http.ResponseWriter, r *http.Request) {
+	project, err := h.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get project: "+err.Error())
+		return
+	}
+	if project == nil {
+		h.writeError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if !IsGitRepository(project.Path) {
+		h.writeError(w, http.StatusBadRequest, "Project is not a git repository")
+		return
+	}
+
+	var req BumpVersionRequest
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+	} else {
+		req.Bump = r.URL.Query().Get("bump")
+	}
+	if req.Bump != "" && bumpRank[req.Bump] == 0 {
+		h.writeError(w, http.StatusBadRequest, "Invalid bump: "+req.Bump+" (use major, minor or patch)")
+		return
+	}
+
+	previous := latestReleaseTag(project.Path)
+	commits, err := h.releaseCommits(project.Path, previous)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read git log: "+err.Error())
+		return
+	}
+	proposal := proposeVersion(project.Path, previous, commits, req.Bump)
+
+	if r.Method == http.MethodGet {
+		h.writeJSON(w, http.StatusOK, proposal)
+		return
+	}
+
+	if proposal.Next == "" {
+		h.writeError(w, http.StatusBadRequest, "Cannot derive the next version from tag "+previous)
+		return
+	}
+	if len(commits) == 0 && previous != "" {
+		h.writeError(w, http.StatusBadRequest, "No commits since "+previous)
+		return
+	}
+	if err := checkNewTag(project.Path, proposal.Next); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hash, err := applyVersionBump(project.Path, proposal.Next, commitMessage(project, nil, "Bump version to "+proposal.Next))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to bump version: "+err.Error())
+		return
+	}
+	proposal.CommitHash = hash
+	proposal.Files = findVersionFiles(project.Path)
+	if req.Push {
+		if err := pushRelease(h.db, project, proposal.Next); err != nil {
+			h.writeError(w, pushErrorStatus(err), err.Error())
+			return
+		}
+	}
+
+	componentLog("release").Info("Version bumped", "project", project.Name, "version", proposal.Next, "bump", proposal.Bump)
+	if updated, _ := h.db.GetProject(project.ID); updated != nil {
+		h.hub.BroadcastProjectUpdate(updated)
+	}
+	h.writeJSON(w, http.StatusOK, proposal)
+}
+
+// applyVersionBump updates the version files, commits only them with message
+// and tags the result
+func applyVersionBump(path, tag, message string) (string, error) {
+	defer lockRepo(path, "version bump")()
+	defer gitStatus.Invalidate(path)
+
+	changed, err := updateVersionFiles(path, tag)
+	if err != nil {
+		return "", err
+	}
+	steps := [][]string{{"tag", "-a", tag, "-m", "Version " + tag}}
+	if len(changed) > 0 {
+		// Only the version files go into the commit, whatever else is modified
+		steps = append([][]string{
+			append([]string{"add", "--"}, changed...),
+			append([]string{"commit", "-m", message, "--"}, changed...),
+		}, steps...)
+	}
+	for _, args := range steps {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = path
+		if output, err := runSigned(cmd); err != nil {
+			return "", gitFailed(args[0], err, output)
+		}
+	}
+	return GetCurrentCommitHash(path)
+}
+
+// proposeVersion derives the next version from the commits since previous.
+// bump overrides the derived bump. Next is "" if previous is not a version.
+func proposeVersion(path, previous string, commits []releaseCommit, bump string) VersionProposal {
+	proposal := VersionProposal{Current: previous, Commits: len(commits), Files: findVersionFiles(path)}
+
+	derived := BumpPatch
+	for _, commit := range commits {
+		b := commitBump(commit)
+		switch b {
+		case BumpMajor:
+			proposal.Breaking++
+		case BumpMinor:
+			proposal.Features++
+		default:
+			proposal.Patches++
+		}
+		if bumpRank[b] > bumpRank[derived] {
+			derived = b
+		}
+	}
+	proposal.Bump = derived
+	if bump != "" {
+		proposal.Bump = bump
+	}
+
+	if previous == "" {
+		// The first release takes the version the project already declares
+		proposal.Next = defaultReleaseTag
+		for _, file := range proposal.Files {
+			if semverPattern.MatchString(file.Version) {
+				proposal.Next = "v" + strings.TrimPrefix(file.Version, "v")
+				break
+			}
+		}
+		return proposal
+	}
+	proposal.Next = bumpVersion(previous, proposal.Bump)
+	return proposal
+}
+
+// commitBump returns the bump a single commit calls for
+func commitBump(commit releaseCommit) string {
+	if commit.Breaking {
+		return BumpMajor
+	}
+	if m := conventionalPattern.FindStringSubmatch(commit.Subject); m != nil {
+		switch {
+		case m[3] == "!":
+			return BumpMajor
+		case m[1] == "feat":
+			return BumpMinor
+		default:
+			return BumpPatch
+		}
+	}
+	for _, typeID := range commit.TaskTypeIDs {
+		if typeID == "type-feature" {
+			return BumpMinor
+		}
+	}
+	return BumpPatch
+}
+
+// bumpVersion applies bump to version, keeping a "v" prefix; "" if version
+// is not a semantic version. Below 1.0.0 a major bump raises the minor version.
+func bumpVersion(version, bump string) string {
+	m := semverPattern.FindStringSubmatch(version)
+	if m == nil {
+		return ""
+	}
+	major, _ := strconv.Atoi(m[2])
+	minor, _ := strconv.Atoi(m[3])
+	patch, _ := strconv.Atoi(m[4])
+	if bump == BumpMajor && major == 0 {
+		bump = BumpMinor
+	}
+	switch bump {
+	case BumpMajor:
+		major, minor, patch = major+1, 0, 0
+	case BumpMinor:
+		minor, patch = minor+1, 0
+	default:
+		patch++
+	}
+	return fmt.Sprintf("%s%d.%d.%d", m[1], major, minor, patch)
+}
+
+// findVersionFiles returns the version files of the project with their current versions
+func findVersionFiles(path string) []VersionFile {
+	files := []VersionFile{}
+	fsys := os.DirFS(path)
+	for _, template := range versionFileTemplates {
+		matches, err := doublestar.Glob(fsys, template.glob)
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			data, err := os.ReadFile(filepath.Join(path, match))
+			if err != nil {
+				continue
+			}
+			if m := template.pattern.FindSubmatch(data); m != nil {
+				files = append(files, VersionFile{Path: match, Version: string(m[1])})
+			}
+		}
+	}
+	return files
+}
+
+// updateVersionFiles writes version into every version file of the project
+// and returns the changed paths. A "v" prefix is kept only where the file used one.
+func updateVersionFiles(path, version string) ([]string, error) {
+	var changed []string
+	for _, file := range findVersionFiles(path) {
+		template := versionTemplateFor(file.Path)
+		full := filepath.Join(path, file.Path)
+		data, err := os.ReadFile(full)
+		if err != nil {
+			return changed, err
+		}
+		value := strings.TrimPrefix(version, "v")
+		if strings.HasPrefix(file.Version, "v") {
+			value = "v" + value
+		}
+		if value == file.Version {
+			continue
+		}
+
+		loc := template.pattern.FindSubmatchIndex(data)
+		updated := append(append(append([]byte{}, data[:loc[2]]...), value...), data[loc[3]:]...)
+		info, err := os.Stat(full)
+		if err != nil {
+			return changed, err
+		}
+		if err := os.WriteFile(full, updated, info.Mode().Perm()); err != nil {
+			return changed, err
+		}
+		changed = append(changed, file.Path)
+	}
+	return changed, nil
+}
+
+// versionTemplateFor returns the template a version file was found with
+func versionTemplateFor(rel string) versionFileTemplate {
+	for _, template := range versionFileTemplates {
+		if ok, _ := doublestar.Match(template.glob, rel); ok {
+			return template
+		}
+	}
+	return versionFileTemplate{}
+}
@@ -0,0 +1,407 @@
+// watcher.go notices changes made to projects outside FORGE, e.g. edits in an
+// IDE or commits and branch switches in a terminal. It watches the working
+// tree of every git project (without .git and ignored directories) and the
+// repository's HEAD and branch refs with fsnotify. Once the changes settle,
+// the cached git status of the project is replaced and the board gets a
+// project_changed_externally event with the updated project. Changes while a
+// task of the project or its repository is running, or while FORGE holds the
+// repository lock, are FORGE's own and are left alone. The list of watched
+// projects follows the database every watchResyncInterval.
+// FORGE_WATCH_PROJECTS=false turns the watcher off.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long changes are collected before the board hears of them
+const watchDebounce = time.Second
+
+// watchResyncInterval is how often the watched projects are matched against the database
+const watchResyncInterval = 30 * time.Second
+
+// maxWatchedDirs limits the directories watched per project, inotify watches are limited
+const maxWatchedDirs = 4096
+
+// watchedProject is a project whose directories are watched
+type watchedProject struct {
+	path string // Project directory
+	root string // Root of its git repository
+}
+
+// projectWatcher watches the projects' directories for changes made outside FORGE
+type projectWatcher struct {
+	db     Store
+	hub    *Hub
+	runner *RalphRunner
+
+	fs       *fsnotify.Watcher
+	mu       sync.Mutex
+	projects map[string]*watchedProject // Project ID -> project
+	dirs     map[string]bool            // Watched directories
+	pending  map[string][]string        // Project ID -> changed paths, until the next flush
+	flushing bool
+}
+
+// watchProjectsEnabled reads FORGE_WATCH_PROJECTS (default true)
+func watchProjectsEnabled() bool {
+	return os.Getenv("FORGE_WATCH_PROJECTS") != "false"
+}
+
+// RunProjectWatcher watches the projects' directories until stop is closed
+func RunProjectWatcher(db Store, hub *Hub, runner *RalphRunner, stop <-chan struct{}) {
+	logger := componentLog("watcher")
+	if !watchProjectsEnabled() {
+		logger.Info("Project watcher disabled")
+		return
+	}
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Warn("Cannot watch projects", "err", err)
+		return
+	}
+	defer fs.Close()
+
+	w := &projectWatcher{
+		db:       db,
+		hub:      hub,
+		runner:   runner,
+		fs:       fs,
+		projects: make(map[string]*watchedProject),
+		dirs:     make(map[string]bool),
+		pending:  make(map[string][]string),
+	}
+	w.sync()
+
+	ticker := time.NewTicker(watchResyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-fs.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case err, ok := <-fs.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("Watch error", "err", err)
+		case <-ticker.C:
+			w.sync()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// sync starts watching new git projects and stops watching removed ones
+func (w *projectWatcher) sync() {
+	projects, err := w.db.GetAllProjects()
+	if err != nil {
+		componentLog("watcher").Error("Failed to list projects", "err", err)
+		return
+	}
+
+	wanted := make(map[string]string, len(projects))
+	for _, p := range projects {
+		if p.Path != "" && IsGitRepository(p.Path) {
+			path, _ := filepath.Abs(p.Path)
+			wanted[p.ID] = path
+		}
+	}
+
+	w.mu.Lock()
+	var added []string
+	for id, path := range wanted {
+		if existing, ok := w.projects[id]; !ok || existing.path != path {
+			w.projects[id] = &watchedProject{path: path, root: gitRoot(path)}
+			added = append(added, id)
+		}
+	}
+	removed := false
+	for id := range w.projects {
+		if _, ok := wanted[id]; !ok {
+			delete(w.projects, id)
+			removed = true
+		}
+	}
+	w.mu.Unlock()
+
+	for _, id := range added {
+		w.watchProject(id)
+	}
+	if removed {
+		w.unwatchOrphans()
+	}
+}
+
+// watchProject watches the working tree of a project and the refs of its repository
+func (w *projectWatcher) watchProject(id string) {
+	w.mu.Lock()
+	project := w.projects[id]
+	w.mu.Unlock()
+	if project == nil {
+		return
+	}
+
+	if project.root != "" {
+		gitDir := filepath.Join(project.root, ".git")
+		if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+			w.add(gitDir)
+			w.addTree(filepath.Join(gitDir, "refs", "heads"), nil)
+		}
+	}
+
+	ignored := ignoredDirs(project.path)
+	dirs := w.addTree(project.path, func(dir string) bool {
+		return ignored[dir] || filepath.Base(dir) == ".git"
+	})
+	if dirs >= maxWatchedDirs {
+		componentLog("watcher").Warn("Too many directories, watching only some", "project_id", id, "path", project.path, "limit", maxWatchedDirs)
+	}
+}
+
+// addTree watches dir and the directories below it that skip does not reject,
+// at most maxWatchedDirs. Returns how many were watched.
+func (w *projectWatcher) addTree(dir string, skip func(string) bool) int {
+	count := 0
+	filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if skip != nil && path != dir && skip(path) {
+			return filepath.SkipDir
+		}
+		if count >= maxWatchedDirs {
+			return filepath.SkipAll
+		}
+		w.add(path)
+		count++
+		return nil
+	})
+	return count
+}
+
+// add watches a directory
+func (w *projectWatcher) add(dir string) {
+	w.mu.Lock()
+	watched := w.dirs[dir]
+	w.mu.Unlock()
+	if watched {
+		return
+	}
+	if err := w.fs.Add(dir); err != nil {
+		componentLog("watcher").Debug("Cannot watch directory", "dir", dir, "err", err)
+		return
+	}
+	w.mu.Lock()
+	w.dirs[dir] = true
+	w.mu.Unlock()
+}
+
+// unwatchOrphans stops watching directories no watched project contains
+func (w *projectWatcher) unwatchOrphans() {
+	w.mu.Lock()
+	var orphans []string
+	for dir := range w.dirs {
+		if len(w.owners(dir)) == 0 {
+			orphans = append(orphans, dir)
+			delete(w.dirs, dir)
+		}
+	}
+	w.mu.Unlock()
+	for _, dir := range orphans {
+		w.fs.Remove(dir)
+	}
+}
+
+// owners returns the projects a changed path belongs to: the projects
+// containing it, or for a path in .git all projects of that repository.
+// Caller holds w.mu.
+func (w *projectWatcher) owners(path string) []string {
+	var ids []string
+	for id, project := range w.projects {
+		if project.root != "" && isWithin(path, filepath.Join(project.root, ".git")) {
+			ids = append(ids, id)
+		} else if isWithin(path, project.path) && !strings.Contains(path, string(filepath.Separator)+".git"+string(filepath.Separator)) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// handle records a change and schedules the flush
+func (w *projectWatcher) handle(event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod || !relevantChange(event.Name) {
+		return
+	}
+
+	// New directories are watched too (new branch ref directories included)
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() && filepath.Base(event.Name) != ".git" {
+			if !isIgnored(filepath.Dir(event.Name), []string{event.Name})[event.Name] {
+				ignored := ignoredDirs(event.Name)
+				w.addTree(event.Name, func(dir string) bool {
+					return ignored[dir] || filepath.Base(dir) == ".git"
+				})
+			}
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, id := range w.owners(event.Name) {
+		w.pending[id] = append(w.pending[id], event.Name)
+	}
+	if len(w.pending) > 0 && !w.flushing {
+		w.flushing = true
+		time.AfterFunc(watchDebounce, w.flush)
+	}
+}
+
+// relevantChange filters out changes that say nothing about the project:
+// lock files and the parts of .git other than HEAD and the branch refs
+func relevantChange(path string) bool {
+	if strings.HasSuffix(path, ".lock") {
+		return false
+	}
+	sep := string(filepath.Separator)
+	i := strings.LastIndex(path, sep+".git"+sep)
+	if i < 0 {
+		return !strings.HasSuffix(path, sep+".git")
+	}
+	rest := path[i+len(sep+".git"+sep):]
+	return rest == "HEAD" || rest == "packed-refs" || strings.HasPrefix(rest, "refs"+sep+"heads")
+}
+
+// flush tells the board about the projects that changed since the last flush
+func (w *projectWatcher) flush() {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = make(map[string][]string)
+	w.flushing = false
+	projects := make(map[string]watchedProject, len(pending))
+	for id := range pending {
+		if project, ok := w.projects[id]; ok {
+			projects[id] = *project
+		}
+	}
+	w.mu.Unlock()
+
+	busyProjects, busyRepos := w.runner.busyLanes()
+	for id, paths := range pending {
+		project, ok := projects[id]
+		if !ok {
+			continue
+		}
+		// A running task or a git operation of FORGE's own
+		if busyProjects[id] || busyRepos[repoKey(project.path)] || repoLockStatus(project.path) != nil {
+			continue
+		}
+		changed := externalChanges(project, paths)
+		if len(changed) == 0 {
+			continue
+		}
+
+		gitStatus.Refresh(project.path)
+		updated, err := w.db.GetProject(id)
+		if err != nil || updated == nil {
+			continue
+		}
+		componentLog("watcher").Debug("Project changed outside FORGE", "project_id", id, "paths", changed)
+		w.hub.BroadcastProjectChangedExternally(updated, describeChanges(changed))
+	}
+}
+
+// externalChanges returns the changed paths relative to the project, without
+// files git ignores. Changes in .git are reported as .git/....
+func externalChanges(project watchedProject, paths []string) []string {
+	seen := make(map[string]bool)
+	var tree []string
+	var changed []string
+	for _, path := range paths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if project.root != "" && isWithin(path, filepath.Join(project.root, ".git")) {
+			rel, _ := filepath.Rel(project.root, path)
+			changed = append(changed, rel)
+			continue
+		}
+		tree = append(tree, path)
+	}
+
+	ignored := isIgnored(project.path, tree)
+	for _, path := range tree {
+		if ignored[path] {
+			continue
+		}
+		if rel, err := filepath.Rel(project.path, path); err == nil {
+			changed = append(changed, rel)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// describeChanges summarizes changed paths for the board
+func describeChanges(paths []string) string {
+	const shown = 3
+	if len(paths) <= shown {
+		return "Changed outside FORGE: " + strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("Changed outside FORGE: %s and %d more", strings.Join(paths[:shown], ", "), len(paths)-shown)
+}
+
+// isIgnored returns which of paths git ignores in the repository at dir
+func isIgnored(dir string, paths []string) map[string]bool {
+	ignored := make(map[string]bool)
+	if len(paths) == 0 {
+		return ignored
+	}
+	cmd := exec.Command("git", "check-ignore", "--stdin", "-z")
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	// Exit code 1 only means that nothing is ignored
+	output, _ := cmd.Output()
+	for _, path := range bytes.Split(output, []byte{0}) {
+		if len(path) > 0 {
+			ignored[string(path)] = true
+		}
+	}
+	return ignored
+}
+
+// ignoredDirs returns the directories below dir that git ignores entirely
+func ignoredDirs(dir string) map[string]bool {
+	dirs := make(map[string]bool)
+	cmd := exec.Command("git", "ls-files", "--others", "--ignored", "--exclude-standard", "--directory", "-z")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return dirs
+	}
+	for _, rel := range bytes.Split(output, []byte{0}) {
+		if bytes.HasSuffix(rel, []byte("/")) {
+			dirs[filepath.Join(dir, string(rel))] = true
+		}
+	}
+	return dirs
+}
@@ -2,19 +2,35 @@ package main
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// Time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong message from the peer
+	pongWait = 60 * time.Second
+
+	// Send pings to peer with this period. Must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+
+	// Maximum size of a client message (subscribe/resume requests only)
+	maxMessageSize = 64 * 1024
+
+	// Number of events kept for replay after a reconnect
+	replayBufferSize = 2048
+)
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for local development
-	},
+	CheckOrigin:     checkWebSocketOrigin, // Own origin, loopback and FORGE_ALLOWED_ORIGINS
 }
 
 // Client represents a WebSocket client connection
@@ -22,24 +38,60 @@ type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
 	send chan []byte
+
+	// Subscriptions: until the client sends a subscribe message it receives everything.
+	// Afterwards a message is delivered if its type or its task ID is subscribed.
+	subMu    sync.Mutex
+	filtered bool
+	taskSubs map[string]bool
+	typeSubs map[string]bool
+}
+
+// hubMessage is a broadcast message plus the metadata used for subscription filtering
+type hubMessage struct {
+	data    []byte
+	msgType string // empty = deliver to every client
+	taskID  string
+	seq     int64 // 0 = not replayable
+}
+
+// resumeRequest asks the hub to replay events a reconnecting client missed
+type resumeRequest struct {
+	client  *Client
+	epoch   string
+	lastSeq int64
 }
 
 // Hub maintains the set of active clients and broadcasts messages
 type Hub struct {
 	clients    map[*Client]bool
-	broadcast  chan []byte
+	broadcast  chan hubMessage
 	register   chan *Client
 	unregister chan *Client
+	resume     chan resumeRequest
 	mu         sync.RWMutex
+
+	// Event sequence: seq numbers are assigned under seqMu together with the
+	// enqueue, so the broadcast channel is always in seq order. Seqs are only
+	// meaningful within one epoch (= one server process).
+	epoch  string
+	seqMu  sync.Mutex
+	seq    int64
+	replay []hubMessage // ring buffer of the last replayBufferSize events, owned by Run
+	next   int          // next write position in replay
+	last   int64        // seq of the newest event in replay
 }
 
 // NewHub creates a new Hub instance
 func NewHub() *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 256),
+		broadcast:  make(chan hubMessage, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		resume:     make(chan resumeRequest),
+		epoch:      uuid.New().String(),
+		replay:     make([]hubMessage, 0, replayBufferSize),
 	}
 }
 
@@ -51,7 +103,8 @@ func (h *Hub) Run() {
 			h.mu.Lock()
 			h.clients[client] = true
 			h.mu.Unlock()
-			log.Printf("WebSocket client connected. Total clients: %d", len(h.clients))
+			h.sendDirect(client, WSMessage{Type: "hello", Epoch: h.epoch, Seq: h.last})
+			componentLog("websocket").Debug("Client connected", "clients", len(h.clients))
 
 		case client := <-h.unregister:
 			h.mu.Lock()
@@ -60,30 +113,108 @@ func (h *Hub) Run() {
 				close(client.send)
 			}
 			h.mu.Unlock()
-			log.Printf("WebSocket client disconnected. Total clients: %d", len(h.clients))
+			componentLog("websocket").Debug("Client disconnected", "clients", len(h.clients))
+
+		case req := <-h.resume:
+			h.replayTo(req)
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
+			if message.seq > 0 {
+				h.remember(message)
+			}
+			h.mu.Lock()
 			for client := range h.clients {
+				if !client.wants(message) {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- message.data:
 				default:
 					// Client can't keep up, close connection
 					close(client.send)
 					delete(h.clients, client)
 				}
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
 		}
 	}
 }
 
-// Broadcast sends a message to all connected clients
+// remember stores an event in the replay ring buffer
+func (h *Hub) remember(message hubMessage) {
+	if len(h.replay) < replayBufferSize {
+		h.replay = append(h.replay, message)
+	} else {
+		h.replay[h.next] = message
+	}
+	h.next = (h.next + 1) % replayBufferSize
+	h.last = message.seq
+}
+
+// replayTo sends the client every buffered event after req.lastSeq that matches its
+// subscriptions. If the gap can't be filled (other epoch, events already evicted,
+// too many to queue) the client gets a "resync" and has to reload its state.
+func (h *Hub) replayTo(req resumeRequest) {
+	h.mu.RLock()
+	_, ok := h.clients[req.client]
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	var oldest int64
+	if len(h.replay) > 0 {
+		oldest = h.replay[h.next%len(h.replay)].seq
+	}
+	if req.epoch != h.epoch || req.lastSeq > h.last || (oldest > 0 && req.lastSeq < oldest-1) {
+		h.sendDirect(req.client, WSMessage{Type: "resync", Epoch: h.epoch, Seq: h.last})
+		return
+	}
+
+	var missed [][]byte
+	for i := 0; i < len(h.replay); i++ {
+		message := h.replay[(h.next+i)%len(h.replay)]
+		if message.seq > req.lastSeq && req.client.wants(message) {
+			missed = append(missed, message.data)
+		}
+	}
+	if len(missed) > cap(req.client.send)-len(req.client.send) {
+		h.sendDirect(req.client, WSMessage{Type: "resync", Epoch: h.epoch, Seq: h.last})
+		return
+	}
+	for _, data := range missed {
+		req.client.send <- data
+	}
+	if len(missed) > 0 {
+		componentLog("websocket").Debug("Client resumed", "replayed", len(missed))
+	}
+}
+
+// sendDirect sends a message to a single client, bypassing subscriptions.
+// Only called from Run, so client.send can't be closed concurrently.
+func (h *Hub) sendDirect(client *Client, msg WSMessage) {
+	data, err := jsonMarshal(msg)
+	if err != nil {
+		componentLog("websocket").Error("Failed to marshal message", "err", err)
+		return
+	}
+	select {
+	case client.send <- data:
+	default:
+	}
+}
+
+// Broadcast sends a message to all connected clients, regardless of their subscriptions
 func (h *Hub) Broadcast(message []byte) {
+	h.enqueue(hubMessage{data: message})
+}
+
+// enqueue hands a message to the hub loop without blocking the caller
+func (h *Hub) enqueue(message hubMessage) {
 	select {
 	case h.broadcast <- message:
 	default:
-		log.Println("Warning: broadcast channel full, message dropped")
+		componentLog("websocket").Warn("Broadcast channel full, message dropped")
 	}
 }
 
@@ -127,6 +258,17 @@ func (h *Hub) BroadcastProjectUpdate(project *Project) {
 	h.broadcastJSON(msg)
 }
 
+// BroadcastProjectChangedExternally announces changes made to a project
+// outside FORGE, with the project's new git status (see watcher.go)
+func (h *Hub) BroadcastProjectChangedExternally(project *Project, message string) {
+	msg := WSMessage{
+		Type:    "project_changed_externally",
+		Project: project,
+		Message: message,
+	}
+	h.broadcastJSON(msg)
+}
+
 // BroadcastBranchChange sends a branch change notification for a task
 func (h *Hub) BroadcastBranchChange(taskID string, branch string) {
 	msg := WSMessage{
@@ -147,6 +289,36 @@ func (h *Hub) BroadcastDeploymentSuccess(taskID string, message string) {
 	h.broadcastJSON(msg)
 }
 
+// BroadcastTaskIdle warns that a task's process has not written output for a while
+func (h *Hub) BroadcastTaskIdle(taskID string, message string) {
+	msg := WSMessage{
+		Type:    "task_idle",
+		TaskID:  taskID,
+		Message: message,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastSLAAlert warns that a task sits in its column longer than the column's SLA
+func (h *Hub) BroadcastSLAAlert(taskID string, message string) {
+	msg := WSMessage{
+		Type:    "sla_alert",
+		TaskID:  taskID,
+		Message: message,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastTaskTriaged announces why a blocked task got stuck (see triage.go)
+func (h *Hub) BroadcastTaskTriaged(taskID string, summary string) {
+	msg := WSMessage{
+		Type:    "task_triaged",
+		TaskID:  taskID,
+		Message: summary,
+	}
+	h.broadcastJSON(msg)
+}
+
 // BroadcastMergeConflict sends a merge conflict notification
 func (h *Hub) BroadcastMergeConflict(conflict *MergeConflict) {
 	msg := WSMessage{
@@ -158,13 +330,143 @@ func (h *Hub) BroadcastMergeConflict(conflict *MergeConflict) {
 	h.broadcastJSON(msg)
 }
 
+// BroadcastComment sends a created, updated or deleted comment (msgType comment_created/_updated/_deleted)
+func (h *Hub) BroadcastComment(msgType string, comment *TaskComment) {
+	msg := WSMessage{
+		Type:    msgType,
+		TaskID:  comment.TaskID,
+		Comment: comment,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastCloneProgress sends the progress of a project clone
+func (h *Hub) BroadcastCloneProgress(progress *CloneProgress) {
+	msg := WSMessage{
+		Type:  "clone_progress",
+		Clone: progress,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastScanProgress sends the progress of a project scan
+func (h *Hub) BroadcastScanProgress(progress *ScanProgress) {
+	msg := WSMessage{
+		Type: "scan_progress",
+		Scan: progress,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastBootstrapProgress sends the progress of a project bootstrap
+func (h *Hub) BroadcastBootstrapProgress(progress *BootstrapProgress) {
+	msg := WSMessage{
+		Type:      "bootstrap_progress",
+		Bootstrap: progress,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastReleaseUpdate sends a release whenever its status changes
+func (h *Hub) BroadcastReleaseUpdate(release *Release) {
+	msg := WSMessage{
+		Type:    "release_updated",
+		Release: release,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastDeploymentUpdate sends a deployment when it starts and when it finishes
+func (h *Hub) BroadcastDeploymentUpdate(deployment *Deployment) {
+	msg := WSMessage{
+		Type:       "deployment_updated",
+		TaskID:     deployment.TaskID,
+		Deployment: deployment,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastLabelsUpdate sends all labels after one was created, changed or deleted
+func (h *Hub) BroadcastLabelsUpdate(labels []Label) {
+	msg := WSMessage{
+		Type:   "labels_updated",
+		Labels: labels,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastQueueOrder sends the complete queue order after a reorder
+func (h *Hub) BroadcastQueueOrder(queue []QueueEntry) {
+	msg := WSMessage{
+		Type:  "queue_reordered",
+		Queue: queue,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastQueueState sends the queue state after it was paused or resumed
+func (h *Hub) BroadcastQueueState(state *QueueState) {
+	msg := WSMessage{
+		Type:       "queue_state",
+		QueueState: state,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastQueueCooldown sends the queue state when a rate limit made the queue cool down
+func (h *Hub) BroadcastQueueCooldown(state *QueueState, message string) {
+	msg := WSMessage{
+		Type:       "queue_cooldown",
+		Message:    message,
+		QueueState: state,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastBudgetAlert warns that a cost budget is nearly used up or exceeded (see budget.go)
+func (h *Hub) BroadcastBudgetAlert(status *BudgetStatus, message string) {
+	msg := WSMessage{
+		Type:    "budget_alert",
+		Message: message,
+		Budget:  status,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastColumnsUpdate sends the board columns after they were changed
+func (h *Hub) BroadcastColumnsUpdate(columns []BoardColumn) {
+	msg := WSMessage{
+		Type:    "columns_updated",
+		Columns: columns,
+	}
+	h.broadcastJSON(msg)
+}
+
+// BroadcastBoardsUpdate sends all boards after one was created, changed or deleted
+func (h *Hub) BroadcastBoardsUpdate(boards []Board) {
+	msg := WSMessage{
+		Type:   "boards_updated",
+		Boards: boards,
+	}
+	h.broadcastJSON(msg)
+}
+
 func (h *Hub) broadcastJSON(msg WSMessage) {
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+
+	msg.Seq = h.seq + 1
 	data, err := jsonMarshal(msg)
 	if err != nil {
-		log.Printf("Error marshaling WebSocket message: %v", err)
+		componentLog("websocket").Error("Failed to marshal message", "err", err)
 		return
 	}
-	h.Broadcast(data)
+	select {
+	case h.broadcast <- hubMessage{data: data, msgType: msg.Type, taskID: msg.TaskID, seq: msg.Seq}:
+		h.seq = msg.Seq
+	default:
+		componentLog("websocket").Warn("Broadcast channel full, message dropped")
+	}
 }
 
 // jsonMarshal is a helper to marshal JSON
@@ -176,7 +478,7 @@ func jsonMarshal(v interface{}) ([]byte, error) {
 func (h *Hub) ServeWs(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		logFrom(r.Context()).Warn("WebSocket upgrade failed", "component", "websocket", "err", err)
 		return
 	}
 
@@ -198,34 +500,115 @@ func (c *Client) readPump() {
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				componentLog("websocket").Warn("Connection error", "err", err)
 			}
 			break
 		}
-		// We don't process incoming messages for now, just keep connection alive
+		c.handleClientMessage(data)
 	}
 }
 
-// writePump pumps messages from the hub to the WebSocket connection
+// handleClientMessage applies a subscribe/unsubscribe/resume request from the client.
+// Invalid messages are ignored so old clients that send nothing keep working.
+func (c *Client) handleClientMessage(data []byte) {
+	var msg WSClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+
+	// Resume goes through the hub loop so the replay is ordered with live events
+	if msg.Action == "resume" {
+		c.hub.resume <- resumeRequest{client: c, epoch: msg.Epoch, lastSeq: msg.LastSeq}
+		return
+	}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+
+	switch msg.Action {
+	case "subscribe":
+		if !c.filtered {
+			c.filtered = true
+			c.taskSubs = make(map[string]bool)
+			c.typeSubs = make(map[string]bool)
+		}
+		for _, id := range msg.TaskIDs {
+			c.taskSubs[id] = true
+		}
+		for _, t := range msg.Types {
+			c.typeSubs[t] = true
+		}
+	case "unsubscribe":
+		for _, id := range msg.TaskIDs {
+			delete(c.taskSubs, id)
+		}
+		for _, t := range msg.Types {
+			delete(c.typeSubs, t)
+		}
+	case "subscribe_all":
+		c.filtered = false
+		c.taskSubs = nil
+		c.typeSubs = nil
+	}
+}
+
+// wants reports whether the message matches the client's subscriptions
+func (c *Client) wants(message hubMessage) bool {
+	if message.msgType == "" {
+		return true
+	}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+
+	if !c.filtered {
+		return true
+	}
+	if c.typeSubs[message.msgType] {
+		return true
+	}
+	return message.taskID != "" && c.taskSubs[message.taskID]
+}
+
+// writePump pumps messages from the hub to the WebSocket connection and
+// pings the peer so dead connections are detected by readPump's deadline
 func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
 	defer func() {
+		ticker.Stop()
 		c.conn.Close()
 	}()
 
 	for {
-		message, ok := <-c.send
-		if !ok {
-			// Hub closed the channel
-			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-			return
-		}
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// Hub closed the channel
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
 
-		if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			return
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
 		}
 	}
 }
@@ -0,0 +1,157 @@
+// workflow.go implements the branch-per-task workflow. Projects default to
+// trunk-based development, where every task works on the project's working
+// branch. With workflow "branch" the runner checks out working/<id>-<slug>
+// off the target branch for each task and, once RALPH succeeded, commits
+// what is left, pushes the branch and opens a pull request on GitHub. Trunk
+// projects get a pull request too when a task targets a branch other than
+// the default branch.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isValidWorkflow reports whether w is a known project workflow
+func isValidWorkflow(w string) bool {
+	return w == WorkflowTrunk || w == WorkflowBranch
+}
+
+// checkoutTaskBranch switches to the task's own branch in branch-per-task
+// projects. Call it after the target branch is checked out; returns "" for
+// trunk projects or on failure.
+func checkoutTaskBranch(projectDir string, task *Task, project *Project) string {
+	if project == nil || project.Workflow != WorkflowBranch {
+		return ""
+	}
+	branch, err := CheckoutTaskBranch(projectDir, task.ID, task.Title)
+	if err != nil {
+		componentLog("workflow").Warn("Failed to check out task branch", "task_id", task.ID, "err", err)
+		return ""
+	}
+	return branch
+}
+
+// taskPRBranches returns the head and base branch of the pull request a task
+// in review gets, or ok=false if it gets none. Branch-per-task projects merge
+// the task branch into its target; trunk projects only open a pull request if
+// the task's target branch differs from the default branch.
+func taskPRBranches(task *Task, project *Project) (head, base string, ok bool) {
+	target := task.TargetBranch
+	if target == "" {
+		target = project.WorkingBranch
+	}
+
+	if project.Workflow == WorkflowBranch {
+		if !strings.HasPrefix(task.WorkingBranch, "working/") {
+			return "", "", false
+		}
+		if target == "" {
+			target = GetDefaultBranch(project.Path)
+		}
+		return task.WorkingBranch, target, true
+	}
+
+	defaultBranch := GetDefaultBranch(project.Path)
+	if target == "" || target == defaultBranch {
+		return "", "", false
+	}
+	return target, defaultBranch, true
+}
+
+// publishTaskBranch pushes the branch of a successful task and opens (or finds)
+// its pull request. Failures are reported in the task log; the task stays in
+// review. prSync moves the task on once the pull request is merged or closed.
+func (r *RalphRunner) publishTaskBranch(taskID string) {
+	task, _ := r.db.GetTask(taskID)
+	if task == nil || task.Status != StatusReview || task.ProjectID == "" {
+		return
+	}
+	project, _ := r.db.GetProject(task.ProjectID)
+	if project == nil || !IsGitRepository(project.Path) {
+		return
+	}
+	head, base, ok := taskPRBranches(task, project)
+	if !ok {
+		return
+	}
+
+	logf := func(format string, args ...interface{}) {
+		msg := "[FORGE] " + fmt.Sprintf(format, args...) + "\n"
+		r.db.AppendTaskLogs(taskID, msg)
+		r.hub.BroadcastLog(taskID, msg)
+	}
+	defer lockRepo(project.Path, "publishing task branch")()
+	defer gitStatus.Invalidate(project.Path)
+
+	prURL, prNumber, err := r.openTaskPR(task, project, head, base)
+	if err != nil {
+		logf("Could not publish %s: %v", head, err)
+		return
+	}
+	if prURL == "" {
+		logf("No changes on %s, no pull request opened", head)
+		return
+	}
+
+	r.db.UpdateTaskPR(taskID, prURL, prNumber)
+	logf("Pull request #%d: %s", prNumber, prURL)
+	if updated, _ := r.db.GetTask(taskID); updated != nil {
+		r.hub.BroadcastTaskUpdate(updated)
+	}
+}
+
+// openTaskPR commits leftovers, pushes head and returns the pull request from
+// head into base. Returns an empty URL if head has nothing to merge.
+func (r *RalphRunner) openTaskPR(task *Task, project *Project, head, base string) (string, int, error) {
+	if err := EnsureOnBranch(project.Path, head); err != nil {
+		return "", 0, err
+	}
+	if hasChanges, _ := HasUncommittedChanges(project.Path); hasChanges {
+		if _, err := CommitAllChanges(project.Path, WithTaskTrailer(commitMessage(project, task, task.Title), task.ID)); err != nil {
+			return "", 0, err
+		}
+	}
+
+	if ahead, err := GetCommitsAhead(project.Path, head, base); err == nil && ahead == 0 {
+		return "", 0, nil
+	}
+
+	remoteURL, err := GetRemoteURL(project.Path)
+	if err != nil || remoteURL == "" {
+		return "", 0, fmt.Errorf("no remote origin configured")
+	}
+	if err := pushUnlessProtected(r.db, project.ID, project.Path); err != nil {
+		return "", 0, err
+	}
+
+	config, err := r.db.GetConfig()
+	if err != nil {
+		return "", 0, err
+	}
+	endpoints := githubEndpointsFor(config, project)
+	repoFullName, err := endpoints.parseRepo(remoteURL)
+	if err != nil {
+		return "", 0, fmt.Errorf("pushed, but the remote is not on GitHub; open the pull request manually")
+	}
+	if config.GithubToken == "" {
+		return "", 0, fmt.Errorf("pushed, but no GitHub token is configured to open the pull request")
+	}
+
+	client := endpoints.client(config.GithubToken)
+	owner := strings.Split(repoFullName, "/")[0]
+	if existing, _ := client.FindExistingPR(repoFullName, owner+":"+head, base); existing != nil {
+		return existing.HTMLURL, existing.Number, nil
+	}
+
+	body := task.Description
+	if body != "" {
+		body += "\n\n---\n"
+	}
+	body += fmt.Sprintf("*Created by FORGE for task %s*", task.ID)
+	pr, err := client.CreatePullRequest(repoFullName, task.Title, body, head, base)
+	if err != nil {
+		return "", 0, err
+	}
+	return pr.HTMLURL, pr.Number, nil
+}